@@ -0,0 +1,56 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+// The following constants identify networks of other cryptocurrencies which
+// are derived from bitcoin and share its wire format.  They are provided so
+// infrastructure which deals with several of them can use this package rather
+// than maintaining a fork per network.  Nothing in this package treats them
+// specially.
+//
+// Some of these networks differ from bitcoin in ways which require registration
+// before use.  Litecoin and Dogecoin use scrypt for proof-of-work, so
+// RegisterPowHash must be called with a scrypt implementation for
+// BlockHeader.PowHash to return meaningful results.  Namecoin and Dogecoin are
+// merged mined, so RegisterAuxPowNet must be called for their block and headers
+// messages to be decoded.
+const (
+	// LitecoinMainNet represents the main litecoin network.
+	LitecoinMainNet BitcoinNet = 0xdbb6c0fb
+
+	// LitecoinTestNet4 represents the litecoin test network (version 4).
+	LitecoinTestNet4 BitcoinNet = 0xf1c8d2fd
+
+	// DogecoinMainNet represents the main dogecoin network.
+	DogecoinMainNet BitcoinNet = 0xc0c0c0c0
+
+	// DogecoinTestNet3 represents the dogecoin test network (version 3).
+	DogecoinTestNet3 BitcoinNet = 0xdcb7c1fc
+
+	// NamecoinMainNet represents the main namecoin network.
+	NamecoinMainNet BitcoinNet = 0xfeb4bef9
+)
+
+const (
+	// LitecoinMainPort is the port used by default on the main litecoin
+	// network.
+	LitecoinMainPort = "9333"
+
+	// LitecoinTestNet4Port is the port used by default on the litecoin
+	// test network (version 4).
+	LitecoinTestNet4Port = "19335"
+
+	// DogecoinMainPort is the port used by default on the main dogecoin
+	// network.
+	DogecoinMainPort = "22556"
+
+	// DogecoinTestNet3Port is the port used by default on the dogecoin
+	// test network (version 3).
+	DogecoinTestNet3Port = "44556"
+
+	// NamecoinMainPort is the port used by default on the main namecoin
+	// network.
+	NamecoinMainPort = "8334"
+)
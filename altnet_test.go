@@ -0,0 +1,41 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"testing"
+)
+
+// TestAltNetMagic tests the alternate network constants are encoded on the
+// wire as the message start bytes used by the respective networks.
+func TestAltNetMagic(t *testing.T) {
+	tests := []struct {
+		in   btcwire.BitcoinNet // Network to encode
+		want []byte             // Expected message start bytes
+	}{
+		{btcwire.LitecoinMainNet, []byte{0xfb, 0xc0, 0xb6, 0xdb}},
+		{btcwire.LitecoinTestNet4, []byte{0xfd, 0xd2, 0xc8, 0xf1}},
+		{btcwire.DogecoinMainNet, []byte{0xc0, 0xc0, 0xc0, 0xc0}},
+		{btcwire.DogecoinTestNet3, []byte{0xfc, 0xc1, 0xb7, 0xdc}},
+		{btcwire.NamecoinMainNet, []byte{0xf9, 0xbe, 0xb4, 0xfe}},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		var buf bytes.Buffer
+		err := btcwire.WriteMessage(&buf, btcwire.NewMsgVerAck(),
+			btcwire.ProtocolVersion, test.in)
+		if err != nil {
+			t.Errorf("WriteMessage #%d error %v", i, err)
+			continue
+		}
+		if got := buf.Bytes()[:4]; !bytes.Equal(got, test.want) {
+			t.Errorf("WriteMessage #%d wrong magic - got %x, want %x",
+				i, got, test.want)
+		}
+	}
+}
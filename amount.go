@@ -0,0 +1,230 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	// ErrInvalidAmount describes an error that indicates an amount string
+	// passed to ParseAmount is not a decimal number, or has more
+	// fractional digits than the smallest amount of the unit allows.
+	ErrInvalidAmount = errors.New("invalid amount")
+
+	// ErrAmountRange describes an error that indicates an amount, or the
+	// result of arithmetic on amounts, is outside of the range of
+	// -MaxSatoshi to MaxSatoshi.
+	ErrAmountRange = errors.New("amount is out of range")
+)
+
+// AmountUnit is a unit used to format and parse an Amount.
+type AmountUnit int
+
+// These constants define the units an Amount may be formatted and parsed in.
+const (
+	AmountBTC AmountUnit = iota
+	AmountMilliBTC
+	AmountSatoshi
+)
+
+// amountUnitStrings is a map of amount units back to their names and the
+// number of satoshi decimal digits in one of the unit.
+var amountUnitStrings = map[AmountUnit]struct {
+	name     string
+	decimals int
+}{
+	AmountBTC:      {"BTC", 8},
+	AmountMilliBTC: {"mBTC", 5},
+	AmountSatoshi:  {"satoshi", 0},
+}
+
+// String returns the symbol of the unit in human-readable form.
+func (u AmountUnit) String() string {
+	if s, ok := amountUnitStrings[u]; ok {
+		return s.name
+	}
+	return fmt.Sprintf("Unknown AmountUnit (%d)", int(u))
+}
+
+// Amount is a quantity of bitcoin in satoshi, which is the unit of transaction
+// output values.  Keeping values in an Amount rather than a bare int64 makes
+// the unit explicit, and its methods convert to and from the units people use
+// and check arithmetic for values which are out of range.
+type Amount int64
+
+// formatAmount returns the provided value in satoshi as a decimal number of a
+// unit with the provided number of satoshi decimal digits, such as 0.50000000
+// for a value of 50000000 and 8 decimal digits.  Exactly that many fractional
+// digits are always included, so the result is exact.
+func formatAmount(value int64, decimals int) string {
+	sign := ""
+	abs := uint64(value)
+	if value < 0 {
+		sign = "-"
+		abs = uint64(-value)
+		if value == math.MinInt64 {
+			abs = 1 << 63
+		}
+	}
+	if decimals == 0 {
+		return fmt.Sprintf("%s%d", sign, abs)
+	}
+
+	scale := uint64(1)
+	for i := 0; i < decimals; i++ {
+		scale *= 10
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, abs/scale, decimals, abs%scale)
+}
+
+// Format returns the amount as a decimal number in the provided unit with
+// every fractional digit down to the satoshi, such as 0.50000000 for half a
+// bitcoin in AmountBTC or 50000.00000 in AmountMilliBTC.  The unit is not
+// included, so the result may be passed back to ParseAmount with the same
+// unit.  Unknown units are treated as AmountSatoshi.
+func (a Amount) Format(u AmountUnit) string {
+	return formatAmount(int64(a), amountUnitStrings[u].decimals)
+}
+
+// String returns the amount in bitcoin along with the unit, such as
+// "0.50000000 BTC".
+func (a Amount) String() string {
+	return a.Format(AmountBTC) + " " + AmountBTC.String()
+}
+
+// ParseAmount returns the amount represented by the provided decimal number in
+// the provided unit, such as "0.5" in AmountBTC for half a bitcoin.  The number
+// is parsed exactly rather than as a floating point number, so an amount never
+// changes by a satoshi when formatted and parsed again.
+//
+// ErrInvalidAmount is returned for strings which are not a decimal number with
+// an optional leading minus sign, and for numbers with a nonzero digit smaller
+// than a satoshi.  ErrAmountRange is returned for amounts outside of the range
+// of -MaxSatoshi to MaxSatoshi.
+func ParseAmount(s string, u AmountUnit) (Amount, error) {
+	unit, ok := amountUnitStrings[u]
+	if !ok {
+		return 0, ErrInvalidAmount
+	}
+
+	negative := len(s) > 0 && s[0] == '-'
+	if negative {
+		s = s[1:]
+	}
+
+	// Split the number into its integer and fractional digits, each of
+	// which must be present when there is a decimal point.
+	intPart, fracPart := s, ""
+	for i := 0; i < len(s); i++ {
+		if s[i] == '.' {
+			intPart, fracPart = s[:i], s[i+1:]
+			if fracPart == "" {
+				return 0, ErrInvalidAmount
+			}
+			break
+		}
+	}
+	if intPart == "" {
+		return 0, ErrInvalidAmount
+	}
+
+	// Digits smaller than a satoshi are only allowed when they are zero.
+	if len(fracPart) > unit.decimals {
+		for i := unit.decimals; i < len(fracPart); i++ {
+			if fracPart[i] != '0' {
+				return 0, ErrInvalidAmount
+			}
+		}
+		fracPart = fracPart[:unit.decimals]
+	}
+
+	var value uint64
+	for _, part := range []string{intPart, fracPart} {
+		for i := 0; i < len(part); i++ {
+			if part[i] < '0' || part[i] > '9' {
+				return 0, ErrInvalidAmount
+			}
+			value = value*10 + uint64(part[i]-'0')
+			if value > MaxSatoshi {
+				return 0, ErrAmountRange
+			}
+		}
+	}
+	for i := len(fracPart); i < unit.decimals; i++ {
+		value *= 10
+		if value > MaxSatoshi {
+			return 0, ErrAmountRange
+		}
+	}
+
+	if negative {
+		return -Amount(value), nil
+	}
+	return Amount(value), nil
+}
+
+// inRange returns whether the amount is within the range of -MaxSatoshi to
+// MaxSatoshi.
+func (a Amount) inRange() bool {
+	return a >= -MaxSatoshi && a <= MaxSatoshi
+}
+
+// Add returns the sum of the amount and b.  ErrAmountRange is returned when
+// either amount or the sum is outside of the range of -MaxSatoshi to
+// MaxSatoshi, so the sum can never overflow.
+func (a Amount) Add(b Amount) (Amount, error) {
+	if !a.inRange() || !b.inRange() {
+		return 0, ErrAmountRange
+	}
+	sum := a + b
+	if !sum.inRange() {
+		return 0, ErrAmountRange
+	}
+	return sum, nil
+}
+
+// Sub returns the amount minus b.  ErrAmountRange is returned when either
+// amount or the difference is outside of the range of -MaxSatoshi to
+// MaxSatoshi, so the difference can never overflow.
+func (a Amount) Sub(b Amount) (Amount, error) {
+	if !b.inRange() {
+		return 0, ErrAmountRange
+	}
+	return a.Add(-b)
+}
+
+// MulInt returns the amount multiplied by n.  ErrAmountRange is returned when
+// the amount or the product is outside of the range of -MaxSatoshi to
+// MaxSatoshi, so the product can never overflow.
+func (a Amount) MulInt(n int64) (Amount, error) {
+	if !a.inRange() {
+		return 0, ErrAmountRange
+	}
+	if a == 0 || n == 0 {
+		return 0, nil
+	}
+
+	// Any factor outside of the range results in a product outside of it
+	// as well.  Checking it first keeps its absolute value from
+	// overflowing, and comparing against the quotient of the range keeps
+	// the product from overflowing.
+	if !Amount(n).inRange() {
+		return 0, ErrAmountRange
+	}
+	absA, absN := int64(a), n
+	if absA < 0 {
+		absA = -absA
+	}
+	if absN < 0 {
+		absN = -absN
+	}
+	if absA > MaxSatoshi/absN {
+		return 0, ErrAmountRange
+	}
+	return a * Amount(n), nil
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"github.com/conformal/btcwire"
+	"math"
+	"testing"
+)
+
+// TestAmountFormat ensures amounts are formatted exactly in every unit.
+func TestAmountFormat(t *testing.T) {
+	tests := []struct {
+		amount  btcwire.Amount // Amount to format
+		btc     string         // Expected amount in BTC
+		mbtc    string         // Expected amount in mBTC
+		satoshi string         // Expected amount in satoshi
+	}{
+		{0, "0.00000000", "0.00000", "0"},
+		{1, "0.00000001", "0.00001", "1"},
+		{50000000, "0.50000000", "500.00000", "50000000"},
+		{123456789, "1.23456789", "1234.56789", "123456789"},
+		{btcwire.MaxSatoshi, "21000000.00000000", "21000000000.00000",
+			"2100000000000000"},
+		{-1, "-0.00000001", "-0.00001", "-1"},
+		{math.MinInt64, "-92233720368.54775808",
+			"-92233720368547.75808", "-9223372036854775808"},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		if got := test.amount.Format(btcwire.AmountBTC); got != test.btc {
+			t.Errorf("Format #%d BTC got: %s want: %s", i, got,
+				test.btc)
+		}
+		got := test.amount.Format(btcwire.AmountMilliBTC)
+		if got != test.mbtc {
+			t.Errorf("Format #%d mBTC got: %s want: %s", i, got,
+				test.mbtc)
+		}
+		got = test.amount.Format(btcwire.AmountSatoshi)
+		if got != test.satoshi {
+			t.Errorf("Format #%d satoshi got: %s want: %s", i, got,
+				test.satoshi)
+		}
+		if got := test.amount.String(); got != test.btc+" BTC" {
+			t.Errorf("String #%d got: %s want: %s", i, got,
+				test.btc+" BTC")
+		}
+	}
+}
+
+// TestAmountUnitStringer tests the stringized output for amount units.
+func TestAmountUnitStringer(t *testing.T) {
+	tests := []struct {
+		in   btcwire.AmountUnit
+		want string
+	}{
+		{btcwire.AmountBTC, "BTC"},
+		{btcwire.AmountMilliBTC, "mBTC"},
+		{btcwire.AmountSatoshi, "satoshi"},
+		{0xff, "Unknown AmountUnit (255)"},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		if result := test.in.String(); result != test.want {
+			t.Errorf("String #%d\n got: %s want: %s", i, result,
+				test.want)
+		}
+	}
+}
+
+// TestParseAmount ensures amounts are parsed exactly and invalid or out of
+// range amounts are rejected.
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in   string             // String to parse
+		unit btcwire.AmountUnit // Unit of the string
+		want btcwire.Amount     // Expected amount
+		err  error              // Expected error
+	}{
+		{"0", btcwire.AmountBTC, 0, nil},
+		{"1", btcwire.AmountBTC, 100000000, nil},
+		{"0.5", btcwire.AmountBTC, 50000000, nil},
+		{"0.00000001", btcwire.AmountBTC, 1, nil},
+		{"0.000000010", btcwire.AmountBTC, 1, nil},
+		{"-1.23456789", btcwire.AmountBTC, -123456789, nil},
+		{"21000000", btcwire.AmountBTC, btcwire.MaxSatoshi, nil},
+		{"-21000000.00000000", btcwire.AmountBTC, -btcwire.MaxSatoshi, nil},
+		{"1234.56789", btcwire.AmountMilliBTC, 123456789, nil},
+		{"0.1", btcwire.AmountMilliBTC, 10000, nil},
+		{"123456789", btcwire.AmountSatoshi, 123456789, nil},
+		{"5.0", btcwire.AmountSatoshi, 5, nil},
+
+		// Invalid numbers.
+		{"", btcwire.AmountBTC, 0, btcwire.ErrInvalidAmount},
+		{"-", btcwire.AmountBTC, 0, btcwire.ErrInvalidAmount},
+		{".5", btcwire.AmountBTC, 0, btcwire.ErrInvalidAmount},
+		{"1.", btcwire.AmountBTC, 0, btcwire.ErrInvalidAmount},
+		{"+1", btcwire.AmountBTC, 0, btcwire.ErrInvalidAmount},
+		{"1e8", btcwire.AmountBTC, 0, btcwire.ErrInvalidAmount},
+		{"1.2.3", btcwire.AmountBTC, 0, btcwire.ErrInvalidAmount},
+		{"1 BTC", btcwire.AmountBTC, 0, btcwire.ErrInvalidAmount},
+		{"1", 0xff, 0, btcwire.ErrInvalidAmount},
+
+		// Digits smaller than a satoshi.
+		{"0.000000001", btcwire.AmountBTC, 0, btcwire.ErrInvalidAmount},
+		{"0.000001", btcwire.AmountMilliBTC, 0, btcwire.ErrInvalidAmount},
+		{"1.5", btcwire.AmountSatoshi, 0, btcwire.ErrInvalidAmount},
+
+		// Out of range amounts.
+		{"21000000.00000001", btcwire.AmountBTC, 0, btcwire.ErrAmountRange},
+		{"-21000001", btcwire.AmountBTC, 0, btcwire.ErrAmountRange},
+		{"99999999999999999999999", btcwire.AmountSatoshi, 0,
+			btcwire.ErrAmountRange},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		got, err := btcwire.ParseAmount(test.in, test.unit)
+		if err != test.err {
+			t.Errorf("ParseAmount #%d (%q) wrong error - got: %v "+
+				"want: %v", i, test.in, err, test.err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseAmount #%d (%q) got: %d want: %d", i,
+				test.in, got, test.want)
+		}
+	}
+
+	// Ensure formatted amounts parse back to the same amount.
+	for _, amount := range []btcwire.Amount{0, 1, -1, 123456789,
+		btcwire.MaxSatoshi, -btcwire.MaxSatoshi} {
+
+		for _, unit := range []btcwire.AmountUnit{btcwire.AmountBTC,
+			btcwire.AmountMilliBTC, btcwire.AmountSatoshi} {
+
+			s := amount.Format(unit)
+			got, err := btcwire.ParseAmount(s, unit)
+			if err != nil || got != amount {
+				t.Errorf("ParseAmount(%q, %v) got: %d, %v want: "+
+					"%d", s, unit, got, err, amount)
+			}
+		}
+	}
+}
+
+// TestAmountArithmetic ensures arithmetic on amounts reports results out of
+// range rather than overflowing.
+func TestAmountArithmetic(t *testing.T) {
+	const max = btcwire.Amount(btcwire.MaxSatoshi)
+
+	tests := []struct {
+		name string
+		fn   func() (btcwire.Amount, error) // Operation to perform
+		want btcwire.Amount                 // Expected result
+		err  error                          // Expected error
+	}{
+		{"add", func() (btcwire.Amount, error) {
+			return btcwire.Amount(1).Add(2)
+		}, 3, nil},
+		{"add to max", func() (btcwire.Amount, error) {
+			return (max - 1).Add(1)
+		}, max, nil},
+		{"add past max", func() (btcwire.Amount, error) {
+			return max.Add(1)
+		}, 0, btcwire.ErrAmountRange},
+		{"add overflow", func() (btcwire.Amount, error) {
+			return btcwire.Amount(math.MaxInt64).Add(1)
+		}, 0, btcwire.ErrAmountRange},
+		{"sub", func() (btcwire.Amount, error) {
+			return btcwire.Amount(1).Sub(3)
+		}, -2, nil},
+		{"sub past min", func() (btcwire.Amount, error) {
+			return (-max).Sub(1)
+		}, 0, btcwire.ErrAmountRange},
+		{"sub min int64", func() (btcwire.Amount, error) {
+			return btcwire.Amount(0).Sub(math.MinInt64)
+		}, 0, btcwire.ErrAmountRange},
+		{"mul", func() (btcwire.Amount, error) {
+			return btcwire.Amount(-3).MulInt(7)
+		}, -21, nil},
+		{"mul zero", func() (btcwire.Amount, error) {
+			return btcwire.Amount(0).MulInt(math.MinInt64)
+		}, 0, nil},
+		{"mul to max", func() (btcwire.Amount, error) {
+			return btcwire.Amount(btcwire.MaxSatoshi / 21).MulInt(21)
+		}, max, nil},
+		{"mul past max", func() (btcwire.Amount, error) {
+			return btcwire.Amount(btcwire.MaxSatoshi/21 + 1).MulInt(21)
+		}, 0, btcwire.ErrAmountRange},
+		{"mul overflow", func() (btcwire.Amount, error) {
+			return max.MulInt(btcwire.MaxSatoshi)
+		}, 0, btcwire.ErrAmountRange},
+		{"mul min int64", func() (btcwire.Amount, error) {
+			return btcwire.Amount(1).MulInt(math.MinInt64)
+		}, 0, btcwire.ErrAmountRange},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for _, test := range tests {
+		got, err := test.fn()
+		if err != test.err {
+			t.Errorf("%s: wrong error - got: %v want: %v", test.name,
+				err, test.err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got: %d want: %d", test.name, got,
+				test.want)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// AuxPowVersionBit is the bit in the block version which indicates a block
+// header is followed by an auxiliary proof-of-work (AuxPow) on networks which
+// support merged mining.
+const AuxPowVersionBit uint32 = 1 << 8
+
+// maxAuxPowBranchLen is the maximum number of hashes allowed in either of the
+// merkle branches of an auxiliary proof-of-work.  A branch of this length is
+// enough to prove membership in a merkle tree with over 4 billion leaves.
+const maxAuxPowBranchLen = 32
+
+// auxPowNets houses the networks registered via RegisterAuxPowNet.
+var (
+	auxPowNetsMtx sync.RWMutex
+	auxPowNets    = make(map[BitcoinNet]struct{})
+)
+
+// RegisterAuxPowNet marks the provided network as one which supports merged
+// mining.  Block headers read from messages on registered networks which have
+// AuxPowVersionBit set in their version are expected to be followed by an
+// auxiliary proof-of-work, which is decoded into the AuxPow field of the
+// header.  Messages on all other networks, including the bitcoin networks, are
+// not affected.
+//
+// This is intended to be called during initialization by applications which
+// work with merged-mined chains.
+func RegisterAuxPowNet(btcnet BitcoinNet) {
+	auxPowNetsMtx.Lock()
+	auxPowNets[btcnet] = struct{}{}
+	auxPowNetsMtx.Unlock()
+}
+
+// IsAuxPowNet returns whether or not the provided network has been registered
+// as supporting merged mining via RegisterAuxPowNet.
+func IsAuxPowNet(btcnet BitcoinNet) bool {
+	auxPowNetsMtx.RLock()
+	_, ok := auxPowNets[btcnet]
+	auxPowNetsMtx.RUnlock()
+	return ok
+}
+
+// AuxPow defines the auxiliary proof-of-work which follows the block header of
+// merged-mined blocks.  It proves the block hash was committed to by the
+// coinbase transaction of a parent block, typically on another chain, which
+// satisfies the proof-of-work requirement.
+type AuxPow struct {
+	// Coinbase transaction of the parent block which commits to the hash
+	// of the merged-mined block.
+	CoinbaseTx MsgTx
+
+	// Hash of the parent block.
+	ParentHash ShaHash
+
+	// Merkle branch linking the coinbase transaction to the merkle root of
+	// the parent block along with the index of the coinbase transaction.
+	CoinbaseBranch []ShaHash
+	CoinbaseIndex  int32
+
+	// Merkle branch linking the merged-mined block to the root committed
+	// to in the coinbase transaction along with the index of the block
+	// within the tree of merged-mined chains.
+	BlockchainBranch []ShaHash
+	BlockchainIndex  int32
+
+	// Header of the parent block.  The transaction count is not part of
+	// the encoding and is always zero.
+	ParentBlock BlockHeader
+}
+
+// Equal returns whether the auxiliary proof-of-work has the same contents as
+// other.  Two nil proofs are considered equal, while a nil proof is not equal
+// to any other.
+func (ap *AuxPow) Equal(other *AuxPow) bool {
+	if ap == nil || other == nil {
+		return ap == other
+	}
+	return ap.CoinbaseTx.Equal(&other.CoinbaseTx) &&
+		ap.ParentHash == other.ParentHash &&
+		auxPowBranchEqual(ap.CoinbaseBranch, other.CoinbaseBranch) &&
+		ap.CoinbaseIndex == other.CoinbaseIndex &&
+		auxPowBranchEqual(ap.BlockchainBranch, other.BlockchainBranch) &&
+		ap.BlockchainIndex == other.BlockchainIndex &&
+		ap.ParentBlock.Equal(&other.ParentBlock)
+}
+
+// auxPowBranchEqual returns whether the provided merkle branches are the same.
+func auxPowBranchEqual(a, b []ShaHash) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// readAuxPowBranch reads a merkle branch of an auxiliary proof-of-work from r.
+func readAuxPowBranch(r io.Reader, pver uint32) ([]ShaHash, error) {
+	count, err := readVarInt(r, pver)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prevent a merkle branch larger than is possible for any valid block
+	// since it would otherwise be possible to cause memory exhaustion.
+	if count > maxAuxPowBranchLen {
+		str := fmt.Sprintf("too many hashes in auxpow merkle branch "+
+			"[count %d, max %d]", count, maxAuxPowBranchLen)
+		return nil, categorizedError("readAuxPow", str,
+			ErrCategoryOversized)
+	}
+
+	err = reserveAlloc(r, "readAuxPow", "auxpow merkle branch hashes",
+		count*HashSize)
+	if err != nil {
+		return nil, err
+	}
+
+	branch := make([]ShaHash, count)
+	for i := range branch {
+		err := readElement(r, &branch[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return branch, nil
+}
+
+// writeAuxPowBranch writes a merkle branch of an auxiliary proof-of-work to w.
+func writeAuxPowBranch(w io.Writer, pver uint32, branch []ShaHash) error {
+	count := len(branch)
+	if count > maxAuxPowBranchLen {
+		str := fmt.Sprintf("too many hashes in auxpow merkle branch "+
+			"[count %d, max %d]", count, maxAuxPowBranchLen)
+		return messageError("writeAuxPow", str)
+	}
+
+	err := writeVarInt(w, pver, uint64(count))
+	if err != nil {
+		return err
+	}
+	for i := range branch {
+		err := writeElement(w, &branch[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readAuxPow reads an auxiliary proof-of-work from r.
+func readAuxPow(r io.Reader, pver uint32, ap *AuxPow) error {
+	err := ap.CoinbaseTx.BtcDecode(r, pver)
+	if err != nil {
+		return err
+	}
+
+	err = readElement(r, &ap.ParentHash)
+	if err != nil {
+		return err
+	}
+
+	ap.CoinbaseBranch, err = readAuxPowBranch(r, pver)
+	if err != nil {
+		return err
+	}
+	index, err := binarySerializer.Uint32(r, littleEndian)
+	if err != nil {
+		return err
+	}
+	ap.CoinbaseIndex = int32(index)
+
+	ap.BlockchainBranch, err = readAuxPowBranch(r, pver)
+	if err != nil {
+		return err
+	}
+	index, err = binarySerializer.Uint32(r, littleEndian)
+	if err != nil {
+		return err
+	}
+	ap.BlockchainIndex = int32(index)
+
+	// The parent block header does not include the transaction count.
+	return readBlockHeaderFields(r, &ap.ParentBlock)
+}
+
+// auxPowSerializeSize returns the number of bytes it would take to write the
+// auxiliary proof-of-work with writeAuxPow.
+func auxPowSerializeSize(pver uint32, ap *AuxPow) int {
+	// Coinbase transaction + parent hash + serialized merkle branches and
+	// their indexes 4 bytes each + parent block header.
+	return ap.CoinbaseTx.SerializeSize(pver) + HashSize +
+		varIntSerializeSize(uint64(len(ap.CoinbaseBranch))) +
+		len(ap.CoinbaseBranch)*HashSize + 4 +
+		varIntSerializeSize(uint64(len(ap.BlockchainBranch))) +
+		len(ap.BlockchainBranch)*HashSize + 4 + blockHashLen
+}
+
+// validateAuxPow returns an error attributed to the function f when the
+// auxiliary proof-of-work would be rejected by writeAuxPow.
+func validateAuxPow(f string, ap *AuxPow) error {
+	for _, branch := range [][]ShaHash{ap.CoinbaseBranch, ap.BlockchainBranch} {
+		if len(branch) > maxAuxPowBranchLen {
+			str := fmt.Sprintf("too many hashes in auxpow merkle "+
+				"branch [count %d, max %d]", len(branch),
+				maxAuxPowBranchLen)
+			return messageError(f, str)
+		}
+	}
+	return ap.CoinbaseTx.Validate()
+}
+
+// writeAuxPow writes an auxiliary proof-of-work to w.
+func writeAuxPow(w io.Writer, pver uint32, ap *AuxPow) error {
+	err := ap.CoinbaseTx.BtcEncode(w, pver)
+	if err != nil {
+		return err
+	}
+
+	err = writeElement(w, &ap.ParentHash)
+	if err != nil {
+		return err
+	}
+
+	err = writeAuxPowBranch(w, pver, ap.CoinbaseBranch)
+	if err != nil {
+		return err
+	}
+	err = binarySerializer.PutUint32(w, littleEndian,
+		uint32(ap.CoinbaseIndex))
+	if err != nil {
+		return err
+	}
+
+	err = writeAuxPowBranch(w, pver, ap.BlockchainBranch)
+	if err != nil {
+		return err
+	}
+	err = binarySerializer.PutUint32(w, littleEndian,
+		uint32(ap.BlockchainIndex))
+	if err != nil {
+		return err
+	}
+
+	// The parent block header does not include the transaction count.
+	return writeBlockHeaderFields(w, &ap.ParentBlock)
+}
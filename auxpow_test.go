@@ -0,0 +1,222 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// auxPowTestNet is a network used to test merged mining support.  It is
+// registered as supporting auxiliary proof-of-work by the tests.
+const auxPowTestNet btcwire.BitcoinNet = 0xfeb4bef0
+
+// newAuxPowHeader returns a block header for a merged-mined block which uses
+// the first transaction of block one as the parent coinbase.
+func newAuxPowHeader() *btcwire.BlockHeader {
+	bh := blockOne.Header
+	bh.Version |= btcwire.AuxPowVersionBit
+	bh.TxnCount = 0
+	bh.AuxPow = &btcwire.AuxPow{
+		CoinbaseTx:       *blockOne.Transactions[0],
+		ParentHash:       btcwire.ShaHash{0x01},
+		CoinbaseBranch:   []btcwire.ShaHash{{0x02}, {0x03}},
+		CoinbaseIndex:    0,
+		BlockchainBranch: []btcwire.ShaHash{{0x04}},
+		BlockchainIndex:  1,
+		ParentBlock: btcwire.BlockHeader{
+			Version:    2,
+			PrevBlock:  btcwire.ShaHash{0x05},
+			MerkleRoot: btcwire.ShaHash{0x06},
+			Timestamp:  time.Unix(0x495fab29, 0),
+			Bits:       0x1d00ffff,
+			Nonce:      0x12345678,
+		},
+	}
+	return &bh
+}
+
+// TestAuxPowNet tests registering networks which support merged mining.
+func TestAuxPowNet(t *testing.T) {
+	if btcwire.IsAuxPowNet(btcwire.MainNet) {
+		t.Errorf("IsAuxPowNet: main network reported as supporting " +
+			"auxpow")
+	}
+
+	btcwire.RegisterAuxPowNet(auxPowTestNet)
+	if !btcwire.IsAuxPowNet(auxPowTestNet) {
+		t.Errorf("IsAuxPowNet: registered network not reported as " +
+			"supporting auxpow")
+	}
+}
+
+// TestAuxPowWire tests the encode and decode of block headers with an
+// auxiliary proof-of-work in the messages which contain them.
+func TestAuxPowWire(t *testing.T) {
+	btcwire.RegisterAuxPowNet(auxPowTestNet)
+	pver := btcwire.ProtocolVersion
+
+	headers := btcwire.NewMsgHeaders()
+	headers.AddBlockHeader(newAuxPowHeader())
+
+	block := btcwire.NewMsgBlock(newAuxPowHeader())
+	block.AddTransaction(blockOne.Transactions[0])
+	block.Header.TxnCount = 1
+
+	tests := []btcwire.Message{headers, block}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		var buf bytes.Buffer
+		err := btcwire.WriteMessage(&buf, test, pver, auxPowTestNet)
+		if err != nil {
+			t.Errorf("WriteMessage #%d error %v", i, err)
+			continue
+		}
+		raw := buf.Bytes()
+
+		// Ensure the reported size includes the auxiliary
+		// proof-of-work.
+		size := test.SerializeSize(pver)
+		if size != len(raw)-btcwire.MessageHeaderSize {
+			t.Errorf("SerializeSize #%d got: %d want: %d", i, size,
+				len(raw)-btcwire.MessageHeaderSize)
+		}
+
+		msg, _, err := btcwire.ReadMessage(bytes.NewReader(raw), pver,
+			auxPowTestNet)
+		if err != nil {
+			t.Errorf("ReadMessage #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(msg, test) {
+			t.Errorf("ReadMessage #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test))
+			continue
+		}
+	}
+
+	// Ensure blocks stored with an auxiliary proof-of-work can be
+	// deserialized.
+	var buf bytes.Buffer
+	if err := block.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	var stored btcwire.MsgBlock
+	if err := stored.DeserializeAuxPow(&buf); err != nil {
+		t.Fatalf("DeserializeAuxPow: %v", err)
+	}
+	if !reflect.DeepEqual(&stored, block) {
+		t.Errorf("DeserializeAuxPow\n got: %s want: %s",
+			spew.Sdump(&stored), spew.Sdump(block))
+	}
+
+	// Ensure decoding a plain block into the same block clears the
+	// auxiliary proof-of-work and the result round trips.
+	err := stored.DeserializeAuxPow(bytes.NewReader(blockOneBytes))
+	if err != nil {
+		t.Fatalf("DeserializeAuxPow: %v", err)
+	}
+	if stored.Header.AuxPow != nil {
+		t.Errorf("DeserializeAuxPow: auxpow not cleared - got %s",
+			spew.Sdump(stored.Header.AuxPow))
+	}
+	if size := stored.SerializeSize(0); size != len(blockOneBytes) {
+		t.Errorf("SerializeSize: wrong size - got %d, want %d", size,
+			len(blockOneBytes))
+	}
+	buf.Reset()
+	if err := stored.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), blockOneBytes) {
+		t.Errorf("Serialize\n got: %s want: %s",
+			spew.Sdump(buf.Bytes()), spew.Sdump(blockOneBytes))
+	}
+
+	// Ensure the auxiliary proof-of-work does not affect the block hash.
+	noAux := *newAuxPowHeader()
+	noAux.AuxPow = nil
+	wantSha, _ := noAux.BlockSha()
+	gotSha, _ := block.Header.BlockSha()
+	if !gotSha.IsEqual(&wantSha) {
+		t.Errorf("BlockSha: wrong hash - got %v, want %v",
+			spew.Sprint(gotSha), spew.Sprint(wantSha))
+	}
+}
+
+// TestAuxPowWireErrors tests the error paths of encoding and decoding block
+// headers with an auxiliary proof-of-work.
+func TestAuxPowWireErrors(t *testing.T) {
+	btcwire.RegisterAuxPowNet(auxPowTestNet)
+	pver := btcwire.ProtocolVersion
+
+	// Encoding an auxiliary proof-of-work without the version bit set
+	// must fail since the remote peer would be unable to decode it.
+	bh := newAuxPowHeader()
+	bh.Version &^= btcwire.AuxPowVersionBit
+	headers := btcwire.NewMsgHeaders()
+	headers.AddBlockHeader(bh)
+	var buf bytes.Buffer
+	err := btcwire.WriteMessage(&buf, headers, pver, auxPowTestNet)
+	if _, ok := err.(*btcwire.MessageError); !ok {
+		t.Errorf("WriteMessage: did not receive expected error for "+
+			"missing auxpow version bit - got %v (%T)", err, err)
+	}
+
+	// Decoding a merkle branch which is too long must fail.
+	bh = newAuxPowHeader()
+	bh.AuxPow.CoinbaseBranch = make([]btcwire.ShaHash, 33)
+	var encoded bytes.Buffer
+	err = btcwire.TstWriteBlockHeader(&encoded, pver, bh)
+	if _, ok := err.(*btcwire.MessageError); !ok {
+		t.Errorf("writeBlockHeader: did not receive expected error "+
+			"for oversized branch - got %v (%T)", err, err)
+	}
+	bh.AuxPow.CoinbaseBranch = make([]btcwire.ShaHash, 32)
+	encoded.Reset()
+	err = btcwire.TstWriteBlockHeader(&encoded, pver, bh)
+	if err != nil {
+		t.Fatalf("writeBlockHeader: %v", err)
+	}
+	raw := encoded.Bytes()
+
+	// The coinbase branch length follows the header, the coinbase
+	// transaction, and the parent hash.
+	branchLenOffset := 80 + bh.AuxPow.CoinbaseTx.SerializeSize(0) +
+		btcwire.HashSize
+	raw[branchLenOffset] = 33
+	var decoded btcwire.BlockHeader
+	err = btcwire.TstReadBlockHeader(
+		btcwire.TstAuxPowReader(bytes.NewReader(raw)), pver, &decoded)
+	merr, ok := err.(*btcwire.MessageError)
+	if !ok || merr.Category != btcwire.ErrCategoryOversized {
+		t.Errorf("BtcDecode: did not receive expected oversized error "+
+			"- got %v (%T)", err, err)
+	}
+
+	// Headers with the auxpow version bit set on networks which do not
+	// support merged mining are decoded as plain headers, so the
+	// auxiliary proof-of-work is not consumed and decoding fails.
+	headers = btcwire.NewMsgHeaders()
+	headers.AddBlockHeader(newAuxPowHeader())
+	buf.Reset()
+	err = btcwire.WriteMessage(&buf, headers, pver, auxPowTestNet)
+	if err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+	raw = buf.Bytes()
+	btcwire.TstSetMessageNet(raw, btcwire.MainNet)
+	_, _, err = btcwire.ReadMessage(bytes.NewReader(raw), pver,
+		btcwire.MainNet)
+	if err == nil {
+		t.Errorf("ReadMessage: unexpected success decoding auxpow " +
+			"headers on the main network")
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"bytes"
 	"github.com/conformal/btcwire"
 	"io/ioutil"
+	"net"
 	"testing"
+	"time"
 )
 
 // BenchmarkWriteVarInt1 performs a benchmark on how long it takes to write
@@ -239,6 +241,20 @@ func BenchmarkDeserializeTx(b *testing.B) {
 	}
 }
 
+// BenchmarkDeserializeTxPooled performs a benchmark on how long it takes to
+// deserialize a transaction obtained via AcquireMsgTx and release it again.
+func BenchmarkDeserializeTxPooled(b *testing.B) {
+	var buf bytes.Buffer
+	blockOne.Transactions[0].Serialize(&buf)
+	r := bytes.NewReader(buf.Bytes())
+	for i := 0; i < b.N; i++ {
+		r.Seek(0, 0)
+		tx := btcwire.AcquireMsgTx()
+		tx.Deserialize(r)
+		btcwire.ReleaseMsgTx(tx)
+	}
+}
+
 // BenchmarkSerializeTx performs a benchmark on how long it takes to serialize
 // a transaction.
 func BenchmarkSerializeTx(b *testing.B) {
@@ -281,3 +297,112 @@ func BenchmarkWriteBlockHeader(b *testing.B) {
 		btcwire.TstWriteBlockHeader(ioutil.Discard, 0, &header)
 	}
 }
+
+// benchHeaders returns a headers message with the maximum number of block
+// headers allowed per message.
+func benchHeaders() *btcwire.MsgHeaders {
+	msg := btcwire.NewMsgHeaders()
+	prevHash := blockOne.Header.PrevBlock
+	for i := 0; i < btcwire.MaxBlockHeadersPerMsg; i++ {
+		bh := btcwire.NewBlockHeader(&prevHash,
+			&blockOne.Header.MerkleRoot, blockOne.Header.Bits,
+			uint32(i))
+		msg.AddBlockHeader(bh)
+		prevHash, _ = bh.BlockSha()
+	}
+	return msg
+}
+
+// benchAddr returns an addr message with the maximum number of addresses
+// allowed per message.
+func benchAddr() *btcwire.MsgAddr {
+	msg := btcwire.NewMsgAddr()
+	for i := 0; i < btcwire.MaxAddrPerMsg; i++ {
+		ip := net.IPv4(10, byte(i>>16), byte(i>>8), byte(i))
+		na := btcwire.NewNetAddressIPPort(ip, 8333,
+			btcwire.SFNodeNetwork)
+		na.Timestamp = time.Unix(0x495fab29+int64(i), 0)
+		msg.AddAddress(na)
+	}
+	return msg
+}
+
+// BenchmarkEncodeHeaders performs a benchmark on how long it takes to encode
+// a headers message with the maximum number of block headers.
+func BenchmarkEncodeHeaders(b *testing.B) {
+	pver := btcwire.ProtocolVersion
+	msg := benchHeaders()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		msg.BtcEncode(ioutil.Discard, pver)
+	}
+}
+
+// BenchmarkDecodeHeaders performs a benchmark on how long it takes to decode
+// a headers message with the maximum number of block headers.
+func BenchmarkDecodeHeaders(b *testing.B) {
+	pver := btcwire.ProtocolVersion
+	var buf bytes.Buffer
+	if err := benchHeaders().BtcEncode(&buf, pver); err != nil {
+		b.Fatalf("BtcEncode: %v", err)
+	}
+	r := bytes.NewReader(buf.Bytes())
+	b.SetBytes(int64(buf.Len()))
+	b.ResetTimer()
+
+	var msg btcwire.MsgHeaders
+	for i := 0; i < b.N; i++ {
+		r.Seek(0, 0)
+		msg.BtcDecode(r, pver)
+	}
+}
+
+// BenchmarkEncodeAddr performs a benchmark on how long it takes to encode an
+// addr message with the maximum number of addresses.
+func BenchmarkEncodeAddr(b *testing.B) {
+	pver := btcwire.ProtocolVersion
+	msg := benchAddr()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		msg.BtcEncode(ioutil.Discard, pver)
+	}
+}
+
+// BenchmarkDecodeAddr performs a benchmark on how long it takes to decode an
+// addr message with the maximum number of addresses.
+func BenchmarkDecodeAddr(b *testing.B) {
+	pver := btcwire.ProtocolVersion
+	var buf bytes.Buffer
+	if err := benchAddr().BtcEncode(&buf, pver); err != nil {
+		b.Fatalf("BtcEncode: %v", err)
+	}
+	r := bytes.NewReader(buf.Bytes())
+	b.SetBytes(int64(buf.Len()))
+	b.ResetTimer()
+
+	var msg btcwire.MsgAddr
+	for i := 0; i < b.N; i++ {
+		r.Seek(0, 0)
+		msg.BtcDecode(r, pver)
+	}
+}
+
+// BenchmarkReadBlockHeaders performs a benchmark on how long it takes to decode
+// the maximum number of block headers allowed per message directly into a
+// slice.
+func BenchmarkReadBlockHeaders(b *testing.B) {
+	var buf bytes.Buffer
+	if err := benchHeaders().BtcEncode(&buf, 0); err != nil {
+		b.Fatalf("BtcEncode: %v", err)
+	}
+	// Skip the varint for the number of headers.
+	r := bytes.NewReader(buf.Bytes()[3:])
+	b.SetBytes(int64(r.Len()))
+	b.ResetTimer()
+
+	headers := make([]btcwire.BlockHeader, btcwire.MaxBlockHeadersPerMsg)
+	for i := 0; i < b.N; i++ {
+		r.Seek(0, 0)
+		btcwire.ReadBlockHeaders(r, headers)
+	}
+}
@@ -0,0 +1,329 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// blockFileRecordHeaderSize is the number of bytes which precede each block in
+// a block file.  Bitcoin network (magic) 4 bytes + block length 4 bytes.
+const blockFileRecordHeaderSize = 8
+
+// DefaultMaxBlockFileSize is the maximum size of the block files written by the
+// reference implementation, which is used by BlockFileWriter when no other
+// maximum is specified.
+const DefaultMaxBlockFileSize = 128 * 1024 * 1024
+
+// BlockFileReader reads the blocks stored in the block files (blk*.dat) of the
+// reference implementation.  Each block in a file is preceded by the network
+// magic and the length of the serialized block as a 4 byte little-endian
+// integer.  The reference implementation preallocates its files, so the space
+// between and after blocks may be filled with zeros, which is skipped.
+type BlockFileReader struct {
+	r      *bufio.Reader
+	btcnet BitcoinNet
+	magic  [4]byte
+	offset int64
+}
+
+// NewBlockFileReader returns a new BlockFileReader which reads blocks of the
+// provided bitcoin network from r.  The blocks are decoded as belonging to a
+// network which supports merged mining when btcnet has been registered via
+// RegisterAuxPowNet.
+func NewBlockFileReader(r io.Reader, btcnet BitcoinNet) *BlockFileReader {
+	br := &BlockFileReader{
+		r:      bufio.NewReader(r),
+		btcnet: btcnet,
+	}
+	littleEndian.PutUint32(br.magic[:], uint32(btcnet))
+	return br
+}
+
+// Offset returns the number of bytes read from the underlying reader so far,
+// which is the offset in the file of the data which follows the last block
+// returned.
+func (r *BlockFileReader) Offset() int64 {
+	return r.offset
+}
+
+// NextRaw returns the serialized form of the next block in the file along with
+// its offset in the file.  The offset is of the serialized block itself rather
+// than the magic and length which precede it, which matches the block
+// positions recorded in the block index of the reference implementation.
+//
+// io.EOF is returned once there are no more blocks, including when the file
+// ends with zero padding, while io.ErrUnexpectedEOF is returned when the file
+// ends part way through a block.  A MessageError is returned when data other
+// than padding is found where a block should start or the length of the block
+// exceeds MaxMessagePayload, since the position of the next block can't be
+// determined reliably in either case.
+func (r *BlockFileReader) NextRaw() ([]byte, int64, error) {
+	// Skip any zero padding until the magic of the next block.
+	for {
+		hdr, err := r.r.Peek(4)
+		if len(hdr) == 0 {
+			return nil, 0, err
+		}
+		if err != nil && err != io.EOF {
+			return nil, 0, err
+		}
+		if bytes.Equal(hdr, r.magic[:]) {
+			break
+		}
+		if hdr[0] != 0x00 {
+			if err != nil && bytes.HasPrefix(r.magic[:], hdr) {
+				return nil, 0, io.ErrUnexpectedEOF
+			}
+			str := fmt.Sprintf("unexpected data %x at offset %d is not "+
+				"the magic of network %v", hdr, r.offset,
+				r.btcnet)
+			return nil, 0, categorizedError("BlockFileReader.NextRaw",
+				str, ErrCategoryMalformed)
+		}
+
+		// Skip all of the buffered zeros at once unless the magic
+		// itself starts with a zero.
+		n := 1
+		if r.magic[0] != 0x00 {
+			buffered, _ := r.r.Peek(r.r.Buffered())
+			for n < len(buffered) && buffered[n] == 0x00 {
+				n++
+			}
+		}
+		r.r.Discard(n)
+		r.offset += int64(n)
+	}
+
+	var hdr [blockFileRecordHeaderSize]byte
+	n, err := io.ReadFull(r.r, hdr[:])
+	r.offset += int64(n)
+	if err != nil {
+		return nil, 0, unexpectedEOF(err)
+	}
+	length := littleEndian.Uint32(hdr[4:])
+	if length > MaxMessagePayload {
+		str := fmt.Sprintf("block at offset %d is too large - length "+
+			"indicates %d bytes, but max message payload is %d bytes",
+			r.offset, length, MaxMessagePayload)
+		return nil, 0, categorizedError("BlockFileReader.NextRaw", str,
+			ErrCategoryOversized)
+	}
+
+	offset := r.offset
+	raw := make([]byte, length)
+	n, err = io.ReadFull(r.r, raw)
+	r.offset += int64(n)
+	if err != nil {
+		return nil, 0, unexpectedEOF(err)
+	}
+	return raw, offset, nil
+}
+
+// Next returns the next block in the file decoded into a MsgBlock along with
+// its offset in the file.  See NextRaw for details of the offset and the errors
+// returned while locating the block.  When the block fails to decode, the
+// error is returned after the block has been consumed, so Next may be called
+// again to continue with the block which follows it.
+func (r *BlockFileReader) Next() (*MsgBlock, int64, error) {
+	raw, offset, err := r.NextRaw()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var block MsgBlock
+	pr := &payloadReader{
+		Reader:          bytes.NewReader(raw),
+		auxPow:          IsAuxPowNet(r.btcnet),
+		maxBlockPayload: uint32(len(raw)),
+	}
+	err = block.BtcDecode(pr, 0)
+	if err != nil {
+		return nil, offset, err
+	}
+	return &block, offset, nil
+}
+
+// BlockFileName returns the name of the block file with the provided number
+// using the naming scheme of the reference implementation, such as
+// blk00000.dat for the first file.
+func BlockFileName(num int) string {
+	return fmt.Sprintf("blk%05d.dat", num)
+}
+
+// BlockFilePos identifies the location of a block written by a
+// BlockFileWriter.
+type BlockFilePos struct {
+	// FileNum is the number of the block file.  See BlockFileName.
+	FileNum int
+
+	// Offset is the offset in the file of the serialized block, which
+	// follows the magic and length which precede it.  This is the offset
+	// returned by BlockFileReader when the file is read back.
+	Offset int64
+}
+
+// BlockFileWriter appends blocks to a sequence of block files in a directory
+// in the format of the block files (blk*.dat) of the reference implementation,
+// so the files can be imported by it via reindexing or read back with
+// BlockFileReader.  Writing moves on to the next file in the sequence once a
+// block would grow the current one beyond the maximum file size.
+type BlockFileWriter struct {
+	dir     string
+	btcnet  BitcoinNet
+	maxSize int64
+	fileNum int
+	file    *os.File
+	size    int64
+}
+
+// NewBlockFileWriter returns a new BlockFileWriter which appends blocks of the
+// provided bitcoin network to the block files in dir with files limited to
+// maxSize bytes.  A maxSize of zero or less means DefaultMaxBlockFileSize.
+//
+// Writing resumes at the end of the last file of the existing sequence, if
+// any, which starts with blk00000.dat.  Blocks larger than the maximum file
+// size are written to a file of their own rather than rejected.
+func NewBlockFileWriter(dir string, btcnet BitcoinNet, maxSize int64) (*BlockFileWriter, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxBlockFileSize
+	}
+
+	// Find the last file of the existing sequence.
+	fileNum := 0
+	for {
+		_, err := os.Stat(filepath.Join(dir, BlockFileName(fileNum+1)))
+		if err != nil {
+			if os.IsNotExist(err) {
+				break
+			}
+			return nil, err
+		}
+		fileNum++
+	}
+
+	w := &BlockFileWriter{
+		dir:     dir,
+		btcnet:  btcnet,
+		maxSize: maxSize,
+	}
+	err := w.openFile(fileNum)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// openFile closes the current file, if any, and opens the file with the
+// provided number for appending.
+func (w *BlockFileWriter) openFile(num int) error {
+	if w.file != nil {
+		err := w.file.Close()
+		w.file = nil
+		if err != nil {
+			return err
+		}
+	}
+
+	path := filepath.Join(w.dir, BlockFileName(num))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND,
+		0644)
+	if err != nil {
+		return err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	w.file = file
+	w.fileNum = num
+	w.size = fi.Size()
+	return nil
+}
+
+// WriteBlock appends the provided block to the current block file, moving on
+// to the next file first when it would exceed the maximum file size, and
+// returns the position it was written at.
+func (w *BlockFileWriter) WriteBlock(block *MsgBlock) (BlockFilePos, error) {
+	var buf bytes.Buffer
+	buf.Grow(blockFileRecordHeaderSize + block.SerializeSize(0))
+	buf.Write(make([]byte, blockFileRecordHeaderSize))
+	err := block.BtcEncode(&buf, 0)
+	if err != nil {
+		return BlockFilePos{}, err
+	}
+	return w.writeRecord(buf.Bytes())
+}
+
+// WriteRawBlock appends the provided serialized block in the same manner as
+// WriteBlock.  The block is not validated, so this is intended for copying
+// blocks which are known to be valid, such as those returned by
+// BlockFileReader.NextRaw.
+func (w *BlockFileWriter) WriteRawBlock(raw []byte) (BlockFilePos, error) {
+	rec := make([]byte, blockFileRecordHeaderSize, blockFileRecordHeaderSize+
+		len(raw))
+	return w.writeRecord(append(rec, raw...))
+}
+
+// writeRecord fills in the magic and length of the provided record, which
+// must have space reserved for them at the front, and appends it to the
+// appropriate block file with a single write.
+func (w *BlockFileWriter) writeRecord(rec []byte) (BlockFilePos, error) {
+	if w.file == nil {
+		return BlockFilePos{}, os.ErrClosed
+	}
+	length := len(rec) - blockFileRecordHeaderSize
+	if length > MaxMessagePayload {
+		str := fmt.Sprintf("block is too large - encoded %d bytes, but "+
+			"max message payload is %d bytes", length,
+			MaxMessagePayload)
+		return BlockFilePos{}, messageError("BlockFileWriter.WriteBlock",
+			str)
+	}
+
+	if w.size > 0 && w.size+int64(len(rec)) > w.maxSize {
+		err := w.openFile(w.fileNum + 1)
+		if err != nil {
+			return BlockFilePos{}, err
+		}
+	}
+
+	littleEndian.PutUint32(rec[0:4], uint32(w.btcnet))
+	littleEndian.PutUint32(rec[4:8], uint32(length))
+	pos := BlockFilePos{
+		FileNum: w.fileNum,
+		Offset:  w.size + blockFileRecordHeaderSize,
+	}
+	n, err := w.file.Write(rec)
+	w.size += int64(n)
+	if err != nil {
+		return BlockFilePos{}, err
+	}
+	return pos, nil
+}
+
+// Sync commits the contents of the current block file to stable storage.
+func (w *BlockFileWriter) Sync() error {
+	if w.file == nil {
+		return os.ErrClosed
+	}
+	return w.file.Sync()
+}
+
+// Close closes the current block file.  Further writes fail.
+func (w *BlockFileWriter) Close() error {
+	if w.file == nil {
+		return os.ErrClosed
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
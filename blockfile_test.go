@@ -0,0 +1,267 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// blockFileRecord returns the provided serialized block framed as it is stored
+// in a block file for the provided network.
+func blockFileRecord(btcnet btcwire.BitcoinNet, raw []byte) []byte {
+	rec := make([]byte, 8, 8+len(raw))
+	rec[0] = byte(btcnet)
+	rec[1] = byte(btcnet >> 8)
+	rec[2] = byte(btcnet >> 16)
+	rec[3] = byte(btcnet >> 24)
+	rec[4] = byte(len(raw))
+	rec[5] = byte(len(raw) >> 8)
+	rec[6] = byte(len(raw) >> 16)
+	rec[7] = byte(len(raw) >> 24)
+	return append(rec, raw...)
+}
+
+// TestBlockFileReader tests reading blocks and their offsets from a block file
+// with zero padding between and after the blocks.
+func TestBlockFileReader(t *testing.T) {
+	btcnet := btcwire.MainNet
+	rec := blockFileRecord(btcnet, blockOneBytes)
+
+	var file bytes.Buffer
+	file.Write(rec)
+	file.Write(make([]byte, 10))
+	file.Write(rec)
+	file.Write(make([]byte, 8192))
+
+	wantOffsets := []int64{8, int64(len(rec)) + 10 + 8}
+
+	r := btcwire.NewBlockFileReader(&file, btcnet)
+	t.Logf("Running %d tests", len(wantOffsets))
+	for i, want := range wantOffsets {
+		block, offset, err := r.Next()
+		if err != nil {
+			t.Fatalf("Next #%d error %v", i, err)
+		}
+		if offset != want {
+			t.Errorf("Next #%d wrong offset got: %d want: %d", i,
+				offset, want)
+		}
+		if !reflect.DeepEqual(block, &blockOne) {
+			t.Errorf("Next #%d\n got: %s want: %s", i,
+				spew.Sdump(block), spew.Sdump(&blockOne))
+		}
+	}
+
+	_, _, err := r.Next()
+	if err != io.EOF {
+		t.Errorf("Next: wrong error at end got: %v want: %v", err,
+			io.EOF)
+	}
+	wantEnd := int64(2*len(rec) + 10 + 8192)
+	if r.Offset() != wantEnd {
+		t.Errorf("Offset: wrong end offset got: %d want: %d",
+			r.Offset(), wantEnd)
+	}
+}
+
+// TestBlockFileReaderErrors performs negative tests against reading block
+// files to confirm error paths work correctly.
+func TestBlockFileReaderErrors(t *testing.T) {
+	btcnet := btcwire.MainNet
+	rec := blockFileRecord(btcnet, blockOneBytes)
+
+	oversized := blockFileRecord(btcnet, nil)
+	oversized[7] = 0xff
+
+	tests := []struct {
+		name string
+		file []byte
+		err  error                 // Expected error
+		cat  btcwire.ErrorCategory // Expected category of MessageError
+	}{
+		{"truncated magic", rec[:2], io.ErrUnexpectedEOF, 0},
+		{"truncated length", rec[:6], io.ErrUnexpectedEOF, 0},
+		{"truncated block", rec[:len(rec)-1], io.ErrUnexpectedEOF, 0},
+		{"wrong network", blockFileRecord(btcwire.TestNet3,
+			blockOneBytes), nil, btcwire.ErrCategoryMalformed},
+		{"garbage after padding", []byte{0x00, 0x00, 0x01, 0x02, 0x03,
+			0x04}, nil, btcwire.ErrCategoryMalformed},
+		{"oversized", oversized, nil, btcwire.ErrCategoryOversized},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		r := btcwire.NewBlockFileReader(bytes.NewReader(test.file),
+			btcnet)
+		_, _, err := r.Next()
+		if test.err != nil {
+			if err != test.err {
+				t.Errorf("Next #%d (%s) wrong error got: %v want: %v",
+					i, test.name, err, test.err)
+			}
+			continue
+		}
+		merr, ok := err.(*btcwire.MessageError)
+		if !ok || merr.Category != test.cat {
+			t.Errorf("Next #%d (%s) wrong error got: %v want "+
+				"category: %v", i, test.name, err, test.cat)
+		}
+	}
+
+	// A block which fails to decode is skipped so the following block may
+	// still be read.
+	var file bytes.Buffer
+	file.Write(blockFileRecord(btcnet, blockOneBytes[:100]))
+	file.Write(rec)
+	r := btcwire.NewBlockFileReader(&file, btcnet)
+	if _, _, err := r.Next(); err == nil {
+		t.Errorf("Next: truncated block did not fail to decode")
+	}
+	block, offset, err := r.Next()
+	if err != nil {
+		t.Fatalf("Next: block after bad block error %v", err)
+	}
+	if want := int64(8 + 100 + 8); offset != want {
+		t.Errorf("Next: wrong offset after bad block got: %d want: %d",
+			offset, want)
+	}
+	if !reflect.DeepEqual(block, &blockOne) {
+		t.Errorf("Next: wrong block after bad block got: %s",
+			spew.Sdump(block))
+	}
+
+	// The raw block is returned as stored.
+	r = btcwire.NewBlockFileReader(bytes.NewReader(rec), btcnet)
+	raw, _, err := r.NextRaw()
+	if err != nil || !bytes.Equal(raw, blockOneBytes) {
+		t.Errorf("NextRaw: got: %x, %v want: %x", raw, err,
+			blockOneBytes)
+	}
+}
+
+// TestBlockFileWriter tests writing blocks across multiple block files and
+// reading them back.
+func TestBlockFileWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "btcwireblockfile")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	btcnet := btcwire.MainNet
+	recLen := int64(len(blockFileRecord(btcnet, blockOneBytes)))
+
+	// Two blocks fit in each file.
+	w, err := btcwire.NewBlockFileWriter(dir, btcnet, recLen*2)
+	if err != nil {
+		t.Fatalf("NewBlockFileWriter: %v", err)
+	}
+	tests := []btcwire.BlockFilePos{
+		{0, 8},
+		{0, recLen + 8},
+		{1, 8},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, want := range tests {
+		var pos btcwire.BlockFilePos
+		if i%2 == 0 {
+			pos, err = w.WriteBlock(&blockOne)
+		} else {
+			pos, err = w.WriteRawBlock(blockOneBytes)
+		}
+		if err != nil {
+			t.Fatalf("WriteBlock #%d error %v", i, err)
+		}
+		if pos != want {
+			t.Errorf("WriteBlock #%d wrong position got: %+v want: "+
+				"%+v", i, pos, want)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := w.WriteBlock(&blockOne); err == nil {
+		t.Errorf("WriteBlock: no error after close")
+	}
+
+	// Writing resumes at the end of the last file.
+	w, err = btcwire.NewBlockFileWriter(dir, btcnet, recLen*2)
+	if err != nil {
+		t.Fatalf("NewBlockFileWriter: %v", err)
+	}
+	pos, err := w.WriteBlock(&blockOne)
+	if err != nil {
+		t.Fatalf("WriteBlock: resumed error %v", err)
+	}
+	if want := (btcwire.BlockFilePos{1, recLen + 8}); pos != want {
+		t.Errorf("WriteBlock: wrong resumed position got: %+v want: %+v",
+			pos, want)
+	}
+	w.Close()
+
+	// Read the files back.
+	for num, count := range []int{2, 2} {
+		f, err := os.Open(filepath.Join(dir, btcwire.BlockFileName(num)))
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		r := btcwire.NewBlockFileReader(f, btcnet)
+		for i := 0; i < count; i++ {
+			block, offset, err := r.Next()
+			if err != nil {
+				t.Fatalf("Next file %d #%d error %v", num, i, err)
+			}
+			if want := int64(i)*recLen + 8; offset != want {
+				t.Errorf("Next file %d #%d wrong offset got: %d "+
+					"want: %d", num, i, offset, want)
+			}
+			if !reflect.DeepEqual(block, &blockOne) {
+				t.Errorf("Next file %d #%d wrong block got: %s",
+					num, i, spew.Sdump(block))
+			}
+		}
+		if _, _, err := r.Next(); err != io.EOF {
+			t.Errorf("Next file %d: wrong error at end got: %v",
+				num, err)
+		}
+		f.Close()
+	}
+}
+
+// TestBlockFileWriterLargeBlock ensures blocks larger than the maximum file
+// size are written to a file of their own.
+func TestBlockFileWriterLargeBlock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "btcwireblockfile")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := btcwire.NewBlockFileWriter(dir, btcwire.MainNet, 10)
+	if err != nil {
+		t.Fatalf("NewBlockFileWriter: %v", err)
+	}
+	defer w.Close()
+
+	for i := 0; i < 3; i++ {
+		pos, err := w.WriteBlock(&blockOne)
+		if err != nil {
+			t.Fatalf("WriteBlock #%d error %v", i, err)
+		}
+		if want := (btcwire.BlockFilePos{i, 8}); pos != want {
+			t.Errorf("WriteBlock #%d wrong position got: %+v want: "+
+				"%+v", i, pos, want)
+		}
+	}
+}
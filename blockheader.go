@@ -5,7 +5,7 @@
 package btcwire
 
 import (
-	"bytes"
+	"fmt"
 	"io"
 	"time"
 )
@@ -13,6 +13,23 @@ import (
 // BlockVersion is the current latest supported block version.
 const BlockVersion uint32 = 2
 
+const (
+	// maxFutureBlockTime is the amount of time the timestamp of a block
+	// header may be ahead of the reference time passed to Validate, which
+	// is the same as the reference implementation allows.
+	maxFutureBlockTime = 2 * time.Hour
+
+	// maxKnownBlockVersion is the highest block version introduced before
+	// block versions were repurposed for signaling soft fork deployments.
+	maxKnownBlockVersion = 4
+
+	// versionBitsTopMask and versionBitsTopBits are the mask of the top
+	// three bits of a block version and their value in the version bits
+	// form used to signal soft fork deployments (BIP0009).
+	versionBitsTopMask = 0xe0000000
+	versionBitsTopBits = 0x20000000
+)
+
 // Version 4 bytes + Timestamp 4 bytes + Bits 4 bytes + Nonce 4 bytes +
 // TxnCount (varInt) + PrevBlock and MerkleRoot hashes.
 const maxBlockHeaderPayload = 16 + maxVarIntPayload + (HashSize * 2)
@@ -43,6 +60,12 @@ type BlockHeader struct {
 	// (MsgHeaders) message, this must be 0.  This is encoded as a variable
 	// length integer on the wire.
 	TxnCount uint64
+
+	// Auxiliary proof-of-work for merged-mined blocks.  It is only encoded
+	// when non-nil, in which case AuxPowVersionBit must be set in Version,
+	// and it is only decoded on networks registered via RegisterAuxPowNet.
+	// It is always nil for bitcoin block headers.
+	AuxPow *AuxPow
 }
 
 // blockHashLen is a constant that represents how much of the block header is
@@ -51,16 +74,12 @@ const blockHashLen = 80
 
 // BlockSha computes the block identifier hash for the given block header.
 func (h *BlockHeader) BlockSha() (ShaHash, error) {
-	// Encode the header and run double sha256 everything prior to the
-	// number of transactions.  Ignore the error returns since there is no
-	// way the encode could fail except being out of memory which would
-	// cause a run-time panic.  Also, SetBytes can't fail here due to the
-	// fact DoubleSha256 always returns a []byte of the right size
-	// regardless of input.
-	var buf bytes.Buffer
-	var sha ShaHash
-	_ = writeBlockHeader(&buf, 0, h)
-	_ = sha.SetBytes(DoubleSha256(buf.Bytes()[0:blockHashLen]))
+	// Encode everything prior to the number of transactions directly
+	// into the hasher.  Ignore the error return since writing to a
+	// HashWriter never fails.
+	hw := NewHashWriter()
+	_ = writeBlockHeaderFields(hw, h)
+	sha := hw.Sum()
 
 	// Even though this function can't currently fail, it still returns
 	// a potential error to help future proof the API should a failure
@@ -68,9 +87,76 @@ func (h *BlockHeader) BlockSha() (ShaHash, error) {
 	return sha, nil
 }
 
+// Equal returns whether the block header has the same contents as other,
+// including its transaction count and auxiliary proof-of-work.  Two nil
+// headers are considered equal, while a nil header is not equal to any other.
+func (h *BlockHeader) Equal(other *BlockHeader) bool {
+	if h == nil || other == nil {
+		return h == other
+	}
+	return h.Version == other.Version && h.PrevBlock == other.PrevBlock &&
+		h.MerkleRoot == other.MerkleRoot &&
+		h.Timestamp.Equal(other.Timestamp) && h.Bits == other.Bits &&
+		h.Nonce == other.Nonce && h.TxnCount == other.TxnCount &&
+		h.AuxPow.Equal(other.AuxPow)
+}
+
+// Validate checks the block header for rules which do not depend on the rest
+// of the chain, so obviously invalid headers can be discarded as soon as they
+// are decoded.  The header is valid when:
+//   - its timestamp is at most two hours after the provided reference time,
+//     or the time reported by the Clock set via SetClock when the reference
+//     time is zero
+//   - its Bits decode to a positive target which fits in 256 bits
+//   - its version uses the version bits form of BIP0009, or is one of
+//     versions 1 through 4 once the chain ID and auxpow version bit of
+//     merged-mined headers are ignored
+//   - any auxiliary proof-of-work could be encoded, which requires the auxpow
+//     version bit to be set in the version
+//
+// A MessageError describing the first rule the header violates is returned.
+// Rules which need other headers, such as the proof-of-work and the timestamp
+// relative to previous headers, are checked by VerifyHeaderChain instead.
+func (h *BlockHeader) Validate(refTime time.Time) error {
+	if refTime.IsZero() {
+		refTime = now()
+	}
+	if h.Timestamp.After(refTime.Add(maxFutureBlockTime)) {
+		str := fmt.Sprintf("block header timestamp is too far in the "+
+			"future [timestamp %v, max %v]", h.Timestamp,
+			refTime.Add(maxFutureBlockTime))
+		return messageError("BlockHeader.Validate", str)
+	}
+
+	target := CompactToBig(h.Bits)
+	if target.Sign() <= 0 || target.BitLen() > 256 {
+		str := fmt.Sprintf("block header bits do not decode to a valid "+
+			"target [bits %#08x]", h.Bits)
+		return messageError("BlockHeader.Validate", str)
+	}
+
+	// Versions in the version bits form may signal any bit, including the
+	// one used as the auxpow version bit, so they are checked before the
+	// chain ID and auxpow version bit of merged-mined versions are
+	// stripped to find the base version.
+	version := h.Version
+	isVersionBits := version&versionBitsTopMask == versionBitsTopBits
+	if !isVersionBits && version&AuxPowVersionBit != 0 {
+		version &= AuxPowVersionBit - 1
+	}
+	if !isVersionBits && (version < 1 || version > maxKnownBlockVersion) {
+		str := fmt.Sprintf("block header version is not a known form "+
+			"[version %#x]", h.Version)
+		return messageError("BlockHeader.Validate", str)
+	}
+
+	return validateBlockHeader("BlockHeader.Validate", h)
+}
+
 // NewBlockHeader returns a new BlockHeader using the provided previous block
 // hash, merkle root hash, difficulty bits, and nonce used to generate the
-// block with defaults for the remaining fields.
+// block with defaults for the remaining fields.  The timestamp is the current
+// time as reported by the Clock set via SetClock.
 func NewBlockHeader(prevHash *ShaHash, merkleRootHash *ShaHash, bits uint32,
 	nonce uint32) *BlockHeader {
 
@@ -78,22 +164,97 @@ func NewBlockHeader(prevHash *ShaHash, merkleRootHash *ShaHash, bits uint32,
 		Version:    BlockVersion,
 		PrevBlock:  *prevHash,
 		MerkleRoot: *merkleRootHash,
-		Timestamp:  time.Now(),
+		Timestamp:  now(),
 		Bits:       bits,
 		Nonce:      nonce,
 		TxnCount:   0,
 	}
 }
 
+// readBlockHeaderFields reads the fixed size fields of a bitcoin block header,
+// which are the fields hashed to produce the block hash, from r.
+func readBlockHeaderFields(r io.Reader, bh *BlockHeader) error {
+	var err error
+	bh.Version, err = binarySerializer.Uint32(r, littleEndian)
+	if err != nil {
+		return err
+	}
+	_, err = io.ReadFull(r, bh.PrevBlock[:])
+	if err != nil {
+		return err
+	}
+	_, err = io.ReadFull(r, bh.MerkleRoot[:])
+	if err != nil {
+		return err
+	}
+	sec, err := binarySerializer.Uint32(r, littleEndian)
+	if err != nil {
+		return err
+	}
+	bh.Timestamp = time.Unix(int64(sec), 0)
+	bh.Bits, err = binarySerializer.Uint32(r, littleEndian)
+	if err != nil {
+		return err
+	}
+	bh.Nonce, err = binarySerializer.Uint32(r, littleEndian)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// writeBlockHeaderFields writes the fixed size fields of a bitcoin block
+// header, which are the fields hashed to produce the block hash, to w.
+func writeBlockHeaderFields(w io.Writer, bh *BlockHeader) error {
+	err := binarySerializer.PutUint32(w, littleEndian, bh.Version)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(bh.PrevBlock[:])
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(bh.MerkleRoot[:])
+	if err != nil {
+		return err
+	}
+	sec := uint32(bh.Timestamp.Unix())
+	err = binarySerializer.PutUint32(w, littleEndian, sec)
+	if err != nil {
+		return err
+	}
+	err = binarySerializer.PutUint32(w, littleEndian, bh.Bits)
+	if err != nil {
+		return err
+	}
+	err = binarySerializer.PutUint32(w, littleEndian, bh.Nonce)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // readBlockHeader reads a bitcoin block header from r.
 func readBlockHeader(r io.Reader, pver uint32, bh *BlockHeader) error {
-	var sec uint32
-	err := readElements(r, &bh.Version, &bh.PrevBlock, &bh.MerkleRoot, &sec,
-		&bh.Bits, &bh.Nonce)
+	err := readBlockHeaderFields(r, bh)
 	if err != nil {
 		return err
 	}
-	bh.Timestamp = time.Unix(int64(sec), 0)
+
+	// Read the auxiliary proof-of-work for merged-mined blocks on networks
+	// which support it.  Clear any left over from a previous decode into
+	// the same header first since a plain header does not have one.
+	bh.AuxPow = nil
+	pr, ok := r.(*payloadReader)
+	if ok && pr.auxPow && bh.Version&AuxPowVersionBit != 0 {
+		bh.AuxPow = &AuxPow{}
+		err := readAuxPow(r, pver, bh.AuxPow)
+		if err != nil {
+			return err
+		}
+	}
 
 	count, err := readVarInt(r, pver)
 	if err != nil {
@@ -104,15 +265,52 @@ func readBlockHeader(r io.Reader, pver uint32, bh *BlockHeader) error {
 	return nil
 }
 
+// blockHeaderSerializeSize returns the number of bytes it would take to write
+// the block header with writeBlockHeader.
+func blockHeaderSerializeSize(pver uint32, bh *BlockHeader) int {
+	n := blockHashLen + varIntSerializeSize(bh.TxnCount)
+	if bh.AuxPow != nil {
+		n += auxPowSerializeSize(pver, bh.AuxPow)
+	}
+	return n
+}
+
+// validateBlockHeader returns an error attributed to the function f when the
+// block header would be rejected by writeBlockHeader.
+func validateBlockHeader(f string, bh *BlockHeader) error {
+	if bh.AuxPow == nil {
+		return nil
+	}
+	if bh.Version&AuxPowVersionBit == 0 {
+		str := fmt.Sprintf("block header with auxpow does not have the "+
+			"auxpow version bit set [version %#x]", bh.Version)
+		return messageError(f, str)
+	}
+	return validateAuxPow(f, bh.AuxPow)
+}
+
 // writeBlockHeader writes a bitcoin block header to w.
 func writeBlockHeader(w io.Writer, pver uint32, bh *BlockHeader) error {
-	sec := uint32(bh.Timestamp.Unix())
-	err := writeElements(w, bh.Version, &bh.PrevBlock, &bh.MerkleRoot,
-		sec, bh.Bits, bh.Nonce)
+	err := writeBlockHeaderFields(w, bh)
 	if err != nil {
 		return err
 	}
 
+	if bh.AuxPow != nil {
+		// The version bit is what tells the remote peer to expect the
+		// auxiliary proof-of-work, so it must be set.
+		if bh.Version&AuxPowVersionBit == 0 {
+			str := fmt.Sprintf("block header with auxpow does not "+
+				"have the auxpow version bit set [version %#x]",
+				bh.Version)
+			return messageError("writeBlockHeader", str)
+		}
+		err = writeAuxPow(w, pver, bh.AuxPow)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = writeVarInt(w, pver, bh.TxnCount)
 	if err != nil {
 		return err
@@ -45,6 +45,158 @@ func TestBlockHeader(t *testing.T) {
 	}
 }
 
+// TestBlockHeaderEqual tests comparing block headers with Equal.
+func TestBlockHeaderEqual(t *testing.T) {
+	base := blockOne.Header
+
+	sameTime := base
+	sameTime.Timestamp = base.Timestamp.UTC()
+	changedNonce := base
+	changedNonce.Nonce++
+	changedCount := base
+	changedCount.TxnCount++
+	changedTime := base
+	changedTime.Timestamp = base.Timestamp.Add(time.Second)
+	withAuxPow := base
+	withAuxPow.AuxPow = &btcwire.AuxPow{CoinbaseTx: *multiTx}
+	sameAuxPow := base
+	sameAuxPow.AuxPow = &btcwire.AuxPow{CoinbaseTx: *multiTx.Copy()}
+	changedAuxPow := base
+	changedAuxPow.AuxPow = &btcwire.AuxPow{CoinbaseTx: *multiTx,
+		CoinbaseIndex: 1}
+
+	tests := []struct {
+		a, b *btcwire.BlockHeader // Headers to compare
+		want bool                 // Expected result
+	}{
+		{&base, &base, true},
+		{&base, &sameTime, true},
+		{&withAuxPow, &sameAuxPow, true},
+		{nil, nil, true},
+		{&base, nil, false},
+		{&base, &changedNonce, false},
+		{&base, &changedCount, false},
+		{&base, &changedTime, false},
+		{&base, &withAuxPow, false},
+		{&withAuxPow, &changedAuxPow, false},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		if got := test.a.Equal(test.b); got != test.want {
+			t.Errorf("Equal #%d got: %v want: %v", i, got, test.want)
+		}
+		if got := test.b.Equal(test.a); got != test.want {
+			t.Errorf("Equal #%d (reversed) got: %v want: %v", i, got,
+				test.want)
+		}
+	}
+}
+
+// TestBlockHeaderValidate ensures block headers which break the rules that do
+// not depend on the rest of the chain are rejected.
+func TestBlockHeaderValidate(t *testing.T) {
+	refTime := blockOne.Header.Timestamp
+
+	tests := []struct {
+		name    string
+		modify  func(h *btcwire.BlockHeader) // Change to block one
+		refTime time.Time                    // Reference time
+		valid   bool                         // Whether it is valid
+	}{
+		{"block one", func(h *btcwire.BlockHeader) {}, refTime, true},
+		{"two hours ahead", func(h *btcwire.BlockHeader) {
+			h.Timestamp = refTime.Add(time.Hour * 2)
+		}, refTime, true},
+		{"too far ahead", func(h *btcwire.BlockHeader) {
+			h.Timestamp = refTime.Add(time.Hour*2 + time.Second)
+		}, refTime, false},
+		{"far in the past", func(h *btcwire.BlockHeader) {
+			h.Timestamp = time.Unix(0, 0)
+		}, refTime, true},
+		{"clock time", func(h *btcwire.BlockHeader) {
+			h.Timestamp = refTime.Add(time.Hour * 3)
+		}, time.Time{}, true},
+		{"regression test limit", func(h *btcwire.BlockHeader) {
+			h.Bits = 0x207fffff
+		}, refTime, true},
+		{"largest target", func(h *btcwire.BlockHeader) {
+			h.Bits = 0x2100ffff
+		}, refTime, true},
+		{"target too large", func(h *btcwire.BlockHeader) {
+			h.Bits = 0x21010000
+		}, refTime, false},
+		{"zero target", func(h *btcwire.BlockHeader) {
+			h.Bits = 0x01003456
+		}, refTime, false},
+		{"negative target", func(h *btcwire.BlockHeader) {
+			h.Bits = 0x1d80ffff
+		}, refTime, false},
+		{"version 4", func(h *btcwire.BlockHeader) {
+			h.Version = 4
+		}, refTime, true},
+		{"version 0", func(h *btcwire.BlockHeader) {
+			h.Version = 0
+		}, refTime, false},
+		{"version 5", func(h *btcwire.BlockHeader) {
+			h.Version = 5
+		}, refTime, false},
+		{"version bits", func(h *btcwire.BlockHeader) {
+			h.Version = 0x20000002
+		}, refTime, true},
+		{"version bits with bit 8", func(h *btcwire.BlockHeader) {
+			h.Version = 0x20000100
+		}, refTime, true},
+		{"version bits 8 to 23", func(h *btcwire.BlockHeader) {
+			h.Version = 0x20ffff00
+		}, refTime, true},
+		{"version bits 1 and 8", func(h *btcwire.BlockHeader) {
+			h.Version = 0x20000102
+		}, refTime, true},
+		{"bad top bits", func(h *btcwire.BlockHeader) {
+			h.Version = 0x40000002
+		}, refTime, false},
+		{"merged mined", func(h *btcwire.BlockHeader) {
+			h.Version = 0x00620104
+		}, refTime, true},
+		{"merged mined version 0", func(h *btcwire.BlockHeader) {
+			h.Version = 0x00620100
+		}, refTime, false},
+		{"auxpow", func(h *btcwire.BlockHeader) {
+			h.Version = 0x00010102
+			h.AuxPow = &btcwire.AuxPow{CoinbaseTx: *multiTx}
+		}, refTime, true},
+		{"auxpow without bit", func(h *btcwire.BlockHeader) {
+			h.AuxPow = &btcwire.AuxPow{CoinbaseTx: *multiTx}
+		}, refTime, false},
+	}
+
+	// Use a clock an hour after block one for a zero reference time, so a
+	// timestamp three hours after block one is within two hours of it.
+	prev := btcwire.SetClock(btcwire.ClockFunc(func() time.Time {
+		return refTime.Add(time.Hour)
+	}))
+	defer btcwire.SetClock(prev)
+
+	t.Logf("Running %d tests", len(tests))
+	for _, test := range tests {
+		h := blockOne.Header
+		test.modify(&h)
+		err := h.Validate(test.refTime)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: got error %v, want valid %v", test.name,
+				err, test.valid)
+			continue
+		}
+		if err != nil {
+			if _, ok := err.(*btcwire.MessageError); !ok {
+				t.Errorf("%s: wrong error type %T", test.name,
+					err)
+			}
+		}
+	}
+}
+
 // TestBlockHeaderWire tests the BlockHeader wire encode and decode for various
 // protocol versions.
 func TestBlockHeaderWire(t *testing.T) {
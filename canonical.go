@@ -0,0 +1,104 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"bytes"
+	"io"
+)
+
+// CanonicalResult describes whether the payload of a message is in the
+// canonical form produced by this package.  See CheckCanonical.
+type CanonicalResult struct {
+	// Msg is the message decoded from the payload.
+	Msg Message
+
+	// Canonical is whether the payload is exactly the canonical encoding
+	// of the message.
+	Canonical bool
+
+	// Encoded is the canonical encoding of the message.
+	Encoded []byte
+
+	// TrailingBytes is the number of bytes following the encoded message
+	// in the payload which were not consumed when decoding it.
+	TrailingBytes int
+
+	// Offset is the offset within the payload of the first byte which
+	// differs from the canonical encoding, or -1 when the payload is
+	// canonical.
+	Offset int
+
+	// Field is the name, as used by Dissect, of the field of the payload
+	// which contains the byte at Offset, such as
+	// "txin[0].signature_script_len" for a non-minimal variable length
+	// integer or "trailing" for trailing bytes.  It is empty when the
+	// payload is canonical or the field could not be determined.
+	Field string
+}
+
+// CheckCanonical decodes the payload of a message with the provided command
+// for the provided protocol version and bitcoin network, re-encodes it, and
+// reports whether the payload is in canonical form.  Payloads which decode
+// successfully may still be non-canonical, such as when they contain
+// variable length integers which are not minimally encoded or bytes after the
+// message, which allows mempool policy to reject them and forensic tools to
+// identify peers which send them.
+//
+// A DecodeError is returned when the payload can't be decoded at all, and a
+// MessageError when the command is not recognized.
+func CheckCanonical(command string, payload []byte, pver uint32,
+	btcnet BitcoinNet) (*CanonicalResult, error) {
+
+	msg, err := NewMessageByCommand(command)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bytes.NewReader(payload)
+	pr := &payloadReader{Reader: br, auxPow: IsAuxPowNet(btcnet)}
+	err = msg.BtcDecode(pr, pver)
+	if err != nil {
+		offset := len(payload) - br.Len()
+		truncated := err == io.EOF || err == io.ErrUnexpectedEOF
+		return nil, &DecodeError{
+			Command: command,
+			Offset:  offset,
+			Field:   fieldAt(command, payload, offset, truncated),
+			Err:     err,
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(payload))
+	if err := msg.BtcEncode(&buf, pver); err != nil {
+		return nil, err
+	}
+	encoded := buf.Bytes()
+
+	result := &CanonicalResult{
+		Msg:           msg,
+		Canonical:     bytes.Equal(payload, encoded),
+		Encoded:       encoded,
+		TrailingBytes: br.Len(),
+		Offset:        -1,
+	}
+	if result.Canonical {
+		return result, nil
+	}
+
+	// Locate the first byte which differs from the canonical encoding.
+	offset := 0
+	for offset < len(payload) && offset < len(encoded) &&
+		payload[offset] == encoded[offset] {
+
+		offset++
+	}
+	result.Offset = offset
+	if offset < len(payload) {
+		result.Field = fieldAt(command, payload, offset+1, false)
+	}
+	return result, nil
+}
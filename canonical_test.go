@@ -0,0 +1,94 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"testing"
+)
+
+// TestCheckCanonical ensures non-canonical payloads are detected and the first
+// non-canonical byte is located.
+func TestCheckCanonical(t *testing.T) {
+	// nonMinimal is multiTxEncoded with the length of the signature script
+	// encoded as a 3 byte variable length integer.
+	nonMinimal := append([]byte{}, multiTxEncoded[:41]...)
+	nonMinimal = append(nonMinimal, 0xfd, 0x07, 0x00)
+	nonMinimal = append(nonMinimal, multiTxEncoded[42:]...)
+
+	trailing := append(append([]byte{}, multiTxEncoded...), 0x00, 0x00)
+
+	pver := btcwire.ProtocolVersion
+	tests := []struct {
+		command   string // Command of the message
+		payload   []byte // Payload to check
+		canonical bool   // Expected canonical flag
+		trailing  int    // Expected number of trailing bytes
+		offset    int    // Expected offset of the first difference
+		field     string // Expected field of the first difference
+	}{
+		{btcwire.CmdTx, multiTxEncoded, true, 0, -1, ""},
+		{btcwire.CmdPing, []byte{1, 2, 3, 4, 5, 6, 7, 8}, true, 0, -1, ""},
+		{btcwire.CmdVerAck, nil, true, 0, -1, ""},
+		{btcwire.CmdTx, nonMinimal, false, 0, 41,
+			"txin[0].signature_script_len"},
+		{btcwire.CmdTx, trailing, false, 2, len(multiTxEncoded),
+			"trailing"},
+		{btcwire.CmdVerAck, []byte{0x00}, false, 1, 0, "trailing"},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		result, err := btcwire.CheckCanonical(test.command, test.payload,
+			pver, btcwire.MainNet)
+		if err != nil {
+			t.Errorf("CheckCanonical #%d error %v", i, err)
+			continue
+		}
+		if result.Canonical != test.canonical {
+			t.Errorf("CheckCanonical #%d: wrong canonical flag - "+
+				"got %v, want %v", i, result.Canonical,
+				test.canonical)
+		}
+		if result.TrailingBytes != test.trailing {
+			t.Errorf("CheckCanonical #%d: wrong trailing bytes - "+
+				"got %d, want %d", i, result.TrailingBytes,
+				test.trailing)
+		}
+		if result.Offset != test.offset {
+			t.Errorf("CheckCanonical #%d: wrong offset - got %d, "+
+				"want %d", i, result.Offset, test.offset)
+		}
+		if result.Field != test.field {
+			t.Errorf("CheckCanonical #%d: wrong field - got %q, "+
+				"want %q", i, result.Field, test.field)
+		}
+		if test.command == btcwire.CmdTx &&
+			!bytes.Equal(result.Encoded, multiTxEncoded) {
+
+			t.Errorf("CheckCanonical #%d: wrong encoding\n got: %x "+
+				"want: %x", i, result.Encoded, multiTxEncoded)
+		}
+	}
+}
+
+// TestCheckCanonicalErrors ensures payloads which can't be decoded and unknown
+// commands are rejected with the expected error types.
+func TestCheckCanonicalErrors(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	_, err := btcwire.CheckCanonical(btcwire.CmdTx, multiTxEncoded[:20],
+		pver, btcwire.MainNet)
+	if _, ok := err.(*btcwire.DecodeError); !ok {
+		t.Errorf("CheckCanonical: wrong error - got %T (%v), want "+
+			"*DecodeError", err, err)
+	}
+
+	_, err = btcwire.CheckCanonical("bogus", nil, pver, btcwire.MainNet)
+	if _, ok := err.(*btcwire.MessageError); !ok {
+		t.Errorf("CheckCanonical: wrong error - got %T (%v), want "+
+			"*MessageError", err, err)
+	}
+}
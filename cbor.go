@@ -0,0 +1,212 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// These constants define the CBOR major types used by MarshalCBOR.
+const (
+	cborUnsigned = 0
+	cborNegative = 1
+	cborBytes    = 2
+	cborText     = 3
+	cborArray    = 4
+	cborMap      = 5
+	cborTag      = 6
+	cborSimple   = 7
+)
+
+// These constants define the CBOR simple values and tags used by MarshalCBOR.
+const (
+	cborFalse     = 20
+	cborTrue      = 21
+	cborNull      = 22
+	cborEpochTime = 1
+)
+
+// writeCBORHead writes the head of a CBOR data item with the provided major
+// type and argument to buf using the shortest possible encoding.
+func writeCBORHead(buf *bytes.Buffer, major byte, arg uint64) {
+	major <<= 5
+	switch {
+	case arg < 24:
+		buf.WriteByte(major | byte(arg))
+	case arg <= 0xff:
+		buf.WriteByte(major | 24)
+		buf.WriteByte(byte(arg))
+	case arg <= 0xffff:
+		buf.WriteByte(major | 25)
+		var b [2]byte
+		bigEndian.PutUint16(b[:], uint16(arg))
+		buf.Write(b[:])
+	case arg <= 0xffffffff:
+		buf.WriteByte(major | 26)
+		var b [4]byte
+		bigEndian.PutUint32(b[:], uint32(arg))
+		buf.Write(b[:])
+	default:
+		buf.WriteByte(major | 27)
+		var b [8]byte
+		bigEndian.PutUint64(b[:], arg)
+		buf.Write(b[:])
+	}
+}
+
+// writeCBORInt writes a signed integer to buf.
+func writeCBORInt(buf *bytes.Buffer, n int64) {
+	if n < 0 {
+		writeCBORHead(buf, cborNegative, uint64(-1-n))
+		return
+	}
+	writeCBORHead(buf, cborUnsigned, uint64(n))
+}
+
+// writeCBORBytes writes a byte string to buf.
+func writeCBORBytes(buf *bytes.Buffer, b []byte) {
+	writeCBORHead(buf, cborBytes, uint64(len(b)))
+	buf.Write(b)
+}
+
+// writeCBORText writes a text string to buf.
+func writeCBORText(buf *bytes.Buffer, s string) {
+	writeCBORHead(buf, cborText, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+// writeCBOR writes the CBOR representation of v to buf.  See MarshalCBOR for
+// the representation of each type.
+func writeCBOR(buf *bytes.Buffer, v reflect.Value) error {
+	t := v.Type()
+	switch t {
+	case timeType:
+		tm := v.Interface().(time.Time)
+		if tm.IsZero() {
+			writeCBORHead(buf, cborSimple, cborNull)
+			return nil
+		}
+		writeCBORHead(buf, cborTag, cborEpochTime)
+		writeCBORInt(buf, tm.Unix())
+		return nil
+
+	case ipType:
+		ip := v.Interface().(net.IP)
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		writeCBORBytes(buf, ip)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			writeCBORHead(buf, cborSimple, cborNull)
+			return nil
+		}
+		return writeCBOR(buf, v.Elem())
+
+	case reflect.Struct:
+		// Encode every exported field as a map entry keyed by its name
+		// and sort the entries by their encoded keys as required for
+		// deterministic encoding.
+		type entry struct {
+			key   []byte
+			value reflect.Value
+		}
+		entries := make([]entry, 0, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).PkgPath != "" {
+				continue
+			}
+			var key bytes.Buffer
+			writeCBORText(&key, t.Field(i).Name)
+			entries = append(entries, entry{key.Bytes(), v.Field(i)})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return bytes.Compare(entries[i].key, entries[j].key) < 0
+		})
+
+		writeCBORHead(buf, cborMap, uint64(len(entries)))
+		for _, e := range entries {
+			buf.Write(e.key)
+			if err := writeCBOR(buf, e.value); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			writeCBORBytes(buf, b)
+			return nil
+		}
+		writeCBORHead(buf, cborArray, uint64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			if err := writeCBOR(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.String:
+		writeCBORText(buf, v.String())
+		return nil
+
+	case reflect.Bool:
+		if v.Bool() {
+			writeCBORHead(buf, cborSimple, cborTrue)
+		} else {
+			writeCBORHead(buf, cborSimple, cborFalse)
+		}
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		writeCBORInt(buf, v.Int())
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		writeCBORHead(buf, cborUnsigned, v.Uint())
+		return nil
+	}
+
+	str := fmt.Sprintf("values of type %s are not supported", t)
+	return messageError("MarshalCBOR", str)
+}
+
+// MarshalCBOR returns the CBOR (RFC 8949) representation of the provided
+// message for embedding decoded messages in telemetry and log pipelines which
+// already use CBOR.  It is not the wire encoding and there is no way to decode
+// it back into a message.
+//
+// The message is represented by a map with a "command" entry holding its
+// command and a "payload" entry holding a map of its exported fields keyed by
+// their names.  Nested structs are represented the same way, lists are arrays,
+// hashes, scripts, and other byte sequences are byte strings, and IP addresses
+// are 4 or 16 byte strings, or empty when unset.  Timestamps are tagged epoch
+// times in seconds, or null when unset, and nil pointers are null.  Map entries
+// are sorted as described by the deterministic encoding requirements of the
+// RFC, so the same message always produces the same bytes.
+func MarshalCBOR(msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	writeCBORHead(&buf, cborMap, 2)
+	writeCBORText(&buf, "command")
+	writeCBORText(&buf, msg.Command())
+	writeCBORText(&buf, "payload")
+	if err := writeCBOR(&buf, reflect.ValueOf(msg)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestMarshalCBOR ensures messages are marshalled to the expected CBOR.
+func TestMarshalCBOR(t *testing.T) {
+	// head returns the start of the CBOR representation of a message with
+	// the provided command up to its payload.
+	head := func(command string) []byte {
+		b := []byte{0xa2, 0x67}
+		b = append(b, "command"...)
+		b = append(b, 0x60+byte(len(command)))
+		b = append(b, command...)
+		b = append(b, 0x67)
+		return append(b, "payload"...)
+	}
+
+	addr := btcwire.NewMsgAddr()
+	addr.AddAddress(&btcwire.NetAddress{
+		Timestamp: time.Unix(0x495fab29, 0),
+		Services:  btcwire.SFNodeNetwork,
+		IP:        net.ParseIP("127.0.0.1"),
+		Port:      8333,
+	})
+	addr.AddAddress(&btcwire.NetAddress{})
+
+	tests := []struct {
+		msg btcwire.Message // Message to marshal
+		buf []byte          // Expected CBOR
+	}{
+		// {"command": "verack", "payload": {}}
+		{
+			btcwire.NewMsgVerAck(),
+			append(head("verack"), 0xa0),
+		},
+
+		// {"command": "ping", "payload": {"Nonce": 1000}}
+		{
+			btcwire.NewMsgPing(1000),
+			append(head("ping"),
+				0xa1, 0x65, 'N', 'o', 'n', 'c', 'e', 0x19, 0x03, 0xe8,
+			),
+		},
+
+		// {"command": "addr", "payload": {"AddrList": [
+		//     {"IP": h'7f000001', "Port": 8333, "Services": 1,
+		//      "Timestamp": 1(1231006505)},
+		//     {"IP": h'', "Port": 0, "Services": 0, "Timestamp": null}]}}
+		{
+			addr,
+			append(head("addr"),
+				0xa1, 0x68, 'A', 'd', 'd', 'r', 'L', 'i', 's', 't',
+				0x82,
+				0xa4,
+				0x62, 'I', 'P', 0x44, 0x7f, 0x00, 0x00, 0x01,
+				0x64, 'P', 'o', 'r', 't', 0x19, 0x20, 0x8d,
+				0x68, 'S', 'e', 'r', 'v', 'i', 'c', 'e', 's', 0x01,
+				0x69, 'T', 'i', 'm', 'e', 's', 't', 'a', 'm', 'p',
+				0xc1, 0x1a, 0x49, 0x5f, 0xab, 0x29,
+				0xa4,
+				0x62, 'I', 'P', 0x40,
+				0x64, 'P', 'o', 'r', 't', 0x00,
+				0x68, 'S', 'e', 'r', 'v', 'i', 'c', 'e', 's', 0x00,
+				0x69, 'T', 'i', 'm', 'e', 's', 't', 'a', 'm', 'p',
+				0xf6,
+			),
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		b, err := btcwire.MarshalCBOR(test.msg)
+		if err != nil {
+			t.Errorf("MarshalCBOR #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(b, test.buf) {
+			t.Errorf("MarshalCBOR #%d\n got: %x want: %x", i, b,
+				test.buf)
+		}
+	}
+}
+
+// TestMarshalCBORMessages ensures every message type can be marshalled and
+// always produces the same bytes.
+func TestMarshalCBORMessages(t *testing.T) {
+	tests := snapshotMessages()
+	t.Logf("Running %d tests", len(tests))
+	for i, msg := range tests {
+		b, err := btcwire.MarshalCBOR(msg)
+		if err != nil {
+			t.Errorf("MarshalCBOR #%d (%s) error %v", i, msg.Command(),
+				err)
+			continue
+		}
+		again, err := btcwire.MarshalCBOR(msg)
+		if err != nil || !bytes.Equal(b, again) {
+			t.Errorf("MarshalCBOR #%d (%s) not deterministic", i,
+				msg.Command())
+		}
+	}
+}
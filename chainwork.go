@@ -0,0 +1,47 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"math/big"
+)
+
+// oneLsh256 is 1 shifted left 256 bits, which is one more than the largest
+// hash.
+var oneLsh256 = new(big.Int).Lsh(big.NewInt(1), 256)
+
+// CalcWork returns the work represented by the target in the provided compact
+// bits, which is the expected number of hashes needed to find a block with the
+// target, 2^256 / (target+1).  Bits which do not decode to a positive target
+// represent no work.  This is the same calculation the reference
+// implementation uses to choose the chain with the most work.
+func CalcWork(bits uint32) *big.Int {
+	target := CompactToBig(bits)
+	if target.Sign() <= 0 {
+		return big.NewInt(0)
+	}
+
+	// (1 << 256) / (target + 1)
+	denominator := target.Add(target, big.NewInt(1))
+	return denominator.Div(oneLsh256, denominator)
+}
+
+// Work returns the work represented by the target in the Bits field of the
+// block header.  See CalcWork for details.
+func (h *BlockHeader) Work() *big.Int {
+	return CalcWork(h.Bits)
+}
+
+// ChainWork returns the total work of the provided block headers, such as the
+// headers of a headers message (MsgHeaders), which is the amount the headers
+// add to the work of the chain they extend.  It does not check that the
+// headers link together or satisfy their targets.
+func ChainWork(headers []*BlockHeader) *big.Int {
+	total := new(big.Int)
+	for _, h := range headers {
+		total.Add(total, h.Work())
+	}
+	return total
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"github.com/conformal/btcwire"
+	"math/big"
+	"testing"
+)
+
+// TestCalcWork ensures the work of compact targets is calculated as the
+// expected number of hashes needed to satisfy them.
+func TestCalcWork(t *testing.T) {
+	tests := []struct {
+		bits uint32 // Compact target
+		want string // Expected work in hex
+	}{
+		// The work of the proof-of-work limit of the main network,
+		// which is the work of the genesis block.
+		{0x1d00ffff, "100010001"},
+
+		// The work of the proof-of-work limit of the regression test
+		// network.
+		{0x207fffff, "2"},
+
+		// A target of 1 needs half of all hashes to be below it.
+		{0x01010000, "8000000000000000000000000000000000000000000000000000000000000000"},
+
+		// A target with a difficulty of 16307.42 on the main network.
+		{0x1b0404cb, "3fb3ab764c00"},
+
+		// Targets which are not positive represent no work.
+		{0, "0"},
+		{0x1d80ffff, "0"},
+		{0x01003456, "0"},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		want, ok := new(big.Int).SetString(test.want, 16)
+		if !ok {
+			t.Errorf("SetString #%d: invalid number %q", i, test.want)
+			continue
+		}
+		if got := btcwire.CalcWork(test.bits); got.Cmp(want) != 0 {
+			t.Errorf("CalcWork #%d (%#08x) got: %x want: %x", i,
+				test.bits, got, want)
+		}
+		h := btcwire.BlockHeader{Bits: test.bits}
+		if got := h.Work(); got.Cmp(want) != 0 {
+			t.Errorf("Work #%d (%#08x) got: %x want: %x", i,
+				test.bits, got, want)
+		}
+	}
+}
+
+// TestChainWork ensures the work of a sequence of headers is accumulated.
+func TestChainWork(t *testing.T) {
+	genesis := &btcwire.GenesisBlock.Header
+	one := &blockOne.Header
+	hard := &btcwire.BlockHeader{Bits: 0x1b0404cb}
+	invalid := &btcwire.BlockHeader{Bits: 0x1d80ffff}
+
+	tests := []struct {
+		headers []*btcwire.BlockHeader // Headers to accumulate
+		want    int64                  // Expected total work
+	}{
+		{nil, 0},
+		{[]*btcwire.BlockHeader{genesis}, 0x100010001},
+		{[]*btcwire.BlockHeader{genesis, one}, 0x200020002},
+		{[]*btcwire.BlockHeader{genesis, one, hard},
+			0x200020002 + 0x3fb3ab764c00},
+		{[]*btcwire.BlockHeader{genesis, invalid, one}, 0x200020002},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		got := btcwire.ChainWork(test.headers)
+		if got.Cmp(big.NewInt(test.want)) != 0 {
+			t.Errorf("ChainWork #%d got: %x want: %x", i, got,
+				test.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time to the constructors which populate
+// timestamps, such as NewMsgVersion and NewNetAddress.  Replacing it via
+// SetClock allows tests to produce deterministic serializations and replay
+// tools to reconstruct historical messages exactly.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc is an adapter which allows an ordinary function to be used as a
+// Clock.
+type ClockFunc func() time.Time
+
+// Now returns the result of calling f.  This is part of the Clock interface
+// implementation.
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+var (
+	// clock is the Clock used by the constructors.  It defaults to the
+	// system clock.
+	clock    Clock = ClockFunc(time.Now)
+	clockMtx sync.RWMutex
+)
+
+// SetClock replaces the Clock used by the constructors in this package which
+// populate timestamps and returns the previous one so it may be restored.  A
+// nil clock restores the system clock.  It is safe for concurrent use,
+// although the clock applies to the whole package, so tests which replace it
+// should not run in parallel with ones which depend on the current time.
+func SetClock(c Clock) Clock {
+	if c == nil {
+		c = ClockFunc(time.Now)
+	}
+
+	clockMtx.Lock()
+	prev := clock
+	clock = c
+	clockMtx.Unlock()
+	return prev
+}
+
+// now returns the current time according to the Clock set via SetClock.
+func now() time.Time {
+	clockMtx.RLock()
+	c := clock
+	clockMtx.RUnlock()
+	return c.Now()
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestSetClock ensures the constructors which populate timestamps use the
+// clock set via SetClock and that the system clock can be restored.
+func TestSetClock(t *testing.T) {
+	fixed := time.Unix(0x495fab29, 0)
+	prev := btcwire.SetClock(btcwire.ClockFunc(func() time.Time {
+		return fixed
+	}))
+	defer btcwire.SetClock(prev)
+
+	na := btcwire.NewNetAddressIPPort(net.ParseIP("127.0.0.1"), 8333,
+		btcwire.SFNodeNetwork)
+	msg, err := btcwire.NewMsgVersion(na, na, 123123, "/btcwiretest:0.0.1/",
+		234234)
+	if err != nil {
+		t.Fatalf("NewMsgVersion: unexpected error %v", err)
+	}
+	bh := btcwire.NewBlockHeader(&btcwire.GenesisHash, &btcwire.GenesisHash,
+		0x1d00ffff, 0)
+	hw := btcwire.NewHeaderWork(&blockOne.Header)
+	hw.UpdateTimestamp()
+
+	tests := []struct {
+		name string
+		got  time.Time
+	}{
+		{"NewNetAddressIPPort", na.Timestamp},
+		{"NewMsgVersion", msg.Timestamp},
+		{"NewBlockHeader", bh.Timestamp},
+		{"UpdateTimestamp", hw.Header().Timestamp},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for _, test := range tests {
+		if !test.got.Equal(fixed) {
+			t.Errorf("%s: wrong timestamp - got %v, want %v",
+				test.name, test.got, fixed)
+		}
+	}
+
+	// Ensure messages created with the same clock serialize identically.
+	again, err := btcwire.NewMsgVersion(na, na, 123123,
+		"/btcwiretest:0.0.1/", 234234)
+	if err != nil {
+		t.Fatalf("NewMsgVersion: unexpected error %v", err)
+	}
+	var buf1, buf2 bytes.Buffer
+	pver := btcwire.ProtocolVersion
+	if err := msg.BtcEncode(&buf1, pver); err != nil {
+		t.Fatalf("BtcEncode: unexpected error %v", err)
+	}
+	if err := again.BtcEncode(&buf2, pver); err != nil {
+		t.Fatalf("BtcEncode: unexpected error %v", err)
+	}
+	if !bytes.Equal(buf1.Bytes(), buf2.Bytes()) {
+		t.Errorf("BtcEncode: serializations differ\n got: %x want: %x",
+			buf2.Bytes(), buf1.Bytes())
+	}
+
+	// Ensure a nil clock restores the system clock.
+	btcwire.SetClock(nil)
+	before := time.Now().Add(-time.Second)
+	na = btcwire.NewNetAddressIPPort(net.ParseIP("127.0.0.1"), 8333, 0)
+	if na.Timestamp.Before(before) {
+		t.Errorf("SetClock(nil): timestamp %v not from the system clock",
+			na.Timestamp)
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+btcwiredump decodes bitcoin wire protocol messages and prints them, which is
+useful for quickly triaging interoperability problems from a packet capture or
+log without writing a throwaway program.
+
+The input is read from the files named on the command line, or from standard
+input when there are none, and may be either raw bytes or hex.  Hex input may
+contain whitespace, so the output of most hex dump tools can be pasted
+directly.  The input is decoded as a sequence of complete messages, including
+their headers, unless -payload is given, in which case it is decoded as the
+bare payload of a single message with the provided command.
+
+Usage:
+
+	btcwiredump [flags] [file...]
+
+The flags are:
+
+	-net name
+		The network the messages belong to, which is one of mainnet,
+		testnet, testnet3, or a number such as 0xd9b4bef9.  By default
+		the network is taken from the start of the first message.
+	-pver version
+		The protocol version used to decode the messages.
+	-format auto|hex|raw
+		The format of the input.  By default input consisting only of
+		hex digits and whitespace is treated as hex.
+	-payload command
+		Decode the input as the payload of a single message with the
+		provided command.
+	-dissect
+		Also print a field-by-field breakdown of each payload.
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/conformal/btcwire"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// networks maps the names accepted by the -net flag to their networks.
+var networks = map[string]btcwire.BitcoinNet{
+	"mainnet":  btcwire.MainNet,
+	"testnet":  btcwire.TestNet,
+	"testnet3": btcwire.TestNet3,
+}
+
+// config houses the options which control how the input is decoded and
+// printed.
+type config struct {
+	net     string
+	pver    uint
+	format  string
+	payload string
+	dissect bool
+}
+
+// parseNet returns the network described by name, which is either one of the
+// names in networks or a number.
+func parseNet(name string) (btcwire.BitcoinNet, error) {
+	if btcnet, ok := networks[strings.ToLower(name)]; ok {
+		return btcnet, nil
+	}
+	n, err := strconv.ParseUint(name, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("unknown network %q", name)
+	}
+	return btcwire.BitcoinNet(n), nil
+}
+
+// isHex returns whether data consists solely of an even number of hex digits
+// along with any amount of whitespace.
+func isHex(data []byte) bool {
+	digits := 0
+	for _, c := range string(data) {
+		switch {
+		case unicode.IsSpace(c):
+		case strings.ContainsRune("0123456789abcdefABCDEF", c):
+			digits++
+		default:
+			return false
+		}
+	}
+	return digits > 0 && digits%2 == 0
+}
+
+// decodeInput returns the bytes represented by the input data in the provided
+// format.
+func decodeInput(data []byte, format string) ([]byte, error) {
+	switch format {
+	case "raw":
+		return data, nil
+	case "auto":
+		if !isHex(data) {
+			return data, nil
+		}
+	case "hex":
+	default:
+		return nil, fmt.Errorf("unknown input format %q", format)
+	}
+
+	stripped := strings.Map(func(c rune) rune {
+		if unicode.IsSpace(c) {
+			return -1
+		}
+		return c
+	}, string(data))
+	return hex.DecodeString(stripped)
+}
+
+// printMessage writes the decoded message, along with the dissection of its
+// payload when requested, to w.
+func printMessage(w io.Writer, cfg *config, msg btcwire.Message, payload []byte) {
+	fmt.Fprintf(w, "%#v\n", msg)
+	if !cfg.dissect {
+		return
+	}
+	d, err := btcwire.Dissect(msg.Command(), payload)
+	fmt.Fprint(w, d)
+	if err != nil {
+		fmt.Fprintf(w, "dissect: %v\n", err)
+	}
+}
+
+// dump decodes the messages in data according to cfg and writes them to w.
+// Decoding stops at the first message which fails to decode.
+func dump(w io.Writer, data []byte, cfg *config) error {
+	pver := uint32(cfg.pver)
+
+	if cfg.payload != "" {
+		msg, err := btcwire.NewMessageByCommand(cfg.payload)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "payload: %s (%d bytes)\n", cfg.payload,
+			len(data))
+		r := bytes.NewReader(data)
+		if err := msg.BtcDecode(r, pver); err != nil {
+			return err
+		}
+		printMessage(w, cfg, msg, data)
+		if r.Len() > 0 {
+			fmt.Fprintf(w, "%d trailing bytes\n", r.Len())
+		}
+		return nil
+	}
+
+	var btcnet btcwire.BitcoinNet
+	if cfg.net != "" {
+		var err error
+		btcnet, err = parseNet(cfg.net)
+		if err != nil {
+			return err
+		}
+	} else if len(data) >= 4 {
+		btcnet = btcwire.BitcoinNet(binary.LittleEndian.Uint32(data))
+	}
+
+	opts := &btcwire.ReadOptions{
+		AllowUnknown:         true,
+		AnnotateDecodeErrors: true,
+	}
+	r := bytes.NewReader(data)
+	for i := 0; r.Len() > 0; i++ {
+		offset := len(data) - r.Len()
+		msg, payload, err := btcwire.ReadMessageWithOptions(r, pver,
+			btcnet, opts)
+		if err != nil {
+			return fmt.Errorf("message %d at offset %d: %v", i, offset,
+				err)
+		}
+		fmt.Fprintf(w, "message %d: %s (%d byte payload) at offset %d\n",
+			i, msg.Command(), len(payload), offset)
+		printMessage(w, cfg, msg, payload)
+	}
+	return nil
+}
+
+// readInput returns the concatenated contents of the named files, or of
+// standard input when there are none.
+func readInput(files []string) ([]byte, error) {
+	if len(files) == 0 {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	var buf bytes.Buffer
+	for _, name := range files {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.net, "net", "", "network of the messages "+
+		"(mainnet, testnet, testnet3, or a number)")
+	flag.UintVar(&cfg.pver, "pver", uint(btcwire.ProtocolVersion),
+		"protocol version used to decode the messages")
+	flag.StringVar(&cfg.format, "format", "auto", "input format (auto, "+
+		"hex, or raw)")
+	flag.StringVar(&cfg.payload, "payload", "", "decode the input as the "+
+		"payload of a message with this command")
+	flag.BoolVar(&cfg.dissect, "dissect", false, "print a field-by-field "+
+		"breakdown of each payload")
+	flag.Parse()
+
+	err := run(os.Stdout, flag.Args(), &cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "btcwiredump: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run reads the input from the named files, or standard input, and dumps the
+// messages it contains to w.
+func run(w io.Writer, files []string, cfg *config) error {
+	data, err := readInput(files)
+	if err != nil {
+		return err
+	}
+	data, err = decodeInput(data, cfg.format)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return errors.New("no input")
+	}
+	return dump(w, data, cfg)
+}
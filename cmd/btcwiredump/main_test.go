@@ -0,0 +1,125 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/conformal/btcwire"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDump tests decoding and printing messages in the supported input
+// formats.
+func TestDump(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+
+	// Encode a couple of messages back to back.
+	var buf bytes.Buffer
+	msgs := []btcwire.Message{
+		btcwire.NewMsgVerAck(),
+		btcwire.NewMsgPing(0x1122334455667788),
+	}
+	for _, msg := range msgs {
+		err := btcwire.WriteMessage(&buf, msg, pver, btcwire.TestNet3)
+		if err != nil {
+			t.Fatalf("WriteMessage: %v", err)
+		}
+	}
+	raw := buf.Bytes()
+
+	// Split the hex over several lines as a hex dump would.
+	hexStr := hex.EncodeToString(raw)
+	spacedHex := hexStr[:10] + " " + hexStr[10:48] + "\n" + hexStr[48:] +
+		"\n"
+
+	// Corrupt the checksum of the second message.
+	badChecksum := make([]byte, len(raw))
+	copy(badChecksum, raw)
+	badChecksum[24+20] ^= 0xff
+
+	pingPayload := raw[24+24:]
+
+	tests := []struct {
+		name  string   // Name of the test
+		input []byte   // Input data
+		cfg   config   // Configuration
+		want  []string // Expected substrings of the output
+		err   string   // Expected substring of the error
+	}{
+		{"raw", raw, config{format: "auto"}, []string{
+			"message 0: verack (0 byte payload) at offset 0",
+			"message 1: ping (8 byte payload) at offset 24",
+			"Nonce: 1234605616436508552",
+		}, ""},
+		{"hex", []byte(spacedHex), config{format: "auto"}, []string{
+			"message 1: ping (8 byte payload) at offset 24",
+		}, ""},
+		{"forced hex", []byte(hexStr), config{format: "hex"}, []string{
+			"message 0: verack",
+		}, ""},
+		{"explicit net", raw, config{net: "testnet3", format: "raw"},
+			[]string{"message 1: ping"}, ""},
+		{"dissect", raw, config{format: "auto", dissect: true},
+			[]string{"nonce: 1234605616436508552"}, ""},
+		{"payload", pingPayload, config{format: "raw", payload: "ping"},
+			[]string{"payload: ping (8 bytes)",
+				"Nonce: 1234605616436508552"}, ""},
+
+		// Errors.
+		{"wrong net", raw, config{net: "mainnet", format: "raw"}, nil,
+			"message 0 at offset 0"},
+		{"bad checksum", badChecksum, config{format: "raw"},
+			[]string{"message 0: verack"}, "message 1 at offset 24"},
+		{"unknown net", raw, config{net: "bogus", format: "raw"}, nil,
+			"unknown network"},
+		{"unknown format", raw, config{format: "bogus"}, nil,
+			"unknown input format"},
+		{"bad hex", []byte("abc"), config{format: "hex"}, nil,
+			"odd length"},
+		{"short payload", pingPayload[:4],
+			config{format: "raw", payload: "ping"}, nil, "EOF"},
+		{"empty", []byte{}, config{format: "auto"}, nil, "no input"},
+	}
+
+	dir, err := ioutil.TempDir("", "btcwiredump")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		file := filepath.Join(dir, "input")
+		err := ioutil.WriteFile(file, test.input, 0600)
+		if err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		test.cfg.pver = uint(pver)
+
+		var out bytes.Buffer
+		err = run(&out, []string{file}, &test.cfg)
+		if test.err == "" && err != nil {
+			t.Errorf("run #%d (%s) error %v", i, test.name, err)
+			continue
+		}
+		if test.err != "" && (err == nil ||
+			!strings.Contains(err.Error(), test.err)) {
+			t.Errorf("run #%d (%s) wrong error - got %v, want %q",
+				i, test.name, err, test.err)
+			continue
+		}
+		for _, want := range test.want {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("run #%d (%s) output missing %q:\n%s", i,
+					test.name, want, out.String())
+			}
+		}
+	}
+}
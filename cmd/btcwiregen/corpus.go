@@ -0,0 +1,180 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"github.com/conformal/btcwire"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// corpusRandomCount is the number of messages with random field values written
+// to the corpus per command and protocol version.
+const corpusRandomCount = 3
+
+// corpusCommands are the commands of the messages written to the corpus.
+var corpusCommands = []string{
+	btcwire.CmdVersion, btcwire.CmdVerAck, btcwire.CmdGetAddr,
+	btcwire.CmdAddr, btcwire.CmdGetBlocks, btcwire.CmdInv,
+	btcwire.CmdGetData, btcwire.CmdNotFound, btcwire.CmdBlock,
+	btcwire.CmdTx, btcwire.CmdGetHeaders, btcwire.CmdHeaders,
+	btcwire.CmdPing, btcwire.CmdPong, btcwire.CmdAlert,
+	btcwire.CmdMemPool, btcwire.CmdGetXThin, btcwire.CmdXThinBlock,
+	btcwire.CmdXBlockTx, btcwire.CmdGetXBlockTx,
+	btcwire.CmdGetGrapheneBlock, btcwire.CmdGrapheneBlock,
+	btcwire.CmdGetGrapheneBlockTx, btcwire.CmdGrapheneBlockTx,
+}
+
+// corpusVersions are the protocol versions the corpus messages are encoded
+// with.  They are the versions at which the encoding of messages changed.
+var corpusVersions = []uint32{
+	btcwire.MultipleAddressVersion,
+	btcwire.NetAddressTimeVersion,
+	btcwire.BIP0031Version,
+	btcwire.ProtocolVersion,
+}
+
+// corpusHash returns a hash which is distinct for every i.
+func corpusHash(i int) *btcwire.ShaHash {
+	return &btcwire.ShaHash{byte(i), byte(i >> 8), byte(i >> 16), 0x01}
+}
+
+// maxCountMessage returns a message of the provided command with the maximum
+// number of entries allowed in its list, or nil when the message has no list
+// which is limited by a count.
+func maxCountMessage(command string) btcwire.Message {
+	switch command {
+	case btcwire.CmdAddr:
+		msg := btcwire.NewMsgAddr()
+		for i := 0; i < btcwire.MaxAddrPerMsg; i++ {
+			ip := net.IPv4(10, byte(i>>16), byte(i>>8), byte(i))
+			na := btcwire.NewNetAddressIPPort(ip, 8333,
+				btcwire.SFNodeNetwork)
+			msg.AddAddress(na)
+		}
+		return msg
+
+	case btcwire.CmdGetBlocks:
+		msg := btcwire.NewMsgGetBlocks(corpusHash(0))
+		for i := 0; i < btcwire.MaxBlockLocatorsPerMsg; i++ {
+			msg.AddBlockLocatorHash(corpusHash(i))
+		}
+		return msg
+
+	case btcwire.CmdGetHeaders:
+		msg := btcwire.NewMsgGetHeaders()
+		for i := 0; i < btcwire.MaxBlockLocatorsPerMsg; i++ {
+			msg.AddBlockLocatorHash(corpusHash(i))
+		}
+		return msg
+
+	case btcwire.CmdInv, btcwire.CmdGetData, btcwire.CmdNotFound:
+		invList := make([]*btcwire.InvVect, btcwire.MaxInvPerMsg)
+		for i := range invList {
+			invList[i] = btcwire.NewInvVect(btcwire.InvTypeTx,
+				corpusHash(i))
+		}
+		switch command {
+		case btcwire.CmdInv:
+			return &btcwire.MsgInv{InvList: invList}
+		case btcwire.CmdGetData:
+			return &btcwire.MsgGetData{InvList: invList}
+		}
+		return &btcwire.MsgNotFound{InvList: invList}
+
+	case btcwire.CmdHeaders:
+		msg := btcwire.NewMsgHeaders()
+		for i := 0; i < btcwire.MaxBlockHeadersPerMsg; i++ {
+			bh := btcwire.NewBlockHeader(corpusHash(i),
+				corpusHash(i+1), 0x1d00ffff, uint32(i))
+			msg.AddBlockHeader(bh)
+		}
+		return msg
+	}
+	return nil
+}
+
+// corpusMessages returns the messages of the provided command to write to the
+// corpus keyed by the name of the case they cover.  They are an empty message,
+// several with random field values, and one with the maximum number of entries
+// in its list for messages which have one.
+func corpusMessages(r *rand.Rand, command string) (map[string]btcwire.Message, error) {
+	msgs := make(map[string]btcwire.Message)
+	empty, err := btcwire.NewMessageByCommand(command)
+	if err != nil {
+		return nil, err
+	}
+	msgs["empty"] = empty
+
+	for i := 0; i < corpusRandomCount; i++ {
+		msg, err := btcwire.RandomMessage(r, command)
+		if err != nil {
+			return nil, err
+		}
+		msgs[fmt.Sprintf("random%d", i)] = msg
+	}
+
+	if msg := maxCountMessage(command); msg != nil {
+		msgs["max"] = msg
+	}
+	return msgs, nil
+}
+
+// writeCorpus writes a fuzzing seed corpus to the directory dir, which is
+// created when it does not exist.  Every file holds a single message, encoded
+// according to cfg, and is named after the command, protocol version, and case
+// of the message, such as "inv-70001-max".  This flat layout is used by both
+// go-fuzz, whose corpus directory it can be written to, and libFuzzer.
+//
+// Every message is written at each of the protocol versions in corpusVersions
+// rather than the configured one.  Messages which can't be encoded at a
+// protocol version, such as the mempool message before it was introduced, are
+// skipped for that version.  The number of files written is returned.
+func writeCorpus(dir string, cfg *config) (int, error) {
+	btcnet, err := parseNet(cfg.net)
+	if err != nil {
+		return 0, err
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return 0, err
+	}
+
+	r := rand.New(rand.NewSource(cfg.seed))
+	written := 0
+	for _, command := range corpusCommands {
+		msgs, err := corpusMessages(r, command)
+		if err != nil {
+			return written, err
+		}
+		for name, msg := range msgs {
+			for _, pver := range corpusVersions {
+				encCfg := *cfg
+				encCfg.pver = uint(pver)
+				b, err := encodeMessage(msg, &encCfg, btcnet)
+				if _, ok := err.(*btcwire.MessageError); ok {
+					continue
+				}
+				if err != nil {
+					return written, err
+				}
+
+				file := fmt.Sprintf("%s-%d-%s", command, pver,
+					name)
+				err = ioutil.WriteFile(filepath.Join(dir, file),
+					b, 0644)
+				if err != nil {
+					return written, err
+				}
+				written++
+			}
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestWriteCorpus ensures the seed corpus covers the expected cases and every
+// file in it decodes as the message it is named after.
+func TestWriteCorpus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "btcwiregen")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := &config{net: "mainnet", seed: 1, format: "raw"}
+	n, err := writeCorpus(filepath.Join(dir, "corpus"), cfg)
+	if err != nil {
+		t.Fatalf("writeCorpus: %v", err)
+	}
+	files, err := ioutil.ReadDir(filepath.Join(dir, "corpus"))
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(files) != n {
+		t.Fatalf("writeCorpus: reported %d files, wrote %d", n,
+			len(files))
+	}
+
+	// Ensure the expected edge cases are present and messages which don't
+	// exist at a protocol version are not.
+	pver := strconv.Itoa(int(btcwire.ProtocolVersion))
+	tests := []struct {
+		name   string // Name of the file
+		exists bool   // Whether the file should exist
+	}{
+		{"inv-" + pver + "-max", true},
+		{"headers-" + pver + "-max", true},
+		{"addr-" + pver + "-empty", true},
+		{"tx-" + pver + "-random0", true},
+		{"grblk-" + pver + "-random2", true},
+		{"mempool-" + pver + "-empty", true},
+		{"mempool-209-empty", false},
+		{"ping-" + pver + "-max", false},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		_, err := os.Stat(filepath.Join(dir, "corpus", test.name))
+		if (err == nil) != test.exists {
+			t.Errorf("writeCorpus #%d: file %s exists %v, want %v",
+				i, test.name, err == nil, test.exists)
+		}
+	}
+
+	opts := &btcwire.ReadOptions{AllowUnknown: true}
+	for _, fi := range files {
+		parts := strings.Split(fi.Name(), "-")
+		if len(parts) != 3 {
+			t.Errorf("writeCorpus: unexpected file name %s", fi.Name())
+			continue
+		}
+		filePver, err := strconv.ParseUint(parts[1], 10, 32)
+		if err != nil {
+			t.Errorf("writeCorpus: bad protocol version in %s",
+				fi.Name())
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, "corpus", fi.Name()))
+		if err != nil {
+			t.Fatalf("ReadFile: %v", err)
+		}
+		msg, _, err := btcwire.ReadMessageWithOptions(bytes.NewReader(b),
+			uint32(filePver), btcwire.MainNet, opts)
+		if err != nil {
+			t.Errorf("ReadMessage %s: %v", fi.Name(), err)
+			continue
+		}
+		if msg.Command() != parts[0] {
+			t.Errorf("ReadMessage %s: wrong command %s", fi.Name(),
+				msg.Command())
+		}
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+btcwiregen writes serialized examples of bitcoin wire protocol messages, which
+is useful for seeding the test suites of other implementations and fuzzing
+corpora.
+
+Each example is a message of the requested command with its fields set to
+random values, or left empty with -empty, and then overridden by the fields of
+the JSON object given with -json.  The JSON object uses the names of the fields
+of the message type in this package, such as {"Nonce": 5} for a ping message,
+and only the fields it contains are changed.  Overridden messages are not
+required to be valid, so they may be used to produce negative test vectors.
+
+Usage:
+
+	btcwiregen [flags]
+
+The flags are:
+
+	-cmd command
+		The command of the messages to generate, such as tx.
+	-pver version
+		The protocol version used to encode the messages.
+	-net name
+		The network the messages belong to, which is one of mainnet,
+		testnet, testnet3, or a number such as 0xd9b4bef9.
+	-n count
+		The number of messages to generate.
+	-seed seed
+		The seed for the random field values.
+	-empty
+		Start from an empty message rather than random field values.
+	-json object
+		A JSON object of field values to override, or @file to read
+		it from a file.
+	-payload
+		Only write the message payloads without their headers.
+	-format hex|raw
+		Write each message as a line of hex, or as raw bytes.
+	-corpus dir
+		Write a fuzzing seed corpus to dir instead.
+
+The -corpus flag writes a seed corpus for go-fuzz or libFuzzer with one file per
+message.  It covers every supported command at each of the protocol versions at
+which the encoding of messages changed, with an empty message, several random
+ones, and, for messages with a list, one with the maximum number of entries.
+The -net, -seed, and -payload flags apply to the corpus as well.
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/conformal/btcwire"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// networks maps the names accepted by the -net flag to their networks.
+var networks = map[string]btcwire.BitcoinNet{
+	"mainnet":  btcwire.MainNet,
+	"testnet":  btcwire.TestNet,
+	"testnet3": btcwire.TestNet3,
+}
+
+// init registers the messages of the optional protocol extensions so they can
+// be generated like any other message.
+func init() {
+	btcwire.RegisterXThinMessages()
+	btcwire.RegisterGrapheneMessages()
+}
+
+// config houses the options which control which messages are generated and
+// how they are written.
+type config struct {
+	command   string
+	pver      uint
+	net       string
+	count     int
+	seed      int64
+	empty     bool
+	overrides string
+	payload   bool
+	format    string
+	corpus    string
+}
+
+// parseNet returns the network described by name, which is either one of the
+// names in networks or a number.
+func parseNet(name string) (btcwire.BitcoinNet, error) {
+	if btcnet, ok := networks[strings.ToLower(name)]; ok {
+		return btcnet, nil
+	}
+	n, err := strconv.ParseUint(name, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("unknown network %q", name)
+	}
+	return btcwire.BitcoinNet(n), nil
+}
+
+// readOverrides returns the JSON object of field overrides described by s,
+// which is either the object itself or the name of a file containing it
+// prefixed with @.
+func readOverrides(s string) ([]byte, error) {
+	if strings.HasPrefix(s, "@") {
+		return ioutil.ReadFile(s[1:])
+	}
+	return []byte(s), nil
+}
+
+// newMessage returns a message of the configured command with random or empty
+// field values, which are then overridden by the JSON object in overrides.
+func newMessage(r *rand.Rand, cfg *config, overrides []byte) (btcwire.Message, error) {
+	var msg btcwire.Message
+	var err error
+	if cfg.empty {
+		msg, err = btcwire.NewMessageByCommand(cfg.command)
+	} else {
+		msg, err = btcwire.RandomMessage(r, cfg.command)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if overrides != nil {
+		err := json.Unmarshal(overrides, msg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid overrides for %s: %v",
+				cfg.command, err)
+		}
+	}
+	return msg, nil
+}
+
+// encodeMessage returns the serialized message, or only its payload when
+// configured to.
+func encodeMessage(msg btcwire.Message, cfg *config, btcnet btcwire.BitcoinNet) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if cfg.payload {
+		err = msg.BtcEncode(&buf, uint32(cfg.pver))
+	} else {
+		err = btcwire.WriteMessage(&buf, msg, uint32(cfg.pver), btcnet)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// generate writes the configured number of messages to w.
+func generate(w io.Writer, cfg *config) error {
+	if cfg.command == "" {
+		return errors.New("no command given")
+	}
+	if cfg.format != "hex" && cfg.format != "raw" {
+		return fmt.Errorf("unknown output format %q", cfg.format)
+	}
+	btcnet, err := parseNet(cfg.net)
+	if err != nil {
+		return err
+	}
+	var overrides []byte
+	if cfg.overrides != "" {
+		overrides, err = readOverrides(cfg.overrides)
+		if err != nil {
+			return err
+		}
+	}
+
+	r := rand.New(rand.NewSource(cfg.seed))
+	for i := 0; i < cfg.count; i++ {
+		msg, err := newMessage(r, cfg, overrides)
+		if err != nil {
+			return err
+		}
+		b, err := encodeMessage(msg, cfg, btcnet)
+		if err != nil {
+			return err
+		}
+
+		if cfg.format == "hex" {
+			_, err = fmt.Fprintln(w, hex.EncodeToString(b))
+		} else {
+			_, err = w.Write(b)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.command, "cmd", "", "command of the messages to "+
+		"generate")
+	flag.UintVar(&cfg.pver, "pver", uint(btcwire.ProtocolVersion),
+		"protocol version used to encode the messages")
+	flag.StringVar(&cfg.net, "net", "mainnet", "network of the messages "+
+		"(mainnet, testnet, testnet3, or a number)")
+	flag.IntVar(&cfg.count, "n", 1, "number of messages to generate")
+	flag.Int64Var(&cfg.seed, "seed", 1, "seed for the random field values")
+	flag.BoolVar(&cfg.empty, "empty", false, "start from an empty message "+
+		"rather than random field values")
+	flag.StringVar(&cfg.overrides, "json", "", "JSON object of field "+
+		"values to override, or @file")
+	flag.BoolVar(&cfg.payload, "payload", false, "only write the message "+
+		"payloads")
+	flag.StringVar(&cfg.format, "format", "hex", "output format (hex or "+
+		"raw)")
+	flag.StringVar(&cfg.corpus, "corpus", "", "write a fuzzing seed "+
+		"corpus to this directory")
+	flag.Parse()
+
+	if cfg.corpus != "" {
+		n, err := writeCorpus(cfg.corpus, &cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "btcwiregen: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("wrote %d files to %s\n", n, cfg.corpus)
+		return
+	}
+
+	err := generate(os.Stdout, &cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "btcwiregen: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/conformal/btcwire"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGenerate tests generating messages with various options and ensures
+// they decode to the expected messages.
+func TestGenerate(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+
+	dir, err := ioutil.TempDir("", "btcwiregen")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	overridesFile := filepath.Join(dir, "overrides.json")
+	err = ioutil.WriteFile(overridesFile, []byte(`{"Nonce": 7}`), 0600)
+	if err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	tests := []struct {
+		name  string          // Name of the test
+		cfg   config          // Configuration
+		count int             // Expected number of messages
+		want  btcwire.Message // Expected message, if known
+	}{
+		{"random", config{command: "tx", count: 3}, 3, nil},
+		{"empty", config{command: "verack", count: 1, empty: true}, 1,
+			btcwire.NewMsgVerAck()},
+		{"overrides", config{command: "ping", count: 2,
+			overrides: `{"Nonce": 5}`}, 2, btcwire.NewMsgPing(5)},
+		{"overrides file", config{command: "pong", count: 1,
+			overrides: "@" + overridesFile}, 1,
+			btcwire.NewMsgPong(7)},
+		{"payload", config{command: "ping", count: 1, payload: true,
+			overrides: `{"Nonce": 9}`}, 1, btcwire.NewMsgPing(9)},
+		{"raw", config{command: "getaddr", count: 2, format: "raw"}, 2,
+			btcwire.NewMsgGetAddr()},
+		{"unknown", config{command: "custom", count: 1}, 1, nil},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		test.cfg.pver = uint(pver)
+		test.cfg.net = "testnet3"
+		if test.cfg.format == "" {
+			test.cfg.format = "hex"
+		}
+		test.cfg.seed = 1
+
+		var out bytes.Buffer
+		if err := generate(&out, &test.cfg); err != nil {
+			t.Errorf("generate #%d (%s) error %v", i, test.name, err)
+			continue
+		}
+
+		// Collect the serialized messages.
+		var encoded [][]byte
+		if test.cfg.format == "hex" {
+			lines := strings.Split(strings.TrimSpace(out.String()),
+				"\n")
+			for _, line := range lines {
+				b, err := hex.DecodeString(line)
+				if err != nil {
+					t.Fatalf("generate #%d (%s) invalid hex: %v",
+						i, test.name, err)
+				}
+				encoded = append(encoded, b)
+			}
+		} else {
+			msgs, rest, err := btcwire.DecodeAll(out.Bytes(), pver,
+				btcwire.TestNet3)
+			if err != nil || len(rest) != 0 {
+				t.Errorf("DecodeAll #%d (%s) error %v", i,
+					test.name, err)
+				continue
+			}
+			if len(msgs) != test.count {
+				t.Errorf("DecodeAll #%d (%s) wrong number of "+
+					"messages - got %d, want %d", i,
+					test.name, len(msgs), test.count)
+			}
+			continue
+		}
+		if len(encoded) != test.count {
+			t.Errorf("generate #%d (%s) wrong number of messages - "+
+				"got %d, want %d", i, test.name, len(encoded),
+				test.count)
+			continue
+		}
+
+		for j, b := range encoded {
+			var msg btcwire.Message
+			var err error
+			if test.cfg.payload {
+				msg, err = btcwire.NewMessageByCommand(
+					test.cfg.command)
+				if err == nil {
+					err = msg.BtcDecode(bytes.NewReader(b),
+						pver)
+				}
+			} else {
+				opts := &btcwire.ReadOptions{AllowUnknown: true}
+				msg, _, err = btcwire.ReadMessageWithOptions(
+					bytes.NewReader(b), pver,
+					btcwire.TestNet3, opts)
+			}
+			if err != nil {
+				t.Errorf("generate #%d (%s) message %d decode "+
+					"error %v", i, test.name, j, err)
+				continue
+			}
+			if msg.Command() != test.cfg.command {
+				t.Errorf("generate #%d (%s) message %d wrong "+
+					"command - got %s, want %s", i,
+					test.name, j, msg.Command(),
+					test.cfg.command)
+			}
+			if test.want != nil && !sameEncoding(msg, test.want) {
+				t.Errorf("generate #%d (%s) message %d got: "+
+					"%#v, want: %#v", i, test.name, j, msg,
+					test.want)
+			}
+		}
+	}
+}
+
+// sameEncoding returns whether the provided messages encode the same.
+func sameEncoding(a, b btcwire.Message) bool {
+	var abuf, bbuf bytes.Buffer
+	a.BtcEncode(&abuf, btcwire.ProtocolVersion)
+	b.BtcEncode(&bbuf, btcwire.ProtocolVersion)
+	return bytes.Equal(abuf.Bytes(), bbuf.Bytes())
+}
+
+// TestGenerateErrors performs negative tests against generating messages.
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		name string // Name of the test
+		cfg  config // Configuration
+		err  string // Expected substring of the error
+	}{
+		{"no command", config{format: "hex", net: "mainnet"},
+			"no command"},
+		{"bad format", config{command: "tx", format: "bogus",
+			net: "mainnet"}, "unknown output format"},
+		{"bad net", config{command: "tx", format: "hex", net: "bogus"},
+			"unknown network"},
+		{"bad command", config{command: "way too long command",
+			format: "hex", net: "mainnet", count: 1}, "command"},
+		{"bad json", config{command: "ping", format: "hex",
+			net: "mainnet", count: 1, overrides: `{"Nonce": "x"}`},
+			"invalid overrides"},
+		{"missing file", config{command: "ping", format: "hex",
+			net: "mainnet", count: 1, overrides: "@/nonexistent"},
+			"nonexistent"},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		err := generate(ioutil.Discard, &test.cfg)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("generate #%d (%s) wrong error - got %v, want %q",
+				i, test.name, err, test.err)
+		}
+	}
+}
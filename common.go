@@ -11,53 +11,228 @@ import (
 	"github.com/conformal/fastsha256"
 	"io"
 	"math"
+	"sync"
 )
 
 // Maximum payload size for a variable length integer.
 const maxVarIntPayload = 9
 
+// binaryFreeListMaxItems is the number of buffers to keep in the free list
+// to use for binary serialization and deserialization.
+const binaryFreeListMaxItems = 1024
+
+// binaryFreeList defines a concurrent safe free list of byte slices (up to the
+// maximum number defined by the binaryFreeListMaxItems constant) that have a
+// cap of 8 (thus it supports up to a uint64).  It is used to provide temporary
+// buffers for serializing and deserializing primitive numbers to and from their
+// binary encoding in order to greatly reduce the number of allocations
+// required.
+//
+// For convenience, functions are provided for each of the primitive unsigned
+// integers that automatically obtain a buffer from the free list, perform the
+// necessary binary conversion, read from or write to the given io.Reader or
+// io.Writer, and return the buffer to the free list.
+type binaryFreeList chan []byte
+
+// Borrow returns a byte slice from the free list with a length of 8.  A new
+// buffer is allocated if there are not any available on the free list.
+func (l binaryFreeList) Borrow() []byte {
+	var buf []byte
+	select {
+	case buf = <-l:
+	default:
+		buf = make([]byte, 8)
+	}
+	return buf[:8]
+}
+
+// Return puts the provided byte slice back on the free list.  The buffer MUST
+// have been obtained via the Borrow function and therefore have a cap of 8.
+func (l binaryFreeList) Return(buf []byte) {
+	select {
+	case l <- buf:
+	default:
+		// Let it go to the garbage collector.
+	}
+}
+
+// Uint8 reads a single byte from the provided reader using a buffer from the
+// free list and returns it as a uint8.
+func (l binaryFreeList) Uint8(r io.Reader) (uint8, error) {
+	buf := l.Borrow()[:1]
+	if _, err := io.ReadFull(r, buf); err != nil {
+		l.Return(buf)
+		return 0, err
+	}
+	rv := buf[0]
+	l.Return(buf)
+	return rv, nil
+}
+
+// Uint16 reads two bytes from the provided reader using a buffer from the
+// free list, converts it to a number using the provided byte order, and
+// returns the resulting uint16.
+func (l binaryFreeList) Uint16(r io.Reader, byteOrder binary.ByteOrder) (uint16, error) {
+	buf := l.Borrow()[:2]
+	if _, err := io.ReadFull(r, buf); err != nil {
+		l.Return(buf)
+		return 0, err
+	}
+	rv := byteOrder.Uint16(buf)
+	l.Return(buf)
+	return rv, nil
+}
+
+// Uint32 reads four bytes from the provided reader using a buffer from the
+// free list, converts it to a number using the provided byte order, and
+// returns the resulting uint32.
+func (l binaryFreeList) Uint32(r io.Reader, byteOrder binary.ByteOrder) (uint32, error) {
+	buf := l.Borrow()[:4]
+	if _, err := io.ReadFull(r, buf); err != nil {
+		l.Return(buf)
+		return 0, err
+	}
+	rv := byteOrder.Uint32(buf)
+	l.Return(buf)
+	return rv, nil
+}
+
+// Uint64 reads eight bytes from the provided reader using a buffer from the
+// free list, converts it to a number using the provided byte order, and
+// returns the resulting uint64.
+func (l binaryFreeList) Uint64(r io.Reader, byteOrder binary.ByteOrder) (uint64, error) {
+	buf := l.Borrow()[:8]
+	if _, err := io.ReadFull(r, buf); err != nil {
+		l.Return(buf)
+		return 0, err
+	}
+	rv := byteOrder.Uint64(buf)
+	l.Return(buf)
+	return rv, nil
+}
+
+// PutUint8 copies the provided uint8 into a buffer from the free list and
+// writes the resulting byte to the given writer.
+func (l binaryFreeList) PutUint8(w io.Writer, val uint8) error {
+	buf := l.Borrow()[:1]
+	buf[0] = val
+	_, err := w.Write(buf)
+	l.Return(buf)
+	return err
+}
+
+// PutUint16 serializes the provided uint16 using the given byte order into a
+// buffer from the free list and writes the resulting two bytes to the given
+// writer.
+func (l binaryFreeList) PutUint16(w io.Writer, byteOrder binary.ByteOrder, val uint16) error {
+	buf := l.Borrow()[:2]
+	byteOrder.PutUint16(buf, val)
+	_, err := w.Write(buf)
+	l.Return(buf)
+	return err
+}
+
+// PutUint32 serializes the provided uint32 using the given byte order into a
+// buffer from the free list and writes the resulting four bytes to the given
+// writer.
+func (l binaryFreeList) PutUint32(w io.Writer, byteOrder binary.ByteOrder, val uint32) error {
+	buf := l.Borrow()[:4]
+	byteOrder.PutUint32(buf, val)
+	_, err := w.Write(buf)
+	l.Return(buf)
+	return err
+}
+
+// PutUint64 serializes the provided uint64 using the given byte order into a
+// buffer from the free list and writes the resulting eight bytes to the given
+// writer.
+func (l binaryFreeList) PutUint64(w io.Writer, byteOrder binary.ByteOrder, val uint64) error {
+	buf := l.Borrow()[:8]
+	byteOrder.PutUint64(buf, val)
+	_, err := w.Write(buf)
+	l.Return(buf)
+	return err
+}
+
+// binarySerializer provides a free list of buffers to use for serializing and
+// deserializing primitive integer values to and from io.Readers and io.Writers.
+var binarySerializer binaryFreeList = make(chan []byte, binaryFreeListMaxItems)
+
+// littleEndian is a convenience variable since binary.LittleEndian is quite
+// long.
+var littleEndian = binary.LittleEndian
+
+// bigEndian is a convenience variable since binary.BigEndian is quite long.
+var bigEndian = binary.BigEndian
+
+// errUnsupportedElement returns the error for an element which is not one of
+// the types supported by readElement and writeElement.  The element itself is
+// intentionally not part of the error so it does not escape.
+func errUnsupportedElement(f string) error {
+	return messageError(f, "unsupported element type")
+}
+
 // readElement reads the next sequence of bytes from r using little endian
-// depending on the concrete type of element pointed to.
+// depending on the concrete type of element pointed to.  Only the types which
+// appear in bitcoin messages are supported.
 func readElement(r io.Reader, element interface{}) error {
-	var scratch [8]byte
-
 	// Attempt to read the element based on the concrete type via fast
 	// type assertions first.
 	switch e := element.(type) {
 	case *int32:
-		b := scratch[0:4]
-		_, err := io.ReadFull(r, b)
+		rv, err := binarySerializer.Uint32(r, littleEndian)
 		if err != nil {
 			return err
 		}
-		*e = int32(binary.LittleEndian.Uint32(b))
+		*e = int32(rv)
 		return nil
 
 	case *uint32:
-		b := scratch[0:4]
-		_, err := io.ReadFull(r, b)
+		rv, err := binarySerializer.Uint32(r, littleEndian)
 		if err != nil {
 			return err
 		}
-		*e = binary.LittleEndian.Uint32(b)
+		*e = rv
 		return nil
 
 	case *int64:
-		b := scratch[0:8]
-		_, err := io.ReadFull(r, b)
+		rv, err := binarySerializer.Uint64(r, littleEndian)
 		if err != nil {
 			return err
 		}
-		*e = int64(binary.LittleEndian.Uint64(b))
+		*e = int64(rv)
 		return nil
 
 	case *uint64:
-		b := scratch[0:8]
-		_, err := io.ReadFull(r, b)
+		rv, err := binarySerializer.Uint64(r, littleEndian)
+		if err != nil {
+			return err
+		}
+		*e = rv
+		return nil
+
+	case *uint8:
+		rv, err := binarySerializer.Uint8(r)
+		if err != nil {
+			return err
+		}
+		*e = rv
+		return nil
+
+	case *uint16:
+		rv, err := binarySerializer.Uint16(r, littleEndian)
+		if err != nil {
+			return err
+		}
+		*e = rv
+		return nil
+
+	case *bool:
+		rv, err := binarySerializer.Uint8(r)
 		if err != nil {
 			return err
 		}
-		*e = binary.LittleEndian.Uint64(b)
+		*e = rv != 0x00
 		return nil
 
 	// Message header checksum.
@@ -92,36 +267,35 @@ func readElement(r io.Reader, element interface{}) error {
 		return nil
 
 	case *ServiceFlag:
-		b := scratch[0:8]
-		_, err := io.ReadFull(r, b)
+		rv, err := binarySerializer.Uint64(r, littleEndian)
 		if err != nil {
 			return err
 		}
-		*e = ServiceFlag(binary.LittleEndian.Uint64(b))
+		*e = ServiceFlag(rv)
 		return nil
 
 	case *InvType:
-		b := scratch[0:4]
-		_, err := io.ReadFull(r, b)
+		rv, err := binarySerializer.Uint32(r, littleEndian)
 		if err != nil {
 			return err
 		}
-		*e = InvType(binary.LittleEndian.Uint32(b))
+		*e = InvType(rv)
 		return nil
 
 	case *BitcoinNet:
-		b := scratch[0:4]
-		_, err := io.ReadFull(r, b)
+		rv, err := binarySerializer.Uint32(r, littleEndian)
 		if err != nil {
 			return err
 		}
-		*e = BitcoinNet(binary.LittleEndian.Uint32(b))
+		*e = BitcoinNet(rv)
 		return nil
 	}
 
-	// Fall back to the slower binary.Read if a fast path was not available
-	// above.
-	return binary.Read(r, binary.LittleEndian, element)
+	// There is intentionally no reflection based fallback.  Every element
+	// type used by the package is handled above, and the lack of a
+	// fallback allows the compiler to prove the element does not escape,
+	// which avoids allocating when callers box values into the interface.
+	return errUnsupportedElement("readElement")
 }
 
 // readElements reads multiple items from r.  It is equivalent to multiple
@@ -136,44 +310,61 @@ func readElements(r io.Reader, elements ...interface{}) error {
 	return nil
 }
 
-// writeElement writes the little endian representation of element to w.
+// writeElement writes the little endian representation of element to w.  Only
+// the types which appear in bitcoin messages are supported.
 func writeElement(w io.Writer, element interface{}) error {
-	var scratch [8]byte
-
 	// Attempt to write the element based on the concrete type via fast
 	// type assertions first.
 	switch e := element.(type) {
 	case int32:
-		b := scratch[0:4]
-		binary.LittleEndian.PutUint32(b, uint32(e))
-		_, err := w.Write(b)
+		err := binarySerializer.PutUint32(w, littleEndian, uint32(e))
 		if err != nil {
 			return err
 		}
 		return nil
 
 	case uint32:
-		b := scratch[0:4]
-		binary.LittleEndian.PutUint32(b, e)
-		_, err := w.Write(b)
+		err := binarySerializer.PutUint32(w, littleEndian, e)
 		if err != nil {
 			return err
 		}
 		return nil
 
 	case int64:
-		b := scratch[0:8]
-		binary.LittleEndian.PutUint64(b, uint64(e))
-		_, err := w.Write(b)
+		err := binarySerializer.PutUint64(w, littleEndian, uint64(e))
 		if err != nil {
 			return err
 		}
 		return nil
 
 	case uint64:
-		b := scratch[0:8]
-		binary.LittleEndian.PutUint64(b, e)
-		_, err := w.Write(b)
+		err := binarySerializer.PutUint64(w, littleEndian, e)
+		if err != nil {
+			return err
+		}
+		return nil
+
+	case uint8:
+		err := binarySerializer.PutUint8(w, e)
+		if err != nil {
+			return err
+		}
+		return nil
+
+	case uint16:
+		err := binarySerializer.PutUint16(w, littleEndian, e)
+		if err != nil {
+			return err
+		}
+		return nil
+
+	case bool:
+		var err error
+		if e {
+			err = binarySerializer.PutUint8(w, 0x01)
+		} else {
+			err = binarySerializer.PutUint8(w, 0x00)
+		}
 		if err != nil {
 			return err
 		}
@@ -211,36 +402,29 @@ func writeElement(w io.Writer, element interface{}) error {
 		return nil
 
 	case ServiceFlag:
-		b := scratch[0:8]
-		binary.LittleEndian.PutUint64(b, uint64(e))
-		_, err := w.Write(b)
+		err := binarySerializer.PutUint64(w, littleEndian, uint64(e))
 		if err != nil {
 			return err
 		}
 		return nil
 
 	case InvType:
-		b := scratch[0:4]
-		binary.LittleEndian.PutUint32(b, uint32(e))
-		_, err := w.Write(b)
+		err := binarySerializer.PutUint32(w, littleEndian, uint32(e))
 		if err != nil {
 			return err
 		}
 		return nil
 
 	case BitcoinNet:
-		b := scratch[0:4]
-		binary.LittleEndian.PutUint32(b, uint32(e))
-		_, err := w.Write(b)
+		err := binarySerializer.PutUint32(w, littleEndian, uint32(e))
 		if err != nil {
 			return err
 		}
 		return nil
 	}
 
-	// Fall back to the slower binary.Write if a fast path was not available
-	// above.
-	return binary.Write(w, binary.LittleEndian, element)
+	// There is intentionally no reflection based fallback.  See readElement.
+	return errUnsupportedElement("writeElement")
 }
 
 // writeElements writes multiple items to w.  It is equivalent to multiple
@@ -255,37 +439,67 @@ func writeElements(w io.Writer, elements ...interface{}) error {
 	return nil
 }
 
+// bytesRemaining returns the number of bytes left to be read from r when it is
+// known, which is the case for readers with a Len method such as bytes.Reader
+// and bytes.Buffer, including when they are wrapped by a payloadReader.
+func bytesRemaining(r io.Reader) (int, bool) {
+	if pr, ok := r.(*payloadReader); ok {
+		r = pr.Reader
+	}
+	lr, ok := r.(interface {
+		Len() int
+	})
+	if !ok {
+		return 0, false
+	}
+	return lr.Len(), true
+}
+
+// checkCountFits returns an error when count elements, described by desc, of at
+// least minSize bytes each can't possibly fit in the bytes left to be read from
+// r.  This allows decoding to fail before allocating space for the elements
+// claimed by a bogus count.  Readers which don't know the number of bytes left
+// are not checked.  See bytesRemaining.
+func checkCountFits(r io.Reader, f string, desc string, count uint64, minSize uint64) error {
+	remaining, ok := bytesRemaining(r)
+	if !ok || count <= uint64(remaining)/minSize {
+		return nil
+	}
+
+	str := fmt.Sprintf("%s [count %d] require at least %d bytes each, but "+
+		"only %d bytes remain", desc, count, minSize, remaining)
+	return categorizedError(f, str, ErrCategoryMalformed)
+}
+
 // readVarInt reads a variable length integer from r and returns it as a uint64.
 func readVarInt(r io.Reader, pver uint32) (uint64, error) {
-	b := make([]byte, 8)
-	_, err := io.ReadFull(r, b[0:1])
+	discriminant, err := binarySerializer.Uint8(r)
 	if err != nil {
 		return 0, err
 	}
 
 	var rv uint64
-	discriminant := uint8(b[0])
 	switch discriminant {
 	case 0xff:
-		_, err := io.ReadFull(r, b)
+		sv, err := binarySerializer.Uint64(r, littleEndian)
 		if err != nil {
 			return 0, err
 		}
-		rv = binary.LittleEndian.Uint64(b)
+		rv = sv
 
 	case 0xfe:
-		_, err := io.ReadFull(r, b[0:4])
+		sv, err := binarySerializer.Uint32(r, littleEndian)
 		if err != nil {
 			return 0, err
 		}
-		rv = uint64(binary.LittleEndian.Uint32(b))
+		rv = uint64(sv)
 
 	case 0xfd:
-		_, err := io.ReadFull(r, b[0:2])
+		sv, err := binarySerializer.Uint16(r, littleEndian)
 		if err != nil {
 			return 0, err
 		}
-		rv = uint64(binary.LittleEndian.Uint16(b))
+		rv = uint64(sv)
 
 	default:
 		rv = uint64(discriminant)
@@ -298,31 +512,30 @@ func readVarInt(r io.Reader, pver uint32) (uint64, error) {
 // on its value.
 func writeVarInt(w io.Writer, pver uint32, val uint64) error {
 	if val < 0xfd {
-		_, err := w.Write([]byte{uint8(val)})
-		return err
+		return binarySerializer.PutUint8(w, uint8(val))
 	}
 
 	if val <= math.MaxUint16 {
-		buf := make([]byte, 3)
-		buf[0] = 0xfd
-		binary.LittleEndian.PutUint16(buf[1:], uint16(val))
-		_, err := w.Write(buf)
-		return err
+		err := binarySerializer.PutUint8(w, 0xfd)
+		if err != nil {
+			return err
+		}
+		return binarySerializer.PutUint16(w, littleEndian, uint16(val))
 	}
 
 	if val <= math.MaxUint32 {
-		buf := make([]byte, 5)
-		buf[0] = 0xfe
-		binary.LittleEndian.PutUint32(buf[1:], uint32(val))
-		_, err := w.Write(buf)
-		return err
+		err := binarySerializer.PutUint8(w, 0xfe)
+		if err != nil {
+			return err
+		}
+		return binarySerializer.PutUint32(w, littleEndian, uint32(val))
 	}
 
-	buf := make([]byte, 9)
-	buf[0] = 0xff
-	binary.LittleEndian.PutUint64(buf[1:], val)
-	_, err := w.Write(buf)
-	return err
+	err := binarySerializer.PutUint8(w, 0xff)
+	if err != nil {
+		return err
+	}
+	return binarySerializer.PutUint64(w, littleEndian, val)
 }
 
 // varIntSerializeSize returns the number of bytes it would take to serialize
@@ -348,6 +561,18 @@ func varIntSerializeSize(val uint64) int {
 	return 9
 }
 
+// varStringBufSize is the size of the pooled buffers readVarString reads
+// variable length strings into.  It is large enough for the user agent in a
+// version message, and therefore for nearly all strings seen in practice.
+const varStringBufSize = MaxUserAgentLen
+
+// varStringBufPool is a pool of buffers used by readVarString to avoid
+// allocating a byte slice for every string it reads in addition to the string
+// itself.
+var varStringBufPool = sync.Pool{New: func() interface{} {
+	return new([varStringBufSize]byte)
+}}
+
 // readVarString reads a variable length string from r and returns it as a Go
 // string.  A varString is encoded as a varInt containing the length of the
 // string, and the bytes that represent the string itself.  An error is returned
@@ -364,10 +589,31 @@ func readVarString(r io.Reader, pver uint32) (string, error) {
 	// Prevent variable length strings that are larger than the maximum
 	// message size.  It would be possible to cause memory exhaustion and
 	// panics without a sane upper bound on this count.
-	if count > maxMessagePayload {
+	if count > MaxMessagePayload {
 		str := fmt.Sprintf("variable length string is too long "+
-			"[count %d, max %d]", count, maxMessagePayload)
-		return "", messageError("readVarString", str)
+			"[count %d, max %d]", count, MaxMessagePayload)
+		return "", categorizedError("readVarString", str,
+			ErrCategoryOversized)
+	}
+	err = checkCountFits(r, "readVarString", "string bytes", count, 1)
+	if err != nil {
+		return "", err
+	}
+	err = reserveAlloc(r, "readVarString", "string bytes", count)
+	if err != nil {
+		return "", err
+	}
+
+	// Read strings which fit into a pooled buffer there so the only
+	// allocation is the one for the returned string.
+	if count <= varStringBufSize {
+		buf := varStringBufPool.Get().(*[varStringBufSize]byte)
+		defer varStringBufPool.Put(buf)
+		_, err = io.ReadFull(r, buf[:count])
+		if err != nil {
+			return "", err
+		}
+		return string(buf[:count]), nil
 	}
 
 	buf := make([]byte, count)
@@ -36,8 +36,6 @@ func (r *fakeRandReader) Read(p []byte) (int, error) {
 // is mainly to test the "fast" paths in readElement and writeElement which use
 // type assertions to avoid reflection when possible.
 func TestElementWire(t *testing.T) {
-	type writeElementReflect int32
-
 	tests := []struct {
 		in  interface{} // Value to encode
 		buf []byte      // Wire encoding
@@ -102,11 +100,10 @@ func TestElementWire(t *testing.T) {
 			btcwire.BitcoinNet(btcwire.MainNet),
 			[]byte{0xf9, 0xbe, 0xb4, 0xd9},
 		},
-		// Type not supported by the "fast" path and requires reflection.
-		{
-			writeElementReflect(1),
-			[]byte{0x01, 0x00, 0x00, 0x00},
-		},
+		{true, []byte{0x01}},
+		{false, []byte{0x00}},
+		{uint8(0xfe), []byte{0xfe}},
+		{uint16(0x0102), []byte{0x02, 0x01}},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -186,6 +183,8 @@ func TestElementWireErrors(t *testing.T) {
 		{btcwire.ServiceFlag(btcwire.SFNodeNetwork), 0, io.ErrShortWrite, io.EOF},
 		{btcwire.InvType(btcwire.InvTypeTx), 0, io.ErrShortWrite, io.EOF},
 		{btcwire.BitcoinNet(btcwire.MainNet), 0, io.ErrShortWrite, io.EOF},
+		{uint8(1), 0, io.ErrShortWrite, io.EOF},
+		{uint16(1), 0, io.ErrShortWrite, io.EOF},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -214,6 +213,31 @@ func TestElementWireErrors(t *testing.T) {
 	}
 }
 
+// TestElementWireUnsupported ensures reading and writing element types which
+// are not supported returns an error rather than falling back to reflection.
+func TestElementWireUnsupported(t *testing.T) {
+	type unsupported int32
+
+	var buf bytes.Buffer
+	err := btcwire.TstWriteElement(&buf, unsupported(1))
+	if _, ok := err.(*btcwire.MessageError); !ok {
+		t.Errorf("writeElement: wrong error got: %v <%T>, want: "+
+			"*MessageError", err, err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("writeElement: unexpected data written for "+
+			"unsupported type - got %x", buf.Bytes())
+	}
+
+	var val unsupported
+	r := bytes.NewReader([]byte{0x01, 0x00, 0x00, 0x00})
+	err = btcwire.TstReadElement(r, &val)
+	if _, ok := err.(*btcwire.MessageError); !ok {
+		t.Errorf("readElement: wrong error got: %v <%T>, want: "+
+			"*MessageError", err, err)
+	}
+}
+
 // TestVarIntWire tests wire encode and decode for variable length integers.
 func TestVarIntWire(t *testing.T) {
 	pver := btcwire.ProtocolVersion
@@ -368,6 +392,10 @@ func TestVarStringWire(t *testing.T) {
 	// str256 is a string that takes a 2-byte varint to encode.
 	str256 := strings.Repeat("test", 64)
 
+	// str4096 is a string that is larger than the pooled buffers used to
+	// read strings.
+	str4096 := strings.Repeat("test", 1024)
+
 	tests := []struct {
 		in   string // String to encode
 		out  string // String to decoded value
@@ -381,6 +409,8 @@ func TestVarStringWire(t *testing.T) {
 		{"Test", "Test", append([]byte{0x04}, []byte("Test")...), pver},
 		// 2-byte varint + string
 		{str256, str256, append([]byte{0xfd, 0x00, 0x01}, []byte(str256)...), pver},
+		// 2-byte varint + string larger than the pooled buffers
+		{str4096, str4096, append([]byte{0xfd, 0x00, 0x10}, []byte(str4096)...), pver},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -406,7 +436,7 @@ func TestVarStringWire(t *testing.T) {
 			continue
 		}
 		if val != test.out {
-			t.Errorf("readVarString #%d\n got: %d want: %d", i,
+			t.Errorf("readVarString #%d\n got: %s want: %s", i,
 				val, test.out)
 			continue
 		}
@@ -551,3 +581,86 @@ func TestRandomUint64Errors(t *testing.T) {
 		t.Errorf("TestRandomUint64Fails: nonce is not 0 [%v]", nonce)
 	}
 }
+
+// TestCountFitsRemaining ensures decoding counted structures fails before
+// reading any elements when the claimed count can't fit in the bytes which
+// remain, and that readers which don't know how many bytes remain are
+// unaffected.
+func TestCountFitsRemaining(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+
+	// zeros returns n zero bytes.
+	zeros := func(n int) []byte {
+		return make([]byte, n)
+	}
+
+	tests := []struct {
+		name string          // Name of the test
+		msg  btcwire.Message // Message to decode into
+		buf  []byte          // Wire encoding
+	}{
+		// Claims 2 inventory vectors, but only 1 remains.
+		{"inv", &btcwire.MsgInv{}, append([]byte{0x02}, zeros(36)...)},
+		{"getdata", &btcwire.MsgGetData{}, append([]byte{0x02}, zeros(36)...)},
+		{"notfound", &btcwire.MsgNotFound{}, append([]byte{0x02}, zeros(36)...)},
+
+		// Claims 2 addresses, but only 1 remains.
+		{"addr", &btcwire.MsgAddr{}, append([]byte{0x02}, zeros(30)...)},
+
+		// Claims 2 block headers, but only 1 remains.
+		{"headers", &btcwire.MsgHeaders{}, append([]byte{0x02}, zeros(81)...)},
+
+		// Claims 2 block locator hashes, but only the hash stop remains.
+		{
+			"getblocks",
+			&btcwire.MsgGetBlocks{},
+			append([]byte{0x01, 0x00, 0x00, 0x00, 0x02}, zeros(32)...),
+		},
+		{
+			"getheaders",
+			&btcwire.MsgGetHeaders{},
+			append([]byte{0x01, 0x00, 0x00, 0x00, 0x02}, zeros(32)...),
+		},
+
+		// Transaction which claims 0xfc inputs with nothing after them.
+		{"tx inputs", &btcwire.MsgTx{}, []byte{0x01, 0x00, 0x00, 0x00, 0xfc}},
+
+		// Transaction which claims 0xfc outputs with only the lock time
+		// after them.
+		{
+			"tx outputs",
+			&btcwire.MsgTx{},
+			[]byte{0x01, 0x00, 0x00, 0x00, 0x00, 0xfc, 0x00, 0x00, 0x00, 0x00},
+		},
+
+		// Version message with a user agent which claims 0xfc bytes.
+		{
+			"version user agent",
+			&btcwire.MsgVersion{},
+			append(zeros(80), 0xfc, 0x00, 0x00, 0x00, 0x00),
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Readers which know the number of bytes remaining must fail
+		// with a malformed error.
+		err := test.msg.BtcDecode(bytes.NewReader(test.buf), pver)
+		msgErr, ok := err.(*btcwire.MessageError)
+		if !ok || msgErr.Category != btcwire.ErrCategoryMalformed {
+			t.Errorf("BtcDecode #%d (%s) wrong error got: %v, want "+
+				"category: %v", i, test.name, err,
+				btcwire.ErrCategoryMalformed)
+			continue
+		}
+
+		// Other readers fail once the data runs out instead.
+		r := newFixedReader(len(test.buf), test.buf)
+		err = test.msg.BtcDecode(r, pver)
+		if err != io.EOF && err != io.ErrUnexpectedEOF {
+			t.Errorf("BtcDecode #%d (%s) wrong error for reader "+
+				"without length got: %v", i, test.name, err)
+			continue
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// CmdCompressed is the command in the header of messages written by
+// WriteMessageCompressed.  It is not part of the bitcoin protocol.
+const CmdCompressed = "zmsg"
+
+// WriteMessageCompressed writes a bitcoin Message to w in compressed form
+// including the necessary header information.  This is not part of the bitcoin
+// protocol and must only be used with peers which have negotiated it, such as
+// by both advertising SFNodeCompression, since other peers will not recognize
+// the message.  It is intended for private networks where relay bandwidth
+// matters more than compatibility with the main network.
+//
+// The message is framed as a regular message with the CmdCompressed command
+// whose payload is the 12 byte zero padded command of msg followed by the
+// zlib compressed payload of msg.  The limits on the payload of msg are
+// enforced on its uncompressed size in the same way as WriteMessage.  Peers
+// read compressed messages by setting the AllowCompressed read option.
+func WriteMessageCompressed(w io.Writer, msg Message, pver uint32, btcnet BitcoinNet) error {
+	var command [commandSize]byte
+
+	// Ensure the command can be represented in the compressed payload.
+	cmd := msg.Command()
+	err := validateCommand("WriteMessageCompressed", cmd)
+	if err != nil {
+		return err
+	}
+	copy(command[:], []byte(cmd))
+
+	var payload bytes.Buffer
+	err = msg.BtcEncode(&payload, pver)
+	if err != nil {
+		return err
+	}
+	lenp := payload.Len()
+
+	// Enforce maximum message payload based on the message type.
+	mpl := maxPayloadLength(msg, pver, btcnet, nil)
+	if lenp > MaxMessagePayload || uint32(lenp) > mpl {
+		str := fmt.Sprintf("message payload is too large - encoded "+
+			"%d bytes, but maximum message payload size for "+
+			"messages of type [%s] is %d.", lenp, cmd, mpl)
+		return messageError("WriteMessageCompressed", str)
+	}
+
+	// Compress the payload after the wrapped command.
+	var compressed bytes.Buffer
+	compressed.Write(command[:])
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(payload.Bytes())
+	zw.Close()
+
+	// Compression only grows incompressible payloads slightly, but ensure
+	// the result still fits in a message.
+	lenc := compressed.Len()
+	if lenc > MaxMessagePayload {
+		str := fmt.Sprintf("compressed message payload is too large - "+
+			"encoded %d bytes, but maximum message payload is %d "+
+			"bytes", lenc, MaxMessagePayload)
+		return messageError("WriteMessageCompressed", str)
+	}
+
+	var hdr [MessageHeaderSize]byte
+	littleEndian.PutUint32(hdr[0:4], uint32(btcnet))
+	copy(hdr[4:4+commandSize], CmdCompressed)
+	littleEndian.PutUint32(hdr[16:20], uint32(lenc))
+	copy(hdr[20:24], DoubleSha256(compressed.Bytes())[0:4])
+
+	// Write header and payload with a single call.  See WriteMessage.
+	rawMsg := append(hdr[:], compressed.Bytes()...)
+	_, err = w.Write(rawMsg)
+	return err
+}
+
+// readCompressedMessage reads the payload of a compressed message with the
+// provided header from r, verifies it, and decodes the message it wraps for
+// the provided protocol version, bitcoin network, and read options, which
+// must not be nil.  The returned payload is the decompressed payload of the
+// wrapped message.  See WriteMessageCompressed.
+func readCompressedMessage(r io.Reader, hdr *messageHeader, pver uint32, btcnet BitcoinNet, opts *ReadOptions) (Message, []byte, error) {
+	payload := make([]byte, hdr.length)
+	_, err := io.ReadFull(r, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Test checksum.
+	checksum := DoubleSha256(payload)[0:4]
+	if !bytes.Equal(checksum[:], hdr.checksum[:]) {
+		str := fmt.Sprintf("payload checksum failed - header "+
+			"indicates %v, but actual checksum is %v.",
+			hdr.checksum, checksum)
+		return nil, nil, categorizedError("ReadMessage", str,
+			ErrCategoryChecksum)
+	}
+
+	if len(payload) < commandSize {
+		str := fmt.Sprintf("compressed message payload of %d bytes is "+
+			"too short to contain a command", len(payload))
+		return nil, nil, categorizedError("ReadMessage", str,
+			ErrCategoryMalformed)
+	}
+	command := string(bytes.TrimRight(payload[:commandSize], "\x00"))
+	if err := validateCommand("ReadMessage", command); err != nil {
+		str := fmt.Sprintf("compressed message wraps invalid command "+
+			"%q", command)
+		return nil, nil, categorizedError("ReadMessage", str,
+			ErrCategoryMalformed)
+	}
+
+	// Compressed messages may not be nested.
+	if command == CmdCompressed {
+		return nil, nil, categorizedError("ReadMessage",
+			"compressed message wraps another compressed message",
+			ErrCategoryMalformed)
+	}
+
+	msg, err := makeEmptyMessage(command)
+	if err != nil {
+		if !opts.AllowUnknown {
+			return nil, nil, categorizedError("ReadMessage",
+				err.Error(), ErrCategoryUnknownCommand)
+		}
+		msg = &MsgUnknown{command: command}
+	}
+
+	// Limit the decompressed size to the maximum payload of the message
+	// type since a malicious peer could otherwise send a small payload
+	// which decompresses to exhaust the machine's memory.
+	mpl := maxPayloadLength(msg, pver, btcnet, opts)
+	zr, err := zlib.NewReader(bytes.NewReader(payload[commandSize:]))
+	if err != nil {
+		str := fmt.Sprintf("invalid compressed payload: %v", err)
+		return nil, nil, categorizedError("ReadMessage", str,
+			ErrCategoryMalformed)
+	}
+	defer zr.Close()
+	decompressed, err := ioutil.ReadAll(io.LimitReader(zr,
+		int64(mpl)+1))
+	if err != nil {
+		str := fmt.Sprintf("invalid compressed payload: %v", err)
+		return nil, nil, categorizedError("ReadMessage", str,
+			ErrCategoryMalformed)
+	}
+	if uint32(len(decompressed)) > mpl {
+		str := fmt.Sprintf("decompressed payload exceeds max length "+
+			"- max payload size for messages of type [%v] is %v.",
+			command, mpl)
+		return nil, nil, categorizedError("ReadMessage", str,
+			ErrCategoryOversized)
+	}
+
+	err = decodePayload(msg, decompressed, pver, btcnet, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return msg, decompressed, nil
+}
@@ -0,0 +1,302 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// compressedMessage returns a raw compressed message on the provided network
+// which wraps the provided command and zlib compressed payload.
+func compressedMessage(btcnet btcwire.BitcoinNet, command string, payload []byte) []byte {
+	var wrapped bytes.Buffer
+	var cmd [12]byte
+	copy(cmd[:], command)
+	wrapped.Write(cmd[:])
+	zw := zlib.NewWriter(&wrapped)
+	zw.Write(payload)
+	zw.Close()
+	return rawMessage(btcnet, btcwire.CmdCompressed, wrapped.Bytes())
+}
+
+// rawMessage returns a raw message on the provided network with the provided
+// command and payload along with a valid checksum.
+func rawMessage(btcnet btcwire.BitcoinNet, command string, payload []byte) []byte {
+	var buf bytes.Buffer
+	msg := btcwire.MustNewMsgUnknown(command, payload)
+	btcwire.WriteMessage(&buf, msg, 0, btcnet)
+	return buf.Bytes()
+}
+
+// TestCompressedMessage tests messages written by WriteMessageCompressed read
+// back as the message they wrap.
+func TestCompressedMessage(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcnet := btcwire.MainNet
+
+	// A block with many identical transactions compresses well.
+	bigBlock := btcwire.NewMsgBlock(&blockOne.Header)
+	for i := 0; i < 50; i++ {
+		bigBlock.AddTransaction(multiTx)
+	}
+
+	tests := []btcwire.Message{
+		btcwire.NewMsgVerAck(),
+		btcwire.NewMsgPing(123123),
+		multiTx,
+		&blockOne,
+		bigBlock,
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, msg := range tests {
+		var buf bytes.Buffer
+		err := btcwire.WriteMessageCompressed(&buf, msg, pver, btcnet)
+		if err != nil {
+			t.Errorf("WriteMessageCompressed #%d error %v", i, err)
+			continue
+		}
+		raw := buf.Bytes()
+		cmd := string(bytes.TrimRight(raw[4:16], "\x00"))
+		if cmd != btcwire.CmdCompressed {
+			t.Errorf("WriteMessageCompressed #%d wrong command got: %q "+
+				"want: %q", i, cmd, btcwire.CmdCompressed)
+			continue
+		}
+
+		// Compressed messages are only accepted when requested.
+		opts := &btcwire.ReadOptions{AllowCompressed: true}
+		got, payload, err := btcwire.ReadMessageWithOptions(
+			bytes.NewReader(raw), pver, btcnet, opts)
+		if err != nil {
+			t.Errorf("ReadMessageWithOptions #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, msg) {
+			t.Errorf("ReadMessageWithOptions #%d\n got: %s want: %s", i,
+				spew.Sdump(got), spew.Sdump(msg))
+			continue
+		}
+		if len(payload) != msg.SerializeSize(pver) {
+			t.Errorf("ReadMessageWithOptions #%d wrong payload length "+
+				"got: %d want: %d", i, len(payload),
+				msg.SerializeSize(pver))
+		}
+
+		_, _, err = btcwire.ReadMessage(bytes.NewReader(raw), pver,
+			btcnet)
+		merr, ok := err.(*btcwire.MessageError)
+		if !ok || merr.Category != btcwire.ErrCategoryUnknownCommand {
+			t.Errorf("ReadMessage #%d wrong error got: %v", i, err)
+		}
+	}
+
+	// Ensure the large block was actually compressed.
+	var plain, compressed bytes.Buffer
+	btcwire.WriteMessage(&plain, bigBlock, pver, btcnet)
+	btcwire.WriteMessageCompressed(&compressed, bigBlock, pver, btcnet)
+	if compressed.Len()*4 > plain.Len() {
+		t.Errorf("WriteMessageCompressed: block of %d bytes only "+
+			"compressed to %d bytes", plain.Len(), compressed.Len())
+	}
+}
+
+// TestCompressedMessageErrors performs negative tests against reading and
+// writing compressed messages to confirm error paths work correctly.
+func TestCompressedMessageErrors(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcnet := btcwire.MainNet
+	opts := &btcwire.ReadOptions{AllowCompressed: true}
+
+	// A ping payload which decompresses beyond its maximum length.
+	bomb := make([]byte, 1024*1024)
+
+	// A compressed payload with corrupt compressed data.
+	corrupt := compressedMessage(btcnet, btcwire.CmdPing, make([]byte, 8))
+	corrupt[len(corrupt)-1] ^= 0xff
+	corrupt = rawMessage(btcnet, btcwire.CmdCompressed,
+		corrupt[btcwire.MessageHeaderSize:])
+
+	tests := []struct {
+		name string
+		raw  []byte
+		cat  btcwire.ErrorCategory
+	}{
+		{"short", rawMessage(btcnet, btcwire.CmdCompressed,
+			[]byte("ping")), btcwire.ErrCategoryMalformed},
+		{"bad command", compressedMessage(btcnet, "p\x01ng", nil),
+			btcwire.ErrCategoryMalformed},
+		{"nested", compressedMessage(btcnet, btcwire.CmdCompressed, nil),
+			btcwire.ErrCategoryMalformed},
+		{"unknown", compressedMessage(btcnet, "bogus", nil),
+			btcwire.ErrCategoryUnknownCommand},
+		{"not zlib", rawMessage(btcnet, btcwire.CmdCompressed,
+			make([]byte, 20)), btcwire.ErrCategoryMalformed},
+		{"corrupt", corrupt, btcwire.ErrCategoryMalformed},
+		{"oversized", compressedMessage(btcnet, btcwire.CmdPing, bomb),
+			btcwire.ErrCategoryOversized},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		_, _, err := btcwire.ReadMessageWithOptions(
+			bytes.NewReader(test.raw), pver, btcnet, opts)
+		merr, ok := err.(*btcwire.MessageError)
+		if !ok || merr.Category != test.cat {
+			t.Errorf("ReadMessageWithOptions #%d (%s) wrong error "+
+				"got: %v want category: %v", i, test.name, err,
+				test.cat)
+		}
+	}
+
+	// Wrapped unknown commands are passed through when requested.
+	unknownOpts := &btcwire.ReadOptions{AllowCompressed: true,
+		AllowUnknown: true}
+	msg, _, err := btcwire.ReadMessageWithOptions(bytes.NewReader(
+		compressedMessage(btcnet, "bogus", []byte{0x01, 0x02})), pver,
+		btcnet, unknownOpts)
+	if err != nil {
+		t.Fatalf("ReadMessageWithOptions: unknown command error %v", err)
+	}
+	unknown, ok := msg.(*btcwire.MsgUnknown)
+	if !ok || unknown.Command() != "bogus" ||
+		!bytes.Equal(unknown.Payload, []byte{0x01, 0x02}) {
+		t.Errorf("ReadMessageWithOptions: wrong unknown message got: %v",
+			spew.Sdump(msg))
+	}
+
+	// Writing a message whose command can't be encoded fails.
+	var buf bytes.Buffer
+	err = btcwire.WriteMessageCompressed(&buf, &fakeMessage{command: ""},
+		pver, btcnet)
+	if _, ok := err.(*btcwire.MessageError); !ok {
+		t.Errorf("WriteMessageCompressed: wrong error got: %T(%v)", err,
+			err)
+	}
+}
+
+// TestHandshakeCompression ensures compression is negotiated by the handshake
+// only when both peers advertise it and that the resulting connections
+// exchange compressed messages.
+func TestHandshakeCompression(t *testing.T) {
+	tests := []struct {
+		name     string
+		out, in  btcwire.ServiceFlag
+		compress bool
+	}{
+		{"neither", 0, 0, false},
+		{"outbound only", btcwire.SFNodeCompression, 0, false},
+		{"inbound only", 0, btcwire.SFNodeCompression, false},
+		{"both", btcwire.SFNodeCompression, btcwire.SFNodeCompression,
+			true},
+	}
+
+	bigBlock := btcwire.NewMsgBlock(&blockOne.Header)
+	for i := 0; i < 10; i++ {
+		bigBlock.AddTransaction(multiTx)
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		outCfg := &btcwire.HandshakeConfig{
+			Net:      btcwire.MainNet,
+			Services: test.out,
+			Timeout:  time.Second * 5,
+		}
+		inCfg := &btcwire.HandshakeConfig{
+			Net:      btcwire.MainNet,
+			Services: test.in,
+			Timeout:  time.Second * 5,
+		}
+		out, in := handshakePipe(outCfg, inCfg)
+		if out.err != nil || in.err != nil {
+			t.Errorf("#%d (%s) outbound error %v, inbound error %v",
+				i, test.name, out.err, in.err)
+			continue
+		}
+		if out.caps.Compression != test.compress ||
+			in.caps.Compression != test.compress {
+
+			t.Errorf("#%d (%s) wrong compression got: %v and %v "+
+				"want: %v", i, test.name, out.caps.Compression,
+				in.caps.Compression, test.compress)
+		}
+
+		// Ensure large messages make it across either way.
+		go out.mc.Send(bigBlock)
+		select {
+		case msg := <-in.mc.Inbound():
+			if !reflect.DeepEqual(msg, bigBlock) {
+				t.Errorf("#%d (%s) wrong message got: %v", i,
+					test.name, spew.Sdump(msg))
+			}
+		case <-time.After(time.Second * 5):
+			t.Errorf("#%d (%s) timeout waiting for message", i,
+				test.name)
+		}
+		out.mc.Close()
+		in.mc.Close()
+	}
+}
+
+// TestMessageConnCompress ensures a MessageConn with compression enabled only
+// compresses messages with large payloads.
+func TestMessageConnCompress(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcnet := btcwire.MainNet
+
+	inConn, outConn := net.Pipe()
+	defer inConn.Close()
+	mc := btcwire.NewMessageConn(outConn, pver, btcnet,
+		&btcwire.MessageConnConfig{Compress: true})
+	mc.Start()
+	defer mc.Close()
+
+	bigBlock := btcwire.NewMsgBlock(&blockOne.Header)
+	for i := 0; i < 10; i++ {
+		bigBlock.AddTransaction(multiTx)
+	}
+
+	tests := []struct {
+		msg        btcwire.Message
+		compressed bool
+	}{
+		{btcwire.NewMsgPing(1), false},
+		{&blockOne, false},
+		{bigBlock, true},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		go mc.Send(test.msg)
+		var hdr [btcwire.MessageHeaderSize]byte
+		inConn.SetReadDeadline(time.Now().Add(time.Second * 5))
+		_, err := inConn.Read(hdr[:])
+		if err != nil {
+			t.Fatalf("Read #%d error %v", i, err)
+		}
+		cmd := string(bytes.TrimRight(hdr[4:16], "\x00"))
+		if (cmd == btcwire.CmdCompressed) != test.compressed {
+			t.Errorf("#%d wrong command got: %q", i, cmd)
+		}
+		length := uint32(hdr[16]) | uint32(hdr[17])<<8 |
+			uint32(hdr[18])<<16 | uint32(hdr[19])<<24
+		payload := make([]byte, length)
+		for n := 0; n < len(payload); {
+			m, err := inConn.Read(payload[n:])
+			if err != nil {
+				t.Fatalf("Read #%d error %v", i, err)
+			}
+			n += m
+		}
+	}
+}
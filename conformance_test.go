@@ -0,0 +1,209 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/conformal/btcwire"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// conformanceDir is the directory which houses the cross-implementation
+// conformance vectors.
+var conformanceDir = filepath.Join("testdata", "conformance")
+
+// conformanceVectors describes the format of the vectors.json file.
+type conformanceVectors struct {
+	Transactions []struct {
+		Comment string `json:"comment"`
+		Raw     string `json:"raw"`
+		TxID    string `json:"txid"`
+	} `json:"transactions"`
+	Blocks []struct {
+		Comment string `json:"comment"`
+		Raw     string `json:"raw"`
+		Hash    string `json:"hash"`
+	} `json:"blocks"`
+	Messages []struct {
+		Comment string             `json:"comment"`
+		Net     btcwire.BitcoinNet `json:"net"`
+		Raw     string             `json:"raw"`
+		Command string             `json:"command"`
+	} `json:"messages"`
+}
+
+// mustDecodeHex decodes the provided hex string and fails the test when it is
+// invalid.
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("invalid hex %q: %v", s, err)
+	}
+	return b
+}
+
+// TestConformanceVectors ensures transactions, blocks, and messages taken from
+// the bitcoin network decode to the hashes reported by the reference
+// implementation and encode back to the same bytes.
+func TestConformanceVectors(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join(conformanceDir,
+		"vectors.json"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	var vectors conformanceVectors
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	t.Logf("Running %d transaction tests", len(vectors.Transactions))
+	for i, test := range vectors.Transactions {
+		raw := mustDecodeHex(t, test.Raw)
+		var tx btcwire.MsgTx
+		err := tx.Deserialize(bytes.NewReader(raw))
+		if err != nil {
+			t.Errorf("Deserialize tx #%d (%s) error %v", i,
+				test.Comment, err)
+			continue
+		}
+		txSha, _ := tx.TxSha()
+		if txSha.String() != test.TxID {
+			t.Errorf("TxSha #%d (%s) got: %v want: %v", i,
+				test.Comment, txSha, test.TxID)
+		}
+		var buf bytes.Buffer
+		tx.Serialize(&buf)
+		if !bytes.Equal(buf.Bytes(), raw) {
+			t.Errorf("Serialize tx #%d (%s)\n got: %x want: %x", i,
+				test.Comment, buf.Bytes(), raw)
+		}
+	}
+
+	t.Logf("Running %d block tests", len(vectors.Blocks))
+	for i, test := range vectors.Blocks {
+		raw := mustDecodeHex(t, test.Raw)
+		var block btcwire.MsgBlock
+		err := block.Deserialize(bytes.NewReader(raw))
+		if err != nil {
+			t.Errorf("Deserialize block #%d (%s) error %v", i,
+				test.Comment, err)
+			continue
+		}
+		blockSha, _ := block.BlockSha()
+		if blockSha.String() != test.Hash {
+			t.Errorf("BlockSha #%d (%s) got: %v want: %v", i,
+				test.Comment, blockSha, test.Hash)
+		}
+		var buf bytes.Buffer
+		block.Serialize(&buf)
+		if !bytes.Equal(buf.Bytes(), raw) {
+			t.Errorf("Serialize block #%d (%s)\n got: %x want: %x",
+				i, test.Comment, buf.Bytes(), raw)
+		}
+	}
+
+	pver := btcwire.ProtocolVersion
+	t.Logf("Running %d message tests", len(vectors.Messages))
+	for i, test := range vectors.Messages {
+		raw := mustDecodeHex(t, test.Raw)
+		msg, _, err := btcwire.ReadMessage(bytes.NewReader(raw), pver,
+			test.Net)
+		if err != nil {
+			t.Errorf("ReadMessage #%d (%s) error %v", i,
+				test.Comment, err)
+			continue
+		}
+		if msg.Command() != test.Command {
+			t.Errorf("ReadMessage #%d (%s) wrong command got: %v "+
+				"want: %v", i, test.Comment, msg.Command(),
+				test.Command)
+			continue
+		}
+		var buf bytes.Buffer
+		err = btcwire.WriteMessage(&buf, msg, pver, test.Net)
+		if err != nil {
+			t.Errorf("WriteMessage #%d (%s) error %v", i,
+				test.Comment, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), raw) {
+			t.Errorf("WriteMessage #%d (%s)\n got: %x want: %x", i,
+				test.Comment, buf.Bytes(), raw)
+		}
+	}
+}
+
+// TestConformanceCoreTxVectors ensures the raw transactions in the
+// tx_valid.json and tx_invalid.json test vectors of the reference
+// implementation survive a decode and encode round trip unchanged.  The files
+// are used as-is, so they must be copied from src/test/data of the reference
+// implementation into testdata/conformance to be exercised.
+//
+// Entries of the files are either comments, which only contain strings, or
+// arrays whose second element is the serialized transaction.  Transactions in
+// tx_invalid.json are invalid per the consensus rules, not due to their
+// serialization, so they must round trip as well.  Transactions with witness
+// data are skipped since they are not supported by this package.
+func TestConformanceCoreTxVectors(t *testing.T) {
+	for _, name := range []string{"tx_valid.json", "tx_invalid.json"} {
+		data, err := ioutil.ReadFile(filepath.Join(conformanceDir, name))
+		if os.IsNotExist(err) {
+			t.Logf("Skipping %s since it is not present", name)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("ReadFile %s: %v", name, err)
+		}
+		var entries [][]interface{}
+		if err := json.Unmarshal(data, &entries); err != nil {
+			t.Fatalf("Unmarshal %s: %v", name, err)
+		}
+
+		var tested, skipped int
+		for i, entry := range entries {
+			if len(entry) < 2 {
+				continue
+			}
+			rawHex, ok := entry[1].(string)
+			if _, isComment := entry[0].(string); isComment || !ok {
+				continue
+			}
+			raw := mustDecodeHex(t, rawHex)
+
+			// Skip transactions with the witness marker and flag.
+			if len(raw) > 5 && raw[4] == 0x00 && raw[5] != 0x00 {
+				skipped++
+				continue
+			}
+
+			tested++
+			var tx btcwire.MsgTx
+			r := bytes.NewReader(raw)
+			err := tx.Deserialize(r)
+			if err != nil {
+				t.Errorf("Deserialize %s #%d error %v", name, i, err)
+				continue
+			}
+			if r.Len() != 0 {
+				t.Errorf("Deserialize %s #%d left %d trailing "+
+					"bytes", name, i, r.Len())
+				continue
+			}
+			var buf bytes.Buffer
+			tx.Serialize(&buf)
+			if !bytes.Equal(buf.Bytes(), raw) {
+				t.Errorf("Serialize %s #%d\n got: %x want: %x",
+					name, i, buf.Bytes(), raw)
+			}
+		}
+		t.Logf("Ran %d tests from %s, skipped %d with witness data",
+			tested, name, skipped)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"unsafe"
+)
+
+// These constants define the approximate number of bytes allocated for each
+// element of the lists of decoded messages, including the pointer to the
+// element for lists of pointers, which are charged to the decode allocation
+// budget.  See ReadOptions.MaxDecodeAlloc.
+const (
+	pointerSize          = uint64(unsafe.Sizeof(uintptr(0)))
+	txInAllocSize        = pointerSize + uint64(unsafe.Sizeof(TxIn{}))
+	txOutAllocSize       = pointerSize + uint64(unsafe.Sizeof(TxOut{}))
+	txAllocSize          = pointerSize + uint64(unsafe.Sizeof(MsgTx{}))
+	blockHeaderAllocSize = pointerSize + uint64(unsafe.Sizeof(BlockHeader{}))
+	invVectAllocSize     = pointerSize + uint64(unsafe.Sizeof(InvVect{}))
+	hashAllocSize        = pointerSize + HashSize
+	ibltCellAllocSize    = uint64(unsafe.Sizeof(IBLTCell{}))
+
+	// Decoded addresses also allocate their IP address.
+	netAddressAllocSize = pointerSize + uint64(unsafe.Sizeof(NetAddress{})) +
+		net.IPv6len
+)
+
+// reserveAlloc charges n bytes, which are about to be allocated for the
+// elements described by desc while decoding from r, to the decode allocation
+// budget of r.  An error attributed to the function f is returned when the
+// budget would be exceeded.  Only readers which are a payloadReader with a
+// budget set via ReadOptions are limited.
+func reserveAlloc(r io.Reader, f string, desc string, n uint64) error {
+	pr, ok := r.(*payloadReader)
+	if !ok || pr.maxAlloc == 0 {
+		return nil
+	}
+
+	pr.allocated += n
+	if pr.allocated <= pr.maxAlloc {
+		return nil
+	}
+	str := fmt.Sprintf("decoding %s exceeds the decode allocation budget "+
+		"[allocated %d, max %d]", desc, pr.allocated, pr.maxAlloc)
+	return categorizedError(f, str, ErrCategoryOversized)
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"testing"
+)
+
+// TestReadMessageDecodeBudget ensures the decode allocation budget rejects
+// messages which would allocate more than it allows.
+func TestReadMessageDecodeBudget(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcnet := btcwire.MainNet
+
+	// rawMsg returns the raw message for the provided message.
+	rawMsg := func(msg btcwire.Message) []byte {
+		var buf bytes.Buffer
+		err := btcwire.WriteMessage(&buf, msg, pver, btcnet)
+		if err != nil {
+			t.Fatalf("WriteMessage: %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	// Transaction with many inputs which have empty scripts, so each
+	// input takes much more memory to decode than its 41 byte encoding.
+	manyInputs := btcwire.NewMsgTx()
+	for i := 0; i < 1000; i++ {
+		prevOut := btcwire.NewOutPoint(&btcwire.ShaHash{}, uint32(i))
+		manyInputs.AddTxIn(btcwire.NewTxIn(prevOut, nil))
+	}
+	manyInputsRaw := rawMsg(manyInputs)
+	payloadLen := uint32(len(manyInputsRaw) - btcwire.MessageHeaderSize)
+
+	alert, _ := btcwire.NewMsgAlert("payload", "signature")
+
+	tests := []struct {
+		raw    []byte // Raw message
+		budget uint32 // Decode allocation budget
+		fail   bool   // Whether the budget is expected to be exceeded
+	}{
+		// No budget.
+		{manyInputsRaw, 0, false},
+		{rawMsg(&blockOne), 0, false},
+
+		// Generous budgets.
+		{manyInputsRaw, 10 * 1000 * 1000, false},
+		{rawMsg(&blockOne), 10 * 1000, false},
+		{rawMsg(alert), 16, false},
+
+		// The inputs take more memory than the payload.
+		{manyInputsRaw, payloadLen, true},
+
+		// Scripts and strings count against the budget.
+		{rawMsg(&blockOne), 200, true},
+		{rawMsg(alert), 15, true},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		opts := &btcwire.ReadOptions{MaxDecodeAlloc: test.budget}
+		_, _, err := btcwire.ReadMessageWithOptions(
+			bytes.NewReader(test.raw), pver, btcnet, opts)
+		if !test.fail {
+			if err != nil {
+				t.Errorf("ReadMessageWithOptions #%d unexpected "+
+					"error %v", i, err)
+			}
+			continue
+		}
+		msgErr, ok := err.(*btcwire.MessageError)
+		if !ok || msgErr.Category != btcwire.ErrCategoryOversized {
+			t.Errorf("ReadMessageWithOptions #%d wrong error got: "+
+				"%v, want category: %v", i, err,
+				btcwire.ErrCategoryOversized)
+		}
+	}
+}
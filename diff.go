@@ -0,0 +1,230 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// stringerType is used to render values which implement fmt.Stringer, such as
+// ServiceFlag, by their String method in a MessageDiff.
+var stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+
+// FieldDiff describes a single field which differs between two messages.
+type FieldDiff struct {
+	// Path identifies the field relative to the message, such as
+	// "Transactions[0].TxIn[1].PreviousOutpoint.Hash".  It is empty when
+	// the messages themselves are of different types.
+	Path string
+
+	// Want and Got are the renderings of the field in the expected and
+	// actual messages respectively.  Hashes are rendered in their usual
+	// byte-reversed hex, other byte sequences in hex, and list elements
+	// which only exist in one of the messages as "<missing>".
+	Want string
+	Got  string
+}
+
+// String returns the difference as a single line in the same form as the
+// failure messages of the tests in this package.
+func (d *FieldDiff) String() string {
+	if d.Path == "" {
+		return fmt.Sprintf("got %s, want %s", d.Got, d.Want)
+	}
+	return fmt.Sprintf("%s: got %s, want %s", d.Path, d.Got, d.Want)
+}
+
+// MessageDiff is the list of differences between two messages returned by
+// Diff.
+type MessageDiff []FieldDiff
+
+// String returns the differences with one per line.
+func (d MessageDiff) String() string {
+	lines := make([]string, 0, len(d))
+	for i := range d {
+		lines = append(lines, d[i].String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Diff returns the field-level differences between the expected message want
+// and the actual message got, in the order the fields are declared, or an empty
+// list when they are the same.  It is intended for readable test failures and
+// interoperability debugging where dumping both messages in full hides the
+// fields which actually differ.
+//
+// Only exported fields are compared, so cached encodings and other internal
+// state are ignored, and nil and empty lists are treated as the same.
+func Diff(want, got Message) MessageDiff {
+	var d MessageDiff
+	wv, gv := reflect.ValueOf(want), reflect.ValueOf(got)
+	if !wv.IsValid() || !gv.IsValid() || wv.Type() != gv.Type() {
+		d = append(d, FieldDiff{
+			Want: fmt.Sprintf("%T", want),
+			Got:  fmt.Sprintf("%T", got),
+		})
+		return d
+	}
+	diffValues(&d, "", wv, gv)
+	return d
+}
+
+// diffValues appends the differences between the values want and got, which
+// have the same type, to d with their paths relative to the provided path.
+func diffValues(d *MessageDiff, path string, want, got reflect.Value) {
+	add := func() {
+		*d = append(*d, FieldDiff{
+			Path: path,
+			Want: diffString(want),
+			Got:  diffString(got),
+		})
+	}
+
+	t := want.Type()
+	switch t {
+	case shaHashType:
+		if want.Interface() != got.Interface() {
+			add()
+		}
+		return
+
+	case timeType:
+		if !want.Interface().(time.Time).Equal(got.Interface().(time.Time)) {
+			add()
+		}
+		return
+
+	case ipType:
+		if !want.Interface().(net.IP).Equal(got.Interface().(net.IP)) {
+			add()
+		}
+		return
+	}
+
+	switch want.Kind() {
+	case reflect.Ptr:
+		switch {
+		case want.IsNil() && got.IsNil():
+		case want.IsNil() || got.IsNil():
+			add()
+		default:
+			diffValues(d, path, want.Elem(), got.Elem())
+		}
+
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).PkgPath != "" {
+				continue
+			}
+			fieldPath := t.Field(i).Name
+			if path != "" {
+				fieldPath = path + "." + fieldPath
+			}
+			diffValues(d, fieldPath, want.Field(i), got.Field(i))
+		}
+
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			if !bytes.Equal(diffBytes(want), diffBytes(got)) {
+				add()
+			}
+			return
+		}
+		for i := 0; i < want.Len() || i < got.Len(); i++ {
+			elemPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= want.Len():
+				*d = append(*d, FieldDiff{
+					Path: elemPath,
+					Want: "<missing>",
+					Got:  diffString(got.Index(i)),
+				})
+			case i >= got.Len():
+				*d = append(*d, FieldDiff{
+					Path: elemPath,
+					Want: diffString(want.Index(i)),
+					Got:  "<missing>",
+				})
+			default:
+				diffValues(d, elemPath, want.Index(i), got.Index(i))
+			}
+		}
+
+	default:
+		if want.Interface() != got.Interface() {
+			add()
+		}
+	}
+}
+
+// diffBytes returns the contents of v, which must be a slice or array of
+// bytes.
+func diffBytes(v reflect.Value) []byte {
+	b := make([]byte, v.Len())
+	reflect.Copy(reflect.ValueOf(b), v)
+	return b
+}
+
+// diffString returns the rendering of v used in a FieldDiff.  Structs and
+// lists, which are only rendered when one side is nil or missing, are
+// represented by their type.
+func diffString(v reflect.Value) string {
+	t := v.Type()
+	switch t {
+	case shaHashType:
+		return v.Interface().(ShaHash).String()
+
+	case timeType:
+		tm := v.Interface().(time.Time)
+		if tm.IsZero() {
+			return "<zero time>"
+		}
+		return fmt.Sprintf("%s (%d)", tm.UTC().Format(time.RFC3339Nano),
+			tm.Unix())
+
+	case ipType:
+		return v.Interface().(net.IP).String()
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return "<nil>"
+		}
+		if v.Elem().Kind() == reflect.Struct {
+			return "&" + goTypeString(t.Elem())
+		}
+		return diffString(v.Elem())
+
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			if v.Len() == 0 {
+				return "<empty>"
+			}
+			return hex.EncodeToString(diffBytes(v))
+		}
+		return fmt.Sprintf("%s (len %d)", goTypeString(t), v.Len())
+
+	case reflect.Struct:
+		return goTypeString(t)
+
+	case reflect.String:
+		return fmt.Sprintf("%q", v.String())
+	}
+
+	// Named numbers such as ServiceFlag are rendered with their value in
+	// addition to their description.
+	if t.Implements(stringerType) && v.Kind() != reflect.Bool {
+		return fmt.Sprintf("%d (%s)", v.Interface(),
+			v.Interface().(fmt.Stringer).String())
+	}
+	return fmt.Sprintf("%v", v.Interface())
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"github.com/conformal/btcwire"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestDiff ensures the differences between messages are reported with the
+// expected paths and renderings.
+func TestDiff(t *testing.T) {
+	// Modify a deep copy of multiTx so the shared test data is untouched.
+	tx := multiTx.Copy()
+	tx.TxIn[0].PreviousOutpoint.Hash = btcwire.GenesisHash
+	tx.TxIn[0].SignatureScript = nil
+	tx.AddTxOut(btcwire.NewTxOut(0, nil))
+	tx.LockTime = 10
+
+	na := &btcwire.NetAddress{
+		Timestamp: time.Unix(0x495fab29, 0),
+		Services:  btcwire.SFNodeNetwork,
+		IP:        net.ParseIP("127.0.0.1"),
+		Port:      8333,
+	}
+	addrWant := btcwire.NewMsgAddr()
+	addrWant.AddAddress(na)
+	addrGot := btcwire.NewMsgAddr()
+	addrGot.AddAddress(&btcwire.NetAddress{
+		Timestamp: time.Unix(0x495fab2a, 0),
+		IP:        net.ParseIP("127.0.0.1").To4(),
+		Port:      8333,
+	})
+
+	tests := []struct {
+		name string
+		want btcwire.Message
+		got  btcwire.Message
+		diff []string
+	}{
+		{
+			name: "same",
+			want: multiTx,
+			got:  multiTx.Copy(),
+		},
+		{
+			name: "nil and empty lists",
+			want: &btcwire.MsgInv{},
+			got:  btcwire.NewMsgInv(),
+		},
+		{
+			name: "different types",
+			want: btcwire.NewMsgPing(1),
+			got:  btcwire.NewMsgPong(1),
+			diff: []string{
+				"got *btcwire.MsgPong, want *btcwire.MsgPing",
+			},
+		},
+		{
+			name: "scalar",
+			want: btcwire.NewMsgPing(1),
+			got:  btcwire.NewMsgPing(2),
+			diff: []string{"Nonce: got 2, want 1"},
+		},
+		{
+			name: "tx",
+			want: multiTx,
+			got:  tx,
+			diff: []string{
+				"TxIn[0].PreviousOutpoint.Hash: got " +
+					btcwire.GenesisHash.String() + ", want " +
+					"0000000000000000000000000000000000000000" +
+					"000000000000000000000000",
+				"TxIn[0].SignatureScript: got <empty>, want " +
+					"0431dc001b0162",
+				"TxOut[1]: got &btcwire.TxOut, want <missing>",
+				"LockTime: got 10, want 0",
+			},
+		},
+		{
+			name: "addr",
+			want: addrWant,
+			got:  addrGot,
+			diff: []string{
+				"AddrList[0].Timestamp: got 2009-01-03T18:15:06Z " +
+					"(1231006506), want 2009-01-03T18:15:05Z " +
+					"(1231006505)",
+				"AddrList[0].Services: got 0 (0x0), want 1 " +
+					"(SFNodeNetwork)",
+			},
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for _, test := range tests {
+		diff := btcwire.Diff(test.want, test.got)
+		if len(diff) != len(test.diff) {
+			t.Errorf("Diff (%s): wrong number of differences - got "+
+				"%d, want %d\n%v", test.name, len(diff),
+				len(test.diff), diff)
+			continue
+		}
+		for i := range diff {
+			if got := diff[i].String(); got != test.diff[i] {
+				t.Errorf("Diff (%s) #%d:\n got: %s\nwant: %s",
+					test.name, i, got, test.diff[i])
+			}
+		}
+	}
+}
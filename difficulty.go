@@ -0,0 +1,107 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// powLimitBits houses the proof-of-work limit, which is the highest target a
+// block may have, of each known network in the compact form of the Bits field
+// of block headers.  The targets of the easiest blocks of a network have
+// exactly these bits.
+var powLimitBits = map[BitcoinNet]uint32{
+	MainNet:          0x1d00ffff,
+	TestNet:          0x207fffff,
+	TestNet3:         0x1d00ffff,
+	LitecoinMainNet:  0x1e0fffff,
+	LitecoinTestNet4: 0x1e0fffff,
+	DogecoinMainNet:  0x1e0fffff,
+	DogecoinTestNet3: 0x1e0fffff,
+	NamecoinMainNet:  0x1d00ffff,
+}
+
+// CompactToBig converts a compact representation of a whole number, such as
+// the target in the Bits field of a block header, to a big integer.
+//
+// Like IEEE754 floating point numbers, the compact representation consists of
+// an exponent in the most significant 8 bits and a mantissa in the other 24
+// bits.  Unlike them, the exponent is the number of bytes of the whole number
+// in base 256, and the mantissa is a sign bit followed by 23 bits of magnitude:
+//
+//	-------------------------------------------------
+//	|   Exponent     |    Sign    |    Mantissa     |
+//	-------------------------------------------------
+//	| 8 bits [31-24] | 1 bit [23] | 23 bits [22-00] |
+//	-------------------------------------------------
+//
+// so the number is mantissa * 256^(exponent-3), negated when the sign bit is
+// set.  This is the same format the reference implementation uses.
+func CompactToBig(compact uint32) *big.Int {
+	mantissa := compact & 0x007fffff
+	isNegative := compact&0x00800000 != 0
+	exponent := uint(compact >> 24)
+
+	// Shift the mantissa right for exponents below 3, dropping the bytes
+	// which do not fit, and left for exponents above it.
+	var bn *big.Int
+	if exponent <= 3 {
+		mantissa >>= 8 * (3 - exponent)
+		bn = big.NewInt(int64(mantissa))
+	} else {
+		bn = big.NewInt(int64(mantissa))
+		bn.Lsh(bn, 8*(exponent-3))
+	}
+
+	if isNegative {
+		bn = bn.Neg(bn)
+	}
+	return bn
+}
+
+// PowLimit returns the proof-of-work limit of the provided network, which is
+// the highest target a block may have.  An error is returned for unknown
+// networks.
+func PowLimit(btcnet BitcoinNet) (*big.Int, error) {
+	bits, ok := powLimitBits[btcnet]
+	if !ok {
+		str := fmt.Sprintf("no proof-of-work limit for network [%v]",
+			btcnet)
+		return nil, messageError("PowLimit", str)
+	}
+	return CompactToBig(bits), nil
+}
+
+// Difficulty returns the difficulty of the target in the provided compact bits
+// on the provided network, which is how many times harder it is to find a
+// block with the target than with the proof-of-work limit of the network.  The
+// easiest blocks of a network have a difficulty of 1.  It is intended for
+// display and analysis, so precision is limited to that of a float64.
+//
+// An error is returned when the bits do not decode to a positive target and
+// for the networks PowLimit does not know.
+func Difficulty(bits uint32, btcnet BitcoinNet) (float64, error) {
+	target := CompactToBig(bits)
+	if target.Sign() <= 0 {
+		str := fmt.Sprintf("target is not positive [bits %#08x]", bits)
+		return 0, messageError("Difficulty", str)
+	}
+	limit, err := PowLimit(btcnet)
+	if err != nil {
+		return 0, err
+	}
+
+	ratio := new(big.Float).Quo(new(big.Float).SetInt(limit),
+		new(big.Float).SetInt(target))
+	difficulty, _ := ratio.Float64()
+	return difficulty, nil
+}
+
+// Difficulty returns the difficulty of the target in the Bits field of the
+// block header on the provided network.  See Difficulty for details.
+func (h *BlockHeader) Difficulty(btcnet BitcoinNet) (float64, error) {
+	return Difficulty(h.Bits, btcnet)
+}
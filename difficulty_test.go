@@ -0,0 +1,105 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"github.com/conformal/btcwire"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// TestCompactToBig ensures compact numbers are converted to the expected big
+// integers.
+func TestCompactToBig(t *testing.T) {
+	tests := []struct {
+		in   uint32 // Compact number to convert
+		want string // Expected number in hex
+	}{
+		{0x00000000, "0"},
+		{0x00123456, "0"},
+		{0x01003456, "0"},
+		{0x01123456, "12"},
+		{0x02123456, "1234"},
+		{0x03123456, "123456"},
+		{0x04123456, "12345600"},
+		{0x05009234, "92340000"},
+		{0x01fedcba, "-7e"},
+		{0x04923456, "-12345600"},
+		{0x1d00ffff, "ffff" + strings.Repeat("00", 26)},
+		{0x207fffff, "7fffff" + strings.Repeat("00", 29)},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		want, ok := new(big.Int).SetString(test.want, 16)
+		if !ok {
+			t.Errorf("SetString #%d: invalid number %q", i, test.want)
+			continue
+		}
+		if got := btcwire.CompactToBig(test.in); got.Cmp(want) != 0 {
+			t.Errorf("CompactToBig #%d (%#08x) got: %x want: %x", i,
+				test.in, got, want)
+		}
+	}
+}
+
+// TestDifficulty ensures the difficulty of compact targets is computed
+// relative to the proof-of-work limit of each network.
+func TestDifficulty(t *testing.T) {
+	tests := []struct {
+		bits   uint32             // Compact target
+		btcnet btcwire.BitcoinNet // Network of the target
+		want   float64            // Expected difficulty
+		err    bool               // Whether an error is expected
+	}{
+		{0x1d00ffff, btcwire.MainNet, 1, false},
+		{0x1f111111, btcwire.MainNet, 0.000001, false},
+		{0x1e00ffff, btcwire.MainNet, 0.003906, false},
+		{0x1c00ffff, btcwire.MainNet, 256, false},
+		{0x1b0404cb, btcwire.MainNet, 16307.420939, false},
+		{0x12345678, btcwire.MainNet, 5913134931067755359633408.0,
+			false},
+		{0x1d00ffff, btcwire.TestNet3, 1, false},
+		{0x207fffff, btcwire.TestNet, 1, false},
+		{0x1e0fffff, btcwire.LitecoinMainNet, 1, false},
+		{0x1d00ffff, btcwire.LitecoinMainNet, 4096.0625, false},
+		{0, btcwire.MainNet, 0, true},
+		{0x1d80ffff, btcwire.MainNet, 0, true},
+		{0x1cf88f6f, btcwire.MainNet, 0, true},
+		{0x01003456, btcwire.MainNet, 0, true},
+		{0x1d00ffff, 0, 0, true},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		got, err := btcwire.Difficulty(test.bits, test.btcnet)
+		if (err != nil) != test.err {
+			t.Errorf("Difficulty #%d unexpected error - got: %v "+
+				"want error: %v", i, err, test.err)
+			continue
+		}
+		if test.err {
+			if _, ok := err.(*btcwire.MessageError); !ok {
+				t.Errorf("Difficulty #%d wrong error type %T", i,
+					err)
+			}
+			continue
+		}
+
+		// The expected difficulties are rounded to six decimal places.
+		if math.Abs(got-test.want) > 1e-6*math.Max(1, test.want) {
+			t.Errorf("Difficulty #%d (%#08x) got: %v want: %v", i,
+				test.bits, got, test.want)
+		}
+	}
+
+	// Ensure the header method uses the bits of the header.
+	got, err := btcwire.GenesisBlock.Header.Difficulty(btcwire.MainNet)
+	if err != nil || got != 1 {
+		t.Errorf("BlockHeader.Difficulty got: %v, %v want: 1", got, err)
+	}
+}
@@ -0,0 +1,406 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// maxDissectedFieldBytes is the maximum number of bytes of a field shown by
+// Dissection.String before the rest are elided.
+const maxDissectedFieldBytes = 16
+
+// DissectedField describes a single field of a dissected message payload.
+type DissectedField struct {
+	// Offset is the offset of the field within the payload.
+	Offset int
+
+	// Bytes are the raw bytes of the field.
+	Bytes []byte
+
+	// Name is the name of the field.  Fields of nested structures and lists
+	// are qualified with the name and index of the structure they belong
+	// to, such as "txin[0].sequence".
+	Name string
+
+	// Value is the decoded value of the field in human-readable form.
+	Value string
+}
+
+// Dissection is the field-by-field breakdown of a message payload produced by
+// Dissect.
+type Dissection []DissectedField
+
+// String returns the dissection as a table with one field per line showing the
+// offset, raw bytes, name, and decoded value of each field.  Long fields, such
+// as scripts, only show their first bytes.
+func (d Dissection) String() string {
+	var buf bytes.Buffer
+	for _, f := range d {
+		raw := hex.EncodeToString(f.Bytes)
+		if len(f.Bytes) > maxDissectedFieldBytes {
+			raw = hex.EncodeToString(f.Bytes[:maxDissectedFieldBytes]) +
+				"..."
+		}
+		fmt.Fprintf(&buf, "%06x  %-35s  %s: %s\n", f.Offset, raw, f.Name,
+			f.Value)
+	}
+	return buf.String()
+}
+
+// dissector walks a message payload recording each field it reads.  Once a
+// field can't be read because the payload is too short, the error is recorded
+// and all further reads are ignored, so callers only need to check it at the
+// end.
+type dissector struct {
+	payload []byte
+	offset  int
+	fields  Dissection
+	err     error
+
+	// failed is the name of the field which could not be read when err is
+	// set.
+	failed string
+}
+
+// remaining returns the number of bytes of the payload which have not been
+// read.
+func (d *dissector) remaining() int {
+	return len(d.payload) - d.offset
+}
+
+// truncated records the error for a field with the provided name which needs
+// more bytes than remain in the payload.
+func (d *dissector) truncated(name string, n uint64) {
+	str := fmt.Sprintf("payload too short for field %s at offset %d "+
+		"[need %d bytes, have %d]", name, d.offset, n, d.remaining())
+	d.err = categorizedError("Dissect", str, ErrCategoryMalformed)
+	d.failed = name
+}
+
+// field reads the next n bytes of the payload as a field with the provided
+// name and records it with the value produced by format.  It returns nil when
+// the payload is too short.
+func (d *dissector) field(name string, n int, format func([]byte) string) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if d.remaining() < n {
+		d.truncated(name, uint64(n))
+		return nil
+	}
+
+	b := d.payload[d.offset : d.offset+n]
+	d.fields = append(d.fields, DissectedField{
+		Offset: d.offset,
+		Bytes:  b,
+		Name:   name,
+		Value:  format(b),
+	})
+	d.offset += n
+	return b
+}
+
+// uint32 reads a little-endian uint32 field.
+func (d *dissector) uint32(name string) uint32 {
+	b := d.field(name, 4, func(b []byte) string {
+		return strconv.FormatUint(uint64(littleEndian.Uint32(b)), 10)
+	})
+	if b == nil {
+		return 0
+	}
+	return littleEndian.Uint32(b)
+}
+
+// int32 reads a little-endian int32 field.
+func (d *dissector) int32(name string) {
+	d.field(name, 4, func(b []byte) string {
+		return strconv.FormatInt(int64(int32(littleEndian.Uint32(b))), 10)
+	})
+}
+
+// uint64 reads a little-endian uint64 field.
+func (d *dissector) uint64(name string) {
+	d.field(name, 8, func(b []byte) string {
+		return strconv.FormatUint(littleEndian.Uint64(b), 10)
+	})
+}
+
+// int64 reads a little-endian int64 field.
+func (d *dissector) int64(name string) {
+	d.field(name, 8, func(b []byte) string {
+		return strconv.FormatInt(int64(littleEndian.Uint64(b)), 10)
+	})
+}
+
+// timestamp reads a little-endian unix timestamp field of the provided size,
+// which must be 4 or 8.
+func (d *dissector) timestamp(name string, n int) {
+	d.field(name, n, func(b []byte) string {
+		var sec int64
+		if n == 4 {
+			sec = int64(littleEndian.Uint32(b))
+		} else {
+			sec = int64(littleEndian.Uint64(b))
+		}
+		return time.Unix(sec, 0).UTC().Format(time.RFC3339)
+	})
+}
+
+// services reads a service flags field.
+func (d *dissector) services(name string) {
+	d.field(name, 8, func(b []byte) string {
+		return ServiceFlag(littleEndian.Uint64(b)).String()
+	})
+}
+
+// varInt reads a variable length integer field.
+func (d *dissector) varInt(name string) uint64 {
+	if d.err != nil {
+		return 0
+	}
+
+	// Determine the size of the integer from its discriminant.
+	n := 1
+	if d.remaining() > 0 {
+		switch d.payload[d.offset] {
+		case 0xff:
+			n = 9
+		case 0xfe:
+			n = 5
+		case 0xfd:
+			n = 3
+		}
+	}
+
+	var val uint64
+	d.field(name, n, func(b []byte) string {
+		val, _ = readVarInt(bytes.NewReader(b), ProtocolVersion)
+		return strconv.FormatUint(val, 10)
+	})
+	return val
+}
+
+// hash reads a hash field.
+func (d *dissector) hash(name string) {
+	d.field(name, HashSize, func(b []byte) string {
+		var hash ShaHash
+		copy(hash[:], b)
+		return hash.String()
+	})
+}
+
+// varBytes reads a variable length byte array field along with its length.
+func (d *dissector) varBytes(name string) {
+	count := d.varInt(name + "_len")
+	if d.err == nil && count > uint64(d.remaining()) {
+		d.truncated(name, count)
+		return
+	}
+	d.field(name, int(count), func(b []byte) string {
+		return hex.EncodeToString(b)
+	})
+}
+
+// varString reads a variable length string field along with its length.
+func (d *dissector) varString(name string) {
+	count := d.varInt(name + "_len")
+	if d.err == nil && count > uint64(d.remaining()) {
+		d.truncated(name, count)
+		return
+	}
+	d.field(name, int(count), func(b []byte) string {
+		return strconv.Quote(string(b))
+	})
+}
+
+// netAddress reads the fields of a bitcoin network address.
+func (d *dissector) netAddress(prefix string, ts bool) {
+	if ts {
+		d.timestamp(prefix+".timestamp", 4)
+	}
+	d.services(prefix + ".services")
+	d.field(prefix+".ip", 16, func(b []byte) string {
+		return net.IP(b).String()
+	})
+	d.field(prefix+".port", 2, func(b []byte) string {
+		return strconv.FormatUint(uint64(bigEndian.Uint16(b)), 10)
+	})
+}
+
+// invList reads a list of inventory vectors along with its count.
+func (d *dissector) invList() {
+	count := d.varInt("count")
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		prefix := fmt.Sprintf("inv[%d]", i)
+		d.field(prefix+".type", 4, func(b []byte) string {
+			return InvType(littleEndian.Uint32(b)).String()
+		})
+		d.hash(prefix + ".hash")
+	}
+}
+
+// blockHeader reads the fields of a block header including the transaction
+// count.
+func (d *dissector) blockHeader(prefix string) uint64 {
+	d.uint32(prefix + "version")
+	d.hash(prefix + "prev_block")
+	d.hash(prefix + "merkle_root")
+	d.timestamp(prefix+"timestamp", 4)
+	d.field(prefix+"bits", 4, func(b []byte) string {
+		return fmt.Sprintf("%#08x", littleEndian.Uint32(b))
+	})
+	d.uint32(prefix + "nonce")
+	return d.varInt(prefix + "txn_count")
+}
+
+// tx reads the fields of a transaction.
+func (d *dissector) tx(prefix string) {
+	d.uint32(prefix + "version")
+	count := d.varInt(prefix + "txin_count")
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		in := fmt.Sprintf("%stxin[%d].", prefix, i)
+		d.hash(in + "previous_outpoint.hash")
+		d.uint32(in + "previous_outpoint.index")
+		d.varBytes(in + "signature_script")
+		d.uint32(in + "sequence")
+	}
+	count = d.varInt(prefix + "txout_count")
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		out := fmt.Sprintf("%stxout[%d].", prefix, i)
+		d.int64(out + "value")
+		d.varBytes(out + "pk_script")
+	}
+	d.uint32(prefix + "lock_time")
+}
+
+// Dissect produces a field-by-field breakdown of the provided raw payload of a
+// message with the provided command.  Each field records its offset within the
+// payload, raw bytes, name, and decoded value, similar to the output of a
+// packet dissector.  This is intended for diagnosing interoperability problems
+// with other implementations, so the payload is not validated beyond what is
+// needed to locate each field, and the fields of payloads which are rejected by
+// ReadMessage, such as those which exceed limits, are still shown.
+//
+// Fields which depend on the protocol version are dissected according to
+// ProtocolVersion.  Unrecognized commands produce a single field containing the
+// entire payload, and any bytes following the known fields of a payload are
+// shown as a field named "trailing".
+//
+// When the payload is too short to contain a field, the fields which precede it
+// are returned along with a MessageError.
+func Dissect(command string, payload []byte) (Dissection, error) {
+	d := dissector{payload: payload}
+	d.dissect(command)
+	if d.err != nil {
+		return d.fields, d.err
+	}
+	return d.fields, nil
+}
+
+// dissect walks the entire payload as the payload of a message with the
+// provided command.  See Dissect for details.
+func (d *dissector) dissect(command string) {
+	switch command {
+	case CmdVersion:
+		d.int32("protocol_version")
+		d.services("services")
+		d.timestamp("timestamp", 8)
+		d.netAddress("addr_you", false)
+		d.netAddress("addr_me", false)
+		d.uint64("nonce")
+		d.varString("user_agent")
+		d.int32("last_block")
+
+		// The relay flag is optional, so only show it when present.
+		if d.err == nil && d.remaining() > 0 {
+			d.field("relay", 1, func(b []byte) string {
+				return strconv.FormatBool(b[0] != 0)
+			})
+		}
+
+	case CmdVerAck, CmdGetAddr, CmdMemPool:
+		// No payload.
+
+	case CmdAddr:
+		count := d.varInt("count")
+		for i := uint64(0); i < count && d.err == nil; i++ {
+			d.netAddress(fmt.Sprintf("addr[%d]", i), true)
+		}
+
+	case CmdInv, CmdGetData, CmdNotFound:
+		d.invList()
+
+	case CmdGetBlocks, CmdGetHeaders:
+		d.uint32("protocol_version")
+		count := d.varInt("count")
+		for i := uint64(0); i < count && d.err == nil; i++ {
+			d.hash(fmt.Sprintf("locator[%d]", i))
+		}
+		d.hash("hash_stop")
+
+	case CmdBlock:
+		count := d.blockHeader("")
+		for i := uint64(0); i < count && d.err == nil; i++ {
+			d.tx(fmt.Sprintf("tx[%d].", i))
+		}
+
+	case CmdHeaders:
+		count := d.varInt("count")
+		for i := uint64(0); i < count && d.err == nil; i++ {
+			d.blockHeader(fmt.Sprintf("header[%d].", i))
+		}
+
+	case CmdTx:
+		d.tx("")
+
+	case CmdPing, CmdPong:
+		// The nonce was added after BIP0031Version.
+		if d.remaining() > 0 {
+			d.uint64("nonce")
+		}
+
+	case CmdAlert:
+		d.varString("payload")
+		d.varString("signature")
+
+	default:
+		d.field("payload", d.remaining(), func(b []byte) string {
+			return hex.EncodeToString(b)
+		})
+	}
+
+	if d.err == nil && d.remaining() > 0 {
+		d.field("trailing", d.remaining(), func(b []byte) string {
+			return hex.EncodeToString(b)
+		})
+	}
+}
+
+// fieldAt returns the name, as used by Dissect, of the field of the payload of
+// a message with the provided command which was being decoded when decoding
+// stopped at the provided offset.  The truncated flag indicates decoding
+// stopped because the payload ended part way through a field, in which case it
+// is the field which could not be read.  Otherwise it is the field which
+// contains the last byte read, such as a count which exceeds its limit.  An
+// empty string is returned when there is no such field.
+func fieldAt(command string, payload []byte, offset int, truncated bool) string {
+	d := dissector{payload: payload}
+	d.dissect(command)
+	if truncated && d.failed != "" && offset >= d.offset {
+		return d.failed
+	}
+	for i := len(d.fields) - 1; i >= 0; i-- {
+		if d.fields[i].Offset < offset {
+			return d.fields[i].Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"testing"
+)
+
+// TestDissect tests dissecting the payloads of various messages.
+func TestDissect(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+
+	// encode returns the payload of the provided message.
+	encode := func(msg btcwire.Message) []byte {
+		var buf bytes.Buffer
+		if err := msg.BtcEncode(&buf, pver); err != nil {
+			t.Fatalf("BtcEncode: %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	addr := btcwire.NewMsgAddr()
+	addr.AddAddress(&btcwire.NetAddress{Port: 8333})
+	inv := btcwire.NewMsgInv()
+	inv.AddInvVect(btcwire.NewInvVect(btcwire.InvTypeBlock,
+		&btcwire.GenesisHash))
+	getBlocks := btcwire.NewMsgGetBlocks(&btcwire.GenesisHash)
+	getBlocks.AddBlockLocatorHash(&btcwire.GenesisHash)
+	header := btcwire.GenesisBlock.Header
+	header.TxnCount = 0
+	headers := btcwire.NewMsgHeaders()
+	headers.AddBlockHeader(&header)
+	alert, err := btcwire.NewMsgAlert("a", "b")
+	if err != nil {
+		t.Fatalf("NewMsgAlert: %v", err)
+	}
+
+	tests := []struct {
+		command string // Command of the payload
+		payload []byte // Payload to dissect
+		fields  int    // Expected number of fields
+		last    string // Expected name of the last field
+	}{
+		{"version", encode(baseVersion), 14, "relay"},
+		{"verack", nil, 0, ""},
+		{"addr", encode(addr), 5, "addr[0].port"},
+		{"inv", encode(inv), 3, "inv[0].hash"},
+		{"getblocks", encode(getBlocks), 4, "hash_stop"},
+		{"block", blockOneBytes, 19, "tx[0].lock_time"},
+		{"headers", encode(headers), 8, "header[0].txn_count"},
+		{"tx", multiTxEncoded, 12, "lock_time"},
+		{"ping", encode(btcwire.NewMsgPing(1)), 1, "nonce"},
+		{"alert", encode(alert), 4, "signature"},
+		{"bogus", []byte{0x01, 0x02}, 1, "payload"},
+		{"ping", []byte{0, 0, 0, 0, 0, 0, 0, 0, 0xff}, 2, "trailing"},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		fields, err := btcwire.Dissect(test.command, test.payload)
+		if err != nil {
+			t.Errorf("Dissect #%d (%s) error %v", i, test.command,
+				err)
+			continue
+		}
+		if len(fields) != test.fields {
+			t.Errorf("Dissect #%d (%s) wrong number of fields - got "+
+				"%d, want %d\n%v", i, test.command, len(fields),
+				test.fields, fields)
+			continue
+		}
+		if len(fields) > 0 && fields[len(fields)-1].Name != test.last {
+			t.Errorf("Dissect #%d (%s) wrong last field - got %s, "+
+				"want %s", i, test.command,
+				fields[len(fields)-1].Name, test.last)
+		}
+
+		// Ensure the fields cover the entire payload in order.
+		var covered []byte
+		for _, f := range fields {
+			if f.Offset != len(covered) {
+				t.Errorf("Dissect #%d (%s) field %s wrong offset "+
+					"- got %d, want %d", i, test.command,
+					f.Name, f.Offset, len(covered))
+				break
+			}
+			covered = append(covered, f.Bytes...)
+		}
+		if !bytes.Equal(covered, test.payload) {
+			t.Errorf("Dissect #%d (%s) fields do not cover payload",
+				i, test.command)
+		}
+	}
+}
+
+// TestDissectString tests the stringized output of a dissection.
+func TestDissectString(t *testing.T) {
+	payload := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
+	fields, err := btcwire.Dissect("pong", payload)
+	if err != nil {
+		t.Fatalf("Dissect: %v", err)
+	}
+
+	want := "000000  0102030405060708                     nonce: " +
+		"578437695752307201\n"
+	if got := fields.String(); got != want {
+		t.Errorf("String: wrong output\n got: %q\nwant: %q", got, want)
+	}
+}
+
+// TestDissectErrors tests dissecting payloads which are too short.
+func TestDissectErrors(t *testing.T) {
+	tests := []struct {
+		command string // Command of the payload
+		payload []byte // Payload to dissect
+		fields  int    // Expected number of fields before the error
+	}{
+		// Truncated transaction.
+		{"tx", multiTxEncoded[:50], 6},
+
+		// Script length beyond the end of the payload.
+		{"alert", []byte{0xfd, 0xff, 0xff, 0x00}, 1},
+
+		// Count which is never satisfied.
+		{"inv", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+			0xff}, 1},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		fields, err := btcwire.Dissect(test.command, test.payload)
+		if _, ok := err.(*btcwire.MessageError); !ok {
+			t.Errorf("Dissect #%d wrong error - got %v (%T), want "+
+				"*MessageError", i, err, err)
+			continue
+		}
+		if len(fields) != test.fields {
+			t.Errorf("Dissect #%d wrong number of fields - got %d, "+
+				"want %d\n%v", i, len(fields), test.fields,
+				fields)
+		}
+	}
+}
@@ -77,6 +77,11 @@ latest protocol version this package supports and is typically the value to use
 for all outbound connections before a potentially lower protocol version is
 negotiated.
 
+Each protocol version bump introduced features such as new messages or fields.
+The ProtocolFeature constants, such as btcwire.FeatureMemPool, report which
+protocol version introduced them via their MinVersion and IsSupported methods,
+and CommandMinVersion does the same for message commands.
+
 Bitcoin Network
 
 The bitcoin network is a magic number which is used to identify the start of a
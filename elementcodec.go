@@ -0,0 +1,126 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"io"
+	"unsafe"
+)
+
+// Integer is the set of fixed-width integer types which may be read and
+// written by ReadInteger and WriteInteger.  Types defined in terms of them,
+// such as BitcoinNet, ServiceFlag, and InvType, are included.
+type Integer interface {
+	~uint8 | ~uint16 | ~int32 | ~uint32 | ~int64 | ~uint64
+}
+
+// ReadInteger reads the little endian encoding of the integer pointed to by v
+// from r.  The number of bytes read is the size of the integer type.
+//
+// It is intended for authors of custom messages and, unlike ReadElements, does
+// not box the value into an interface, so it does not allocate.
+func ReadInteger[T Integer](r io.Reader, v *T) error {
+	switch unsafe.Sizeof(*v) {
+	case 1:
+		rv, err := binarySerializer.Uint8(r)
+		if err != nil {
+			return err
+		}
+		*v = T(rv)
+
+	case 2:
+		rv, err := binarySerializer.Uint16(r, littleEndian)
+		if err != nil {
+			return err
+		}
+		*v = T(rv)
+
+	case 4:
+		rv, err := binarySerializer.Uint32(r, littleEndian)
+		if err != nil {
+			return err
+		}
+		*v = T(rv)
+
+	default:
+		rv, err := binarySerializer.Uint64(r, littleEndian)
+		if err != nil {
+			return err
+		}
+		*v = T(rv)
+	}
+
+	return nil
+}
+
+// WriteInteger writes the little endian encoding of v to w.  The number of
+// bytes written is the size of the integer type.
+//
+// It is intended for authors of custom messages and, unlike WriteElements,
+// does not box the value into an interface, so it does not allocate.
+func WriteInteger[T Integer](w io.Writer, v T) error {
+	switch unsafe.Sizeof(v) {
+	case 1:
+		return binarySerializer.PutUint8(w, uint8(v))
+	case 2:
+		return binarySerializer.PutUint16(w, littleEndian, uint16(v))
+	case 4:
+		return binarySerializer.PutUint32(w, littleEndian, uint32(v))
+	}
+	return binarySerializer.PutUint64(w, littleEndian, uint64(v))
+}
+
+// ReadElements reads the encoding of each of the provided elements from r in
+// order.  Each element must be a pointer to one of the types which appear in
+// bitcoin messages, namely int32, uint32, int64, uint64, uint8, uint16, bool,
+// [4]byte, [12]byte, [16]byte, ShaHash, ServiceFlag, InvType, or BitcoinNet.
+// Integers are little endian and booleans are a single byte.
+//
+// It provides custom messages with the same encoding and error semantics as
+// the messages in this package.  A MessageError is returned for elements of
+// any other type.
+func ReadElements(r io.Reader, elements ...interface{}) error {
+	return readElements(r, elements...)
+}
+
+// WriteElements writes the encoding of each of the provided elements to w in
+// order.  See ReadElements for the supported types.  Elements are passed by
+// value, except for ShaHash which must be passed by pointer.
+func WriteElements(w io.Writer, elements ...interface{}) error {
+	return writeElements(w, elements...)
+}
+
+// ReadVarInt reads a variable length integer from r and returns it as a
+// uint64.
+func ReadVarInt(r io.Reader, pver uint32) (uint64, error) {
+	return readVarInt(r, pver)
+}
+
+// WriteVarInt serializes val to w using a variable number of bytes depending
+// on its value.
+func WriteVarInt(w io.Writer, pver uint32, val uint64) error {
+	return writeVarInt(w, pver, val)
+}
+
+// VarIntSerializeSize returns the number of bytes it would take to serialize
+// val as a variable length integer.
+func VarIntSerializeSize(val uint64) int {
+	return varIntSerializeSize(val)
+}
+
+// ReadVarString reads a variable length string from r and returns it as a Go
+// string.  A variable length string is encoded as a variable length integer
+// containing the length of the string followed by the bytes that represent the
+// string itself.
+func ReadVarString(r io.Reader, pver uint32) (string, error) {
+	return readVarString(r, pver)
+}
+
+// WriteVarString serializes str to w as a variable length integer containing
+// the length of the string followed by the bytes that represent the string
+// itself.
+func WriteVarString(w io.Writer, pver uint32, str string) error {
+	return writeVarString(w, pver, str)
+}
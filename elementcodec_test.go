@@ -0,0 +1,215 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// TestIntegerWire tests the generic integer codec for each size of integer
+// along with types defined in terms of them.
+func TestIntegerWire(t *testing.T) {
+	// Each test writes a value via WriteInteger and reads it back via
+	// ReadInteger into a value of the same type.
+	tests := []struct {
+		name  string
+		write func(io.Writer) error
+		read  func(io.Reader) (interface{}, error)
+		in    interface{}
+		buf   []byte
+	}{
+		{
+			"uint8",
+			func(w io.Writer) error { return btcwire.WriteInteger(w, uint8(0xfe)) },
+			func(r io.Reader) (interface{}, error) {
+				var v uint8
+				err := btcwire.ReadInteger(r, &v)
+				return v, err
+			},
+			uint8(0xfe),
+			[]byte{0xfe},
+		},
+		{
+			"uint16",
+			func(w io.Writer) error { return btcwire.WriteInteger(w, uint16(0x0102)) },
+			func(r io.Reader) (interface{}, error) {
+				var v uint16
+				err := btcwire.ReadInteger(r, &v)
+				return v, err
+			},
+			uint16(0x0102),
+			[]byte{0x02, 0x01},
+		},
+		{
+			"int32",
+			func(w io.Writer) error { return btcwire.WriteInteger(w, int32(-2)) },
+			func(r io.Reader) (interface{}, error) {
+				var v int32
+				err := btcwire.ReadInteger(r, &v)
+				return v, err
+			},
+			int32(-2),
+			[]byte{0xfe, 0xff, 0xff, 0xff},
+		},
+		{
+			"int64",
+			func(w io.Writer) error { return btcwire.WriteInteger(w, int64(-2)) },
+			func(r io.Reader) (interface{}, error) {
+				var v int64
+				err := btcwire.ReadInteger(r, &v)
+				return v, err
+			},
+			int64(-2),
+			[]byte{0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		},
+		{
+			"BitcoinNet",
+			func(w io.Writer) error { return btcwire.WriteInteger(w, btcwire.MainNet) },
+			func(r io.Reader) (interface{}, error) {
+				var v btcwire.BitcoinNet
+				err := btcwire.ReadInteger(r, &v)
+				return v, err
+			},
+			btcwire.MainNet,
+			[]byte{0xf9, 0xbe, 0xb4, 0xd9},
+		},
+		{
+			"ServiceFlag",
+			func(w io.Writer) error { return btcwire.WriteInteger(w, btcwire.SFNodeNetwork) },
+			func(r io.Reader) (interface{}, error) {
+				var v btcwire.ServiceFlag
+				err := btcwire.ReadInteger(r, &v)
+				return v, err
+			},
+			btcwire.SFNodeNetwork,
+			[]byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		var buf bytes.Buffer
+		err := test.write(&buf)
+		if err != nil {
+			t.Errorf("WriteInteger #%d (%s) error %v", i, test.name, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("WriteInteger #%d (%s)\n got: %x want: %x", i,
+				test.name, buf.Bytes(), test.buf)
+			continue
+		}
+
+		// Decode from wire format.
+		got, err := test.read(bytes.NewReader(test.buf))
+		if err != nil {
+			t.Errorf("ReadInteger #%d (%s) error %v", i, test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.in) {
+			t.Errorf("ReadInteger #%d (%s)\n got: %v want: %v", i,
+				test.name, got, test.in)
+			continue
+		}
+
+		// Decoding from a short buffer must fail.
+		_, err = test.read(bytes.NewReader(test.buf[:len(test.buf)-1]))
+		if err == nil {
+			t.Errorf("ReadInteger #%d (%s) did not fail on short "+
+				"read", i, test.name)
+		}
+	}
+}
+
+// TestElementsWire tests the exported element codec using a custom message
+// layout.
+func TestElementsWire(t *testing.T) {
+	var hash btcwire.ShaHash
+	hash[0] = 0x01
+	wantBuf := []byte{
+		0x01, 0x00, 0x00, 0x00, // int32
+		0x01,                   // bool
+		0x02, 0x00, 0x00, 0x00, // InvType
+	}
+	wantBuf = append(wantBuf, hash[:]...)
+
+	var buf bytes.Buffer
+	err := btcwire.WriteElements(&buf, int32(1), true,
+		btcwire.InvTypeBlock, &hash)
+	if err != nil {
+		t.Fatalf("WriteElements: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), wantBuf) {
+		t.Fatalf("WriteElements\n got: %x want: %x", buf.Bytes(),
+			wantBuf)
+	}
+
+	var (
+		i32     int32
+		b       bool
+		invType btcwire.InvType
+		gotHash btcwire.ShaHash
+	)
+	err = btcwire.ReadElements(bytes.NewReader(wantBuf), &i32, &b,
+		&invType, &gotHash)
+	if err != nil {
+		t.Fatalf("ReadElements: %v", err)
+	}
+	if i32 != 1 || !b || invType != btcwire.InvTypeBlock ||
+		!gotHash.IsEqual(&hash) {
+		t.Fatalf("ReadElements: unexpected values %v %v %v %v", i32, b,
+			invType, gotHash)
+	}
+
+	// Unsupported types must be rejected with a MessageError.
+	err = btcwire.WriteElements(&buf, "string")
+	if _, ok := err.(*btcwire.MessageError); !ok {
+		t.Errorf("WriteElements: wrong error for unsupported type "+
+			"got: %T(%v)", err, err)
+	}
+	var s string
+	err = btcwire.ReadElements(bytes.NewReader(wantBuf), &s)
+	if _, ok := err.(*btcwire.MessageError); !ok {
+		t.Errorf("ReadElements: wrong error for unsupported type "+
+			"got: %T(%v)", err, err)
+	}
+}
+
+// TestVarWire tests the exported variable length integer and string helpers.
+func TestVarWire(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+
+	var buf bytes.Buffer
+	err := btcwire.WriteVarInt(&buf, pver, 0xfd)
+	if err != nil {
+		t.Fatalf("WriteVarInt: %v", err)
+	}
+	err = btcwire.WriteVarString(&buf, pver, "test")
+	if err != nil {
+		t.Fatalf("WriteVarString: %v", err)
+	}
+	want := []byte{0xfd, 0xfd, 0x00, 0x04, 't', 'e', 's', 't'}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("encoding\n got: %x want: %x", buf.Bytes(), want)
+	}
+	if size := btcwire.VarIntSerializeSize(0xfd); size != 3 {
+		t.Errorf("VarIntSerializeSize: got %d want 3", size)
+	}
+
+	r := bytes.NewReader(want)
+	val, err := btcwire.ReadVarInt(r, pver)
+	if err != nil || val != 0xfd {
+		t.Errorf("ReadVarInt: got %d (%v) want %d", val, err, 0xfd)
+	}
+	str, err := btcwire.ReadVarString(r, pver)
+	if err != nil || str != "test" {
+		t.Errorf("ReadVarString: got %q (%v) want %q", str, err, "test")
+	}
+}
@@ -8,16 +8,99 @@ import (
 	"fmt"
 )
 
+// ErrorCategory classifies the issue described by a MessageError so callers
+// such as peer managers can decide how to treat the remote peer responsible
+// for it without parsing error strings.
+type ErrorCategory int
+
+// These constants define the various categories of message errors.
+const (
+	// ErrCategoryNone indicates the error has not been categorized.  This
+	// is the case for errors which result from misuse of the API, such as
+	// attempting to encode an invalid message, rather than from data
+	// received from a remote peer.
+	ErrCategoryNone ErrorCategory = iota
+
+	// ErrCategoryMalformed indicates a message was not encoded in
+	// accordance with the protocol.
+	ErrCategoryMalformed
+
+	// ErrCategoryOversized indicates a message, or an element within it,
+	// exceeds the maximum allowed size or count.
+	ErrCategoryOversized
+
+	// ErrCategoryUnsupportedVersion indicates a message, or a feature of
+	// it, is not valid for the negotiated protocol version.
+	ErrCategoryUnsupportedVersion
+
+	// ErrCategoryChecksum indicates the checksum of a message payload does
+	// not match the checksum in its header.
+	ErrCategoryChecksum
+
+	// ErrCategoryWrongNetwork indicates a message is for a different
+	// bitcoin network.
+	ErrCategoryWrongNetwork
+
+	// ErrCategoryUnknownCommand indicates a message has a well-formed
+	// command which is not recognized.
+	ErrCategoryUnknownCommand
+
+	// ErrCategoryDeprecated indicates a message belongs to a retired part
+	// of the protocol, such as an alert other than the final alert when
+	// ReadOptions.RejectAlerts is set.
+	ErrCategoryDeprecated
+)
+
+// Map of error categories back to their constant names for pretty printing.
+var errorCategoryStrings = map[ErrorCategory]string{
+	ErrCategoryNone:               "ErrCategoryNone",
+	ErrCategoryMalformed:          "ErrCategoryMalformed",
+	ErrCategoryOversized:          "ErrCategoryOversized",
+	ErrCategoryUnsupportedVersion: "ErrCategoryUnsupportedVersion",
+	ErrCategoryChecksum:           "ErrCategoryChecksum",
+	ErrCategoryWrongNetwork:       "ErrCategoryWrongNetwork",
+	ErrCategoryUnknownCommand:     "ErrCategoryUnknownCommand",
+	ErrCategoryDeprecated:         "ErrCategoryDeprecated",
+}
+
+// String returns the ErrorCategory in human-readable form.
+func (c ErrorCategory) String() string {
+	if s, ok := errorCategoryStrings[c]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown ErrorCategory (%d)", int(c))
+}
+
+// BanScore returns a suggested misbehavior score to assign to the peer which
+// sent a message that resulted in an error of the category.  The values mirror
+// the scores the reference implementation assigns for the same issues, where a
+// cumulative score of 100 typically results in a ban.  Categories which are
+// not considered misbehavior, such as unrecognized commands from newer peers
+// or checksum failures which may be due to corruption in transit, return 0.
+func (c ErrorCategory) BanScore() uint32 {
+	switch c {
+	case ErrCategoryMalformed:
+		return 100
+	case ErrCategoryOversized:
+		return 20
+	case ErrCategoryUnsupportedVersion:
+		return 1
+	}
+	return 0
+}
+
 // MessageError describes an issue with a message.
 // An example of some potential issues are messages from the wrong bitcoin
 // network, invalid commands, mismatched checksums, and exceeding max payloads.
 //
 // This provides a mechanism for the caller to type assert the error to
 // differentiate between general io errors such as io.EOF and issues that
-// resulted from malformed messages.
+// resulted from malformed messages.  The Category field further classifies
+// the issue.
 type MessageError struct {
-	Func        string // Function name
-	Description string // Human readable description of the issue
+	Func        string        // Function name
+	Description string        // Human readable description of the issue
+	Category    ErrorCategory // Classification of the issue
 }
 
 // Error satisfies the error interface and prints human-readable errors.
@@ -32,3 +115,37 @@ func (e *MessageError) Error() string {
 func messageError(f string, desc string) *MessageError {
 	return &MessageError{Func: f, Description: desc}
 }
+
+// categorizedError creates an error for the given function, description, and
+// category.
+func categorizedError(f string, desc string, cat ErrorCategory) *MessageError {
+	return &MessageError{Func: f, Description: desc, Category: cat}
+}
+
+// DecodeError describes an error which occurred while decoding the payload of a
+// message read with the AnnotateDecodeErrors read option.  It identifies where
+// in the payload decoding failed, which is otherwise difficult to determine for
+// large messages such as blocks.  The underlying error, such as a MessageError
+// or io.ErrUnexpectedEOF, is available via Unwrap.
+type DecodeError struct {
+	Command string // Command of the message
+	Offset  int    // Offset within the payload at which decoding stopped
+	Field   string // Name of the field being decoded, as used by Dissect
+	Err     error  // Underlying error
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e *DecodeError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("failed to decode %v payload at offset %d: %v",
+			e.Command, e.Offset, e.Err)
+	}
+	return fmt.Sprintf("failed to decode %v payload at offset %d in "+
+		"field %v: %v", e.Command, e.Offset, e.Field, e.Err)
+}
+
+// Unwrap returns the underlying error so it may be inspected with errors.Is
+// and errors.As.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
@@ -57,3 +57,15 @@ func (msg *fakeMessage) MaxPayloadLength(pver uint32) uint32 {
 
 	return lenp
 }
+
+// SerializeSize returns the length of the payload field of the fake message.
+// It satisfies the btcwire.Message interface.
+func (msg *fakeMessage) SerializeSize(pver uint32) int {
+	return len(msg.payload)
+}
+
+// Validate doesn't do anything.  It just satisfies the btcwire.Message
+// interface.
+func (msg *fakeMessage) Validate() error {
+	return nil
+}
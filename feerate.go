@@ -0,0 +1,71 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"fmt"
+	"math"
+)
+
+// FeeRate is a transaction fee rate in satoshi per 1000 virtual bytes (kvB),
+// which is the unit of the minimum fee rate announced by the feefilter message
+// of BIP0133 and of the relay fee rates of the reference implementation.
+//
+// The virtual size of a transaction only differs from its serialized size for
+// transactions with witness data, which this package does not support, so
+// rates per byte and per virtual byte are the same.
+type FeeRate int64
+
+// NewFeeRate returns the fee rate of a transaction of the provided serialized
+// size which pays the provided fee, rounded down to a whole number of satoshi
+// per kvB.  The rate of a transaction without any size is zero.
+func NewFeeRate(fee int64, size int) FeeRate {
+	if size <= 0 {
+		return 0
+	}
+	return FeeRate(fee * 1000 / int64(size))
+}
+
+// FeeRateFromSatPerByte returns the fee rate for the provided rate in satoshi
+// per byte, rounded to the nearest satoshi per kvB.
+func FeeRateFromSatPerByte(satPerByte float64) FeeRate {
+	return FeeRate(math.Floor(satPerByte*1000 + 0.5))
+}
+
+// FeeRateFromSatPerVByte returns the fee rate for the provided rate in satoshi
+// per virtual byte, rounded to the nearest satoshi per kvB.
+func FeeRateFromSatPerVByte(satPerVByte float64) FeeRate {
+	return FeeRateFromSatPerByte(satPerVByte)
+}
+
+// SatPerByte returns the fee rate in satoshi per byte.
+func (r FeeRate) SatPerByte() float64 {
+	return float64(r) / 1000
+}
+
+// SatPerVByte returns the fee rate in satoshi per virtual byte.
+func (r FeeRate) SatPerVByte() float64 {
+	return r.SatPerByte()
+}
+
+// Fee returns the fee for a transaction of the provided serialized size at the
+// fee rate, rounded down to a whole satoshi.
+func (r FeeRate) Fee(size int) int64 {
+	return int64(r) * int64(size) / 1000
+}
+
+// IsMetBy returns whether a transaction of the provided serialized size which
+// pays the provided fee pays at least the fee rate, such as whether it should
+// be relayed to a peer which announced the fee rate with a feefilter message.
+// Unlike comparing against the result of Fee or NewFeeRate, nothing is
+// rounded.
+func (r FeeRate) IsMetBy(fee int64, size int) bool {
+	return fee*1000 >= int64(r)*int64(size)
+}
+
+// String returns the fee rate in satoshi per kvB in human-readable form.
+func (r FeeRate) String() string {
+	return fmt.Sprintf("%d sat/kvB", int64(r))
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"github.com/conformal/btcwire"
+	"testing"
+)
+
+// TestFeeRate ensures fee rates convert between units and compute fees as
+// expected.
+func TestFeeRate(t *testing.T) {
+	tests := []struct {
+		rate       btcwire.FeeRate // Fee rate to test
+		satPerByte float64         // Expected rate in satoshi per byte
+		size       int             // Transaction size to compute a fee for
+		fee        int64           // Expected fee for the size
+		str        string          // Expected string
+	}{
+		{0, 0, 250, 0, "0 sat/kvB"},
+		{1000, 1, 250, 250, "1000 sat/kvB"},
+		{1, 0.001, 999, 0, "1 sat/kvB"},
+		{1, 0.001, 1000, 1, "1 sat/kvB"},
+		{12345, 12.345, 226, 2789, "12345 sat/kvB"},
+		{-500, -0.5, 100, -50, "-500 sat/kvB"},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		if got := test.rate.SatPerByte(); got != test.satPerByte {
+			t.Errorf("SatPerByte #%d got: %v want: %v", i, got,
+				test.satPerByte)
+		}
+		if got := test.rate.SatPerVByte(); got != test.satPerByte {
+			t.Errorf("SatPerVByte #%d got: %v want: %v", i, got,
+				test.satPerByte)
+		}
+		got := btcwire.FeeRateFromSatPerByte(test.satPerByte)
+		if got != test.rate {
+			t.Errorf("FeeRateFromSatPerByte #%d got: %v want: %v", i,
+				got, test.rate)
+		}
+		got = btcwire.FeeRateFromSatPerVByte(test.satPerByte)
+		if got != test.rate {
+			t.Errorf("FeeRateFromSatPerVByte #%d got: %v want: %v",
+				i, got, test.rate)
+		}
+		if fee := test.rate.Fee(test.size); fee != test.fee {
+			t.Errorf("Fee #%d got: %d want: %d", i, fee, test.fee)
+		}
+		if s := test.rate.String(); s != test.str {
+			t.Errorf("String #%d got: %q want: %q", i, s, test.str)
+		}
+	}
+}
+
+// TestFeeRateMet ensures the fee rates of transactions are computed and
+// compared against a fee rate without rounding errors.
+func TestFeeRateMet(t *testing.T) {
+	tests := []struct {
+		fee  int64           // Fee paid by the transaction
+		size int             // Size of the transaction
+		rate btcwire.FeeRate // Expected fee rate of the transaction
+		min  btcwire.FeeRate // Minimum fee rate to compare against
+		met  bool            // Expected result of IsMetBy
+	}{
+		{250, 250, 1000, 1000, true},
+		{249, 250, 996, 1000, false},
+		{1000, 0, 0, 1000, true},
+		{0, 250, 0, 0, true},
+		{0, 250, 0, 1, false},
+
+		{1, 3, 333, 333, true},
+		{1, 3, 333, 334, false},
+
+		// The fee for 1001 bytes at 333 sat/kvB rounds down to 333
+		// satoshi, yet paying 333 satoshi is slightly below the rate.
+		{333, 1001, 332, 333, false},
+		{334, 1001, 333, 333, true},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		rate := btcwire.NewFeeRate(test.fee, test.size)
+		if rate != test.rate {
+			t.Errorf("NewFeeRate #%d got: %v want: %v", i, rate,
+				test.rate)
+		}
+		if got := test.min.IsMetBy(test.fee, test.size); got != test.met {
+			t.Errorf("IsMetBy #%d got: %v want: %v", i, got,
+				test.met)
+		}
+	}
+}
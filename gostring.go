@@ -0,0 +1,213 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// goStringPkg is the package qualifier of the types in this package in the
+// Go-syntax representations produced by goString.
+const goStringPkg = "btcwire."
+
+var (
+	// btcwirePkgPath is the import path of this package as reported by
+	// reflection.
+	btcwirePkgPath = reflect.TypeOf(ShaHash{}).PkgPath()
+
+	// The following types are rendered specially by writeGoSyntax.
+	shaHashType    = reflect.TypeOf(ShaHash{})
+	timeType       = reflect.TypeOf(time.Time{})
+	ipType         = reflect.TypeOf(net.IP(nil))
+	byteSliceType  = reflect.TypeOf([]byte(nil))
+	goStringerType = reflect.TypeOf((*fmt.GoStringer)(nil)).Elem()
+)
+
+// goString returns a Go-syntax representation of v which evaluates to an
+// equivalent value when compiled in a package which imports this one along
+// with the net and time packages.  Unexported fields are not represented and
+// fields with their zero value are omitted.  Nested values which implement
+// fmt.GoStringer are represented by their GoString method, while the method of
+// v itself is not used so it may be implemented in terms of this function.
+func goString(v interface{}) string {
+	var buf bytes.Buffer
+	writeGoSyntax(&buf, reflect.ValueOf(v), 0, true)
+	return buf.String()
+}
+
+// goTypeString returns the name of the provided type as it is written in Go
+// source outside of this package.
+func goTypeString(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return "*" + goTypeString(t.Elem())
+	case reflect.Slice:
+		if t.Name() == "" {
+			return "[]" + goTypeString(t.Elem())
+		}
+	case reflect.Array:
+		if t.Name() == "" {
+			return fmt.Sprintf("[%d]%s", t.Len(),
+				goTypeString(t.Elem()))
+		}
+	}
+	if t.PkgPath() == btcwirePkgPath {
+		return goStringPkg + t.Name()
+	}
+	return t.String()
+}
+
+// writeGoSyntax writes the Go-syntax representation of v to buf.  Multi-line
+// representations are indented by the provided number of tabs.  The GoString
+// method of v is not used when top is set.  See goString.
+func writeGoSyntax(buf *bytes.Buffer, v reflect.Value, indent int, top bool) {
+	t := v.Type()
+
+	// Pointers to hashes are represented directly by a call which returns
+	// one, while pointers to other types use their GoString method when
+	// they have one or are otherwise taken of a composite literal.
+	if t.Kind() == reflect.Ptr {
+		switch {
+		case v.IsNil():
+			buf.WriteString("nil")
+		case t.Elem() == shaHashType:
+			fmt.Fprintf(buf, "%sMustNewShaHashFromStr(%q)", goStringPkg,
+				v.Elem().Interface().(ShaHash).String())
+		case !top && t.Implements(goStringerType) &&
+			!t.Elem().Implements(goStringerType):
+			writeGoStringer(buf, v, indent)
+		default:
+			buf.WriteByte('&')
+			writeGoSyntax(buf, v.Elem(), indent, false)
+		}
+		return
+	}
+
+	switch t {
+	case timeType:
+		tm := v.Interface().(time.Time)
+		if tm.IsZero() {
+			buf.WriteString("time.Time{}")
+			return
+		}
+		fmt.Fprintf(buf, "time.Unix(%d, %d)", tm.Unix(), tm.Nanosecond())
+		return
+
+	case ipType:
+		if v.IsNil() {
+			buf.WriteString("nil")
+			return
+		}
+		fmt.Fprintf(buf, "net.ParseIP(%q)", v.Interface().(net.IP).String())
+		return
+
+	case byteSliceType:
+		if v.IsNil() {
+			buf.WriteString("nil")
+			return
+		}
+		buf.WriteString("[]byte{")
+		for i, b := range v.Bytes() {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			fmt.Fprintf(buf, "%#02x", b)
+		}
+		buf.WriteByte('}')
+		return
+	}
+
+	if !top && t.Implements(goStringerType) {
+		writeGoStringer(buf, v, indent)
+		return
+	}
+
+	tabs := strings.Repeat("\t", indent+1)
+	switch v.Kind() {
+	case reflect.Struct:
+		buf.WriteString(goTypeString(t))
+		buf.WriteByte('{')
+		wroteField := false
+		for i := 0; i < t.NumField(); i++ {
+			field := v.Field(i)
+			if t.Field(i).PkgPath != "" || field.IsZero() {
+				continue
+			}
+			if !wroteField {
+				buf.WriteByte('\n')
+				wroteField = true
+			}
+			buf.WriteString(tabs)
+			buf.WriteString(t.Field(i).Name)
+			buf.WriteString(": ")
+			writeGoSyntax(buf, field, indent+1, false)
+			buf.WriteString(",\n")
+		}
+		if wroteField {
+			buf.WriteString(tabs[1:])
+		}
+		buf.WriteByte('}')
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			buf.WriteString("nil")
+			return
+		}
+		buf.WriteString(goTypeString(t))
+		buf.WriteByte('{')
+		if v.Len() > 0 {
+			buf.WriteByte('\n')
+			for i := 0; i < v.Len(); i++ {
+				buf.WriteString(tabs)
+				writeGoSyntax(buf, v.Index(i), indent+1, false)
+				buf.WriteString(",\n")
+			}
+			buf.WriteString(tabs[1:])
+		}
+		buf.WriteByte('}')
+
+	case reflect.String:
+		fmt.Fprintf(buf, "%q", v.String())
+
+	case reflect.Bool:
+		fmt.Fprintf(buf, "%t", v.Bool())
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		writeNamedNumber(buf, t, fmt.Sprintf("%d", v.Int()))
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		writeNamedNumber(buf, t, fmt.Sprintf("%d", v.Uint()))
+
+	default:
+		fmt.Fprintf(buf, "%#v", v.Interface())
+	}
+}
+
+// writeGoStringer writes the result of the GoString method of v, which must
+// implement fmt.GoStringer, to buf with any lines after the first indented by
+// the provided number of tabs.
+func writeGoStringer(buf *bytes.Buffer, v reflect.Value, indent int) {
+	str := v.Interface().(fmt.GoStringer).GoString()
+	tabs := strings.Repeat("\t", indent)
+	buf.WriteString(strings.Replace(str, "\n", "\n"+tabs, -1))
+}
+
+// writeNamedNumber writes the provided number to buf converted to the provided
+// type when it is one defined by this package, such as ServiceFlag, so it
+// keeps its type when used outside of a composite literal.
+func writeNamedNumber(buf *bytes.Buffer, t reflect.Type, num string) {
+	if t.PkgPath() == btcwirePkgPath {
+		fmt.Fprintf(buf, "%s(%s)", goTypeString(t), num)
+		return
+	}
+	buf.WriteString(num)
+}
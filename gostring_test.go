@@ -0,0 +1,77 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"fmt"
+	"github.com/conformal/btcwire"
+	"go/parser"
+	"testing"
+)
+
+// TestGoStringParses ensures the Go-syntax representation of every message is
+// a valid Go expression.
+func TestGoStringParses(t *testing.T) {
+	msgs := snapshotMessages()
+	t.Logf("Running %d tests", len(msgs))
+	for i, msg := range msgs {
+		str := fmt.Sprintf("%#v", msg)
+		if _, err := parser.ParseExpr(str); err != nil {
+			t.Errorf("GoString #%d (%s) is not valid Go: %v\n%s", i,
+				msg.Command(), err, str)
+		}
+	}
+}
+
+// TestGoString tests the exact Go-syntax representation of hashes, inventory
+// vectors, and a few messages.
+func TestGoString(t *testing.T) {
+	hashStr := "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"
+	hash := btcwire.MustNewShaHashFromStr(hashStr)
+
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{*hash, `*btcwire.MustNewShaHashFromStr("` + hashStr + `")`},
+		{btcwire.InvTypeTx, "btcwire.InvTypeTx"},
+		{btcwire.InvType(99), "btcwire.InvType(99)"},
+		{
+			btcwire.NewInvVect(btcwire.InvTypeBlock, hash),
+			`btcwire.NewInvVect(btcwire.InvTypeBlock, ` +
+				`btcwire.MustNewShaHashFromStr("` + hashStr + `"))`,
+		},
+		{btcwire.NewMsgVerAck(), "&btcwire.MsgVerAck{}"},
+		{
+			btcwire.NewMsgPing(10),
+			"&btcwire.MsgPing{\n\tNonce: 10,\n}",
+		},
+		{
+			btcwire.MustNewMsgUnknown("foo", []byte{0x01, 0x02}),
+			`btcwire.MustNewMsgUnknown("foo", []byte{0x01, 0x02})`,
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		got := fmt.Sprintf("%#v", test.in)
+		if got != test.want {
+			t.Errorf("GoString #%d\n got: %s\nwant: %s", i, got,
+				test.want)
+		}
+	}
+}
+
+// TestMustNewShaHashFromStrPanic ensures MustNewShaHashFromStr panics when
+// given an invalid hash.
+func TestMustNewShaHashFromStrPanic(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("MustNewShaHashFromStr did not panic on an " +
+				"invalid hash")
+		}
+	}()
+	btcwire.MustNewShaHashFromStr("invalid")
+}
@@ -0,0 +1,228 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Commands used in the message headers of the experimental Graphene block
+// relay messages.  These messages are not part of the bitcoin protocol and are
+// only recognized once RegisterGrapheneMessages has been called.
+const (
+	CmdGetGrapheneBlock   = "get_grblk"
+	CmdGrapheneBlock      = "grblk"
+	CmdGetGrapheneBlockTx = "get_grblktx"
+	CmdGrapheneBlockTx    = "grblktx"
+)
+
+// RegisterGrapheneMessages registers the experimental Graphene block relay
+// messages, which are MsgGetGrapheneBlock, MsgGrapheneBlock,
+// MsgGetGrapheneBlockTx, and MsgGrapheneBlockTx, so they are recognized when
+// they are read.  Until it is called, messages with their commands are treated
+// the same as any other unrecognized command.
+//
+// This is intended to be called during initialization by applications which
+// experiment with set reconciliation based block relay, such as for
+// benchmarking it against other relay protocols.
+func RegisterGrapheneMessages() {
+	registerMessage(CmdGetGrapheneBlock, func() Message {
+		return &MsgGetGrapheneBlock{}
+	})
+	registerMessage(CmdGrapheneBlock, func() Message {
+		return &MsgGrapheneBlock{}
+	})
+	registerMessage(CmdGetGrapheneBlockTx, func() Message {
+		return &MsgGetGrapheneBlockTx{}
+	})
+	registerMessage(CmdGrapheneBlockTx, func() Message {
+		return &MsgGrapheneBlockTx{}
+	})
+}
+
+// MaxIBLTValueSize is the maximum number of bytes the value sum of a cell of an
+// invertible bloom lookup table can be.
+const MaxIBLTValueSize = 64
+
+// minIBLTCellPayload is the minimum number of bytes a cell of an invertible
+// bloom lookup table takes, which is the count, key sum, key check, and a
+// single byte for the size of an empty value sum.
+const minIBLTCellPayload = 4 + 8 + 4 + 1
+
+// maxIBLTCells is the maximum number of cells an invertible bloom lookup table
+// can have while fitting in the max block payload.
+const maxIBLTCells = MaxBlockPayload / minIBLTCellPayload
+
+// IBLTCell is a single cell of an invertible bloom lookup table.
+type IBLTCell struct {
+	// Count is the number of keys inserted into the cell less the number
+	// removed.
+	Count int32
+
+	// KeySum is the XOR of all keys of the cell.
+	KeySum uint64
+
+	// KeyCheck is the XOR of a hash of all keys of the cell, which is used
+	// to detect when a cell with a count of one or negative one holds a
+	// single key.
+	KeyCheck uint32
+
+	// ValueSum is the XOR of all values of the cell.
+	ValueSum []byte
+}
+
+// IBLT is an invertible bloom lookup table as used by Graphene to reconcile
+// the transactions of a block with those the receiving peer has in its
+// mempool.  Only its wire representation is provided here and interpreting it
+// is left to the caller.
+type IBLT struct {
+	// HashFuncs is the number of hash functions used to map keys to cells.
+	HashFuncs uint8
+
+	// Salt is mixed into the hash functions of the table.
+	Salt uint32
+
+	// Cells are the cells of the table.
+	Cells []IBLTCell
+}
+
+// Equal returns whether the table has the same parameters and cells as other.
+// Nil and empty value sums are considered the same.  Two nil tables are
+// considered equal, while a nil table is not equal to any other.
+func (t *IBLT) Equal(other *IBLT) bool {
+	if t == nil || other == nil {
+		return t == other
+	}
+	if t.HashFuncs != other.HashFuncs || t.Salt != other.Salt ||
+		len(t.Cells) != len(other.Cells) {
+		return false
+	}
+	for i := range t.Cells {
+		a, b := &t.Cells[i], &other.Cells[i]
+		if a.Count != b.Count || a.KeySum != b.KeySum ||
+			a.KeyCheck != b.KeyCheck ||
+			!bytes.Equal(a.ValueSum, b.ValueSum) {
+			return false
+		}
+	}
+	return true
+}
+
+// readIBLT reads an invertible bloom lookup table from r into t.  The function
+// f is used to attribute any errors.
+func readIBLT(r io.Reader, pver uint32, f string, t *IBLT) error {
+	err := readElements(r, &t.HashFuncs, &t.Salt)
+	if err != nil {
+		return err
+	}
+
+	count, err := readVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	if count > maxIBLTCells {
+		str := fmt.Sprintf("too many IBLT cells for message "+
+			"[count %v, max %v]", count, maxIBLTCells)
+		return categorizedError(f, str, ErrCategoryOversized)
+	}
+	err = checkCountFits(r, f, "IBLT cells", count, minIBLTCellPayload)
+	if err != nil {
+		return err
+	}
+	err = reserveAlloc(r, f, "IBLT cells", count*ibltCellAllocSize)
+	if err != nil {
+		return err
+	}
+
+	t.Cells = make([]IBLTCell, count)
+	for i := range t.Cells {
+		cell := &t.Cells[i]
+		err := readElements(r, &cell.Count, &cell.KeySum, &cell.KeyCheck)
+		if err != nil {
+			return err
+		}
+		size, err := readVarInt(r, pver)
+		if err != nil {
+			return err
+		}
+		if size > MaxIBLTValueSize {
+			str := fmt.Sprintf("IBLT value sum too large for "+
+				"message [size %v, max %v]", size,
+				MaxIBLTValueSize)
+			return categorizedError(f, str, ErrCategoryOversized)
+		}
+		err = reserveAlloc(r, f, "IBLT value sums", size)
+		if err != nil {
+			return err
+		}
+		cell.ValueSum = make([]byte, size)
+		_, err = io.ReadFull(r, cell.ValueSum)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeIBLT writes the provided invertible bloom lookup table to w.
+func writeIBLT(w io.Writer, pver uint32, t *IBLT) error {
+	err := writeElements(w, t.HashFuncs, t.Salt)
+	if err != nil {
+		return err
+	}
+	err = writeVarInt(w, pver, uint64(len(t.Cells)))
+	if err != nil {
+		return err
+	}
+	for i := range t.Cells {
+		cell := &t.Cells[i]
+		err := writeElements(w, cell.Count, cell.KeySum, cell.KeyCheck)
+		if err != nil {
+			return err
+		}
+		err = writeVarInt(w, pver, uint64(len(cell.ValueSum)))
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(cell.ValueSum)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateIBLT returns an error attributed to the function f when the provided
+// invertible bloom lookup table exceeds the allowed limits.
+func validateIBLT(f string, t *IBLT) error {
+	if len(t.Cells) > maxIBLTCells {
+		str := fmt.Sprintf("too many IBLT cells for message "+
+			"[count %v, max %v]", len(t.Cells), maxIBLTCells)
+		return messageError(f, str)
+	}
+	for i := range t.Cells {
+		if len(t.Cells[i].ValueSum) > MaxIBLTValueSize {
+			str := fmt.Sprintf("IBLT value sum too large for "+
+				"message [size %v, max %v]",
+				len(t.Cells[i].ValueSum), MaxIBLTValueSize)
+			return messageError(f, str)
+		}
+	}
+	return nil
+}
+
+// ibltSerializeSize returns the number of bytes it would take to encode the
+// provided invertible bloom lookup table.
+func ibltSerializeSize(t *IBLT) int {
+	// Hash funcs 1 byte + salt 4 bytes + num cells (varInt).
+	n := 5 + varIntSerializeSize(uint64(len(t.Cells)))
+	for i := range t.Cells {
+		size := len(t.Cells[i].ValueSum)
+		n += 16 + varIntSerializeSize(uint64(size)) + size
+	}
+	return n
+}
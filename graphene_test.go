@@ -0,0 +1,68 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"reflect"
+	"testing"
+)
+
+// TestRegisterGrapheneMessages ensures the Graphene messages are only
+// recognized once they have been registered.
+func TestRegisterGrapheneMessages(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcnet := btcwire.MainNet
+
+	iv := btcwire.NewInvVect(btcwire.InvTypeBlock, &btcwire.GenesisHash)
+	grapheneBlock, _ := grapheneBlockOne()
+	grapheneBlockTx := btcwire.NewMsgGrapheneBlockTx(&btcwire.GenesisHash)
+	grapheneBlockTx.Txs = []*btcwire.MsgTx{multiTx}
+	getGrapheneBlockTx := btcwire.NewMsgGetGrapheneBlockTx(
+		&btcwire.GenesisHash)
+	getGrapheneBlockTx.AddTxHash(1)
+	tests := []btcwire.Message{
+		btcwire.NewMsgGetGrapheneBlock(iv, 5000),
+		grapheneBlock,
+		getGrapheneBlockTx,
+		grapheneBlockTx,
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		btcwire.TstUnregisterMessage(test.Command())
+
+		var buf bytes.Buffer
+		err := btcwire.WriteMessage(&buf, test, pver, btcnet)
+		if err != nil {
+			t.Errorf("WriteMessage #%d error %v", i, err)
+			continue
+		}
+		raw := buf.Bytes()
+
+		// The command is unrecognized before registration.
+		_, _, err = btcwire.ReadMessage(bytes.NewReader(raw), pver, btcnet)
+		merr, ok := err.(*btcwire.MessageError)
+		if !ok || merr.Category != btcwire.ErrCategoryUnknownCommand {
+			t.Errorf("ReadMessage #%d unregistered: wrong error got: %v",
+				i, err)
+			continue
+		}
+
+		btcwire.RegisterGrapheneMessages()
+		msg, _, err := btcwire.ReadMessage(bytes.NewReader(raw), pver,
+			btcnet)
+		if err != nil {
+			t.Errorf("ReadMessage #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(msg, test) {
+			t.Errorf("ReadMessage #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test))
+		}
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// defaultHandshakeTimeout is the default amount of time allowed for the
+// version handshake to complete.
+const defaultHandshakeTimeout = time.Second * 30
+
+// ErrSelfConnection describes an error that indicates the version handshake
+// failed because the remote peer sent a version message with a nonce which
+// was generated locally, meaning the connection is to ourselves.
+var ErrSelfConnection = errors.New("connection is to self")
+
+// HandshakeConfig houses the parameters used to build the local version
+// message and negotiate with the remote peer during the version handshake
+// performed by DialMessageConn, AcceptMessageConn, and HandshakeMessageConn.
+type HandshakeConfig struct {
+	// Net is the bitcoin network the messages are exchanged on.
+	Net BitcoinNet
+
+	// ProtocolVersion is the maximum protocol version advertised to the
+	// remote peer.  It defaults to ProtocolVersion when zero.
+	ProtocolVersion uint32
+
+	// MinProtocolVersion is the minimum protocol version the remote peer
+	// must advertise for the handshake to succeed.  There is no minimum
+	// when zero.
+	MinProtocolVersion uint32
+
+	// Services, UserAgent, LastBlock, and DisableRelayTx populate the
+	// respective fields of the local version message.
+	Services       ServiceFlag
+	UserAgent      string
+	LastBlock      int32
+	DisableRelayTx bool
+
+	// Nonces, when non-nil, is used to generate the nonce of the local
+	// version message and to detect connections to ourselves.  The nonce
+	// is removed from it once the handshake is complete.
+	Nonces *NonceRegistry
+
+	// Timeout is the maximum amount of time allowed for the connection to
+	// be established and the handshake to complete.  It defaults to 30
+	// seconds when zero.
+	Timeout time.Duration
+
+	// ConnConfig, when non-nil, configures the returned MessageConn.  See
+	// MessageConnConfig for details.  Its AddrMe hook also supplies the
+	// local address of the version message sent during the handshake.
+	ConnConfig *MessageConnConfig
+}
+
+// PeerCapabilities describes what was negotiated with a remote peer during the
+// version handshake.
+type PeerCapabilities struct {
+	// ProtocolVersion is the negotiated protocol version, which is the
+	// lower of the local and remote versions.  The returned MessageConn
+	// uses it to read and write messages.
+	ProtocolVersion uint32
+
+	// Services are the services advertised by the remote peer.
+	Services ServiceFlag
+
+	// RelayTx is whether the remote peer wants transactions announced
+	// before it loads a filter.
+	RelayTx bool
+
+	// Compression is whether both peers advertised SFNodeCompression, in
+	// which case the returned MessageConn exchanges compressed messages.
+	Compression bool
+
+	// Version is the version message sent by the remote peer.
+	Version *MsgVersion
+}
+
+// DialMessageConn connects to the address addr on the TCP network, performs the
+// version handshake as the outbound peer, and returns a started MessageConn
+// which uses the negotiated protocol version along with the negotiated
+// capabilities.  The connection is closed when the handshake fails.  See
+// HandshakeMessageConn for details of the handshake.
+func DialMessageConn(addr string, cfg *HandshakeConfig) (*MessageConn, *PeerCapabilities, error) {
+	timeout := defaultHandshakeTimeout
+	if cfg.Timeout > 0 {
+		timeout = cfg.Timeout
+	}
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	return HandshakeMessageConn(conn, false, cfg)
+}
+
+// AcceptMessageConn waits for the next connection on l, performs the version
+// handshake as the inbound peer, and returns a started MessageConn which uses
+// the negotiated protocol version along with the negotiated capabilities.  The
+// accepted connection is closed when the handshake fails.  See
+// HandshakeMessageConn for details of the handshake.
+func AcceptMessageConn(l net.Listener, cfg *HandshakeConfig) (*MessageConn, *PeerCapabilities, error) {
+	conn, err := l.Accept()
+	if err != nil {
+		return nil, nil, err
+	}
+	return HandshakeMessageConn(conn, true, cfg)
+}
+
+// HandshakeMessageConn performs the version handshake over an established
+// connection and returns a started MessageConn which uses the negotiated
+// protocol version along with the negotiated capabilities.  The inbound flag
+// specifies whether the remote peer initiated the connection.
+//
+// The outbound peer sends its version message first and the inbound peer
+// replies with its own once it has received it.  Each peer then acknowledges
+// the version of the other with a verack message.  Any other message received
+// before the handshake is complete is an error, as is a remote version below
+// MinProtocolVersion or, when a NonceRegistry is configured, a remote nonce
+// which was generated locally.  The connection is closed when the handshake
+// fails.
+//
+// Compression is enabled on the returned MessageConn when both peers advertise
+// SFNodeCompression in their services.
+func HandshakeMessageConn(conn net.Conn, inbound bool, cfg *HandshakeConfig) (*MessageConn, *PeerCapabilities, error) {
+	caps, err := handshake(conn, inbound, cfg)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	connCfg := cfg.ConnConfig
+	if caps.Compression {
+		var c MessageConnConfig
+		if connCfg != nil {
+			c = *connCfg
+		}
+		c.Compress = true
+		connCfg = &c
+	}
+	mc := NewMessageConn(conn, caps.ProtocolVersion, cfg.Net, connCfg)
+	mc.Start()
+	return mc, caps, nil
+}
+
+// handshake performs the version handshake described by HandshakeMessageConn
+// and returns the negotiated capabilities.
+func handshake(conn net.Conn, inbound bool, cfg *HandshakeConfig) (*PeerCapabilities, error) {
+	pver := cfg.ProtocolVersion
+	if pver == 0 {
+		pver = ProtocolVersion
+	}
+	timeout := defaultHandshakeTimeout
+	if cfg.Timeout > 0 {
+		timeout = cfg.Timeout
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	defer conn.SetDeadline(time.Time{})
+
+	localVer, err := newHandshakeVersion(conn, pver, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Nonces != nil {
+		defer cfg.Nonces.Remove(localVer.Nonce)
+	}
+
+	// The local address is supplied by the hook, if any, as the version is
+	// sent.
+	var addrMe AddrMeFunc
+	if cfg.ConnConfig != nil {
+		addrMe = cfg.ConnConfig.AddrMe
+	}
+
+	// The outbound peer sends its version first.
+	if !inbound {
+		msg := withAddrMe(addrMe, conn, localVer)
+		err := WriteMessage(conn, msg, pver, cfg.Net)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	msg, _, err := ReadMessage(conn, pver, cfg.Net)
+	if err != nil {
+		return nil, err
+	}
+	remoteVer, ok := msg.(*MsgVersion)
+	if !ok {
+		str := fmt.Sprintf("expected version message, received %s",
+			msg.Command())
+		return nil, messageError("handshake", str)
+	}
+	if cfg.Nonces != nil && cfg.Nonces.IsSelfConnection(remoteVer) {
+		return nil, ErrSelfConnection
+	}
+	if remoteVer.ProtocolVersion < 0 ||
+		uint32(remoteVer.ProtocolVersion) < cfg.MinProtocolVersion {
+
+		str := fmt.Sprintf("remote protocol version %d is below the "+
+			"minimum of %d", remoteVer.ProtocolVersion,
+			cfg.MinProtocolVersion)
+		return nil, messageError("handshake", str)
+	}
+
+	// The inbound peer replies with its version once it has received the
+	// version of the outbound peer.
+	if inbound {
+		msg := withAddrMe(addrMe, conn, localVer)
+		err := WriteMessage(conn, msg, pver, cfg.Net)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Negotiate down to the lower of the two versions and acknowledge the
+	// remote version.  The outbound peer sends its verack first so peers
+	// on unbuffered connections don't block on each other.
+	if uint32(remoteVer.ProtocolVersion) < pver {
+		pver = uint32(remoteVer.ProtocolVersion)
+	}
+	if !inbound {
+		err := WriteMessage(conn, NewMsgVerAck(), pver, cfg.Net)
+		if err != nil {
+			return nil, err
+		}
+	}
+	msg, _, err = ReadMessage(conn, pver, cfg.Net)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := msg.(*MsgVerAck); !ok {
+		str := fmt.Sprintf("expected verack message, received %s",
+			msg.Command())
+		return nil, messageError("handshake", str)
+	}
+	if inbound {
+		err := WriteMessage(conn, NewMsgVerAck(), pver, cfg.Net)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &PeerCapabilities{
+		ProtocolVersion: pver,
+		Services:        remoteVer.Services,
+		RelayTx:         !remoteVer.DisableRelayTx,
+		Compression: cfg.Services&SFNodeCompression != 0 &&
+			remoteVer.Services&SFNodeCompression != 0,
+		Version: remoteVer,
+	}, nil
+}
+
+// newHandshakeVersion returns the local version message for the handshake
+// over conn.  The addresses of the connection are only included when they are
+// TCP addresses.
+func newHandshakeVersion(conn net.Conn, pver uint32, cfg *HandshakeConfig) (*MsgVersion, error) {
+	var nonce uint64
+	var err error
+	if cfg.Nonces != nil {
+		nonce, err = cfg.Nonces.Generate()
+	} else {
+		nonce, err = RandomUint64()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var me, you NetAddress
+	if na, err := NewNetAddress(conn.LocalAddr(), 0); err == nil {
+		me = *na
+	}
+	if na, err := NewNetAddress(conn.RemoteAddr(), 0); err == nil {
+		you = *na
+	}
+
+	msg, err := NewMsgVersionWithOptions(&me, &you, nonce,
+		WithUserAgent(cfg.UserAgent), WithServices(cfg.Services),
+		WithRelay(!cfg.DisableRelayTx),
+		WithStartHeight(cfg.LastBlock))
+	if err != nil {
+		return nil, err
+	}
+	msg.ProtocolVersion = int32(pver)
+	msg.AddrMe.Services = cfg.Services
+	return msg, nil
+}
@@ -0,0 +1,215 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"github.com/conformal/btcwire"
+	"net"
+	"testing"
+	"time"
+)
+
+// handshakeResult houses the result of one side of a handshake run in the
+// background.
+type handshakeResult struct {
+	mc   *btcwire.MessageConn
+	caps *btcwire.PeerCapabilities
+	err  error
+}
+
+// handshakePipe performs the version handshake over both ends of a pipe with
+// the provided configurations and returns the results of the outbound and
+// inbound sides.
+func handshakePipe(outCfg, inCfg *btcwire.HandshakeConfig) (handshakeResult, handshakeResult) {
+	inConn, outConn := net.Pipe()
+	inChan := make(chan handshakeResult, 1)
+	go func() {
+		mc, caps, err := btcwire.HandshakeMessageConn(inConn, true, inCfg)
+		inChan <- handshakeResult{mc, caps, err}
+	}()
+	mc, caps, err := btcwire.HandshakeMessageConn(outConn, false, outCfg)
+	return handshakeResult{mc, caps, err}, <-inChan
+}
+
+// TestHandshake tests the version handshake between an outbound and inbound
+// peer negotiates the expected capabilities.
+func TestHandshake(t *testing.T) {
+	external := btcwire.NewNetAddressIPPort(net.ParseIP("203.0.113.1"),
+		8333, btcwire.SFNodeNetwork)
+	outCfg := &btcwire.HandshakeConfig{
+		Net:       btcwire.MainNet,
+		Services:  btcwire.SFNodeNetwork,
+		UserAgent: "/outbound:0.0.1/",
+		LastBlock: 234234,
+		Timeout:   time.Second * 5,
+		ConnConfig: &btcwire.MessageConnConfig{
+			AddrMe: func(net.Addr) *btcwire.NetAddress {
+				return external
+			},
+		},
+	}
+	inCfg := &btcwire.HandshakeConfig{
+		Net:             btcwire.MainNet,
+		ProtocolVersion: btcwire.BIP0035Version,
+		UserAgent:       "/inbound:0.0.1/",
+		DisableRelayTx:  true,
+		Timeout:         time.Second * 5,
+	}
+
+	out, in := handshakePipe(outCfg, inCfg)
+	if out.err != nil || in.err != nil {
+		t.Fatalf("HandshakeMessageConn: outbound error %v, inbound error "+
+			"%v", out.err, in.err)
+	}
+	defer out.mc.Close()
+	defer in.mc.Close()
+
+	// Both sides negotiate down to the lower version.  The relay flag is
+	// not sent at that version, so it defaults to relaying.
+	tests := []struct {
+		name     string
+		caps     *btcwire.PeerCapabilities
+		mc       *btcwire.MessageConn
+		services btcwire.ServiceFlag
+		ua       string
+	}{
+		{"outbound", out.caps, out.mc, 0, "/inbound:0.0.1/"},
+		{"inbound", in.caps, in.mc, btcwire.SFNodeNetwork,
+			"/outbound:0.0.1/"},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		if test.caps.ProtocolVersion != btcwire.BIP0035Version {
+			t.Errorf("#%d (%s) wrong protocol version got: %d want: %d",
+				i, test.name, test.caps.ProtocolVersion,
+				btcwire.BIP0035Version)
+		}
+		if pver := test.mc.ProtocolVersion(); pver != btcwire.BIP0035Version {
+			t.Errorf("#%d (%s) wrong connection protocol version "+
+				"got: %d want: %d", i, test.name, pver,
+				btcwire.BIP0035Version)
+		}
+		if test.caps.Services != test.services {
+			t.Errorf("#%d (%s) wrong services got: %v want: %v", i,
+				test.name, test.caps.Services, test.services)
+		}
+		if !test.caps.RelayTx {
+			t.Errorf("#%d (%s) relay not enabled", i, test.name)
+		}
+		if test.caps.Version.UserAgent != test.ua {
+			t.Errorf("#%d (%s) wrong user agent got: %q want: %q", i,
+				test.name, test.caps.Version.UserAgent, test.ua)
+		}
+	}
+
+	// Ensure the local address of the outbound version was supplied by the
+	// hook.
+	addrMe := in.caps.Version.AddrMe
+	if !addrMe.IP.Equal(external.IP) || addrMe.Port != external.Port {
+		t.Errorf("AddrMe: wrong local address got: %v:%d want: %v:%d",
+			addrMe.IP, addrMe.Port, external.IP, external.Port)
+	}
+
+	// Ensure the connections are ready for use.
+	go out.mc.Send(btcwire.NewMsgPing(1))
+	select {
+	case msg := <-in.mc.Inbound():
+		if _, ok := msg.(*btcwire.MsgPing); !ok {
+			t.Errorf("Inbound: wrong message got: %T", msg)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("Inbound: timeout waiting for message")
+	}
+}
+
+// TestHandshakeTCP tests DialMessageConn and AcceptMessageConn over a TCP
+// connection.
+func TestHandshakeTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Listen: %v", err)
+	}
+	defer l.Close()
+
+	cfg := &btcwire.HandshakeConfig{
+		Net:     btcwire.TestNet3,
+		Timeout: time.Second * 5,
+	}
+	inChan := make(chan handshakeResult, 1)
+	go func() {
+		mc, caps, err := btcwire.AcceptMessageConn(l, cfg)
+		inChan <- handshakeResult{mc, caps, err}
+	}()
+	mc, caps, err := btcwire.DialMessageConn(l.Addr().String(), cfg)
+	if err != nil {
+		t.Fatalf("DialMessageConn: %v", err)
+	}
+	defer mc.Close()
+	in := <-inChan
+	if in.err != nil {
+		t.Fatalf("AcceptMessageConn: %v", in.err)
+	}
+	defer in.mc.Close()
+
+	if caps.ProtocolVersion != btcwire.ProtocolVersion ||
+		in.caps.ProtocolVersion != btcwire.ProtocolVersion {
+		t.Errorf("wrong protocol versions got: %d and %d want: %d",
+			caps.ProtocolVersion, in.caps.ProtocolVersion,
+			btcwire.ProtocolVersion)
+	}
+
+	// Each side reports the address it connected from or to.
+	want := l.Addr().(*net.TCPAddr)
+	got := caps.Version.AddrMe
+	if !got.IP.Equal(want.IP) || got.Port != uint16(want.Port) {
+		t.Errorf("wrong remote address got: %v:%d want: %v", got.IP,
+			got.Port, want)
+	}
+}
+
+// TestHandshakeErrors ensures the handshake fails for connections to self,
+// remote versions which are too old, and unexpected messages.
+func TestHandshakeErrors(t *testing.T) {
+	// Peers sharing a nonce registry are the same node.
+	nonces := btcwire.NewNonceRegistry(0)
+	selfCfg := &btcwire.HandshakeConfig{
+		Net:     btcwire.MainNet,
+		Nonces:  nonces,
+		Timeout: time.Second * 5,
+	}
+	_, in := handshakePipe(selfCfg, selfCfg)
+	if in.err != btcwire.ErrSelfConnection {
+		t.Errorf("self connection: wrong error got: %v want: %v",
+			in.err, btcwire.ErrSelfConnection)
+	}
+
+	// The inbound peer requires a newer version than the outbound peer
+	// advertises.
+	oldCfg := &btcwire.HandshakeConfig{
+		Net:             btcwire.MainNet,
+		ProtocolVersion: btcwire.BIP0031Version,
+		Timeout:         time.Second * 5,
+	}
+	minCfg := &btcwire.HandshakeConfig{
+		Net:                btcwire.MainNet,
+		MinProtocolVersion: btcwire.BIP0035Version,
+		Timeout:            time.Second * 5,
+	}
+	_, in = handshakePipe(oldCfg, minCfg)
+	if _, ok := in.err.(*btcwire.MessageError); !ok {
+		t.Errorf("old version: wrong error got: %T(%v)", in.err, in.err)
+	}
+
+	// The remote peer sends a message other than version first.
+	inConn, outConn := net.Pipe()
+	go btcwire.WriteMessage(outConn, btcwire.NewMsgPing(1),
+		btcwire.ProtocolVersion, btcwire.MainNet)
+	_, _, err := btcwire.HandshakeMessageConn(inConn, true, minCfg)
+	if _, ok := err.(*btcwire.MessageError); !ok {
+		t.Errorf("unexpected message: wrong error got: %T(%v)", err, err)
+	}
+	outConn.Close()
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"github.com/conformal/fastsha256"
+	"hash"
+)
+
+// HashWriter is an io.Writer which computes the double sha256 of everything
+// written to it.  It allows the hash of a serialized structure, such as a
+// transaction or block header, to be computed by serializing directly into
+// the hasher without first serializing into a buffer.
+//
+// For example, the hash of a transaction may be computed with:
+//
+//	hw := btcwire.NewHashWriter()
+//	err := tx.Serialize(hw)
+//	sha := hw.Sum()
+type HashWriter struct {
+	hasher hash.Hash
+}
+
+// NewHashWriter returns a new HashWriter which has not had any data written to
+// it.
+func NewHashWriter() *HashWriter {
+	return &HashWriter{hasher: fastsha256.New()}
+}
+
+// Write adds p to the data being hashed.  It never returns an error.  This is
+// part of the io.Writer interface implementation.
+func (hw *HashWriter) Write(p []byte) (int, error) {
+	return hw.hasher.Write(p)
+}
+
+// Sum returns the double sha256 of the data written so far.  It does not
+// change the underlying state, so more data may be written afterwards.
+func (hw *HashWriter) Sum() ShaHash {
+	var first, sha ShaHash
+	hw.hasher.Sum(first[:0])
+
+	hasher := fastsha256.New()
+	hasher.Write(first[:])
+	hasher.Sum(sha[:0])
+	return sha
+}
+
+// Reset discards all of the data written so far.
+func (hw *HashWriter) Reset() {
+	hw.hasher.Reset()
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"testing"
+)
+
+// TestHashWriter ensures HashWriter computes the same double sha256 as
+// DoubleSha256 regardless of how the data is split across writes.
+func TestHashWriter(t *testing.T) {
+	var serialized bytes.Buffer
+	err := multiTx.Serialize(&serialized)
+	if err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	data := serialized.Bytes()
+
+	tests := []struct {
+		name  string
+		data  []byte
+		split int // Number of bytes per write
+	}{
+		{"empty", nil, 1},
+		{"single write", data, len(data)},
+		{"byte at a time", data, 1},
+		{"uneven writes", data, 7},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		var want btcwire.ShaHash
+		copy(want[:], btcwire.DoubleSha256(test.data))
+
+		hw := btcwire.NewHashWriter()
+		for b := test.data; len(b) > 0; {
+			n := test.split
+			if n > len(b) {
+				n = len(b)
+			}
+			_, err := hw.Write(b[:n])
+			if err != nil {
+				t.Fatalf("Write #%d (%s) error %v", i, test.name, err)
+			}
+			b = b[n:]
+		}
+
+		// Sum must not change the state, so calling it twice must give
+		// the same result.
+		for j := 0; j < 2; j++ {
+			got := hw.Sum()
+			if !got.IsEqual(&want) {
+				t.Errorf("Sum #%d (%s) call %d\n got: %v want: %v",
+					i, test.name, j, got, want)
+			}
+		}
+
+		// Reset must discard the data written so far.
+		var empty btcwire.ShaHash
+		copy(empty[:], btcwire.DoubleSha256(nil))
+		hw.Reset()
+		if got := hw.Sum(); !got.IsEqual(&empty) {
+			t.Errorf("Reset #%d (%s)\n got: %v want: %v", i,
+				test.name, got, empty)
+		}
+	}
+}
+
+// TestHashWriterTxSha ensures the hash of a transaction serialized into a
+// HashWriter matches its TxSha.
+func TestHashWriterTxSha(t *testing.T) {
+	want, err := multiTx.TxSha()
+	if err != nil {
+		t.Fatalf("TxSha: %v", err)
+	}
+
+	hw := btcwire.NewHashWriter()
+	err = multiTx.Serialize(hw)
+	if err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	if got := hw.Sum(); !got.IsEqual(&want) {
+		t.Errorf("Sum\n got: %v want: %v", got, want)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// medianTimeBlocks is the number of previous headers whose median timestamp a
+// header must follow.
+const medianTimeBlocks = 11
+
+// hashToBig converts a hash to the big integer it represents, which is the
+// hash interpreted as a big-endian number in display order.
+func hashToBig(hash *ShaHash) *big.Int {
+	// The hash is in wire order, which is little endian, so reverse it.
+	buf := *hash
+	for i := 0; i < HashSize/2; i++ {
+		buf[i], buf[HashSize-1-i] = buf[HashSize-1-i], buf[i]
+	}
+	return new(big.Int).SetBytes(buf[:])
+}
+
+// medianTime returns the median of the provided timestamps, which are sorted
+// in place.  For an even number of timestamps the later of the middle two is
+// used, the same as the reference implementation.
+func medianTime(timestamps []time.Time) time.Time {
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i].Before(timestamps[j])
+	})
+	return timestamps[len(timestamps)/2]
+}
+
+// checkHeaderPow returns an error describing why the block header with the
+// provided index does not satisfy the proof-of-work requirement of the
+// network with the provided proof-of-work limit.
+func checkHeaderPow(h *BlockHeader, i int, btcnet BitcoinNet, powLimit *big.Int) error {
+	target := CompactToBig(h.Bits)
+	if target.Sign() <= 0 || target.Cmp(powLimit) > 0 {
+		str := fmt.Sprintf("header %d has a target outside of the "+
+			"valid range [bits %#08x]", i, h.Bits)
+		return messageError("VerifyHeaderChain", str)
+	}
+
+	// The proof-of-work of merged-mined blocks is provided by the parent
+	// block in the auxiliary proof-of-work.
+	powHeader := h
+	if h.AuxPow != nil {
+		powHeader = &h.AuxPow.ParentBlock
+	}
+	hash, err := powHeader.PowHash(btcnet)
+	if err != nil {
+		return err
+	}
+	if hashToBig(&hash).Cmp(target) > 0 {
+		str := fmt.Sprintf("header %d has a proof-of-work hash higher "+
+			"than its target [hash %v, bits %#08x]", i, hash,
+			h.Bits)
+		return messageError("VerifyHeaderChain", str)
+	}
+	return nil
+}
+
+// VerifyHeaderChain checks the provided sequence of block headers, such as the
+// headers of a headers message (MsgHeaders), for the rules which can be
+// checked without the rest of the chain.  It returns the index of the first
+// header which violates one of them along with an error describing the
+// violation, or -1 and nil when every header is valid.  This is the core loop
+// of syncing headers first.
+//
+// Every header must:
+//   - have a PrevBlock which is the hash of the header before it, apart from
+//     the first header, which is not checked
+//   - have a positive target which does not exceed the proof-of-work limit of
+//     the network and a proof-of-work hash, as returned by PowHash, which does
+//     not exceed its target
+//   - have a timestamp after the median timestamp of up to 11 headers before
+//     it in the sequence
+//
+// The targets are not checked against the difficulty retargeting rules, which
+// need the headers of the whole retarget period.  For merged-mined headers the
+// proof-of-work hash of the parent block is checked against the target, but
+// the commitment to the header in the parent block is not verified.
+//
+// An error is returned with an index of 0 for the networks PowLimit does not
+// know.
+func VerifyHeaderChain(headers []*BlockHeader, btcnet BitcoinNet) (int, error) {
+	powLimit, err := PowLimit(btcnet)
+	if err != nil {
+		return 0, err
+	}
+
+	timestamps := make([]time.Time, 0, medianTimeBlocks)
+	for i, h := range headers {
+		if i > 0 {
+			prevHash, err := headers[i-1].BlockSha()
+			if err != nil {
+				return i, err
+			}
+			if h.PrevBlock != prevHash {
+				str := fmt.Sprintf("header %d does not connect "+
+					"to the header before it [prev block %v, "+
+					"want %v]", i, h.PrevBlock, prevHash)
+				return i, messageError("VerifyHeaderChain", str)
+			}
+		}
+
+		err := checkHeaderPow(h, i, btcnet, powLimit)
+		if err != nil {
+			return i, err
+		}
+
+		// Use the timestamps of up to the last 11 headers, copied so
+		// sorting them does not change their order.
+		start := 0
+		if i > medianTimeBlocks {
+			start = i - medianTimeBlocks
+		}
+		timestamps = timestamps[:0]
+		for _, prev := range headers[start:i] {
+			timestamps = append(timestamps, prev.Timestamp)
+		}
+		if len(timestamps) > 0 {
+			median := medianTime(timestamps)
+			if !h.Timestamp.After(median) {
+				str := fmt.Sprintf("header %d has a timestamp "+
+					"which is not after the median of the "+
+					"previous headers [timestamp %v, "+
+					"median %v]", i, h.Timestamp, median)
+				return i, messageError("VerifyHeaderChain", str)
+			}
+		}
+	}
+	return -1, nil
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"github.com/conformal/btcwire"
+	"testing"
+	"time"
+)
+
+// mineHeaders returns a chain of n regression test network headers extending
+// its genesis block, spaced ten minutes apart.  The modify function, when not
+// nil, is called to change each header before its nonce is chosen.
+func mineHeaders(t *testing.T, n int, modify func(i int, h *btcwire.BlockHeader)) []*btcwire.BlockHeader {
+	genesis := &btcwire.TestNetGenesisBlock.Header
+	prevHash := btcwire.TestNetGenesisHash
+	headers := make([]*btcwire.BlockHeader, 0, n)
+	for i := 0; i < n; i++ {
+		h := &btcwire.BlockHeader{
+			Version:    1,
+			PrevBlock:  prevHash,
+			MerkleRoot: genesis.MerkleRoot,
+			Timestamp: genesis.Timestamp.Add(time.Duration(i+1) *
+				time.Minute * 10),
+			Bits: genesis.Bits,
+		}
+		if modify != nil {
+			modify(i, h)
+		}
+
+		// Roughly half of all hashes satisfy the target of the
+		// proof-of-work limit of the regression test network.
+		single := []*btcwire.BlockHeader{h}
+		for {
+			idx, _ := btcwire.VerifyHeaderChain(single,
+				btcwire.TestNet)
+			if idx == -1 {
+				break
+			}
+			h.Nonce++
+		}
+
+		hash, err := h.BlockSha()
+		if err != nil {
+			t.Fatalf("BlockSha: unexpected error %v", err)
+		}
+		prevHash = hash
+		headers = append(headers, h)
+	}
+	return headers
+}
+
+// TestVerifyHeaderChain ensures the first header of a sequence which does not
+// connect, lacks proof-of-work, or has an early timestamp is identified.
+func TestVerifyHeaderChain(t *testing.T) {
+	genesis := &btcwire.GenesisBlock.Header
+	one := &blockOne.Header
+
+	// Headers whose hashes exceed their target.
+	badPow := mineHeaders(t, 3, nil)
+	badPow[2].Bits = 0x1d00ffff
+
+	// Headers whose link is broken by a modified header.
+	badLink := mineHeaders(t, 6, nil)
+	badLink[3].Nonce++
+
+	// Headers with timestamps which go back in time while staying after
+	// the median of the last 11 headers, and headers whose last timestamp
+	// goes back too far.
+	backInTime := mineHeaders(t, 14, func(i int, h *btcwire.BlockHeader) {
+		if i >= 12 {
+			h.Timestamp = h.Timestamp.Add(-time.Minute * 50)
+		}
+	})
+	tooFarBack := mineHeaders(t, 14, func(i int, h *btcwire.BlockHeader) {
+		if i == 13 {
+			h.Timestamp = h.Timestamp.Add(-time.Minute * 70)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		headers []*btcwire.BlockHeader // Headers to verify
+		btcnet  btcwire.BitcoinNet     // Network of the headers
+		want    int                    // Expected index of violation
+	}{
+		{"no headers", nil, btcwire.MainNet, -1},
+		{"main network", []*btcwire.BlockHeader{genesis, one},
+			btcwire.MainNet, -1},
+		{"not connected", []*btcwire.BlockHeader{one, genesis},
+			btcwire.MainNet, 1},
+		{"mined chain", mineHeaders(t, 20, nil), btcwire.TestNet, -1},
+		{"hash above target", badPow, btcwire.TestNet, 2},
+		{"broken link", badLink, btcwire.TestNet, 4},
+		{"back in time", backInTime, btcwire.TestNet, -1},
+		{"too far back", tooFarBack, btcwire.TestNet, 13},
+		{"target above limit", backInTime, btcwire.MainNet, 0},
+		{"unknown network", []*btcwire.BlockHeader{genesis}, 0, 0},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for _, test := range tests {
+		got, err := btcwire.VerifyHeaderChain(test.headers, test.btcnet)
+		if got != test.want {
+			t.Errorf("%s: got index %d (%v), want %d", test.name, got,
+				err, test.want)
+			continue
+		}
+		if (err == nil) != (test.want == -1) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if err != nil {
+			if _, ok := err.(*btcwire.MessageError); !ok {
+				t.Errorf("%s: wrong error type %T", test.name,
+					err)
+			}
+		}
+	}
+}
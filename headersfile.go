@@ -0,0 +1,157 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// checkHeaderContinuity returns an error attributed to the function f when the
+// provided header, which is at the provided index in a file, does not build on
+// the block with the provided hash.  Nothing is checked when prevHash is nil.
+func checkHeaderContinuity(f string, bh *BlockHeader, index int64, prevHash *ShaHash) error {
+	if prevHash == nil || bh.PrevBlock == *prevHash {
+		return nil
+	}
+	str := fmt.Sprintf("header %d does not connect to the previous header "+
+		"- it references %v, but the previous header is %v", index,
+		bh.PrevBlock, prevHash)
+	return categorizedError(f, str, ErrCategoryMalformed)
+}
+
+// HeadersFileReader reads a headers file, which is a flat concatenation of
+// serialized 80 byte block headers without transaction counts or any other
+// framing, such as those shipped with light clients to bootstrap their header
+// chain.  Each header must build on the one before it.
+type HeadersFileReader struct {
+	r        *bufio.Reader
+	buf      [blockHashLen]byte
+	prevHash *ShaHash
+	count    int64
+}
+
+// NewHeadersFileReader returns a new HeadersFileReader which reads headers from
+// r.  When prevHash is non-nil, the first header must build on the block with
+// that hash, such as the genesis block when the file starts at height one.
+func NewHeadersFileReader(r io.Reader, prevHash *ShaHash) *HeadersFileReader {
+	hr := &HeadersFileReader{r: bufio.NewReader(r)}
+	if prevHash != nil {
+		hash := *prevHash
+		hr.prevHash = &hash
+	}
+	return hr
+}
+
+// Count returns the number of headers read so far.
+func (r *HeadersFileReader) Count() int64 {
+	return r.count
+}
+
+// Next returns the next header in the file.  io.EOF is returned once there are
+// no more headers, while io.ErrUnexpectedEOF is returned when the file ends
+// part way through a header.  A MessageError is returned when the header does
+// not build on the previous one, in which case the reader should not be used
+// further.
+func (r *HeadersFileReader) Next() (*BlockHeader, error) {
+	_, err := io.ReadFull(r.r, r.buf[:])
+	if err != nil {
+		return nil, err
+	}
+
+	var bh BlockHeader
+	err = readBlockHeaderFields(bytes.NewReader(r.buf[:]), &bh)
+	if err != nil {
+		return nil, err
+	}
+	err = checkHeaderContinuity("HeadersFileReader.Next", &bh, r.count,
+		r.prevHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hash the raw header rather than serializing it again.
+	var hash ShaHash
+	copy(hash[:], DoubleSha256(r.buf[:]))
+	r.prevHash = &hash
+	r.count++
+	return &bh, nil
+}
+
+// ReadHeadersFile reads all of the headers from r in the same manner as
+// HeadersFileReader and returns them in order.  See NewHeadersFileReader for
+// details of prevHash.
+func ReadHeadersFile(r io.Reader, prevHash *ShaHash) ([]BlockHeader, error) {
+	hr := NewHeadersFileReader(r, prevHash)
+	var headers []BlockHeader
+	for {
+		bh, err := hr.Next()
+		if err == io.EOF {
+			return headers, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		headers = append(headers, *bh)
+	}
+}
+
+// HeadersFileWriter writes a headers file as read by HeadersFileReader.  Each
+// header written must build on the one before it.
+type HeadersFileWriter struct {
+	w        io.Writer
+	prevHash *ShaHash
+	count    int64
+}
+
+// NewHeadersFileWriter returns a new HeadersFileWriter which writes headers to
+// w.  When prevHash is non-nil, the first header must build on the block with
+// that hash.
+func NewHeadersFileWriter(w io.Writer, prevHash *ShaHash) *HeadersFileWriter {
+	hw := &HeadersFileWriter{w: w}
+	if prevHash != nil {
+		hash := *prevHash
+		hw.prevHash = &hash
+	}
+	return hw
+}
+
+// Count returns the number of headers written so far.
+func (w *HeadersFileWriter) Count() int64 {
+	return w.count
+}
+
+// WriteHeader appends the provided header to the file.  A MessageError is
+// returned, and nothing is written, when the header does not build on the
+// previous one or has an auxiliary proof-of-work, which the format can't
+// represent.
+func (w *HeadersFileWriter) WriteHeader(bh *BlockHeader) error {
+	if bh.AuxPow != nil {
+		return messageError("HeadersFileWriter.WriteHeader", "headers "+
+			"with an auxiliary proof-of-work can't be written to a "+
+			"headers file")
+	}
+	err := checkHeaderContinuity("HeadersFileWriter.WriteHeader", bh,
+		w.count, w.prevHash)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(blockHashLen)
+	writeBlockHeaderFields(&buf, bh)
+	_, err = w.w.Write(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	var hash ShaHash
+	copy(hash[:], DoubleSha256(buf.Bytes()))
+	w.prevHash = &hash
+	w.count++
+	return nil
+}
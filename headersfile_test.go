@@ -0,0 +1,160 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// headersFileChain returns a chain of block headers starting with the genesis
+// block followed by block one and a made up third block.
+func headersFileChain() []btcwire.BlockHeader {
+	genesis := btcwire.GenesisBlock.Header
+	one := blockOne.Header
+	one.TxnCount = 0
+	oneHash, _ := one.BlockSha()
+	two := btcwire.BlockHeader{
+		Version:    1,
+		PrevBlock:  oneHash,
+		MerkleRoot: btcwire.GenesisMerkleRoot,
+		Timestamp:  time.Unix(0x4966bc61, 0),
+		Bits:       0x1d00ffff,
+		Nonce:      1,
+	}
+	genesis.TxnCount = 0
+	return []btcwire.BlockHeader{genesis, one, two}
+}
+
+// TestHeadersFile tests writing a headers file and reading it back.
+func TestHeadersFile(t *testing.T) {
+	chain := headersFileChain()
+
+	var buf bytes.Buffer
+	w := btcwire.NewHeadersFileWriter(&buf, &btcwire.ShaHash{})
+	for i := range chain {
+		err := w.WriteHeader(&chain[i])
+		if err != nil {
+			t.Fatalf("WriteHeader #%d error %v", i, err)
+		}
+	}
+	if w.Count() != int64(len(chain)) {
+		t.Errorf("Count: wrong writer count got: %d want: %d",
+			w.Count(), len(chain))
+	}
+	if buf.Len() != 80*len(chain) {
+		t.Errorf("WriteHeader: wrong file size got: %d want: %d",
+			buf.Len(), 80*len(chain))
+	}
+
+	// The first header of the file is the genesis block, so the file
+	// starts with its serialization.
+	if !bytes.Equal(buf.Bytes()[:80], genesisBlockBytes[:80]) {
+		t.Errorf("WriteHeader: wrong genesis header\n got: %x want: %x",
+			buf.Bytes()[:80], genesisBlockBytes[:80])
+	}
+
+	file := buf.Bytes()
+	r := btcwire.NewHeadersFileReader(bytes.NewReader(file), nil)
+	t.Logf("Running %d tests", len(chain))
+	for i := range chain {
+		bh, err := r.Next()
+		if err != nil {
+			t.Fatalf("Next #%d error %v", i, err)
+		}
+		if !reflect.DeepEqual(bh, &chain[i]) {
+			t.Errorf("Next #%d\n got: %s want: %s", i,
+				spew.Sdump(bh), spew.Sdump(&chain[i]))
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Next: wrong error at end got: %v want: %v", err,
+			io.EOF)
+	}
+	if r.Count() != int64(len(chain)) {
+		t.Errorf("Count: wrong reader count got: %d want: %d",
+			r.Count(), len(chain))
+	}
+
+	// Files may start part way through the chain.
+	genesisHash := btcwire.GenesisHash
+	headers, err := btcwire.ReadHeadersFile(bytes.NewReader(file[80:]),
+		&genesisHash)
+	if err != nil {
+		t.Fatalf("ReadHeadersFile: %v", err)
+	}
+	if !reflect.DeepEqual(headers, chain[1:]) {
+		t.Errorf("ReadHeadersFile\n got: %s want: %s",
+			spew.Sdump(headers), spew.Sdump(chain[1:]))
+	}
+}
+
+// TestHeadersFileErrors performs negative tests against reading and writing
+// headers files to confirm error paths work correctly.
+func TestHeadersFileErrors(t *testing.T) {
+	chain := headersFileChain()
+	var buf bytes.Buffer
+	w := btcwire.NewHeadersFileWriter(&buf, nil)
+	for i := range chain {
+		w.WriteHeader(&chain[i])
+	}
+	file := buf.Bytes()
+
+	// Swap the last two headers so the chain is broken.
+	broken := append([]byte{}, file[:80]...)
+	broken = append(broken, file[160:]...)
+	broken = append(broken, file[80:160]...)
+
+	genesisHash := btcwire.GenesisHash
+	tests := []struct {
+		name     string
+		file     []byte
+		prevHash *btcwire.ShaHash
+		err      error // Expected error when not a MessageError
+	}{
+		{"truncated", file[:200], nil, io.ErrUnexpectedEOF},
+		{"broken chain", broken, nil, nil},
+		{"wrong start", file, &genesisHash, nil},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		_, err := btcwire.ReadHeadersFile(bytes.NewReader(test.file),
+			test.prevHash)
+		if test.err != nil {
+			if err != test.err {
+				t.Errorf("ReadHeadersFile #%d (%s) wrong error got: "+
+					"%v want: %v", i, test.name, err, test.err)
+			}
+			continue
+		}
+		if _, ok := err.(*btcwire.MessageError); !ok {
+			t.Errorf("ReadHeadersFile #%d (%s) wrong error got: %v", i,
+				test.name, err)
+		}
+	}
+
+	// Headers which don't connect or have an auxiliary proof-of-work are
+	// not written.
+	buf.Reset()
+	w = btcwire.NewHeadersFileWriter(&buf, &genesisHash)
+	if err := w.WriteHeader(&chain[2]); err == nil {
+		t.Errorf("WriteHeader: disconnected header did not fail")
+	}
+	auxHeader := chain[1]
+	auxHeader.AuxPow = &btcwire.AuxPow{}
+	if err := w.WriteHeader(&auxHeader); err == nil {
+		t.Errorf("WriteHeader: header with auxpow did not fail")
+	}
+	if buf.Len() != 0 || w.Count() != 0 {
+		t.Errorf("WriteHeader: wrote %d bytes for failed headers",
+			buf.Len())
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"fmt"
+	"io"
+)
+
+// HeadersScanner decodes the block headers of a headers message (MsgHeaders)
+// payload one header at a time.  This lets sync code validate each header and
+// discard it without materializing the entire list of up to
+// MaxBlockHeadersPerMsg headers, and, since the same header is reused, without
+// allocating anything per header.
+type HeadersScanner struct {
+	r      io.Reader
+	buf    []byte
+	header BlockHeader
+	count  uint64
+	index  uint64
+}
+
+// NewHeadersScanner returns a new HeadersScanner which decodes the headers of
+// the headers message payload in r, which must be in the same format BtcDecode
+// reads.  The number of headers is read immediately and an error is returned
+// when it is more than MaxBlockHeadersPerMsg.
+func NewHeadersScanner(r io.Reader, pver uint32) (*HeadersScanner, error) {
+	count, err := readVarInt(r, pver)
+	if err != nil {
+		return nil, err
+	}
+	if count > MaxBlockHeadersPerMsg {
+		str := fmt.Sprintf("too many block headers for message "+
+			"[count %v, max %v]", count, MaxBlockHeadersPerMsg)
+		return nil, categorizedError("NewHeadersScanner", str,
+			ErrCategoryOversized)
+	}
+	err = checkCountFits(r, "NewHeadersScanner", "block headers", count,
+		headerEntryLen)
+	if err != nil {
+		return nil, err
+	}
+	return &HeadersScanner{
+		r:     r,
+		buf:   make([]byte, headerEntryLen),
+		count: count,
+	}, nil
+}
+
+// Count returns the number of headers in the message.
+func (s *HeadersScanner) Count() int {
+	return int(s.count)
+}
+
+// Next decodes the next header of the message.  The returned header is owned
+// by the scanner and is overwritten by the next call, so it must be copied to
+// be retained.  io.EOF is returned once all of the headers have been decoded,
+// while io.ErrUnexpectedEOF is returned when the payload ends part way through
+// a header.  The scanner should not be used further after any other error.
+func (s *HeadersScanner) Next() (*BlockHeader, error) {
+	if s.index >= s.count {
+		return nil, io.EOF
+	}
+
+	_, err := io.ReadFull(s.r, s.buf)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	err = decodeHeaderEntry("HeadersScanner.Next", s.buf, &s.header)
+	if err != nil {
+		return nil, err
+	}
+	s.index++
+	return &s.header, nil
+}
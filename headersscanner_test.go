@@ -0,0 +1,139 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// scannerHeaders returns a headers message with the provided number of block
+// headers, all of which survive being encoded and decoded.
+func scannerHeaders(count int) *btcwire.MsgHeaders {
+	msg := btcwire.NewMsgHeaders()
+	prevHash := btcwire.GenesisHash
+	for i := 0; i < count; i++ {
+		bh := btcwire.NewBlockHeader(&prevHash,
+			&blockOne.Header.MerkleRoot, blockOne.Header.Bits,
+			uint32(i))
+		bh.Timestamp = blockOne.Header.Timestamp
+		msg.AddBlockHeader(bh)
+		prevHash, _ = bh.BlockSha()
+	}
+	return msg
+}
+
+// TestHeadersScanner tests decoding the headers of headers messages one at a
+// time.
+func TestHeadersScanner(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+
+	tests := []int{0, 1, 3, btcwire.MaxBlockHeadersPerMsg}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, count := range tests {
+		msg := scannerHeaders(count)
+		var buf bytes.Buffer
+		if err := msg.BtcEncode(&buf, pver); err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+
+		s, err := btcwire.NewHeadersScanner(&buf, pver)
+		if err != nil {
+			t.Errorf("NewHeadersScanner #%d error %v", i, err)
+			continue
+		}
+		if s.Count() != count {
+			t.Errorf("Count #%d got: %d, want: %d", i, s.Count(),
+				count)
+			continue
+		}
+		for j, want := range msg.Headers {
+			bh, err := s.Next()
+			if err != nil {
+				t.Errorf("Next #%d header %d error %v", i, j, err)
+				break
+			}
+			if !reflect.DeepEqual(bh, want) {
+				t.Errorf("Next #%d header %d\n got: %s want: %s",
+					i, j, spew.Sdump(bh), spew.Sdump(want))
+				break
+			}
+		}
+		if _, err := s.Next(); err != io.EOF {
+			t.Errorf("Next #%d: got %v after last header, want %v", i,
+				err, io.EOF)
+		}
+	}
+}
+
+// TestHeadersScannerErrors performs negative tests against scanning the
+// headers of headers messages.
+func TestHeadersScannerErrors(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+
+	msg := scannerHeaders(2)
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver); err != nil {
+		t.Fatalf("BtcEncode: %v", err)
+	}
+	encoded := buf.Bytes()
+
+	// Intentionally invalid second header with a transaction count.
+	withTxns := make([]byte, len(encoded))
+	copy(withTxns, encoded)
+	withTxns[len(withTxns)-1] = 0x01
+
+	tests := []struct {
+		buf     []byte // Headers message payload
+		stream  bool   // Hide the remaining length of the payload
+		newErr  error  // Expected error from NewHeadersScanner
+		nextErr error  // Expected error from scanning the headers
+	}{
+		// Missing header count.
+		{[]byte{}, false, io.EOF, nil},
+		// More headers than allowed per message.
+		{[]byte{0xfd, 0xd1, 0x07}, false, &btcwire.MessageError{}, nil},
+		// More headers than fit in the remaining payload.
+		{encoded[:100], false, &btcwire.MessageError{}, nil},
+		// Truncated second header of a stream.
+		{encoded[:100], true, nil, io.ErrUnexpectedEOF},
+		// Second header with transactions.
+		{withTxns, false, nil, &btcwire.MessageError{}},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		var r io.Reader = bytes.NewReader(test.buf)
+		if test.stream {
+			r = io.MultiReader(r)
+		}
+		s, err := btcwire.NewHeadersScanner(r, pver)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.newErr) {
+			t.Errorf("NewHeadersScanner #%d wrong error got: %v, "+
+				"want: %v", i, err, test.newErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		for err == nil {
+			_, err = s.Next()
+		}
+		if err == io.EOF {
+			err = nil
+		}
+		if reflect.TypeOf(err) != reflect.TypeOf(test.nextErr) {
+			t.Errorf("Next #%d wrong error got: %v, want: %v", i,
+				err, test.nextErr)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"bytes"
+	"time"
+)
+
+// Offsets of the fields which are modified while mining within the serialized
+// block header.
+const (
+	headerTimestampOffset = 68
+	headerNonceOffset     = 76
+)
+
+// HeaderWork houses a block header along with its serialized form for use in
+// getwork-style mining loops.  The header is serialized once when the
+// HeaderWork is created, and changes to the nonce and timestamp are written
+// directly into the serialized bytes so repeatedly hashing the header does not
+// require re-serializing it.
+type HeaderWork struct {
+	header     BlockHeader
+	serialized [blockHashLen]byte
+}
+
+// NewHeaderWork returns a new HeaderWork for a copy of the provided header.
+func NewHeaderWork(bh *BlockHeader) *HeaderWork {
+	hw := HeaderWork{header: *bh}
+
+	// Ignore the error return since there is no way the encode could fail
+	// except being out of memory which would cause a run-time panic.
+	var buf bytes.Buffer
+	_ = writeBlockHeader(&buf, 0, bh)
+	copy(hw.serialized[:], buf.Bytes())
+
+	// Normalize the timestamp to the precision which is encoded on the
+	// wire so Header reflects what was actually hashed.
+	hw.header.Timestamp = time.Unix(bh.Timestamp.Unix(), 0)
+
+	return &hw
+}
+
+// Header returns a copy of the block header reflecting all changes made to the
+// nonce and timestamp.
+func (hw *HeaderWork) Header() BlockHeader {
+	return hw.header
+}
+
+// Nonce returns the current nonce of the header.
+func (hw *HeaderWork) Nonce() uint32 {
+	return hw.header.Nonce
+}
+
+// SetNonce sets the nonce of the header.
+func (hw *HeaderWork) SetNonce(nonce uint32) {
+	hw.header.Nonce = nonce
+	littleEndian.PutUint32(hw.serialized[headerNonceOffset:], nonce)
+}
+
+// IncrementNonce increments the nonce of the header by one.  It returns true
+// when the nonce wrapped around to zero, which indicates the entire nonce space
+// has been exhausted and the timestamp or some other part of the header, such
+// as the extra nonce in the coinbase transaction, must be changed.
+func (hw *HeaderWork) IncrementNonce() bool {
+	hw.SetNonce(hw.header.Nonce + 1)
+	return hw.header.Nonce == 0
+}
+
+// SetTimestamp sets the timestamp of the header.  The timestamp is truncated to
+// one second precision since that is all the wire encoding supports.
+func (hw *HeaderWork) SetTimestamp(t time.Time) {
+	sec := t.Unix()
+	hw.header.Timestamp = time.Unix(sec, 0)
+	littleEndian.PutUint32(hw.serialized[headerTimestampOffset:],
+		uint32(sec))
+}
+
+// UpdateTimestamp sets the timestamp of the header to the current time as
+// reported by the Clock set via SetClock.
+func (hw *HeaderWork) UpdateTimestamp() {
+	hw.SetTimestamp(now())
+}
+
+// Bytes returns the serialized header which is hashed to produce the block
+// hash.  The returned slice must not be modified and is only valid until the
+// next change to the header.
+func (hw *HeaderWork) Bytes() []byte {
+	return hw.serialized[:]
+}
+
+// BlockSha computes the block identifier hash for the current state of the
+// header.  The result is identical to calling BlockSha on the header returned
+// by Header.
+func (hw *HeaderWork) BlockSha() ShaHash {
+	// SetBytes can't fail here due to the fact DoubleSha256 always returns
+	// a []byte of the right size regardless of input.
+	var sha ShaHash
+	_ = sha.SetBytes(DoubleSha256(hw.serialized[:]))
+	return sha
+}
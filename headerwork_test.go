@@ -0,0 +1,85 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestHeaderWork tests the HeaderWork API.
+func TestHeaderWork(t *testing.T) {
+	bh := blockOne.Header
+	hw := btcwire.NewHeaderWork(&bh)
+
+	// Ensure the initial hash matches the header.
+	wantSha, _ := bh.BlockSha()
+	if sha := hw.BlockSha(); !sha.IsEqual(&wantSha) {
+		t.Errorf("BlockSha: wrong hash - got %v, want %v",
+			spew.Sprint(sha), spew.Sprint(wantSha))
+	}
+
+	tests := []struct {
+		name   string                    // Description of the change
+		change func(*btcwire.HeaderWork) // Change to apply
+	}{
+		{"SetNonce", func(hw *btcwire.HeaderWork) {
+			hw.SetNonce(0x12345678)
+		}},
+		{"IncrementNonce", func(hw *btcwire.HeaderWork) {
+			hw.IncrementNonce()
+		}},
+		{"SetTimestamp", func(hw *btcwire.HeaderWork) {
+			hw.SetTimestamp(time.Unix(0x495fab29, 999))
+		}},
+		{"UpdateTimestamp", func(hw *btcwire.HeaderWork) {
+			hw.UpdateTimestamp()
+		}},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		test.change(hw)
+
+		// Ensure the hash of the serialized bytes matches the hash of
+		// the header which reflects the same changes.
+		header := hw.Header()
+		wantSha, _ := header.BlockSha()
+		if sha := hw.BlockSha(); !sha.IsEqual(&wantSha) {
+			t.Errorf("%s #%d: wrong hash - got %v, want %v",
+				test.name, i, spew.Sprint(sha),
+				spew.Sprint(wantSha))
+			continue
+		}
+		if hw.Nonce() != header.Nonce {
+			t.Errorf("%s #%d: wrong nonce - got %v, want %v",
+				test.name, i, hw.Nonce(), header.Nonce)
+		}
+	}
+
+	// Ensure the original header was not modified.
+	if !reflect.DeepEqual(&bh, &blockOne.Header) {
+		t.Errorf("NewHeaderWork: original header modified - got %v, "+
+			"want %v", spew.Sdump(&bh), spew.Sdump(&blockOne.Header))
+	}
+
+	// Ensure the nonce wraps and reports it.
+	hw.SetNonce(math.MaxUint32 - 1)
+	if hw.IncrementNonce() {
+		t.Errorf("IncrementNonce: unexpected wrap at nonce %d",
+			hw.Nonce())
+	}
+	if !hw.IncrementNonce() {
+		t.Errorf("IncrementNonce: did not report wrap")
+	}
+	if hw.Nonce() != 0 {
+		t.Errorf("IncrementNonce: wrong nonce after wrap - got %d, "+
+			"want 0", hw.Nonce())
+	}
+}
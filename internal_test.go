@@ -15,10 +15,6 @@ import (
 	"io"
 )
 
-// MaxMessagePayload makes the internal maxMessagePayload constant available to
-// the test package.
-const MaxMessagePayload uint32 = maxMessagePayload
-
 // CommandSize makes the internal commandSize constant available to the test
 // package.
 const CommandSize = commandSize
@@ -154,3 +150,48 @@ func TstReadTxIn(r io.Reader, pver uint32, version uint32, ti *TxIn) error {
 func TstWriteTxIn(w io.Writer, pver uint32, version uint32, ti *TxIn) error {
 	return writeTxIn(w, pver, version, ti)
 }
+
+// TstAuxPowReader wraps the provided reader so block headers read from it are
+// decoded as belonging to a network which supports merged mining.
+func TstAuxPowReader(r io.Reader) io.Reader {
+	return &payloadReader{Reader: r, auxPow: true}
+}
+
+// TstSetMessageNet replaces the network magic in the header of the provided
+// raw message.  The checksum does not cover the header, so the message remains
+// valid.
+func TstSetMessageNet(rawMsg []byte, btcnet BitcoinNet) {
+	littleEndian.PutUint32(rawMsg[0:4], uint32(btcnet))
+}
+
+// TstReadVLQ makes the internal readVLQ function available to the test
+// package.
+func TstReadVLQ(r io.Reader) (uint64, error) {
+	return readVLQ(r)
+}
+
+// TstWriteVLQ makes the internal writeVLQ function available to the test
+// package.
+func TstWriteVLQ(w io.Writer, val uint64) error {
+	return writeVLQ(w, val)
+}
+
+// TstVLQSerializeSize makes the internal vlqSerializeSize function available
+// to the test package.
+func TstVLQSerializeSize(val uint64) int {
+	return vlqSerializeSize(val)
+}
+
+// TstUnregisterMessage removes the constructor registered for the provided
+// command so tests can confirm commands are unrecognized until registered.
+func TstUnregisterMessage(command string) {
+	extraMessagesMtx.Lock()
+	delete(extraMessages, command)
+	extraMessagesMtx.Unlock()
+}
+
+// TstMurmurHash3 makes the internal murmurHash3 function available to the
+// test package.
+func TstMurmurHash3(seed uint32, data []byte) uint32 {
+	return murmurHash3(seed, data)
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"sync"
+)
+
+// invCacheEntry is an inventory vector in an InvCache along with the indices
+// of its neighbors in the recency list, which are -1 at the ends of the list.
+// The next index of an unused entry links the list of unused entries instead.
+type invCacheEntry struct {
+	iv   InvVect
+	prev int32
+	next int32
+}
+
+// InvCache is a set of inventory vectors with a fixed capacity, such as the
+// inventory known to a peer, which forgets the least recently used vector once
+// it is full.  Adding, looking up, removing, and evicting a vector all take
+// constant time.  It is safe for concurrent use.
+//
+// Unlike InvSet, adding a vector which is already present makes it the most
+// recently used, so inventory which keeps being announced is not forgotten.
+// The vectors are kept in a single slice linked by indices rather than a list
+// of separately allocated elements, so each vector costs little more than its
+// own size and the map entry which finds it.
+type InvCache struct {
+	mtx      sync.Mutex
+	capacity int
+	index    map[InvVect]int32
+	entries  []invCacheEntry
+	head     int32 // Most recently used entry
+	tail     int32 // Least recently used entry
+	free     int32 // First unused entry
+}
+
+// NewInvCache returns a new empty InvCache which holds up to capacity
+// inventory vectors.  A capacity less than one is treated as one.
+func NewInvCache(capacity int) *InvCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &InvCache{
+		capacity: capacity,
+		index:    make(map[InvVect]int32),
+		head:     -1,
+		tail:     -1,
+		free:     -1,
+	}
+}
+
+// unlink removes entry i from the recency list.  The cache must be locked.
+func (c *InvCache) unlink(i int32) {
+	e := &c.entries[i]
+	if e.prev >= 0 {
+		c.entries[e.prev].next = e.next
+	} else {
+		c.head = e.next
+	}
+	if e.next >= 0 {
+		c.entries[e.next].prev = e.prev
+	} else {
+		c.tail = e.prev
+	}
+}
+
+// pushFront makes entry i the most recently used.  The cache must be locked.
+func (c *InvCache) pushFront(i int32) {
+	e := &c.entries[i]
+	e.prev = -1
+	e.next = c.head
+	if c.head >= 0 {
+		c.entries[c.head].prev = i
+	} else {
+		c.tail = i
+	}
+	c.head = i
+}
+
+// Add adds the provided inventory vector to the cache as the most recently
+// used and returns whether it was not already present.  The least recently
+// used vector is forgotten when adding a new one to a full cache.
+func (c *InvCache) Add(iv *InvVect) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if i, ok := c.index[*iv]; ok {
+		if i != c.head {
+			c.unlink(i)
+			c.pushFront(i)
+		}
+		return false
+	}
+
+	// Reuse an entry freed by Delete, then grow the entries up to the
+	// capacity, and finally evict the least recently used entry.
+	var i int32
+	switch {
+	case c.free >= 0:
+		i = c.free
+		c.free = c.entries[i].next
+	case len(c.entries) < c.capacity:
+		c.entries = append(c.entries, invCacheEntry{})
+		i = int32(len(c.entries) - 1)
+	default:
+		i = c.tail
+		c.unlink(i)
+		delete(c.index, c.entries[i].iv)
+	}
+	c.entries[i].iv = *iv
+	c.pushFront(i)
+	c.index[*iv] = i
+	return true
+}
+
+// Contains returns whether the provided inventory vector is in the cache.  It
+// does not change how recently the vector was used.
+func (c *InvCache) Contains(iv *InvVect) bool {
+	c.mtx.Lock()
+	_, ok := c.index[*iv]
+	c.mtx.Unlock()
+	return ok
+}
+
+// Delete removes the provided inventory vector from the cache.  It has no
+// effect when the vector is not in the cache.
+func (c *InvCache) Delete(iv *InvVect) {
+	c.mtx.Lock()
+	if i, ok := c.index[*iv]; ok {
+		delete(c.index, *iv)
+		c.unlink(i)
+		c.entries[i] = invCacheEntry{next: c.free}
+		c.free = i
+	}
+	c.mtx.Unlock()
+}
+
+// Len returns the number of inventory vectors in the cache.
+func (c *InvCache) Len() int {
+	c.mtx.Lock()
+	n := len(c.index)
+	c.mtx.Unlock()
+	return n
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"github.com/conformal/btcwire"
+	"testing"
+)
+
+// TestInvCache ensures an InvCache tracks membership and forgets the least
+// recently used vectors once it is full.
+func TestInvCache(t *testing.T) {
+	tx := btcwire.NewInvVect(btcwire.InvTypeTx, &btcwire.GenesisHash)
+	block := btcwire.NewInvVect(btcwire.InvTypeBlock, &btcwire.GenesisHash)
+	other := btcwire.NewInvVect(btcwire.InvTypeTx,
+		&blockOne.Header.MerkleRoot)
+
+	c := btcwire.NewInvCache(2)
+	tests := []struct {
+		name string
+		fn   func() bool // Operation to perform
+		want bool        // Expected result
+		has  []bool      // Expected membership of tx, block, and other
+	}{
+		{"add tx", func() bool { return c.Add(tx) }, true,
+			[]bool{true, false, false}},
+		{"add block", func() bool { return c.Add(block) }, true,
+			[]bool{true, true, false}},
+		{"use tx again", func() bool { return c.Add(tx) }, false,
+			[]bool{true, true, false}},
+		{"add other", func() bool { return c.Add(other) }, true,
+			[]bool{true, false, true}},
+		{"add block", func() bool { return c.Add(block) }, true,
+			[]bool{false, true, true}},
+		{"delete other", func() bool { c.Delete(other); return true },
+			true, []bool{false, true, false}},
+		{"delete missing", func() bool { c.Delete(tx); return true },
+			true, []bool{false, true, false}},
+		{"add tx after delete", func() bool { return c.Add(tx) }, true,
+			[]bool{true, true, false}},
+		{"add other", func() bool { return c.Add(other) }, true,
+			[]bool{true, false, true}},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for _, test := range tests {
+		if got := test.fn(); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+		n := 0
+		for i, iv := range []*btcwire.InvVect{tx, block, other} {
+			if test.has[i] {
+				n++
+			}
+			if got := c.Contains(iv); got != test.has[i] {
+				t.Errorf("%s: Contains #%d got %v, want %v",
+					test.name, i, got, test.has[i])
+			}
+		}
+		if got := c.Len(); got != n {
+			t.Errorf("%s: Len got %d, want %d", test.name, got, n)
+		}
+	}
+
+	// Ensure a large cache only keeps the most recent vectors.
+	c = btcwire.NewInvCache(100)
+	hash := btcwire.ShaHash{}
+	for i := 0; i < 1000; i++ {
+		hash[0], hash[1] = byte(i), byte(i>>8)
+		c.Add(btcwire.NewInvVect(btcwire.InvTypeTx, &hash))
+	}
+	if c.Len() != 100 {
+		t.Errorf("Len: got %d, want %d", c.Len(), 100)
+	}
+	for i := 0; i < 1000; i++ {
+		hash[0], hash[1] = byte(i), byte(i>>8)
+		want := i >= 900
+		iv := btcwire.NewInvVect(btcwire.InvTypeTx, &hash)
+		if got := c.Contains(iv); got != want {
+			t.Errorf("Contains #%d: got %v, want %v", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"container/list"
+	"sync"
+)
+
+// InvSet is a set of inventory vectors, such as those already requested from
+// or announced to a peer, which answers whether it contains a vector in
+// constant time.  It is safe for concurrent use.
+//
+// A set may be bounded to a maximum number of vectors.  Once it is full, the
+// vector which was added the longest time ago is forgotten each time a new one
+// is added, so the memory used for a peer stays bounded no matter how much
+// inventory it relays.
+type InvSet struct {
+	mtx   sync.Mutex
+	limit int
+	set   map[InvVect]*list.Element
+	order *list.List
+}
+
+// NewInvSet returns a new empty InvSet which holds up to limit inventory
+// vectors.  A limit of zero or less results in an unbounded set.
+func NewInvSet(limit int) *InvSet {
+	if limit < 0 {
+		limit = 0
+	}
+	return &InvSet{
+		limit: limit,
+		set:   make(map[InvVect]*list.Element),
+		order: list.New(),
+	}
+}
+
+// Add adds the provided inventory vector to the set and returns whether it was
+// not already present.  The oldest vector is forgotten when adding a new one
+// to a full set.
+func (s *InvSet) Add(iv *InvVect) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if _, ok := s.set[*iv]; ok {
+		return false
+	}
+	if s.limit > 0 && s.order.Len() >= s.limit {
+		oldest := s.order.Front()
+		delete(s.set, oldest.Value.(InvVect))
+		s.order.Remove(oldest)
+	}
+	s.set[*iv] = s.order.PushBack(*iv)
+	return true
+}
+
+// Has returns whether the provided inventory vector is in the set.
+func (s *InvSet) Has(iv *InvVect) bool {
+	s.mtx.Lock()
+	_, ok := s.set[*iv]
+	s.mtx.Unlock()
+	return ok
+}
+
+// Delete removes the provided inventory vector from the set.  It has no effect
+// when the vector is not in the set.
+func (s *InvSet) Delete(iv *InvVect) {
+	s.mtx.Lock()
+	if elem, ok := s.set[*iv]; ok {
+		delete(s.set, *iv)
+		s.order.Remove(elem)
+	}
+	s.mtx.Unlock()
+}
+
+// Len returns the number of inventory vectors in the set.
+func (s *InvSet) Len() int {
+	s.mtx.Lock()
+	n := len(s.set)
+	s.mtx.Unlock()
+	return n
+}
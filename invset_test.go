@@ -0,0 +1,73 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"github.com/conformal/btcwire"
+	"testing"
+)
+
+// TestInvSet ensures an InvSet tracks membership and forgets the oldest
+// vectors once it is full.
+func TestInvSet(t *testing.T) {
+	tx := btcwire.NewInvVect(btcwire.InvTypeTx, &btcwire.GenesisHash)
+	block := btcwire.NewInvVect(btcwire.InvTypeBlock, &btcwire.GenesisHash)
+	other := btcwire.NewInvVect(btcwire.InvTypeTx,
+		&blockOne.Header.MerkleRoot)
+
+	s := btcwire.NewInvSet(2)
+	tests := []struct {
+		name string
+		fn   func() bool // Operation to perform
+		want bool        // Expected result
+		has  []bool      // Expected membership of tx, block, and other
+	}{
+		{"add tx", func() bool { return s.Add(tx) }, true,
+			[]bool{true, false, false}},
+		{"add tx again", func() bool { return s.Add(tx) }, false,
+			[]bool{true, false, false}},
+		{"add block", func() bool { return s.Add(block) }, true,
+			[]bool{true, true, false}},
+		{"add other", func() bool { return s.Add(other) }, true,
+			[]bool{false, true, true}},
+		{"delete block", func() bool { s.Delete(block); return true },
+			true, []bool{false, false, true}},
+		{"delete missing", func() bool { s.Delete(tx); return true },
+			true, []bool{false, false, true}},
+		{"add tx after delete", func() bool { return s.Add(tx) }, true,
+			[]bool{true, false, true}},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for _, test := range tests {
+		if got := test.fn(); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+		n := 0
+		for i, iv := range []*btcwire.InvVect{tx, block, other} {
+			if test.has[i] {
+				n++
+			}
+			if got := s.Has(iv); got != test.has[i] {
+				t.Errorf("%s: Has #%d got %v, want %v", test.name,
+					i, got, test.has[i])
+			}
+		}
+		if got := s.Len(); got != n {
+			t.Errorf("%s: Len got %d, want %d", test.name, got, n)
+		}
+	}
+
+	// Ensure an unbounded set never forgets vectors.
+	s = btcwire.NewInvSet(0)
+	hash := btcwire.ShaHash{}
+	for i := 0; i < 1000; i++ {
+		hash[0], hash[1] = byte(i), byte(i>>8)
+		s.Add(btcwire.NewInvVect(btcwire.InvTypeTx, &hash))
+	}
+	if s.Len() != 1000 {
+		t.Errorf("Len: got %d, want %d", s.Len(), 1000)
+	}
+}
@@ -7,6 +7,8 @@ package btcwire
 import (
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -44,6 +46,21 @@ func (invtype InvType) String() string {
 	return fmt.Sprintf("Unknown InvType (%d)", uint32(invtype))
 }
 
+// GoString returns the InvType as the Go-syntax name of its constant, or as a
+// conversion of its value when it has none.  This is part of the
+// fmt.GoStringer interface implementation.
+func (invtype InvType) GoString() string {
+	switch invtype {
+	case InvTypeError:
+		return goStringPkg + "InvTypeError"
+	case InvTypeTx:
+		return goStringPkg + "InvTypeTx"
+	case InvTypeBlock:
+		return goStringPkg + "InvTypeBlock"
+	}
+	return fmt.Sprintf("%sInvType(%d)", goStringPkg, uint32(invtype))
+}
+
 // InvVect defines a bitcoin inventory vector which is used to describe data,
 // as specified by the Type field, that a peer wants, has, or does not have to
 // another peer.
@@ -60,10 +77,116 @@ func NewInvVect(typ InvType, hash *ShaHash) *InvVect {
 	}
 }
 
+// GoString returns a Go-syntax representation of the inventory vector in terms
+// of NewInvVect.  This is part of the fmt.GoStringer interface implementation.
+func (iv *InvVect) GoString() string {
+	return fmt.Sprintf("%sNewInvVect(%#v, %sMustNewShaHashFromStr(%q))",
+		goStringPkg, iv.Type, goStringPkg, iv.Hash.String())
+}
+
+// invTypeTextNames maps the inventory vector types to the names used for them
+// in the textual form of inventory vectors.  See NewInvVectFromString.
+var invTypeTextNames = map[InvType]string{
+	InvTypeError: "error",
+	InvTypeTx:    "tx",
+	InvTypeBlock: "block",
+}
+
+// NewInvVectFromString returns a new InvVect parsed from its textual form of
+// "type:hash", such as "tx:" followed by the hash of a transaction.  The type
+// is one of "error", "tx", or "block", matched without regard to case, or the
+// decimal value of any other type.  The hash must be a complete hash in the
+// standard bitcoin big-endian form.  This form is intended for command line
+// tools, configuration files, and RPC bridges which need to reference
+// inventory textually.
+func NewInvVectFromString(s string) (*InvVect, error) {
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		str := fmt.Sprintf("inventory vector %q is not of the form "+
+			"type:hash", s)
+		return nil, messageError("NewInvVectFromString", str)
+	}
+	typeStr, hashStr := s[:i], s[i+1:]
+
+	typ, ok := InvType(0), false
+	for t, name := range invTypeTextNames {
+		if strings.EqualFold(typeStr, name) {
+			typ, ok = t, true
+			break
+		}
+	}
+	if !ok {
+		n, err := strconv.ParseUint(typeStr, 10, 32)
+		if err != nil {
+			str := fmt.Sprintf("unknown inventory vector type %q",
+				typeStr)
+			return nil, messageError("NewInvVectFromString", str)
+		}
+		typ = InvType(n)
+	}
+
+	hash, err := NewShaHashFromStrStrict(hashStr)
+	if err != nil {
+		str := fmt.Sprintf("invalid inventory vector hash %q: %v",
+			hashStr, err)
+		return nil, messageError("NewInvVectFromString", str)
+	}
+	return NewInvVect(typ, hash), nil
+}
+
+// MarshalText returns the inventory vector in the textual form parsed by
+// NewInvVectFromString.  Types without a name are written as their decimal
+// value.  This is part of the encoding.TextMarshaler interface implementation.
+func (iv *InvVect) MarshalText() ([]byte, error) {
+	name, ok := invTypeTextNames[iv.Type]
+	if !ok {
+		name = strconv.FormatUint(uint64(iv.Type), 10)
+	}
+	return []byte(name + ":" + iv.Hash.String()), nil
+}
+
+// UnmarshalText sets the inventory vector to the one parsed from text in the
+// same manner as NewInvVectFromString.  This is part of the
+// encoding.TextUnmarshaler interface implementation.
+func (iv *InvVect) UnmarshalText(text []byte) error {
+	parsed, err := NewInvVectFromString(string(text))
+	if err != nil {
+		return err
+	}
+	*iv = *parsed
+	return nil
+}
+
+// invListEqual returns whether the provided lists contain the same inventory
+// vectors in the same order.
+func invListEqual(a, b []*InvVect) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, iv := range a {
+		if iv == nil || b[i] == nil {
+			if iv != b[i] {
+				return false
+			}
+			continue
+		}
+		if *iv != *b[i] {
+			return false
+		}
+	}
+	return true
+}
+
 // readInvVect reads an encoded InvVect from r depending on the protocol
 // version.
 func readInvVect(r io.Reader, pver uint32, iv *InvVect) error {
-	err := readElements(r, &iv.Type, &iv.Hash)
+	typ, err := binarySerializer.Uint32(r, littleEndian)
+	if err != nil {
+		return err
+	}
+	iv.Type = InvType(typ)
+
+	_, err = io.ReadFull(r, iv.Hash[:])
 	if err != nil {
 		return err
 	}
@@ -72,7 +195,12 @@ func readInvVect(r io.Reader, pver uint32, iv *InvVect) error {
 
 // writeInvVect serializes an InvVect to w depending on the protocol version.
 func writeInvVect(w io.Writer, pver uint32, iv *InvVect) error {
-	err := writeElements(w, iv.Type, &iv.Hash)
+	err := binarySerializer.PutUint32(w, littleEndian, uint32(iv.Type))
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(iv.Hash[:])
 	if err != nil {
 		return err
 	}
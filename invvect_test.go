@@ -266,3 +266,80 @@ func TestInvVectWire(t *testing.T) {
 		}
 	}
 }
+
+// TestInvVectText tests the textual form of inventory vectors.
+func TestInvVectText(t *testing.T) {
+	hashStr := "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"
+	hash := btcwire.MustNewShaHashFromStr(hashStr)
+
+	tests := []struct {
+		in   string           // Textual form to parse
+		want *btcwire.InvVect // Expected inventory vector
+		text string           // Expected canonical textual form
+	}{
+		{"block:" + hashStr, btcwire.NewInvVect(btcwire.InvTypeBlock, hash),
+			"block:" + hashStr},
+		{"tx:" + hashStr, btcwire.NewInvVect(btcwire.InvTypeTx, hash),
+			"tx:" + hashStr},
+		{"error:" + hashStr, btcwire.NewInvVect(btcwire.InvTypeError, hash),
+			"error:" + hashStr},
+		{"BLOCK:" + hashStr, btcwire.NewInvVect(btcwire.InvTypeBlock, hash),
+			"block:" + hashStr},
+		{"1:" + hashStr, btcwire.NewInvVect(btcwire.InvTypeTx, hash),
+			"tx:" + hashStr},
+		{"4:" + hashStr, btcwire.NewInvVect(btcwire.InvType(4), hash),
+			"4:" + hashStr},
+
+		// Invalid forms.
+		{hashStr, nil, ""},
+		{"bogus:" + hashStr, nil, ""},
+		{"-1:" + hashStr, nil, ""},
+		{"4294967296:" + hashStr, nil, ""},
+		{"tx:" + hashStr[1:], nil, ""},
+		{"tx:" + hashStr[:63] + "g", nil, ""},
+		{"tx:", nil, ""},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		iv, err := btcwire.NewInvVectFromString(test.in)
+		if test.want == nil {
+			if _, ok := err.(*btcwire.MessageError); !ok {
+				t.Errorf("NewInvVectFromString #%d: expected "+
+					"MessageError, got %v", i, err)
+			}
+			var uiv btcwire.InvVect
+			if err := uiv.UnmarshalText([]byte(test.in)); err == nil {
+				t.Errorf("UnmarshalText #%d: expected error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NewInvVectFromString #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(iv, test.want) {
+			t.Errorf("NewInvVectFromString #%d\n got: %s want: %s",
+				i, spew.Sdump(iv), spew.Sdump(test.want))
+			continue
+		}
+
+		text, err := iv.MarshalText()
+		if err != nil || string(text) != test.text {
+			t.Errorf("MarshalText #%d got: %s (%v), want: %s", i,
+				text, err, test.text)
+			continue
+		}
+
+		// Ensure the canonical form parses back to the same value.
+		var uiv btcwire.InvVect
+		if err := uiv.UnmarshalText(text); err != nil {
+			t.Errorf("UnmarshalText #%d error %v", i, err)
+			continue
+		}
+		if uiv != *test.want {
+			t.Errorf("UnmarshalText #%d\n got: %s want: %s", i,
+				spew.Sdump(uiv), spew.Sdump(test.want))
+		}
+	}
+}
@@ -0,0 +1,312 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Commands used in the message headers of the messages of the IP transaction
+// protocol, which allowed paying a node directly by its IP address in the
+// earliest versions of the reference implementation before being removed.
+// These messages are only recognized once RegisterIPTransactionMessages has
+// been called.
+const (
+	CmdCheckOrder  = "checkorder"
+	CmdSubmitOrder = "submitorder"
+	CmdReply       = "reply"
+)
+
+// maxMerkleBranchLen is the maximum number of hashes in the merkle branch of a
+// MerkleTx, which is enough for a block with 2^32 transactions.
+const maxMerkleBranchLen = 32
+
+// RegisterIPTransactionMessages registers the messages of the retired IP
+// transaction protocol, which are MsgCheckOrder, MsgSubmitOrder, and MsgReply,
+// so they are recognized when they are read.  Until it is called, messages
+// with their commands are treated the same as any other unrecognized command.
+//
+// These messages only exist in captures of the very early network, so this is
+// intended to be called by tools which replay historical traffic.  They can
+// only be decoded and any attempt to encode them returns a MessageError.
+func RegisterIPTransactionMessages() {
+	registerMessage(CmdCheckOrder, func() Message { return &MsgCheckOrder{} })
+	registerMessage(CmdSubmitOrder, func() Message { return &MsgSubmitOrder{} })
+	registerMessage(CmdReply, func() Message { return &MsgReply{} })
+}
+
+// errDecodeOnly returns the error for an attempt to encode a message of the IP
+// transaction protocol attributed to the function f.
+func errDecodeOnly(f string) error {
+	return messageError(f, "messages of the retired IP transaction "+
+		"protocol can only be decoded")
+}
+
+// StringPair is a key and value pair of the string maps and lists in a
+// WalletTx.
+type StringPair struct {
+	Key   string
+	Value string
+}
+
+// MerkleTx is a transaction along with the merkle branch which links it to the
+// block containing it, as used by the IP transaction protocol.
+type MerkleTx struct {
+	Tx MsgTx
+
+	// BlockHash is the hash of the block containing the transaction.
+	BlockHash ShaHash
+
+	// MerkleBranch holds the hashes linking the transaction to the merkle
+	// root of the block, and Index is the position of the transaction in
+	// the block.
+	MerkleBranch []ShaHash
+	Index        int32
+}
+
+// WalletTx is a transaction in the form the wallets of the earliest versions
+// of the reference implementation stored and exchanged it, which is the
+// transaction with its merkle branch followed by the wallet's bookkeeping.
+type WalletTx struct {
+	MerkleTx
+
+	// PrevTxs holds the transactions spent by the transaction which are
+	// not yet in a block, along with their merkle branches.
+	PrevTxs []*MerkleTx
+
+	// Values holds the wallet's values for the transaction, such as the
+	// sender's name and message, sorted by key.
+	Values []StringPair
+
+	// OrderForm holds the fields of the order form submitted with the
+	// transaction in the order they were entered.
+	OrderForm []StringPair
+
+	TimeReceivedIsTxTime uint32
+	TimeReceived         time.Time
+	FromMe               bool
+	Spent                bool
+}
+
+// readMerkleTx reads a transaction with its merkle branch from r into mtx.
+// The function f is used to attribute any errors.
+func readMerkleTx(r io.Reader, pver uint32, f string, mtx *MerkleTx) error {
+	err := mtx.Tx.BtcDecode(r, pver)
+	if err != nil {
+		return err
+	}
+	_, err = io.ReadFull(r, mtx.BlockHash[:])
+	if err != nil {
+		return err
+	}
+
+	count, err := readVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	if count > maxMerkleBranchLen {
+		str := fmt.Sprintf("too many merkle branch hashes for message "+
+			"[count %v, max %v]", count, maxMerkleBranchLen)
+		return categorizedError(f, str, ErrCategoryOversized)
+	}
+	err = checkCountFits(r, f, "merkle branch hashes", count, HashSize)
+	if err != nil {
+		return err
+	}
+	mtx.MerkleBranch = make([]ShaHash, count)
+	for i := range mtx.MerkleBranch {
+		_, err := io.ReadFull(r, mtx.MerkleBranch[i][:])
+		if err != nil {
+			return err
+		}
+	}
+
+	index, err := binarySerializer.Uint32(r, littleEndian)
+	if err != nil {
+		return err
+	}
+	mtx.Index = int32(index)
+	return nil
+}
+
+// merkleTxSerializeSize returns the number of bytes it would take to encode
+// the provided transaction with its merkle branch.
+func merkleTxSerializeSize(pver uint32, mtx *MerkleTx) int {
+	return mtx.Tx.SerializeSize(pver) + HashSize +
+		varIntSerializeSize(uint64(len(mtx.MerkleBranch))) +
+		len(mtx.MerkleBranch)*HashSize + 4
+}
+
+// merkleTxEqual returns whether the provided transactions and their merkle
+// branches are the same.
+func merkleTxEqual(a, b *MerkleTx) bool {
+	if !a.Tx.Equal(&b.Tx) || a.BlockHash != b.BlockHash ||
+		a.Index != b.Index || len(a.MerkleBranch) != len(b.MerkleBranch) {
+
+		return false
+	}
+	for i := range a.MerkleBranch {
+		if a.MerkleBranch[i] != b.MerkleBranch[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// readStringPairs reads a list of string pairs from r.  The function f is used
+// to attribute any errors.
+func readStringPairs(r io.Reader, pver uint32, f string) ([]StringPair, error) {
+	count, err := readVarInt(r, pver)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every pair takes at least the two bytes of the lengths of its key
+	// and value.
+	if count > MaxMessagePayload/2 {
+		str := fmt.Sprintf("too many string pairs for message "+
+			"[count %v, max %v]", count, MaxMessagePayload/2)
+		return nil, categorizedError(f, str, ErrCategoryOversized)
+	}
+	err = checkCountFits(r, f, "string pairs", count, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := make([]StringPair, count)
+	for i := range pairs {
+		pairs[i].Key, err = readVarString(r, pver)
+		if err != nil {
+			return nil, err
+		}
+		pairs[i].Value, err = readVarString(r, pver)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pairs, nil
+}
+
+// stringPairsSerializeSize returns the number of bytes it would take to encode
+// the provided list of string pairs.
+func stringPairsSerializeSize(pairs []StringPair) int {
+	n := varIntSerializeSize(uint64(len(pairs)))
+	for _, pair := range pairs {
+		n += varIntSerializeSize(uint64(len(pair.Key))) + len(pair.Key) +
+			varIntSerializeSize(uint64(len(pair.Value))) +
+			len(pair.Value)
+	}
+	return n
+}
+
+// stringPairsEqual returns whether the provided lists contain the same string
+// pairs in the same order.
+func stringPairsEqual(a, b []StringPair) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// readWalletTx reads a wallet transaction from r into wtx.  The function f is
+// used to attribute any errors.
+func readWalletTx(r io.Reader, pver uint32, f string, wtx *WalletTx) error {
+	err := readMerkleTx(r, pver, f, &wtx.MerkleTx)
+	if err != nil {
+		return err
+	}
+
+	count, err := readVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	if count > maxTxPerBlock {
+		str := fmt.Sprintf("too many previous transactions for message "+
+			"[count %v, max %v]", count, maxTxPerBlock)
+		return categorizedError(f, str, ErrCategoryOversized)
+	}
+	err = checkCountFits(r, f, "previous transactions", count,
+		minTxPayload+HashSize+5)
+	if err != nil {
+		return err
+	}
+	err = reserveAlloc(r, f, "previous transactions", count*txAllocSize)
+	if err != nil {
+		return err
+	}
+	wtx.PrevTxs = make([]*MerkleTx, 0, count)
+	for i := uint64(0); i < count; i++ {
+		mtx := MerkleTx{}
+		err := readMerkleTx(r, pver, f, &mtx)
+		if err != nil {
+			return err
+		}
+		wtx.PrevTxs = append(wtx.PrevTxs, &mtx)
+	}
+
+	wtx.Values, err = readStringPairs(r, pver, f)
+	if err != nil {
+		return err
+	}
+	wtx.OrderForm, err = readStringPairs(r, pver, f)
+	if err != nil {
+		return err
+	}
+
+	var timeReceived uint32
+	var fromMe, spent uint8
+	err = readElements(r, &wtx.TimeReceivedIsTxTime, &timeReceived,
+		&fromMe, &spent)
+	if err != nil {
+		return err
+	}
+	wtx.TimeReceived = time.Unix(int64(timeReceived), 0)
+	wtx.FromMe = fromMe != 0
+	wtx.Spent = spent != 0
+	return nil
+}
+
+// walletTxSerializeSize returns the number of bytes it would take to encode
+// the provided wallet transaction.
+func walletTxSerializeSize(pver uint32, wtx *WalletTx) int {
+	n := merkleTxSerializeSize(pver, &wtx.MerkleTx) +
+		varIntSerializeSize(uint64(len(wtx.PrevTxs)))
+	for _, mtx := range wtx.PrevTxs {
+		n += merkleTxSerializeSize(pver, mtx)
+	}
+
+	// Time received is tx time 4 bytes + time received 4 bytes + from me
+	// 1 byte + spent 1 byte.
+	return n + stringPairsSerializeSize(wtx.Values) +
+		stringPairsSerializeSize(wtx.OrderForm) + 10
+}
+
+// walletTxEqual returns whether the provided wallet transactions are the
+// same.
+func walletTxEqual(a, b *WalletTx) bool {
+	if !merkleTxEqual(&a.MerkleTx, &b.MerkleTx) ||
+		len(a.PrevTxs) != len(b.PrevTxs) ||
+		!stringPairsEqual(a.Values, b.Values) ||
+		!stringPairsEqual(a.OrderForm, b.OrderForm) ||
+		a.TimeReceivedIsTxTime != b.TimeReceivedIsTxTime ||
+		!a.TimeReceived.Equal(b.TimeReceived) ||
+		a.FromMe != b.FromMe || a.Spent != b.Spent {
+
+		return false
+	}
+	for i := range a.PrevTxs {
+		if !merkleTxEqual(a.PrevTxs[i], b.PrevTxs[i]) {
+			return false
+		}
+	}
+	return true
+}
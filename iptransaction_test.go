@@ -0,0 +1,140 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/conformal/btcwire"
+	"testing"
+	"time"
+)
+
+// walletTx is a wallet transaction with one unconfirmed previous transaction,
+// a value, and an order form field used in the IP transaction tests.
+var walletTx = btcwire.WalletTx{
+	MerkleTx: btcwire.MerkleTx{
+		Tx:           *multiTx,
+		BlockHash:    btcwire.GenesisHash,
+		MerkleBranch: []btcwire.ShaHash{blockOne.Header.MerkleRoot},
+		Index:        2,
+	},
+	PrevTxs: []*btcwire.MerkleTx{
+		{
+			Tx:           *multiTx,
+			MerkleBranch: []btcwire.ShaHash{},
+			Index:        -1,
+		},
+	},
+	Values: []btcwire.StringPair{
+		{Key: "from", Value: "Satoshi"},
+	},
+	OrderForm: []btcwire.StringPair{
+		{Key: "Name", Value: "Hal"},
+	},
+	TimeReceivedIsTxTime: 1,
+	TimeReceived:         time.Unix(0x495fab29, 0),
+	FromMe:               true,
+}
+
+// walletTxEncoded is the wire encoded bytes of walletTx.
+var walletTxEncoded = func() []byte {
+	var b []byte
+	b = append(b, multiTxEncoded...)
+	b = append(b, btcwire.GenesisHash[:]...)               // Block hash
+	b = append(b, 0x01)                                    // Varint for number of branch hashes
+	b = append(b, blockOne.Header.MerkleRoot[:]...)        // Merkle branch
+	b = append(b, 0x02, 0x00, 0x00, 0x00)                  // Index
+	b = append(b, 0x01)                                    // Varint for number of previous txs
+	b = append(b, multiTxEncoded...)                       // Previous tx
+	b = append(b, make([]byte, btcwire.HashSize)...)       // Block hash
+	b = append(b, 0x00)                                    // Varint for number of branch hashes
+	b = append(b, 0xff, 0xff, 0xff, 0xff)                  // Index
+	b = append(b, 0x01)                                    // Varint for number of values
+	b = append(b, 0x04, 'f', 'r', 'o', 'm')                // Key
+	b = append(b, 0x07, 'S', 'a', 't', 'o', 's', 'h', 'i') // Value
+	b = append(b, 0x01)                                    // Varint for number of order form fields
+	b = append(b, 0x04, 'N', 'a', 'm', 'e')                // Key
+	b = append(b, 0x03, 'H', 'a', 'l')                     // Value
+	b = append(b, 0x01, 0x00, 0x00, 0x00)                  // Time received is tx time
+	b = append(b, 0x29, 0xab, 0x5f, 0x49)                  // Time received
+	b = append(b, 0x01)                                    // From me
+	b = append(b, 0x00)                                    // Spent
+	return b
+}()
+
+// TestRegisterIPTransactionMessages ensures the IP transaction messages are
+// only recognized once they have been registered and can't be encoded.
+func TestRegisterIPTransactionMessages(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcnet := btcwire.MainNet
+
+	hash := btcwire.GenesisHash
+	replyPayload := append(append([]byte{}, hash[:]...), 0, 0, 0, 0)
+	orderPayload := append(append([]byte{}, hash[:]...), walletTxEncoded...)
+	tests := []struct {
+		payload []byte          // Payload of the message
+		msg     btcwire.Message // Expected decoded message
+	}{
+		{
+			orderPayload,
+			&btcwire.MsgCheckOrder{ReplyHash: hash, Order: walletTx},
+		},
+		{
+			orderPayload,
+			&btcwire.MsgSubmitOrder{ReplyHash: hash, Order: walletTx},
+		},
+		{
+			replyPayload,
+			&btcwire.MsgReply{ReplyHash: hash},
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		btcwire.TstUnregisterMessage(test.msg.Command())
+
+		checksum := binary.LittleEndian.Uint32(
+			btcwire.DoubleSha256(test.payload))
+		raw := makeHeader(btcnet, test.msg.Command(),
+			uint32(len(test.payload)), checksum)
+		raw = append(raw, test.payload...)
+
+		// The command is unrecognized before registration.
+		_, _, err := btcwire.ReadMessage(bytes.NewReader(raw), pver, btcnet)
+		merr, ok := err.(*btcwire.MessageError)
+		if !ok || merr.Category != btcwire.ErrCategoryUnknownCommand {
+			t.Errorf("ReadMessage #%d unregistered: wrong error got: %v",
+				i, err)
+			continue
+		}
+
+		btcwire.RegisterIPTransactionMessages()
+		msg, _, err := btcwire.ReadMessage(bytes.NewReader(raw), pver,
+			btcnet)
+		if err != nil {
+			t.Errorf("ReadMessage #%d error %v", i, err)
+			continue
+		}
+		if diff := btcwire.Diff(test.msg, msg); len(diff) != 0 {
+			t.Errorf("ReadMessage #%d\n%v", i, diff)
+		}
+		if size := msg.SerializeSize(pver); size != len(test.payload) {
+			t.Errorf("SerializeSize #%d: got %d, want %d", i, size,
+				len(test.payload))
+		}
+
+		// The messages can only be decoded.
+		var buf bytes.Buffer
+		err = btcwire.WriteMessage(&buf, msg, pver, btcnet)
+		if _, ok := err.(*btcwire.MessageError); !ok {
+			t.Errorf("WriteMessage #%d: wrong error - got %T (%v), "+
+				"want *MessageError", i, err, err)
+		}
+		if _, ok := msg.Validate().(*btcwire.MessageError); !ok {
+			t.Errorf("Validate #%d: expected a MessageError", i)
+		}
+	}
+}
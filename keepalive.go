@@ -0,0 +1,217 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultKeepAliveInterval is the default amount of time a connection
+	// may be idle before a KeepAlive sends a ping.
+	defaultKeepAliveInterval = time.Minute * 2
+
+	// defaultKeepAliveTimeout is the default amount of time a KeepAlive
+	// waits for the pong answering a ping.
+	defaultKeepAliveTimeout = time.Minute * 20
+)
+
+// ErrPingTimeout describes an error that indicates the remote peer did not
+// answer a ping within the timeout of a KeepAlive.
+var ErrPingTimeout = errors.New("ping timeout")
+
+// KeepAliveConfig houses the optional configuration parameters for a
+// KeepAlive.  The zero value provides the same defaults as the reference
+// implementation.
+type KeepAliveConfig struct {
+	// Interval is the amount of time the connection may go without
+	// receiving a message before a ping is sent.  It defaults to 2 minutes
+	// when zero.
+	Interval time.Duration
+
+	// Timeout is the amount of time allowed for the remote peer to answer
+	// a ping with a pong.  It defaults to 20 minutes when zero.
+	Timeout time.Duration
+
+	// Tracker, when non-nil, is used to create the pings and match the
+	// pongs which answer them, so round-trip times can be measured.  A new
+	// tracker is used otherwise.
+	Tracker *PingTracker
+
+	// Disconnect causes the connection to be shut down with ErrPingTimeout
+	// when the timeout elapses.  Otherwise the timeout is only reported via
+	// TimedOut.
+	Disconnect bool
+}
+
+// KeepAlive sends ping messages (MsgPing) over a MessageConn after it has been
+// idle for a while and flags remote peers which fail to answer them with a
+// pong message (MsgPong) in time.  This keeps connections through NAT devices
+// and firewalls from being dropped and detects peers which have stopped
+// responding without closing the connection.
+//
+// Since the caller receives the messages of the connection, it must pass each
+// of them to MessageReceived so the KeepAlive knows the connection is active
+// and sees the pongs.
+//
+// Pongs only exist for protocol versions after BIP0031Version, so pings sent
+// with older protocol versions only serve to keep the connection active and
+// are never flagged as unanswered.
+type KeepAlive struct {
+	conn    *MessageConn
+	cfg     KeepAliveConfig
+	tracker *PingTracker
+
+	received chan struct{}
+	pong     chan struct{}
+	timedOut chan struct{}
+	quit     chan struct{}
+	wg       sync.WaitGroup
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+// NewKeepAlive returns a new KeepAlive which sends pings over conn.  A nil cfg
+// is treated the same as the zero value.  Start must be called to begin
+// sending pings.
+func NewKeepAlive(conn *MessageConn, cfg *KeepAliveConfig) *KeepAlive {
+	var c KeepAliveConfig
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.Interval <= 0 {
+		c.Interval = defaultKeepAliveInterval
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultKeepAliveTimeout
+	}
+	tracker := c.Tracker
+	if tracker == nil {
+		tracker = NewPingTracker(0)
+	}
+
+	return &KeepAlive{
+		conn:     conn,
+		cfg:      c,
+		tracker:  tracker,
+		received: make(chan struct{}, 1),
+		pong:     make(chan struct{}, 1),
+		timedOut: make(chan struct{}),
+		quit:     make(chan struct{}),
+	}
+}
+
+// Start begins sending pings once the connection is idle.  Calling it more
+// than once has no effect.
+func (ka *KeepAlive) Start() {
+	ka.startOnce.Do(func() {
+		ka.wg.Add(1)
+		go ka.handler()
+	})
+}
+
+// Stop stops sending pings and waits for the KeepAlive to finish.  It also
+// stops once the connection shuts down, so calling it is only necessary to
+// stop early.
+func (ka *KeepAlive) Stop() {
+	ka.stopOnce.Do(func() {
+		close(ka.quit)
+	})
+	ka.wg.Wait()
+}
+
+// TimedOut returns a channel which is closed when the remote peer fails to
+// answer a ping within the timeout.  No further pings are sent once it has
+// been closed.
+func (ka *KeepAlive) TimedOut() <-chan struct{} {
+	return ka.timedOut
+}
+
+// Tracker returns the PingTracker used to create pings and match pongs.
+func (ka *KeepAlive) Tracker() *PingTracker {
+	return ka.tracker
+}
+
+// MessageReceived notes that the provided message was received from the
+// remote peer, which means the connection is active.  Pongs which answer a
+// ping are also recorded with the tracker.  It must be called for every
+// message received over the connection and never blocks.
+func (ka *KeepAlive) MessageReceived(msg Message) {
+	select {
+	case ka.received <- struct{}{}:
+	default:
+	}
+
+	pong, ok := msg.(*MsgPong)
+	if !ok {
+		return
+	}
+	if _, ok := ka.tracker.HandlePong(pong); ok {
+		select {
+		case ka.pong <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// handler sends a ping each time the connection has been idle for the
+// interval and watches for the pongs which answer them until the KeepAlive is
+// stopped or the connection shuts down.  It must be run as a goroutine.
+func (ka *KeepAlive) handler() {
+	defer ka.wg.Done()
+
+	idle := time.NewTimer(ka.cfg.Interval)
+	defer idle.Stop()
+
+	// pongTimeout is only set while a ping is waiting for its pong.
+	var pongTimeout <-chan time.Time
+	for {
+		select {
+		case <-ka.received:
+			if !idle.Stop() {
+				<-idle.C
+			}
+			idle.Reset(ka.cfg.Interval)
+
+		case <-ka.pong:
+			pongTimeout = nil
+
+		case <-idle.C:
+			idle.Reset(ka.cfg.Interval)
+
+			// Only one ping is outstanding at a time.
+			if pongTimeout != nil {
+				continue
+			}
+			ping, err := ka.tracker.NewPing()
+			if err != nil {
+				continue
+			}
+			pver := ka.conn.ProtocolVersion()
+			if ka.conn.QueueMessage(ping, nil) != nil {
+				return
+			}
+			if FeaturePingNonce.IsSupported(pver) {
+				pongTimeout = time.After(ka.cfg.Timeout)
+			}
+
+		case <-pongTimeout:
+			close(ka.timedOut)
+			if ka.cfg.Disconnect {
+				ka.conn.shutdown(ErrPingTimeout)
+			}
+			return
+
+		case <-ka.conn.Done():
+			return
+
+		case <-ka.quit:
+			return
+		}
+	}
+}
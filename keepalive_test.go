@@ -0,0 +1,144 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"github.com/conformal/btcwire"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestKeepAlive ensures a KeepAlive pings an idle connection, records the
+// pongs which answer it, and flags a peer which stops answering.
+func TestKeepAlive(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcnet := btcwire.MainNet
+
+	inConn, outConn := net.Pipe()
+	a := btcwire.NewMessageConn(outConn, pver, btcnet, nil)
+	b := btcwire.NewMessageConn(inConn, pver, btcnet, nil)
+	a.Start()
+	b.Start()
+	defer a.Close()
+	defer b.Close()
+
+	cfg := &btcwire.KeepAliveConfig{
+		Interval:   time.Millisecond * 10,
+		Timeout:    time.Millisecond * 50,
+		Disconnect: true,
+	}
+	ka := btcwire.NewKeepAlive(a, cfg)
+	ka.Start()
+	defer ka.Stop()
+
+	// Answer a few pings and pass the pongs back to the keepalive.
+	tests := 3
+	t.Logf("Running %d tests", tests)
+	for i := 0; i < tests; i++ {
+		var ping *btcwire.MsgPing
+		select {
+		case msg := <-b.Inbound():
+			var ok bool
+			ping, ok = msg.(*btcwire.MsgPing)
+			if !ok {
+				t.Fatalf("Inbound #%d: unexpected message %T", i,
+					msg)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("Inbound #%d: timeout waiting for ping", i)
+		}
+		if err := b.Send(btcwire.NewMsgPong(ping.Nonce)); err != nil {
+			t.Fatalf("Send #%d: unexpected error %v", i, err)
+		}
+
+		select {
+		case msg := <-a.Inbound():
+			ka.MessageReceived(msg)
+		case <-time.After(time.Second * 5):
+			t.Fatalf("Inbound #%d: timeout waiting for pong", i)
+		}
+	}
+	if ka.Tracker().LastRTT() <= 0 {
+		t.Errorf("LastRTT: round-trip time not recorded")
+	}
+	select {
+	case <-ka.TimedOut():
+		t.Fatalf("TimedOut: unexpected timeout with answered pings")
+	default:
+	}
+
+	// Stop answering the pings while still reading them and ensure the
+	// peer is flagged and the connection shut down.
+	go func() {
+		for range b.Inbound() {
+		}
+	}()
+	select {
+	case <-ka.TimedOut():
+	case <-time.After(time.Second * 5):
+		t.Fatalf("TimedOut: timeout waiting for unanswered ping")
+	}
+	select {
+	case <-a.Done():
+	case <-time.After(time.Second * 5):
+		t.Fatalf("Done: timeout waiting for shutdown")
+	}
+	if err := a.Err(); err != btcwire.ErrPingTimeout {
+		t.Errorf("Err: wrong error - got %v, want %v", err,
+			btcwire.ErrPingTimeout)
+	}
+}
+
+// TestKeepAliveNoPong ensures pings sent with protocol versions which predate
+// pongs keep the connection active without ever timing out.
+func TestKeepAliveNoPong(t *testing.T) {
+	pver := btcwire.BIP0031Version
+	btcnet := btcwire.MainNet
+
+	inConn, outConn := net.Pipe()
+	a := btcwire.NewMessageConn(outConn, pver, btcnet, nil)
+	b := btcwire.NewMessageConn(inConn, pver, btcnet, nil)
+	a.Start()
+	b.Start()
+	defer a.Close()
+	defer b.Close()
+
+	cfg := &btcwire.KeepAliveConfig{
+		Interval: time.Millisecond * 5,
+		Timeout:  time.Millisecond * 5,
+	}
+	ka := btcwire.NewKeepAlive(a, cfg)
+	ka.Start()
+
+	// Ensure several pings are sent without any pongs being expected.
+	tests := 3
+	t.Logf("Running %d tests", tests)
+	for i := 0; i < tests; i++ {
+		select {
+		case msg := <-b.Inbound():
+			if _, ok := msg.(*btcwire.MsgPing); !ok {
+				t.Fatalf("Inbound #%d: unexpected message %T", i,
+					msg)
+			}
+		case <-ka.TimedOut():
+			t.Fatalf("TimedOut #%d: unexpected timeout", i)
+		case <-time.After(time.Second * 5):
+			t.Fatalf("Inbound #%d: timeout waiting for ping", i)
+		}
+	}
+
+	// Ensure stopping the keepalive stops the pings.
+	ka.Stop()
+	select {
+	case <-b.Inbound():
+	default:
+	}
+	select {
+	case msg := <-b.Inbound():
+		t.Errorf("Inbound: unexpected message %T after Stop", msg)
+	case <-time.After(time.Millisecond * 50):
+	}
+}
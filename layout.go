@@ -0,0 +1,248 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// ByteRange is a range of bytes within serialized data.
+type ByteRange struct {
+	Offset int
+	Len    int
+}
+
+// TxInLayout describes where the parts of a transaction input are located
+// within serialized data.
+type TxInLayout struct {
+	// Range covers the entire input.
+	Range ByteRange
+
+	// PreviousOutpoint covers the hash and index of the previous outpoint.
+	PreviousOutpoint ByteRange
+
+	// SignatureScript covers the signature script, excluding the variable
+	// length integer which precedes it.
+	SignatureScript ByteRange
+
+	// Sequence covers the sequence number.
+	Sequence ByteRange
+}
+
+// TxOutLayout describes where the parts of a transaction output are located
+// within serialized data.
+type TxOutLayout struct {
+	// Range covers the entire output.
+	Range ByteRange
+
+	// Value covers the value of the output.
+	Value ByteRange
+
+	// PkScript covers the public key script, excluding the variable length
+	// integer which precedes it.
+	PkScript ByteRange
+}
+
+// TxLayout describes where the parts of a transaction are located within
+// serialized data.
+type TxLayout struct {
+	// Range covers the entire transaction.
+	Range ByteRange
+
+	// Version covers the version of the transaction.
+	Version ByteRange
+
+	// TxIn and TxOut describe each of the inputs and outputs in order.
+	TxIn  []TxInLayout
+	TxOut []TxOutLayout
+
+	// LockTime covers the lock time of the transaction.
+	LockTime ByteRange
+}
+
+// BlockLayout describes where the parts of a block are located within
+// serialized data.
+type BlockLayout struct {
+	// Header covers the fixed size fields of the block header, which are
+	// the bytes hashed to produce the block hash.
+	Header ByteRange
+
+	// TxCount covers the variable length integer of the number of
+	// transactions.
+	TxCount ByteRange
+
+	// Tx describes each of the transactions in order.
+	Tx []TxLayout
+}
+
+// layoutReader reads serialized data while keeping track of the current
+// offset within it.
+type layoutReader struct {
+	*bytes.Reader
+	size int
+}
+
+// offset returns the offset of the next byte to be read.
+func (r *layoutReader) offset() int {
+	return r.size - r.Len()
+}
+
+// since returns the range from the provided offset to the current offset.
+func (r *layoutReader) since(offset int) ByteRange {
+	return ByteRange{Offset: offset, Len: r.offset() - offset}
+}
+
+// readTxLayout reads a transaction from r into tx while recording the location
+// of each of its parts.  The function f is used to attribute any errors.
+func readTxLayout(r *layoutReader, f string, tx *MsgTx) (TxLayout, error) {
+	var layout TxLayout
+	start := r.offset()
+
+	var err error
+	tx.Version, err = binarySerializer.Uint32(r, littleEndian)
+	if err != nil {
+		return layout, err
+	}
+	layout.Version = r.since(start)
+
+	count, err := readVarInt(r, 0)
+	if err != nil {
+		return layout, err
+	}
+	if count > uint64(maxTxInPerMessage) {
+		str := fmt.Sprintf("too many input transactions to fit into "+
+			"max message size [count %d, max %d]", count,
+			maxTxInPerMessage)
+		return layout, categorizedError(f, str, ErrCategoryOversized)
+	}
+	err = checkCountFits(r, f, "transaction inputs", count, minTxInPayload)
+	if err != nil {
+		return layout, err
+	}
+	tx.TxIn = make([]*TxIn, count)
+	layout.TxIn = make([]TxInLayout, count)
+	for i := range tx.TxIn {
+		inStart := r.offset()
+		ti := &TxIn{}
+		err := readTxIn(r, 0, tx.Version, ti)
+		if err != nil {
+			return layout, err
+		}
+		tx.TxIn[i] = ti
+
+		// The variable length fields are located by working back from
+		// the end of the input.
+		end := r.offset()
+		scriptLen := len(ti.SignatureScript)
+		layout.TxIn[i] = TxInLayout{
+			Range:            r.since(inStart),
+			PreviousOutpoint: ByteRange{inStart, HashSize + 4},
+			SignatureScript:  ByteRange{end - 4 - scriptLen, scriptLen},
+			Sequence:         ByteRange{end - 4, 4},
+		}
+	}
+
+	count, err = readVarInt(r, 0)
+	if err != nil {
+		return layout, err
+	}
+	if count > uint64(maxTxOutPerMessage) {
+		str := fmt.Sprintf("too many output transactions to fit into "+
+			"max message size [count %d, max %d]", count,
+			maxTxOutPerMessage)
+		return layout, categorizedError(f, str, ErrCategoryOversized)
+	}
+	err = checkCountFits(r, f, "transaction outputs", count,
+		minTxOutPayload)
+	if err != nil {
+		return layout, err
+	}
+	tx.TxOut = make([]*TxOut, count)
+	layout.TxOut = make([]TxOutLayout, count)
+	for i := range tx.TxOut {
+		outStart := r.offset()
+		to := &TxOut{}
+		err := readTxOut(r, 0, tx.Version, to)
+		if err != nil {
+			return layout, err
+		}
+		tx.TxOut[i] = to
+
+		end := r.offset()
+		scriptLen := len(to.PkScript)
+		layout.TxOut[i] = TxOutLayout{
+			Range:    r.since(outStart),
+			Value:    ByteRange{outStart, 8},
+			PkScript: ByteRange{end - scriptLen, scriptLen},
+		}
+	}
+
+	lockTimeStart := r.offset()
+	tx.LockTime, err = binarySerializer.Uint32(r, littleEndian)
+	if err != nil {
+		return layout, err
+	}
+	layout.LockTime = r.since(lockTimeStart)
+	layout.Range = r.since(start)
+	return layout, nil
+}
+
+// DeserializeLayout decodes a transaction from b into the receiver in the same
+// manner as Deserialize while recording where each of its parts, such as every
+// input and script, is located within b.  This is useful for indexers, pruning
+// tools, and utilities which patch serialized transactions in place.
+func (msg *MsgTx) DeserializeLayout(b []byte) (*TxLayout, error) {
+	r := &layoutReader{Reader: bytes.NewReader(b), size: len(b)}
+	layout, err := readTxLayout(r, "MsgTx.DeserializeLayout", msg)
+	if err != nil {
+		return nil, err
+	}
+	return &layout, nil
+}
+
+// DeserializeLayout decodes a block from b into the receiver in the same manner
+// as Deserialize while recording where each of its parts, such as every
+// transaction, input, and script, is located within b.  It is a more detailed
+// version of DeserializeTxLoc.  Merged-mined blocks are not supported.
+func (msg *MsgBlock) DeserializeLayout(b []byte) (*BlockLayout, error) {
+	r := &layoutReader{Reader: bytes.NewReader(b), size: len(b)}
+	err := readBlockHeader(r, 0, &msg.Header)
+	if err != nil {
+		return nil, err
+	}
+	layout := &BlockLayout{
+		Header:  ByteRange{0, blockHashLen},
+		TxCount: r.since(blockHashLen),
+	}
+
+	// Prevent more transactions than could possibly fit into a block.
+	txCount := msg.Header.TxnCount
+	if txCount > maxTxPerBlock {
+		str := fmt.Sprintf("too many transactions to fit into a block "+
+			"[count %d, max %d]", txCount, maxTxPerBlock)
+		return nil, categorizedError("MsgBlock.DeserializeLayout", str,
+			ErrCategoryOversized)
+	}
+	err = checkCountFits(r, "MsgBlock.DeserializeLayout", "transactions",
+		txCount, minTxPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	msg.Transactions = make([]*MsgTx, 0, txCount)
+	layout.Tx = make([]TxLayout, 0, txCount)
+	for i := uint64(0); i < txCount; i++ {
+		tx := MsgTx{}
+		txLayout, err := readTxLayout(r, "MsgBlock.DeserializeLayout",
+			&tx)
+		if err != nil {
+			return nil, err
+		}
+		msg.Transactions = append(msg.Transactions, &tx)
+		layout.Tx = append(layout.Tx, txLayout)
+	}
+	return layout, nil
+}
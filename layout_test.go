@@ -0,0 +1,148 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// TestTxLayout tests the layout recorded when deserializing a transaction.
+func TestTxLayout(t *testing.T) {
+	var tx btcwire.MsgTx
+	layout, err := tx.DeserializeLayout(multiTxEncoded)
+	if err != nil {
+		t.Fatalf("DeserializeLayout: %v", err)
+	}
+	if !reflect.DeepEqual(&tx, multiTx) {
+		t.Errorf("DeserializeLayout\n got: %s want: %s", spew.Sdump(&tx),
+			spew.Sdump(multiTx))
+	}
+
+	want := &btcwire.TxLayout{
+		Range:   btcwire.ByteRange{Offset: 0, Len: len(multiTxEncoded)},
+		Version: btcwire.ByteRange{Offset: 0, Len: 4},
+		TxIn: []btcwire.TxInLayout{{
+			Range:            btcwire.ByteRange{Offset: 5, Len: 48},
+			PreviousOutpoint: btcwire.ByteRange{Offset: 5, Len: 36},
+			SignatureScript:  btcwire.ByteRange{Offset: 42, Len: 7},
+			Sequence:         btcwire.ByteRange{Offset: 49, Len: 4},
+		}},
+		TxOut: []btcwire.TxOutLayout{{
+			Range:    btcwire.ByteRange{Offset: 54, Len: 76},
+			Value:    btcwire.ByteRange{Offset: 54, Len: 8},
+			PkScript: btcwire.ByteRange{Offset: 63, Len: 67},
+		}},
+		LockTime: btcwire.ByteRange{Offset: 130, Len: 4},
+	}
+	if !reflect.DeepEqual(layout, want) {
+		t.Errorf("DeserializeLayout\n got: %s want: %s", spew.Sdump(layout),
+			spew.Sdump(want))
+	}
+
+	// The recorded ranges contain the scripts.
+	sigScript := layout.TxIn[0].SignatureScript
+	got := multiTxEncoded[sigScript.Offset : sigScript.Offset+sigScript.Len]
+	if !bytes.Equal(got, multiTx.TxIn[0].SignatureScript) {
+		t.Errorf("DeserializeLayout: wrong signature script range "+
+			"got: %x want: %x", got, multiTx.TxIn[0].SignatureScript)
+	}
+	pkScript := layout.TxOut[0].PkScript
+	got = multiTxEncoded[pkScript.Offset : pkScript.Offset+pkScript.Len]
+	if !bytes.Equal(got, multiTx.TxOut[0].PkScript) {
+		t.Errorf("DeserializeLayout: wrong public key script range "+
+			"got: %x want: %x", got, multiTx.TxOut[0].PkScript)
+	}
+}
+
+// TestBlockLayout tests the layout recorded when deserializing a block.
+func TestBlockLayout(t *testing.T) {
+	block := btcwire.NewMsgBlock(&blockOne.Header)
+	block.AddTransaction(blockOne.Transactions[0])
+	block.AddTransaction(multiTx)
+	block.Header.TxnCount = 2
+	serialized, err := block.SerializedBytes()
+	if err != nil {
+		t.Fatalf("SerializedBytes: %v", err)
+	}
+
+	var msg btcwire.MsgBlock
+	layout, err := msg.DeserializeLayout(serialized)
+	if err != nil {
+		t.Fatalf("DeserializeLayout: %v", err)
+	}
+	if !reflect.DeepEqual(msg.Transactions, block.Transactions) {
+		t.Errorf("DeserializeLayout\n got: %s want: %s",
+			spew.Sdump(msg.Transactions), spew.Sdump(block.Transactions))
+	}
+	if layout.Header != (btcwire.ByteRange{Offset: 0, Len: 80}) ||
+		layout.TxCount != (btcwire.ByteRange{Offset: 80, Len: 1}) {
+		t.Errorf("DeserializeLayout: wrong header ranges got: %v %v",
+			layout.Header, layout.TxCount)
+	}
+
+	// The transaction ranges match the locations from DeserializeTxLoc and
+	// every part is located relative to the start of the block.
+	var txLocBlock btcwire.MsgBlock
+	txLocs, err := txLocBlock.DeserializeTxLoc(bytes.NewBuffer(serialized))
+	if err != nil {
+		t.Fatalf("DeserializeTxLoc: %v", err)
+	}
+	if len(layout.Tx) != len(txLocs) {
+		t.Fatalf("DeserializeLayout: wrong number of transactions "+
+			"got: %d want: %d", len(layout.Tx), len(txLocs))
+	}
+	t.Logf("Running %d tests", len(txLocs))
+	for i, txLoc := range txLocs {
+		txRange := layout.Tx[i].Range
+		if txRange.Offset != txLoc.TxStart || txRange.Len != txLoc.TxLen {
+			t.Errorf("DeserializeLayout #%d wrong range got: %v "+
+				"want: %v", i, txRange, txLoc)
+		}
+		lockTime := layout.Tx[i].LockTime
+		if lockTime.Offset+lockTime.Len != txLoc.TxStart+txLoc.TxLen {
+			t.Errorf("DeserializeLayout #%d wrong lock time range "+
+				"got: %v", i, lockTime)
+		}
+	}
+}
+
+// TestLayoutErrors performs negative tests against deserializing with layouts
+// to confirm error paths work correctly.
+func TestLayoutErrors(t *testing.T) {
+	tests := []struct {
+		buf []byte // Serialized block
+		err error  // Expected error when not a MessageError
+	}{
+		{blockOneBytes[:40], io.ErrUnexpectedEOF},
+		{blockOneBytes[:80], io.EOF},
+		{blockOneBytes[:81], nil},
+		{blockOneBytes[:len(blockOneBytes)-2], io.ErrUnexpectedEOF},
+		// Transaction count which can't fit in the remaining bytes.
+		{append(append([]byte{}, blockOneBytes[:80]...), 0xfd, 0xff,
+			0x00), nil},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		var msg btcwire.MsgBlock
+		_, err := msg.DeserializeLayout(test.buf)
+		if test.err == nil {
+			if _, ok := err.(*btcwire.MessageError); !ok {
+				t.Errorf("DeserializeLayout #%d wrong error got: %v",
+					i, err)
+			}
+			continue
+		}
+		if err != test.err {
+			t.Errorf("DeserializeLayout #%d wrong error got: %v "+
+				"want: %v", i, err, test.err)
+		}
+	}
+}
@@ -8,103 +8,164 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"strings"
+	"sync"
 	"unicode/utf8"
 )
 
+// MessageHeaderSize is the number of bytes in a bitcoin message header.
+// Bitcoin network (magic) 4 bytes + command 12 bytes + payload length 4 bytes +
+// checksum 4 bytes.
+const MessageHeaderSize = 24
+
 // commandSize is the fixed size of all commands in the common bitcoin message
 // header.  Shorter commands must be zero padded.
 const commandSize = 12
 
-// maxMessagePayload is the maximum bytes a message can be regardless of other
-// individual limits imposed by messages themselves.
-const maxMessagePayload = (1024 * 1024 * 32) // 32MB
+// MaxMessagePayload is the maximum bytes a message payload can be regardless of
+// other individual limits imposed by messages themselves.
+const MaxMessagePayload = (1024 * 1024 * 32) // 32MB
 
 // Commands used in bitcoin message headers which describe the type of message.
+// These are the values returned by the Command method of the corresponding
+// messages, so they may be compared against it to determine the type of a
+// message without a type switch.
 const (
-	cmdVersion    = "version"
-	cmdVerAck     = "verack"
-	cmdGetAddr    = "getaddr"
-	cmdAddr       = "addr"
-	cmdGetBlocks  = "getblocks"
-	cmdInv        = "inv"
-	cmdGetData    = "getdata"
-	cmdNotFound   = "notfound"
-	cmdBlock      = "block"
-	cmdTx         = "tx"
-	cmdGetHeaders = "getheaders"
-	cmdHeaders    = "headers"
-	cmdPing       = "ping"
-	cmdPong       = "pong"
-	cmdAlert      = "alert"
-	cmdMemPool    = "mempool"
+	CmdVersion    = "version"
+	CmdVerAck     = "verack"
+	CmdGetAddr    = "getaddr"
+	CmdAddr       = "addr"
+	CmdGetBlocks  = "getblocks"
+	CmdInv        = "inv"
+	CmdGetData    = "getdata"
+	CmdNotFound   = "notfound"
+	CmdBlock      = "block"
+	CmdTx         = "tx"
+	CmdGetHeaders = "getheaders"
+	CmdHeaders    = "headers"
+	CmdPing       = "ping"
+	CmdPong       = "pong"
+	CmdAlert      = "alert"
+	CmdMemPool    = "mempool"
 )
 
 // Message is an interface that describes a bitcoin message.  A type that
 // implements Message has complete control over the representation of its data
 // and may therefore contain additional or fewer fields than those which
 // are used directly in the protocol encoded message.
+//
+// SerializeSize returns the exact size of the payload BtcEncode would produce
+// for the provided protocol version, which allows buffers to be sized before
+// encoding.  Validate returns an error for messages BtcEncode would reject
+// regardless of the protocol version, such as those with too many entries, so
+// outgoing messages can be checked before they are queued.
 type Message interface {
 	BtcDecode(io.Reader, uint32) error
 	BtcEncode(io.Writer, uint32) error
 	Command() string
 	MaxPayloadLength(uint32) uint32
+	SerializeSize(uint32) int
+	Validate() error
 }
 
 // makeEmptyMessage creates a message of the appropriate concrete type based
-// on the command.
+// on the command.  Messages of the high-frequency types which are pooled are
+// obtained from their respective pools so callers may release them once they
+// have been processed.
 func makeEmptyMessage(command string) (Message, error) {
 	var msg Message
 	switch command {
-	case cmdVersion:
+	case CmdVersion:
 		msg = &MsgVersion{}
 
-	case cmdVerAck:
+	case CmdVerAck:
 		msg = &MsgVerAck{}
 
-	case cmdGetAddr:
+	case CmdGetAddr:
 		msg = &MsgGetAddr{}
 
-	case cmdAddr:
+	case CmdAddr:
 		msg = &MsgAddr{}
 
-	case cmdGetBlocks:
+	case CmdGetBlocks:
 		msg = &MsgGetBlocks{}
 
-	case cmdBlock:
+	case CmdBlock:
 		msg = &MsgBlock{}
 
-	case cmdInv:
-		msg = &MsgInv{}
+	case CmdInv:
+		msg = AcquireMsgInv()
 
-	case cmdGetData:
-		msg = &MsgGetData{}
+	case CmdGetData:
+		msg = AcquireMsgGetData()
 
-	case cmdNotFound:
+	case CmdNotFound:
 		msg = &MsgNotFound{}
 
-	case cmdTx:
+	case CmdTx:
 		msg = &MsgTx{}
 
-	case cmdPing:
-		msg = &MsgPing{}
+	case CmdPing:
+		msg = AcquireMsgPing(0)
 
-	case cmdPong:
-		msg = &MsgPong{}
+	case CmdPong:
+		msg = AcquireMsgPong(0)
 
-	case cmdGetHeaders:
+	case CmdGetHeaders:
 		msg = &MsgGetHeaders{}
 
-	case cmdHeaders:
+	case CmdHeaders:
 		msg = &MsgHeaders{}
 
-	case cmdAlert:
+	case CmdAlert:
 		msg = &MsgAlert{}
 
-	case cmdMemPool:
+	case CmdMemPool:
 		msg = &MsgMemPool{}
 
 	default:
-		return nil, fmt.Errorf("unhandled command [%s]", command)
+		extraMessagesMtx.RLock()
+		fn := extraMessages[command]
+		extraMessagesMtx.RUnlock()
+		if fn == nil {
+			return nil, fmt.Errorf("unhandled command [%s]", command)
+		}
+		msg = fn()
+	}
+	return msg, nil
+}
+
+// extraMessages houses the constructors of the messages of optional protocol
+// extensions, such as Xtreme Thinblocks, which have been registered by their
+// respective registration functions, keyed by command.
+var (
+	extraMessagesMtx sync.RWMutex
+	extraMessages    = make(map[string]func() Message)
+)
+
+// registerMessage registers the constructor of the message with the provided
+// command so messages with the command are recognized when they are read.
+func registerMessage(command string, fn func() Message) {
+	extraMessagesMtx.Lock()
+	extraMessages[command] = fn
+	extraMessagesMtx.Unlock()
+}
+
+// NewMessageByCommand returns a new message of the concrete type which
+// corresponds to the provided command, such as a *MsgTx for CmdTx, with all of
+// its fields set to their zero values.  This allows a message to be created
+// and decoded with BtcDecode by command name without reading a message header.
+// A MessageError with the ErrCategoryUnknownCommand category is returned for
+// commands this package does not recognize.
+//
+// Messages of the types which are pooled are obtained from their respective
+// pools, so they may be released once they have been processed, although
+// doing so is not required.
+func NewMessageByCommand(command string) (Message, error) {
+	msg, err := makeEmptyMessage(command)
+	if err != nil {
+		return nil, categorizedError("NewMessageByCommand", err.Error(),
+			ErrCategoryUnknownCommand)
 	}
 	return msg, nil
 }
@@ -122,13 +183,26 @@ func readMessageHeader(r io.Reader) (*messageHeader, error) {
 	var command [commandSize]byte
 
 	hdr := messageHeader{}
-	err := readElements(r, &hdr.magic, &command, &hdr.length, &hdr.checksum)
+	magic, err := binarySerializer.Uint32(r, littleEndian)
+	if err != nil {
+		return nil, err
+	}
+	hdr.magic = BitcoinNet(magic)
+	_, err = io.ReadFull(r, command[:])
+	if err != nil {
+		return nil, err
+	}
+	hdr.length, err = binarySerializer.Uint32(r, littleEndian)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.ReadFull(r, hdr.checksum[:])
 	if err != nil {
 		return nil, err
 	}
 
 	// Strip trailing zeros from command string.
-	hdr.command = string(bytes.TrimRight(command[:], string(0)))
+	hdr.command = string(bytes.TrimRight(command[:], "\x00"))
 
 	return &hdr, nil
 }
@@ -153,39 +227,70 @@ func discardInput(r io.Reader, n uint32) {
 	}
 }
 
+// validateCommand returns an error attributed to the function f when the
+// provided command can't be encoded in a message header.  Commands are zero
+// padded in the header, so they must be between 1 and commandSize bytes long
+// and may only contain printable ASCII characters.  Otherwise the remote peer
+// would decode a different command than the one intended, or none at all.
+func validateCommand(f, cmd string) error {
+	if len(cmd) == 0 {
+		return messageError(f, "command is empty")
+	}
+	if len(cmd) > commandSize {
+		str := fmt.Sprintf("command [%s] is too long [max %v]",
+			cmd, commandSize)
+		return messageError(f, str)
+	}
+	for i := 0; i < len(cmd); i++ {
+		if cmd[i] < 0x20 || cmd[i] > 0x7e {
+			str := fmt.Sprintf("command %q contains non-printable "+
+				"character %#02x at position %d", cmd, cmd[i], i)
+			return messageError(f, str)
+		}
+	}
+	return nil
+}
+
 // WriteMessage writes a bitcoin Message to w including the necessary header
-// information.
+// information.  An error is returned when the command of the message is empty,
+// longer than 12 bytes, or contains characters other than printable ASCII.
 func WriteMessage(w io.Writer, msg Message, pver uint32, btcnet BitcoinNet) error {
 	var command [commandSize]byte
 
-	// Enforce max command size.
+	// Ensure the command can be represented in the header.
 	cmd := msg.Command()
-	if len(cmd) > commandSize {
-		str := fmt.Sprintf("command [%s] is too long [max %v]",
-			cmd, commandSize)
-		return messageError("WriteMessage", str)
+	err := validateCommand("WriteMessage", cmd)
+	if err != nil {
+		return err
 	}
 	copy(command[:], []byte(cmd))
 
-	// Encode the message payload.
+	// Encode the message payload into a buffer which has space reserved at
+	// the front for the message header.  This allows the header and
+	// payload to be flushed to the writer with a single call which avoids
+	// an extra syscall per message and poor interactions with Nagle's
+	// algorithm when w is a network connection.
+	var hdrSpace [MessageHeaderSize]byte
 	var bw bytes.Buffer
-	err := msg.BtcEncode(&bw, pver)
+	bw.Write(hdrSpace[:])
+	err = msg.BtcEncode(&bw, pver)
 	if err != nil {
 		return err
 	}
-	payload := bw.Bytes()
+	rawMsg := bw.Bytes()
+	payload := rawMsg[MessageHeaderSize:]
 	lenp := len(payload)
 
 	// Enforce maximum overall message payload.
-	if lenp > maxMessagePayload {
+	if lenp > MaxMessagePayload {
 		str := fmt.Sprintf("message payload is too large - encoded "+
 			"%d bytes, but maximum message payload is %d bytes",
-			lenp, maxMessagePayload)
+			lenp, MaxMessagePayload)
 		return messageError("WriteMessage", str)
 	}
 
 	// Enforce maximum message payload based on the message type.
-	mpl := msg.MaxPayloadLength(pver)
+	mpl := maxPayloadLength(msg, pver, btcnet, nil)
 	if uint32(lenp) > mpl {
 		str := fmt.Sprintf("message payload is too large - encoded "+
 			"%d bytes, but maximum message payload size for "+
@@ -193,41 +298,201 @@ func WriteMessage(w io.Writer, msg Message, pver uint32, btcnet BitcoinNet) erro
 		return messageError("WriteMessage", str)
 	}
 
-	// Create header for the message.
-	hdr := messageHeader{}
-	hdr.magic = btcnet
-	hdr.command = cmd
-	hdr.length = uint32(lenp)
-	copy(hdr.checksum[:], DoubleSha256(payload)[0:4])
+	// Fill in the header for the message in the reserved space.
+	littleEndian.PutUint32(rawMsg[0:4], uint32(btcnet))
+	copy(rawMsg[4:4+commandSize], command[:])
+	littleEndian.PutUint32(rawMsg[16:20], uint32(lenp))
+	copy(rawMsg[20:24], DoubleSha256(payload)[0:4])
 
-	// Write header.
-	err = writeElements(w, hdr.magic, command, hdr.length, hdr.checksum)
+	// Write header and payload.
+	_, err = w.Write(rawMsg)
 	if err != nil {
 		return err
 	}
+	return nil
+}
 
-	// Write payload.
-	_, err = w.Write(payload)
-	if err != nil {
-		return err
+// maxPayloadLength returns the maximum length the payload of msg can be for the
+// provided protocol version, bitcoin network, and read options.  Block headers
+// on networks which support merged mining carry an auxiliary proof-of-work
+// which is not accounted for by the limits of the block and headers messages,
+// so they are only limited by the maximum overall message payload.  A nil opts
+// is treated the same as the zero value.
+func maxPayloadLength(msg Message, pver uint32, btcnet BitcoinNet, opts *ReadOptions) uint32 {
+	switch msg.(type) {
+	case *MsgBlock, *MsgHeaders:
+		if IsAuxPowNet(btcnet) {
+			return MaxMessagePayload
+		}
 	}
-	return nil
+
+	mpl := msg.MaxPayloadLength(pver)
+	if _, ok := msg.(*MsgBlock); ok && opts != nil {
+		if opts.MaxBlockPayload > mpl {
+			mpl = opts.MaxBlockPayload
+		}
+	}
+	return mpl
+}
+
+// payloadReader wraps the reader a message payload is decoded from in order to
+// provide decoding parameters which depend on the bitcoin network or read
+// options since they are not part of the BtcDecode signature.
+type payloadReader struct {
+	io.Reader
+
+	// auxPow indicates block headers belong to a network which supports
+	// merged mining.  See RegisterAuxPowNet.
+	auxPow bool
+
+	// maxBlockPayload overrides MaxBlockPayload when it is larger.  See
+	// ReadOptions.
+	maxBlockPayload uint32
+
+	// maxScriptSize overrides MaxScriptSize when it is non-zero.  See
+	// ReadOptions.
+	maxScriptSize uint32
+
+	// maxAlloc is the decode allocation budget when it is non-zero, and
+	// allocated is the number of bytes charged to it so far.  See
+	// ReadOptions and reserveAlloc.
+	maxAlloc  uint64
+	allocated uint64
+}
+
+// ReadOptions houses optional behavior for reading messages via
+// ReadMessageWithOptions.  The zero value results in the same behavior as
+// ReadMessage.
+type ReadOptions struct {
+	// AllowUnknown causes messages with well-formed, but unrecognized,
+	// commands to be returned as a MsgUnknown containing the command and
+	// raw payload rather than failing with a MessageError.
+	AllowUnknown bool
+
+	// MaxBlockPayload raises the maximum payload allowed for block
+	// messages above MaxBlockPayload when it is larger.  The maximum
+	// number of transactions allowed in a block is scaled accordingly.
+	// This is intended for networks which allow larger blocks than
+	// bitcoin.  Values beyond the maximum overall message payload of 32MB
+	// are limited to it.
+	MaxBlockPayload uint32
+
+	// RejectTrailingBytes causes messages whose payload is not entirely
+	// consumed when decoding the message to be rejected with a
+	// MessageError with the ErrCategoryMalformed category.  Otherwise any
+	// bytes following the encoded message in the payload are ignored.
+	RejectTrailingBytes bool
+
+	// MaxScriptSize overrides the maximum size of the signature scripts
+	// and public key scripts of decoded transactions, which defaults to
+	// MaxScriptSize, when it is non-zero.  Values beyond the maximum
+	// overall message payload of 32MB are limited to it.
+	MaxScriptSize uint32
+
+	// AllowCompressed causes compressed messages written by
+	// WriteMessageCompressed to be decompressed and returned as the
+	// message they wrap.  Otherwise they are treated as messages with an
+	// unrecognized command.  It should only be set for peers which have
+	// negotiated compression, such as via SFNodeCompression.
+	AllowCompressed bool
+
+	// AnnotateDecodeErrors causes errors which occur while decoding the
+	// payload of a message to be returned as a DecodeError identifying the
+	// offset within the payload and the field at which decoding failed.
+	// Otherwise they are returned as is, such as io.ErrUnexpectedEOF for a
+	// truncated payload, which gives no indication of where in the payload
+	// the problem is.
+	AnnotateDecodeErrors bool
+
+	// MaxDecodeAlloc limits the total number of bytes allocated for the
+	// scripts, strings, and lists of a message while decoding it when it
+	// is non-zero.  Messages which would exceed it are rejected with a
+	// MessageError with the ErrCategoryOversized category before the
+	// allocation is made.  Every list and variable length field is already
+	// limited by the payload length, but since a decoded element can take
+	// several times the memory of its encoding, such as a transaction
+	// input with an empty script, a message crafted to consist of many of
+	// them can use considerably more memory than its payload.  The budget
+	// bounds that regardless of the structure of the message.
+	MaxDecodeAlloc uint32
+
+	// RejectAlerts causes alert messages (MsgAlert) other than the final
+	// alert to be rejected with a MessageError with the
+	// ErrCategoryDeprecated category, matching the retirement of the alert
+	// system by the network.  Otherwise alerts are decoded like any other
+	// message, which is necessary to parse archived traffic.  See
+	// FinalAlertPayloadBlob.
+	RejectAlerts bool
 }
 
 // ReadMessage reads, validates, and parses the next bitcoin Message from r for
 // the provided protocol version and bitcoin network.
+//
+// The entire payload is consumed from r before its checksum is verified, so
+// when a MessageError with the ErrCategoryChecksum category is returned, r is
+// positioned at the start of the next message.  This allows callers to skip
+// the corrupt message and keep the connection alive if their policy permits.
 func ReadMessage(r io.Reader, pver uint32, btcnet BitcoinNet) (Message, []byte, error) {
+	return readMessage(r, pver, btcnet, nil, nil)
+}
+
+// ReadMessageWithOptions reads, validates, and parses the next bitcoin Message
+// from r for the provided protocol version and bitcoin network in the same
+// manner as ReadMessage, except the behavior may be modified by the provided
+// options.  See ReadOptions for details.  A nil opts is treated the same as
+// the zero value.
+func ReadMessageWithOptions(r io.Reader, pver uint32, btcnet BitcoinNet, opts *ReadOptions) (Message, []byte, error) {
+	return readMessage(r, pver, btcnet, nil, opts)
+}
+
+// ReadMessageBuf reads, validates, and parses the next bitcoin Message from r
+// for the provided protocol version and bitcoin network in the same manner as
+// ReadMessage, except the raw payload is read into buf when it has enough
+// capacity.  A new buffer is only allocated when buf is too small to hold the
+// payload.
+//
+// The returned payload slice shares memory with buf (or the newly allocated
+// buffer), so callers in a receive loop should pass the returned slice back
+// in as buf on the next call to avoid allocating a payload buffer per
+// message.  The contents of the returned payload are only valid until the
+// buffer is reused.  The decoded message does not reference the buffer.
+func ReadMessageBuf(r io.Reader, pver uint32, btcnet BitcoinNet, buf []byte) (Message, []byte, error) {
+	return readMessage(r, pver, btcnet, buf, nil)
+}
+
+// readMessage is the shared implementation of the various message reading
+// functions.  The payload is read into buf when it has enough capacity,
+// otherwise a new buffer is allocated.  A nil opts is treated the same as the
+// zero value.
+func readMessage(r io.Reader, pver uint32, btcnet BitcoinNet, buf []byte, opts *ReadOptions) (Message, []byte, error) {
+	if opts == nil {
+		opts = &ReadOptions{}
+	}
+	if opts.MaxBlockPayload > MaxMessagePayload ||
+		opts.MaxScriptSize > MaxMessagePayload {
+
+		o := *opts
+		if o.MaxBlockPayload > MaxMessagePayload {
+			o.MaxBlockPayload = MaxMessagePayload
+		}
+		if o.MaxScriptSize > MaxMessagePayload {
+			o.MaxScriptSize = MaxMessagePayload
+		}
+		opts = &o
+	}
+
 	hdr, err := readMessageHeader(r)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	// Enforce maximum message payload.
-	if hdr.length > maxMessagePayload {
+	if hdr.length > MaxMessagePayload {
 		str := fmt.Sprintf("message payload is too large - header "+
 			"indicates %d bytes, but max message payload is %d "+
-			"bytes.", hdr.length, maxMessagePayload)
-		return nil, nil, messageError("ReadMessage", str)
+			"bytes.", hdr.length, MaxMessagePayload)
+		return nil, nil, categorizedError("ReadMessage", str,
+			ErrCategoryOversized)
 
 	}
 
@@ -235,7 +500,8 @@ func ReadMessage(r io.Reader, pver uint32, btcnet BitcoinNet) (Message, []byte,
 	if hdr.magic != btcnet {
 		discardInput(r, hdr.length)
 		str := fmt.Sprintf("message from other network [%v]", hdr.magic)
-		return nil, nil, messageError("ReadMessage", str)
+		return nil, nil, categorizedError("ReadMessage", str,
+			ErrCategoryWrongNetwork)
 	}
 
 	// Check for malformed commands.
@@ -243,30 +509,61 @@ func ReadMessage(r io.Reader, pver uint32, btcnet BitcoinNet) (Message, []byte,
 	if !utf8.ValidString(command) {
 		discardInput(r, hdr.length)
 		str := fmt.Sprintf("invalid command %v", []byte(command))
-		return nil, nil, messageError("ReadMessage", str)
+		return nil, nil, categorizedError("ReadMessage", str,
+			ErrCategoryMalformed)
+	}
+
+	// Reject commands which are followed by anything other than zero
+	// padding.  Since trailing zeros have already been stripped, any zero
+	// left in the command indicates non-zero bytes after the terminator.
+	if strings.IndexByte(command, 0x00) != -1 {
+		discardInput(r, hdr.length)
+		str := fmt.Sprintf("command %q has non-zero bytes after its "+
+			"terminating zero", command)
+		return nil, nil, categorizedError("ReadMessage", str,
+			ErrCategoryMalformed)
+	}
+
+	// Compressed messages wrap the command and payload of another message,
+	// so they are handled separately when accepted.  See
+	// WriteMessageCompressed.
+	if command == CmdCompressed && opts.AllowCompressed {
+		return readCompressedMessage(r, hdr, pver, btcnet, opts)
 	}
 
 	// Create struct of appropriate message type based on the command.
+	// Unrecognized commands are passed through as a MsgUnknown when
+	// requested.
 	msg, err := makeEmptyMessage(command)
 	if err != nil {
-		discardInput(r, hdr.length)
-		return nil, nil, messageError("ReadMessage", err.Error())
+		if !opts.AllowUnknown {
+			discardInput(r, hdr.length)
+			return nil, nil, categorizedError("ReadMessage",
+				err.Error(), ErrCategoryUnknownCommand)
+		}
+		msg = &MsgUnknown{command: command}
 	}
 
 	// Check for maximum length based on the message type as a malicious client
 	// could otherwise create a well-formed header and set the length to max
 	// numbers in order to exhaust the machine's memory.
-	mpl := msg.MaxPayloadLength(pver)
+	mpl := maxPayloadLength(msg, pver, btcnet, opts)
 	if hdr.length > mpl {
 		discardInput(r, hdr.length)
 		str := fmt.Sprintf("payload exceeds max length - header "+
 			"indicates %v bytes, but max payload size for "+
 			"messages of type [%v] is %v.", hdr.length, command, mpl)
-		return nil, nil, messageError("ReadMessage", str)
+		return nil, nil, categorizedError("ReadMessage", str,
+			ErrCategoryOversized)
 	}
 
-	// Read payload.
-	payload := make([]byte, hdr.length)
+	// Read payload into the provided buffer when it is large enough.
+	var payload []byte
+	if uint32(cap(buf)) >= hdr.length {
+		payload = buf[:hdr.length]
+	} else {
+		payload = make([]byte, hdr.length)
+	}
 	_, err = io.ReadFull(r, payload)
 	if err != nil {
 		return nil, nil, err
@@ -278,15 +575,100 @@ func ReadMessage(r io.Reader, pver uint32, btcnet BitcoinNet) (Message, []byte,
 		str := fmt.Sprintf("payload checksum failed - header "+
 			"indicates %v, but actual checksum is %v.",
 			hdr.checksum, checksum)
-		return nil, nil, messageError("ReadMessage", str)
+		return nil, nil, categorizedError("ReadMessage", str,
+			ErrCategoryChecksum)
 	}
 
 	// Unmarshal message.
-	pr := bytes.NewBuffer(payload)
-	err = msg.BtcDecode(pr, pver)
+	err = decodePayload(msg, payload, pver, btcnet, opts)
 	if err != nil {
 		return nil, nil, err
 	}
-
 	return msg, payload, nil
 }
+
+// decodePayload decodes the provided payload into msg for the provided protocol
+// version, bitcoin network, and read options, which must not be nil.
+func decodePayload(msg Message, payload []byte, pver uint32, btcnet BitcoinNet, opts *ReadOptions) error {
+	br := bytes.NewReader(payload)
+	pr := &payloadReader{
+		Reader:          br,
+		auxPow:          IsAuxPowNet(btcnet),
+		maxBlockPayload: opts.MaxBlockPayload,
+		maxScriptSize:   opts.MaxScriptSize,
+		maxAlloc:        uint64(opts.MaxDecodeAlloc),
+	}
+	err := msg.BtcDecode(pr, pver)
+	if err != nil {
+		if opts.AnnotateDecodeErrors {
+			offset := len(payload) - br.Len()
+			truncated := err == io.EOF || err == io.ErrUnexpectedEOF
+			field := fieldAt(msg.Command(), payload, offset, truncated)
+			return &DecodeError{
+				Command: msg.Command(),
+				Offset:  offset,
+				Field:   field,
+				Err:     err,
+			}
+		}
+		return err
+	}
+
+	// Reject payloads with bytes after the encoded message when requested.
+	if opts.RejectTrailingBytes && br.Len() > 0 {
+		str := fmt.Sprintf("payload for messages of type [%v] has %d "+
+			"trailing bytes after the %d byte message",
+			msg.Command(), br.Len(), len(payload)-br.Len())
+		return categorizedError("ReadMessage", str,
+			ErrCategoryMalformed)
+	}
+
+	// Reject alerts other than the final alert when requested.
+	if alert, ok := msg.(*MsgAlert); ok && opts.RejectAlerts &&
+		!alert.IsFinalAlert() {
+
+		return categorizedError("ReadMessage", "alert messages other "+
+			"than the final alert are no longer accepted",
+			ErrCategoryDeprecated)
+	}
+	return nil
+}
+
+// DecodeAll decodes as many complete messages as possible from buf for the
+// provided protocol version and bitcoin network.  It returns the decoded
+// messages in order along with any trailing bytes which do not yet form a
+// complete message, such as a partial header or payload at the end of a
+// buffered read.  The trailing bytes share memory with buf.
+//
+// Decoding stops at the first message which fails to decode, in which case
+// the messages decoded prior to it are returned along with the error and the
+// remaining bytes, starting with the failed message.
+func DecodeAll(buf []byte, pver uint32, btcnet BitcoinNet) ([]Message, []byte, error) {
+	var msgs []Message
+	for len(buf) >= MessageHeaderSize {
+		// Stop when the full payload is not available yet.  Headers
+		// which claim a payload larger than the max allowed are handed
+		// to ReadMessage right away so the error is reported instead of
+		// waiting for data which will never be valid.
+		plen := littleEndian.Uint32(buf[16:20])
+		if plen > MaxMessagePayload {
+			_, _, err := ReadMessage(bytes.NewReader(buf), pver,
+				btcnet)
+			return msgs, buf, err
+		}
+		msgLen := MessageHeaderSize + int(plen)
+		if len(buf) < msgLen {
+			break
+		}
+
+		msg, _, err := ReadMessage(bytes.NewReader(buf[:msgLen]), pver,
+			btcnet)
+		if err != nil {
+			return msgs, buf, err
+		}
+		msgs = append(msgs, msg)
+		buf = buf[msgLen:]
+	}
+
+	return msgs, buf, nil
+}
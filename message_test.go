@@ -12,6 +12,7 @@ import (
 	"io"
 	"net"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -51,7 +52,11 @@ func TestMessage(t *testing.T) {
 		t.Errorf("NewNetAddress: %v", err)
 	}
 	me.Timestamp = time.Time{} // Version message has zero value timestamp.
-	msgVersion := btcwire.NewMsgVersion(me, you, 123123, "/test:0.0.1/", 0)
+	msgVersion, err := btcwire.NewMsgVersion(me, you, 123123,
+		"/test:0.0.1/", 0)
+	if err != nil {
+		t.Errorf("NewMsgVersion: %v", err)
+	}
 
 	msgVerack := btcwire.NewMsgVerAck()
 	msgGetAddr := btcwire.NewMsgGetAddr()
@@ -66,7 +71,10 @@ func TestMessage(t *testing.T) {
 	msgPong := btcwire.NewMsgPong(123123)
 	msgGetHeaders := btcwire.NewMsgGetHeaders()
 	msgHeaders := btcwire.NewMsgHeaders()
-	msgAlert := btcwire.NewMsgAlert("payload", "signature")
+	msgAlert, err := btcwire.NewMsgAlert("payload", "signature")
+	if err != nil {
+		t.Errorf("NewMsgAlert: %v", err)
+	}
 	msgMemPool := btcwire.NewMsgMemPool()
 
 	tests := []struct {
@@ -112,13 +120,198 @@ func TestMessage(t *testing.T) {
 			continue
 		}
 		if !reflect.DeepEqual(msg, test.out) {
-			t.Errorf("ReadMessage #%d\n got: %v want: %v", i,
-				spew.Sdump(msg), spew.Sdump(test.out))
+			t.Errorf("ReadMessage #%d\n%v", i,
+				btcwire.Diff(test.out, msg))
 			continue
 		}
 	}
 }
 
+// TestCommandConstants ensures the exported command constants match the
+// commands of their respective messages and the strings used on the wire.
+func TestCommandConstants(t *testing.T) {
+	tests := []struct {
+		msg  btcwire.Message // Message to get the command of
+		cmd  string          // Exported command constant
+		want string          // Command on the wire
+	}{
+		{&btcwire.MsgVersion{}, btcwire.CmdVersion, "version"},
+		{&btcwire.MsgVerAck{}, btcwire.CmdVerAck, "verack"},
+		{&btcwire.MsgGetAddr{}, btcwire.CmdGetAddr, "getaddr"},
+		{&btcwire.MsgAddr{}, btcwire.CmdAddr, "addr"},
+		{&btcwire.MsgGetBlocks{}, btcwire.CmdGetBlocks, "getblocks"},
+		{&btcwire.MsgInv{}, btcwire.CmdInv, "inv"},
+		{&btcwire.MsgGetData{}, btcwire.CmdGetData, "getdata"},
+		{&btcwire.MsgNotFound{}, btcwire.CmdNotFound, "notfound"},
+		{&btcwire.MsgBlock{}, btcwire.CmdBlock, "block"},
+		{&btcwire.MsgTx{}, btcwire.CmdTx, "tx"},
+		{&btcwire.MsgGetHeaders{}, btcwire.CmdGetHeaders, "getheaders"},
+		{&btcwire.MsgHeaders{}, btcwire.CmdHeaders, "headers"},
+		{&btcwire.MsgPing{}, btcwire.CmdPing, "ping"},
+		{&btcwire.MsgPong{}, btcwire.CmdPong, "pong"},
+		{&btcwire.MsgAlert{}, btcwire.CmdAlert, "alert"},
+		{&btcwire.MsgMemPool{}, btcwire.CmdMemPool, "mempool"},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		if test.cmd != test.want {
+			t.Errorf("command constant #%d got: %q want: %q", i,
+				test.cmd, test.want)
+		}
+		if cmd := test.msg.Command(); cmd != test.cmd {
+			t.Errorf("Command #%d got: %q want: %q", i, cmd,
+				test.cmd)
+		}
+	}
+}
+
+// TestNewMessageByCommand ensures NewMessageByCommand creates an empty message
+// of the correct type for every known command and rejects unknown commands.
+func TestNewMessageByCommand(t *testing.T) {
+	msgs := snapshotMessages()
+	t.Logf("Running %d tests", len(msgs))
+	for i, want := range msgs {
+		msg, err := btcwire.NewMessageByCommand(want.Command())
+		if err != nil {
+			t.Errorf("NewMessageByCommand #%d (%s) error %v", i,
+				want.Command(), err)
+			continue
+		}
+		if reflect.TypeOf(msg) != reflect.TypeOf(want) {
+			t.Errorf("NewMessageByCommand #%d (%s) wrong type got: "+
+				"%T want: %T", i, want.Command(), msg, want)
+		}
+	}
+
+	_, err := btcwire.NewMessageByCommand("bogus")
+	merr, ok := err.(*btcwire.MessageError)
+	if !ok || merr.Category != btcwire.ErrCategoryUnknownCommand {
+		t.Errorf("NewMessageByCommand: wrong error for unknown "+
+			"command got: %T(%v)", err, err)
+	}
+}
+
+// TestMessageSerializeSize ensures SerializeSize reports the exact size of the
+// payload produced by BtcEncode for every message at every protocol version
+// the message can be encoded with.
+func TestMessageSerializeSize(t *testing.T) {
+	pvers := []uint32{
+		btcwire.ProtocolVersion,
+		btcwire.BIP0035Version,
+		btcwire.BIP0031Version,
+		btcwire.NetAddressTimeVersion,
+		btcwire.MultipleAddressVersion,
+	}
+
+	msgs := snapshotMessages()
+	t.Logf("Running %d tests", len(msgs)*len(pvers))
+	for _, msg := range msgs {
+		if err := msg.Validate(); err != nil {
+			t.Errorf("Validate (%s) error %v", msg.Command(), err)
+		}
+		for _, pver := range pvers {
+			var buf bytes.Buffer
+			err := msg.BtcEncode(&buf, pver)
+			if err != nil {
+				// Not all messages are valid at all protocol
+				// versions.
+				continue
+			}
+			if size := msg.SerializeSize(pver); size != buf.Len() {
+				t.Errorf("SerializeSize (%s, pver %d) got: %d "+
+					"want: %d", msg.Command(), pver, size,
+					buf.Len())
+			}
+		}
+	}
+}
+
+// TestMessageValidate ensures Validate rejects messages which BtcEncode would
+// reject regardless of the protocol version.
+func TestMessageValidate(t *testing.T) {
+	tooManyInv := btcwire.NewMsgInv()
+	tooManyInv.InvList = make([]*btcwire.InvVect, btcwire.MaxInvPerMsg+1)
+
+	tooManyAddr := btcwire.NewMsgAddr()
+	tooManyAddr.AddrList = make([]*btcwire.NetAddress,
+		btcwire.MaxAddrPerMsg+1)
+
+	tooManyLocators := btcwire.NewMsgGetBlocks(&btcwire.ShaHash{})
+	tooManyLocators.BlockLocatorHashes = make([]*btcwire.ShaHash,
+		btcwire.MaxBlockLocatorsPerMsg+1)
+
+	header := btcwire.GenesisBlock.Header
+	headersWithTxns := btcwire.NewMsgHeaders()
+	headersWithTxns.AddBlockHeader(&header)
+
+	bigScript := btcwire.NewMsgTx()
+	bigScript.AddTxOut(btcwire.NewTxOut(0,
+		make([]byte, btcwire.MaxScriptSize+1)))
+
+	blockWithBigScript := btcwire.NewMsgBlock(&btcwire.GenesisBlock.Header)
+	blockWithBigScript.AddTransaction(bigScript)
+
+	longUA := *baseVersion
+	longUA.UserAgent = strings.Repeat("a", btcwire.MaxUserAgentLen+1)
+
+	tests := []struct {
+		name string          // Description of the test
+		msg  btcwire.Message // Message to validate
+	}{
+		{"too many inventory vectors", tooManyInv},
+		{"too many addresses", tooManyAddr},
+		{"too many block locators", tooManyLocators},
+		{"headers with transactions", headersWithTxns},
+		{"oversized script", bigScript},
+		{"block with oversized script", blockWithBigScript},
+		{"long user agent", &longUA},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		err := test.msg.Validate()
+		if _, ok := err.(*btcwire.MessageError); !ok {
+			t.Errorf("Validate #%d (%s) wrong error got: %T(%v)", i,
+				test.name, err, err)
+		}
+	}
+}
+
+// TestMessageEqual ensures every message is equal to an identical copy of
+// itself but not to an empty message of the same type or to nil.
+func TestMessageEqual(t *testing.T) {
+	msgs := snapshotMessages()
+	copies := snapshotMessages()
+
+	t.Logf("Running %d tests", len(msgs))
+	for i, msg := range msgs {
+		// Equal takes the concrete message type, so it is called via
+		// reflection.
+		equal := func(other reflect.Value) bool {
+			m := reflect.ValueOf(msg).MethodByName("Equal")
+			return m.Call([]reflect.Value{other})[0].Bool()
+		}
+
+		typ := reflect.TypeOf(msg)
+		if !equal(reflect.ValueOf(copies[i])) {
+			t.Errorf("Equal (%s) not equal to copy", msg.Command())
+		}
+		if equal(reflect.Zero(typ)) {
+			t.Errorf("Equal (%s) equal to nil", msg.Command())
+		}
+
+		// Messages with no fields are always equal to empty messages.
+		empty := reflect.New(typ.Elem())
+		wantEmpty := typ.Elem().NumField() == 0
+		if equal(empty) != wantEmpty {
+			t.Errorf("Equal (%s) wrong result for empty message "+
+				"got: %v want: %v", msg.Command(), !wantEmpty,
+				wantEmpty)
+		}
+	}
+}
+
 // TestReadMessageWireErrors performs negative tests against wire decoding into
 // concrete messages to confirm error paths work correctly.
 func TestReadMessageWireErrors(t *testing.T) {
@@ -146,7 +339,7 @@ func TestReadMessageWireErrors(t *testing.T) {
 
 	// Wire encoded bytes for a message that exceeds max overall message
 	// length.
-	mpl := btcwire.MaxMessagePayload
+	mpl := uint32(btcwire.MaxMessagePayload)
 	exceedMaxPayloadBytes := makeHeader(btcnet, "getaddr", mpl+1, 0)
 
 	// Wire encoded bytes for a command which is invalid utf-8.
@@ -261,13 +454,15 @@ func TestReadMessageWireErrors(t *testing.T) {
 			&btcwire.MessageError{},
 		},
 
-		// Message with a valid header, but wrong format.
+		// Message with a valid header, but wrong format.  The claimed
+		// addresses can't fit in the payload, so decoding fails before
+		// attempting to read them.
 		{
 			badMessageBytes,
 			pver,
 			btcnet,
 			len(badMessageBytes),
-			io.EOF,
+			&btcwire.MessageError{},
 		},
 
 		// 15k bytes of data to discard.
@@ -314,16 +509,24 @@ func TestWriteMessageWireErrors(t *testing.T) {
 	// Fake message with a command that is too long.
 	badCommandMsg := &fakeMessage{command: "somethingtoolong"}
 
+	// Fake messages with commands which are empty or contain characters
+	// which are not printable ASCII.
+	emptyCommandMsg := &fakeMessage{command: ""}
+	nulCommandMsg := &fakeMessage{command: "bad\x00cmd"}
+	nonASCIICommandMsg := &fakeMessage{command: "bad\xffcmd"}
+
 	// Fake message with a problem during encoding
-	encodeErrMsg := &fakeMessage{forceEncodeErr: true}
+	encodeErrMsg := &fakeMessage{command: "fake", forceEncodeErr: true}
 
 	// Fake message that has payload which exceeds max overall message size.
 	exceedOverallPayload := make([]byte, btcwire.MaxMessagePayload+1)
-	exceedOverallPayloadErrMsg := &fakeMessage{payload: exceedOverallPayload}
+	exceedOverallPayloadErrMsg := &fakeMessage{command: "fake",
+		payload: exceedOverallPayload}
 
 	// Fake message that has payload which exceeds max allowed per message.
 	exceedPayload := make([]byte, 1)
-	exceedPayloadErrMsg := &fakeMessage{payload: exceedPayload, forceLenErr: true}
+	exceedPayloadErrMsg := &fakeMessage{command: "fake",
+		payload: exceedPayload, forceLenErr: true}
 
 	// Fake message that is used to force errors in the header and payload
 	// writes.
@@ -339,15 +542,21 @@ func TestWriteMessageWireErrors(t *testing.T) {
 	}{
 		// Command too long.
 		{badCommandMsg, pver, btcnet, 0, btcwireErr},
+		// Command empty.
+		{emptyCommandMsg, pver, btcnet, 0, btcwireErr},
+		// Command with embedded zero byte.
+		{nulCommandMsg, pver, btcnet, 0, btcwireErr},
+		// Command with non-ASCII byte.
+		{nonASCIICommandMsg, pver, btcnet, 0, btcwireErr},
 		// Force error in payload encode.
 		{encodeErrMsg, pver, btcnet, 0, btcwireErr},
 		// Force error due to exceeding max overall message payload size.
 		{exceedOverallPayloadErrMsg, pver, btcnet, 0, btcwireErr},
 		// Force error due to exceeding max payload for message type.
 		{exceedPayloadErrMsg, pver, btcnet, 0, btcwireErr},
-		// Force error in header write.
+		// Force error in write with no room for the header.
 		{bogusMsg, pver, btcnet, 0, io.ErrShortWrite},
-		// Force error in payload write.
+		// Force error in write with room for the header only.
 		{bogusMsg, pver, btcnet, 24, io.ErrShortWrite},
 	}
 
@@ -374,3 +583,562 @@ func TestWriteMessageWireErrors(t *testing.T) {
 		}
 	}
 }
+
+// countingWriter implements the io.Writer interface and records the number of
+// calls made to Write along with the bytes written.
+type countingWriter struct {
+	bytes.Buffer
+	calls int
+}
+
+// Write records the call and appends p to the underlying buffer.
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.calls++
+	return w.Buffer.Write(p)
+}
+
+// TestWriteMessageSingleWrite ensures WriteMessage flushes the header and
+// payload of a message to the writer with a single call.
+func TestWriteMessageSingleWrite(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcnet := btcwire.MainNet
+
+	msgs := []btcwire.Message{
+		btcwire.NewMsgVerAck(),
+		btcwire.NewMsgPing(123123),
+		&blockOne,
+	}
+
+	t.Logf("Running %d tests", len(msgs))
+	for i, msg := range msgs {
+		var w countingWriter
+		err := btcwire.WriteMessage(&w, msg, pver, btcnet)
+		if err != nil {
+			t.Errorf("WriteMessage #%d error %v", i, err)
+			continue
+		}
+		if w.calls != 1 {
+			t.Errorf("WriteMessage #%d wrong number of writes - "+
+				"got %d, want 1", i, w.calls)
+			continue
+		}
+
+		// Ensure the single write is a valid message.
+		_, _, err = btcwire.ReadMessage(&w.Buffer, pver, btcnet)
+		if err != nil {
+			t.Errorf("ReadMessage #%d error %v", i, err)
+			continue
+		}
+	}
+}
+
+// TestReadMessageBuf tests that ReadMessageBuf decodes messages the same as
+// ReadMessage while reusing the provided payload buffer when it is large
+// enough.
+func TestReadMessageBuf(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcnet := btcwire.MainNet
+
+	msgs := []btcwire.Message{
+		btcwire.NewMsgPing(123123),
+		btcwire.NewMsgVerAck(),
+		&blockOne,
+		btcwire.NewMsgPong(456456),
+	}
+
+	var stream bytes.Buffer
+	for i, msg := range msgs {
+		err := btcwire.WriteMessage(&stream, msg, pver, btcnet)
+		if err != nil {
+			t.Fatalf("WriteMessage #%d error %v", i, err)
+		}
+	}
+
+	buf := make([]byte, 0, 16)
+	t.Logf("Running %d tests", len(msgs))
+	for i, want := range msgs {
+		msg, payload, err := btcwire.ReadMessageBuf(&stream, pver,
+			btcnet, buf)
+		if err != nil {
+			t.Errorf("ReadMessageBuf #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(msg, want) {
+			t.Errorf("ReadMessageBuf #%d\n got: %v want: %v", i,
+				spew.Sdump(msg), spew.Sdump(want))
+			continue
+		}
+
+		// Ensure the buffer is reused when it has enough capacity.
+		if len(payload) <= cap(buf) && len(payload) > 0 &&
+			&payload[0] != &buf[:1][0] {
+			t.Errorf("ReadMessageBuf #%d did not reuse buffer", i)
+			continue
+		}
+		buf = payload
+	}
+}
+
+// TestReadMessageErrorCategories ensures errors returned while reading
+// messages are categorized as expected.
+func TestReadMessageErrorCategories(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcnet := btcwire.MainNet
+
+	// Wire encoded bytes for a command which is invalid utf-8.
+	badCommandBytes := makeHeader(btcnet, "bogus", 0, 0)
+	badCommandBytes[4] = 0x81
+
+	// Wire encoded bytes for a command with non-zero bytes after the zero
+	// terminating it.
+	badPaddingBytes := makeHeader(btcnet, "verack\x00\x00x", 0, 0)
+
+	// Wire encoded bytes for a message with a bad checksum.
+	badChecksumBytes := makeHeader(btcnet, "version", 2, 0xbeef)
+	badChecksumBytes = append(badChecksumBytes, []byte{0x0, 0x0}...)
+
+	// Wire encoded bytes for an inv message which claims more inventory
+	// vectors than allowed.
+	invPayload := []byte{0xfe, 0x51, 0xc3, 0x00, 0x00} // 50001
+	checksum := binary.LittleEndian.Uint32(btcwire.DoubleSha256(invPayload))
+	tooManyInvBytes := makeHeader(btcnet, "inv", uint32(len(invPayload)),
+		checksum)
+	tooManyInvBytes = append(tooManyInvBytes, invPayload...)
+
+	// Wire encoded bytes for a mempool message which is invalid for the
+	// old protocol version used to read it.
+	checksum = binary.LittleEndian.Uint32(btcwire.DoubleSha256(nil))
+	memPoolBytes := makeHeader(btcnet, "mempool", 0, checksum)
+
+	tests := []struct {
+		buf  []byte                // Wire encoding
+		pver uint32                // Protocol version for wire encoding
+		cat  btcwire.ErrorCategory // Expected error category
+		ban  uint32                // Expected suggested ban score
+	}{
+		{makeHeader(btcwire.TestNet3, "", 0, 0), pver,
+			btcwire.ErrCategoryWrongNetwork, 0},
+		{makeHeader(btcnet, "getaddr", btcwire.MaxMessagePayload+1, 0),
+			pver, btcwire.ErrCategoryOversized, 20},
+		{badCommandBytes, pver, btcwire.ErrCategoryMalformed, 100},
+		{badPaddingBytes, pver, btcwire.ErrCategoryMalformed, 100},
+		{makeHeader(btcnet, "bogus", 0, 0), pver,
+			btcwire.ErrCategoryUnknownCommand, 0},
+		{makeHeader(btcnet, "getaddr", 1, 0), pver,
+			btcwire.ErrCategoryOversized, 20},
+		{badChecksumBytes, pver, btcwire.ErrCategoryChecksum, 0},
+		{tooManyInvBytes, pver, btcwire.ErrCategoryOversized, 20},
+		{memPoolBytes, btcwire.BIP0035Version - 1,
+			btcwire.ErrCategoryUnsupportedVersion, 1},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		r := bytes.NewReader(test.buf)
+		_, _, err := btcwire.ReadMessage(r, test.pver, btcnet)
+		msgErr, ok := err.(*btcwire.MessageError)
+		if !ok {
+			t.Errorf("ReadMessage #%d wrong error got: %v <%T>, "+
+				"want: *btcwire.MessageError", i, err, err)
+			continue
+		}
+		if msgErr.Category != test.cat {
+			t.Errorf("ReadMessage #%d wrong category got: %v, "+
+				"want: %v", i, msgErr.Category, test.cat)
+			continue
+		}
+		if score := msgErr.Category.BanScore(); score != test.ban {
+			t.Errorf("BanScore #%d got: %v, want: %v", i, score,
+				test.ban)
+			continue
+		}
+	}
+}
+
+// TestErrorCategoryStringer tests the stringized output for the ErrorCategory
+// type.
+func TestErrorCategoryStringer(t *testing.T) {
+	tests := []struct {
+		in   btcwire.ErrorCategory
+		want string
+	}{
+		{btcwire.ErrCategoryNone, "ErrCategoryNone"},
+		{btcwire.ErrCategoryMalformed, "ErrCategoryMalformed"},
+		{btcwire.ErrCategoryOversized, "ErrCategoryOversized"},
+		{btcwire.ErrCategoryUnsupportedVersion,
+			"ErrCategoryUnsupportedVersion"},
+		{btcwire.ErrCategoryChecksum, "ErrCategoryChecksum"},
+		{btcwire.ErrCategoryWrongNetwork, "ErrCategoryWrongNetwork"},
+		{btcwire.ErrCategoryUnknownCommand, "ErrCategoryUnknownCommand"},
+		{btcwire.ErrCategoryDeprecated, "ErrCategoryDeprecated"},
+		{0xff, "Unknown ErrorCategory (255)"},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		result := test.in.String()
+		if result != test.want {
+			t.Errorf("String #%d\n got: %s want: %s", i, result,
+				test.want)
+			continue
+		}
+	}
+}
+
+// TestReadMessageChecksumRecovery ensures a message with a bad checksum is
+// fully consumed so the following message can still be read.
+func TestReadMessageChecksumRecovery(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcnet := btcwire.MainNet
+
+	// Corrupt the checksum of a ping message and follow it with a valid
+	// pong message.
+	var buf bytes.Buffer
+	err := btcwire.WriteMessage(&buf, btcwire.NewMsgPing(1), pver, btcnet)
+	if err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+	buf.Bytes()[20] ^= 0xff
+	want := btcwire.NewMsgPong(2)
+	err = btcwire.WriteMessage(&buf, want, pver, btcnet)
+	if err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+
+	_, _, err = btcwire.ReadMessage(&buf, pver, btcnet)
+	msgErr, ok := err.(*btcwire.MessageError)
+	if !ok || msgErr.Category != btcwire.ErrCategoryChecksum {
+		t.Fatalf("ReadMessage: expected checksum error - got %v", err)
+	}
+
+	msg, _, err := btcwire.ReadMessage(&buf, pver, btcnet)
+	if err != nil {
+		t.Fatalf("ReadMessage: unexpected error after checksum "+
+			"failure: %v", err)
+	}
+	if !reflect.DeepEqual(msg, want) {
+		t.Errorf("ReadMessage\n got: %v want: %v", spew.Sdump(msg),
+			spew.Sdump(want))
+	}
+}
+
+// TestDecodeAll tests decoding multiple messages from a single buffer.
+func TestDecodeAll(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcnet := btcwire.MainNet
+
+	msgs := []btcwire.Message{
+		btcwire.NewMsgVerAck(),
+		btcwire.NewMsgPing(123123),
+		&blockOne,
+	}
+	var buf bytes.Buffer
+	for i, msg := range msgs {
+		err := btcwire.WriteMessage(&buf, msg, pver, btcnet)
+		if err != nil {
+			t.Fatalf("WriteMessage #%d error %v", i, err)
+		}
+	}
+	encoded := buf.Bytes()
+
+	tests := []struct {
+		buf     []byte            // Buffer to decode
+		want    []btcwire.Message // Expected decoded messages
+		restLen int               // Expected number of trailing bytes
+	}{
+		// All messages.
+		{encoded, msgs, 0},
+		// Partial header of the last message.
+		{encoded[:24+32+10], msgs[:2], 10},
+		// Partial payload of the last message.
+		{encoded[:len(encoded)-1], msgs[:2], len(encoded) - 24 - 32 - 1},
+		// Nothing complete.
+		{encoded[:23], nil, 23},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		got, rest, err := btcwire.DecodeAll(test.buf, pver, btcnet)
+		if err != nil {
+			t.Errorf("DecodeAll #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("DecodeAll #%d\n got: %v want: %v", i,
+				spew.Sdump(got), spew.Sdump(test.want))
+			continue
+		}
+		if len(rest) != test.restLen {
+			t.Errorf("DecodeAll #%d wrong trailing bytes - got %d, "+
+				"want %d", i, len(rest), test.restLen)
+			continue
+		}
+	}
+
+	// Ensure errors are reported along with the messages decoded so far.
+	bad := append([]byte{}, encoded...)
+	bad[24+20] ^= 0xff // Corrupt the checksum of the ping message.
+	got, rest, err := btcwire.DecodeAll(bad, pver, btcnet)
+	if _, ok := err.(*btcwire.MessageError); !ok {
+		t.Errorf("DecodeAll: expected MessageError - got %v", err)
+	}
+	if len(got) != 1 || len(rest) != len(bad)-24 {
+		t.Errorf("DecodeAll: wrong results on error - got %d messages "+
+			"and %d trailing bytes", len(got), len(rest))
+	}
+}
+
+// TestReadMessageLargeBlock tests reading blocks larger than MaxBlockPayload
+// when allowed via the read options.
+func TestReadMessageLargeBlock(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcnet := btcwire.MainNet
+
+	// rawBlockMsg returns the raw message for the provided block.
+	rawBlockMsg := func(block *btcwire.MsgBlock) []byte {
+		var payload bytes.Buffer
+		if err := block.Serialize(&payload); err != nil {
+			t.Fatalf("Serialize: %v", err)
+		}
+		checksum := btcwire.DoubleSha256(payload.Bytes())[0:4]
+		hdr := makeHeader(btcnet, "block", uint32(payload.Len()),
+			binary.LittleEndian.Uint32(checksum))
+		return append(hdr, payload.Bytes()...)
+	}
+
+	// Block with a single transaction that has a 1.5MB script.
+	bigTxBlock := btcwire.NewMsgBlock(&blockOne.Header)
+	bigTx := btcwire.NewMsgTx()
+	bigTx.AddTxOut(btcwire.NewTxOut(0, make([]byte, 1500000)))
+	bigTxBlock.AddTransaction(bigTx)
+	bigTxBlock.Header.TxnCount = 1
+	bigTxRaw := rawBlockMsg(bigTxBlock)
+
+	// Block with more small transactions than fit in a 1MB block.
+	manyTxBlock := btcwire.NewMsgBlock(&blockOne.Header)
+	for i := 0; i < 150000; i++ {
+		manyTxBlock.AddTransaction(btcwire.NewMsgTx())
+	}
+	manyTxBlock.Header.TxnCount = 150000
+	manyTxRaw := rawBlockMsg(manyTxBlock)
+
+	tests := []struct {
+		raw     []byte                // Raw block message
+		opts    *btcwire.ReadOptions  // Read options
+		txCount int                   // Expected transaction count
+		cat     btcwire.ErrorCategory // Expected error category
+	}{
+		// Blocks beyond the default limit are rejected.
+		{bigTxRaw, nil, 0, btcwire.ErrCategoryOversized},
+		{manyTxRaw, nil, 0, btcwire.ErrCategoryOversized},
+
+		// Limit raised, but not enough.
+		{
+			bigTxRaw,
+			&btcwire.ReadOptions{MaxBlockPayload: 1200000},
+			0,
+			btcwire.ErrCategoryOversized,
+		},
+
+		// Limit raised enough for both blocks, but the script in the
+		// big transaction is still beyond the max script size.
+		{
+			bigTxRaw,
+			&btcwire.ReadOptions{MaxBlockPayload: 2000000},
+			0,
+			btcwire.ErrCategoryOversized,
+		},
+		{
+			bigTxRaw,
+			&btcwire.ReadOptions{
+				MaxBlockPayload: 2000000,
+				MaxScriptSize:   1500000,
+			},
+			1,
+			btcwire.ErrCategoryNone,
+		},
+		{
+			manyTxRaw,
+			&btcwire.ReadOptions{MaxBlockPayload: 2000000},
+			150000,
+			btcwire.ErrCategoryNone,
+		},
+
+		// Limit beyond the max message payload is limited to it.
+		{
+			manyTxRaw,
+			&btcwire.ReadOptions{MaxBlockPayload: 0xffffffff},
+			150000,
+			btcwire.ErrCategoryNone,
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		r := bytes.NewReader(test.raw)
+		msg, _, err := btcwire.ReadMessageWithOptions(r, pver, btcnet,
+			test.opts)
+		if test.cat != btcwire.ErrCategoryNone {
+			merr, ok := err.(*btcwire.MessageError)
+			if !ok || merr.Category != test.cat {
+				t.Errorf("ReadMessageWithOptions #%d wrong error "+
+					"got: %v, want category: %v", i, err,
+					test.cat)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ReadMessageWithOptions #%d error %v", i, err)
+			continue
+		}
+		block, ok := msg.(*btcwire.MsgBlock)
+		if !ok {
+			t.Errorf("ReadMessageWithOptions #%d wrong message type "+
+				"%T", i, msg)
+			continue
+		}
+		if len(block.Transactions) != test.txCount {
+			t.Errorf("ReadMessageWithOptions #%d wrong transaction "+
+				"count - got %d, want %d", i,
+				len(block.Transactions), test.txCount)
+		}
+	}
+}
+
+// TestReadMessageTrailingBytes ensures payloads with bytes after the encoded
+// message are only rejected when requested via the read options.
+func TestReadMessageTrailingBytes(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcnet := btcwire.MainNet
+
+	// rawMsg returns the raw message for the provided command and payload.
+	rawMsg := func(command string, payload []byte) []byte {
+		checksum := btcwire.DoubleSha256(payload)[0:4]
+		hdr := makeHeader(btcnet, command, uint32(len(payload)),
+			binary.LittleEndian.Uint32(checksum))
+		return append(hdr, payload...)
+	}
+
+	// Transaction message payload followed by trailing bytes.  Messages
+	// with a fixed size payload, such as ping, can't have trailing bytes
+	// since their maximum payload length is enforced.
+	var txPayload bytes.Buffer
+	if err := multiTx.Serialize(&txPayload); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	paddedTx := rawMsg("tx", append(txPayload.Bytes(), 0x00, 0x00))
+
+	// Empty inv message followed by trailing bytes.
+	invPayload := []byte{0x00}
+	paddedInv := rawMsg("inv", append(invPayload, 0xff))
+
+	strict := &btcwire.ReadOptions{RejectTrailingBytes: true}
+	tests := []struct {
+		raw  []byte                // Raw message
+		opts *btcwire.ReadOptions  // Read options
+		cat  btcwire.ErrorCategory // Expected error category
+	}{
+		// Messages without trailing bytes are accepted either way.
+		{rawMsg("tx", txPayload.Bytes()), nil, btcwire.ErrCategoryNone},
+		{rawMsg("tx", txPayload.Bytes()), strict, btcwire.ErrCategoryNone},
+		{rawMsg("inv", invPayload), strict, btcwire.ErrCategoryNone},
+		{rawMsg("verack", nil), strict, btcwire.ErrCategoryNone},
+
+		// Trailing bytes are ignored by default.
+		{paddedTx, nil, btcwire.ErrCategoryNone},
+		{paddedInv, nil, btcwire.ErrCategoryNone},
+
+		// Trailing bytes are rejected when requested.
+		{paddedTx, strict, btcwire.ErrCategoryMalformed},
+		{paddedInv, strict, btcwire.ErrCategoryMalformed},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		r := bytes.NewReader(test.raw)
+		_, _, err := btcwire.ReadMessageWithOptions(r, pver, btcnet,
+			test.opts)
+		if test.cat == btcwire.ErrCategoryNone {
+			if err != nil {
+				t.Errorf("ReadMessageWithOptions #%d unexpected "+
+					"error %v", i, err)
+			}
+			continue
+		}
+		msgErr, ok := err.(*btcwire.MessageError)
+		if !ok || msgErr.Category != test.cat {
+			t.Errorf("ReadMessageWithOptions #%d wrong error got: "+
+				"%v, want category: %v", i, err, test.cat)
+			continue
+		}
+	}
+}
+
+// TestReadMessageAnnotatedErrors ensures errors which occur while decoding a
+// payload identify where decoding failed when requested via the read options.
+func TestReadMessageAnnotatedErrors(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcnet := btcwire.MainNet
+
+	// rawMsg returns the raw message for the provided command and payload.
+	rawMsg := func(command string, payload []byte) []byte {
+		checksum := btcwire.DoubleSha256(payload)[0:4]
+		hdr := makeHeader(btcnet, command, uint32(len(payload)),
+			binary.LittleEndian.Uint32(checksum))
+		return append(hdr, payload...)
+	}
+
+	// Block which ends part way through the lock time of its transaction.
+	truncatedBlock := rawMsg("block", blockOneBytes[:len(blockOneBytes)-2])
+
+	// Inv message with more inventory vectors than allowed.
+	tooManyInv := rawMsg("inv", []byte{0xfd, 0x51, 0xc3})
+
+	tests := []struct {
+		raw    []byte // Raw message
+		cmd    string // Expected command
+		offset int    // Expected offset
+		field  string // Expected field
+	}{
+		{truncatedBlock, "block", len(blockOneBytes) - 2, "tx[0].lock_time"},
+		{tooManyInv, "inv", 3, "count"},
+	}
+
+	opts := &btcwire.ReadOptions{AnnotateDecodeErrors: true}
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Errors are unchanged without the option.
+		_, _, plainErr := btcwire.ReadMessage(bytes.NewReader(test.raw),
+			pver, btcnet)
+		if plainErr == nil {
+			t.Errorf("ReadMessage #%d did not fail", i)
+			continue
+		}
+
+		_, _, err := btcwire.ReadMessageWithOptions(
+			bytes.NewReader(test.raw), pver, btcnet, opts)
+		decodeErr, ok := err.(*btcwire.DecodeError)
+		if !ok {
+			t.Errorf("ReadMessageWithOptions #%d wrong error got: "+
+				"%T(%v)", i, err, err)
+			continue
+		}
+		if decodeErr.Command != test.cmd ||
+			decodeErr.Offset != test.offset ||
+			decodeErr.Field != test.field {
+			t.Errorf("ReadMessageWithOptions #%d wrong location got: "+
+				"%s %d %s, want: %s %d %s", i, decodeErr.Command,
+				decodeErr.Offset, decodeErr.Field, test.cmd,
+				test.offset, test.field)
+		}
+		if decodeErr.Unwrap().Error() != plainErr.Error() {
+			t.Errorf("ReadMessageWithOptions #%d wrong underlying "+
+				"error got: %v, want: %v", i, decodeErr.Unwrap(),
+				plainErr)
+		}
+		if !strings.Contains(err.Error(), test.field) {
+			t.Errorf("ReadMessageWithOptions #%d error %q does not "+
+				"name the field", i, err)
+		}
+	}
+}
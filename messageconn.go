@@ -0,0 +1,364 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultInboundQueueSize is the default number of received messages
+	// which may be queued before a MessageConn stops reading from the
+	// underlying connection.
+	defaultInboundQueueSize = 50
+
+	// defaultOutboundQueueSize is the default number of messages which may
+	// be queued for sending before callers of QueueMessage block.
+	defaultOutboundQueueSize = 50
+
+	// compressMinPayload is the minimum payload size of messages which are
+	// compressed when compression is enabled.  Smaller payloads, such as
+	// those of pings and small inventory announcements, are not worth the
+	// overhead.
+	compressMinPayload = 256
+)
+
+// ErrConnClosed describes an error that indicates a message was queued on, or
+// could not be delivered by, a MessageConn which has been shut down.
+var ErrConnClosed = errors.New("message connection is closed")
+
+// MessageConnConfig houses the optional configuration parameters for a
+// MessageConn.  The zero value provides sane defaults.
+type MessageConnConfig struct {
+	// InboundQueueSize is the number of received messages which may be
+	// queued before the connection stops reading from the network, which
+	// in turn applies TCP backpressure to the remote peer.  It defaults to
+	// 50 when zero.
+	InboundQueueSize int
+
+	// OutboundQueueSize is the number of messages which may be queued for
+	// sending before QueueMessage and Send block.  It defaults to 50 when
+	// zero.
+	OutboundQueueSize int
+
+	// ReadTimeout is the maximum amount of time to wait for the next
+	// message to be read.  The connection is shut down when it elapses.
+	// There is no timeout when zero.
+	ReadTimeout time.Duration
+
+	// WriteTimeout is the maximum amount of time allowed to write each
+	// message.  The connection is shut down when it elapses.  There is no
+	// timeout when zero.
+	WriteTimeout time.Duration
+
+	// ReadOptions, when non-nil, modifies how messages are read.  See
+	// ReadOptions for details.
+	ReadOptions *ReadOptions
+
+	// AddrMe, when non-nil, supplies the local address of version messages
+	// (MsgVersion) as they are written.  See AddrMeFunc for details.
+	AddrMe AddrMeFunc
+
+	// Compress enables compressed messages.  Messages with payloads of at
+	// least 256 bytes are written via WriteMessageCompressed, and
+	// compressed messages are accepted when reading.  It must only be set
+	// when the remote peer supports compression.  See SFNodeCompression.
+	Compress bool
+}
+
+// AddrMeFunc returns the address the local peer should advertise in the AddrMe
+// field of a version message sent to the remote peer with the provided
+// address.  It is called each time a version message is written rather than
+// when the message is created, since the externally routable address of the
+// local peer is often only learned later, such as by NAT traversal or UPnP
+// discovery.  Returning nil leaves the address of the message unchanged.
+type AddrMeFunc func(remote net.Addr) *NetAddress
+
+// withAddrMe returns msg with its local address supplied by hook when it is a
+// version message and hook provides one.  The message is copied rather than
+// modified since the caller may still be using it.
+func withAddrMe(hook AddrMeFunc, conn net.Conn, msg Message) Message {
+	if hook == nil {
+		return msg
+	}
+	ver, ok := msg.(*MsgVersion)
+	if !ok {
+		return msg
+	}
+	na := hook(conn.RemoteAddr())
+	if na == nil {
+		return msg
+	}
+
+	verCopy := *ver
+	verCopy.AddrMe = *na
+	return &verCopy
+}
+
+// outMsg houses a message queued for sending along with the channel to notify
+// once it has been written.
+type outMsg struct {
+	msg  Message
+	done chan<- error
+}
+
+// MessageConn provides goroutine-safe queues for sending and receiving bitcoin
+// messages over a net.Conn.  It runs one goroutine which reads messages via
+// ReadMessage and delivers them on the channel returned by Inbound and another
+// which writes queued messages via WriteMessage.
+//
+// Both queues are bounded.  When the inbound queue is full, the connection
+// stops reading until the caller catches up, and when the outbound queue is
+// full, QueueMessage and Send block until there is room.
+//
+// Any error reading or writing a message shuts the connection down.  Callers
+// which want to tolerate messages with unrecognized commands should set the
+// AllowUnknown read option.  Once shut down, the inbound channel is closed, the
+// channel returned by Done is closed, and Err reports the error responsible,
+// if any.
+type MessageConn struct {
+	conn   net.Conn
+	btcnet BitcoinNet
+	pver   uint32 // Use atomic accessors.
+	cfg    MessageConnConfig
+
+	inbound  chan Message
+	outbound chan outMsg
+	quit     chan struct{}
+	wg       sync.WaitGroup
+
+	startOnce sync.Once
+	closeOnce sync.Once
+
+	// mtx protects the following fields.  It is held for reads while
+	// messages are being queued so shutdown can ensure no messages are
+	// queued once it has drained the outbound queue.
+	mtx    sync.RWMutex
+	closed bool
+	err    error
+}
+
+// NewMessageConn returns a new MessageConn which exchanges messages over conn
+// using the provided protocol version and bitcoin network.  A nil cfg is
+// treated the same as the zero value.  Start must be called to begin
+// processing messages.
+func NewMessageConn(conn net.Conn, pver uint32, btcnet BitcoinNet, cfg *MessageConnConfig) *MessageConn {
+	var c MessageConnConfig
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.InboundQueueSize <= 0 {
+		c.InboundQueueSize = defaultInboundQueueSize
+	}
+	if c.OutboundQueueSize <= 0 {
+		c.OutboundQueueSize = defaultOutboundQueueSize
+	}
+	if c.Compress {
+		var opts ReadOptions
+		if c.ReadOptions != nil {
+			opts = *c.ReadOptions
+		}
+		opts.AllowCompressed = true
+		c.ReadOptions = &opts
+	}
+
+	return &MessageConn{
+		conn:     conn,
+		btcnet:   btcnet,
+		pver:     pver,
+		cfg:      c,
+		inbound:  make(chan Message, c.InboundQueueSize),
+		outbound: make(chan outMsg, c.OutboundQueueSize),
+		quit:     make(chan struct{}),
+	}
+}
+
+// Start begins reading and writing messages.  Calling it more than once has no
+// effect.
+func (c *MessageConn) Start() {
+	c.startOnce.Do(func() {
+		c.wg.Add(2)
+		go c.inHandler()
+		go c.outHandler()
+	})
+}
+
+// Conn returns the underlying connection.
+func (c *MessageConn) Conn() net.Conn {
+	return c.conn
+}
+
+// ProtocolVersion returns the protocol version currently used to read and
+// write messages.
+func (c *MessageConn) ProtocolVersion() uint32 {
+	return atomic.LoadUint32(&c.pver)
+}
+
+// SetProtocolVersion sets the protocol version used to read and write
+// messages.  This is typically called once the version has been negotiated
+// with the remote peer.
+func (c *MessageConn) SetProtocolVersion(pver uint32) {
+	atomic.StoreUint32(&c.pver, pver)
+}
+
+// Inbound returns the channel on which received messages are delivered.  It is
+// closed when the connection shuts down.
+func (c *MessageConn) Inbound() <-chan Message {
+	return c.inbound
+}
+
+// QueueMessage adds msg to the outbound queue, blocking while the queue is
+// full.  When done is non-nil, the result of writing the message is sent to it
+// once the message has been written or the connection has shut down, so it
+// should be buffered to avoid blocking the connection.
+//
+// ErrConnClosed is returned if the connection has been shut down, in which
+// case nothing is sent to done.
+func (c *MessageConn) QueueMessage(msg Message, done chan<- error) error {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	if c.closed {
+		return ErrConnClosed
+	}
+
+	select {
+	case c.outbound <- outMsg{msg: msg, done: done}:
+		return nil
+	case <-c.quit:
+		return ErrConnClosed
+	}
+}
+
+// Send queues msg for sending and waits until it has been written, returning
+// the result.
+func (c *MessageConn) Send(msg Message) error {
+	done := make(chan error, 1)
+	err := c.QueueMessage(msg, done)
+	if err != nil {
+		return err
+	}
+	return <-done
+}
+
+// Done returns a channel which is closed when the connection shuts down.
+func (c *MessageConn) Done() <-chan struct{} {
+	return c.quit
+}
+
+// Err returns the error which caused the connection to shut down.  It returns
+// nil while the connection is running and when it was shut down by Close.
+func (c *MessageConn) Err() error {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.err
+}
+
+// Close shuts the connection down and waits for the reading and writing
+// goroutines to finish.  Messages still in the outbound queue are not sent and
+// their done channels receive ErrConnClosed.
+func (c *MessageConn) Close() error {
+	c.shutdown(nil)
+	c.wg.Wait()
+	return nil
+}
+
+// shutdown closes the connection and drains the outbound queue while
+// recording err as the reason for the shutdown.  Only the first call has any
+// effect.
+func (c *MessageConn) shutdown(err error) {
+	c.closeOnce.Do(func() {
+		// Signal the handlers and any blocked callers to stop.  This
+		// must happen before acquiring the write lock since callers
+		// blocked in QueueMessage hold the read lock.
+		close(c.quit)
+		c.conn.Close()
+
+		c.mtx.Lock()
+		c.closed = true
+		c.err = err
+		c.mtx.Unlock()
+
+		// No more messages can be queued at this point, so notify the
+		// callers of any that are still waiting.
+		for {
+			select {
+			case om := <-c.outbound:
+				if om.done != nil {
+					om.done <- ErrConnClosed
+				}
+			default:
+				return
+			}
+		}
+	})
+}
+
+// inHandler reads messages from the connection and delivers them to the
+// inbound queue until the connection shuts down.  It must be run as a
+// goroutine.
+func (c *MessageConn) inHandler() {
+	defer c.wg.Done()
+	defer close(c.inbound)
+
+	for {
+		if c.cfg.ReadTimeout > 0 {
+			deadline := time.Now().Add(c.cfg.ReadTimeout)
+			c.conn.SetReadDeadline(deadline)
+		}
+		msg, _, err := readMessage(c.conn, c.ProtocolVersion(),
+			c.btcnet, nil, c.cfg.ReadOptions)
+		if err != nil {
+			c.shutdown(err)
+			return
+		}
+
+		select {
+		case c.inbound <- msg:
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// outHandler writes messages from the outbound queue to the connection until
+// the connection shuts down.  It must be run as a goroutine.
+func (c *MessageConn) outHandler() {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case om := <-c.outbound:
+			if c.cfg.WriteTimeout > 0 {
+				deadline := time.Now().Add(c.cfg.WriteTimeout)
+				c.conn.SetWriteDeadline(deadline)
+			}
+			msg := withAddrMe(c.cfg.AddrMe, c.conn, om.msg)
+			pver := c.ProtocolVersion()
+			var err error
+			if c.cfg.Compress &&
+				msg.SerializeSize(pver) >= compressMinPayload {
+
+				err = WriteMessageCompressed(c.conn, msg, pver,
+					c.btcnet)
+			} else {
+				err = WriteMessage(c.conn, msg, pver, c.btcnet)
+			}
+			if om.done != nil {
+				om.done <- err
+			}
+			if err != nil {
+				c.shutdown(err)
+				return
+			}
+
+		case <-c.quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestMessageConn tests sending and receiving messages between two
+// MessageConns along with their shutdown semantics.
+func TestMessageConn(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcnet := btcwire.MainNet
+
+	inConn, outConn := net.Pipe()
+	a := btcwire.NewMessageConn(outConn, pver, btcnet, nil)
+	b := btcwire.NewMessageConn(inConn, pver, btcnet, nil)
+	a.Start()
+	b.Start()
+
+	msgs := []btcwire.Message{
+		btcwire.NewMsgVerAck(),
+		btcwire.NewMsgPing(123123),
+		btcwire.NewMsgPong(123123),
+	}
+
+	// Send the messages in the background while reading them on the other
+	// side since the pipe is synchronous.
+	errChan := make(chan error, len(msgs))
+	go func() {
+		for _, msg := range msgs {
+			errChan <- a.Send(msg)
+		}
+	}()
+
+	t.Logf("Running %d tests", len(msgs))
+	for i, want := range msgs {
+		select {
+		case msg := <-b.Inbound():
+			if !reflect.DeepEqual(msg, want) {
+				t.Errorf("Inbound #%d\n got: %v want: %v", i,
+					spew.Sdump(msg), spew.Sdump(want))
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("Inbound #%d timeout waiting for message", i)
+		}
+		if err := <-errChan; err != nil {
+			t.Errorf("Send #%d error %v", i, err)
+		}
+	}
+
+	// Ensure closing one side closes its inbound channel and rejects
+	// further messages.
+	a.Close()
+	if _, ok := <-a.Inbound(); ok {
+		t.Errorf("Inbound: channel not closed after Close")
+	}
+	if err := a.Send(btcwire.NewMsgVerAck()); err != btcwire.ErrConnClosed {
+		t.Errorf("Send: wrong error after Close - got %v, want %v",
+			err, btcwire.ErrConnClosed)
+	}
+	if err := a.Err(); err != nil {
+		t.Errorf("Err: unexpected error after Close - got %v", err)
+	}
+
+	// Ensure the remote side shuts down with an error.
+	select {
+	case <-b.Done():
+	case <-time.After(time.Second * 5):
+		t.Fatalf("Done: timeout waiting for remote shutdown")
+	}
+	if b.Err() == nil {
+		t.Errorf("Err: expected error after remote close")
+	}
+	b.Close()
+}
+
+// TestMessageConnReadTimeout ensures the read timeout shuts the connection
+// down when no message arrives in time.
+func TestMessageConnReadTimeout(t *testing.T) {
+	inConn, outConn := net.Pipe()
+	defer outConn.Close()
+
+	cfg := &btcwire.MessageConnConfig{ReadTimeout: time.Millisecond * 10}
+	c := btcwire.NewMessageConn(inConn, btcwire.ProtocolVersion,
+		btcwire.MainNet, cfg)
+	c.Start()
+	defer c.Close()
+
+	select {
+	case <-c.Done():
+	case <-time.After(time.Second * 5):
+		t.Fatalf("Done: timeout waiting for read timeout")
+	}
+	netErr, ok := c.Err().(net.Error)
+	if !ok || !netErr.Timeout() {
+		t.Errorf("Err: expected timeout error - got %v", c.Err())
+	}
+}
+
+// TestMessageConnProtocolVersion ensures the protocol version can be updated.
+func TestMessageConnProtocolVersion(t *testing.T) {
+	inConn, outConn := net.Pipe()
+	defer inConn.Close()
+	defer outConn.Close()
+
+	c := btcwire.NewMessageConn(inConn, btcwire.ProtocolVersion,
+		btcwire.MainNet, nil)
+	if pver := c.ProtocolVersion(); pver != btcwire.ProtocolVersion {
+		t.Errorf("ProtocolVersion: got %v, want %v", pver,
+			btcwire.ProtocolVersion)
+	}
+	c.SetProtocolVersion(btcwire.BIP0031Version)
+	if pver := c.ProtocolVersion(); pver != btcwire.BIP0031Version {
+		t.Errorf("ProtocolVersion: got %v, want %v", pver,
+			btcwire.BIP0031Version)
+	}
+}
+
+// TestMessageConnAddrMe ensures the AddrMe hook supplies the local address of
+// version messages as they are written without modifying the queued message.
+func TestMessageConnAddrMe(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcnet := btcwire.MainNet
+
+	external := btcwire.NewNetAddressIPPort(net.ParseIP("203.0.113.1"),
+		8333, btcwire.SFNodeNetwork)
+	var hookCalls int
+	cfg := &btcwire.MessageConnConfig{
+		AddrMe: func(remote net.Addr) *btcwire.NetAddress {
+			hookCalls++
+			return external
+		},
+	}
+
+	inConn, outConn := net.Pipe()
+	a := btcwire.NewMessageConn(outConn, pver, btcnet, cfg)
+	b := btcwire.NewMessageConn(inConn, pver, btcnet, nil)
+	a.Start()
+	b.Start()
+	defer a.Close()
+	defer b.Close()
+
+	sent := *baseVersion
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- a.Send(&sent)
+	}()
+
+	select {
+	case msg := <-b.Inbound():
+		ver, ok := msg.(*btcwire.MsgVersion)
+		if !ok {
+			t.Fatalf("Inbound: wrong message got: %T", msg)
+		}
+		if !ver.AddrMe.IP.Equal(external.IP) ||
+			ver.AddrMe.Port != external.Port {
+			t.Errorf("Inbound: wrong local address got: %v:%d "+
+				"want: %v:%d", ver.AddrMe.IP, ver.AddrMe.Port,
+				external.IP, external.Port)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("Inbound: timeout waiting for message")
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if hookCalls != 1 {
+		t.Errorf("AddrMe: hook called %d times, want 1", hookCalls)
+	}
+	if !reflect.DeepEqual(&sent, baseVersion) {
+		t.Errorf("Send: queued message was modified\n got: %s want: %s",
+			spew.Sdump(&sent), spew.Sdump(baseVersion))
+	}
+}
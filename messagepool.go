@@ -0,0 +1,191 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"sync"
+)
+
+// The following pools hold previously released messages of the small types
+// which are exchanged at a very high rate by busy relay nodes.  Reusing them
+// significantly reduces the number of short-lived objects the garbage
+// collector has to deal with.
+//
+// Messages of these types returned by ReadMessage are obtained from the pools
+// as well, so they may be released once the caller is finished with them.
+var (
+	msgInvPool = sync.Pool{New: func() interface{} {
+		return NewMsgInv()
+	}}
+	msgGetDataPool = sync.Pool{New: func() interface{} {
+		return NewMsgGetData()
+	}}
+	msgPingPool = sync.Pool{New: func() interface{} {
+		return &MsgPing{}
+	}}
+	msgPongPool = sync.Pool{New: func() interface{} {
+		return &MsgPong{}
+	}}
+)
+
+// maxPooledScriptLen is the maximum capacity of a script buffer which is kept
+// along with a released transaction input or output.  Larger buffers are left
+// for the garbage collector so a handful of unusually large scripts do not pin
+// memory in the pools.
+const maxPooledScriptLen = MaxScriptSize
+
+// The following pools hold the transactions, and their inputs and outputs,
+// released via ReleaseMsgTx.  Unlike the message pools above, they are opt-in
+// and only used for transactions obtained via AcquireMsgTx.  Released inputs
+// and outputs keep their script buffers so decoding into them again does not
+// need to allocate new ones.
+var (
+	msgTxPool = sync.Pool{New: func() interface{} {
+		return &MsgTx{pooled: true}
+	}}
+	txInPool = sync.Pool{New: func() interface{} {
+		return &TxIn{}
+	}}
+	txOutPool = sync.Pool{New: func() interface{} {
+		return &TxOut{}
+	}}
+)
+
+// clearInvList removes all entries from the provided inventory list while
+// retaining the backing array for reuse.  The entries are set to nil so the
+// inventory vectors they referenced can be garbage collected.
+func clearInvList(invList []*InvVect) []*InvVect {
+	for i := range invList {
+		invList[i] = nil
+	}
+	return invList[:0]
+}
+
+// AcquireMsgInv returns an empty inv message from a pool of released
+// messages, creating a new one when the pool is empty.  The message should
+// be returned to the pool with ReleaseMsgInv once it is no longer needed.
+func AcquireMsgInv() *MsgInv {
+	return msgInvPool.Get().(*MsgInv)
+}
+
+// ReleaseMsgInv resets the provided inv message and returns it to the pool
+// used by AcquireMsgInv.  The message, and any inventory vectors it contained,
+// must not be used after it has been released.
+func ReleaseMsgInv(msg *MsgInv) {
+	msg.InvList = clearInvList(msg.InvList)
+	msgInvPool.Put(msg)
+}
+
+// AcquireMsgGetData returns an empty getdata message from a pool of released
+// messages, creating a new one when the pool is empty.  The message should be
+// returned to the pool with ReleaseMsgGetData once it is no longer needed.
+func AcquireMsgGetData() *MsgGetData {
+	return msgGetDataPool.Get().(*MsgGetData)
+}
+
+// ReleaseMsgGetData resets the provided getdata message and returns it to the
+// pool used by AcquireMsgGetData.  The message, and any inventory vectors it
+// contained, must not be used after it has been released.
+func ReleaseMsgGetData(msg *MsgGetData) {
+	msg.InvList = clearInvList(msg.InvList)
+	msgGetDataPool.Put(msg)
+}
+
+// AcquireMsgPing returns a ping message with the provided nonce from a pool of
+// released messages, creating a new one when the pool is empty.  The message
+// should be returned to the pool with ReleaseMsgPing once it is no longer
+// needed.
+func AcquireMsgPing(nonce uint64) *MsgPing {
+	msg := msgPingPool.Get().(*MsgPing)
+	msg.Nonce = nonce
+	return msg
+}
+
+// ReleaseMsgPing resets the provided ping message and returns it to the pool
+// used by AcquireMsgPing.  The message must not be used after it has been
+// released.
+func ReleaseMsgPing(msg *MsgPing) {
+	msg.Nonce = 0
+	msgPingPool.Put(msg)
+}
+
+// AcquireMsgPong returns a pong message with the provided nonce from a pool of
+// released messages, creating a new one when the pool is empty.  The message
+// should be returned to the pool with ReleaseMsgPong once it is no longer
+// needed.
+func AcquireMsgPong(nonce uint64) *MsgPong {
+	msg := msgPongPool.Get().(*MsgPong)
+	msg.Nonce = nonce
+	return msg
+}
+
+// ReleaseMsgPong resets the provided pong message and returns it to the pool
+// used by AcquireMsgPong.  The message must not be used after it has been
+// released.
+func ReleaseMsgPong(msg *MsgPong) {
+	msg.Nonce = 0
+	msgPongPool.Put(msg)
+}
+
+// AcquireMsgTx returns an empty transaction with a version of TxVersion from
+// a pool of released transactions, creating a new one when the pool is empty.
+// Decoding into the transaction, via BtcDecode or Deserialize, reuses the
+// inputs, outputs, and script buffers of previously released transactions.
+//
+// This is intended for callers, such as mempool acceptance pipelines, which
+// decode and discard large numbers of transactions.  The transaction should
+// be returned to the pool with ReleaseMsgTx once it is no longer needed.
+func AcquireMsgTx() *MsgTx {
+	msg := msgTxPool.Get().(*MsgTx)
+	msg.Version = TxVersion
+	return msg
+}
+
+// ReleaseMsgTx resets the provided transaction and returns it, along with its
+// inputs, outputs, and their script buffers, to the pools used by
+// AcquireMsgTx.  The transaction, its inputs and outputs, and any slices of
+// their scripts must not be used after it has been released.
+//
+// Only transactions obtained via AcquireMsgTx are released.  Since their
+// scripts are reused, inputs and outputs with scripts owned elsewhere must not
+// be added to them.  Calling it with any other transaction has no effect.
+func ReleaseMsgTx(msg *MsgTx) {
+	if !msg.pooled {
+		return
+	}
+
+	for i, ti := range msg.TxIn {
+		if ti != nil {
+			ti.PreviousOutpoint = OutPoint{}
+			ti.SignatureScript = releaseScript(ti.SignatureScript)
+			ti.Sequence = 0
+			txInPool.Put(ti)
+		}
+		msg.TxIn[i] = nil
+	}
+	for i, to := range msg.TxOut {
+		if to != nil {
+			to.Value = 0
+			to.PkScript = releaseScript(to.PkScript)
+			txOutPool.Put(to)
+		}
+		msg.TxOut[i] = nil
+	}
+
+	msg.TxIn = msg.TxIn[:0]
+	msg.TxOut = msg.TxOut[:0]
+	msg.LockTime = 0
+	msg.serialized = nil
+	msgTxPool.Put(msg)
+}
+
+// releaseScript returns the provided script truncated to zero length so its
+// buffer can be reused, or nil when the buffer is too large to be retained.
+func releaseScript(script []byte) []byte {
+	if cap(script) > maxPooledScriptLen {
+		return nil
+	}
+	return script[:0]
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"reflect"
+	"testing"
+)
+
+// TestMessagePool tests the acquire and release functions for the pooled
+// message types.
+func TestMessagePool(t *testing.T) {
+	hash := btcwire.ShaHash{0x01}
+
+	// Ensure released inv messages are handed out empty.
+	inv := btcwire.AcquireMsgInv()
+	inv.AddInvVect(btcwire.NewInvVect(btcwire.InvTypeTx, &hash))
+	btcwire.ReleaseMsgInv(inv)
+	inv = btcwire.AcquireMsgInv()
+	if len(inv.InvList) != 0 {
+		t.Errorf("AcquireMsgInv: non-empty inventory list - got %d",
+			len(inv.InvList))
+	}
+
+	// Ensure released getdata messages are handed out empty.
+	getData := btcwire.AcquireMsgGetData()
+	getData.AddInvVect(btcwire.NewInvVect(btcwire.InvTypeBlock, &hash))
+	btcwire.ReleaseMsgGetData(getData)
+	getData = btcwire.AcquireMsgGetData()
+	if len(getData.InvList) != 0 {
+		t.Errorf("AcquireMsgGetData: non-empty inventory list - got %d",
+			len(getData.InvList))
+	}
+
+	// Ensure ping and pong messages carry the requested nonce.
+	btcwire.ReleaseMsgPing(btcwire.AcquireMsgPing(1))
+	ping := btcwire.AcquireMsgPing(2)
+	if ping.Nonce != 2 {
+		t.Errorf("AcquireMsgPing: wrong nonce - got %v, want %v",
+			ping.Nonce, 2)
+	}
+	btcwire.ReleaseMsgPong(btcwire.AcquireMsgPong(3))
+	pong := btcwire.AcquireMsgPong(4)
+	if pong.Nonce != 4 {
+		t.Errorf("AcquireMsgPong: wrong nonce - got %v, want %v",
+			pong.Nonce, 4)
+	}
+}
+
+// TestMessagePoolInvList ensures inv and getdata messages obtained via the
+// pool decode into the inventory list retained on release instead of
+// allocating a new one.
+func TestMessagePoolInvList(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	hash := btcwire.ShaHash{0x01}
+
+	// Encode an inventory list to decode, which is the same for both
+	// message types.
+	want := btcwire.NewMsgInv()
+	want.AddInvVect(btcwire.NewInvVect(btcwire.InvTypeTx, &hash))
+	want.AddInvVect(btcwire.NewInvVect(btcwire.InvTypeBlock, &hash))
+	var buf bytes.Buffer
+	if err := want.BtcEncode(&buf, pver); err != nil {
+		t.Fatalf("BtcEncode error %v", err)
+	}
+	payload := buf.Bytes()
+
+	// Decode into a released inv message and ensure its inventory list
+	// still uses the same backing array.
+	inv := btcwire.AcquireMsgInv()
+	if err := inv.BtcDecode(bytes.NewReader(payload), pver); err != nil {
+		t.Fatalf("MsgInv.BtcDecode error %v", err)
+	}
+	backing := &inv.InvList[0]
+	btcwire.ReleaseMsgInv(inv)
+	inv = btcwire.AcquireMsgInv()
+	if err := inv.BtcDecode(bytes.NewReader(payload), pver); err != nil {
+		t.Fatalf("MsgInv.BtcDecode error %v", err)
+	}
+	if &inv.InvList[0] != backing {
+		t.Errorf("MsgInv.BtcDecode: inventory list not reused")
+	}
+	if !reflect.DeepEqual(inv.InvList, want.InvList) {
+		t.Errorf("MsgInv.BtcDecode\n got: %s want: %s",
+			spew.Sdump(inv.InvList), spew.Sdump(want.InvList))
+	}
+
+	// Do the same for getdata messages.
+	getData := btcwire.AcquireMsgGetData()
+	err := getData.BtcDecode(bytes.NewReader(payload), pver)
+	if err != nil {
+		t.Fatalf("MsgGetData.BtcDecode error %v", err)
+	}
+	backing = &getData.InvList[0]
+	btcwire.ReleaseMsgGetData(getData)
+	getData = btcwire.AcquireMsgGetData()
+	err = getData.BtcDecode(bytes.NewReader(payload), pver)
+	if err != nil {
+		t.Fatalf("MsgGetData.BtcDecode error %v", err)
+	}
+	if &getData.InvList[0] != backing {
+		t.Errorf("MsgGetData.BtcDecode: inventory list not reused")
+	}
+	if !reflect.DeepEqual(getData.InvList, want.InvList) {
+		t.Errorf("MsgGetData.BtcDecode\n got: %s want: %s",
+			spew.Sdump(getData.InvList), spew.Sdump(want.InvList))
+	}
+}
+
+// TestMessagePoolReadMessage ensures pooled messages read via ReadMessage
+// can be released and decoded into again.
+func TestMessagePoolReadMessage(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcnet := btcwire.MainNet
+
+	for i := 0; i < 3; i++ {
+		var buf bytes.Buffer
+		want := btcwire.NewMsgPing(uint64(i + 100))
+		err := btcwire.WriteMessage(&buf, want, pver, btcnet)
+		if err != nil {
+			t.Fatalf("WriteMessage #%d error %v", i, err)
+		}
+		msg, _, err := btcwire.ReadMessage(&buf, pver, btcnet)
+		if err != nil {
+			t.Fatalf("ReadMessage #%d error %v", i, err)
+		}
+		ping, ok := msg.(*btcwire.MsgPing)
+		if !ok {
+			t.Fatalf("ReadMessage #%d wrong type %T", i, msg)
+		}
+		if ping.Nonce != want.Nonce {
+			t.Errorf("ReadMessage #%d wrong nonce - got %v, want %v",
+				i, ping.Nonce, want.Nonce)
+		}
+		btcwire.ReleaseMsgPing(ping)
+	}
+}
+
+// TestMessagePoolTx ensures transactions obtained via AcquireMsgTx decode
+// correctly when reusing released inputs, outputs, and scripts, and that
+// releasing other transactions has no effect.
+func TestMessagePoolTx(t *testing.T) {
+	// Decode the same transaction repeatedly, releasing it in between, so
+	// later iterations decode into released inputs and outputs.
+	for i := 0; i < 3; i++ {
+		tx := btcwire.AcquireMsgTx()
+		if tx.Version != btcwire.TxVersion || len(tx.TxIn) != 0 ||
+			len(tx.TxOut) != 0 || tx.LockTime != 0 {
+			t.Fatalf("AcquireMsgTx #%d: non-empty transaction %v", i,
+				spew.Sdump(tx))
+		}
+
+		err := tx.Deserialize(bytes.NewReader(multiTxEncoded))
+		if err != nil {
+			t.Fatalf("Deserialize #%d error %v", i, err)
+		}
+		if tx.Version != multiTx.Version ||
+			tx.LockTime != multiTx.LockTime ||
+			!reflect.DeepEqual(tx.TxIn, multiTx.TxIn) ||
+			!reflect.DeepEqual(tx.TxOut, multiTx.TxOut) {
+			t.Fatalf("Deserialize #%d\n got: %s want: %s", i,
+				spew.Sdump(tx), spew.Sdump(multiTx))
+		}
+
+		// Ensure the cached serialization is discarded on release.
+		_, err = tx.SerializedBytes()
+		if err != nil {
+			t.Fatalf("SerializedBytes #%d error %v", i, err)
+		}
+		btcwire.ReleaseMsgTx(tx)
+	}
+
+	// Releasing a transaction which was not acquired from the pool must
+	// leave it untouched.
+	tx := multiTx.Copy()
+	btcwire.ReleaseMsgTx(tx)
+	if !reflect.DeepEqual(tx, multiTx.Copy()) {
+		t.Errorf("ReleaseMsgTx: modified unpooled transaction %v",
+			spew.Sdump(tx))
+	}
+}
@@ -7,6 +7,8 @@ package btcwire
 import (
 	"fmt"
 	"io"
+	"net"
+	"time"
 )
 
 // MaxAddrPerMsg is the maximum number of addresses that can be in a single
@@ -55,6 +57,70 @@ func (msg *MsgAddr) ClearAddresses() {
 	msg.AddrList = []*NetAddress{}
 }
 
+// SanitizeTimestamps calls SanitizeTimestamp with the provided time on every
+// address in the message and returns the number of addresses whose timestamps
+// were replaced.  This is useful both for received messages and for building
+// messages from addresses of unknown quality.
+func (msg *MsgAddr) SanitizeTimestamps(now time.Time) int {
+	n := 0
+	for _, na := range msg.AddrList {
+		if na.SanitizeTimestamp(now) {
+			n++
+		}
+	}
+	return n
+}
+
+// addrKey identifies an address by its IP and port for MergeAddrMessages.
+// IPv4 addresses and their IPv4-mapped IPv6 forms are treated the same.
+type addrKey struct {
+	ip   [net.IPv6len]byte
+	port uint16
+}
+
+// MergeAddrMessages merges the addresses of the provided addr messages into as
+// few messages as possible, each with at most MaxAddrPerMsg addresses.  Each
+// address, identified by its IP and port, appears only once with the newest of
+// its timestamps and the union of its services, so relaying the result does
+// not gossip the same address repeatedly.  Addresses keep the order in which
+// they first appear.  The provided messages and their addresses are not
+// modified.
+func MergeAddrMessages(msgs ...*MsgAddr) []*MsgAddr {
+	var merged []*NetAddress
+	seen := make(map[addrKey]*NetAddress)
+	for _, msg := range msgs {
+		for _, na := range msg.AddrList {
+			var key addrKey
+			copy(key.ip[:], na.IP.To16())
+			key.port = na.Port
+
+			if existing, ok := seen[key]; ok {
+				if na.Timestamp.After(existing.Timestamp) {
+					existing.Timestamp = na.Timestamp
+				}
+				existing.Services |= na.Services
+				continue
+			}
+
+			dup := *na
+			dup.IP = append(net.IP(nil), na.IP...)
+			seen[key] = &dup
+			merged = append(merged, &dup)
+		}
+	}
+
+	result := make([]*MsgAddr, 0, (len(merged)+MaxAddrPerMsg-1)/MaxAddrPerMsg)
+	for len(merged) > 0 {
+		n := len(merged)
+		if n > MaxAddrPerMsg {
+			n = MaxAddrPerMsg
+		}
+		result = append(result, &MsgAddr{AddrList: merged[:n:n]})
+		merged = merged[n:]
+	}
+	return result
+}
+
 // BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
 // This is part of the Message interface implementation.
 func (msg *MsgAddr) BtcDecode(r io.Reader, pver uint32) error {
@@ -67,7 +133,18 @@ func (msg *MsgAddr) BtcDecode(r io.Reader, pver uint32) error {
 	if count > MaxAddrPerMsg {
 		str := fmt.Sprintf("too many addresses for message "+
 			"[count %v, max %v]", count, MaxAddrPerMsg)
-		return messageError("MsgAddr.BtcDecode", str)
+		return categorizedError("MsgAddr.BtcDecode", str,
+			ErrCategoryOversized)
+	}
+	err = checkCountFits(r, "MsgAddr.BtcDecode", "addresses", count,
+		uint64(NetAddressPayloadSize(pver, true)))
+	if err != nil {
+		return err
+	}
+	err = reserveAlloc(r, "MsgAddr.BtcDecode", "addresses",
+		count*netAddressAllocSize)
+	if err != nil {
+		return err
 	}
 
 	msg.AddrList = make([]*NetAddress, 0, count)
@@ -88,7 +165,7 @@ func (msg *MsgAddr) BtcEncode(w io.Writer, pver uint32) error {
 	// Protocol versions before MultipleAddressVersion only allowed 1 address
 	// per message.
 	count := len(msg.AddrList)
-	if pver < MultipleAddressVersion && count > 1 {
+	if !FeatureMultipleAddresses.IsSupported(pver) && count > 1 {
 		str := fmt.Sprintf("too many addresses for message of "+
 			"protocol version %v [count %v, max 1]", pver, count)
 		return messageError("MsgAddr.BtcEncode", str)
@@ -118,13 +195,13 @@ func (msg *MsgAddr) BtcEncode(w io.Writer, pver uint32) error {
 // Command returns the protocol command string for the message.  This is part
 // of the Message interface implementation.
 func (msg *MsgAddr) Command() string {
-	return cmdAddr
+	return CmdAddr
 }
 
 // MaxPayloadLength returns the maximum length the payload can be for the
 // receiver.  This is part of the Message interface implementation.
 func (msg *MsgAddr) MaxPayloadLength(pver uint32) uint32 {
-	if pver < MultipleAddressVersion {
+	if !FeatureMultipleAddresses.IsSupported(pver) {
 		// Num addresses (varInt) + a single net addresses.
 		return maxVarIntPayload + maxNetAddressPayload(pver)
 	}
@@ -133,6 +210,52 @@ func (msg *MsgAddr) MaxPayloadLength(pver uint32) uint32 {
 	return maxVarIntPayload + (MaxAddrPerMsg * maxNetAddressPayload(pver))
 }
 
+// SerializeSize returns the number of bytes it would take to encode the addr
+// message using the provided protocol version.  This is part of the Message
+// interface implementation.
+func (msg *MsgAddr) SerializeSize(pver uint32) int {
+	count := len(msg.AddrList)
+	return varIntSerializeSize(uint64(count)) +
+		count*int(NetAddressPayloadSize(pver, true))
+}
+
+// Validate returns an error when the addr message would be rejected by
+// BtcEncode regardless of the protocol version.  This is part of the Message
+// interface implementation.
+func (msg *MsgAddr) Validate() error {
+	count := len(msg.AddrList)
+	if count > MaxAddrPerMsg {
+		str := fmt.Sprintf("too many addresses for message "+
+			"[count %v, max %v]", count, MaxAddrPerMsg)
+		return messageError("MsgAddr.Validate", str)
+	}
+	return nil
+}
+
+// Equal returns whether the message contains the same addresses in the same
+// order as other.  Two nil messages are considered equal, while a nil message
+// is not equal to any other.
+func (msg *MsgAddr) Equal(other *MsgAddr) bool {
+	if msg == nil || other == nil {
+		return msg == other
+	}
+	if len(msg.AddrList) != len(other.AddrList) {
+		return false
+	}
+	for i, na := range msg.AddrList {
+		if !na.Equal(other.AddrList[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// GoString returns a Go-syntax representation of the message.  This is part
+// of the fmt.GoStringer interface implementation.
+func (msg *MsgAddr) GoString() string {
+	return goString(msg)
+}
+
 // NewMsgAddr returns a new bitcoin addr message that conforms to the
 // Message interface.  See MsgAddr for details.
 func NewMsgAddr() *MsgAddr {
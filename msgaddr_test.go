@@ -52,6 +52,20 @@ func TestAddr(t *testing.T) {
 			spew.Sprint(msg.AddrList[0]), spew.Sprint(na))
 	}
 
+	// Ensure only implausible timestamps are sanitized.
+	now := time.Unix(0x5c000000, 0)
+	bogus := &btcwire.NetAddress{Timestamp: now.Add(time.Hour)}
+	msg.AddAddress(bogus)
+	na.Timestamp = now
+	if n := msg.SanitizeTimestamps(now); n != 1 {
+		t.Errorf("SanitizeTimestamps: wrong number of sanitized "+
+			"addresses - got %v, want %v", n, 1)
+	}
+	if !na.Timestamp.Equal(now) || !bogus.Timestamp.Before(now) {
+		t.Errorf("SanitizeTimestamps: wrong timestamps - got %v and "+
+			"%v", na.Timestamp, bogus.Timestamp)
+	}
+
 	// Ensure the address list is cleared properly.
 	msg.ClearAddresses()
 	if len(msg.AddrList) != 0 {
@@ -102,6 +116,81 @@ func TestAddr(t *testing.T) {
 	return
 }
 
+// TestMergeAddrMessages ensures addresses are deduplicated by IP and port with
+// their newest timestamps and combined services, and split across messages.
+func TestMergeAddrMessages(t *testing.T) {
+	old := time.Unix(0x495fab29, 0)
+	newer := old.Add(time.Hour)
+	a := &btcwire.NetAddress{
+		Timestamp: old,
+		Services:  btcwire.SFNodeNetwork,
+		IP:        net.ParseIP("127.0.0.1"),
+		Port:      8333,
+	}
+	b := &btcwire.NetAddress{
+		Timestamp: newer,
+		Services:  btcwire.SFNodeCompression,
+		IP:        net.ParseIP("127.0.0.1").To4(),
+		Port:      8333,
+	}
+	c := &btcwire.NetAddress{
+		Timestamp: newer,
+		IP:        net.ParseIP("127.0.0.1"),
+		Port:      18333,
+	}
+	msg1 := btcwire.NewMsgAddr()
+	msg1.AddAddresses(a, c)
+	msg2 := btcwire.NewMsgAddr()
+	msg2.AddAddresses(b, c)
+
+	merged := btcwire.MergeAddrMessages(msg1, msg2)
+	if len(merged) != 1 || len(merged[0].AddrList) != 2 {
+		t.Fatalf("MergeAddrMessages: wrong result - got %v", merged)
+	}
+	got := merged[0].AddrList[0]
+	if !got.Timestamp.Equal(newer) ||
+		got.Services != btcwire.SFNodeNetwork|btcwire.SFNodeCompression ||
+		!got.IP.Equal(a.IP) || got.Port != 8333 {
+
+		t.Errorf("MergeAddrMessages: wrong merged address - got %v",
+			spew.Sdump(got))
+	}
+	if !merged[0].AddrList[1].Equal(c) {
+		t.Errorf("MergeAddrMessages: wrong second address - got %v",
+			spew.Sdump(merged[0].AddrList[1]))
+	}
+
+	// Ensure the provided addresses were not modified.
+	if !a.Timestamp.Equal(old) || a.Services != btcwire.SFNodeNetwork {
+		t.Errorf("MergeAddrMessages: provided address modified")
+	}
+
+	// Ensure large merges are split into messages of the maximum size.
+	big := btcwire.NewMsgAddr()
+	for i := 0; i < btcwire.MaxAddrPerMsg; i++ {
+		big.AddAddress(btcwire.NewNetAddressIPPort(
+			net.IPv4(10, 0, byte(i>>8), byte(i)), 8333, 0))
+	}
+	merged = btcwire.MergeAddrMessages(big, big, msg1)
+	tests := []int{btcwire.MaxAddrPerMsg, 2}
+	if len(merged) != len(tests) {
+		t.Fatalf("MergeAddrMessages: wrong number of messages - got "+
+			"%d, want %d", len(merged), len(tests))
+	}
+	t.Logf("Running %d tests", len(tests))
+	for i, want := range tests {
+		if got := len(merged[i].AddrList); got != want {
+			t.Errorf("MergeAddrMessages #%d: wrong number of "+
+				"addresses - got %d, want %d", i, got, want)
+		}
+	}
+
+	if merged := btcwire.MergeAddrMessages(); len(merged) != 0 {
+		t.Errorf("MergeAddrMessages: expected no messages, got %d",
+			len(merged))
+	}
+}
+
 // TestAddrWire tests the MsgAddr wire encode and decode for various numbers
 // of addreses and protocol versions.
 func TestAddrWire(t *testing.T) {
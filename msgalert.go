@@ -5,6 +5,7 @@
 package btcwire
 
 import (
+	"fmt"
 	"io"
 )
 
@@ -59,7 +60,7 @@ func (msg *MsgAlert) BtcEncode(w io.Writer, pver uint32) error {
 // Command returns the protocol command string for the message.  This is part
 // of the Message interface implementation.
 func (msg *MsgAlert) Command() string {
-	return cmdAlert
+	return CmdAlert
 }
 
 // MaxPayloadLength returns the maximum length the payload can be for the
@@ -67,14 +68,111 @@ func (msg *MsgAlert) Command() string {
 func (msg *MsgAlert) MaxPayloadLength(pver uint32) uint32 {
 	// Since this can vary depending on the message, make it the max
 	// size allowed.
-	return maxMessagePayload
+	return MaxMessagePayload
+}
+
+// SerializeSize returns the number of bytes it would take to encode the alert
+// message using the provided protocol version.  This is part of the Message
+// interface implementation.
+func (msg *MsgAlert) SerializeSize(pver uint32) int {
+	return varIntSerializeSize(uint64(len(msg.PayloadBlob))) +
+		len(msg.PayloadBlob) +
+		varIntSerializeSize(uint64(len(msg.Signature))) +
+		len(msg.Signature)
+}
+
+// Validate returns an error when the alert message would be rejected by
+// BtcEncode regardless of the protocol version.  This is part of the Message
+// interface implementation.
+func (msg *MsgAlert) Validate() error {
+	plen := msg.SerializeSize(ProtocolVersion)
+	if plen > MaxMessagePayload {
+		str := fmt.Sprintf("alert is too large [len %v, max %v]", plen,
+			MaxMessagePayload)
+		return messageError("MsgAlert.Validate", str)
+	}
+	return nil
+}
+
+// Equal returns whether the message has the same payload and signature as
+// other.  Two nil messages are considered equal, while a nil message is not
+// equal to any other.
+func (msg *MsgAlert) Equal(other *MsgAlert) bool {
+	if msg == nil || other == nil {
+		return msg == other
+	}
+	return msg.PayloadBlob == other.PayloadBlob &&
+		msg.Signature == other.Signature
+}
+
+// GoString returns a Go-syntax representation of the message.  This is part
+// of the fmt.GoStringer interface implementation.
+func (msg *MsgAlert) GoString() string {
+	return goString(msg)
 }
 
 // NewMsgAlert returns a new bitcoin alert message that conforms to the Message
-// interface.  See MsgAlert for details.
-func NewMsgAlert(payloadblob string, signature string) *MsgAlert {
+// interface.  An error is returned when the serialized payload and signature
+// would exceed MaxMessagePayload.  See MsgAlert for details.
+func NewMsgAlert(payloadblob string, signature string) (*MsgAlert, error) {
+	plen := VarIntSerializeSize(uint64(len(payloadblob))) +
+		len(payloadblob) + VarIntSerializeSize(uint64(len(signature))) +
+		len(signature)
+	if plen > MaxMessagePayload {
+		str := fmt.Sprintf("alert is too large [len %v, max %v]", plen,
+			MaxMessagePayload)
+		return nil, messageError("NewMsgAlert", str)
+	}
+
 	return &MsgAlert{
 		PayloadBlob: payloadblob,
 		Signature:   signature,
+	}, nil
+}
+
+// These constants define the final alert, which was broadcast with the alert
+// key when the alert system was retired.  It has the maximum ID and priority,
+// cancels every other alert, never expires, and applies to every version, so
+// nodes which still process alerts display its warning and disregard every
+// other alert.  Nodes which removed alert processing send it to peers which
+// still process alerts.
+const (
+	// FinalAlertPayloadBlob is the serialized payload of the final alert.
+	FinalAlertPayloadBlob = "\x01\x00\x00\x00" + // Version 1
+		"\x00\x00\x00\x00\x00\x00\x00\x00" + // RelayUntil 0
+		"\xff\xff\xff\x7f\x00\x00\x00\x00" + // Expiration 0x7fffffff
+		"\xff\xff\xff\x7f" + // ID 0x7fffffff
+		"\xfe\xff\xff\x7f" + // Cancel 0x7ffffffe
+		"\x01\xff\xff\xff\x7f" + // SetCancel {0x7fffffff}
+		"\x00\x00\x00\x00" + // MinVer 0
+		"\xff\xff\xff\x7f" + // MaxVer 0x7fffffff
+		"\x00" + // SetSubVer {}
+		"\xff\xff\xff\x7f" + // Priority 0x7fffffff
+		"\x00" + // Comment ""
+		"\x2f" + "URGENT: Alert key compromised, upgrade required" + // StatusBar
+		"\x00" // Reserved ""
+
+	// FinalAlertSignature is the signature of the final alert.
+	FinalAlertSignature = "" +
+		"\x30\x44\x02\x20\x65\x3f\xeb\xd6\x41\x0f\x47\x0f" +
+		"\x6b\xae\x11\xca\xd1\x9c\x48\x41\x3b\xec\xb1\xac" +
+		"\x2c\x17\xf9\x08\xfd\x0f\xd5\x3b\xdc\x3a\xbd\x52" +
+		"\x02\x20\x6d\x0e\x9c\x96\xfe\x88\xd4\xa0\xf0\x1e" +
+		"\xd9\xde\xda\xe2\xb6\xf9\xe0\x0d\xa9\x4c\xad\x0f" +
+		"\xec\xaa\xe6\x6e\xcf\x68\x9b\xf7\x1b\x50"
+)
+
+// IsFinalAlert returns whether the message is the final alert.  See
+// FinalAlertPayloadBlob.
+func (msg *MsgAlert) IsFinalAlert() bool {
+	return msg.PayloadBlob == FinalAlertPayloadBlob &&
+		msg.Signature == FinalAlertSignature
+}
+
+// NewFinalAlert returns a new final alert message.  See FinalAlertPayloadBlob.
+func NewFinalAlert() *MsgAlert {
+	return &MsgAlert{
+		PayloadBlob: FinalAlertPayloadBlob,
+		Signature:   FinalAlertSignature,
 	}
 }
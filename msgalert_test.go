@@ -6,10 +6,12 @@ package btcwire_test
 
 import (
 	"bytes"
+	"encoding/hex"
 	"github.com/conformal/btcwire"
 	"github.com/davecgh/go-spew/spew"
 	"io"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -20,7 +22,10 @@ func TestAlert(t *testing.T) {
 	signature := "some sig"
 
 	// Ensure we get the same payload and signature back out.
-	msg := btcwire.NewMsgAlert(payloadblob, signature)
+	msg, err := btcwire.NewMsgAlert(payloadblob, signature)
+	if err != nil {
+		t.Fatalf("NewMsgAlert: %v", err)
+	}
 	if msg.PayloadBlob != payloadblob {
 		t.Errorf("NewMsgAlert: wrong payloadblob - got %v, want %v",
 			msg.PayloadBlob, payloadblob)
@@ -46,13 +51,24 @@ func TestAlert(t *testing.T) {
 			maxPayload, wantPayload)
 	}
 
+	// Ensure an alert which can't fit in a message is rejected.
+	_, err = btcwire.NewMsgAlert(strings.Repeat("a",
+		btcwire.MaxMessagePayload), signature)
+	if _, ok := err.(*btcwire.MessageError); !ok {
+		t.Errorf("NewMsgAlert: wrong error for oversized alert - "+
+			"got %T(%v)", err, err)
+	}
+
 	return
 }
 
 // TestAlertWire tests the MsgAlert wire encode and decode for various protocol
 // versions.
 func TestAlertWire(t *testing.T) {
-	baseAlert := btcwire.NewMsgAlert("some payload", "somesig")
+	baseAlert, err := btcwire.NewMsgAlert("some payload", "somesig")
+	if err != nil {
+		t.Fatalf("NewMsgAlert: %v", err)
+	}
 	baseAlertEncoded := []byte{
 		0x0c, // Varint for payload length
 		0x73, 0x6f, 0x6d, 0x65, 0x20, 0x70, 0x61, 0x79,
@@ -144,7 +160,10 @@ func TestAlertWire(t *testing.T) {
 func TestAlertWireErrors(t *testing.T) {
 	pver := btcwire.ProtocolVersion
 
-	baseAlert := btcwire.NewMsgAlert("some payload", "somesig")
+	baseAlert, err := btcwire.NewMsgAlert("some payload", "somesig")
+	if err != nil {
+		t.Fatalf("NewMsgAlert: %v", err)
+	}
 	baseAlertEncoded := []byte{
 		0x0c, // Varint for payload length
 		0x73, 0x6f, 0x6d, 0x65, 0x20, 0x70, 0x61, 0x79,
@@ -213,3 +232,88 @@ func TestAlertWireErrors(t *testing.T) {
 		}
 	}
 }
+
+// TestFinalAlert ensures the final alert encodes to the payload broadcast by
+// the network and is recognized, including by the RejectAlerts read option.
+func TestFinalAlert(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcnet := btcwire.MainNet
+
+	// finalAlertPayload is the payload of the final alert message as sent
+	// by the reference implementation.
+	finalAlertPayload, _ := hex.DecodeString("60010000000000000000000000" +
+		"ffffff7f00000000ffffff7ffeffff7f01ffffff7f00000000ffffff7f00" +
+		"ffffff7f002f555247454e543a20416c657274206b657920636f6d70726f" +
+		"6d697365642c2075706772616465207265717569726564004630440220" +
+		"653febd6410f470f6bae11cad19c48413becb1ac2c17f908fd0fd53bdc3a" +
+		"bd5202206d0e9c96fe88d4a0f01ed9dedae2b6f9e00da94cad0fecaae66e" +
+		"cf689bf71b50")
+
+	final := btcwire.NewFinalAlert()
+	var buf bytes.Buffer
+	if err := final.BtcEncode(&buf, pver); err != nil {
+		t.Fatalf("BtcEncode: unexpected error %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), finalAlertPayload) {
+		t.Errorf("BtcEncode: wrong final alert payload\n got: %x want: %x",
+			buf.Bytes(), finalAlertPayload)
+	}
+
+	other, _ := btcwire.NewMsgAlert(btcwire.FinalAlertPayloadBlob,
+		"signature")
+	tests := []struct {
+		msg     *btcwire.MsgAlert // Alert to read
+		isFinal bool              // Expected result of IsFinalAlert
+	}{
+		{final, true},
+		{other, false},
+		{&btcwire.MsgAlert{}, false},
+	}
+
+	opts := &btcwire.ReadOptions{RejectAlerts: true}
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		if got := test.msg.IsFinalAlert(); got != test.isFinal {
+			t.Errorf("IsFinalAlert #%d: got %v, want %v", i, got,
+				test.isFinal)
+			continue
+		}
+
+		var buf bytes.Buffer
+		err := btcwire.WriteMessage(&buf, test.msg, pver, btcnet)
+		if err != nil {
+			t.Errorf("WriteMessage #%d error %v", i, err)
+			continue
+		}
+		raw := buf.Bytes()
+
+		// Ensure alerts are always decoded without the option.
+		_, _, err = btcwire.ReadMessage(bytes.NewReader(raw), pver,
+			btcnet)
+		if err != nil {
+			t.Errorf("ReadMessage #%d error %v", i, err)
+			continue
+		}
+
+		// Ensure only the final alert is decoded with the option.
+		msg, _, err := btcwire.ReadMessageWithOptions(
+			bytes.NewReader(raw), pver, btcnet, opts)
+		if test.isFinal {
+			if err != nil {
+				t.Errorf("ReadMessageWithOptions #%d error %v", i,
+					err)
+				continue
+			}
+			if !msg.(*btcwire.MsgAlert).IsFinalAlert() {
+				t.Errorf("ReadMessageWithOptions #%d: final "+
+					"alert not recognized", i)
+			}
+			continue
+		}
+		merr, ok := err.(*btcwire.MessageError)
+		if !ok || merr.Category != btcwire.ErrCategoryDeprecated {
+			t.Errorf("ReadMessageWithOptions #%d: wrong error - got "+
+				"%v, want ErrCategoryDeprecated", i, err)
+		}
+	}
+}
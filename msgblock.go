@@ -44,9 +44,18 @@ type TxLoc struct {
 // value, but if you are manually modifying the public members, you will need
 // to ensure you update the Header.TxnCount when you add and remove
 // transactions.
+//
+// Once SerializedBytes has been called, the serialized form of the block is
+// cached and used for all further encoding and by SerializeSize.  Call
+// InvalidateCache after modifying the block, including modifying any of its
+// transactions in place, to discard it.
 type MsgBlock struct {
 	Header       BlockHeader
 	Transactions []*MsgTx
+
+	// serialized holds the cached serialized bytes of the block, if any.
+	// See SerializedBytes.
+	serialized []byte
 }
 
 // AddTransaction adds a transaction to the message and updates Header.TxnCount
@@ -56,6 +65,7 @@ func (msg *MsgBlock) AddTransaction(tx *MsgTx) error {
 	// too large.
 	msg.Transactions = append(msg.Transactions, tx)
 	msg.Header.TxnCount = uint64(len(msg.Transactions))
+	msg.serialized = nil
 	return nil
 
 }
@@ -65,6 +75,7 @@ func (msg *MsgBlock) AddTransaction(tx *MsgTx) error {
 func (msg *MsgBlock) ClearTransactions() {
 	msg.Transactions = make([]*MsgTx, 0, defaultTransactionAlloc)
 	msg.Header.TxnCount = 0
+	msg.serialized = nil
 }
 
 // BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
@@ -72,6 +83,10 @@ func (msg *MsgBlock) ClearTransactions() {
 // See Deserialize for decoding blocks stored to disk, such as in a database, as
 // opposed to decoding blocks from the wire.
 func (msg *MsgBlock) BtcDecode(r io.Reader, pver uint32) error {
+	// Discard any cached serialized bytes since they belong to whatever
+	// the block held before.
+	msg.serialized = nil
+
 	err := readBlockHeader(r, pver, &msg.Header)
 	if err != nil {
 		return err
@@ -80,11 +95,29 @@ func (msg *MsgBlock) BtcDecode(r io.Reader, pver uint32) error {
 	// Prevent more transactions than could possibly fit into a block.
 	// It would be possible to cause memory exhaustion and panics without
 	// a sane upper bound on this count.
+	// The bound is raised accordingly when larger blocks have been allowed
+	// via ReadOptions.
+	maxTxs := uint64(maxTxPerBlock)
+	pr, ok := r.(*payloadReader)
+	if ok && pr.maxBlockPayload > MaxBlockPayload {
+		maxTxs = uint64(pr.maxBlockPayload/minTxPayload) + 1
+	}
 	txCount := msg.Header.TxnCount
-	if txCount > maxTxPerBlock {
+	if txCount > maxTxs {
 		str := fmt.Sprintf("too many transactions to fit into a block "+
-			"[count %d, max %d]", txCount, maxTxPerBlock)
-		return messageError("MsgBlock.BtcDecode", str)
+			"[count %d, max %d]", txCount, maxTxs)
+		return categorizedError("MsgBlock.BtcDecode", str,
+			ErrCategoryOversized)
+	}
+	err = checkCountFits(r, "MsgBlock.BtcDecode", "transactions", txCount,
+		minTxPayload)
+	if err != nil {
+		return err
+	}
+	err = reserveAlloc(r, "MsgBlock.BtcDecode", "transactions",
+		txCount*txAllocSize)
+	if err != nil {
+		return err
 	}
 
 	msg.Transactions = make([]*MsgTx, 0, txCount)
@@ -116,11 +149,47 @@ func (msg *MsgBlock) Deserialize(r io.Reader) error {
 	return msg.BtcDecode(r, 0)
 }
 
+// DeserializeAuxPow decodes a block from r into the receiver in the same
+// manner as Deserialize, except the block header is decoded as belonging to a
+// network which supports merged mining.  That is to say the auxiliary
+// proof-of-work which follows the header when it has AuxPowVersionBit set is
+// decoded into its AuxPow field.
+func (msg *MsgBlock) DeserializeAuxPow(r io.Reader) error {
+	return msg.BtcDecode(&payloadReader{Reader: r, auxPow: true}, 0)
+}
+
+// DeserializeBlockHeader decodes only the header and transaction count of a
+// block serialized in the same format Deserialize reads, without decoding any
+// of its transactions.  This is much cheaper than decoding the whole block,
+// which is useful for indexing or sanity checking blocks, such as those read
+// from block files.  The transaction count is returned along with the header
+// and is also set in its TxnCount field.  When r is not consumed any further,
+// it is left positioned at the start of the first transaction.
+//
+// An error is returned when the transaction count is larger than could fit in
+// a block.  Merged-mined blocks are not supported since their auxiliary
+// proof-of-work comes before the transaction count.
+func DeserializeBlockHeader(r io.Reader) (*BlockHeader, uint64, error) {
+	var bh BlockHeader
+	err := readBlockHeader(r, 0, &bh)
+	if err != nil {
+		return nil, 0, err
+	}
+	if bh.TxnCount > maxTxPerBlock {
+		str := fmt.Sprintf("too many transactions to fit into a block "+
+			"[count %d, max %d]", bh.TxnCount, maxTxPerBlock)
+		return nil, 0, categorizedError("DeserializeBlockHeader", str,
+			ErrCategoryOversized)
+	}
+	return &bh, bh.TxnCount, nil
+}
+
 // DeserializeTxLoc decodes r in the same manner Deserialize does, but it takes
 // a byte buffer instead of a generic reader and returns a slice containing the start and length of
 // each transaction within the raw data that is being deserialized.
 func (msg *MsgBlock) DeserializeTxLoc(r *bytes.Buffer) ([]TxLoc, error) {
 	fullLen := r.Len()
+	msg.serialized = nil
 
 	// At the current time, there is no difference between the wire encoding
 	// at protocol version 0 and the stable long-term storage format.  As
@@ -137,7 +206,8 @@ func (msg *MsgBlock) DeserializeTxLoc(r *bytes.Buffer) ([]TxLoc, error) {
 	if txCount > maxTxPerBlock {
 		str := fmt.Sprintf("too many transactions to fit into a block "+
 			"[count %d, max %d]", txCount, maxTxPerBlock)
-		return nil, messageError("MsgBlock.DeserializeTxLoc", str)
+		return nil, categorizedError("MsgBlock.DeserializeTxLoc",
+			str, ErrCategoryOversized)
 	}
 
 	// Deserialize each transaction while keeping track of its location
@@ -163,6 +233,12 @@ func (msg *MsgBlock) DeserializeTxLoc(r *bytes.Buffer) ([]TxLoc, error) {
 // See Serialize for encoding blocks to be stored to disk, such as in a
 // database, as opposed to encoding blocks for the wire.
 func (msg *MsgBlock) BtcEncode(w io.Writer, pver uint32) error {
+	// Write the cached serialized bytes when available.
+	if msg.serialized != nil {
+		_, err := w.Write(msg.serialized)
+		return err
+	}
+
 	msg.Header.TxnCount = uint64(len(msg.Transactions))
 
 	err := writeBlockHeader(w, pver, &msg.Header)
@@ -196,10 +272,44 @@ func (msg *MsgBlock) Serialize(w io.Writer) error {
 	return msg.BtcEncode(w, 0)
 }
 
+// SerializedBytes returns the serialized bytes of the block.  The result is
+// cached on the first call so subsequent calls, as well as BtcEncode and
+// Serialize, reuse the cached bytes instead of re-serializing the block.  This
+// is useful when relaying the same block to many peers.
+//
+// The returned slice must not be modified.  Call InvalidateCache after
+// modifying the header or any of the transactions so the cached bytes are not
+// used.
+func (msg *MsgBlock) SerializedBytes() ([]byte, error) {
+	if msg.serialized != nil {
+		return msg.serialized, nil
+	}
+
+	var buf bytes.Buffer
+	err := msg.Serialize(&buf)
+	if err != nil {
+		return nil, err
+	}
+	msg.serialized = buf.Bytes()
+	return msg.serialized, nil
+}
+
+// InvalidateCache discards the serialized bytes cached by SerializedBytes for
+// the block and each of its transactions, if any.  It must be called after
+// modifying a block for which the serialized bytes have been cached, including
+// after modifying one of its transactions in place, since the block can not
+// detect changes to its transactions.
+func (msg *MsgBlock) InvalidateCache() {
+	msg.serialized = nil
+	for _, tx := range msg.Transactions {
+		tx.InvalidateCache()
+	}
+}
+
 // Command returns the protocol command string for the message.  This is part
 // of the Message interface implementation.
 func (msg *MsgBlock) Command() string {
-	return cmdBlock
+	return CmdBlock
 }
 
 // MaxPayloadLength returns the maximum length the payload can be for the
@@ -210,23 +320,96 @@ func (msg *MsgBlock) MaxPayloadLength(pver uint32) uint32 {
 	return MaxBlockPayload
 }
 
+// SerializeSize returns the number of bytes it would take to encode the block
+// using the provided protocol version.  The size of the cached serialized
+// bytes is returned when available, since those are what BtcEncode writes, so
+// the result is stale after modifying the block until InvalidateCache is
+// called.  This is part of the Message interface implementation.
+func (msg *MsgBlock) SerializeSize(pver uint32) int {
+	// Use the cached serialized bytes when available.
+	if msg.serialized != nil {
+		return len(msg.serialized)
+	}
+
+	// The transaction count of the header is replaced by the actual number
+	// of transactions when encoding.
+	n := blockHashLen + varIntSerializeSize(uint64(len(msg.Transactions)))
+	if msg.Header.AuxPow != nil {
+		n += auxPowSerializeSize(pver, msg.Header.AuxPow)
+	}
+	for _, tx := range msg.Transactions {
+		n += tx.SerializeSize(pver)
+	}
+	return n
+}
+
+// Validate returns an error when the block would be rejected by BtcEncode
+// regardless of the protocol version.  This is part of the Message interface
+// implementation.
+func (msg *MsgBlock) Validate() error {
+	if len(msg.Transactions) > maxTxPerBlock {
+		str := fmt.Sprintf("too many transactions to fit into a block "+
+			"[count %d, max %d]", len(msg.Transactions), maxTxPerBlock)
+		return messageError("MsgBlock.Validate", str)
+	}
+
+	err := validateBlockHeader("MsgBlock.Validate", &msg.Header)
+	if err != nil {
+		return err
+	}
+	for _, tx := range msg.Transactions {
+		err := tx.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // BlockSha computes the block identifier hash for this block.
 func (msg *MsgBlock) BlockSha() (ShaHash, error) {
 	return msg.Header.BlockSha()
 }
 
-// TxShas returns a slice of hashes of all of transactions in this block.
+// TxShas returns a slice of hashes of all of transactions in this block in the
+// order they appear in the block.  The hashes are computed in a single pass
+// which reuses one serialization buffer for every transaction, and the
+// serialized bytes cached by MsgTx.SerializedBytes are used when available.
 func (msg *MsgBlock) TxShas() ([]ShaHash, error) {
-	shaList := make([]ShaHash, 0, len(msg.Transactions))
-	for _, tx := range msg.Transactions {
-		// Ignore error here since TxSha can't fail in the current
-		// implementation except due to run-time panics.
-		sha, _ := tx.TxSha()
-		shaList = append(shaList, sha)
+	shaList := make([]ShaHash, len(msg.Transactions))
+	var buf bytes.Buffer
+	for i, tx := range msg.Transactions {
+		// Ignore errors here since neither serializing to a buffer nor
+		// SetBytes can fail in the current implementation except due to
+		// run-time panics.
+		serialized := tx.serialized
+		if serialized == nil {
+			buf.Reset()
+			_ = tx.Serialize(&buf)
+			serialized = buf.Bytes()
+		}
+		_ = shaList[i].SetBytes(DoubleSha256(serialized))
 	}
 	return shaList, nil
 }
 
+// Equal returns whether the block has the same header and transactions as
+// other.  Cached serializations are ignored.  Two nil blocks are considered
+// equal, while a nil block is not equal to any other.
+func (msg *MsgBlock) Equal(other *MsgBlock) bool {
+	if msg == nil || other == nil {
+		return msg == other
+	}
+	return msg.Header.Equal(&other.Header) &&
+		txListEqual(msg.Transactions, other.Transactions)
+}
+
+// GoString returns a Go-syntax representation of the message.  This is part
+// of the fmt.GoStringer interface implementation.
+func (msg *MsgBlock) GoString() string {
+	return goString(msg)
+}
+
 // NewMsgBlock returns a new bitcoin block message that conforms to the
 // Message interface.  See MsgBlock for details.
 func NewMsgBlock(blockHeader *BlockHeader) *MsgBlock {
@@ -88,6 +88,32 @@ func TestBlockTxShas(t *testing.T) {
 		t.Errorf("TxShas: wrong transaction hashes - got %v, want %v",
 			spew.Sdump(shas), spew.Sdump(wantShas))
 	}
+
+	// Ensure the hashes for a block with multiple transactions match the
+	// individual transaction hashes in order, including when some of the
+	// transactions have cached serialized bytes.
+	block := btcwire.NewMsgBlock(&blockOne.Header)
+	block.AddTransaction(blockOne.Transactions[0])
+	block.AddTransaction(multiTx)
+	tx := multiTx.Copy()
+	tx.LockTime = 1
+	if _, err := tx.SerializedBytes(); err != nil {
+		t.Errorf("SerializedBytes: %v", err)
+	}
+	block.AddTransaction(tx)
+	wantShas = make([]btcwire.ShaHash, 0, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		sha, _ := tx.TxSha()
+		wantShas = append(wantShas, sha)
+	}
+	shas, err = block.TxShas()
+	if err != nil {
+		t.Errorf("TxShas: %v", err)
+	}
+	if !reflect.DeepEqual(shas, wantShas) {
+		t.Errorf("TxShas: wrong transaction hashes - got %v, want %v",
+			spew.Sdump(shas), spew.Sdump(wantShas))
+	}
 }
 
 // TestBlockSha tests the ability to generate the hash of a block accurately.
@@ -190,8 +216,8 @@ func TestBlockWire(t *testing.T) {
 			continue
 		}
 		if !reflect.DeepEqual(&msg, test.out) {
-			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
-				spew.Sdump(&msg), spew.Sdump(test.out))
+			t.Errorf("BtcDecode #%d\n%v", i,
+				btcwire.Diff(test.out, &msg))
 			continue
 		}
 	}
@@ -294,8 +320,8 @@ func TestBlockSerialize(t *testing.T) {
 			continue
 		}
 		if !reflect.DeepEqual(&block, test.out) {
-			t.Errorf("Deserialize #%d\n got: %s want: %s", i,
-				spew.Sdump(&block), spew.Sdump(test.out))
+			t.Errorf("Deserialize #%d\n%v", i,
+				btcwire.Diff(test.out, &block))
 			continue
 		}
 
@@ -309,8 +335,8 @@ func TestBlockSerialize(t *testing.T) {
 			continue
 		}
 		if !reflect.DeepEqual(&txLocBlock, test.out) {
-			t.Errorf("DeserializeTxLoc #%d\n got: %s want: %s", i,
-				spew.Sdump(&txLocBlock), spew.Sdump(test.out))
+			t.Errorf("DeserializeTxLoc #%d\n%v", i,
+				btcwire.Diff(test.out, &txLocBlock))
 			continue
 		}
 		if !reflect.DeepEqual(txLocs, test.txLocs) {
@@ -381,6 +407,159 @@ func TestBlockSerializeErrors(t *testing.T) {
 	}
 }
 
+// TestDeserializeBlockHeader tests decoding only the header and transaction
+// count of serialized blocks.
+func TestDeserializeBlockHeader(t *testing.T) {
+	tests := []struct {
+		buf     []byte               // Serialized block
+		header  *btcwire.BlockHeader // Expected header
+		txCount uint64               // Expected transaction count
+	}{
+		{genesisBlockBytes, &btcwire.GenesisBlock.Header, 1},
+		{blockOneBytes, &blockOne.Header, 1},
+		// Only the header and transaction count need to be present.
+		{blockOneBytes[:81], &blockOne.Header, 1},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		r := bytes.NewReader(test.buf)
+		bh, txCount, err := btcwire.DeserializeBlockHeader(r)
+		if err != nil {
+			t.Errorf("DeserializeBlockHeader #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(bh, test.header) {
+			t.Errorf("DeserializeBlockHeader #%d\n got: %s want: %s",
+				i, spew.Sdump(bh), spew.Sdump(test.header))
+			continue
+		}
+		if txCount != test.txCount {
+			t.Errorf("DeserializeBlockHeader #%d wrong tx count "+
+				"got: %d want: %d", i, txCount, test.txCount)
+			continue
+		}
+
+		// The reader is left at the first transaction.
+		if r.Len() != len(test.buf)-81 {
+			t.Errorf("DeserializeBlockHeader #%d wrong bytes "+
+				"remaining got: %d want: %d", i, r.Len(),
+				len(test.buf)-81)
+		}
+	}
+
+	// Ensure truncated headers and transaction counts larger than a block
+	// can hold are rejected.
+	for _, max := range []int{0, 4, 80} {
+		r := newFixedReader(max, blockOneBytes)
+		_, _, err := btcwire.DeserializeBlockHeader(r)
+		if err != io.EOF {
+			t.Errorf("DeserializeBlockHeader: wrong error for %d "+
+				"bytes got: %v want: %v", max, err, io.EOF)
+		}
+	}
+	buf := append([]byte{}, blockOneBytes[:80]...)
+	buf = append(buf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+	_, _, err := btcwire.DeserializeBlockHeader(bytes.NewReader(buf))
+	if _, ok := err.(*btcwire.MessageError); !ok {
+		t.Errorf("DeserializeBlockHeader: wrong error for oversized tx "+
+			"count got: %v", err)
+	}
+}
+
+// TestBlockSerializedBytes tests the serialization cache of MsgBlock.
+func TestBlockSerializedBytes(t *testing.T) {
+	var block btcwire.MsgBlock
+	err := block.Deserialize(bytes.NewBuffer(blockOneBytes))
+	if err != nil {
+		t.Fatalf("Deserialize: %v", err)
+	}
+
+	// Ensure the serialized bytes are the expected value.
+	serialized, err := block.SerializedBytes()
+	if err != nil {
+		t.Errorf("SerializedBytes: %v", err)
+	}
+	if !bytes.Equal(serialized, blockOneBytes) {
+		t.Errorf("SerializedBytes: wrong bytes\n got: %s want: %s",
+			spew.Sdump(serialized), spew.Sdump(blockOneBytes))
+	}
+
+	// Ensure the cached bytes are used for encoding and discarded once
+	// the cache is invalidated.
+	block.Header.Nonce++
+	var buf bytes.Buffer
+	err = block.BtcEncode(&buf, btcwire.ProtocolVersion)
+	if err != nil {
+		t.Errorf("BtcEncode: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), blockOneBytes) {
+		t.Errorf("BtcEncode: did not use cached bytes")
+	}
+	block.InvalidateCache()
+	buf.Reset()
+	err = block.BtcEncode(&buf, btcwire.ProtocolVersion)
+	if err != nil {
+		t.Errorf("BtcEncode: %v", err)
+	}
+	if bytes.Equal(buf.Bytes(), blockOneBytes) {
+		t.Errorf("BtcEncode: used cached bytes after InvalidateCache")
+	}
+
+	// Ensure the size of the cached bytes is reported until the cache is
+	// invalidated, and that invalidating the block also discards the
+	// caches of transactions modified in place.
+	serialized, err = block.SerializedBytes()
+	if err != nil {
+		t.Errorf("SerializedBytes: %v", err)
+	}
+	tx := block.Transactions[0]
+	if _, err := tx.SerializedBytes(); err != nil {
+		t.Errorf("SerializedBytes: %v", err)
+	}
+	tx.TxOut[0].PkScript = append(tx.TxOut[0].PkScript, 0x00)
+	if size := block.SerializeSize(0); size != len(serialized) {
+		t.Errorf("SerializeSize: got %d, want cached size %d", size,
+			len(serialized))
+	}
+	block.InvalidateCache()
+	if size := block.SerializeSize(0); size != len(serialized)+1 {
+		t.Errorf("SerializeSize: got %d after InvalidateCache, want %d",
+			size, len(serialized)+1)
+	}
+
+	// Ensure decoding into a block with cached bytes, including cached
+	// transaction bytes, discards them so the decoded block is encoded
+	// and hashed rather than the block it replaced.
+	if _, err := block.SerializedBytes(); err != nil {
+		t.Errorf("SerializedBytes: %v", err)
+	}
+	err = block.Deserialize(bytes.NewReader(blockOneBytes))
+	if err != nil {
+		t.Fatalf("Deserialize: %v", err)
+	}
+	buf.Reset()
+	err = block.BtcEncode(&buf, btcwire.ProtocolVersion)
+	if err != nil {
+		t.Errorf("BtcEncode: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), blockOneBytes) {
+		t.Errorf("BtcEncode: stale bytes after Deserialize\n got: %s "+
+			"want: %s", spew.Sdump(buf.Bytes()),
+			spew.Sdump(blockOneBytes))
+	}
+	if size := block.SerializeSize(0); size != len(blockOneBytes) {
+		t.Errorf("SerializeSize: got %d after Deserialize, want %d",
+			size, len(blockOneBytes))
+	}
+	gotShas, _ := block.TxShas()
+	wantShas, _ := blockOne.TxShas()
+	if !reflect.DeepEqual(gotShas, wantShas) {
+		t.Errorf("TxShas: stale hashes after Deserialize - got %v, "+
+			"want %v", gotShas, wantShas)
+	}
+}
+
 // TestBlockOverflowErrors  performs tests to ensure deserializing blocks which
 // are intentionally crafted to use large values for the number of transactions
 // are handled properly.  This could otherwise potentially be used as an attack
@@ -0,0 +1,87 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"io"
+)
+
+// MsgCheckOrder implements the Message interface and represents a checkorder
+// message of the retired IP transaction protocol.  It is sent by a payer to a
+// node accepting payments to its IP address with the order it intends to pay
+// for.  The node answers with a reply message (MsgReply) holding the public
+// key script to pay to.
+//
+// This message is only recognized once RegisterIPTransactionMessages has been
+// called and can only be decoded.
+type MsgCheckOrder struct {
+	// ReplyHash identifies the request so the reply can be matched to it.
+	ReplyHash ShaHash
+
+	// Order is the transaction paying for the order along with the order
+	// form.
+	Order WalletTx
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgCheckOrder) BtcDecode(r io.Reader, pver uint32) error {
+	_, err := io.ReadFull(r, msg.ReplyHash[:])
+	if err != nil {
+		return err
+	}
+	return readWalletTx(r, pver, "MsgCheckOrder.BtcDecode", &msg.Order)
+}
+
+// BtcEncode always returns an error since messages of the retired IP
+// transaction protocol can only be decoded.  This is part of the Message
+// interface implementation.
+func (msg *MsgCheckOrder) BtcEncode(w io.Writer, pver uint32) error {
+	return errDecodeOnly("MsgCheckOrder.BtcEncode")
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgCheckOrder) Command() string {
+	return CmdCheckOrder
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgCheckOrder) MaxPayloadLength(pver uint32) uint32 {
+	// Since the order includes any number of unconfirmed previous
+	// transactions, make it the max size allowed.
+	return MaxMessagePayload
+}
+
+// SerializeSize returns the number of bytes the checkorder message occupies
+// when encoded using the provided protocol version.  This is part of the
+// Message interface implementation.
+func (msg *MsgCheckOrder) SerializeSize(pver uint32) int {
+	return HashSize + walletTxSerializeSize(pver, &msg.Order)
+}
+
+// Validate always returns an error since the checkorder message is rejected by
+// BtcEncode.  This is part of the Message interface implementation.
+func (msg *MsgCheckOrder) Validate() error {
+	return errDecodeOnly("MsgCheckOrder.Validate")
+}
+
+// Equal returns whether the message has the same reply hash and order as
+// other.  Two nil messages are considered equal, while a nil message is not
+// equal to any other.
+func (msg *MsgCheckOrder) Equal(other *MsgCheckOrder) bool {
+	if msg == nil || other == nil {
+		return msg == other
+	}
+	return msg.ReplyHash == other.ReplyHash &&
+		walletTxEqual(&msg.Order, &other.Order)
+}
+
+// GoString returns a Go-syntax representation of the message.  This is part
+// of the fmt.GoStringer interface implementation.
+func (msg *MsgCheckOrder) GoString() string {
+	return goString(msg)
+}
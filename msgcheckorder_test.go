@@ -0,0 +1,104 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"io"
+	"testing"
+)
+
+// TestCheckOrder tests the MsgCheckOrder API.
+func TestCheckOrder(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+
+	// Ensure the command is expected value.
+	wantCmd := "checkorder"
+	msg := &btcwire.MsgCheckOrder{}
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("MsgCheckOrder: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	wantPayload := uint32(btcwire.MaxMessagePayload)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure messages are compared by their contents.
+	a := &btcwire.MsgCheckOrder{Order: walletTx}
+	b := &btcwire.MsgCheckOrder{Order: walletTx}
+	if !a.Equal(b) {
+		t.Errorf("Equal: messages with the same order are not equal")
+	}
+	b.Order.OrderForm = nil
+	if a.Equal(b) {
+		t.Errorf("Equal: messages with different orders are equal")
+	}
+}
+
+// TestCheckOrderWireErrors performs negative tests against the decoding of
+// MsgCheckOrder to confirm error paths work correctly.
+func TestCheckOrderWireErrors(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	encoded := append(append([]byte{}, btcwire.GenesisHash[:]...),
+		walletTxEncoded...)
+
+	// orderOffset is the offset of the order within the encoded message
+	// and branchOffset is the offset of its merkle branch.
+	orderOffset := btcwire.HashSize
+	branchOffset := orderOffset + len(multiTxEncoded) + btcwire.HashSize
+
+	// Encoded message with more merkle branch hashes than allowed.
+	longBranch := append([]byte{}, encoded[:branchOffset]...)
+	longBranch = append(longBranch, 0x21)
+
+	tests := []struct {
+		buf []byte // Wire encoding
+		err error  // Expected read error
+	}{
+		// Force error in reply hash.
+		{encoded[:0], io.EOF},
+		// Force error in transaction.
+		{encoded[:orderOffset], io.EOF},
+		// Force error in block hash.
+		{encoded[:branchOffset-1], io.ErrUnexpectedEOF},
+		// Force error in merkle branch count.
+		{encoded[:branchOffset], io.EOF},
+		// Force error with merkle branch hashes which don't fit.
+		{encoded[:branchOffset+1], &btcwire.MessageError{}},
+		// Force error in previous transactions count.
+		{encoded[:branchOffset+1+btcwire.HashSize+4], io.EOF},
+		// Force error with previous transactions which don't fit.
+		{encoded[:branchOffset+1+btcwire.HashSize+4+1],
+			&btcwire.MessageError{}},
+		// Force error in spent flag.
+		{encoded[:len(encoded)-1], io.EOF},
+		// Force error with too many merkle branch hashes.
+		{longBranch, &btcwire.MessageError{}},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		var msg btcwire.MsgCheckOrder
+		err := msg.BtcDecode(bytes.NewReader(test.buf), pver)
+		if _, ok := test.err.(*btcwire.MessageError); ok {
+			if _, ok := err.(*btcwire.MessageError); !ok {
+				t.Errorf("BtcDecode #%d wrong error got: %v, "+
+					"want: %T", i, err, test.err)
+			}
+			continue
+		}
+		if err != test.err {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.err)
+		}
+	}
+}
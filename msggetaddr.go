@@ -31,7 +31,7 @@ func (msg *MsgGetAddr) BtcEncode(w io.Writer, pver uint32) error {
 // Command returns the protocol command string for the message.  This is part
 // of the Message interface implementation.
 func (msg *MsgGetAddr) Command() string {
-	return cmdGetAddr
+	return CmdGetAddr
 }
 
 // MaxPayloadLength returns the maximum length the payload can be for the
@@ -40,6 +40,31 @@ func (msg *MsgGetAddr) MaxPayloadLength(pver uint32) uint32 {
 	return 0
 }
 
+// SerializeSize returns the number of bytes it would take to encode the getaddr
+// message using the provided protocol version, which is always zero.  This is
+// part of the Message interface implementation.
+func (msg *MsgGetAddr) SerializeSize(pver uint32) int {
+	return 0
+}
+
+// Validate returns nil since the getaddr message has no payload which could be
+// invalid.  This is part of the Message interface implementation.
+func (msg *MsgGetAddr) Validate() error {
+	return nil
+}
+
+// Equal returns whether the message is the same as other.  Since the message
+// has no fields, this is only the case when both or neither are nil.
+func (msg *MsgGetAddr) Equal(other *MsgGetAddr) bool {
+	return (msg == nil) == (other == nil)
+}
+
+// GoString returns a Go-syntax representation of the message.  This is part
+// of the fmt.GoStringer interface implementation.
+func (msg *MsgGetAddr) GoString() string {
+	return goString(msg)
+}
+
 // NewMsgGetAddr returns a new bitcoin getaddr message that conforms to the
 // Message interface.  See MsgGetAddr for details.
 func NewMsgGetAddr() *MsgGetAddr {
@@ -49,10 +49,11 @@ func (msg *MsgGetBlocks) AddBlockLocatorHash(hash *ShaHash) error {
 // BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
 // This is part of the Message interface implementation.
 func (msg *MsgGetBlocks) BtcDecode(r io.Reader, pver uint32) error {
-	err := readElement(r, &msg.ProtocolVersion)
+	protocolVersion, err := binarySerializer.Uint32(r, littleEndian)
 	if err != nil {
 		return err
 	}
+	msg.ProtocolVersion = protocolVersion
 
 	// Read num block locator hashes and limit to max.
 	count, err := readVarInt(r, pver)
@@ -62,17 +63,34 @@ func (msg *MsgGetBlocks) BtcDecode(r io.Reader, pver uint32) error {
 	if count > MaxBlockLocatorsPerMsg {
 		str := fmt.Sprintf("too many block locator hashes for message "+
 			"[count %v, max %v]", count, MaxBlockLocatorsPerMsg)
-		return messageError("MsgGetBlocks.BtcDecode", str)
+		return categorizedError("MsgGetBlocks.BtcDecode", str,
+			ErrCategoryOversized)
+	}
+	err = checkCountFits(r, "MsgGetBlocks.BtcDecode", "block locator hashes",
+		count, HashSize)
+	if err != nil {
+		return err
+	}
+	err = reserveAlloc(r, "MsgGetBlocks.BtcDecode", "block locator hashes",
+		count*hashAllocSize)
+	if err != nil {
+		return err
 	}
 
+	// Create a contiguous slice of hashes to deserialize into in order to
+	// reduce the number of allocations.
+	locatorHashes := make([]ShaHash, count)
 	msg.BlockLocatorHashes = make([]*ShaHash, 0, count)
 	for i := uint64(0); i < count; i++ {
-		sha := ShaHash{}
-		err := readElement(r, &sha)
+		sha := &locatorHashes[i]
+		err := readElement(r, sha)
+		if err != nil {
+			return err
+		}
+		err = msg.AddBlockLocatorHash(sha)
 		if err != nil {
 			return err
 		}
-		msg.AddBlockLocatorHash(&sha)
 	}
 
 	err = readElement(r, &msg.HashStop)
@@ -93,7 +111,7 @@ func (msg *MsgGetBlocks) BtcEncode(w io.Writer, pver uint32) error {
 		return messageError("MsgGetBlocks.BtcEncode", str)
 	}
 
-	err := writeElement(w, msg.ProtocolVersion)
+	err := binarySerializer.PutUint32(w, littleEndian, msg.ProtocolVersion)
 	if err != nil {
 		return err
 	}
@@ -121,7 +139,7 @@ func (msg *MsgGetBlocks) BtcEncode(w io.Writer, pver uint32) error {
 // Command returns the protocol command string for the message.  This is part
 // of the Message interface implementation.
 func (msg *MsgGetBlocks) Command() string {
-	return cmdGetBlocks
+	return CmdGetBlocks
 }
 
 // MaxPayloadLength returns the maximum length the payload can be for the
@@ -132,6 +150,48 @@ func (msg *MsgGetBlocks) MaxPayloadLength(pver uint32) uint32 {
 	return 4 + maxVarIntPayload + (MaxBlockLocatorsPerMsg * HashSize) + HashSize
 }
 
+// SerializeSize returns the number of bytes it would take to encode the
+// getblocks message using the provided protocol version.  This is part of the
+// Message interface implementation.
+func (msg *MsgGetBlocks) SerializeSize(pver uint32) int {
+	// Protocol version 4 bytes + serialized block locator hashes + hash
+	// stop.
+	count := len(msg.BlockLocatorHashes)
+	return 4 + varIntSerializeSize(uint64(count)) + count*HashSize +
+		HashSize
+}
+
+// Validate returns an error when the getblocks message would be rejected by
+// BtcEncode regardless of the protocol version.  This is part of the Message
+// interface implementation.
+func (msg *MsgGetBlocks) Validate() error {
+	count := len(msg.BlockLocatorHashes)
+	if count > MaxBlockLocatorsPerMsg {
+		str := fmt.Sprintf("too many block locator hashes for message "+
+			"[count %v, max %v]", count, MaxBlockLocatorsPerMsg)
+		return messageError("MsgGetBlocks.Validate", str)
+	}
+	return nil
+}
+
+// Equal returns whether the message has the same protocol version, block
+// locator hashes, and hash stop as other.  Two nil messages are considered
+// equal, while a nil message is not equal to any other.
+func (msg *MsgGetBlocks) Equal(other *MsgGetBlocks) bool {
+	if msg == nil || other == nil {
+		return msg == other
+	}
+	return msg.ProtocolVersion == other.ProtocolVersion &&
+		hashListEqual(msg.BlockLocatorHashes, other.BlockLocatorHashes) &&
+		msg.HashStop == other.HashStop
+}
+
+// GoString returns a Go-syntax representation of the message.  This is part
+// of the fmt.GoStringer interface implementation.
+func (msg *MsgGetBlocks) GoString() string {
+	return goString(msg)
+}
+
 // NewMsgGetBlocks returns a new bitcoin getblocks message that conforms to the
 // Message interface using the passed parameters and defaults for the remaining
 // fields.
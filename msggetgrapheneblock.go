@@ -0,0 +1,103 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"io"
+)
+
+// MsgGetGrapheneBlock implements the Message interface and represents a
+// get_grblk message of the experimental Graphene block relay protocol.  It is
+// used to request a block as a grblk message (MsgGrapheneBlock).  The number
+// of transactions in the mempool of the requesting peer lets the responding
+// peer size the bloom filter and invertible bloom lookup table of the
+// response.
+//
+// This message is not part of the bitcoin protocol and is only recognized once
+// RegisterGrapheneMessages has been called.
+type MsgGetGrapheneBlock struct {
+	// InvVect identifies the requested block.
+	InvVect InvVect
+
+	// MemPoolCount is the number of transactions in the mempool of the
+	// requesting peer.
+	MemPoolCount uint64
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgGetGrapheneBlock) BtcDecode(r io.Reader, pver uint32) error {
+	err := readInvVect(r, pver, &msg.InvVect)
+	if err != nil {
+		return err
+	}
+	msg.MemPoolCount, err = readVarInt(r, pver)
+	return err
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgGetGrapheneBlock) BtcEncode(w io.Writer, pver uint32) error {
+	err := writeInvVect(w, pver, &msg.InvVect)
+	if err != nil {
+		return err
+	}
+	return writeVarInt(w, pver, msg.MemPoolCount)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgGetGrapheneBlock) Command() string {
+	return CmdGetGrapheneBlock
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgGetGrapheneBlock) MaxPayloadLength(pver uint32) uint32 {
+	// Inventory vector + mempool count (varInt).
+	return maxInvVectPayload + maxVarIntPayload
+}
+
+// SerializeSize returns the number of bytes it would take to encode the
+// get_grblk message using the provided protocol version.  This is part of the
+// Message interface implementation.
+func (msg *MsgGetGrapheneBlock) SerializeSize(pver uint32) int {
+	return maxInvVectPayload + varIntSerializeSize(msg.MemPoolCount)
+}
+
+// Validate returns an error when the get_grblk message would be rejected by
+// BtcEncode regardless of the protocol version.  This is part of the Message
+// interface implementation.
+func (msg *MsgGetGrapheneBlock) Validate() error {
+	return nil
+}
+
+// Equal returns whether the message requests the same block with the same
+// memory pool count as other.  Two nil messages are considered equal, while a
+// nil message is not equal to any other.
+func (msg *MsgGetGrapheneBlock) Equal(other *MsgGetGrapheneBlock) bool {
+	if msg == nil || other == nil {
+		return msg == other
+	}
+	return msg.InvVect == other.InvVect &&
+		msg.MemPoolCount == other.MemPoolCount
+}
+
+// GoString returns a Go-syntax representation of the message.  This is part
+// of the fmt.GoStringer interface implementation.
+func (msg *MsgGetGrapheneBlock) GoString() string {
+	return goString(msg)
+}
+
+// NewMsgGetGrapheneBlock returns a new get_grblk message that conforms to the
+// Message interface using the provided inventory vector of the requested block
+// and number of transactions in the mempool.  See MsgGetGrapheneBlock for
+// details.
+func NewMsgGetGrapheneBlock(iv *InvVect, memPoolCount uint64) *MsgGetGrapheneBlock {
+	return &MsgGetGrapheneBlock{
+		InvVect:      *iv,
+		MemPoolCount: memPoolCount,
+	}
+}
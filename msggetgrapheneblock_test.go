@@ -0,0 +1,159 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// TestGetGrapheneBlock tests the MsgGetGrapheneBlock API.
+func TestGetGrapheneBlock(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+
+	// Ensure the command is expected value.
+	wantCmd := "get_grblk"
+	iv := btcwire.NewInvVect(btcwire.InvTypeBlock, &btcwire.GenesisHash)
+	msg := btcwire.NewMsgGetGrapheneBlock(iv, 0)
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgGetGrapheneBlock: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	// Inventory vector + mempool count (varInt).
+	wantPayload := uint32(45)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+}
+
+// TestGetGrapheneBlockWire tests the MsgGetGrapheneBlock wire encode and
+// decode.
+func TestGetGrapheneBlockWire(t *testing.T) {
+	iv := btcwire.NewInvVect(btcwire.InvTypeBlock, &btcwire.GenesisHash)
+	ivEncoded := []byte{
+		0x02, 0x00, 0x00, 0x00, // InvTypeBlock
+		0x6f, 0xe2, 0x8c, 0x0a, 0xb6, 0xf1, 0xb3, 0x72,
+		0xc1, 0xa6, 0xa2, 0x46, 0xae, 0x63, 0xf7, 0x4f,
+		0x93, 0x1e, 0x83, 0x65, 0xe1, 0x5a, 0x08, 0x9c,
+		0x68, 0xd6, 0x19, 0x00, 0x00, 0x00, 0x00, 0x00, // Genesis hash
+	}
+
+	// Message with an empty mempool.
+	emptyPool := btcwire.NewMsgGetGrapheneBlock(iv, 0)
+	emptyPoolEncoded := append([]byte{}, ivEncoded...)
+	emptyPoolEncoded = append(emptyPoolEncoded, 0x00) // Varint for mempool count
+
+	// Message with a large mempool.
+	largePool := btcwire.NewMsgGetGrapheneBlock(iv, 70000)
+	largePoolEncoded := append([]byte{}, ivEncoded...)
+	largePoolEncoded = append(largePoolEncoded,
+		0xfe, 0x70, 0x11, 0x01, 0x00, // Varint for mempool count
+	)
+
+	tests := []struct {
+		in   *btcwire.MsgGetGrapheneBlock // Message to encode
+		out  *btcwire.MsgGetGrapheneBlock // Expected decoded message
+		buf  []byte                       // Wire encoding
+		pver uint32                       // Protocol version for wire encoding
+	}{
+		// Latest protocol version with an empty mempool.
+		{emptyPool, emptyPool, emptyPoolEncoded, btcwire.ProtocolVersion},
+
+		// Latest protocol version with a large mempool.
+		{largePool, largePool, largePoolEncoded, btcwire.ProtocolVersion},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+		if size := test.in.SerializeSize(test.pver); size != len(test.buf) {
+			t.Errorf("SerializeSize #%d got: %d want: %d", i, size,
+				len(test.buf))
+		}
+
+		// Decode the message from wire format.
+		var msg btcwire.MsgGetGrapheneBlock
+		rbuf := bytes.NewBuffer(test.buf)
+		err = msg.BtcDecode(rbuf, test.pver)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+}
+
+// TestGetGrapheneBlockWireErrors performs negative tests against wire encode
+// and decode of MsgGetGrapheneBlock to confirm error paths work correctly.
+func TestGetGrapheneBlockWireErrors(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+
+	iv := btcwire.NewInvVect(btcwire.InvTypeBlock, &btcwire.GenesisHash)
+	baseGetGraphene := btcwire.NewMsgGetGrapheneBlock(iv, 1)
+	var baseGetGrapheneEncoded bytes.Buffer
+	baseGetGraphene.BtcEncode(&baseGetGrapheneEncoded, pver)
+
+	tests := []struct {
+		in       *btcwire.MsgGetGrapheneBlock // Value to encode
+		buf      []byte                       // Wire encoding
+		pver     uint32                       // Protocol version for wire encoding
+		max      int                          // Max size of fixed buffer to induce errors
+		writeErr error                        // Expected write error
+		readErr  error                        // Expected read error
+	}{
+		// Force error in inventory vector.
+		{baseGetGraphene, baseGetGrapheneEncoded.Bytes(), pver, 0,
+			io.ErrShortWrite, io.EOF},
+		// Force error in mempool count.
+		{baseGetGraphene, baseGetGrapheneEncoded.Bytes(), pver, 36,
+			io.ErrShortWrite, io.EOF},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		w := newFixedWriter(test.max)
+		err := test.in.BtcEncode(w, test.pver)
+		if err != test.writeErr {
+			t.Errorf("BtcEncode #%d wrong error got: %v, want: %v",
+				i, err, test.writeErr)
+			continue
+		}
+
+		// Decode from wire format.
+		var msg btcwire.MsgGetGrapheneBlock
+		r := newFixedReader(test.max, test.buf)
+		err = msg.BtcDecode(r, test.pver)
+		if err != test.readErr {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+	}
+}
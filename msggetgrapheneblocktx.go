@@ -0,0 +1,119 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"fmt"
+	"io"
+)
+
+// MsgGetGrapheneBlockTx implements the Message interface and represents a
+// get_grblktx message of the experimental Graphene block relay protocol.  It is
+// used to request the transactions of a block, identified by their cheap
+// hashes, which the requesting peer was unable to reconstruct from a grblk
+// message.  The transactions are sent in a grblktx message
+// (MsgGrapheneBlockTx).  See CheapHash.
+//
+// This message is not part of the bitcoin protocol and is only recognized once
+// RegisterGrapheneMessages has been called.
+type MsgGetGrapheneBlockTx struct {
+	// BlockHash identifies the block the transactions belong to.
+	BlockHash ShaHash
+
+	// TxHashes are the cheap hashes of the requested transactions.
+	TxHashes []uint64
+}
+
+// AddTxHash adds the cheap hash of a requested transaction to the message.
+func (msg *MsgGetGrapheneBlockTx) AddTxHash(hash uint64) error {
+	if len(msg.TxHashes)+1 > maxCheapHashesPerMsg {
+		str := fmt.Sprintf("too many transaction hashes for message "+
+			"[max %v]", maxCheapHashesPerMsg)
+		return messageError("MsgGetGrapheneBlockTx.AddTxHash", str)
+	}
+	msg.TxHashes = append(msg.TxHashes, hash)
+	return nil
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgGetGrapheneBlockTx) BtcDecode(r io.Reader, pver uint32) error {
+	_, err := io.ReadFull(r, msg.BlockHash[:])
+	if err != nil {
+		return err
+	}
+	msg.TxHashes, err = readCheapHashes(r, pver, "MsgGetGrapheneBlockTx.BtcDecode")
+	return err
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgGetGrapheneBlockTx) BtcEncode(w io.Writer, pver uint32) error {
+	err := validateCheapHashes("MsgGetGrapheneBlockTx.BtcEncode", msg.TxHashes)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(msg.BlockHash[:])
+	if err != nil {
+		return err
+	}
+	return writeCheapHashes(w, pver, "MsgGetGrapheneBlockTx.BtcEncode",
+		msg.TxHashes)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgGetGrapheneBlockTx) Command() string {
+	return CmdGetGrapheneBlockTx
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgGetGrapheneBlockTx) MaxPayloadLength(pver uint32) uint32 {
+	// Block hash + num cheap hashes (varInt) + max cheap hashes at 8 bytes
+	// each.
+	return HashSize + maxVarIntPayload + maxCheapHashesPerMsg*8
+}
+
+// SerializeSize returns the number of bytes it would take to encode the
+// get_grblktx message using the provided protocol version.  This is part of
+// the Message interface implementation.
+func (msg *MsgGetGrapheneBlockTx) SerializeSize(pver uint32) int {
+	return HashSize + varIntSerializeSize(uint64(len(msg.TxHashes))) +
+		len(msg.TxHashes)*8
+}
+
+// Validate returns an error when the get_grblktx message would be rejected by
+// BtcEncode regardless of the protocol version.  This is part of the Message
+// interface implementation.
+func (msg *MsgGetGrapheneBlockTx) Validate() error {
+	return validateCheapHashes("MsgGetGrapheneBlockTx.Validate", msg.TxHashes)
+}
+
+// Equal returns whether the message requests the same transactions of the
+// same block as other.  Two nil messages are considered equal, while a nil
+// message is not equal to any other.
+func (msg *MsgGetGrapheneBlockTx) Equal(other *MsgGetGrapheneBlockTx) bool {
+	if msg == nil || other == nil {
+		return msg == other
+	}
+	return msg.BlockHash == other.BlockHash &&
+		cheapHashesEqual(msg.TxHashes, other.TxHashes)
+}
+
+// GoString returns a Go-syntax representation of the message.  This is part
+// of the fmt.GoStringer interface implementation.
+func (msg *MsgGetGrapheneBlockTx) GoString() string {
+	return goString(msg)
+}
+
+// NewMsgGetGrapheneBlockTx returns a new get_grblktx message that conforms to
+// the Message interface for the block with the provided hash.  See
+// MsgGetGrapheneBlockTx for details.
+func NewMsgGetGrapheneBlockTx(blockHash *ShaHash) *MsgGetGrapheneBlockTx {
+	return &MsgGetGrapheneBlockTx{
+		BlockHash: *blockHash,
+	}
+}
@@ -46,10 +46,11 @@ func (msg *MsgGetHeaders) AddBlockLocatorHash(hash *ShaHash) error {
 // BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
 // This is part of the Message interface implementation.
 func (msg *MsgGetHeaders) BtcDecode(r io.Reader, pver uint32) error {
-	err := readElement(r, &msg.ProtocolVersion)
+	protocolVersion, err := binarySerializer.Uint32(r, littleEndian)
 	if err != nil {
 		return err
 	}
+	msg.ProtocolVersion = protocolVersion
 
 	// Read num block locator hashes and limit to max.
 	count, err := readVarInt(r, pver)
@@ -59,17 +60,34 @@ func (msg *MsgGetHeaders) BtcDecode(r io.Reader, pver uint32) error {
 	if count > MaxBlockLocatorsPerMsg {
 		str := fmt.Sprintf("too many block locator hashes for message "+
 			"[count %v, max %v]", count, MaxBlockLocatorsPerMsg)
-		return messageError("MsgGetHeaders.BtcDecode", str)
+		return categorizedError("MsgGetHeaders.BtcDecode", str,
+			ErrCategoryOversized)
+	}
+	err = checkCountFits(r, "MsgGetHeaders.BtcDecode", "block locator hashes",
+		count, HashSize)
+	if err != nil {
+		return err
+	}
+	err = reserveAlloc(r, "MsgGetHeaders.BtcDecode", "block locator hashes",
+		count*hashAllocSize)
+	if err != nil {
+		return err
 	}
 
+	// Create a contiguous slice of hashes to deserialize into in order to
+	// reduce the number of allocations.
+	locatorHashes := make([]ShaHash, count)
 	msg.BlockLocatorHashes = make([]*ShaHash, 0, count)
 	for i := uint64(0); i < count; i++ {
-		sha := ShaHash{}
-		err := readElement(r, &sha)
+		sha := &locatorHashes[i]
+		err := readElement(r, sha)
+		if err != nil {
+			return err
+		}
+		err = msg.AddBlockLocatorHash(sha)
 		if err != nil {
 			return err
 		}
-		msg.AddBlockLocatorHash(&sha)
 	}
 
 	err = readElement(r, &msg.HashStop)
@@ -91,7 +109,7 @@ func (msg *MsgGetHeaders) BtcEncode(w io.Writer, pver uint32) error {
 		return messageError("MsgGetHeaders.BtcEncode", str)
 	}
 
-	err := writeElement(w, msg.ProtocolVersion)
+	err := binarySerializer.PutUint32(w, littleEndian, msg.ProtocolVersion)
 	if err != nil {
 		return err
 	}
@@ -119,7 +137,7 @@ func (msg *MsgGetHeaders) BtcEncode(w io.Writer, pver uint32) error {
 // Command returns the protocol command string for the message.  This is part
 // of the Message interface implementation.
 func (msg *MsgGetHeaders) Command() string {
-	return cmdGetHeaders
+	return CmdGetHeaders
 }
 
 // MaxPayloadLength returns the maximum length the payload can be for the
@@ -130,6 +148,48 @@ func (msg *MsgGetHeaders) MaxPayloadLength(pver uint32) uint32 {
 	return 4 + maxVarIntPayload + (MaxBlockLocatorsPerMsg * HashSize) + HashSize
 }
 
+// SerializeSize returns the number of bytes it would take to encode the
+// getheaders message using the provided protocol version.  This is part of the
+// Message interface implementation.
+func (msg *MsgGetHeaders) SerializeSize(pver uint32) int {
+	// Protocol version 4 bytes + serialized block locator hashes + hash
+	// stop.
+	count := len(msg.BlockLocatorHashes)
+	return 4 + varIntSerializeSize(uint64(count)) + count*HashSize +
+		HashSize
+}
+
+// Validate returns an error when the getheaders message would be rejected by
+// BtcEncode regardless of the protocol version.  This is part of the Message
+// interface implementation.
+func (msg *MsgGetHeaders) Validate() error {
+	count := len(msg.BlockLocatorHashes)
+	if count > MaxBlockLocatorsPerMsg {
+		str := fmt.Sprintf("too many block locator hashes for message "+
+			"[count %v, max %v]", count, MaxBlockLocatorsPerMsg)
+		return messageError("MsgGetHeaders.Validate", str)
+	}
+	return nil
+}
+
+// Equal returns whether the message has the same protocol version, block
+// locator hashes, and hash stop as other.  Two nil messages are considered
+// equal, while a nil message is not equal to any other.
+func (msg *MsgGetHeaders) Equal(other *MsgGetHeaders) bool {
+	if msg == nil || other == nil {
+		return msg == other
+	}
+	return msg.ProtocolVersion == other.ProtocolVersion &&
+		hashListEqual(msg.BlockLocatorHashes, other.BlockLocatorHashes) &&
+		msg.HashStop == other.HashStop
+}
+
+// GoString returns a Go-syntax representation of the message.  This is part
+// of the fmt.GoStringer interface implementation.
+func (msg *MsgGetHeaders) GoString() string {
+	return goString(msg)
+}
+
 // NewMsgGetHeaders returns a new bitcoin getheaders message that conforms to
 // the Message interface.  See MsgGetHeaders for details.
 func NewMsgGetHeaders() *MsgGetHeaders {
@@ -0,0 +1,118 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"fmt"
+	"io"
+)
+
+// MsgGetXBlockTx implements the Message interface and represents a
+// get_xblocktx message of Xtreme Thinblocks (BUIP010).  It is used to request
+// the transactions of a block, identified by their cheap hashes, which the
+// requesting peer was unable to reconstruct from an xthinblock message.  The
+// transactions are sent in an xblocktx message (MsgXBlockTx).  See CheapHash.
+//
+// This message is not part of the bitcoin protocol and is only recognized once
+// RegisterXThinMessages has been called.
+type MsgGetXBlockTx struct {
+	// BlockHash identifies the block the transactions belong to.
+	BlockHash ShaHash
+
+	// TxHashes are the cheap hashes of the requested transactions.
+	TxHashes []uint64
+}
+
+// AddTxHash adds the cheap hash of a requested transaction to the message.
+func (msg *MsgGetXBlockTx) AddTxHash(hash uint64) error {
+	if len(msg.TxHashes)+1 > maxCheapHashesPerMsg {
+		str := fmt.Sprintf("too many transaction hashes for message "+
+			"[max %v]", maxCheapHashesPerMsg)
+		return messageError("MsgGetXBlockTx.AddTxHash", str)
+	}
+	msg.TxHashes = append(msg.TxHashes, hash)
+	return nil
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgGetXBlockTx) BtcDecode(r io.Reader, pver uint32) error {
+	_, err := io.ReadFull(r, msg.BlockHash[:])
+	if err != nil {
+		return err
+	}
+	msg.TxHashes, err = readCheapHashes(r, pver, "MsgGetXBlockTx.BtcDecode")
+	return err
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgGetXBlockTx) BtcEncode(w io.Writer, pver uint32) error {
+	err := validateCheapHashes("MsgGetXBlockTx.BtcEncode", msg.TxHashes)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(msg.BlockHash[:])
+	if err != nil {
+		return err
+	}
+	return writeCheapHashes(w, pver, "MsgGetXBlockTx.BtcEncode",
+		msg.TxHashes)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgGetXBlockTx) Command() string {
+	return CmdGetXBlockTx
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgGetXBlockTx) MaxPayloadLength(pver uint32) uint32 {
+	// Block hash + num cheap hashes (varInt) + max cheap hashes at 8 bytes
+	// each.
+	return HashSize + maxVarIntPayload + maxCheapHashesPerMsg*8
+}
+
+// SerializeSize returns the number of bytes it would take to encode the
+// get_xblocktx message using the provided protocol version.  This is part of
+// the Message interface implementation.
+func (msg *MsgGetXBlockTx) SerializeSize(pver uint32) int {
+	return HashSize + varIntSerializeSize(uint64(len(msg.TxHashes))) +
+		len(msg.TxHashes)*8
+}
+
+// Validate returns an error when the get_xblocktx message would be rejected by
+// BtcEncode regardless of the protocol version.  This is part of the Message
+// interface implementation.
+func (msg *MsgGetXBlockTx) Validate() error {
+	return validateCheapHashes("MsgGetXBlockTx.Validate", msg.TxHashes)
+}
+
+// Equal returns whether the message requests the same transactions of the
+// same block as other.  Two nil messages are considered equal, while a nil
+// message is not equal to any other.
+func (msg *MsgGetXBlockTx) Equal(other *MsgGetXBlockTx) bool {
+	if msg == nil || other == nil {
+		return msg == other
+	}
+	return msg.BlockHash == other.BlockHash &&
+		cheapHashesEqual(msg.TxHashes, other.TxHashes)
+}
+
+// GoString returns a Go-syntax representation of the message.  This is part
+// of the fmt.GoStringer interface implementation.
+func (msg *MsgGetXBlockTx) GoString() string {
+	return goString(msg)
+}
+
+// NewMsgGetXBlockTx returns a new get_xblocktx message that conforms to the
+// Message interface for the block with the provided hash.  See
+// MsgGetXBlockTx for details.
+func NewMsgGetXBlockTx(blockHash *ShaHash) *MsgGetXBlockTx {
+	return &MsgGetXBlockTx{
+		BlockHash: *blockHash,
+	}
+}
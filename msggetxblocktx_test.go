@@ -0,0 +1,209 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// TestGetXBlockTx tests the MsgGetXBlockTx API.
+func TestGetXBlockTx(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+
+	// Ensure the command is expected value.
+	wantCmd := "get_xblocktx"
+	msg := btcwire.NewMsgGetXBlockTx(&btcwire.GenesisHash)
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgGetXBlockTx: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	// Block hash + num cheap hashes (varInt) + max cheap hashes.
+	wantPayload := uint32(800049)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure transaction hashes are added properly.
+	err := msg.AddTxHash(0x0102030405060708)
+	if err != nil {
+		t.Errorf("AddTxHash: %v", err)
+	}
+	if msg.TxHashes[0] != 0x0102030405060708 {
+		t.Errorf("AddTxHash: wrong tx hash added - got %x, want %x",
+			msg.TxHashes[0], 0x0102030405060708)
+	}
+
+	// Ensure adding more than the max allowed transaction hashes per
+	// message returns an error.
+	for i := 0; i < 1000000/10+1; i++ {
+		err = msg.AddTxHash(0)
+	}
+	if err == nil {
+		t.Errorf("AddTxHash: expected error on too many transaction " +
+			"hashes not received")
+	}
+}
+
+// TestGetXBlockTxWire tests the MsgGetXBlockTx wire encode and decode.
+func TestGetXBlockTxWire(t *testing.T) {
+	// Message with no transaction hashes.
+	noHashes := btcwire.NewMsgGetXBlockTx(&btcwire.GenesisHash)
+	noHashes.TxHashes = []uint64{}
+	noHashesEncoded := append([]byte{}, btcwire.GenesisHash[:]...)
+	noHashesEncoded = append(noHashesEncoded, 0x00) // Varint for number of tx hashes
+
+	// Message with multiple transaction hashes.
+	multiHashes := btcwire.NewMsgGetXBlockTx(&btcwire.GenesisHash)
+	multiHashes.AddTxHash(0x0102030405060708)
+	multiHashes.AddTxHash(0xffeeddccbbaa9988)
+	multiHashesEncoded := append([]byte{}, btcwire.GenesisHash[:]...)
+	multiHashesEncoded = append(multiHashesEncoded,
+		0x02,                                           // Varint for number of tx hashes
+		0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01, // Tx hash
+		0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff, // Tx hash
+	)
+
+	tests := []struct {
+		in   *btcwire.MsgGetXBlockTx // Message to encode
+		out  *btcwire.MsgGetXBlockTx // Expected decoded message
+		buf  []byte                  // Wire encoding
+		pver uint32                  // Protocol version for wire encoding
+	}{
+		// Latest protocol version with no transaction hashes.
+		{noHashes, noHashes, noHashesEncoded, btcwire.ProtocolVersion},
+
+		// Latest protocol version with multiple transaction hashes.
+		{multiHashes, multiHashes, multiHashesEncoded,
+			btcwire.ProtocolVersion},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+		if size := test.in.SerializeSize(test.pver); size != len(test.buf) {
+			t.Errorf("SerializeSize #%d got: %d want: %d", i, size,
+				len(test.buf))
+		}
+
+		// Decode the message from wire format.
+		var msg btcwire.MsgGetXBlockTx
+		rbuf := bytes.NewBuffer(test.buf)
+		err = msg.BtcDecode(rbuf, test.pver)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+}
+
+// TestGetXBlockTxWireErrors performs negative tests against wire encode and
+// decode of MsgGetXBlockTx to confirm error paths work correctly.
+func TestGetXBlockTxWireErrors(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcwireErr := &btcwire.MessageError{}
+
+	baseGetXBlockTx := btcwire.NewMsgGetXBlockTx(&btcwire.GenesisHash)
+	baseGetXBlockTx.AddTxHash(0x0102030405060708)
+	baseGetXBlockTxEncoded := append([]byte{}, btcwire.GenesisHash[:]...)
+	baseGetXBlockTxEncoded = append(baseGetXBlockTxEncoded,
+		0x01,                                           // Varint for number of tx hashes
+		0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01, // Tx hash
+	)
+
+	// Message that forces an error by having more than the max allowed
+	// transaction hashes.
+	maxHashes := btcwire.NewMsgGetXBlockTx(&btcwire.GenesisHash)
+	maxHashes.TxHashes = make([]uint64, 1000000/10+2)
+	maxHashesEncoded := append([]byte{}, btcwire.GenesisHash[:]...)
+	maxHashesEncoded = append(maxHashesEncoded,
+		0xfe, 0xa2, 0x86, 0x01, 0x00, // Varint for number of tx hashes (100002)
+	)
+
+	tests := []struct {
+		in       *btcwire.MsgGetXBlockTx // Value to encode
+		buf      []byte                  // Wire encoding
+		pver     uint32                  // Protocol version for wire encoding
+		max      int                     // Max size of fixed buffer to induce errors
+		writeErr error                   // Expected write error
+		readErr  error                   // Expected read error
+	}{
+		// Force error in block hash.
+		{baseGetXBlockTx, baseGetXBlockTxEncoded, pver, 0, io.ErrShortWrite, io.EOF},
+		// Force error in tx hash count.
+		{baseGetXBlockTx, baseGetXBlockTxEncoded, pver, 32, io.ErrShortWrite, io.EOF},
+		// Force error in tx hashes.
+		{baseGetXBlockTx, baseGetXBlockTxEncoded, pver, 33, io.ErrShortWrite, io.EOF},
+		// Force error with greater than max tx hashes.
+		{maxHashes, maxHashesEncoded, pver, 37, btcwireErr, btcwireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		w := newFixedWriter(test.max)
+		err := test.in.BtcEncode(w, test.pver)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+			t.Errorf("BtcEncode #%d wrong error got: %v, want: %v",
+				i, err, test.writeErr)
+			continue
+		}
+
+		// For errors which are not of type btcwire.MessageError, check
+		// them for equality.
+		if _, ok := err.(*btcwire.MessageError); !ok {
+			if err != test.writeErr {
+				t.Errorf("BtcEncode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.writeErr)
+				continue
+			}
+		}
+
+		// Decode from wire format.
+		var msg btcwire.MsgGetXBlockTx
+		r := newFixedReader(test.max, test.buf)
+		err = msg.BtcDecode(r, test.pver)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+
+		// For errors which are not of type btcwire.MessageError, check
+		// them for equality.
+		if _, ok := err.(*btcwire.MessageError); !ok {
+			if err != test.readErr {
+				t.Errorf("BtcDecode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.readErr)
+				continue
+			}
+		}
+	}
+}
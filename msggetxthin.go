@@ -0,0 +1,132 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"bytes"
+	"io"
+)
+
+const (
+	// MaxXThinFilterSize is the maximum number of bytes the bloom filter of
+	// a get_xthin message can be.  Unlike the filters of BIP0037, the size
+	// of the filter scales with the size of the mempool of the requesting
+	// peer, so it is only limited to the maximum block payload.
+	MaxXThinFilterSize = MaxBlockPayload
+
+	// MaxXThinFilterHashFuncs is the maximum number of hash functions the
+	// bloom filter of a get_xthin message can use.
+	MaxXThinFilterHashFuncs = 50
+)
+
+// MsgGetXThin implements the Message interface and represents a get_xthin
+// message of Xtreme Thinblocks (BUIP010).  It is used to request a block as an
+// xthinblock message (MsgXThinBlock).  The bloom filter contains the
+// transactions the requesting peer already has in its mempool, so the
+// responding peer only needs to send the transactions of the block which do not
+// match it.  The filter uses the same parameters as BIP0037 filters.
+//
+// This message is not part of the bitcoin protocol and is only recognized once
+// RegisterXThinMessages has been called.
+type MsgGetXThin struct {
+	// InvVect identifies the requested block.
+	InvVect InvVect
+
+	// Filter, HashFuncs, Tweak, and Flags make up the bloom filter of the
+	// transactions the requesting peer already has.
+	Filter    []byte
+	HashFuncs uint32
+	Tweak     uint32
+	Flags     uint8
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgGetXThin) BtcDecode(r io.Reader, pver uint32) error {
+	err := readInvVect(r, pver, &msg.InvVect)
+	if err != nil {
+		return err
+	}
+	return readRelayFilter(r, pver, "MsgGetXThin.BtcDecode", &msg.Filter,
+		&msg.HashFuncs, &msg.Tweak, &msg.Flags)
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgGetXThin) BtcEncode(w io.Writer, pver uint32) error {
+	err := validateRelayFilter("MsgGetXThin.BtcEncode", msg.Filter,
+		msg.HashFuncs)
+	if err != nil {
+		return err
+	}
+
+	err = writeInvVect(w, pver, &msg.InvVect)
+	if err != nil {
+		return err
+	}
+	return writeRelayFilter(w, pver, msg.Filter, msg.HashFuncs, msg.Tweak,
+		msg.Flags)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgGetXThin) Command() string {
+	return CmdGetXThin
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgGetXThin) MaxPayloadLength(pver uint32) uint32 {
+	// Inventory vector + num filter bytes (varInt) + filter + hash funcs
+	// 4 bytes + tweak 4 bytes + flags 1 byte.
+	return maxInvVectPayload + maxVarIntPayload + MaxXThinFilterSize + 9
+}
+
+// SerializeSize returns the number of bytes it would take to encode the
+// get_xthin message using the provided protocol version.  This is part of the
+// Message interface implementation.
+func (msg *MsgGetXThin) SerializeSize(pver uint32) int {
+	return maxInvVectPayload + relayFilterSerializeSize(msg.Filter)
+}
+
+// Validate returns an error when the get_xthin message would be rejected by
+// BtcEncode regardless of the protocol version.  This is part of the Message
+// interface implementation.
+func (msg *MsgGetXThin) Validate() error {
+	return validateRelayFilter("MsgGetXThin.Validate", msg.Filter,
+		msg.HashFuncs)
+}
+
+// Equal returns whether the message requests the same block with the same
+// filter as other.  Two nil messages are considered equal, while a nil message
+// is not equal to any other.
+func (msg *MsgGetXThin) Equal(other *MsgGetXThin) bool {
+	if msg == nil || other == nil {
+		return msg == other
+	}
+	return msg.InvVect == other.InvVect &&
+		bytes.Equal(msg.Filter, other.Filter) &&
+		msg.HashFuncs == other.HashFuncs && msg.Tweak == other.Tweak &&
+		msg.Flags == other.Flags
+}
+
+// GoString returns a Go-syntax representation of the message.  This is part
+// of the fmt.GoStringer interface implementation.
+func (msg *MsgGetXThin) GoString() string {
+	return goString(msg)
+}
+
+// NewMsgGetXThin returns a new get_xthin message that conforms to the Message
+// interface using the provided inventory vector of the requested block and
+// bloom filter parameters.  See MsgGetXThin for details.
+func NewMsgGetXThin(iv *InvVect, filter []byte, hashFuncs, tweak uint32, flags uint8) *MsgGetXThin {
+	return &MsgGetXThin{
+		InvVect:   *iv,
+		Filter:    filter,
+		HashFuncs: hashFuncs,
+		Tweak:     tweak,
+		Flags:     flags,
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// TestGetXThin tests the MsgGetXThin API.
+func TestGetXThin(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+
+	// Ensure the command is expected value.
+	wantCmd := "get_xthin"
+	iv := btcwire.NewInvVect(btcwire.InvTypeBlock, &btcwire.GenesisHash)
+	msg := btcwire.NewMsgGetXThin(iv, []byte{0x01}, 10, 0, 0)
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgGetXThin: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	// Inventory vector + num filter bytes (varInt) + max filter size +
+	// hash funcs + tweak + flags.
+	wantPayload := uint32(1000054)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure too many hash functions are rejected.
+	if err := msg.Validate(); err != nil {
+		t.Errorf("Validate: unexpected error %v", err)
+	}
+	msg.HashFuncs = btcwire.MaxXThinFilterHashFuncs + 1
+	if err := msg.Validate(); err == nil {
+		t.Errorf("Validate: expected error on too many hash functions " +
+			"not received")
+	}
+}
+
+// TestGetXThinWire tests the MsgGetXThin wire encode and decode.
+func TestGetXThinWire(t *testing.T) {
+	iv := btcwire.NewInvVect(btcwire.InvTypeBlock, &btcwire.GenesisHash)
+
+	// Message with an empty filter.
+	noFilter := btcwire.NewMsgGetXThin(iv, []byte{}, 0, 0, 0)
+	noFilterEncoded := []byte{
+		0x02, 0x00, 0x00, 0x00, // InvTypeBlock
+		0x6f, 0xe2, 0x8c, 0x0a, 0xb6, 0xf1, 0xb3, 0x72,
+		0xc1, 0xa6, 0xa2, 0x46, 0xae, 0x63, 0xf7, 0x4f,
+		0x93, 0x1e, 0x83, 0x65, 0xe1, 0x5a, 0x08, 0x9c,
+		0x68, 0xd6, 0x19, 0x00, 0x00, 0x00, 0x00, 0x00, // Genesis hash
+		0x00,                   // Varint for size of filter
+		0x00, 0x00, 0x00, 0x00, // Hash funcs
+		0x00, 0x00, 0x00, 0x00, // Tweak
+		0x00, // Flags
+	}
+
+	// Message with a filter.
+	withFilter := btcwire.NewMsgGetXThin(iv, []byte{0xb5, 0x0f}, 11,
+		0x01020304, 1)
+	withFilterEncoded := []byte{
+		0x02, 0x00, 0x00, 0x00, // InvTypeBlock
+		0x6f, 0xe2, 0x8c, 0x0a, 0xb6, 0xf1, 0xb3, 0x72,
+		0xc1, 0xa6, 0xa2, 0x46, 0xae, 0x63, 0xf7, 0x4f,
+		0x93, 0x1e, 0x83, 0x65, 0xe1, 0x5a, 0x08, 0x9c,
+		0x68, 0xd6, 0x19, 0x00, 0x00, 0x00, 0x00, 0x00, // Genesis hash
+		0x02,       // Varint for size of filter
+		0xb5, 0x0f, // Filter
+		0x0b, 0x00, 0x00, 0x00, // Hash funcs
+		0x04, 0x03, 0x02, 0x01, // Tweak
+		0x01, // Flags
+	}
+
+	tests := []struct {
+		in   *btcwire.MsgGetXThin // Message to encode
+		out  *btcwire.MsgGetXThin // Expected decoded message
+		buf  []byte               // Wire encoding
+		pver uint32               // Protocol version for wire encoding
+	}{
+		// Latest protocol version with an empty filter.
+		{noFilter, noFilter, noFilterEncoded, btcwire.ProtocolVersion},
+
+		// Latest protocol version with a filter.
+		{withFilter, withFilter, withFilterEncoded, btcwire.ProtocolVersion},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+		if size := test.in.SerializeSize(test.pver); size != len(test.buf) {
+			t.Errorf("SerializeSize #%d got: %d want: %d", i, size,
+				len(test.buf))
+		}
+
+		// Decode the message from wire format.
+		var msg btcwire.MsgGetXThin
+		rbuf := bytes.NewBuffer(test.buf)
+		err = msg.BtcDecode(rbuf, test.pver)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+}
+
+// TestGetXThinWireErrors performs negative tests against wire encode and
+// decode of MsgGetXThin to confirm error paths work correctly.
+func TestGetXThinWireErrors(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcwireErr := &btcwire.MessageError{}
+
+	iv := btcwire.NewInvVect(btcwire.InvTypeBlock, &btcwire.GenesisHash)
+	baseGetXThin := btcwire.NewMsgGetXThin(iv, []byte{0xb5}, 11, 0, 0)
+	baseGetXThinEncoded := []byte{
+		0x02, 0x00, 0x00, 0x00, // InvTypeBlock
+		0x6f, 0xe2, 0x8c, 0x0a, 0xb6, 0xf1, 0xb3, 0x72,
+		0xc1, 0xa6, 0xa2, 0x46, 0xae, 0x63, 0xf7, 0x4f,
+		0x93, 0x1e, 0x83, 0x65, 0xe1, 0x5a, 0x08, 0x9c,
+		0x68, 0xd6, 0x19, 0x00, 0x00, 0x00, 0x00, 0x00, // Genesis hash
+		0x01,                   // Varint for size of filter
+		0xb5,                   // Filter
+		0x0b, 0x00, 0x00, 0x00, // Hash funcs
+		0x00, 0x00, 0x00, 0x00, // Tweak
+		0x00, // Flags
+	}
+
+	// Message that forces an error by having too many hash functions.
+	maxHashFuncs := btcwire.NewMsgGetXThin(iv, []byte{0xb5}, 51, 0, 0)
+	maxHashFuncsEncoded := append([]byte{}, baseGetXThinEncoded...)
+	maxHashFuncsEncoded[38] = 51
+
+	// Message that forces an error by having a filter larger than the max
+	// allowed.
+	maxFilter := btcwire.NewMsgGetXThin(iv,
+		make([]byte, btcwire.MaxXThinFilterSize+1), 0, 0, 0)
+	maxFilterEncoded := append([]byte{}, baseGetXThinEncoded[:36]...)
+	maxFilterEncoded = append(maxFilterEncoded, 0xfe, 0x41, 0x42, 0x0f, 0x00)
+
+	tests := []struct {
+		in       *btcwire.MsgGetXThin // Value to encode
+		buf      []byte               // Wire encoding
+		pver     uint32               // Protocol version for wire encoding
+		max      int                  // Max size of fixed buffer to induce errors
+		writeErr error                // Expected write error
+		readErr  error                // Expected read error
+	}{
+		// Force error in inventory vector.
+		{baseGetXThin, baseGetXThinEncoded, pver, 0, io.ErrShortWrite, io.EOF},
+		// Force error in filter size.
+		{baseGetXThin, baseGetXThinEncoded, pver, 36, io.ErrShortWrite, io.EOF},
+		// Force error in filter.
+		{baseGetXThin, baseGetXThinEncoded, pver, 37, io.ErrShortWrite, io.EOF},
+		// Force error in hash funcs.
+		{baseGetXThin, baseGetXThinEncoded, pver, 38, io.ErrShortWrite, io.EOF},
+		// Force error in tweak.
+		{baseGetXThin, baseGetXThinEncoded, pver, 42, io.ErrShortWrite, io.EOF},
+		// Force error in flags.
+		{baseGetXThin, baseGetXThinEncoded, pver, 46, io.ErrShortWrite, io.EOF},
+		// Force error with too many hash functions.
+		{maxHashFuncs, maxHashFuncsEncoded, pver, 47, btcwireErr, btcwireErr},
+		// Force error with greater than max filter size.
+		{maxFilter, maxFilterEncoded, pver, 41, btcwireErr, btcwireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		w := newFixedWriter(test.max)
+		err := test.in.BtcEncode(w, test.pver)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+			t.Errorf("BtcEncode #%d wrong error got: %v, want: %v",
+				i, err, test.writeErr)
+			continue
+		}
+
+		// For errors which are not of type btcwire.MessageError, check
+		// them for equality.
+		if _, ok := err.(*btcwire.MessageError); !ok {
+			if err != test.writeErr {
+				t.Errorf("BtcEncode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.writeErr)
+				continue
+			}
+		}
+
+		// Decode from wire format.
+		var msg btcwire.MsgGetXThin
+		r := newFixedReader(test.max, test.buf)
+		err = msg.BtcDecode(r, test.pver)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+
+		// For errors which are not of type btcwire.MessageError, check
+		// them for equality.
+		if _, ok := err.(*btcwire.MessageError); !ok {
+			if err != test.readErr {
+				t.Errorf("BtcDecode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.readErr)
+				continue
+			}
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// MsgGrapheneBlock implements the Message interface and represents a grblk
+// message of the experimental Graphene block relay protocol.  It is sent in
+// response to a get_grblk message (MsgGetGrapheneBlock) and describes a block
+// by its header, a bloom filter of its transactions, and an invertible bloom
+// lookup table of their cheap hashes, which the receiving peer uses to
+// reconcile the block with its mempool.  Transactions the receiving peer is not
+// expected to have are sent in full.  See CheapHash.
+//
+// This message is not part of the bitcoin protocol and is only recognized once
+// RegisterGrapheneMessages has been called.
+type MsgGrapheneBlock struct {
+	// Header is the header of the block.  Its transaction count is not
+	// encoded.
+	Header BlockHeader
+
+	// BlockTxCount is the number of transactions in the block.
+	BlockTxCount uint64
+
+	// Filter, HashFuncs, Tweak, and Flags make up the bloom filter of the
+	// transactions of the block.  The filter uses the same parameters as
+	// BIP0037 filters.
+	Filter    []byte
+	HashFuncs uint32
+	Tweak     uint32
+	Flags     uint8
+
+	// IBLT is the invertible bloom lookup table of the cheap hashes of the
+	// transactions of the block.
+	IBLT IBLT
+
+	// AdditionalTxs are the transactions of the block which the receiving
+	// peer is not expected to have, such as the coinbase.
+	AdditionalTxs []*MsgTx
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgGrapheneBlock) BtcDecode(r io.Reader, pver uint32) error {
+	err := readBlockHeaderFields(r, &msg.Header)
+	if err != nil {
+		return err
+	}
+	msg.BlockTxCount, err = readVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	if msg.BlockTxCount > maxTxPerBlock {
+		str := fmt.Sprintf("too many transactions for block "+
+			"[count %v, max %v]", msg.BlockTxCount, maxTxPerBlock)
+		return categorizedError("MsgGrapheneBlock.BtcDecode", str,
+			ErrCategoryOversized)
+	}
+	err = readRelayFilter(r, pver, "MsgGrapheneBlock.BtcDecode",
+		&msg.Filter, &msg.HashFuncs, &msg.Tweak, &msg.Flags)
+	if err != nil {
+		return err
+	}
+	err = readIBLT(r, pver, "MsgGrapheneBlock.BtcDecode", &msg.IBLT)
+	if err != nil {
+		return err
+	}
+	msg.AdditionalTxs, err = readTxList(r, pver,
+		"MsgGrapheneBlock.BtcDecode")
+	return err
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgGrapheneBlock) BtcEncode(w io.Writer, pver uint32) error {
+	err := msg.validate("MsgGrapheneBlock.BtcEncode")
+	if err != nil {
+		return err
+	}
+
+	err = writeBlockHeaderFields(w, &msg.Header)
+	if err != nil {
+		return err
+	}
+	err = writeVarInt(w, pver, msg.BlockTxCount)
+	if err != nil {
+		return err
+	}
+	err = writeRelayFilter(w, pver, msg.Filter, msg.HashFuncs, msg.Tweak,
+		msg.Flags)
+	if err != nil {
+		return err
+	}
+	err = writeIBLT(w, pver, &msg.IBLT)
+	if err != nil {
+		return err
+	}
+	return writeTxList(w, pver, "MsgGrapheneBlock.BtcEncode",
+		msg.AdditionalTxs)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgGrapheneBlock) Command() string {
+	return CmdGrapheneBlock
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgGrapheneBlock) MaxPayloadLength(pver uint32) uint32 {
+	// Block header at 80 bytes + block tx count (varInt) + num filter
+	// bytes (varInt) + filter + hash funcs, tweak, and flags at 9 bytes +
+	// IBLT hash funcs and salt at 5 bytes + num IBLT cells (varInt) + IBLT
+	// cells + num additional transactions (varInt) + additional
+	// transactions.  The filter, IBLT cells, and additional transactions
+	// are each limited to the max block payload.
+	return blockHashLen + maxVarIntPayload + maxVarIntPayload +
+		MaxXThinFilterSize + 9 + 5 + maxVarIntPayload + MaxBlockPayload +
+		maxVarIntPayload + MaxBlockPayload
+}
+
+// SerializeSize returns the number of bytes it would take to encode the grblk
+// message using the provided protocol version.  This is part of the Message
+// interface implementation.
+func (msg *MsgGrapheneBlock) SerializeSize(pver uint32) int {
+	return blockHashLen + varIntSerializeSize(msg.BlockTxCount) +
+		relayFilterSerializeSize(msg.Filter) +
+		ibltSerializeSize(&msg.IBLT) +
+		txListSerializeSize(pver, msg.AdditionalTxs)
+}
+
+// Validate returns an error when the grblk message would be rejected by
+// BtcEncode regardless of the protocol version.  This is part of the Message
+// interface implementation.
+func (msg *MsgGrapheneBlock) Validate() error {
+	return msg.validate("MsgGrapheneBlock.Validate")
+}
+
+// validate returns an error attributed to the function f when any part of the
+// message exceeds the allowed limits.
+func (msg *MsgGrapheneBlock) validate(f string) error {
+	if msg.BlockTxCount > maxTxPerBlock {
+		str := fmt.Sprintf("too many transactions for block "+
+			"[count %v, max %v]", msg.BlockTxCount, maxTxPerBlock)
+		return messageError(f, str)
+	}
+	err := validateRelayFilter(f, msg.Filter, msg.HashFuncs)
+	if err != nil {
+		return err
+	}
+	err = validateIBLT(f, &msg.IBLT)
+	if err != nil {
+		return err
+	}
+	return validateTxList(f, msg.AdditionalTxs)
+}
+
+// Equal returns whether the message has the same header, filter, IBLT, and
+// additional transactions as other.  Two nil messages are considered equal,
+// while a nil message is not equal to any other.
+func (msg *MsgGrapheneBlock) Equal(other *MsgGrapheneBlock) bool {
+	if msg == nil || other == nil {
+		return msg == other
+	}
+	return msg.Header.Equal(&other.Header) &&
+		msg.BlockTxCount == other.BlockTxCount &&
+		bytes.Equal(msg.Filter, other.Filter) &&
+		msg.HashFuncs == other.HashFuncs && msg.Tweak == other.Tweak &&
+		msg.Flags == other.Flags && msg.IBLT.Equal(&other.IBLT) &&
+		txListEqual(msg.AdditionalTxs, other.AdditionalTxs)
+}
+
+// GoString returns a Go-syntax representation of the message.  This is part
+// of the fmt.GoStringer interface implementation.
+func (msg *MsgGrapheneBlock) GoString() string {
+	return goString(msg)
+}
+
+// NewMsgGrapheneBlock returns a new grblk message that conforms to the Message
+// interface for the block with the provided header and number of
+// transactions.  See MsgGrapheneBlock for details.
+func NewMsgGrapheneBlock(header *BlockHeader, blockTxCount uint64) *MsgGrapheneBlock {
+	return &MsgGrapheneBlock{
+		Header:       *header,
+		BlockTxCount: blockTxCount,
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// grapheneBlockOne returns a grblk message for block one with a small filter
+// and IBLT along with its wire encoding.
+func grapheneBlockOne() (*btcwire.MsgGrapheneBlock, []byte) {
+	msg := btcwire.NewMsgGrapheneBlock(&blockOne.Header, 1)
+	msg.Header.TxnCount = 0
+	msg.Filter = []byte{0xb5}
+	msg.HashFuncs = 11
+	msg.Tweak = 0x01020304
+	msg.IBLT = btcwire.IBLT{
+		HashFuncs: 3,
+		Salt:      0x0a0b0c0d,
+		Cells: []btcwire.IBLTCell{
+			{Count: 0, ValueSum: []byte{}},
+			{Count: -1, KeySum: 0x0102030405060708,
+				KeyCheck: 0xdeadbeef, ValueSum: []byte{0xaa}},
+		},
+	}
+	msg.AdditionalTxs = []*btcwire.MsgTx{blockOne.Transactions[0]}
+
+	encoded := append([]byte{}, blockOneBytes[:80]...)
+	encoded = append(encoded,
+		0x01,                   // Varint for block tx count
+		0x01,                   // Varint for size of filter
+		0xb5,                   // Filter
+		0x0b, 0x00, 0x00, 0x00, // Filter hash funcs
+		0x04, 0x03, 0x02, 0x01, // Filter tweak
+		0x00,                   // Filter flags
+		0x03,                   // IBLT hash funcs
+		0x0d, 0x0c, 0x0b, 0x0a, // IBLT salt
+		0x02,                   // Varint for number of IBLT cells
+		0x00, 0x00, 0x00, 0x00, // Count
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Key sum
+		0x00, 0x00, 0x00, 0x00, // Key check
+		0x00,                   // Varint for size of value sum
+		0xff, 0xff, 0xff, 0xff, // Count
+		0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01, // Key sum
+		0xef, 0xbe, 0xad, 0xde, // Key check
+		0x01, // Varint for size of value sum
+		0xaa, // Value sum
+	)
+	encoded = append(encoded, blockOneBytes[80:]...)
+	return msg, encoded
+}
+
+// TestGrapheneBlock tests the MsgGrapheneBlock API.
+func TestGrapheneBlock(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+
+	// Ensure the command is expected value.
+	wantCmd := "grblk"
+	msg := btcwire.NewMsgGrapheneBlock(&blockOne.Header, 1)
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgGrapheneBlock: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	wantPayload := uint32(3000130)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure oversized IBLT value sums are rejected.
+	if err := msg.Validate(); err != nil {
+		t.Errorf("Validate: unexpected error %v", err)
+	}
+	msg.IBLT.Cells = []btcwire.IBLTCell{
+		{ValueSum: make([]byte, btcwire.MaxIBLTValueSize+1)},
+	}
+	if err := msg.Validate(); err == nil {
+		t.Errorf("Validate: expected error on oversized IBLT value " +
+			"sum not received")
+	}
+}
+
+// TestGrapheneBlockWire tests the MsgGrapheneBlock wire encode and decode.
+func TestGrapheneBlockWire(t *testing.T) {
+	grapheneOne, grapheneOneEncoded := grapheneBlockOne()
+
+	// Message with an empty filter and IBLT and no additional
+	// transactions.
+	empty := btcwire.NewMsgGrapheneBlock(&grapheneOne.Header, 0)
+	empty.Filter = []byte{}
+	empty.IBLT.Cells = []btcwire.IBLTCell{}
+	empty.AdditionalTxs = []*btcwire.MsgTx{}
+	emptyEncoded := append([]byte{}, blockOneBytes[:80]...)
+	emptyEncoded = append(emptyEncoded,
+		0x00,                   // Varint for block tx count
+		0x00,                   // Varint for size of filter
+		0x00, 0x00, 0x00, 0x00, // Filter hash funcs
+		0x00, 0x00, 0x00, 0x00, // Filter tweak
+		0x00,                   // Filter flags
+		0x00,                   // IBLT hash funcs
+		0x00, 0x00, 0x00, 0x00, // IBLT salt
+		0x00, // Varint for number of IBLT cells
+		0x00, // Varint for number of additional transactions
+	)
+
+	tests := []struct {
+		in   *btcwire.MsgGrapheneBlock // Message to encode
+		out  *btcwire.MsgGrapheneBlock // Expected decoded message
+		buf  []byte                    // Wire encoding
+		pver uint32                    // Protocol version for wire encoding
+	}{
+		// Latest protocol version with block one.
+		{grapheneOne, grapheneOne, grapheneOneEncoded,
+			btcwire.ProtocolVersion},
+
+		// Latest protocol version with an empty filter and IBLT.
+		{empty, empty, emptyEncoded, btcwire.ProtocolVersion},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+		if size := test.in.SerializeSize(test.pver); size != len(test.buf) {
+			t.Errorf("SerializeSize #%d got: %d want: %d", i, size,
+				len(test.buf))
+		}
+
+		// Decode the message from wire format.
+		var msg btcwire.MsgGrapheneBlock
+		rbuf := bytes.NewBuffer(test.buf)
+		err = msg.BtcDecode(rbuf, test.pver)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+}
+
+// TestGrapheneBlockWireErrors performs negative tests against wire encode and
+// decode of MsgGrapheneBlock to confirm error paths work correctly.
+func TestGrapheneBlockWireErrors(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcwireErr := &btcwire.MessageError{}
+
+	baseGraphene, baseGrapheneEncoded := grapheneBlockOne()
+
+	// Message that forces an error by having more transactions than a
+	// block can hold.
+	maxTxCount := btcwire.NewMsgGrapheneBlock(&baseGraphene.Header,
+		1000000/10+2)
+	maxTxCountEncoded := append([]byte{}, blockOneBytes[:80]...)
+	maxTxCountEncoded = append(maxTxCountEncoded,
+		0xfe, 0xa2, 0x86, 0x01, 0x00, // Varint for block tx count (100002)
+	)
+
+	// Message that forces an error by having an IBLT value sum larger than
+	// the max allowed.
+	maxValueSum, maxValueSumEncoded := grapheneBlockOne()
+	maxValueSum.IBLT.Cells[1].ValueSum = make([]byte,
+		btcwire.MaxIBLTValueSize+1)
+	maxValueSumEncoded = append([]byte{}, maxValueSumEncoded[:131]...)
+	maxValueSumEncoded = append(maxValueSumEncoded, 0x41) // Varint for size of value sum (65)
+
+	tests := []struct {
+		in       *btcwire.MsgGrapheneBlock // Value to encode
+		buf      []byte                    // Wire encoding
+		pver     uint32                    // Protocol version for wire encoding
+		max      int                       // Max size of fixed buffer to induce errors
+		writeErr error                     // Expected write error
+		readErr  error                     // Expected read error
+	}{
+		// Force error in header.
+		{baseGraphene, baseGrapheneEncoded, pver, 0, io.ErrShortWrite, io.EOF},
+		// Force error in block tx count.
+		{baseGraphene, baseGrapheneEncoded, pver, 80, io.ErrShortWrite, io.EOF},
+		// Force error in filter.
+		{baseGraphene, baseGrapheneEncoded, pver, 81, io.ErrShortWrite, io.EOF},
+		// Force error in IBLT hash funcs.
+		{baseGraphene, baseGrapheneEncoded, pver, 92, io.ErrShortWrite, io.EOF},
+		// Force error in number of IBLT cells.
+		{baseGraphene, baseGrapheneEncoded, pver, 97, io.ErrShortWrite, io.EOF},
+		// Force error in IBLT cells.
+		{baseGraphene, baseGrapheneEncoded, pver, 98, io.ErrShortWrite, io.EOF},
+		// Force error in IBLT value sum.
+		{baseGraphene, baseGrapheneEncoded, pver, 132, io.ErrShortWrite, io.EOF},
+		// Force error in number of additional transactions.
+		{baseGraphene, baseGrapheneEncoded, pver, 133, io.ErrShortWrite, io.EOF},
+		// Force error in additional transactions.
+		{baseGraphene, baseGrapheneEncoded, pver, 134, io.ErrShortWrite, io.EOF},
+		// Force error with greater than max block tx count.
+		{maxTxCount, maxTxCountEncoded, pver, 85, btcwireErr, btcwireErr},
+		// Force error with greater than max IBLT value sum size.
+		{maxValueSum, maxValueSumEncoded, pver, 132, btcwireErr, btcwireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		w := newFixedWriter(test.max)
+		err := test.in.BtcEncode(w, test.pver)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+			t.Errorf("BtcEncode #%d wrong error got: %v, want: %v",
+				i, err, test.writeErr)
+			continue
+		}
+
+		// For errors which are not of type btcwire.MessageError, check
+		// them for equality.
+		if _, ok := err.(*btcwire.MessageError); !ok {
+			if err != test.writeErr {
+				t.Errorf("BtcEncode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.writeErr)
+				continue
+			}
+		}
+
+		// Decode from wire format.
+		var msg btcwire.MsgGrapheneBlock
+		r := newFixedReader(test.max, test.buf)
+		err = msg.BtcDecode(r, test.pver)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+
+		// For errors which are not of type btcwire.MessageError, check
+		// them for equality.
+		if _, ok := err.(*btcwire.MessageError); !ok {
+			if err != test.readErr {
+				t.Errorf("BtcDecode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.readErr)
+				continue
+			}
+		}
+	}
+}
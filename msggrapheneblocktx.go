@@ -0,0 +1,104 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"io"
+)
+
+// MsgGrapheneBlockTx implements the Message interface and represents a grblktx
+// message of the experimental Graphene block relay protocol.  It is sent in
+// response to a get_grblktx message (MsgGetGrapheneBlockTx) with the requested
+// transactions of the block which the requesting peer was unable to
+// reconstruct from a grblk message.
+//
+// This message is not part of the bitcoin protocol and is only recognized once
+// RegisterGrapheneMessages has been called.
+type MsgGrapheneBlockTx struct {
+	// BlockHash identifies the block the transactions belong to.
+	BlockHash ShaHash
+
+	// Txs are the requested transactions.
+	Txs []*MsgTx
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgGrapheneBlockTx) BtcDecode(r io.Reader, pver uint32) error {
+	_, err := io.ReadFull(r, msg.BlockHash[:])
+	if err != nil {
+		return err
+	}
+	msg.Txs, err = readTxList(r, pver, "MsgGrapheneBlockTx.BtcDecode")
+	return err
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgGrapheneBlockTx) BtcEncode(w io.Writer, pver uint32) error {
+	err := validateTxList("MsgGrapheneBlockTx.BtcEncode", msg.Txs)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(msg.BlockHash[:])
+	if err != nil {
+		return err
+	}
+	return writeTxList(w, pver, "MsgGrapheneBlockTx.BtcEncode", msg.Txs)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgGrapheneBlockTx) Command() string {
+	return CmdGrapheneBlockTx
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgGrapheneBlockTx) MaxPayloadLength(pver uint32) uint32 {
+	// Block hash + num transactions (varInt) + transactions up to the max
+	// block payload.
+	return HashSize + maxVarIntPayload + MaxBlockPayload
+}
+
+// SerializeSize returns the number of bytes it would take to encode the
+// grblktx message using the provided protocol version.  This is part of the
+// Message interface implementation.
+func (msg *MsgGrapheneBlockTx) SerializeSize(pver uint32) int {
+	return HashSize + txListSerializeSize(pver, msg.Txs)
+}
+
+// Validate returns an error when the grblktx message would be rejected by
+// BtcEncode regardless of the protocol version.  This is part of the Message
+// interface implementation.
+func (msg *MsgGrapheneBlockTx) Validate() error {
+	return validateTxList("MsgGrapheneBlockTx.Validate", msg.Txs)
+}
+
+// Equal returns whether the message contains the same transactions of the
+// same block as other.  Two nil messages are considered equal, while a nil
+// message is not equal to any other.
+func (msg *MsgGrapheneBlockTx) Equal(other *MsgGrapheneBlockTx) bool {
+	if msg == nil || other == nil {
+		return msg == other
+	}
+	return msg.BlockHash == other.BlockHash &&
+		txListEqual(msg.Txs, other.Txs)
+}
+
+// GoString returns a Go-syntax representation of the message.  This is part
+// of the fmt.GoStringer interface implementation.
+func (msg *MsgGrapheneBlockTx) GoString() string {
+	return goString(msg)
+}
+
+// NewMsgGrapheneBlockTx returns a new grblktx message that conforms to the
+// Message interface for the block with the provided hash.  See
+// MsgGrapheneBlockTx for details.
+func NewMsgGrapheneBlockTx(blockHash *ShaHash) *MsgGrapheneBlockTx {
+	return &MsgGrapheneBlockTx{
+		BlockHash: *blockHash,
+	}
+}
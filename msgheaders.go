@@ -7,6 +7,7 @@ package btcwire
 import (
 	"fmt"
 	"io"
+	"time"
 )
 
 // MaxBlockHeadersPerMsg is the maximum number of block headers that can be in
@@ -46,7 +47,18 @@ func (msg *MsgHeaders) BtcDecode(r io.Reader, pver uint32) error {
 	if count > MaxBlockHeadersPerMsg {
 		str := fmt.Sprintf("too many block headers for message "+
 			"[count %v, max %v]", count, MaxBlockHeadersPerMsg)
-		return messageError("MsgHeaders.BtcDecode", str)
+		return categorizedError("MsgHeaders.BtcDecode", str,
+			ErrCategoryOversized)
+	}
+	err = checkCountFits(r, "MsgHeaders.BtcDecode", "block headers", count,
+		blockHashLen+1)
+	if err != nil {
+		return err
+	}
+	err = reserveAlloc(r, "MsgHeaders.BtcDecode", "block headers",
+		count*blockHeaderAllocSize)
+	if err != nil {
+		return err
 	}
 
 	msg.Headers = make([]*BlockHeader, 0, count)
@@ -61,7 +73,8 @@ func (msg *MsgHeaders) BtcDecode(r io.Reader, pver uint32) error {
 		if bh.TxnCount > 0 {
 			str := fmt.Sprintf("block headers may not contain "+
 				"transactions [count %v]", bh.TxnCount)
-			return messageError("MsgHeaders.BtcDecode", str)
+			return categorizedError("MsgHeaders.BtcDecode", str,
+				ErrCategoryMalformed)
 		}
 		msg.AddBlockHeader(&bh)
 	}
@@ -105,7 +118,7 @@ func (msg *MsgHeaders) BtcEncode(w io.Writer, pver uint32) error {
 // Command returns the protocol command string for the message.  This is part
 // of the Message interface implementation.
 func (msg *MsgHeaders) Command() string {
-	return cmdHeaders
+	return CmdHeaders
 }
 
 // MaxPayloadLength returns the maximum length the payload can be for the
@@ -115,6 +128,68 @@ func (msg *MsgHeaders) MaxPayloadLength(pver uint32) uint32 {
 	return maxVarIntPayload + (maxBlockHeaderPayload * MaxBlockHeadersPerMsg)
 }
 
+// SerializeSize returns the number of bytes it would take to encode the headers
+// message using the provided protocol version.  This is part of the Message
+// interface implementation.
+func (msg *MsgHeaders) SerializeSize(pver uint32) int {
+	n := varIntSerializeSize(uint64(len(msg.Headers)))
+	for _, bh := range msg.Headers {
+		n += blockHeaderSerializeSize(pver, bh)
+	}
+	return n
+}
+
+// Validate returns an error when the headers message would be rejected by
+// BtcEncode regardless of the protocol version.  This is part of the Message
+// interface implementation.
+func (msg *MsgHeaders) Validate() error {
+	count := len(msg.Headers)
+	if count > MaxBlockHeadersPerMsg {
+		str := fmt.Sprintf("too many block headers for message "+
+			"[count %v, max %v]", count, MaxBlockHeadersPerMsg)
+		return messageError("MsgHeaders.Validate", str)
+	}
+
+	for _, bh := range msg.Headers {
+		// Ensure block headers do not contain a transaction count.
+		if bh.TxnCount > 0 {
+			str := fmt.Sprintf("block headers may not contain "+
+				"transactions [count %v]", bh.TxnCount)
+			return messageError("MsgHeaders.Validate", str)
+		}
+
+		err := validateBlockHeader("MsgHeaders.Validate", bh)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Equal returns whether the message contains the same block headers in the
+// same order as other.  Two nil messages are considered equal, while a nil
+// message is not equal to any other.
+func (msg *MsgHeaders) Equal(other *MsgHeaders) bool {
+	if msg == nil || other == nil {
+		return msg == other
+	}
+	if len(msg.Headers) != len(other.Headers) {
+		return false
+	}
+	for i, bh := range msg.Headers {
+		if !bh.Equal(other.Headers[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// GoString returns a Go-syntax representation of the message.  This is part
+// of the fmt.GoStringer interface implementation.
+func (msg *MsgHeaders) GoString() string {
+	return goString(msg)
+}
+
 // NewMsgHeaders returns a new bitcoin headers message that conforms to the
 // Message interface.  See MsgHeaders for details.
 func NewMsgHeaders() *MsgHeaders {
@@ -122,3 +197,57 @@ func NewMsgHeaders() *MsgHeaders {
 		Headers: make([]*BlockHeader, 0, MaxBlockHeadersPerMsg),
 	}
 }
+
+// headerEntryLen is the length of each block header in a headers message,
+// which is the fixed size fields of the header followed by its transaction
+// count of zero encoded in a single byte.
+const headerEntryLen = blockHashLen + 1
+
+// decodeHeaderEntry decodes a block header as encoded in a headers message from
+// buf, which must be headerEntryLen bytes, into bh.  All of the fields of bh
+// are set.  The fields are decoded directly from buf so nothing is allocated.
+// The function f is used to attribute any errors.
+func decodeHeaderEntry(f string, buf []byte, bh *BlockHeader) error {
+	// Any other transaction count byte is either a non-zero count or a
+	// zero count which is not canonically encoded.
+	if buf[blockHashLen] != 0 {
+		str := fmt.Sprintf("block headers may not contain transactions "+
+			"[count prefix %#x]", buf[blockHashLen])
+		return categorizedError(f, str, ErrCategoryMalformed)
+	}
+
+	bh.Version = littleEndian.Uint32(buf[0:4])
+	copy(bh.PrevBlock[:], buf[4:4+HashSize])
+	copy(bh.MerkleRoot[:], buf[4+HashSize:4+HashSize*2])
+	bh.Timestamp = time.Unix(int64(littleEndian.Uint32(buf[68:72])), 0)
+	bh.Bits = littleEndian.Uint32(buf[72:76])
+	bh.Nonce = littleEndian.Uint32(buf[76:80])
+	bh.TxnCount = 0
+	bh.AuxPow = nil
+	return nil
+}
+
+// ReadBlockHeaders reads len(headers) consecutive block headers, each encoded
+// as in a headers message (MsgHeaders), from r directly into headers.  Unlike
+// decoding a headers message, which allocates every header separately, the
+// only allocation is a single read buffer, so the same slice can be reused to
+// decode millions of headers during headers-first sync.  Every field of each
+// header is overwritten.
+//
+// The headers must have a transaction count of zero, and merged-mined headers
+// are not supported since their auxiliary proof-of-work is of variable size.
+// io.ErrUnexpectedEOF is returned when r ends part way through the headers.
+func ReadBlockHeaders(r io.Reader, headers []BlockHeader) error {
+	buf := make([]byte, headerEntryLen)
+	for i := range headers {
+		_, err := io.ReadFull(r, buf)
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		err = decodeHeaderEntry("ReadBlockHeaders", buf, &headers[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -345,3 +345,89 @@ func TestHeadersWireErrors(t *testing.T) {
 
 	}
 }
+
+// TestReadBlockHeaders tests decoding consecutive block headers directly into
+// a slice.
+func TestReadBlockHeaders(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+
+	// Encode a headers message and strip the header count to get the
+	// consecutive headers.
+	msg := btcwire.NewMsgHeaders()
+	prevHash := btcwire.GenesisHash
+	for i := 0; i < 3; i++ {
+		bh := btcwire.NewBlockHeader(&prevHash,
+			&blockOne.Header.MerkleRoot, blockOne.Header.Bits,
+			uint32(i))
+		bh.Version = blockOne.Header.Version
+		bh.Timestamp = blockOne.Header.Timestamp
+		msg.AddBlockHeader(bh)
+		prevHash, _ = bh.BlockSha()
+	}
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver); err != nil {
+		t.Fatalf("BtcEncode: %v", err)
+	}
+	encoded := buf.Bytes()[1:]
+
+	// Ensure every field of previously used headers is overwritten.
+	headers := make([]btcwire.BlockHeader, len(msg.Headers))
+	for i := range headers {
+		headers[i].TxnCount = 5
+		headers[i].AuxPow = &btcwire.AuxPow{}
+	}
+	err := btcwire.ReadBlockHeaders(bytes.NewReader(encoded), headers)
+	if err != nil {
+		t.Fatalf("ReadBlockHeaders: %v", err)
+	}
+	for i := range headers {
+		if !reflect.DeepEqual(&headers[i], msg.Headers[i]) {
+			t.Errorf("ReadBlockHeaders: header %d\n got: %s want: %s",
+				i, spew.Sdump(&headers[i]),
+				spew.Sdump(msg.Headers[i]))
+		}
+	}
+
+	// Ensure decoding does not allocate anything per header.
+	r := bytes.NewReader(encoded)
+	allocs := testing.AllocsPerRun(100, func() {
+		r.Seek(0, 0)
+		btcwire.ReadBlockHeaders(r, headers)
+	})
+	if allocs > 1 {
+		t.Errorf("ReadBlockHeaders: got %v allocations, want at most 1",
+			allocs)
+	}
+
+	// Intentionally invalid header with a transaction count.
+	withTxns := make([]byte, len(encoded))
+	copy(withTxns, encoded)
+	withTxns[80] = 0x01
+
+	tests := []struct {
+		buf   []byte // Consecutive headers
+		count int    // Number of headers to read
+		err   error  // Expected error
+	}{
+		{encoded, 3, nil},
+		{encoded, 0, nil},
+		{encoded, 4, io.ErrUnexpectedEOF},
+		{encoded[:100], 2, io.ErrUnexpectedEOF},
+		{withTxns, 1, &btcwire.MessageError{}},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		headers := make([]btcwire.BlockHeader, test.count)
+		err := btcwire.ReadBlockHeaders(bytes.NewReader(test.buf), headers)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.err) {
+			t.Errorf("ReadBlockHeaders #%d wrong error got: %v, "+
+				"want: %v", i, err, test.err)
+			continue
+		}
+		if _, ok := err.(*btcwire.MessageError); !ok && err != test.err {
+			t.Errorf("ReadBlockHeaders #%d wrong error got: %v, "+
+				"want: %v", i, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// messageLogMagic identifies the start of a message log.  The final byte is
+// the version of the log format.
+var messageLogMagic = [8]byte{'b', 't', 'c', 'w', 'l', 'o', 'g', 1}
+
+// maxMessageLogRecord is the maximum size of the raw bytes of a single record
+// in a message log.  It is large enough for the header and the maximum
+// payload of any message.
+const maxMessageLogRecord = MessageHeaderSize + MaxMessagePayload
+
+// MessageDirection identifies whether a logged message was received from or
+// sent to the remote peer.
+type MessageDirection uint8
+
+// These constants define the directions of logged messages.
+const (
+	MessageInbound  MessageDirection = 0
+	MessageOutbound MessageDirection = 1
+)
+
+// Map of message directions back to their constant names for pretty printing.
+var mdStrings = map[MessageDirection]string{
+	MessageInbound:  "MessageInbound",
+	MessageOutbound: "MessageOutbound",
+}
+
+// String returns the MessageDirection in human-readable form.
+func (dir MessageDirection) String() string {
+	if s, ok := mdStrings[dir]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("Unknown MessageDirection (%d)", uint8(dir))
+}
+
+// MessageLogRecord describes a single message in a message log.
+type MessageLogRecord struct {
+	// Direction indicates whether the message was received or sent.
+	Direction MessageDirection
+
+	// Timestamp is the time the message was read or written.
+	Timestamp time.Time
+
+	// Raw is the raw bytes of the message, including the header, exactly
+	// as they were read or written.  It only contains the bytes which were
+	// actually transferred when an error occurred.
+	Raw []byte
+}
+
+// Decode decodes the raw bytes of the record into a message for the provided
+// protocol version and bitcoin network.  It returns the same values
+// ReadMessage would have for the original data.
+func (rec *MessageLogRecord) Decode(pver uint32, btcnet BitcoinNet) (Message, []byte, error) {
+	return ReadMessage(bytes.NewReader(rec.Raw), pver, btcnet)
+}
+
+// writeMessageLogRecord writes a message log record to w.
+func writeMessageLogRecord(w io.Writer, rec *MessageLogRecord) error {
+	err := writeElements(w, uint8(rec.Direction),
+		rec.Timestamp.UnixNano(), uint32(len(rec.Raw)))
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(rec.Raw)
+	return err
+}
+
+// MessageRecorder wraps ReadMessage and WriteMessage to record every message
+// read or written, along with its direction and the time, to a compact log
+// which can be replayed with a MessageLogReader.  This is useful for
+// reproducing bugs deterministically.  It is safe for concurrent use.
+//
+// Failure to write to the log does not affect reading or writing messages.
+// The first such error is available via Err and no further messages are
+// recorded once it occurs.
+type MessageRecorder struct {
+	mtx sync.Mutex
+	log io.Writer
+	err error
+}
+
+// NewMessageRecorder returns a new MessageRecorder which records messages to
+// the provided log.  The log format header is written immediately.
+func NewMessageRecorder(log io.Writer) (*MessageRecorder, error) {
+	_, err := log.Write(messageLogMagic[:])
+	if err != nil {
+		return nil, err
+	}
+	return &MessageRecorder{log: log}, nil
+}
+
+// record writes the provided raw message data to the log.
+func (mr *MessageRecorder) record(dir MessageDirection, raw []byte) {
+	if len(raw) == 0 {
+		return
+	}
+
+	rec := MessageLogRecord{
+		Direction: dir,
+		Timestamp: time.Now(),
+		Raw:       raw,
+	}
+
+	mr.mtx.Lock()
+	defer mr.mtx.Unlock()
+	if mr.err != nil {
+		return
+	}
+	mr.err = writeMessageLogRecord(mr.log, &rec)
+}
+
+// Err returns the first error encountered while writing to the log, if any.
+func (mr *MessageRecorder) Err() error {
+	mr.mtx.Lock()
+	defer mr.mtx.Unlock()
+	return mr.err
+}
+
+// ReadMessage reads the next message from r in the same manner as the
+// package-level ReadMessage function and records all of the bytes it read as
+// an inbound message.
+func (mr *MessageRecorder) ReadMessage(r io.Reader, pver uint32, btcnet BitcoinNet) (Message, []byte, error) {
+	var raw bytes.Buffer
+	msg, payload, err := ReadMessage(io.TeeReader(r, &raw), pver, btcnet)
+	mr.record(MessageInbound, raw.Bytes())
+	return msg, payload, err
+}
+
+// captureWriter forwards writes to the wrapped writer while keeping a copy of
+// the bytes which were successfully written.
+type captureWriter struct {
+	w        io.Writer
+	captured []byte
+}
+
+// Write writes p to the wrapped writer and captures the bytes written.
+func (cw *captureWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.captured = append(cw.captured, p[:n]...)
+	return n, err
+}
+
+// WriteMessage writes msg to w in the same manner as the package-level
+// WriteMessage function and records all of the bytes it wrote as an outbound
+// message.
+func (mr *MessageRecorder) WriteMessage(w io.Writer, msg Message, pver uint32, btcnet BitcoinNet) error {
+	cw := captureWriter{w: w}
+	err := WriteMessage(&cw, msg, pver, btcnet)
+	mr.record(MessageOutbound, cw.captured)
+	return err
+}
+
+// MessageLogReader reads the records of a message log written by a
+// MessageRecorder in the order they were recorded.
+type MessageLogReader struct {
+	r io.Reader
+}
+
+// NewMessageLogReader returns a new MessageLogReader which reads records from
+// the provided log.  An error is returned when the log does not start with a
+// valid message log header.
+func NewMessageLogReader(r io.Reader) (*MessageLogReader, error) {
+	var magic [len(messageLogMagic)]byte
+	_, err := io.ReadFull(r, magic[:])
+	if err != nil {
+		return nil, err
+	}
+	if magic != messageLogMagic {
+		str := fmt.Sprintf("invalid message log header %x", magic)
+		return nil, messageError("NewMessageLogReader", str)
+	}
+	return &MessageLogReader{r: r}, nil
+}
+
+// Next returns the next record in the log.  It returns io.EOF when there are
+// no more records.
+func (lr *MessageLogReader) Next() (*MessageLogRecord, error) {
+	var dir uint8
+	err := readElement(lr.r, &dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var nsec int64
+	var length uint32
+	err = readElements(lr.r, &nsec, &length)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if length > maxMessageLogRecord {
+		str := fmt.Sprintf("message log record is too large [len %d, "+
+			"max %d]", length, maxMessageLogRecord)
+		return nil, messageError("MessageLogReader.Next", str)
+	}
+
+	raw := make([]byte, length)
+	_, err = io.ReadFull(lr.r, raw)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	rec := MessageLogRecord{
+		Direction: MessageDirection(dir),
+		Timestamp: time.Unix(0, nsec),
+		Raw:       raw,
+	}
+	return &rec, nil
+}
+
+// unexpectedEOF converts io.EOF to io.ErrUnexpectedEOF since reaching the end
+// of the log in the middle of a record means the log is truncated.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestMessageDirectionStringer tests the stringized output for message
+// directions.
+func TestMessageDirectionStringer(t *testing.T) {
+	tests := []struct {
+		in   btcwire.MessageDirection
+		want string
+	}{
+		{btcwire.MessageInbound, "MessageInbound"},
+		{btcwire.MessageOutbound, "MessageOutbound"},
+		{0xff, "Unknown MessageDirection (255)"},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		result := test.in.String()
+		if result != test.want {
+			t.Errorf("String #%d\n got: %s want: %s", i, result,
+				test.want)
+			continue
+		}
+	}
+}
+
+// TestMessageLog tests recording messages to a log and replaying them.
+func TestMessageLog(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcnet := btcwire.MainNet
+
+	var log bytes.Buffer
+	mr, err := btcwire.NewMessageRecorder(&log)
+	if err != nil {
+		t.Fatalf("NewMessageRecorder: %v", err)
+	}
+
+	// Write a couple of messages through the recorder and read them back
+	// through it as well.
+	msgs := []btcwire.Message{
+		btcwire.NewMsgPing(123),
+		btcwire.NewMsgVerAck(),
+	}
+	var conn bytes.Buffer
+	start := time.Now()
+	for i, msg := range msgs {
+		err := mr.WriteMessage(&conn, msg, pver, btcnet)
+		if err != nil {
+			t.Fatalf("WriteMessage #%d: %v", i, err)
+		}
+	}
+	wire := append([]byte(nil), conn.Bytes()...)
+	for i := range msgs {
+		_, _, err := mr.ReadMessage(&conn, pver, btcnet)
+		if err != nil {
+			t.Fatalf("ReadMessage #%d: %v", i, err)
+		}
+	}
+
+	// Reading a truncated message records the bytes which were read.
+	truncated := wire[:10]
+	_, _, err = mr.ReadMessage(bytes.NewReader(truncated), pver, btcnet)
+	if err == nil {
+		t.Fatalf("ReadMessage: did not receive error for truncated " +
+			"message")
+	}
+	if err := mr.Err(); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	// Replay the log.
+	lr, err := btcwire.NewMessageLogReader(&log)
+	if err != nil {
+		t.Fatalf("NewMessageLogReader: %v", err)
+	}
+	pingLen := 24 + 8
+	wantRecs := []struct {
+		dir btcwire.MessageDirection
+		raw []byte
+		msg btcwire.Message
+	}{
+		{btcwire.MessageOutbound, wire[:pingLen], msgs[0]},
+		{btcwire.MessageOutbound, wire[pingLen:], msgs[1]},
+		{btcwire.MessageInbound, wire[:pingLen], msgs[0]},
+		{btcwire.MessageInbound, wire[pingLen:], msgs[1]},
+		{btcwire.MessageInbound, truncated, nil},
+	}
+	for i, want := range wantRecs {
+		rec, err := lr.Next()
+		if err != nil {
+			t.Fatalf("Next #%d: %v", i, err)
+		}
+		if rec.Direction != want.dir {
+			t.Errorf("Next #%d wrong direction - got %v, want %v", i,
+				rec.Direction, want.dir)
+		}
+		if !bytes.Equal(rec.Raw, want.raw) {
+			t.Errorf("Next #%d wrong raw bytes - got %x, want %x", i,
+				rec.Raw, want.raw)
+		}
+		if rec.Timestamp.Before(start.Add(-time.Second)) {
+			t.Errorf("Next #%d wrong timestamp %v", i, rec.Timestamp)
+		}
+		if want.msg == nil {
+			continue
+		}
+		msg, _, err := rec.Decode(pver, btcnet)
+		if err != nil {
+			t.Errorf("Decode #%d: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(msg, want.msg) {
+			t.Errorf("Decode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(want.msg))
+		}
+	}
+	if _, err := lr.Next(); err != io.EOF {
+		t.Errorf("Next: wrong error at end of log - got %v, want %v",
+			err, io.EOF)
+	}
+}
+
+// TestMessageLogErrors tests reading invalid message logs.
+func TestMessageLogErrors(t *testing.T) {
+	// Invalid header.
+	_, err := btcwire.NewMessageLogReader(bytes.NewReader([]byte("notalog!")))
+	if _, ok := err.(*btcwire.MessageError); !ok {
+		t.Errorf("NewMessageLogReader: wrong error for invalid header - "+
+			"got %v (%T)", err, err)
+	}
+
+	// Build a log with one record and truncate it.
+	var log bytes.Buffer
+	mr, _ := btcwire.NewMessageRecorder(&log)
+	mr.WriteMessage(ioutil.Discard, btcwire.NewMsgVerAck(),
+		btcwire.ProtocolVersion, btcwire.MainNet)
+	raw := log.Bytes()
+	lr, err := btcwire.NewMessageLogReader(bytes.NewReader(raw[:len(raw)-1]))
+	if err != nil {
+		t.Fatalf("NewMessageLogReader: %v", err)
+	}
+	if _, err := lr.Next(); err != io.ErrUnexpectedEOF {
+		t.Errorf("Next: wrong error for truncated record - got %v, "+
+			"want %v", err, io.ErrUnexpectedEOF)
+	}
+
+	// Record which claims to be larger than any message.
+	huge := append([]byte(nil), raw[:8+1+8]...)
+	huge = append(huge, 0xff, 0xff, 0xff, 0xff)
+	lr, _ = btcwire.NewMessageLogReader(bytes.NewReader(huge))
+	if _, err := lr.Next(); err == nil {
+		t.Errorf("Next: did not receive error for oversized record")
+	}
+
+	// Failures writing to the log are reported by Err.
+	mr, err = btcwire.NewMessageRecorder(newFixedWriter(8))
+	if err != nil {
+		t.Fatalf("NewMessageRecorder: %v", err)
+	}
+	err = mr.WriteMessage(ioutil.Discard, btcwire.NewMsgVerAck(),
+		btcwire.ProtocolVersion, btcwire.MainNet)
+	if err != nil {
+		t.Errorf("WriteMessage: unexpected error %v", err)
+	}
+	if mr.Err() == nil {
+		t.Errorf("Err: did not receive error for failed log write")
+	}
+}
@@ -20,10 +20,11 @@ type MsgMemPool struct{}
 // BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
 // This is part of the Message interface implementation.
 func (msg *MsgMemPool) BtcDecode(r io.Reader, pver uint32) error {
-	if pver < BIP0035Version {
+	if !FeatureMemPool.IsSupported(pver) {
 		str := fmt.Sprintf("mempool message invalid for protocol "+
 			"version %d", pver)
-		return messageError("MsgMemPool.BtcDecode", str)
+		return categorizedError("MsgMemPool.BtcDecode", str,
+			ErrCategoryUnsupportedVersion)
 	}
 
 	return nil
@@ -32,7 +33,7 @@ func (msg *MsgMemPool) BtcDecode(r io.Reader, pver uint32) error {
 // BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
 // This is part of the Message interface implementation.
 func (msg *MsgMemPool) BtcEncode(w io.Writer, pver uint32) error {
-	if pver < BIP0035Version {
+	if !FeatureMemPool.IsSupported(pver) {
 		str := fmt.Sprintf("mempool message invalid for protocol "+
 			"version %d", pver)
 		return messageError("MsgMemPool.BtcEncode", str)
@@ -44,7 +45,7 @@ func (msg *MsgMemPool) BtcEncode(w io.Writer, pver uint32) error {
 // Command returns the protocol command string for the message.  This is part
 // of the Message interface implementation.
 func (msg *MsgMemPool) Command() string {
-	return cmdMemPool
+	return CmdMemPool
 }
 
 // MaxPayloadLength returns the maximum length the payload can be for the
@@ -53,6 +54,31 @@ func (msg *MsgMemPool) MaxPayloadLength(pver uint32) uint32 {
 	return 0
 }
 
+// SerializeSize returns the number of bytes it would take to encode the mempool
+// message using the provided protocol version, which is always zero.  This is
+// part of the Message interface implementation.
+func (msg *MsgMemPool) SerializeSize(pver uint32) int {
+	return 0
+}
+
+// Validate returns nil since the mempool message has no payload which could be
+// invalid.  This is part of the Message interface implementation.
+func (msg *MsgMemPool) Validate() error {
+	return nil
+}
+
+// Equal returns whether the message is the same as other.  Since the message
+// has no fields, this is only the case when both or neither are nil.
+func (msg *MsgMemPool) Equal(other *MsgMemPool) bool {
+	return (msg == nil) == (other == nil)
+}
+
+// GoString returns a Go-syntax representation of the message.  This is part
+// of the fmt.GoStringer interface implementation.
+func (msg *MsgMemPool) GoString() string {
+	return goString(msg)
+}
+
 // NewMsgMemPool returns a new bitcoin pong message that conforms to the Message
 // interface.  See MsgPong for details.
 func NewMsgMemPool() *MsgMemPool {
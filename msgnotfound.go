@@ -43,10 +43,27 @@ func (msg *MsgNotFound) BtcDecode(r io.Reader, pver uint32) error {
 	// Limit to max inventory vectors per message.
 	if count > MaxInvPerMsg {
 		str := fmt.Sprintf("too many invvect in message [%v]", count)
-		return messageError("MsgNotFound.BtcDecode", str)
+		return categorizedError("MsgNotFound.BtcDecode", str,
+			ErrCategoryOversized)
+	}
+	err = checkCountFits(r, "MsgNotFound.BtcDecode", "inventory vectors", count,
+		maxInvVectPayload)
+	if err != nil {
+		return err
+	}
+	err = reserveAlloc(r, "MsgNotFound.BtcDecode", "inventory vectors",
+		count*invVectAllocSize)
+	if err != nil {
+		return err
 	}
 
-	msg.InvList = make([]*InvVect, 0, count)
+	// Reuse the backing array of the inventory list when it is large
+	// enough.
+	if msg.InvList != nil && uint64(cap(msg.InvList)) >= count {
+		msg.InvList = msg.InvList[:0]
+	} else {
+		msg.InvList = make([]*InvVect, 0, count)
+	}
 	for i := uint64(0); i < count; i++ {
 		iv := InvVect{}
 		err := readInvVect(r, pver, &iv)
@@ -87,7 +104,7 @@ func (msg *MsgNotFound) BtcEncode(w io.Writer, pver uint32) error {
 // Command returns the protocol command string for the message.  This is part
 // of the Message interface implementation.
 func (msg *MsgNotFound) Command() string {
-	return cmdNotFound
+	return CmdNotFound
 }
 
 // MaxPayloadLength returns the maximum length the payload can be for the
@@ -98,6 +115,42 @@ func (msg *MsgNotFound) MaxPayloadLength(pver uint32) uint32 {
 	return maxVarIntPayload + (MaxInvPerMsg * maxInvVectPayload)
 }
 
+// SerializeSize returns the number of bytes it would take to encode the
+// notfound message using the provided protocol version.  This is part of the
+// Message interface implementation.
+func (msg *MsgNotFound) SerializeSize(pver uint32) int {
+	count := len(msg.InvList)
+	return varIntSerializeSize(uint64(count)) + count*maxInvVectPayload
+}
+
+// Validate returns an error when the notfound message would be rejected by
+// BtcEncode regardless of the protocol version.  This is part of the Message
+// interface implementation.
+func (msg *MsgNotFound) Validate() error {
+	count := len(msg.InvList)
+	if count > MaxInvPerMsg {
+		str := fmt.Sprintf("too many invvect in message [%v]", count)
+		return messageError("MsgNotFound.Validate", str)
+	}
+	return nil
+}
+
+// Equal returns whether the message contains the same inventory vectors in
+// the same order as other.  Two nil messages are considered equal, while a nil
+// message is not equal to any other.
+func (msg *MsgNotFound) Equal(other *MsgNotFound) bool {
+	if msg == nil || other == nil {
+		return msg == other
+	}
+	return invListEqual(msg.InvList, other.InvList)
+}
+
+// GoString returns a Go-syntax representation of the message.  This is part
+// of the fmt.GoStringer interface implementation.
+func (msg *MsgNotFound) GoString() string {
+	return goString(msg)
+}
+
 // NewMsgNotFound returns a new bitcoin notfound message that conforms to the
 // Message interface.  See MsgNotFound for details.
 func NewMsgNotFound() *MsgNotFound {
@@ -29,13 +29,12 @@ type MsgPing struct {
 // This is part of the Message interface implementation.
 func (msg *MsgPing) BtcDecode(r io.Reader, pver uint32) error {
 	// There was no nonce for BIP0031Version and earlier.
-	// NOTE: > is not a mistake here.  The BIP0031 was defined as AFTER
-	// the version unlike most others.
-	if pver > BIP0031Version {
-		err := readElement(r, &msg.Nonce)
+	if FeaturePingNonce.IsSupported(pver) {
+		nonce, err := binarySerializer.Uint64(r, littleEndian)
 		if err != nil {
 			return err
 		}
+		msg.Nonce = nonce
 	}
 
 	return nil
@@ -45,10 +44,8 @@ func (msg *MsgPing) BtcDecode(r io.Reader, pver uint32) error {
 // This is part of the Message interface implementation.
 func (msg *MsgPing) BtcEncode(w io.Writer, pver uint32) error {
 	// There was no nonce for BIP0031Version and earlier.
-	// NOTE: > is not a mistake here.  The BIP0031 was defined as AFTER
-	// the version unlike most others.
-	if pver > BIP0031Version {
-		err := writeElement(w, msg.Nonce)
+	if FeaturePingNonce.IsSupported(pver) {
+		err := binarySerializer.PutUint64(w, littleEndian, msg.Nonce)
 		if err != nil {
 			return err
 		}
@@ -60,7 +57,7 @@ func (msg *MsgPing) BtcEncode(w io.Writer, pver uint32) error {
 // Command returns the protocol command string for the message.  This is part
 // of the Message interface implementation.
 func (msg *MsgPing) Command() string {
-	return cmdPing
+	return CmdPing
 }
 
 // MaxPayloadLength returns the maximum length the payload can be for the
@@ -68,9 +65,7 @@ func (msg *MsgPing) Command() string {
 func (msg *MsgPing) MaxPayloadLength(pver uint32) uint32 {
 	plen := uint32(0)
 	// There was no nonce for BIP0031Version and earlier.
-	// NOTE: > is not a mistake here.  The BIP0031 was defined as AFTER
-	// the version unlike most others.
-	if pver > BIP0031Version {
+	if FeaturePingNonce.IsSupported(pver) {
 		// Nonce 8 bytes.
 		plen += 8
 	}
@@ -78,6 +73,40 @@ func (msg *MsgPing) MaxPayloadLength(pver uint32) uint32 {
 	return plen
 }
 
+// SerializeSize returns the number of bytes it would take to encode the ping
+// message using the provided protocol version.  This is part of the Message
+// interface implementation.
+func (msg *MsgPing) SerializeSize(pver uint32) int {
+	// There was no nonce for BIP0031Version and earlier.
+	if FeaturePingNonce.IsSupported(pver) {
+		// Nonce 8 bytes.
+		return 8
+	}
+	return 0
+}
+
+// Validate returns nil since the ping message has no payload which could be
+// invalid.  This is part of the Message interface implementation.
+func (msg *MsgPing) Validate() error {
+	return nil
+}
+
+// Equal returns whether the message has the same nonce as other.  Two nil
+// messages are considered equal, while a nil message is not equal to any
+// other.
+func (msg *MsgPing) Equal(other *MsgPing) bool {
+	if msg == nil || other == nil {
+		return msg == other
+	}
+	return msg.Nonce == other.Nonce
+}
+
+// GoString returns a Go-syntax representation of the message.  This is part
+// of the fmt.GoStringer interface implementation.
+func (msg *MsgPing) GoString() string {
+	return goString(msg)
+}
+
 // NewMsgPing returns a new bitcoin ping message that conforms to the Message
 // interface.  See MsgPing for details.
 func NewMsgPing(nonce uint64) *MsgPing {
@@ -23,18 +23,18 @@ type MsgPong struct {
 // BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
 // This is part of the Message interface implementation.
 func (msg *MsgPong) BtcDecode(r io.Reader, pver uint32) error {
-	// NOTE: <= is not a mistake here.  The BIP0031 was defined as AFTER
-	// the version unlike most others.
-	if pver <= BIP0031Version {
+	if !FeaturePingNonce.IsSupported(pver) {
 		str := fmt.Sprintf("pong message invalid for protocol "+
 			"version %d", pver)
-		return messageError("MsgPong.BtcDecode", str)
+		return categorizedError("MsgPong.BtcDecode", str,
+			ErrCategoryUnsupportedVersion)
 	}
 
-	err := readElement(r, &msg.Nonce)
+	nonce, err := binarySerializer.Uint64(r, littleEndian)
 	if err != nil {
 		return err
 	}
+	msg.Nonce = nonce
 
 	return nil
 }
@@ -42,15 +42,13 @@ func (msg *MsgPong) BtcDecode(r io.Reader, pver uint32) error {
 // BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
 // This is part of the Message interface implementation.
 func (msg *MsgPong) BtcEncode(w io.Writer, pver uint32) error {
-	// NOTE: <= is not a mistake here.  The BIP0031 was defined as AFTER
-	// the version unlike most others.
-	if pver <= BIP0031Version {
+	if !FeaturePingNonce.IsSupported(pver) {
 		str := fmt.Sprintf("pong message invalid for protocol "+
 			"version %d", pver)
 		return messageError("MsgPong.BtcEncode", str)
 	}
 
-	err := writeElement(w, msg.Nonce)
+	err := binarySerializer.PutUint64(w, littleEndian, msg.Nonce)
 	if err != nil {
 		return err
 	}
@@ -61,7 +59,7 @@ func (msg *MsgPong) BtcEncode(w io.Writer, pver uint32) error {
 // Command returns the protocol command string for the message.  This is part
 // of the Message interface implementation.
 func (msg *MsgPong) Command() string {
-	return cmdPong
+	return CmdPong
 }
 
 // MaxPayloadLength returns the maximum length the payload can be for the
@@ -69,9 +67,7 @@ func (msg *MsgPong) Command() string {
 func (msg *MsgPong) MaxPayloadLength(pver uint32) uint32 {
 	plen := uint32(0)
 	// The pong message did not exist for BIP0031Version and earlier.
-	// NOTE: > is not a mistake here.  The BIP0031 was defined as AFTER
-	// the version unlike most others.
-	if pver > BIP0031Version {
+	if FeaturePingNonce.IsSupported(pver) {
 		// Nonce 8 bytes.
 		plen += 8
 	}
@@ -79,6 +75,36 @@ func (msg *MsgPong) MaxPayloadLength(pver uint32) uint32 {
 	return plen
 }
 
+// SerializeSize returns the number of bytes it would take to encode the pong
+// message using the provided protocol version.  This is part of the Message
+// interface implementation.
+func (msg *MsgPong) SerializeSize(pver uint32) int {
+	// Nonce 8 bytes.
+	return 8
+}
+
+// Validate returns nil since the pong message has no payload which could be
+// invalid.  This is part of the Message interface implementation.
+func (msg *MsgPong) Validate() error {
+	return nil
+}
+
+// Equal returns whether the message has the same nonce as other.  Two nil
+// messages are considered equal, while a nil message is not equal to any
+// other.
+func (msg *MsgPong) Equal(other *MsgPong) bool {
+	if msg == nil || other == nil {
+		return msg == other
+	}
+	return msg.Nonce == other.Nonce
+}
+
+// GoString returns a Go-syntax representation of the message.  This is part
+// of the fmt.GoStringer interface implementation.
+func (msg *MsgPong) GoString() string {
+	return goString(msg)
+}
+
 // NewMsgPong returns a new bitcoin pong message that conforms to the Message
 // interface.  See MsgPong for details.
 func NewMsgPong(nonce uint64) *MsgPong {
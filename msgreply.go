@@ -0,0 +1,124 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// MsgReply implements the Message interface and represents a reply message of
+// the retired IP transaction protocol.  It is sent in response to a checkorder
+// message (MsgCheckOrder), in which case it holds the public key script to pay
+// to, or a submitorder message (MsgSubmitOrder).
+//
+// This message is only recognized once RegisterIPTransactionMessages has been
+// called and can only be decoded.
+type MsgReply struct {
+	// ReplyHash is the reply hash of the request being answered.
+	ReplyHash ShaHash
+
+	// Result is zero when the request was accepted.
+	Result int32
+
+	// PkScript is the public key script to pay to in a reply to a
+	// checkorder message.  It is nil when the reply doesn't include one.
+	PkScript []byte
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgReply) BtcDecode(r io.Reader, pver uint32) error {
+	_, err := io.ReadFull(r, msg.ReplyHash[:])
+	if err != nil {
+		return err
+	}
+	err = readElement(r, &msg.Result)
+	if err != nil {
+		return err
+	}
+
+	// The public key script is only present in replies to checkorder
+	// messages.
+	count, err := readVarInt(r, pver)
+	if err == io.EOF {
+		msg.PkScript = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	maxSize := maxScriptSize(r)
+	if count > maxSize {
+		str := fmt.Sprintf("reply public key script is larger than max "+
+			"script size [count %d, max %d]", count, maxSize)
+		return categorizedError("MsgReply.BtcDecode", str,
+			ErrCategoryOversized)
+	}
+	err = checkCountFits(r, "MsgReply.BtcDecode", "script bytes", count, 1)
+	if err != nil {
+		return err
+	}
+	msg.PkScript, err = readScript(r, nil, count)
+	return err
+}
+
+// BtcEncode always returns an error since messages of the retired IP
+// transaction protocol can only be decoded.  This is part of the Message
+// interface implementation.
+func (msg *MsgReply) BtcEncode(w io.Writer, pver uint32) error {
+	return errDecodeOnly("MsgReply.BtcEncode")
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgReply) Command() string {
+	return CmdReply
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgReply) MaxPayloadLength(pver uint32) uint32 {
+	// Reply hash + result 4 bytes + script length (varInt) + script.
+	return HashSize + 4 + maxVarIntPayload + MaxScriptSize
+}
+
+// SerializeSize returns the number of bytes the reply message occupies when
+// encoded using the provided protocol version.  This is part of the Message
+// interface implementation.
+func (msg *MsgReply) SerializeSize(pver uint32) int {
+	n := HashSize + 4
+	if msg.PkScript != nil {
+		n += varIntSerializeSize(uint64(len(msg.PkScript))) +
+			len(msg.PkScript)
+	}
+	return n
+}
+
+// Validate always returns an error since the reply message is rejected by
+// BtcEncode.  This is part of the Message interface implementation.
+func (msg *MsgReply) Validate() error {
+	return errDecodeOnly("MsgReply.Validate")
+}
+
+// Equal returns whether the message has the same reply hash, result, and
+// public key script as other.  Two nil messages are considered equal, while a
+// nil message is not equal to any other.
+func (msg *MsgReply) Equal(other *MsgReply) bool {
+	if msg == nil || other == nil {
+		return msg == other
+	}
+	return msg.ReplyHash == other.ReplyHash &&
+		msg.Result == other.Result &&
+		(msg.PkScript == nil) == (other.PkScript == nil) &&
+		bytes.Equal(msg.PkScript, other.PkScript)
+}
+
+// GoString returns a Go-syntax representation of the message.  This is part
+// of the fmt.GoStringer interface implementation.
+func (msg *MsgReply) GoString() string {
+	return goString(msg)
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"io"
+	"testing"
+)
+
+// TestReplyWire tests the MsgReply wire decode.
+func TestReplyWire(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	hash := btcwire.GenesisHash
+
+	// Reply to a submitorder message which failed.
+	failed := &btcwire.MsgReply{ReplyHash: hash, Result: 1}
+	failedEncoded := append([]byte{}, hash[:]...)
+	failedEncoded = append(failedEncoded, 0x01, 0x00, 0x00, 0x00) // Result
+
+	// Reply to a checkorder message with the public key script to pay.
+	pkScript := []byte{0x01, 0x02, 0xac}
+	script := &btcwire.MsgReply{ReplyHash: hash, PkScript: pkScript}
+	scriptEncoded := append([]byte{}, hash[:]...)
+	scriptEncoded = append(scriptEncoded, 0x00, 0x00, 0x00, 0x00) // Result
+	scriptEncoded = append(scriptEncoded, 0x03)                   // Varint for script length
+	scriptEncoded = append(scriptEncoded, pkScript...)
+
+	tests := []struct {
+		out *btcwire.MsgReply // Expected decoded message
+		buf []byte            // Wire encoding
+	}{
+		{failed, failedEncoded},
+		{script, scriptEncoded},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		var msg btcwire.MsgReply
+		err := msg.BtcDecode(bytes.NewReader(test.buf), pver)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !msg.Equal(test.out) {
+			t.Errorf("BtcDecode #%d\n%v", i, btcwire.Diff(test.out, &msg))
+		}
+		if size := msg.SerializeSize(pver); size != len(test.buf) {
+			t.Errorf("SerializeSize #%d: got %d, want %d", i, size,
+				len(test.buf))
+		}
+	}
+}
+
+// TestReplyWireErrors performs negative tests against the decoding of MsgReply
+// to confirm error paths work correctly.
+func TestReplyWireErrors(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	encoded := append([]byte{}, btcwire.GenesisHash[:]...)
+	encoded = append(encoded, 0x00, 0x00, 0x00, 0x00, 0x03, 0x01, 0x02, 0xac)
+
+	// Encoded message with a script larger than allowed.
+	longScript := append([]byte{}, encoded[:36]...)
+	longScript = append(longScript, 0xfd, 0x11, 0x27) // 10001
+
+	tests := []struct {
+		buf []byte // Wire encoding
+		err error  // Expected read error
+	}{
+		// Force error in reply hash.
+		{encoded[:0], io.EOF},
+		// Force error in result.
+		{encoded[:32], io.EOF},
+		// Force error with a script which doesn't fit.
+		{encoded[:38], &btcwire.MessageError{}},
+		// Force error with a script larger than allowed.
+		{longScript, &btcwire.MessageError{}},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		var msg btcwire.MsgReply
+		err := msg.BtcDecode(bytes.NewReader(test.buf), pver)
+		if _, ok := test.err.(*btcwire.MessageError); ok {
+			if _, ok := err.(*btcwire.MessageError); !ok {
+				t.Errorf("BtcDecode #%d wrong error got: %v, "+
+					"want: %T", i, err, test.err)
+			}
+			continue
+		}
+		if err != test.err {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.err)
+		}
+	}
+}
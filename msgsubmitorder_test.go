@@ -0,0 +1,42 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"testing"
+)
+
+// TestSubmitOrder tests the MsgSubmitOrder API.
+func TestSubmitOrder(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+
+	// Ensure the command is expected value.
+	wantCmd := "submitorder"
+	msg := &btcwire.MsgSubmitOrder{}
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("MsgSubmitOrder: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure the message decodes the same order as a checkorder message.
+	encoded := append(append([]byte{}, btcwire.GenesisHash[:]...),
+		walletTxEncoded...)
+	err := msg.BtcDecode(bytes.NewReader(encoded), pver)
+	if err != nil {
+		t.Fatalf("BtcDecode: unexpected error %v", err)
+	}
+	want := &btcwire.MsgSubmitOrder{
+		ReplyHash: btcwire.GenesisHash,
+		Order:     walletTx,
+	}
+	if !msg.Equal(want) {
+		t.Errorf("BtcDecode: wrong message\n%v", btcwire.Diff(want, msg))
+	}
+	if size := msg.SerializeSize(pver); size != len(encoded) {
+		t.Errorf("SerializeSize: got %d, want %d", size, len(encoded))
+	}
+}
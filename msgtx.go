@@ -6,7 +6,6 @@ package btcwire
 
 import (
 	"bytes"
-	"encoding/binary"
 	"fmt"
 	"io"
 )
@@ -14,6 +13,11 @@ import (
 // TxVersion is the current latest supported transaction version.
 const TxVersion = 1
 
+// MaxScriptSize is the maximum size of a signature script or public key script
+// allowed by the consensus rules.  It is the default limit on the size of the
+// scripts of decoded transactions.  See ReadOptions for overriding it.
+const MaxScriptSize = 10000
+
 // MaxTxInSequenceNum is the maximum sequence number the sequence field
 // of a transaction input can be.
 const MaxTxInSequenceNum uint32 = 0xffffffff
@@ -33,7 +37,7 @@ const (
 
 	// maxTxInPerMessage is the maximum number of transactions inputs that
 	// a transaction which fits into a message could possibly have.
-	maxTxInPerMessage = (maxMessagePayload / minTxInPayload) + 1
+	maxTxInPerMessage = (MaxMessagePayload / minTxInPayload) + 1
 
 	// minTxOutPayload is the minimum payload size for a transaction output.
 	// Value 8 bytes + Varint for PkScript length 1 byte.
@@ -41,7 +45,7 @@ const (
 
 	// maxTxOutPerMessage is the maximum number of transactions outputs that
 	// a transaction which fits into a message could possibly have.
-	maxTxOutPerMessage = (maxMessagePayload / minTxOutPayload) + 1
+	maxTxOutPerMessage = (MaxMessagePayload / minTxOutPayload) + 1
 
 	// minTxPayload is the minimum payload size for a transaction.  Note
 	// that any realistically usable transaction must have at least one
@@ -86,6 +90,19 @@ func (t *TxIn) SerializeSize() int {
 		len(t.SignatureScript)
 }
 
+// Equal returns whether the transaction input has the same previous outpoint,
+// signature script, and sequence number as other.  Nil and empty scripts are
+// considered the same.  Two nil inputs are considered equal, while a nil input
+// is not equal to any other.
+func (t *TxIn) Equal(other *TxIn) bool {
+	if t == nil || other == nil {
+		return t == other
+	}
+	return t.PreviousOutpoint == other.PreviousOutpoint &&
+		bytes.Equal(t.SignatureScript, other.SignatureScript) &&
+		t.Sequence == other.Sequence
+}
+
 // NewTxIn returns a new bitcoin transaction input with the provided
 // previous outpoint point and signature script with a default sequence of
 // MaxTxInSequenceNum.
@@ -97,6 +114,12 @@ func NewTxIn(prevOut *OutPoint, signatureScript []byte) *TxIn {
 	}
 }
 
+// dustSpendSize is the size of a typical input which spends an output, as used
+// by TxOut.IsDust.  It is the outpoint hash 32 bytes + outpoint index 4 bytes
+// + script length 1 byte + signature script 107 bytes for a signature and
+// compressed public key + sequence 4 bytes.
+const dustSpendSize = 32 + 4 + 1 + 107 + 4
+
 // TxOut defines a bitcoin transaction output.
 type TxOut struct {
 	Value    int64
@@ -111,11 +134,34 @@ func (t *TxOut) SerializeSize() int {
 	return 8 + varIntSerializeSize(uint64(len(t.PkScript))) + len(t.PkScript)
 }
 
+// Equal returns whether the transaction output has the same value and public
+// key script as other.  Nil and empty scripts are considered the same.  Two nil
+// outputs are considered equal, while a nil output is not equal to any other.
+func (t *TxOut) Equal(other *TxOut) bool {
+	if t == nil || other == nil {
+		return t == other
+	}
+	return t.Value == other.Value && bytes.Equal(t.PkScript, other.PkScript)
+}
+
+// IsDust returns whether the transaction output is dust, which is an output
+// whose value is so small that spending it would cost more than a third of its
+// value in fees at the provided minimum relay fee rate.  This is the standard
+// dust calculation of the reference implementation, which nodes use to refuse
+// to relay transactions creating such outputs.
+//
+// The cost of spending the output is the fee for its own serialized size plus
+// the size of a typical input which spends it.
+func (t *TxOut) IsDust(minRelayFeeRate FeeRate) bool {
+	totalSize := t.SerializeSize() + dustSpendSize
+	return 3*minRelayFeeRate.Fee(totalSize) > t.Value
+}
+
 // NewTxOut returns a new bitcoin transaction output with the provided
 // transaction value and public key script.
-func NewTxOut(value int64, pkScript []byte) *TxOut {
+func NewTxOut(value Amount, pkScript []byte) *TxOut {
 	return &TxOut{
-		Value:    value,
+		Value:    int64(value),
 		PkScript: pkScript,
 	}
 }
@@ -126,35 +172,47 @@ func NewTxOut(value int64, pkScript []byte) *TxOut {
 //
 // Use the AddTxIn and AddTxOut functions to build up the list of transaction
 // inputs and outputs.
+//
+// NOTE: Once SerializedBytes has been called, the serialized form of the
+// transaction is cached and used for all further encoding and by SerializeSize.
+// Call InvalidateCache after modifying the transaction to discard it.
 type MsgTx struct {
 	Version  uint32
 	TxIn     []*TxIn
 	TxOut    []*TxOut
 	LockTime uint32
+
+	// serialized holds the cached serialized bytes of the transaction, if
+	// any.  See SerializedBytes.
+	serialized []byte
+
+	// pooled indicates the transaction was obtained via AcquireMsgTx, in
+	// which case decoding into it reuses released inputs, outputs, and
+	// script buffers.
+	pooled bool
 }
 
 // AddTxIn adds a transaction input to the message.
 func (msg *MsgTx) AddTxIn(ti *TxIn) {
 	msg.TxIn = append(msg.TxIn, ti)
+	msg.serialized = nil
 }
 
 // AddTxOut adds a transaction output to the message.
 func (msg *MsgTx) AddTxOut(to *TxOut) {
 	msg.TxOut = append(msg.TxOut, to)
+	msg.serialized = nil
 }
 
 // TxSha generates the ShaHash name for the transaction.
 func (msg *MsgTx) TxSha() (ShaHash, error) {
-	// Encode the transaction and calculate double sha256 on the result.
-	// Ignore the error returns since the only way the encode could fail
-	// is being out of memory or due to nil pointers, both of which would
-	// cause a run-time panic.  Also, SetBytes can't fail here due to the
-	// fact DoubleSha256 always returns a []byte of the right size
-	// regardless of input.
-	var buf bytes.Buffer
-	var sha ShaHash
-	_ = msg.Serialize(&buf)
-	_ = sha.SetBytes(DoubleSha256(buf.Bytes()))
+	// Encode the transaction directly into the hasher.  Ignore the error
+	// returns since the only way the encode could fail is due to nil
+	// pointers, which would cause a run-time panic, and writing to a
+	// HashWriter never fails.
+	hw := NewHashWriter()
+	_ = msg.Serialize(hw)
+	sha := hw.Sum()
 
 	// Even though this function can't currently fail, it still returns
 	// a potential error to help future proof the API should a failure
@@ -162,6 +220,36 @@ func (msg *MsgTx) TxSha() (ShaHash, error) {
 	return sha, nil
 }
 
+// SerializedBytes returns the serialized bytes of the transaction.  The result
+// is cached on the first call so subsequent calls, as well as BtcEncode and
+// Serialize, reuse the cached bytes instead of re-serializing the
+// transaction.  This is useful when relaying the same transaction to many
+// peers.
+//
+// The returned slice must not be modified.  Call InvalidateCache after
+// modifying any of the transaction fields so the cached bytes are not used.
+func (msg *MsgTx) SerializedBytes() ([]byte, error) {
+	if msg.serialized != nil {
+		return msg.serialized, nil
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(msg.SerializeSize(0))
+	err := msg.Serialize(&buf)
+	if err != nil {
+		return nil, err
+	}
+	msg.serialized = buf.Bytes()
+	return msg.serialized, nil
+}
+
+// InvalidateCache discards the serialized bytes cached by SerializedBytes, if
+// any.  It must be called after modifying a transaction for which the
+// serialized bytes have been cached.
+func (msg *MsgTx) InvalidateCache() {
+	msg.serialized = nil
+}
+
 // Copy creates a deep copy of a transaction so that the original does not get
 // modified when the copy is manipulated.
 func (msg *MsgTx) Copy() *MsgTx {
@@ -229,12 +317,15 @@ func (msg *MsgTx) Copy() *MsgTx {
 // See Deserialize for decoding transactions stored to disk, such as in a
 // database, as opposed to decoding transactions from the wire.
 func (msg *MsgTx) BtcDecode(r io.Reader, pver uint32) error {
-	buf := make([]byte, 4)
-	_, err := io.ReadFull(r, buf)
+	// Discard any cached serialized bytes since they belong to whatever
+	// the transaction held before.
+	msg.serialized = nil
+
+	version, err := binarySerializer.Uint32(r, littleEndian)
 	if err != nil {
 		return err
 	}
-	msg.Version = binary.LittleEndian.Uint32(buf)
+	msg.Version = version
 
 	count, err := readVarInt(r, pver)
 	if err != nil {
@@ -248,17 +339,37 @@ func (msg *MsgTx) BtcDecode(r io.Reader, pver uint32) error {
 		str := fmt.Sprintf("too many input transactions to fit into "+
 			"max message size [count %d, max %d]", count,
 			maxTxInPerMessage)
-		return messageError("MsgTx.BtcDecode", str)
+		return categorizedError("MsgTx.BtcDecode", str,
+			ErrCategoryOversized)
+	}
+	err = checkCountFits(r, "MsgTx.BtcDecode", "transaction inputs", count,
+		minTxInPayload)
+	if err != nil {
+		return err
+	}
+	err = reserveAlloc(r, "MsgTx.BtcDecode", "transaction inputs",
+		count*txInAllocSize)
+	if err != nil {
+		return err
 	}
 
-	msg.TxIn = make([]*TxIn, count)
+	if msg.pooled && uint64(cap(msg.TxIn)) >= count {
+		msg.TxIn = msg.TxIn[:count]
+	} else {
+		msg.TxIn = make([]*TxIn, count)
+	}
 	for i := uint64(0); i < count; i++ {
-		ti := TxIn{}
-		err = readTxIn(r, pver, msg.Version, &ti)
+		var ti *TxIn
+		if msg.pooled {
+			ti = txInPool.Get().(*TxIn)
+		} else {
+			ti = &TxIn{}
+		}
+		err = readTxIn(r, pver, msg.Version, ti)
 		if err != nil {
 			return err
 		}
-		msg.TxIn[i] = &ti
+		msg.TxIn[i] = ti
 	}
 
 	count, err = readVarInt(r, pver)
@@ -273,24 +384,43 @@ func (msg *MsgTx) BtcDecode(r io.Reader, pver uint32) error {
 		str := fmt.Sprintf("too many output transactions to fit into "+
 			"max message size [count %d, max %d]", count,
 			maxTxOutPerMessage)
-		return messageError("MsgTx.BtcDecode", str)
+		return categorizedError("MsgTx.BtcDecode", str,
+			ErrCategoryOversized)
+	}
+	err = checkCountFits(r, "MsgTx.BtcDecode", "transaction outputs",
+		count, minTxOutPayload)
+	if err != nil {
+		return err
+	}
+	err = reserveAlloc(r, "MsgTx.BtcDecode", "transaction outputs",
+		count*txOutAllocSize)
+	if err != nil {
+		return err
 	}
 
-	msg.TxOut = make([]*TxOut, count)
+	if msg.pooled && uint64(cap(msg.TxOut)) >= count {
+		msg.TxOut = msg.TxOut[:count]
+	} else {
+		msg.TxOut = make([]*TxOut, count)
+	}
 	for i := uint64(0); i < count; i++ {
-		to := TxOut{}
-		err = readTxOut(r, pver, msg.Version, &to)
+		var to *TxOut
+		if msg.pooled {
+			to = txOutPool.Get().(*TxOut)
+		} else {
+			to = &TxOut{}
+		}
+		err = readTxOut(r, pver, msg.Version, to)
 		if err != nil {
 			return err
 		}
-		msg.TxOut[i] = &to
+		msg.TxOut[i] = to
 	}
 
-	_, err = io.ReadFull(r, buf)
+	msg.LockTime, err = binarySerializer.Uint32(r, littleEndian)
 	if err != nil {
 		return err
 	}
-	msg.LockTime = binary.LittleEndian.Uint32(buf)
 
 	return nil
 }
@@ -317,9 +447,13 @@ func (msg *MsgTx) Deserialize(r io.Reader) error {
 // See Serialize for encoding transactions to be stored to disk, such as in a
 // database, as opposed to encoding transactions for the wire.
 func (msg *MsgTx) BtcEncode(w io.Writer, pver uint32) error {
-	buf := make([]byte, 4)
-	binary.LittleEndian.PutUint32(buf, msg.Version)
-	_, err := w.Write(buf)
+	// Write the cached serialized bytes when available.
+	if msg.serialized != nil {
+		_, err := w.Write(msg.serialized)
+		return err
+	}
+
+	err := binarySerializer.PutUint32(w, littleEndian, msg.Version)
 	if err != nil {
 		return err
 	}
@@ -350,8 +484,7 @@ func (msg *MsgTx) BtcEncode(w io.Writer, pver uint32) error {
 		}
 	}
 
-	binary.LittleEndian.PutUint32(buf, msg.LockTime)
-	_, err = w.Write(buf)
+	err = binarySerializer.PutUint32(w, littleEndian, msg.LockTime)
 	if err != nil {
 		return err
 	}
@@ -377,9 +510,19 @@ func (msg *MsgTx) Serialize(w io.Writer) error {
 
 }
 
-// SerializeSize returns the number of bytes it would take to serialize the
-// the transaction.
-func (msg *MsgTx) SerializeSize() int {
+// SerializeSize returns the number of bytes it would take to encode the
+// transaction using the provided protocol version.  The encoding of a
+// transaction does not currently depend on the protocol version, so this is
+// also the number of bytes it would take to serialize the transaction with
+// Serialize.  The size of the cached serialized bytes is returned when
+// available, since those are what BtcEncode writes.  This is part of the
+// Message interface implementation.
+func (msg *MsgTx) SerializeSize(pver uint32) int {
+	// Use the cached serialized bytes when available.
+	if msg.serialized != nil {
+		return len(msg.serialized)
+	}
+
 	// Version 4 bytes + LockTime 4 bytes + Serialized varint size for the
 	// number of transaction inputs and outputs.
 	n := 8 + varIntSerializeSize(uint64(len(msg.TxIn))) +
@@ -396,10 +539,34 @@ func (msg *MsgTx) SerializeSize() int {
 	return n
 }
 
+// Validate returns an error when the transaction contains a script which is
+// larger than MaxScriptSize and would therefore be rejected when it is decoded
+// with the default options.  This is part of the Message interface
+// implementation.
+func (msg *MsgTx) Validate() error {
+	for i, ti := range msg.TxIn {
+		if len(ti.SignatureScript) > MaxScriptSize {
+			str := fmt.Sprintf("signature script of input %d is too "+
+				"large [len %d, max %d]", i,
+				len(ti.SignatureScript), MaxScriptSize)
+			return messageError("MsgTx.Validate", str)
+		}
+	}
+	for i, to := range msg.TxOut {
+		if len(to.PkScript) > MaxScriptSize {
+			str := fmt.Sprintf("public key script of output %d is "+
+				"too large [len %d, max %d]", i, len(to.PkScript),
+				MaxScriptSize)
+			return messageError("MsgTx.Validate", str)
+		}
+	}
+	return nil
+}
+
 // Command returns the protocol command string for the message.  This is part
 // of the Message interface implementation.
 func (msg *MsgTx) Command() string {
-	return cmdTx
+	return CmdTx
 }
 
 // MaxPayloadLength returns the maximum length the payload can be for the
@@ -408,6 +575,52 @@ func (msg *MsgTx) MaxPayloadLength(pver uint32) uint32 {
 	return MaxBlockPayload
 }
 
+// Equal returns whether the transaction has the same contents as other.
+// Cached serializations are ignored, and nil and empty scripts are considered
+// the same.  Two nil transactions are considered equal, while a nil
+// transaction is not equal to any other.
+func (msg *MsgTx) Equal(other *MsgTx) bool {
+	if msg == nil || other == nil {
+		return msg == other
+	}
+	if msg.Version != other.Version || msg.LockTime != other.LockTime ||
+		len(msg.TxIn) != len(other.TxIn) ||
+		len(msg.TxOut) != len(other.TxOut) {
+		return false
+	}
+	for i, ti := range msg.TxIn {
+		if !ti.Equal(other.TxIn[i]) {
+			return false
+		}
+	}
+	for i, to := range msg.TxOut {
+		if !to.Equal(other.TxOut[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// txListEqual returns whether the provided lists contain equal transactions in
+// the same order.
+func txListEqual(a, b []*MsgTx) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, tx := range a {
+		if !tx.Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// GoString returns a Go-syntax representation of the message.  This is part
+// of the fmt.GoStringer interface implementation.
+func (msg *MsgTx) GoString() string {
+	return goString(msg)
+}
+
 // NewMsgTx returns a new bitcoin tx message that conforms to the Message
 // interface.  The return instance has a default version of TxVersion and there
 // are no transaction inputs or outputs.  Also, the lock time is set to zero
@@ -428,12 +641,10 @@ func readOutPoint(r io.Reader, pver uint32, version uint32, op *OutPoint) error
 		return err
 	}
 
-	buf := make([]byte, 4)
-	_, err = io.ReadFull(r, buf)
+	op.Index, err = binarySerializer.Uint32(r, littleEndian)
 	if err != nil {
 		return err
 	}
-	op.Index = binary.LittleEndian.Uint32(buf)
 	return nil
 }
 
@@ -445,15 +656,46 @@ func writeOutPoint(w io.Writer, pver uint32, version uint32, op *OutPoint) error
 		return err
 	}
 
-	buf := make([]byte, 4)
-	binary.LittleEndian.PutUint32(buf, op.Index)
-	_, err = w.Write(buf)
+	err = binarySerializer.PutUint32(w, littleEndian, op.Index)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// maxScriptSize returns the maximum size of the scripts of transactions
+// decoded from r.  It is MaxScriptSize unless it has been overridden via
+// ReadOptions.
+func maxScriptSize(r io.Reader) uint64 {
+	pr, ok := r.(*payloadReader)
+	if ok && pr.maxScriptSize != 0 {
+		return uint64(pr.maxScriptSize)
+	}
+	return MaxScriptSize
+}
+
+// readScript reads a script of count bytes from r.  The script is read into
+// buf when it is non-nil and has enough capacity, which is the case for the
+// inputs and outputs of transactions obtained via AcquireMsgTx that are reused
+// after having been released.
+func readScript(r io.Reader, buf []byte, count uint64) ([]byte, error) {
+	err := reserveAlloc(r, "readScript", "script bytes", count)
+	if err != nil {
+		return nil, err
+	}
+
+	if buf != nil && uint64(cap(buf)) >= count {
+		buf = buf[:count]
+	} else {
+		buf = make([]byte, count)
+	}
+	_, err = io.ReadFull(r, buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
 // readTxIn reads the next sequence of bytes from r as a transaction input
 // (TxIn).
 func readTxIn(r io.Reader, pver uint32, version uint32, ti *TxIn) error {
@@ -469,29 +711,28 @@ func readTxIn(r io.Reader, pver uint32, version uint32, ti *TxIn) error {
 		return err
 	}
 
-	// Prevent signature script larger than the max message size.  It would
+	// Prevent signature script larger than the max script size.  It would
 	// be possible to cause memory exhaustion and panics without a sane
 	// upper bound on this count.
-	if count > uint64(maxMessagePayload) {
+	maxSize := maxScriptSize(r)
+	if count > maxSize {
 		str := fmt.Sprintf("transaction input signature script is "+
-			"larger than max message size [count %d, max %d]",
-			count, maxMessagePayload)
-		return messageError("MsgTx.BtcDecode", str)
+			"larger than max script size [count %d, max %d]",
+			count, maxSize)
+		return categorizedError("MsgTx.BtcDecode", str,
+			ErrCategoryOversized)
 	}
 
-	b := make([]byte, count)
-	_, err = io.ReadFull(r, b)
+	b, err := readScript(r, ti.SignatureScript, count)
 	if err != nil {
 		return err
 	}
 	ti.SignatureScript = b
 
-	b = make([]byte, 4)
-	_, err = io.ReadFull(r, b)
+	ti.Sequence, err = binarySerializer.Uint32(r, littleEndian)
 	if err != nil {
 		return err
 	}
-	ti.Sequence = binary.LittleEndian.Uint32(b)
 
 	return nil
 }
@@ -515,9 +756,7 @@ func writeTxIn(w io.Writer, pver uint32, version uint32, ti *TxIn) error {
 		return err
 	}
 
-	buf := make([]byte, 4)
-	binary.LittleEndian.PutUint32(buf, ti.Sequence)
-	_, err = w.Write(buf)
+	err = binarySerializer.PutUint32(w, littleEndian, ti.Sequence)
 	if err != nil {
 		return err
 	}
@@ -528,30 +767,30 @@ func writeTxIn(w io.Writer, pver uint32, version uint32, ti *TxIn) error {
 // readTxOut reads the next sequence of bytes from r as a transaction output
 // (TxOut).
 func readTxOut(r io.Reader, pver uint32, version uint32, to *TxOut) error {
-	buf := make([]byte, 8)
-	_, err := io.ReadFull(r, buf)
+	value, err := binarySerializer.Uint64(r, littleEndian)
 	if err != nil {
 		return err
 	}
-	to.Value = int64(binary.LittleEndian.Uint64(buf))
+	to.Value = int64(value)
 
 	count, err := readVarInt(r, pver)
 	if err != nil {
 		return err
 	}
 
-	// Prevent public key script larger than the max message size.  It would
+	// Prevent public key script larger than the max script size.  It would
 	// be possible to cause memory exhaustion and panics without a sane
 	// upper bound on this count.
-	if count > uint64(maxMessagePayload) {
+	maxSize := maxScriptSize(r)
+	if count > maxSize {
 		str := fmt.Sprintf("transaction output public key script is "+
-			"larger than max message size [count %d, max %d]",
-			count, maxMessagePayload)
-		return messageError("MsgTx.BtcDecode", str)
+			"larger than max script size [count %d, max %d]",
+			count, maxSize)
+		return categorizedError("MsgTx.BtcDecode", str,
+			ErrCategoryOversized)
 	}
 
-	b := make([]byte, count)
-	_, err = io.ReadFull(r, b)
+	b, err := readScript(r, to.PkScript, count)
 	if err != nil {
 		return err
 	}
@@ -563,9 +802,7 @@ func readTxOut(r io.Reader, pver uint32, version uint32, to *TxOut) error {
 // writeTxOut encodes to into the bitcoin protocol encoding for a transaction
 // output (TxOut) to w.
 func writeTxOut(w io.Writer, pver uint32, version uint32, to *TxOut) error {
-	buf := make([]byte, 8)
-	binary.LittleEndian.PutUint64(buf, uint64(to.Value))
-	_, err := w.Write(buf)
+	err := binarySerializer.PutUint64(w, littleEndian, uint64(to.Value))
 	if err != nil {
 		return err
 	}
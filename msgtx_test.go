@@ -83,7 +83,7 @@ func TestTx(t *testing.T) {
 		0xa6, // 65-byte signature
 		0xac, // OP_CHECKSIG
 	}
-	txOut := btcwire.NewTxOut(txValue, pkScript)
+	txOut := btcwire.NewTxOut(btcwire.Amount(txValue), pkScript)
 	if txOut.Value != txValue {
 		t.Errorf("NewTxOut: wrong pk script - got %v, want %v",
 			txOut.Value, txValue)
@@ -294,8 +294,8 @@ func TestTxWire(t *testing.T) {
 			continue
 		}
 		if !reflect.DeepEqual(&msg, test.out) {
-			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
-				spew.Sdump(&msg), spew.Sdump(test.out))
+			t.Errorf("BtcDecode #%d\n%v", i,
+				btcwire.Diff(test.out, &msg))
 			continue
 		}
 	}
@@ -423,8 +423,8 @@ func TestTxSerialize(t *testing.T) {
 			continue
 		}
 		if !reflect.DeepEqual(&tx, test.out) {
-			t.Errorf("Deserialize #%d\n got: %s want: %s", i,
-				spew.Sdump(&tx), spew.Sdump(test.out))
+			t.Errorf("Deserialize #%d\n%v", i,
+				btcwire.Diff(test.out, &tx))
 			continue
 		}
 	}
@@ -562,6 +562,40 @@ func TestTxOverflowErrors(t *testing.T) {
 				0xff, // Varint for length of public key script
 			}, pver, txVer, &btcwire.MessageError{},
 		},
+
+		// Transaction that has an input with a signature script that
+		// is one byte larger than the max script size.
+		{
+			[]byte{
+				0x00, 0x00, 0x00, 0x01, // Version
+				0x01, // Varint for number of input transactions
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Previous output hash
+				0xff, 0xff, 0xff, 0xff, // Prevous output index
+				0xfd, 0x11, 0x27, // Varint for length of signature script
+			}, pver, txVer, &btcwire.MessageError{},
+		},
+
+		// Transaction that has an output with a public key script
+		// that is one byte larger than the max script size.
+		{
+			[]byte{
+				0x00, 0x00, 0x00, 0x01, // Version
+				0x01, // Varint for number of input transactions
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Previous output hash
+				0xff, 0xff, 0xff, 0xff, // Prevous output index
+				0x00,                   // Varint for length of signature script
+				0xff, 0xff, 0xff, 0xff, // Sequence
+				0x01,                                           // Varint for number of output transactions
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Transaction amount
+				0xfd, 0x11, 0x27, // Varint for length of public key script
+			}, pver, txVer, &btcwire.MessageError{},
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -607,7 +641,7 @@ func TestTxSerializeSize(t *testing.T) {
 
 	t.Logf("Running %d tests", len(tests))
 	for i, test := range tests {
-		serializedSize := test.in.SerializeSize()
+		serializedSize := test.in.SerializeSize(0)
 		if serializedSize != test.size {
 			t.Errorf("MsgTx.SerializeSize: #%d got: %d, want: %d", i,
 				serializedSize, test.size)
@@ -616,6 +650,212 @@ func TestTxSerializeSize(t *testing.T) {
 	}
 }
 
+// TestTxOutIsDust ensures the dust threshold of transaction outputs follows
+// the standard calculation based on their serialized size.
+func TestTxOutIsDust(t *testing.T) {
+	// A pay-to-pubkey-hash script is 25 bytes, so the output is 34 bytes
+	// and spending it costs 182 bytes in total.
+	pkScript := make([]byte, 25)
+
+	tests := []struct {
+		value   btcwire.Amount  // Value of the output
+		script  []byte          // Public key script of the output
+		feeRate btcwire.FeeRate // Minimum relay fee rate
+		want    bool            // Expected result
+	}{
+		{545, pkScript, 1000, true},
+		{546, pkScript, 1000, false},
+		{0, pkScript, 0, false},
+		{-1, pkScript, 0, true},
+		{1, pkScript, 0, false},
+		{5459, pkScript, 10000, true},
+		{5460, pkScript, 10000, false},
+
+		// A 67-byte pay-to-pubkey script makes the output 76 bytes and
+		// spending it 224 bytes.
+		{671, make([]byte, 67), 1000, true},
+		{672, make([]byte, 67), 1000, false},
+
+		// An empty script makes the output 9 bytes and spending it 157
+		// bytes, which rounds down to a fee of 0 below 7 satoshi per
+		// kB.
+		{0, nil, 6, false},
+		{2, nil, 7, true},
+		{3, nil, 7, false},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		to := btcwire.NewTxOut(test.value, test.script)
+		if got := to.IsDust(test.feeRate); got != test.want {
+			t.Errorf("IsDust #%d (value %d, rate %d) got: %v want: "+
+				"%v", i, test.value, test.feeRate, got, test.want)
+		}
+	}
+}
+
+// TestTxSerializedBytes tests the serialization cache of MsgTx.
+func TestTxSerializedBytes(t *testing.T) {
+	tx := multiTx.Copy()
+
+	// Ensure the serialized bytes are the expected value.
+	serialized, err := tx.SerializedBytes()
+	if err != nil {
+		t.Errorf("SerializedBytes: %v", err)
+	}
+	if !bytes.Equal(serialized, multiTxEncoded) {
+		t.Errorf("SerializedBytes: wrong bytes\n got: %s want: %s",
+			spew.Sdump(serialized), spew.Sdump(multiTxEncoded))
+	}
+
+	// Ensure modifications are not reflected in the encoding until the
+	// cache is invalidated.
+	tx.LockTime = 1
+	var buf bytes.Buffer
+	err = tx.Serialize(&buf)
+	if err != nil {
+		t.Errorf("Serialize: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), multiTxEncoded) {
+		t.Errorf("Serialize: did not use cached bytes\n got: %s "+
+			"want: %s", spew.Sdump(buf.Bytes()),
+			spew.Sdump(multiTxEncoded))
+	}
+
+	tx.InvalidateCache()
+	buf.Reset()
+	err = tx.Serialize(&buf)
+	if err != nil {
+		t.Errorf("Serialize: %v", err)
+	}
+	if bytes.Equal(buf.Bytes(), multiTxEncoded) {
+		t.Errorf("Serialize: used cached bytes after InvalidateCache")
+	}
+
+	// Ensure adding an output discards the cache.
+	_, err = tx.SerializedBytes()
+	if err != nil {
+		t.Errorf("SerializedBytes: %v", err)
+	}
+	tx.AddTxOut(btcwire.NewTxOut(1, []byte{0x51}))
+	serialized, err = tx.SerializedBytes()
+	if err != nil {
+		t.Errorf("SerializedBytes: %v", err)
+	}
+	if want := tx.Copy().SerializeSize(0); len(serialized) != want {
+		t.Errorf("SerializedBytes: stale bytes after AddTxOut - got "+
+			"len %d, want %d", len(serialized), want)
+	}
+
+	// Ensure SerializeSize reports the size of the cached bytes, which is
+	// what is encoded, until the cache is invalidated.
+	tx.LockTime = 2
+	tx.TxIn[0].SignatureScript = append(tx.TxIn[0].SignatureScript, 0x00)
+	if size := tx.SerializeSize(0); size != len(serialized) {
+		t.Errorf("SerializeSize: got %d, want cached size %d", size,
+			len(serialized))
+	}
+	tx.InvalidateCache()
+	if size := tx.SerializeSize(0); size != len(serialized)+1 {
+		t.Errorf("SerializeSize: got %d after InvalidateCache, want %d",
+			size, len(serialized)+1)
+	}
+
+	// Ensure decoding into a transaction with cached bytes discards them,
+	// so the decoded transaction is encoded and hashed rather than the
+	// transaction it replaced.
+	_, err = tx.SerializedBytes()
+	if err != nil {
+		t.Errorf("SerializedBytes: %v", err)
+	}
+	err = tx.Deserialize(bytes.NewReader(multiTxEncoded))
+	if err != nil {
+		t.Fatalf("Deserialize: %v", err)
+	}
+	serialized, err = tx.SerializedBytes()
+	if err != nil {
+		t.Errorf("SerializedBytes: %v", err)
+	}
+	if !bytes.Equal(serialized, multiTxEncoded) {
+		t.Errorf("SerializedBytes: stale bytes after Deserialize\n "+
+			"got: %s want: %s", spew.Sdump(serialized),
+			spew.Sdump(multiTxEncoded))
+	}
+	gotSha, _ := tx.TxSha()
+	wantSha, _ := multiTx.TxSha()
+	if gotSha != wantSha {
+		t.Errorf("TxSha: stale hash after Deserialize - got %v, want "+
+			"%v", gotSha, wantSha)
+	}
+}
+
+// TestTxEqual tests comparing transactions with Equal.
+func TestTxEqual(t *testing.T) {
+	// A decoded transaction has a cached serialization and scripts which
+	// share a buffer, neither of which should affect equality.
+	var decoded btcwire.MsgTx
+	err := decoded.Deserialize(bytes.NewReader(multiTxEncoded))
+	if err != nil {
+		t.Fatalf("Deserialize: %v", err)
+	}
+
+	changedVersion := multiTx.Copy()
+	changedVersion.Version++
+	changedLockTime := multiTx.Copy()
+	changedLockTime.LockTime++
+	changedSequence := multiTx.Copy()
+	changedSequence.TxIn[0].Sequence--
+	changedOutPoint := multiTx.Copy()
+	changedOutPoint.TxIn[0].PreviousOutpoint.Index = 0
+	changedSigScript := multiTx.Copy()
+	changedSigScript.TxIn[0].SignatureScript[0] ^= 0xff
+	changedValue := multiTx.Copy()
+	changedValue.TxOut[0].Value++
+	changedPkScript := multiTx.Copy()
+	changedPkScript.TxOut[0].PkScript = nil
+	extraOutput := multiTx.Copy()
+	extraOutput.AddTxOut(btcwire.NewTxOut(0, nil))
+
+	// Nil and empty scripts are the same.
+	nilScripts := btcwire.NewMsgTx()
+	nilScripts.AddTxOut(btcwire.NewTxOut(0, nil))
+	emptyScripts := btcwire.NewMsgTx()
+	emptyScripts.AddTxOut(btcwire.NewTxOut(0, []byte{}))
+
+	tests := []struct {
+		a, b *btcwire.MsgTx // Transactions to compare
+		want bool           // Expected result
+	}{
+		{multiTx, multiTx, true},
+		{multiTx, multiTx.Copy(), true},
+		{multiTx, &decoded, true},
+		{nilScripts, emptyScripts, true},
+		{nil, nil, true},
+		{multiTx, nil, false},
+		{nil, multiTx, false},
+		{multiTx, btcwire.NewMsgTx(), false},
+		{multiTx, changedVersion, false},
+		{multiTx, changedLockTime, false},
+		{multiTx, changedSequence, false},
+		{multiTx, changedOutPoint, false},
+		{multiTx, changedSigScript, false},
+		{multiTx, changedValue, false},
+		{multiTx, changedPkScript, false},
+		{multiTx, extraOutput, false},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		if got := test.a.Equal(test.b); got != test.want {
+			t.Errorf("Equal #%d got: %v want: %v", i, got, test.want)
+		}
+		if got := test.b.Equal(test.a); got != test.want {
+			t.Errorf("Equal #%d (reversed) got: %v want: %v", i, got,
+				test.want)
+		}
+	}
+}
+
 // multiTx is a MsgTx with an input and output and used in various tests.
 var multiTx = &btcwire.MsgTx{
 	Version: 1,
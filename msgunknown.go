@@ -0,0 +1,144 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// MsgUnknown implements the Message interface and represents a message with a
+// command this package does not recognize.  It is only returned by
+// ReadMessageWithOptions when the AllowUnknown option is set and carries the
+// command string along with the raw payload so the caller can log, forward,
+// or ignore messages from peers which speak a newer protocol without
+// dropping the connection.
+//
+// Since the payload is treated as an opaque blob, a MsgUnknown may also be
+// written with WriteMessage in order to forward it unchanged.
+type MsgUnknown struct {
+	// command is the command from the header of the message.
+	command string
+
+	// Payload is the raw payload of the message.
+	Payload []byte
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// Since the format of the payload is not known, all remaining bytes in r are
+// read as the payload.  This is part of the Message interface implementation.
+func (msg *MsgUnknown) BtcDecode(r io.Reader, pver uint32) error {
+	payload, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	msg.Payload = payload
+	return nil
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// The raw payload is written as is.  This is part of the Message interface
+// implementation.
+func (msg *MsgUnknown) BtcEncode(w io.Writer, pver uint32) error {
+	if len(msg.Payload) > MaxMessagePayload {
+		str := fmt.Sprintf("payload is too large [len %v, max %v]",
+			len(msg.Payload), MaxMessagePayload)
+		return messageError("MsgUnknown.BtcEncode", str)
+	}
+
+	_, err := w.Write(msg.Payload)
+	return err
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgUnknown) Command() string {
+	return msg.command
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgUnknown) MaxPayloadLength(pver uint32) uint32 {
+	// Since nothing is known about the message, make it the max size
+	// allowed.
+	return MaxMessagePayload
+}
+
+// SerializeSize returns the number of bytes it would take to encode the message
+// using the provided protocol version.  This is part of the Message interface
+// implementation.
+func (msg *MsgUnknown) SerializeSize(pver uint32) int {
+	return len(msg.Payload)
+}
+
+// Validate returns an error when the command of the message can't be
+// represented in a message header or the payload is too large.  This is part
+// of the Message interface implementation.
+func (msg *MsgUnknown) Validate() error {
+	err := validateCommand("MsgUnknown.Validate", msg.command)
+	if err != nil {
+		return err
+	}
+	if len(msg.Payload) > MaxMessagePayload {
+		str := fmt.Sprintf("payload is too large [len %v, max %v]",
+			len(msg.Payload), MaxMessagePayload)
+		return messageError("MsgUnknown.Validate", str)
+	}
+	return nil
+}
+
+// Equal returns whether the message has the same command and payload as
+// other.  Two nil messages are considered equal, while a nil message is not
+// equal to any other.
+func (msg *MsgUnknown) Equal(other *MsgUnknown) bool {
+	if msg == nil || other == nil {
+		return msg == other
+	}
+	return msg.command == other.command &&
+		bytes.Equal(msg.Payload, other.Payload)
+}
+
+// GoString returns a Go-syntax representation of the message in terms of
+// MustNewMsgUnknown since the command is not exported.  This is part of the
+// fmt.GoStringer interface implementation.
+func (msg *MsgUnknown) GoString() string {
+	return fmt.Sprintf("%sMustNewMsgUnknown(%q, %s)", goStringPkg,
+		msg.command, goString(msg.Payload))
+}
+
+// NewMsgUnknown returns a new message for the provided command and raw payload
+// that conforms to the Message interface.  An error is returned when the
+// command can't be represented in a message header or the payload exceeds
+// MaxMessagePayload.  See MsgUnknown for details.
+func NewMsgUnknown(command string, payload []byte) (*MsgUnknown, error) {
+	err := validateCommand("NewMsgUnknown", command)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > MaxMessagePayload {
+		str := fmt.Sprintf("payload is too large [len %v, max %v]",
+			len(payload), MaxMessagePayload)
+		return nil, messageError("NewMsgUnknown", str)
+	}
+
+	return &MsgUnknown{
+		command: command,
+		Payload: payload,
+	}, nil
+}
+
+// MustNewMsgUnknown returns a new message for the provided command and raw
+// payload in the same manner as NewMsgUnknown, except it panics if they are
+// invalid.  It is intended for messages which are known to be valid, such as
+// those hard coded in tests or produced by GoString.
+func MustNewMsgUnknown(command string, payload []byte) *MsgUnknown {
+	msg, err := NewMsgUnknown(command, payload)
+	if err != nil {
+		panic(fmt.Sprintf("MustNewMsgUnknown(%q): %v", command, err))
+	}
+	return msg
+}
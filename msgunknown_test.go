@@ -0,0 +1,142 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"reflect"
+	"testing"
+)
+
+// TestUnknown tests the MsgUnknown API.
+func TestUnknown(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+
+	payload := []byte{0x01, 0x02, 0x03}
+	msg, err := btcwire.NewMsgUnknown("sendcmpct", payload)
+	if err != nil {
+		t.Fatalf("NewMsgUnknown: %v", err)
+	}
+
+	// Ensure the command is expected value.
+	wantCmd := "sendcmpct"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgUnknown: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value.
+	wantPayload := uint32(btcwire.MaxMessagePayload)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure the payload round trips unchanged.
+	var buf bytes.Buffer
+	err = msg.BtcEncode(&buf, pver)
+	if err != nil {
+		t.Errorf("BtcEncode: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), payload) {
+		t.Errorf("BtcEncode: wrong payload - got %v, want %v",
+			buf.Bytes(), payload)
+	}
+	var readmsg btcwire.MsgUnknown
+	err = readmsg.BtcDecode(&buf, pver)
+	if err != nil {
+		t.Errorf("BtcDecode: %v", err)
+	}
+	if !bytes.Equal(readmsg.Payload, payload) {
+		t.Errorf("BtcDecode: wrong payload - got %v, want %v",
+			readmsg.Payload, payload)
+	}
+}
+
+// TestNewMsgUnknownErrors ensures NewMsgUnknown rejects commands which can't
+// be represented in a message header.
+func TestNewMsgUnknownErrors(t *testing.T) {
+	tests := []struct {
+		command string // Command to use
+		valid   bool   // Whether the command is valid
+	}{
+		{"sendcmpct", true},
+		{"abcdefghijkl", true},
+		{"", false},
+		{"abcdefghijklm", false},
+		{"bad\x00cmd", false},
+		{"bad\xffcmd", false},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		_, err := btcwire.NewMsgUnknown(test.command, nil)
+		if test.valid {
+			if err != nil {
+				t.Errorf("NewMsgUnknown #%d (%q) error %v", i,
+					test.command, err)
+			}
+			continue
+		}
+		if _, ok := err.(*btcwire.MessageError); !ok {
+			t.Errorf("NewMsgUnknown #%d (%q) wrong error got: %T(%v)",
+				i, test.command, err, err)
+		}
+	}
+}
+
+// TestReadMessageAllowUnknown ensures unrecognized commands are returned as
+// MsgUnknown when requested and rejected otherwise.
+func TestReadMessageAllowUnknown(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcnet := btcwire.MainNet
+
+	unknown := btcwire.MustNewMsgUnknown("sendheaders", []byte{0xde, 0xad})
+	var buf bytes.Buffer
+	err := btcwire.WriteMessage(&buf, unknown, pver, btcnet)
+	if err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+	encoded := buf.Bytes()
+
+	// Ensure the default behavior rejects the message.
+	_, _, err = btcwire.ReadMessage(bytes.NewReader(encoded), pver, btcnet)
+	if _, ok := err.(*btcwire.MessageError); !ok {
+		t.Errorf("ReadMessage: expected MessageError - got %v <%T>",
+			err, err)
+	}
+
+	// Ensure the message is passed through when allowed.
+	opts := &btcwire.ReadOptions{AllowUnknown: true}
+	msg, _, err := btcwire.ReadMessageWithOptions(bytes.NewReader(encoded),
+		pver, btcnet, opts)
+	if err != nil {
+		t.Fatalf("ReadMessageWithOptions: %v", err)
+	}
+	if !reflect.DeepEqual(msg, unknown) {
+		t.Errorf("ReadMessageWithOptions\n got: %v want: %v",
+			spew.Sdump(msg), spew.Sdump(unknown))
+	}
+
+	// Ensure known messages are unaffected by the option.
+	buf.Reset()
+	ping := btcwire.NewMsgPing(1)
+	err = btcwire.WriteMessage(&buf, ping, pver, btcnet)
+	if err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+	msg, _, err = btcwire.ReadMessageWithOptions(&buf, pver, btcnet, opts)
+	if err != nil {
+		t.Fatalf("ReadMessageWithOptions: %v", err)
+	}
+	if _, ok := msg.(*btcwire.MsgPing); !ok {
+		t.Errorf("ReadMessageWithOptions: wrong type - got %T, "+
+			"want *btcwire.MsgPing", msg)
+	}
+}
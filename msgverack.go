@@ -30,7 +30,7 @@ func (msg *MsgVerAck) BtcEncode(w io.Writer, pver uint32) error {
 // Command returns the protocol command string for the message.  This is part
 // of the Message interface implementation.
 func (msg *MsgVerAck) Command() string {
-	return cmdVerAck
+	return CmdVerAck
 }
 
 // MaxPayloadLength returns the maximum length the payload can be for the
@@ -39,6 +39,31 @@ func (msg *MsgVerAck) MaxPayloadLength(pver uint32) uint32 {
 	return 0
 }
 
+// SerializeSize returns the number of bytes it would take to encode the verack
+// message using the provided protocol version, which is always zero.  This is
+// part of the Message interface implementation.
+func (msg *MsgVerAck) SerializeSize(pver uint32) int {
+	return 0
+}
+
+// Validate returns nil since the verack message has no payload which could be
+// invalid.  This is part of the Message interface implementation.
+func (msg *MsgVerAck) Validate() error {
+	return nil
+}
+
+// Equal returns whether the message is the same as other.  Since the message
+// has no fields, this is only the case when both or neither are nil.
+func (msg *MsgVerAck) Equal(other *MsgVerAck) bool {
+	return (msg == nil) == (other == nil)
+}
+
+// GoString returns a Go-syntax representation of the message.  This is part
+// of the fmt.GoStringer interface implementation.
+func (msg *MsgVerAck) GoString() string {
+	return goString(msg)
+}
+
 // NewMsgVerAck returns a new bitcoin verack message that conforms to the
 // Message interface.
 func NewMsgVerAck() *MsgVerAck {
@@ -48,6 +48,11 @@ type MsgVersion struct {
 
 	// Last block seen by the generator of the version message.
 	LastBlock int32
+
+	// Don't announce transactions to the peer until it sends a filter.
+	// This is encoded on the wire as the inverse relay flag which was added
+	// by BIP0037 (pver >= BIP0037Version).
+	DisableRelayTx bool
 }
 
 // HasService returns whether the specified service is supported by the peer
@@ -68,12 +73,21 @@ func (msg *MsgVersion) AddService(service ServiceFlag) {
 // BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
 // This is part of the Message interface implementation.
 func (msg *MsgVersion) BtcDecode(r io.Reader, pver uint32) error {
-	var sec int64
-	err := readElements(r, &msg.ProtocolVersion, &msg.Services, &sec)
+	protocolVersion, err := binarySerializer.Uint32(r, littleEndian)
+	if err != nil {
+		return err
+	}
+	msg.ProtocolVersion = int32(protocolVersion)
+	services, err := binarySerializer.Uint64(r, littleEndian)
+	if err != nil {
+		return err
+	}
+	msg.Services = ServiceFlag(services)
+	sec, err := binarySerializer.Uint64(r, littleEndian)
 	if err != nil {
 		return err
 	}
-	msg.Timestamp = time.Unix(sec, 0)
+	msg.Timestamp = time.Unix(int64(sec), 0)
 
 	err = readNetAddress(r, pver, &msg.AddrYou, false)
 	if err != nil {
@@ -85,7 +99,7 @@ func (msg *MsgVersion) BtcDecode(r io.Reader, pver uint32) error {
 		return err
 	}
 
-	err = readElement(r, &msg.Nonce)
+	msg.Nonce, err = binarySerializer.Uint64(r, littleEndian)
 	if err != nil {
 		return err
 	}
@@ -97,14 +111,30 @@ func (msg *MsgVersion) BtcDecode(r io.Reader, pver uint32) error {
 	if len(userAgent) > MaxUserAgentLen {
 		str := fmt.Sprintf("user agent too long [len %v, max %v]",
 			len(userAgent), MaxUserAgentLen)
-		return messageError("MsgVersion.BtcDecode", str)
+		return categorizedError("MsgVersion.BtcDecode", str,
+			ErrCategoryOversized)
 	}
 	msg.UserAgent = userAgent
 
-	err = readElement(r, &msg.LastBlock)
+	lastBlock, err := binarySerializer.Uint32(r, littleEndian)
 	if err != nil {
 		return err
 	}
+	msg.LastBlock = int32(lastBlock)
+
+	// The relay flag is optional even for protocol versions which support
+	// it, so it is only read when the reader is known to have more data.
+	if FeatureBloomFilter.IsSupported(pver) {
+		remaining, ok := bytesRemaining(r)
+		if ok && remaining > 0 {
+			var relayTx bool
+			err = readElement(r, &relayTx)
+			if err != nil {
+				return err
+			}
+			msg.DisableRelayTx = !relayTx
+		}
+	}
 
 	return nil
 }
@@ -118,8 +148,17 @@ func (msg *MsgVersion) BtcEncode(w io.Writer, pver uint32) error {
 		return messageError("MsgVersion.BtcEncode", str)
 	}
 
-	err := writeElements(w, msg.ProtocolVersion, msg.Services,
-		msg.Timestamp.Unix())
+	err := binarySerializer.PutUint32(w, littleEndian,
+		uint32(msg.ProtocolVersion))
+	if err != nil {
+		return err
+	}
+	err = binarySerializer.PutUint64(w, littleEndian, uint64(msg.Services))
+	if err != nil {
+		return err
+	}
+	err = binarySerializer.PutUint64(w, littleEndian,
+		uint64(msg.Timestamp.Unix()))
 	if err != nil {
 		return err
 	}
@@ -134,7 +173,7 @@ func (msg *MsgVersion) BtcEncode(w io.Writer, pver uint32) error {
 		return err
 	}
 
-	err = writeElement(w, msg.Nonce)
+	err = binarySerializer.PutUint64(w, littleEndian, msg.Nonce)
 	if err != nil {
 		return err
 	}
@@ -144,50 +183,156 @@ func (msg *MsgVersion) BtcEncode(w io.Writer, pver uint32) error {
 		return err
 	}
 
-	err = writeElement(w, msg.LastBlock)
+	err = binarySerializer.PutUint32(w, littleEndian, uint32(msg.LastBlock))
 	if err != nil {
 		return err
 	}
 
+	if FeatureBloomFilter.IsSupported(pver) {
+		err = writeElement(w, !msg.DisableRelayTx)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 // Command returns the protocol command string for the message.  This is part
 // of the Message interface implementation.
 func (msg *MsgVersion) Command() string {
-	return cmdVersion
+	return CmdVersion
 }
 
 // MaxPayloadLength returns the maximum length the payload can be for the
 // receiver.  This is part of the Message interface implementation.
 func (msg *MsgVersion) MaxPayloadLength(pver uint32) uint32 {
 	// XXX: <= 106 different
-	// XXX: >= 70001 different
 
 	// Protocol version 4 bytes + services 8 bytes + timestamp 8 bytes + remote
 	// and local net addresses + nonce 8 bytes + length of user agent (varInt) +
-	// max allowed useragent length + last block 4 bytes.
-	return 32 + (maxNetAddressPayload(pver) * 2) + maxVarIntPayload + MaxUserAgentLen
+	// max allowed useragent length + last block 4 bytes + relay transactions
+	// flag 1 byte for protocol versions which support it.
+	plen := 32 + (maxNetAddressPayload(pver) * 2) + maxVarIntPayload +
+		MaxUserAgentLen
+	if FeatureBloomFilter.IsSupported(pver) {
+		plen++
+	}
+	return plen
+}
+
+// SerializeSize returns the number of bytes it would take to encode the version
+// message using the provided protocol version.  This is part of the Message
+// interface implementation.
+func (msg *MsgVersion) SerializeSize(pver uint32) int {
+	// Protocol version 4 bytes + services 8 bytes + timestamp 8 bytes +
+	// remote and local net addresses + nonce 8 bytes + serialized user
+	// agent + last block 4 bytes.
+	n := 32 + int(NetAddressPayloadSize(pver, false))*2 +
+		varIntSerializeSize(uint64(len(msg.UserAgent))) +
+		len(msg.UserAgent)
+
+	// Relay transactions flag 1 byte.
+	if FeatureBloomFilter.IsSupported(pver) {
+		n++
+	}
+	return n
+}
+
+// Validate returns an error when the version message would be rejected by
+// BtcEncode regardless of the protocol version.  This is part of the Message
+// interface implementation.
+func (msg *MsgVersion) Validate() error {
+	if len(msg.UserAgent) > MaxUserAgentLen {
+		str := fmt.Sprintf("user agent too long [len %v, max %v]",
+			len(msg.UserAgent), MaxUserAgentLen)
+		return messageError("MsgVersion.Validate", str)
+	}
+	return nil
+}
+
+// CheckSanity returns an error when the version message is not internally
+// consistent, which is likely to get it rejected by the remote peer even
+// though BtcEncode accepts it.  That is the case when the message fails
+// Validate, the protocol version is not positive, the last block is negative,
+// or the services of the message and of AddrMe are both set but differ.
+// Either set of services may be left zero, as many implementations do not fill
+// in the services of the local address.
+func (msg *MsgVersion) CheckSanity() error {
+	err := msg.Validate()
+	if err != nil {
+		return err
+	}
+	if msg.ProtocolVersion <= 0 {
+		str := fmt.Sprintf("invalid protocol version %v",
+			msg.ProtocolVersion)
+		return messageError("MsgVersion.CheckSanity", str)
+	}
+	if msg.LastBlock < 0 {
+		str := fmt.Sprintf("invalid last block height %v", msg.LastBlock)
+		return messageError("MsgVersion.CheckSanity", str)
+	}
+	if msg.Services != 0 && msg.AddrMe.Services != 0 &&
+		msg.Services != msg.AddrMe.Services {
+		str := fmt.Sprintf("services %v do not match the services of "+
+			"the local address %v", msg.Services, msg.AddrMe.Services)
+		return messageError("MsgVersion.CheckSanity", str)
+	}
+	return nil
+}
+
+// Equal returns whether the message has the same contents as other.  Two nil
+// messages are considered equal, while a nil message is not equal to any
+// other.
+func (msg *MsgVersion) Equal(other *MsgVersion) bool {
+	if msg == nil || other == nil {
+		return msg == other
+	}
+	return msg.ProtocolVersion == other.ProtocolVersion &&
+		msg.Services == other.Services &&
+		msg.Timestamp.Equal(other.Timestamp) &&
+		msg.AddrYou.Equal(&other.AddrYou) &&
+		msg.AddrMe.Equal(&other.AddrMe) && msg.Nonce == other.Nonce &&
+		msg.UserAgent == other.UserAgent &&
+		msg.LastBlock == other.LastBlock &&
+		msg.DisableRelayTx == other.DisableRelayTx
+}
+
+// GoString returns a Go-syntax representation of the message.  This is part
+// of the fmt.GoStringer interface implementation.
+func (msg *MsgVersion) GoString() string {
+	return goString(msg)
 }
 
 // NewMsgVersion returns a new bitcoin version message that conforms to the
 // Message interface using the passed parameters and defaults for the remaining
-// fields.
+// fields.  The timestamp is the current time as reported by the Clock set via
+// SetClock.  An error is returned when either address is nil or the user agent
+// is longer than MaxUserAgentLen.
 func NewMsgVersion(me *NetAddress, you *NetAddress, nonce uint64,
-	userAgent string, lastBlock int32) *MsgVersion {
+	userAgent string, lastBlock int32) (*MsgVersion, error) {
+
+	if me == nil || you == nil {
+		return nil, messageError("NewMsgVersion", "nil address")
+	}
+	if len(userAgent) > MaxUserAgentLen {
+		str := fmt.Sprintf("user agent too long [len %v, max %v]",
+			len(userAgent), MaxUserAgentLen)
+		return nil, messageError("NewMsgVersion", str)
+	}
 
 	// Limit the Timestamp to millisecond precision since the protocol
 	// doesn't support better.
 	return &MsgVersion{
 		ProtocolVersion: int32(ProtocolVersion),
 		Services:        0,
-		Timestamp:       time.Unix(time.Now().Unix(), 0),
+		Timestamp:       time.Unix(now().Unix(), 0),
 		AddrYou:         *you,
 		AddrMe:          *me,
 		Nonce:           nonce,
 		UserAgent:       userAgent,
 		LastBlock:       lastBlock,
-	}
+	}, nil
 }
 
 // NewMsgVersionFromConn is a convenience function that extracts the remote
@@ -208,5 +353,73 @@ func NewMsgVersionFromConn(conn net.Conn, nonce uint64, userAgent string,
 		return nil, err
 	}
 
-	return NewMsgVersion(lna, rna, nonce, userAgent, lastBlock), nil
+	return NewMsgVersion(lna, rna, nonce, userAgent, lastBlock)
+}
+
+// VersionOption configures a version message created by
+// NewMsgVersionWithOptions.
+type VersionOption func(msg *MsgVersion)
+
+// WithUserAgent returns an option which sets the user agent of a version
+// message.  It must not be longer than MaxUserAgentLen.
+func WithUserAgent(userAgent string) VersionOption {
+	return func(msg *MsgVersion) {
+		msg.UserAgent = userAgent
+	}
+}
+
+// WithServices returns an option which sets the services advertised by a
+// version message.
+func WithServices(services ServiceFlag) VersionOption {
+	return func(msg *MsgVersion) {
+		msg.Services = services
+	}
+}
+
+// WithRelay returns an option which sets whether the remote peer should
+// announce transactions before a filter is loaded.  Transactions are relayed
+// by default.
+func WithRelay(relay bool) VersionOption {
+	return func(msg *MsgVersion) {
+		msg.DisableRelayTx = !relay
+	}
+}
+
+// WithStartHeight returns an option which sets the height of the last block
+// seen by the generator of a version message.
+func WithStartHeight(height int32) VersionOption {
+	return func(msg *MsgVersion) {
+		msg.LastBlock = height
+	}
+}
+
+// NewMsgVersionWithOptions returns a new bitcoin version message that conforms
+// to the Message interface using the passed addresses and nonce along with the
+// provided options.  Fields which aren't set by an option have the same
+// defaults as NewMsgVersion.  For example:
+//
+//	msg, err := btcwire.NewMsgVersionWithOptions(me, you, nonce,
+//		btcwire.WithUserAgent("/btcd:0.1.0/"),
+//		btcwire.WithServices(btcwire.SFNodeNetwork),
+//		btcwire.WithStartHeight(lastBlock))
+//
+// An error is returned for the same reasons as NewMsgVersion.
+func NewMsgVersionWithOptions(me *NetAddress, you *NetAddress, nonce uint64,
+	opts ...VersionOption) (*MsgVersion, error) {
+
+	msg, err := NewMsgVersion(me, you, nonce, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		opt(msg)
+	}
+
+	if len(msg.UserAgent) > MaxUserAgentLen {
+		str := fmt.Sprintf("user agent too long [len %v, max %v]",
+			len(msg.UserAgent), MaxUserAgentLen)
+		return nil, messageError("NewMsgVersionWithOptions", str)
+	}
+
+	return msg, nil
 }
@@ -39,7 +39,10 @@ func TestVersion(t *testing.T) {
 	}
 
 	// Ensure we get the correct data back out.
-	msg := btcwire.NewMsgVersion(me, you, nonce, userAgent, lastBlock)
+	msg, err := btcwire.NewMsgVersion(me, you, nonce, userAgent, lastBlock)
+	if err != nil {
+		t.Fatalf("NewMsgVersion: %v", err)
+	}
 	if msg.ProtocolVersion != int32(pver) {
 		t.Errorf("NewMsgVersion: wrong protocol version - got %v, want %v",
 			msg.ProtocolVersion, pver)
@@ -75,6 +78,20 @@ func TestVersion(t *testing.T) {
 		t.Errorf("HasService: SFNodeNetwork service is set")
 	}
 
+	// Ensure an overly long user agent and missing addresses are
+	// rejected.
+	_, err = btcwire.NewMsgVersion(me, you, nonce,
+		strings.Repeat("a", btcwire.MaxUserAgentLen+1), lastBlock)
+	if _, ok := err.(*btcwire.MessageError); !ok {
+		t.Errorf("NewMsgVersion: wrong error for long user agent - "+
+			"got %T(%v)", err, err)
+	}
+	_, err = btcwire.NewMsgVersion(nil, you, nonce, userAgent, lastBlock)
+	if _, ok := err.(*btcwire.MessageError); !ok {
+		t.Errorf("NewMsgVersion: wrong error for nil address - "+
+			"got %T(%v)", err, err)
+	}
+
 	// Ensure the command is expected value.
 	wantCmd := "version"
 	if cmd := msg.Command(); cmd != wantCmd {
@@ -85,8 +102,9 @@ func TestVersion(t *testing.T) {
 	// Ensure max payload is expected value.
 	// Protocol version 4 bytes + services 8 bytes + timestamp 8 bytes +
 	// remote and local net addresses + nonce 8 bytes + length of user agent
-	// (varInt) + max allowed user agent length + last block 4 bytes.
-	wantPayload := uint32(2101)
+	// (varInt) + max allowed user agent length + last block 4 bytes +
+	// relay transactions flag 1 byte.
+	wantPayload := uint32(2102)
 	maxPayload := msg.MaxPayloadLength(pver)
 	if maxPayload != wantPayload {
 		t.Errorf("MaxPayloadLength: wrong max payload length for "+
@@ -146,6 +164,57 @@ func TestVersion(t *testing.T) {
 	return
 }
 
+// TestVersionWithOptions tests creating a version message via
+// NewMsgVersionWithOptions.
+func TestVersionWithOptions(t *testing.T) {
+	me := &baseVersion.AddrMe
+	you := &baseVersion.AddrYou
+	nonce := baseVersion.Nonce
+
+	// Ensure the defaults match NewMsgVersion.
+	msg, err := btcwire.NewMsgVersionWithOptions(me, you, nonce)
+	if err != nil {
+		t.Fatalf("NewMsgVersionWithOptions: %v", err)
+	}
+	want, err := btcwire.NewMsgVersion(me, you, nonce, "", 0)
+	if err != nil {
+		t.Fatalf("NewMsgVersion: %v", err)
+	}
+	want.Timestamp = msg.Timestamp
+	if !reflect.DeepEqual(msg, want) {
+		t.Errorf("NewMsgVersionWithOptions: wrong defaults\n got: %s "+
+			"want: %s", spew.Sdump(msg), spew.Sdump(want))
+	}
+
+	// Ensure each option sets its field.
+	msg, err = btcwire.NewMsgVersionWithOptions(me, you, nonce,
+		btcwire.WithUserAgent(baseVersion.UserAgent),
+		btcwire.WithServices(btcwire.SFNodeNetwork),
+		btcwire.WithRelay(false),
+		btcwire.WithStartHeight(baseVersion.LastBlock))
+	if err != nil {
+		t.Fatalf("NewMsgVersionWithOptions: %v", err)
+	}
+	want.UserAgent = baseVersion.UserAgent
+	want.Services = btcwire.SFNodeNetwork
+	want.DisableRelayTx = true
+	want.LastBlock = baseVersion.LastBlock
+	want.Timestamp = msg.Timestamp
+	if !reflect.DeepEqual(msg, want) {
+		t.Errorf("NewMsgVersionWithOptions: wrong options\n got: %s "+
+			"want: %s", spew.Sdump(msg), spew.Sdump(want))
+	}
+
+	// Ensure invalid options are rejected.
+	_, err = btcwire.NewMsgVersionWithOptions(me, you, nonce,
+		btcwire.WithUserAgent(strings.Repeat("a",
+			btcwire.MaxUserAgentLen+1)))
+	if _, ok := err.(*btcwire.MessageError); !ok {
+		t.Errorf("NewMsgVersionWithOptions: wrong error for long user "+
+			"agent - got %T(%v)", err, err)
+	}
+}
+
 // TestAlertWire tests the MsgAlert wire encode and decode for various protocol
 // versions.
 func TestVersionWire(t *testing.T) {
@@ -159,10 +228,18 @@ func TestVersionWire(t *testing.T) {
 		{
 			baseVersion,
 			baseVersion,
-			baseVersionEncoded,
+			baseVersionBIP0037Encoded,
 			btcwire.ProtocolVersion,
 		},
 
+		// Protocol version BIP0037Version with relay disabled.
+		{
+			baseVersionNoRelay,
+			baseVersionNoRelay,
+			baseVersionNoRelayEncoded,
+			btcwire.BIP0037Version,
+		},
+
 		// Protocol version BIP0035Version.
 		{
 			baseVersion,
@@ -225,6 +302,19 @@ func TestVersionWire(t *testing.T) {
 			continue
 		}
 	}
+
+	// The relay flag is optional, so a version message without it must
+	// decode with relay enabled.
+	var msg btcwire.MsgVersion
+	err := msg.BtcDecode(bytes.NewBuffer(baseVersionEncoded),
+		btcwire.BIP0037Version)
+	if err != nil {
+		t.Fatalf("BtcDecode without relay flag error %v", err)
+	}
+	if !reflect.DeepEqual(&msg, baseVersion) {
+		t.Errorf("BtcDecode without relay flag\n got: %s want: %s",
+			spew.Sdump(msg), spew.Sdump(baseVersion))
+	}
 }
 
 // TestVersionWireErrors performs negative tests against wire encode and
@@ -333,6 +423,63 @@ func TestVersionWireErrors(t *testing.T) {
 	}
 }
 
+// TestVersionCheckSanity ensures CheckSanity rejects version messages which
+// are not internally consistent while Validate still accepts them since
+// BtcEncode does.
+func TestVersionCheckSanity(t *testing.T) {
+	longUA := *baseVersion
+	longUA.UserAgent = strings.Repeat("a", btcwire.MaxUserAgentLen+1)
+
+	badPver := *baseVersion
+	badPver.ProtocolVersion = -1
+
+	badLastBlock := *baseVersion
+	badLastBlock.LastBlock = -1
+
+	badServices := *baseVersion
+	badServices.AddrMe.Services = btcwire.SFNodeNetwork |
+		btcwire.SFNodeCompression
+
+	noMeServices := *baseVersion
+	noMeServices.AddrMe.Services = 0
+
+	tests := []struct {
+		name  string              // Description of the test
+		msg   *btcwire.MsgVersion // Message to check
+		valid bool                // Whether Validate accepts the message
+		sane  bool                // Whether CheckSanity accepts the message
+	}{
+		{"base version", baseVersion, true, true},
+		{"no local address services", &noMeServices, true, true},
+		{"long user agent", &longUA, false, false},
+		{"negative protocol version", &badPver, true, false},
+		{"negative last block", &badLastBlock, true, false},
+		{"mismatched services", &badServices, true, false},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		err := test.msg.Validate()
+		if (err == nil) != test.valid {
+			t.Errorf("Validate #%d (%s) unexpected result got: %v", i,
+				test.name, err)
+		}
+
+		err = test.msg.CheckSanity()
+		if test.sane {
+			if err != nil {
+				t.Errorf("CheckSanity #%d (%s) unexpected error %v",
+					i, test.name, err)
+			}
+			continue
+		}
+		if _, ok := err.(*btcwire.MessageError); !ok {
+			t.Errorf("CheckSanity #%d (%s) wrong error got: %T(%v)",
+				i, test.name, err, err)
+		}
+	}
+}
+
 // baseVersion is used in the various tests as a baseline MsgVersion.
 var baseVersion = &btcwire.MsgVersion{
 	ProtocolVersion: 60002,
@@ -355,6 +502,13 @@ var baseVersion = &btcwire.MsgVersion{
 	LastBlock: 234234, // 0x392fa
 }
 
+// baseVersionNoRelay is baseVersion with transaction relay disabled.
+var baseVersionNoRelay = func() *btcwire.MsgVersion {
+	msg := *baseVersion
+	msg.DisableRelayTx = true
+	return &msg
+}()
+
 // baseVersionEncoded is the wire encoded bytes for baseVersion using protocol
 // version 60002 and is used in the various tests.
 var baseVersionEncoded = []byte{
@@ -377,3 +531,16 @@ var baseVersionEncoded = []byte{
 	0x74, 0x3a, 0x30, 0x2e, 0x30, 0x2e, 0x31, 0x2f, // User agent
 	0xfa, 0x92, 0x03, 0x00, // Last block
 }
+
+// baseVersionBIP0037Encoded is the wire encoded bytes for baseVersion using
+// protocol version BIP0037Version, which adds the relay transactions flag, and
+// is used in the various tests.
+var baseVersionBIP0037Encoded = append(append([]byte{}, baseVersionEncoded...),
+	0x01, // Relay transactions
+)
+
+// baseVersionNoRelayEncoded is the wire encoded bytes for baseVersionNoRelay
+// using protocol version BIP0037Version.
+var baseVersionNoRelayEncoded = append(append([]byte{}, baseVersionEncoded...),
+	0x00, // Don't relay transactions
+)
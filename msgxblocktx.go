@@ -0,0 +1,104 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"io"
+)
+
+// MsgXBlockTx implements the Message interface and represents an xblocktx
+// message of Xtreme Thinblocks (BUIP010).  It is sent in response to a
+// get_xblocktx message (MsgGetXBlockTx) with the requested transactions of the
+// block which the requesting peer was unable to reconstruct from an
+// xthinblock message.
+//
+// This message is not part of the bitcoin protocol and is only recognized once
+// RegisterXThinMessages has been called.
+type MsgXBlockTx struct {
+	// BlockHash identifies the block the transactions belong to.
+	BlockHash ShaHash
+
+	// Txs are the requested transactions.
+	Txs []*MsgTx
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgXBlockTx) BtcDecode(r io.Reader, pver uint32) error {
+	_, err := io.ReadFull(r, msg.BlockHash[:])
+	if err != nil {
+		return err
+	}
+	msg.Txs, err = readTxList(r, pver, "MsgXBlockTx.BtcDecode")
+	return err
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgXBlockTx) BtcEncode(w io.Writer, pver uint32) error {
+	err := validateTxList("MsgXBlockTx.BtcEncode", msg.Txs)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(msg.BlockHash[:])
+	if err != nil {
+		return err
+	}
+	return writeTxList(w, pver, "MsgXBlockTx.BtcEncode", msg.Txs)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgXBlockTx) Command() string {
+	return CmdXBlockTx
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgXBlockTx) MaxPayloadLength(pver uint32) uint32 {
+	// Block hash + num transactions (varInt) + transactions up to the max
+	// block payload.
+	return HashSize + maxVarIntPayload + MaxBlockPayload
+}
+
+// SerializeSize returns the number of bytes it would take to encode the
+// xblocktx message using the provided protocol version.  This is part of the
+// Message interface implementation.
+func (msg *MsgXBlockTx) SerializeSize(pver uint32) int {
+	return HashSize + txListSerializeSize(pver, msg.Txs)
+}
+
+// Validate returns an error when the xblocktx message would be rejected by
+// BtcEncode regardless of the protocol version.  This is part of the Message
+// interface implementation.
+func (msg *MsgXBlockTx) Validate() error {
+	return validateTxList("MsgXBlockTx.Validate", msg.Txs)
+}
+
+// Equal returns whether the message contains the same transactions of the
+// same block as other.  Two nil messages are considered equal, while a nil
+// message is not equal to any other.
+func (msg *MsgXBlockTx) Equal(other *MsgXBlockTx) bool {
+	if msg == nil || other == nil {
+		return msg == other
+	}
+	return msg.BlockHash == other.BlockHash &&
+		txListEqual(msg.Txs, other.Txs)
+}
+
+// GoString returns a Go-syntax representation of the message.  This is part
+// of the fmt.GoStringer interface implementation.
+func (msg *MsgXBlockTx) GoString() string {
+	return goString(msg)
+}
+
+// NewMsgXBlockTx returns a new xblocktx message that conforms to the Message
+// interface for the block with the provided hash.  See MsgXBlockTx for
+// details.
+func NewMsgXBlockTx(blockHash *ShaHash) *MsgXBlockTx {
+	return &MsgXBlockTx{
+		BlockHash: *blockHash,
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// TestXBlockTx tests the MsgXBlockTx API.
+func TestXBlockTx(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+
+	// Ensure the command is expected value.
+	wantCmd := "xblocktx"
+	msg := btcwire.NewMsgXBlockTx(&btcwire.GenesisHash)
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgXBlockTx: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	// Block hash + num transactions (varInt) + max block payload.
+	wantPayload := uint32(1000041)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+}
+
+// TestXBlockTxWire tests the MsgXBlockTx wire encode and decode.
+func TestXBlockTxWire(t *testing.T) {
+	// Message with no transactions.
+	noTxs := btcwire.NewMsgXBlockTx(&btcwire.GenesisHash)
+	noTxs.Txs = []*btcwire.MsgTx{}
+	noTxsEncoded := append([]byte{}, btcwire.GenesisHash[:]...)
+	noTxsEncoded = append(noTxsEncoded, 0x00) // Varint for number of transactions
+
+	// Message with multiple transactions.
+	multiTxs := btcwire.NewMsgXBlockTx(&btcwire.GenesisHash)
+	multiTxs.Txs = []*btcwire.MsgTx{multiTx, multiTx}
+	multiTxsEncoded := append([]byte{}, btcwire.GenesisHash[:]...)
+	multiTxsEncoded = append(multiTxsEncoded, 0x02) // Varint for number of transactions
+	multiTxsEncoded = append(multiTxsEncoded, multiTxEncoded...)
+	multiTxsEncoded = append(multiTxsEncoded, multiTxEncoded...)
+
+	tests := []struct {
+		in   *btcwire.MsgXBlockTx // Message to encode
+		out  *btcwire.MsgXBlockTx // Expected decoded message
+		buf  []byte               // Wire encoding
+		pver uint32               // Protocol version for wire encoding
+	}{
+		// Latest protocol version with no transactions.
+		{noTxs, noTxs, noTxsEncoded, btcwire.ProtocolVersion},
+
+		// Latest protocol version with multiple transactions.
+		{multiTxs, multiTxs, multiTxsEncoded, btcwire.ProtocolVersion},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+		if size := test.in.SerializeSize(test.pver); size != len(test.buf) {
+			t.Errorf("SerializeSize #%d got: %d want: %d", i, size,
+				len(test.buf))
+		}
+
+		// Decode the message from wire format.
+		var msg btcwire.MsgXBlockTx
+		rbuf := bytes.NewBuffer(test.buf)
+		err = msg.BtcDecode(rbuf, test.pver)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+}
+
+// TestXBlockTxWireErrors performs negative tests against wire encode and
+// decode of MsgXBlockTx to confirm error paths work correctly.
+func TestXBlockTxWireErrors(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcwireErr := &btcwire.MessageError{}
+
+	baseXBlockTx := btcwire.NewMsgXBlockTx(&btcwire.GenesisHash)
+	baseXBlockTx.Txs = []*btcwire.MsgTx{multiTx}
+	baseXBlockTxEncoded := append([]byte{}, btcwire.GenesisHash[:]...)
+	baseXBlockTxEncoded = append(baseXBlockTxEncoded, 0x01) // Varint for number of transactions
+	baseXBlockTxEncoded = append(baseXBlockTxEncoded, multiTxEncoded...)
+
+	// Message that forces an error by having more than the max allowed
+	// transactions.
+	maxTxs := btcwire.NewMsgXBlockTx(&btcwire.GenesisHash)
+	maxTxs.Txs = make([]*btcwire.MsgTx, 1000000/10+2)
+	maxTxsEncoded := append([]byte{}, btcwire.GenesisHash[:]...)
+	maxTxsEncoded = append(maxTxsEncoded,
+		0xfe, 0xa2, 0x86, 0x01, 0x00, // Varint for number of transactions (100002)
+	)
+
+	tests := []struct {
+		in       *btcwire.MsgXBlockTx // Value to encode
+		buf      []byte               // Wire encoding
+		pver     uint32               // Protocol version for wire encoding
+		max      int                  // Max size of fixed buffer to induce errors
+		writeErr error                // Expected write error
+		readErr  error                // Expected read error
+	}{
+		// Force error in block hash.
+		{baseXBlockTx, baseXBlockTxEncoded, pver, 0, io.ErrShortWrite, io.EOF},
+		// Force error in transaction count.
+		{baseXBlockTx, baseXBlockTxEncoded, pver, 32, io.ErrShortWrite, io.EOF},
+		// Force error in transactions.
+		{baseXBlockTx, baseXBlockTxEncoded, pver, 33, io.ErrShortWrite, io.EOF},
+		// Force error with greater than max transactions.
+		{maxTxs, maxTxsEncoded, pver, 37, btcwireErr, btcwireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		w := newFixedWriter(test.max)
+		err := test.in.BtcEncode(w, test.pver)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+			t.Errorf("BtcEncode #%d wrong error got: %v, want: %v",
+				i, err, test.writeErr)
+			continue
+		}
+
+		// For errors which are not of type btcwire.MessageError, check
+		// them for equality.
+		if _, ok := err.(*btcwire.MessageError); !ok {
+			if err != test.writeErr {
+				t.Errorf("BtcEncode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.writeErr)
+				continue
+			}
+		}
+
+		// Decode from wire format.
+		var msg btcwire.MsgXBlockTx
+		r := newFixedReader(test.max, test.buf)
+		err = msg.BtcDecode(r, test.pver)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+
+		// For errors which are not of type btcwire.MessageError, check
+		// them for equality.
+		if _, ok := err.(*btcwire.MessageError); !ok {
+			if err != test.readErr {
+				t.Errorf("BtcDecode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.readErr)
+				continue
+			}
+		}
+	}
+}
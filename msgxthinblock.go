@@ -0,0 +1,132 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"io"
+)
+
+// MsgXThinBlock implements the Message interface and represents an xthinblock
+// message of Xtreme Thinblocks (BUIP010).  It is sent in response to a
+// get_xthin message (MsgGetXThin) and describes a block by its header, the
+// cheap hashes of all of its transactions in order, and the full transactions
+// which did not match the bloom filter of the request.  See CheapHash.
+//
+// This message is not part of the bitcoin protocol and is only recognized once
+// RegisterXThinMessages has been called.
+type MsgXThinBlock struct {
+	// Header is the header of the block.  Its transaction count is not
+	// encoded.
+	Header BlockHeader
+
+	// TxHashes are the cheap hashes of the transactions of the block.
+	TxHashes []uint64
+
+	// MissingTxs are the transactions of the block which the requesting
+	// peer is not expected to have.
+	MissingTxs []*MsgTx
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgXThinBlock) BtcDecode(r io.Reader, pver uint32) error {
+	err := readBlockHeaderFields(r, &msg.Header)
+	if err != nil {
+		return err
+	}
+	msg.TxHashes, err = readCheapHashes(r, pver, "MsgXThinBlock.BtcDecode")
+	if err != nil {
+		return err
+	}
+	msg.MissingTxs, err = readTxList(r, pver, "MsgXThinBlock.BtcDecode")
+	return err
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgXThinBlock) BtcEncode(w io.Writer, pver uint32) error {
+	err := msg.validate("MsgXThinBlock.BtcEncode")
+	if err != nil {
+		return err
+	}
+
+	err = writeBlockHeaderFields(w, &msg.Header)
+	if err != nil {
+		return err
+	}
+	err = writeCheapHashes(w, pver, "MsgXThinBlock.BtcEncode", msg.TxHashes)
+	if err != nil {
+		return err
+	}
+	return writeTxList(w, pver, "MsgXThinBlock.BtcEncode", msg.MissingTxs)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgXThinBlock) Command() string {
+	return CmdXThinBlock
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgXThinBlock) MaxPayloadLength(pver uint32) uint32 {
+	// Block header at 80 bytes + num cheap hashes (varInt) + max cheap
+	// hashes at 8 bytes each + missing transactions up to the max block
+	// payload.
+	return blockHashLen + maxVarIntPayload + maxCheapHashesPerMsg*8 +
+		MaxBlockPayload
+}
+
+// SerializeSize returns the number of bytes it would take to encode the
+// xthinblock message using the provided protocol version.  This is part of
+// the Message interface implementation.
+func (msg *MsgXThinBlock) SerializeSize(pver uint32) int {
+	return blockHashLen + varIntSerializeSize(uint64(len(msg.TxHashes))) +
+		len(msg.TxHashes)*8 + txListSerializeSize(pver, msg.MissingTxs)
+}
+
+// Validate returns an error when the xthinblock message would be rejected by
+// BtcEncode regardless of the protocol version.  This is part of the Message
+// interface implementation.
+func (msg *MsgXThinBlock) Validate() error {
+	return msg.validate("MsgXThinBlock.Validate")
+}
+
+// validate returns an error attributed to the function f when the message
+// carries too many transaction hashes or transactions.
+func (msg *MsgXThinBlock) validate(f string) error {
+	err := validateCheapHashes(f, msg.TxHashes)
+	if err != nil {
+		return err
+	}
+	return validateTxList(f, msg.MissingTxs)
+}
+
+// Equal returns whether the message has the same header, transaction hashes,
+// and missing transactions as other.  Two nil messages are considered equal,
+// while a nil message is not equal to any other.
+func (msg *MsgXThinBlock) Equal(other *MsgXThinBlock) bool {
+	if msg == nil || other == nil {
+		return msg == other
+	}
+	return msg.Header.Equal(&other.Header) &&
+		cheapHashesEqual(msg.TxHashes, other.TxHashes) &&
+		txListEqual(msg.MissingTxs, other.MissingTxs)
+}
+
+// GoString returns a Go-syntax representation of the message.  This is part
+// of the fmt.GoStringer interface implementation.
+func (msg *MsgXThinBlock) GoString() string {
+	return goString(msg)
+}
+
+// NewMsgXThinBlock returns a new xthinblock message that conforms to the
+// Message interface for the block with the provided header.  See
+// MsgXThinBlock for details.
+func NewMsgXThinBlock(header *BlockHeader) *MsgXThinBlock {
+	return &MsgXThinBlock{
+		Header: *header,
+	}
+}
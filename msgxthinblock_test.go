@@ -0,0 +1,208 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// TestXThinBlock tests the MsgXThinBlock API.
+func TestXThinBlock(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+
+	// Ensure the command is expected value.
+	wantCmd := "xthinblock"
+	msg := btcwire.NewMsgXThinBlock(&blockOne.Header)
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgXThinBlock: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	// Header + num cheap hashes (varInt) + max cheap hashes + max block
+	// payload.
+	wantPayload := uint32(1800097)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure too many transaction hashes are rejected.
+	if err := msg.Validate(); err != nil {
+		t.Errorf("Validate: unexpected error %v", err)
+	}
+	msg.TxHashes = make([]uint64, 1000000/10+2)
+	if err := msg.Validate(); err == nil {
+		t.Errorf("Validate: expected error on too many transaction " +
+			"hashes not received")
+	}
+}
+
+// TestXThinBlockWire tests the MsgXThinBlock wire encode and decode.
+func TestXThinBlockWire(t *testing.T) {
+	// Thin block one with its coinbase missing from the receiver.
+	txHash, _ := blockOne.Transactions[0].TxSha()
+	thinBlockOne := btcwire.NewMsgXThinBlock(&blockOne.Header)
+	thinBlockOne.TxHashes = []uint64{btcwire.CheapHash(&txHash)}
+	thinBlockOne.MissingTxs = []*btcwire.MsgTx{blockOne.Transactions[0]}
+	thinBlockOne.Header.TxnCount = 0
+	thinBlockOneEncoded := append([]byte{}, blockOneBytes[:80]...)
+	thinBlockOneEncoded = append(thinBlockOneEncoded, 0x01) // Varint for number of tx hashes
+	thinBlockOneEncoded = append(thinBlockOneEncoded, txHash[:8]...)
+	thinBlockOneEncoded = append(thinBlockOneEncoded, blockOneBytes[80:]...)
+
+	// Thin block one with no missing transactions.
+	noMissing := btcwire.NewMsgXThinBlock(&thinBlockOne.Header)
+	noMissing.TxHashes = thinBlockOne.TxHashes
+	noMissing.MissingTxs = []*btcwire.MsgTx{}
+	noMissingEncoded := append([]byte{}, thinBlockOneEncoded[:89]...)
+	noMissingEncoded = append(noMissingEncoded, 0x00) // Varint for number of transactions
+
+	tests := []struct {
+		in   *btcwire.MsgXThinBlock // Message to encode
+		out  *btcwire.MsgXThinBlock // Expected decoded message
+		buf  []byte                 // Wire encoding
+		pver uint32                 // Protocol version for wire encoding
+	}{
+		// Latest protocol version with a missing transaction.
+		{thinBlockOne, thinBlockOne, thinBlockOneEncoded,
+			btcwire.ProtocolVersion},
+
+		// Latest protocol version with no missing transactions.
+		{noMissing, noMissing, noMissingEncoded, btcwire.ProtocolVersion},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+		if size := test.in.SerializeSize(test.pver); size != len(test.buf) {
+			t.Errorf("SerializeSize #%d got: %d want: %d", i, size,
+				len(test.buf))
+		}
+
+		// Decode the message from wire format.
+		var msg btcwire.MsgXThinBlock
+		rbuf := bytes.NewBuffer(test.buf)
+		err = msg.BtcDecode(rbuf, test.pver)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+}
+
+// TestXThinBlockWireErrors performs negative tests against wire encode and
+// decode of MsgXThinBlock to confirm error paths work correctly.
+func TestXThinBlockWireErrors(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcwireErr := &btcwire.MessageError{}
+
+	baseThinBlock := btcwire.NewMsgXThinBlock(&blockOne.Header)
+	baseThinBlock.TxHashes = []uint64{0x0102030405060708}
+	baseThinBlock.MissingTxs = []*btcwire.MsgTx{multiTx}
+	baseThinBlockEncoded := append([]byte{}, blockOneBytes[:80]...)
+	baseThinBlockEncoded = append(baseThinBlockEncoded,
+		0x01,                                           // Varint for number of tx hashes
+		0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01, // Tx hash
+		0x01, // Varint for number of transactions
+	)
+	baseThinBlockEncoded = append(baseThinBlockEncoded, multiTxEncoded...)
+
+	// Message that forces an error by having more than the max allowed
+	// transaction hashes.
+	maxHashes := btcwire.NewMsgXThinBlock(&blockOne.Header)
+	maxHashes.TxHashes = make([]uint64, 1000000/10+2)
+	maxHashesEncoded := append([]byte{}, blockOneBytes[:80]...)
+	maxHashesEncoded = append(maxHashesEncoded,
+		0xfe, 0xa2, 0x86, 0x01, 0x00, // Varint for number of tx hashes (100002)
+	)
+
+	tests := []struct {
+		in       *btcwire.MsgXThinBlock // Value to encode
+		buf      []byte                 // Wire encoding
+		pver     uint32                 // Protocol version for wire encoding
+		max      int                    // Max size of fixed buffer to induce errors
+		writeErr error                  // Expected write error
+		readErr  error                  // Expected read error
+	}{
+		// Force error in header.
+		{baseThinBlock, baseThinBlockEncoded, pver, 0, io.ErrShortWrite, io.EOF},
+		// Force error in tx hash count.
+		{baseThinBlock, baseThinBlockEncoded, pver, 80, io.ErrShortWrite, io.EOF},
+		// Force error in tx hashes.
+		{baseThinBlock, baseThinBlockEncoded, pver, 81, io.ErrShortWrite, io.EOF},
+		// Force error in transaction count.
+		{baseThinBlock, baseThinBlockEncoded, pver, 89, io.ErrShortWrite, io.EOF},
+		// Force error in transactions.
+		{baseThinBlock, baseThinBlockEncoded, pver, 90, io.ErrShortWrite, io.EOF},
+		// Force error with greater than max tx hashes.
+		{maxHashes, maxHashesEncoded, pver, 85, btcwireErr, btcwireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		w := newFixedWriter(test.max)
+		err := test.in.BtcEncode(w, test.pver)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+			t.Errorf("BtcEncode #%d wrong error got: %v, want: %v",
+				i, err, test.writeErr)
+			continue
+		}
+
+		// For errors which are not of type btcwire.MessageError, check
+		// them for equality.
+		if _, ok := err.(*btcwire.MessageError); !ok {
+			if err != test.writeErr {
+				t.Errorf("BtcEncode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.writeErr)
+				continue
+			}
+		}
+
+		// Decode from wire format.
+		var msg btcwire.MsgXThinBlock
+		r := newFixedReader(test.max, test.buf)
+		err = msg.BtcDecode(r, test.pver)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+
+		// For errors which are not of type btcwire.MessageError, check
+		// them for equality.
+		if _, ok := err.(*btcwire.MessageError); !ok {
+			if err != test.readErr {
+				t.Errorf("BtcDecode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.readErr)
+				continue
+			}
+		}
+	}
+}
@@ -5,7 +5,6 @@
 package btcwire
 
 import (
-	"encoding/binary"
 	"errors"
 	"io"
 	"net"
@@ -16,14 +15,18 @@ import (
 // a TCP address as required.
 var ErrInvalidNetAddr = errors.New("provided net.Addr is not a net.TCPAddr")
 
-// maxNetAddressPayload returns the max payload size for a bitcoin NetAddress
-// based on the protocol version.
-func maxNetAddressPayload(pver uint32) uint32 {
+// NetAddressPayloadSize returns the serialized size of a bitcoin NetAddress
+// for the provided protocol version.  The ts flag indicates whether or not the
+// address is encoded with its timestamp, which is the case for addresses in
+// the addr message (MsgAddr), but not for those in the version message
+// (MsgVersion).  The timestamp is only encoded for protocol versions >=
+// NetAddressTimeVersion regardless of the flag.
+func NetAddressPayloadSize(pver uint32, ts bool) uint32 {
 	// Services 8 bytes + ip 16 bytes + port 2 bytes.
 	plen := uint32(26)
 
 	// NetAddressTimeVersion added a timestamp field.
-	if pver >= NetAddressTimeVersion {
+	if ts && FeatureAddrTimestamp.IsSupported(pver) {
 		// Timestamp 4 bytes.
 		plen += 4
 	}
@@ -31,6 +34,12 @@ func maxNetAddressPayload(pver uint32) uint32 {
 	return plen
 }
 
+// maxNetAddressPayload returns the max payload size for a bitcoin NetAddress
+// based on the protocol version.
+func maxNetAddressPayload(pver uint32) uint32 {
+	return NetAddressPayloadSize(pver, true)
+}
+
 // NetAddress defines information about a peer on the network including the time
 // it was last seen, the services it supports, its IP address, and port.
 type NetAddress struct {
@@ -72,11 +81,50 @@ func (na *NetAddress) SetAddress(ip net.IP, port uint16) {
 	na.Port = port
 }
 
+// Equal returns whether the address has the same timestamp, services, IP
+// address, and port as other.  IPv4 addresses are considered the same as their
+// IPv4-mapped IPv6 form, which is how they are decoded.  Two nil addresses are
+// considered equal, while a nil address is not equal to any other.
+func (na *NetAddress) Equal(other *NetAddress) bool {
+	if na == nil || other == nil {
+		return na == other
+	}
+	return na.Timestamp.Equal(other.Timestamp) &&
+		na.Services == other.Services && na.IP.Equal(other.IP) &&
+		na.Port == other.Port
+}
+
+// Address timestamps which are more than maxAddrTimeFuture ahead of the current
+// time or from before the genesis block are not believable, so
+// SanitizeTimestamp replaces them with a time addrTimePenalty in the past as
+// the reference implementation does.  This keeps the address usable while
+// ensuring it is not preferred over addresses which are known to be recent.
+const (
+	maxAddrTimeFuture = 10 * time.Minute
+	addrTimePenalty   = 5 * 24 * time.Hour
+)
+
+// SanitizeTimestamp replaces the timestamp of the address with one five days
+// before now when it is more than ten minutes after now or before the genesis
+// block, which mirrors how the reference implementation treats the addresses
+// of incoming addr messages.  It returns whether or not the timestamp was
+// replaced.  Address managers should call this on received addresses before
+// using their timestamps.
+func (na *NetAddress) SanitizeTimestamp(now time.Time) bool {
+	if !na.Timestamp.Before(GenesisBlock.Header.Timestamp) &&
+		!na.Timestamp.After(now.Add(maxAddrTimeFuture)) {
+		return false
+	}
+	na.Timestamp = now.Add(-addrTimePenalty)
+	return true
+}
+
 // NewNetAddressIPPort returns a new NetAddress using the provided IP, port, and
-// supported services with defaults for the remaining fields.
+// supported services with defaults for the remaining fields.  The timestamp is
+// the current time as reported by the Clock set via SetClock.
 func NewNetAddressIPPort(ip net.IP, port uint16, services ServiceFlag) *NetAddress {
 	na := NetAddress{
-		Timestamp: time.Now(),
+		Timestamp: now(),
 		Services:  services,
 		IP:        ip,
 		Port:      port,
@@ -111,21 +159,25 @@ func readNetAddress(r io.Reader, pver uint32, na *NetAddress, ts bool) error {
 	// NOTE: The bitcoin protocol uses a uint32 for the timestamp so it will
 	// stop working somewhere around 2106.  Also timestamp wasn't added until
 	// protocol version >= NetAddressTimeVersion
-	if ts && pver >= NetAddressTimeVersion {
-		var stamp uint32
-		err := readElement(r, &stamp)
+	if ts && FeatureAddrTimestamp.IsSupported(pver) {
+		stamp, err := binarySerializer.Uint32(r, littleEndian)
 		if err != nil {
 			return err
 		}
 		timestamp = time.Unix(int64(stamp), 0)
 	}
 
-	err := readElements(r, &services, &ip)
+	svc, err := binarySerializer.Uint64(r, littleEndian)
+	if err != nil {
+		return err
+	}
+	services = ServiceFlag(svc)
+	_, err = io.ReadFull(r, ip[:])
 	if err != nil {
 		return err
 	}
 	// Sigh.  Bitcoin protocol mixes little and big endian.
-	err = binary.Read(r, binary.BigEndian, &port)
+	port, err = binarySerializer.Uint16(r, bigEndian)
 	if err != nil {
 		return err
 	}
@@ -143,8 +195,9 @@ func writeNetAddress(w io.Writer, pver uint32, na *NetAddress, ts bool) error {
 	// NOTE: The bitcoin protocol uses a uint32 for the timestamp so it will
 	// stop working somewhere around 2106.  Also timestamp wasn't added until
 	// until protocol version >= NetAddressTimeVersion.
-	if ts && pver >= NetAddressTimeVersion {
-		err := writeElement(w, uint32(na.Timestamp.Unix()))
+	if ts && FeatureAddrTimestamp.IsSupported(pver) {
+		err := binarySerializer.PutUint32(w, littleEndian,
+			uint32(na.Timestamp.Unix()))
 		if err != nil {
 			return err
 		}
@@ -155,13 +208,17 @@ func writeNetAddress(w io.Writer, pver uint32, na *NetAddress, ts bool) error {
 	if na.IP != nil {
 		copy(ip[:], na.IP.To16())
 	}
-	err := writeElements(w, na.Services, ip)
+	err := binarySerializer.PutUint64(w, littleEndian, uint64(na.Services))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(ip[:])
 	if err != nil {
 		return err
 	}
 
 	// Sigh.  Bitcoin protocol mixes little and big endian.
-	err = binary.Write(w, binary.BigEndian, na.Port)
+	err = binarySerializer.PutUint16(w, bigEndian, na.Port)
 	if err != nil {
 		return err
 	}
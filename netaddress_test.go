@@ -77,6 +77,28 @@ func TestNetAddress(t *testing.T) {
 			maxPayload, wantPayload)
 	}
 
+	// Ensure the exported payload size matches with and without the
+	// timestamp for protocol versions on both sides of when it was added.
+	sizeTests := []struct {
+		pver uint32 // Protocol version
+		ts   bool   // Whether the timestamp is encoded
+		want uint32 // Expected payload size
+	}{
+		{btcwire.ProtocolVersion, true, 30},
+		{btcwire.ProtocolVersion, false, 26},
+		{btcwire.NetAddressTimeVersion, true, 30},
+		{btcwire.NetAddressTimeVersion - 1, true, 26},
+		{btcwire.NetAddressTimeVersion - 1, false, 26},
+	}
+	for i, test := range sizeTests {
+		size := btcwire.NetAddressPayloadSize(test.pver, test.ts)
+		if size != test.want {
+			t.Errorf("NetAddressPayloadSize #%d: wrong size for "+
+				"protocol version %d, ts %v - got %v, want %v",
+				i, test.pver, test.ts, size, test.want)
+		}
+	}
+
 	// Check for expected failure on wrong address type.
 	udpAddr := &net.UDPAddr{}
 	_, err = btcwire.NewNetAddress(udpAddr, 0)
@@ -86,6 +108,49 @@ func TestNetAddress(t *testing.T) {
 	}
 }
 
+// TestNetAddressSanitizeTimestamp ensures implausible address timestamps are
+// replaced while plausible ones are left alone.
+func TestNetAddressSanitizeTimestamp(t *testing.T) {
+	now := time.Unix(0x5c000000, 0) // 2018-11-29 14:56:32 +0000 UTC
+	penalized := now.Add(-5 * 24 * time.Hour)
+	genesis := btcwire.GenesisBlock.Header.Timestamp
+
+	tests := []struct {
+		in       time.Time // Timestamp to sanitize
+		want     time.Time // Expected timestamp
+		replaced bool      // Expected result
+	}{
+		// Recent and slightly future timestamps are kept.
+		{now, now, false},
+		{now.Add(-time.Hour), now.Add(-time.Hour), false},
+		{now.Add(10 * time.Minute), now.Add(10 * time.Minute), false},
+		{genesis, genesis, false},
+
+		// Far future timestamps are penalized.
+		{now.Add(10*time.Minute + time.Second), penalized, true},
+		{time.Unix(0xffffffff, 0), penalized, true},
+
+		// Timestamps from before bitcoin existed are penalized.
+		{genesis.Add(-time.Second), penalized, true},
+		{time.Unix(0, 0), penalized, true},
+		{time.Time{}, penalized, true},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		na := btcwire.NetAddress{Timestamp: test.in}
+		replaced := na.SanitizeTimestamp(now)
+		if replaced != test.replaced {
+			t.Errorf("SanitizeTimestamp #%d wrong result got: %v "+
+				"want: %v", i, replaced, test.replaced)
+		}
+		if !na.Timestamp.Equal(test.want) {
+			t.Errorf("SanitizeTimestamp #%d wrong timestamp got: %v "+
+				"want: %v", i, na.Timestamp, test.want)
+		}
+	}
+}
+
 // TestNetAddressWire tests the NetAddress wire encode and decode for various
 // protocol versions and timestamp flag combinations.
 func TestNetAddressWire(t *testing.T) {
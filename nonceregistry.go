@@ -0,0 +1,104 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"sync"
+)
+
+// defaultNonceRegistrySize is the default number of nonces a NonceRegistry
+// remembers.
+const defaultNonceRegistrySize = 100
+
+// NonceRegistry remembers the nonces sent in locally generated version
+// messages (MsgVersion) so incoming version messages can be checked against
+// them.  Receiving a version message with a nonce which was sent locally means
+// the connection is to ourselves, which is how bitcoind detects
+// self-connections.  It is safe for concurrent use.
+//
+// The registry holds a limited number of nonces.  Once it is full, the oldest
+// nonce is forgotten each time a new one is added.  Nonces should be removed
+// once the version handshake for the connection which used them has
+// completed.
+type NonceRegistry struct {
+	mtx    sync.Mutex
+	max    int
+	nonces map[uint64]struct{}
+	order  []uint64
+}
+
+// NewNonceRegistry returns a new NonceRegistry which remembers up to max
+// nonces.  A max of zero or less results in a default of 100.
+func NewNonceRegistry(max int) *NonceRegistry {
+	if max <= 0 {
+		max = defaultNonceRegistrySize
+	}
+	return &NonceRegistry{
+		max:    max,
+		nonces: make(map[uint64]struct{}, max),
+		order:  make([]uint64, 0, max),
+	}
+}
+
+// Generate returns a new cryptographically random nonce which has been added
+// to the registry.
+func (nr *NonceRegistry) Generate() (uint64, error) {
+	nonce, err := RandomUint64()
+	if err != nil {
+		return 0, err
+	}
+	nr.Add(nonce)
+	return nonce, nil
+}
+
+// Add adds the provided nonce to the registry, evicting the oldest nonce when
+// the registry is full.  Adding a nonce which is already in the registry has
+// no effect.
+func (nr *NonceRegistry) Add(nonce uint64) {
+	nr.mtx.Lock()
+	defer nr.mtx.Unlock()
+
+	if _, ok := nr.nonces[nonce]; ok {
+		return
+	}
+	if len(nr.order) >= nr.max {
+		delete(nr.nonces, nr.order[0])
+		nr.order = append(nr.order[:0], nr.order[1:]...)
+	}
+	nr.nonces[nonce] = struct{}{}
+	nr.order = append(nr.order, nonce)
+}
+
+// Remove removes the provided nonce from the registry.
+func (nr *NonceRegistry) Remove(nonce uint64) {
+	nr.mtx.Lock()
+	defer nr.mtx.Unlock()
+
+	if _, ok := nr.nonces[nonce]; !ok {
+		return
+	}
+	delete(nr.nonces, nonce)
+	for i, n := range nr.order {
+		if n == nonce {
+			nr.order = append(nr.order[:i], nr.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// Contains returns whether or not the provided nonce is in the registry.
+func (nr *NonceRegistry) Contains(nonce uint64) bool {
+	nr.mtx.Lock()
+	_, ok := nr.nonces[nonce]
+	nr.mtx.Unlock()
+	return ok
+}
+
+// IsSelfConnection returns whether or not the provided version message
+// received from a remote peer carries a nonce which was sent locally, which
+// indicates the connection is to ourselves.
+func (nr *NonceRegistry) IsSelfConnection(msg *MsgVersion) bool {
+	return nr.Contains(msg.Nonce)
+}
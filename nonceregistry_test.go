@@ -0,0 +1,79 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"github.com/conformal/btcwire"
+	"testing"
+)
+
+// TestNonceRegistry tests the NonceRegistry API.
+func TestNonceRegistry(t *testing.T) {
+	nr := btcwire.NewNonceRegistry(3)
+
+	// Ensure generated nonces are remembered and detected as
+	// self-connections.
+	nonce, err := nr.Generate()
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if !nr.Contains(nonce) {
+		t.Errorf("Contains: generated nonce %d not found", nonce)
+	}
+	msg, err := btcwire.NewMsgVersion(&btcwire.NetAddress{},
+		&btcwire.NetAddress{}, nonce, "/test:0.0.1/", 0)
+	if err != nil {
+		t.Fatalf("NewMsgVersion: %v", err)
+	}
+	if !nr.IsSelfConnection(msg) {
+		t.Errorf("IsSelfConnection: version with local nonce not " +
+			"detected")
+	}
+
+	// Ensure nonces which were not sent locally are not detected.
+	msg.Nonce = nonce + 1
+	if nr.IsSelfConnection(msg) {
+		t.Errorf("IsSelfConnection: version with remote nonce " +
+			"detected")
+	}
+
+	// Ensure the oldest nonce is evicted once the registry is full.
+	nr.Add(nonce + 1)
+	nr.Add(nonce + 2)
+	nr.Add(nonce + 2)
+	if !nr.Contains(nonce) {
+		t.Errorf("Contains: nonce evicted before registry was full")
+	}
+	nr.Add(nonce + 3)
+	if nr.Contains(nonce) {
+		t.Errorf("Contains: oldest nonce not evicted")
+	}
+	for i := uint64(1); i <= 3; i++ {
+		if !nr.Contains(nonce + i) {
+			t.Errorf("Contains: nonce %d not found", nonce+i)
+		}
+	}
+
+	// Ensure removed nonces are forgotten and make room for new ones.
+	nr.Remove(nonce + 2)
+	nr.Remove(nonce + 2)
+	if nr.Contains(nonce + 2) {
+		t.Errorf("Contains: removed nonce found")
+	}
+	nr.Add(nonce + 4)
+	if !nr.Contains(nonce+1) || !nr.Contains(nonce+3) ||
+		!nr.Contains(nonce+4) {
+		t.Errorf("Add: nonce unexpectedly evicted after removal")
+	}
+
+	// Ensure the default size is used when none is specified.
+	nr = btcwire.NewNonceRegistry(0)
+	for i := uint64(0); i < 101; i++ {
+		nr.Add(i)
+	}
+	if nr.Contains(0) || !nr.Contains(1) || !nr.Contains(100) {
+		t.Errorf("NewNonceRegistry: wrong default size")
+	}
+}
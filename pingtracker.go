@@ -0,0 +1,113 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultMaxPendingPings is the default number of unanswered pings a
+// PingTracker remembers.
+const defaultMaxPendingPings = 10
+
+// PingTracker measures the round-trip time to a peer by generating the nonces
+// of ping messages (MsgPing), recording when they were sent, and matching the
+// nonces of the pong messages (MsgPong) received in response.  It is safe for
+// concurrent use.
+//
+// The tracker remembers a limited number of unanswered pings.  Once it is
+// full, the oldest ping is forgotten each time a new one is created, so a
+// late pong for it is treated the same as an unsolicited one.
+//
+// Pong messages only exist for protocol versions after BIP0031Version, so
+// round-trip times can't be measured for peers which negotiate older protocol
+// versions.
+type PingTracker struct {
+	mtx     sync.Mutex
+	max     int
+	pending map[uint64]time.Time
+	order   []uint64
+	lastRTT time.Duration
+}
+
+// NewPingTracker returns a new PingTracker which remembers up to max
+// unanswered pings.  A max of zero or less results in a default of 10.
+func NewPingTracker(max int) *PingTracker {
+	if max <= 0 {
+		max = defaultMaxPendingPings
+	}
+	return &PingTracker{
+		max:     max,
+		pending: make(map[uint64]time.Time, max),
+		order:   make([]uint64, 0, max),
+	}
+}
+
+// NewPing returns a new ping message with a cryptographically random nonce and
+// records the current time as the time it was sent.  The message should be
+// sent as soon as possible after it is created so the measured round-trip
+// times are accurate.
+func (pt *PingTracker) NewPing() (*MsgPing, error) {
+	nonce, err := RandomUint64()
+	if err != nil {
+		return nil, err
+	}
+
+	pt.mtx.Lock()
+	defer pt.mtx.Unlock()
+
+	if _, ok := pt.pending[nonce]; !ok {
+		if len(pt.order) >= pt.max {
+			delete(pt.pending, pt.order[0])
+			pt.order = append(pt.order[:0], pt.order[1:]...)
+		}
+		pt.order = append(pt.order, nonce)
+	}
+	pt.pending[nonce] = time.Now()
+	return NewMsgPing(nonce), nil
+}
+
+// HandlePong matches the provided pong message received from the peer against
+// the unanswered pings and returns the round-trip time of the ping it answers.
+// False is returned when the nonce of the pong does not match an unanswered
+// ping, such as for unsolicited or duplicate pongs, in which case the pong
+// should be ignored.
+func (pt *PingTracker) HandlePong(pong *MsgPong) (time.Duration, bool) {
+	pt.mtx.Lock()
+	defer pt.mtx.Unlock()
+
+	sent, ok := pt.pending[pong.Nonce]
+	if !ok {
+		return 0, false
+	}
+	delete(pt.pending, pong.Nonce)
+	for i, nonce := range pt.order {
+		if nonce == pong.Nonce {
+			pt.order = append(pt.order[:i], pt.order[i+1:]...)
+			break
+		}
+	}
+
+	pt.lastRTT = time.Since(sent)
+	return pt.lastRTT, true
+}
+
+// LastRTT returns the round-trip time of the most recently answered ping, or
+// zero when no pings have been answered.
+func (pt *PingTracker) LastRTT() time.Duration {
+	pt.mtx.Lock()
+	rtt := pt.lastRTT
+	pt.mtx.Unlock()
+	return rtt
+}
+
+// Pending returns the number of pings which have not been answered.
+func (pt *PingTracker) Pending() int {
+	pt.mtx.Lock()
+	n := len(pt.order)
+	pt.mtx.Unlock()
+	return n
+}
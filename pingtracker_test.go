@@ -0,0 +1,77 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"github.com/conformal/btcwire"
+	"testing"
+	"time"
+)
+
+// TestPingTracker tests the PingTracker API.
+func TestPingTracker(t *testing.T) {
+	pt := btcwire.NewPingTracker(2)
+	if rtt := pt.LastRTT(); rtt != 0 {
+		t.Errorf("LastRTT: unexpected rtt %v before any pongs", rtt)
+	}
+
+	ping, err := pt.NewPing()
+	if err != nil {
+		t.Fatalf("NewPing: %v", err)
+	}
+	if pt.Pending() != 1 {
+		t.Errorf("Pending: wrong count got: %d want: 1", pt.Pending())
+	}
+
+	// Ensure the matching pong reports the elapsed time.
+	time.Sleep(10 * time.Millisecond)
+	rtt, ok := pt.HandlePong(btcwire.NewMsgPong(ping.Nonce))
+	if !ok {
+		t.Fatalf("HandlePong: pong for ping not matched")
+	}
+	if rtt < 10*time.Millisecond {
+		t.Errorf("HandlePong: rtt %v less than elapsed time", rtt)
+	}
+	if pt.LastRTT() != rtt {
+		t.Errorf("LastRTT: got: %v want: %v", pt.LastRTT(), rtt)
+	}
+	if pt.Pending() != 0 {
+		t.Errorf("Pending: wrong count got: %d want: 0", pt.Pending())
+	}
+
+	// Ensure duplicate and unsolicited pongs are not matched and don't
+	// change the last rtt.
+	if _, ok := pt.HandlePong(btcwire.NewMsgPong(ping.Nonce)); ok {
+		t.Errorf("HandlePong: duplicate pong matched")
+	}
+	if _, ok := pt.HandlePong(btcwire.NewMsgPong(ping.Nonce + 1)); ok {
+		t.Errorf("HandlePong: unsolicited pong matched")
+	}
+	if pt.LastRTT() != rtt {
+		t.Errorf("LastRTT: changed by unmatched pong got: %v want: %v",
+			pt.LastRTT(), rtt)
+	}
+
+	// Ensure the oldest ping is forgotten once the tracker is full.
+	pings := make([]*btcwire.MsgPing, 3)
+	for i := range pings {
+		pings[i], err = pt.NewPing()
+		if err != nil {
+			t.Fatalf("NewPing: %v", err)
+		}
+	}
+	if pt.Pending() != 2 {
+		t.Errorf("Pending: wrong count got: %d want: 2", pt.Pending())
+	}
+	if _, ok := pt.HandlePong(btcwire.NewMsgPong(pings[0].Nonce)); ok {
+		t.Errorf("HandlePong: pong for forgotten ping matched")
+	}
+	for _, ping := range pings[1:] {
+		if _, ok := pt.HandlePong(btcwire.NewMsgPong(ping.Nonce)); !ok {
+			t.Errorf("HandlePong: pong for ping %d not matched",
+				ping.Nonce)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"bytes"
+	"sync"
+)
+
+// PowHashFunc computes the proof-of-work hash of a serialized block header.
+// The provided slice is the 80-byte header as it is hashed for the block
+// identifier and must not be modified or retained.
+type PowHashFunc func(header []byte) ShaHash
+
+// powHashFuncs houses the proof-of-work hash functions registered via
+// RegisterPowHash.
+var (
+	powHashFuncsMtx sync.RWMutex
+	powHashFuncs    = make(map[BitcoinNet]PowHashFunc)
+)
+
+// RegisterPowHash registers the function used to compute the proof-of-work
+// hash of block headers on the provided network.  This allows networks which
+// share the bitcoin wire format, but use a different proof-of-work algorithm
+// such as scrypt, to be supported without modifying this package.  Passing a
+// nil fn removes any previously registered function.
+//
+// The registered function only affects PowHash.  Block identifiers, as
+// returned by BlockSha, transaction hashes, and message checksums always use
+// double sha256.
+func RegisterPowHash(btcnet BitcoinNet, fn PowHashFunc) {
+	powHashFuncsMtx.Lock()
+	if fn == nil {
+		delete(powHashFuncs, btcnet)
+	} else {
+		powHashFuncs[btcnet] = fn
+	}
+	powHashFuncsMtx.Unlock()
+}
+
+// powHash returns the proof-of-work hash of the provided serialized header
+// using the function registered for the network, or double sha256 when no
+// function has been registered.
+func powHash(header []byte, btcnet BitcoinNet) ShaHash {
+	powHashFuncsMtx.RLock()
+	fn := powHashFuncs[btcnet]
+	powHashFuncsMtx.RUnlock()
+	if fn != nil {
+		return fn(header)
+	}
+
+	// SetBytes can't fail here due to the fact DoubleSha256 always returns
+	// a []byte of the right size regardless of input.
+	var sha ShaHash
+	_ = sha.SetBytes(DoubleSha256(header))
+	return sha
+}
+
+// PowHash computes the proof-of-work hash of the block header for the provided
+// network.  This is the hash which must be less than the target difficulty.
+// It is the same as the block identifier returned by BlockSha unless a
+// different proof-of-work hash function has been registered for the network
+// via RegisterPowHash.
+func (h *BlockHeader) PowHash(btcnet BitcoinNet) (ShaHash, error) {
+	// Ignore the error return since there is no way the encode could fail
+	// except being out of memory which would cause a run-time panic.
+	var buf bytes.Buffer
+	_ = writeBlockHeader(&buf, 0, h)
+	return powHash(buf.Bytes()[0:blockHashLen], btcnet), nil
+}
+
+// PowHash computes the proof-of-work hash for the current state of the header
+// for the provided network.  See BlockHeader.PowHash for details.
+func (hw *HeaderWork) PowHash(btcnet BitcoinNet) ShaHash {
+	return powHash(hw.serialized[:], btcnet)
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"testing"
+)
+
+// TestPowHash tests computing proof-of-work hashes with the default and
+// registered hash functions.
+func TestPowHash(t *testing.T) {
+	// powTestNet is a network used to test registering a proof-of-work
+	// hash function.
+	const powTestNet btcwire.BitcoinNet = 0xdbb6c0f0
+
+	// Ensure the default proof-of-work hash is the block hash.
+	bh := blockOne.Header
+	wantSha, _ := bh.BlockSha()
+	sha, err := bh.PowHash(btcwire.MainNet)
+	if err != nil {
+		t.Errorf("PowHash: %v", err)
+	}
+	if !sha.IsEqual(&wantSha) {
+		t.Errorf("PowHash: wrong hash - got %v, want %v",
+			spew.Sprint(sha), spew.Sprint(wantSha))
+	}
+
+	// Register a hash function which returns the last bytes of the header
+	// reversed to make it obviously different.
+	var gotHeader []byte
+	powFunc := func(header []byte) btcwire.ShaHash {
+		gotHeader = append([]byte(nil), header...)
+		var sha btcwire.ShaHash
+		for i := range sha {
+			sha[i] = header[len(header)-1-i]
+		}
+		return sha
+	}
+	btcwire.RegisterPowHash(powTestNet, powFunc)
+	defer btcwire.RegisterPowHash(powTestNet, nil)
+
+	hw := btcwire.NewHeaderWork(&bh)
+	wantPow := powFunc(hw.Bytes())
+	sha, _ = bh.PowHash(powTestNet)
+	if !sha.IsEqual(&wantPow) {
+		t.Errorf("PowHash: wrong registered hash - got %v, want %v",
+			spew.Sprint(sha), spew.Sprint(wantPow))
+	}
+	if len(gotHeader) != 80 {
+		t.Errorf("PowHash: wrong header length passed to hash "+
+			"function - got %d, want 80", len(gotHeader))
+	}
+	if sha := hw.PowHash(powTestNet); !sha.IsEqual(&wantPow) {
+		t.Errorf("HeaderWork.PowHash: wrong registered hash - got %v, "+
+			"want %v", spew.Sprint(sha), spew.Sprint(wantPow))
+	}
+
+	// Ensure the block hash and other networks are unaffected.
+	if sha, _ := bh.BlockSha(); !sha.IsEqual(&wantSha) {
+		t.Errorf("BlockSha: wrong hash - got %v, want %v",
+			spew.Sprint(sha), spew.Sprint(wantSha))
+	}
+	if sha, _ := bh.PowHash(btcwire.MainNet); !sha.IsEqual(&wantSha) {
+		t.Errorf("PowHash: wrong main network hash - got %v, want %v",
+			spew.Sprint(sha), spew.Sprint(wantSha))
+	}
+
+	// Ensure removing the registered function restores the default.
+	btcwire.RegisterPowHash(powTestNet, nil)
+	if sha, _ := bh.PowHash(powTestNet); !sha.IsEqual(&wantSha) {
+		t.Errorf("PowHash: wrong hash after removal - got %v, want %v",
+			spew.Sprint(sha), spew.Sprint(wantSha))
+	}
+}
@@ -0,0 +1,817 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// These constants define the protocol buffer wire types used by the messages
+// in btcwire.proto.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// These constants define the numbers of the payload fields of WireMessage in
+// btcwire.proto.
+const (
+	protoFieldEmpty        = 2
+	protoFieldVersion      = 3
+	protoFieldAddr         = 4
+	protoFieldBlockLocator = 5
+	protoFieldInvList      = 6
+	protoFieldBlock        = 7
+	protoFieldTx           = 8
+	protoFieldHeaders      = 9
+	protoFieldNonce        = 10
+	protoFieldAlert        = 11
+	protoFieldUnknown      = 12
+)
+
+// protoWriter encodes protocol buffer fields.  Scalar fields with their default
+// value are omitted as in proto3.
+type protoWriter struct {
+	buf []byte
+}
+
+// varint appends v as a base 128 varint.
+func (p *protoWriter) varint(v uint64) {
+	for v >= 0x80 {
+		p.buf = append(p.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	p.buf = append(p.buf, byte(v))
+}
+
+// tag appends the key of a field with the provided number and wire type.
+func (p *protoWriter) tag(field int, wireType int) {
+	p.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+// uint appends a varint field unless v is zero.  Signed values are passed as
+// their two's complement as they are for the int32 and int64 types.
+func (p *protoWriter) uint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	p.tag(field, protoVarint)
+	p.varint(v)
+}
+
+// bool appends a bool field unless v is false.
+func (p *protoWriter) bool(field int, v bool) {
+	if v {
+		p.uint(field, 1)
+	}
+}
+
+// timestamp appends an optional timestamp field unless t is the zero time.
+func (p *protoWriter) timestamp(field int, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	p.tag(field, protoVarint)
+	p.varint(uint64(t.Unix()))
+}
+
+// bytes appends a bytes field.  Empty values are omitted unless always is set,
+// which is required for the entries of repeated fields.
+func (p *protoWriter) bytes(field int, b []byte, always bool) {
+	if len(b) == 0 && !always {
+		return
+	}
+	p.tag(field, protoBytes)
+	p.varint(uint64(len(b)))
+	p.buf = append(p.buf, b...)
+}
+
+// message appends an embedded message field whose fields are written by fn.
+// The field is always written so its presence is preserved.
+func (p *protoWriter) message(field int, fn func(p *protoWriter)) {
+	var sub protoWriter
+	fn(&sub)
+	p.bytes(field, sub.buf, true)
+}
+
+// protoField is a single decoded protocol buffer field.  Only one of v and b
+// is set depending on the wire type.
+type protoField struct {
+	num      int
+	wireType int
+	v        uint64
+	b        []byte
+}
+
+// protoError returns an error for a malformed protocol buffer encoding.
+func protoError(desc string) error {
+	return categorizedError("UnmarshalProto", desc, ErrCategoryMalformed)
+}
+
+// uint returns the value of a varint field.
+func (f *protoField) uint() (uint64, error) {
+	if f.wireType != protoVarint {
+		str := fmt.Sprintf("field %d has wire type %d, expected a "+
+			"varint", f.num, f.wireType)
+		return 0, protoError(str)
+	}
+	return f.v, nil
+}
+
+// bytes returns the value of a bytes or embedded message field.
+func (f *protoField) bytes() ([]byte, error) {
+	if f.wireType != protoBytes {
+		str := fmt.Sprintf("field %d has wire type %d, expected bytes",
+			f.num, f.wireType)
+		return nil, protoError(str)
+	}
+	return f.b, nil
+}
+
+// hash returns the value of a bytes field which holds a hash.
+func (f *protoField) hash(hash *ShaHash) error {
+	b, err := f.bytes()
+	if err != nil {
+		return err
+	}
+	if len(b) != HashSize {
+		str := fmt.Sprintf("field %d holds %d bytes, expected a %d "+
+			"byte hash", f.num, len(b), HashSize)
+		return protoError(str)
+	}
+	copy(hash[:], b)
+	return nil
+}
+
+// readProtoVarint reads a base 128 varint from the start of b and returns it
+// along with the number of bytes read.
+func readProtoVarint(b []byte) (uint64, int, error) {
+	var v uint64
+	for i := 0; i < len(b) && i < 10; i++ {
+		v |= uint64(b[i]&0x7f) << (7 * uint(i))
+		if b[i] < 0x80 {
+			return v, i + 1, nil
+		}
+	}
+	return 0, 0, protoError("truncated or overlong varint")
+}
+
+// decodeProtoFields calls fn for each field encoded in b.  Fields with the
+// fixed size wire types, which are not used by btcwire.proto, are skipped so
+// fields added by later versions of the definitions are ignored.
+func decodeProtoFields(b []byte, fn func(f *protoField) error) error {
+	for len(b) > 0 {
+		key, n, err := readProtoVarint(b)
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+		f := protoField{num: int(key >> 3), wireType: int(key & 7)}
+
+		switch f.wireType {
+		case protoVarint:
+			f.v, n, err = readProtoVarint(b)
+			if err != nil {
+				return err
+			}
+		case protoBytes:
+			var size uint64
+			size, n, err = readProtoVarint(b)
+			if err != nil {
+				return err
+			}
+			if size > uint64(len(b)-n) {
+				return protoError("truncated bytes field")
+			}
+			f.b = b[n : n+int(size)]
+			n += int(size)
+		case protoFixed64:
+			n = 8
+		case protoFixed32:
+			n = 4
+		default:
+			str := fmt.Sprintf("unsupported wire type %d", f.wireType)
+			return protoError(str)
+		}
+		if n > len(b) {
+			return protoError("truncated field")
+		}
+		b = b[n:]
+
+		if f.wireType == protoFixed64 || f.wireType == protoFixed32 {
+			continue
+		}
+		err = fn(&f)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeProtoMessage decodes the embedded message held by the field f by
+// calling fn for each of its fields.
+func decodeProtoMessage(f *protoField, fn func(f *protoField) error) error {
+	b, err := f.bytes()
+	if err != nil {
+		return err
+	}
+	return decodeProtoFields(b, fn)
+}
+
+// protoTime returns the time represented by the value of a timestamp field.
+func protoTime(f *protoField) (time.Time, error) {
+	v, err := f.uint()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(v), 0), nil
+}
+
+// writeProtoTx writes the fields of a Tx.
+func writeProtoTx(p *protoWriter, msg *MsgTx) {
+	p.uint(1, uint64(msg.Version))
+	for _, ti := range msg.TxIn {
+		p.message(2, func(p *protoWriter) {
+			p.message(1, func(p *protoWriter) {
+				op := &ti.PreviousOutpoint
+				p.bytes(1, op.Hash[:], false)
+				p.uint(2, uint64(op.Index))
+			})
+			p.bytes(2, ti.SignatureScript, false)
+			p.uint(3, uint64(ti.Sequence))
+		})
+	}
+	for _, to := range msg.TxOut {
+		p.message(3, func(p *protoWriter) {
+			p.uint(1, uint64(to.Value))
+			p.bytes(2, to.PkScript, false)
+		})
+	}
+	p.uint(4, uint64(msg.LockTime))
+}
+
+// readProtoOutPoint decodes the OutPoint held by f into op.
+func readProtoOutPoint(f *protoField, op *OutPoint) error {
+	return decodeProtoMessage(f, func(f *protoField) error {
+		switch f.num {
+		case 1:
+			return f.hash(&op.Hash)
+		case 2:
+			v, err := f.uint()
+			op.Index = uint32(v)
+			return err
+		}
+		return nil
+	})
+}
+
+// readProtoTx decodes the Tx held by f into msg.
+func readProtoTx(f *protoField, msg *MsgTx) error {
+	return decodeProtoMessage(f, func(f *protoField) error {
+		switch f.num {
+		case 1:
+			v, err := f.uint()
+			msg.Version = uint32(v)
+			return err
+
+		case 2:
+			ti := &TxIn{}
+			err := decodeProtoMessage(f, func(f *protoField) error {
+				switch f.num {
+				case 1:
+					return readProtoOutPoint(f,
+						&ti.PreviousOutpoint)
+				case 2:
+					b, err := f.bytes()
+					ti.SignatureScript = append([]byte{}, b...)
+					return err
+				case 3:
+					v, err := f.uint()
+					ti.Sequence = uint32(v)
+					return err
+				}
+				return nil
+			})
+			msg.TxIn = append(msg.TxIn, ti)
+			return err
+
+		case 3:
+			to := &TxOut{}
+			err := decodeProtoMessage(f, func(f *protoField) error {
+				switch f.num {
+				case 1:
+					v, err := f.uint()
+					to.Value = int64(v)
+					return err
+				case 2:
+					b, err := f.bytes()
+					to.PkScript = append([]byte{}, b...)
+					return err
+				}
+				return nil
+			})
+			msg.TxOut = append(msg.TxOut, to)
+			return err
+
+		case 4:
+			v, err := f.uint()
+			msg.LockTime = uint32(v)
+			return err
+		}
+		return nil
+	})
+}
+
+// writeProtoBlockHeader writes the fields of a BlockHeader.
+func writeProtoBlockHeader(p *protoWriter, bh *BlockHeader) {
+	p.uint(1, uint64(bh.Version))
+	p.bytes(2, bh.PrevBlock[:], false)
+	p.bytes(3, bh.MerkleRoot[:], false)
+	p.timestamp(4, bh.Timestamp)
+	p.uint(5, uint64(bh.Bits))
+	p.uint(6, uint64(bh.Nonce))
+	p.uint(7, bh.TxnCount)
+}
+
+// readProtoBlockHeader decodes the BlockHeader held by f into bh.
+func readProtoBlockHeader(f *protoField, bh *BlockHeader) error {
+	return decodeProtoMessage(f, func(f *protoField) error {
+		var v uint64
+		var err error
+		switch f.num {
+		case 1:
+			v, err = f.uint()
+			bh.Version = uint32(v)
+		case 2:
+			err = f.hash(&bh.PrevBlock)
+		case 3:
+			err = f.hash(&bh.MerkleRoot)
+		case 4:
+			bh.Timestamp, err = protoTime(f)
+		case 5:
+			v, err = f.uint()
+			bh.Bits = uint32(v)
+		case 6:
+			v, err = f.uint()
+			bh.Nonce = uint32(v)
+		case 7:
+			bh.TxnCount, err = f.uint()
+		}
+		return err
+	})
+}
+
+// writeProtoNetAddress writes the fields of a NetAddress.
+func writeProtoNetAddress(p *protoWriter, na *NetAddress) {
+	p.timestamp(1, na.Timestamp)
+	p.uint(2, uint64(na.Services))
+	p.bytes(3, na.IP, false)
+	p.uint(4, uint64(na.Port))
+}
+
+// readProtoNetAddress decodes the NetAddress held by f into na.
+func readProtoNetAddress(f *protoField, na *NetAddress) error {
+	return decodeProtoMessage(f, func(f *protoField) error {
+		var v uint64
+		var err error
+		switch f.num {
+		case 1:
+			na.Timestamp, err = protoTime(f)
+		case 2:
+			v, err = f.uint()
+			na.Services = ServiceFlag(v)
+		case 3:
+			var b []byte
+			b, err = f.bytes()
+			na.IP = append(net.IP{}, b...)
+		case 4:
+			v, err = f.uint()
+			na.Port = uint16(v)
+		}
+		return err
+	})
+}
+
+// writeProtoInvList writes the fields of an InvList.
+func writeProtoInvList(p *protoWriter, invList []*InvVect) {
+	for _, iv := range invList {
+		p.message(1, func(p *protoWriter) {
+			p.uint(1, uint64(iv.Type))
+			p.bytes(2, iv.Hash[:], false)
+		})
+	}
+}
+
+// readProtoInvList decodes the InvList held by f.
+func readProtoInvList(f *protoField) ([]*InvVect, error) {
+	invList := make([]*InvVect, 0)
+	err := decodeProtoMessage(f, func(f *protoField) error {
+		if f.num != 1 {
+			return nil
+		}
+		iv := &InvVect{}
+		invList = append(invList, iv)
+		return decodeProtoMessage(f, func(f *protoField) error {
+			switch f.num {
+			case 1:
+				v, err := f.uint()
+				iv.Type = InvType(v)
+				return err
+			case 2:
+				return f.hash(&iv.Hash)
+			}
+			return nil
+		})
+	})
+	return invList, err
+}
+
+// writeProtoBlockLocator writes the fields of a BlockLocator.
+func writeProtoBlockLocator(p *protoWriter, pver uint32, locator []*ShaHash, hashStop *ShaHash) {
+	p.uint(1, uint64(pver))
+	for _, hash := range locator {
+		p.bytes(2, hash[:], true)
+	}
+	p.bytes(3, hashStop[:], false)
+}
+
+// readProtoBlockLocator decodes the BlockLocator held by f.
+func readProtoBlockLocator(f *protoField, pver *uint32, locator *[]*ShaHash, hashStop *ShaHash) error {
+	*locator = make([]*ShaHash, 0)
+	return decodeProtoMessage(f, func(f *protoField) error {
+		switch f.num {
+		case 1:
+			v, err := f.uint()
+			*pver = uint32(v)
+			return err
+		case 2:
+			hash := &ShaHash{}
+			*locator = append(*locator, hash)
+			return f.hash(hash)
+		case 3:
+			return f.hash(hashStop)
+		}
+		return nil
+	})
+}
+
+// MarshalProto encodes the provided message as the WireMessage protocol buffer
+// defined in btcwire.proto.  This provides a language-neutral representation
+// of decoded messages for services such as indexers and analytics pipelines,
+// which can generate code from btcwire.proto rather than reimplementing the
+// wire format.  It is not a replacement for the wire encoding.
+//
+// The messages of the optional protocol extensions, such as MsgGetXThin, are
+// not supported, nor are block headers with an auxiliary proof-of-work.  A
+// MessageError is returned for them.
+func MarshalProto(msg Message) ([]byte, error) {
+	var p protoWriter
+	p.bytes(1, []byte(msg.Command()), false)
+
+	switch msg := msg.(type) {
+	case *MsgVerAck, *MsgGetAddr, *MsgMemPool:
+		p.message(protoFieldEmpty, func(p *protoWriter) {})
+
+	case *MsgVersion:
+		p.message(protoFieldVersion, func(p *protoWriter) {
+			p.uint(1, uint64(msg.ProtocolVersion))
+			p.uint(2, uint64(msg.Services))
+			p.timestamp(3, msg.Timestamp)
+			p.message(4, func(p *protoWriter) {
+				writeProtoNetAddress(p, &msg.AddrYou)
+			})
+			p.message(5, func(p *protoWriter) {
+				writeProtoNetAddress(p, &msg.AddrMe)
+			})
+			p.uint(6, msg.Nonce)
+			p.bytes(7, []byte(msg.UserAgent), false)
+			p.uint(8, uint64(msg.LastBlock))
+			p.bool(9, msg.DisableRelayTx)
+		})
+
+	case *MsgAddr:
+		p.message(protoFieldAddr, func(p *protoWriter) {
+			for _, na := range msg.AddrList {
+				p.message(1, func(p *protoWriter) {
+					writeProtoNetAddress(p, na)
+				})
+			}
+		})
+
+	case *MsgGetBlocks:
+		p.message(protoFieldBlockLocator, func(p *protoWriter) {
+			writeProtoBlockLocator(p, msg.ProtocolVersion,
+				msg.BlockLocatorHashes, &msg.HashStop)
+		})
+
+	case *MsgGetHeaders:
+		p.message(protoFieldBlockLocator, func(p *protoWriter) {
+			writeProtoBlockLocator(p, msg.ProtocolVersion,
+				msg.BlockLocatorHashes, &msg.HashStop)
+		})
+
+	case *MsgInv:
+		p.message(protoFieldInvList, func(p *protoWriter) {
+			writeProtoInvList(p, msg.InvList)
+		})
+
+	case *MsgGetData:
+		p.message(protoFieldInvList, func(p *protoWriter) {
+			writeProtoInvList(p, msg.InvList)
+		})
+
+	case *MsgNotFound:
+		p.message(protoFieldInvList, func(p *protoWriter) {
+			writeProtoInvList(p, msg.InvList)
+		})
+
+	case *MsgBlock:
+		if msg.Header.AuxPow != nil {
+			return nil, messageError("MarshalProto", "block headers "+
+				"with an auxiliary proof-of-work are not supported")
+		}
+		p.message(protoFieldBlock, func(p *protoWriter) {
+			p.message(1, func(p *protoWriter) {
+				writeProtoBlockHeader(p, &msg.Header)
+			})
+			for _, tx := range msg.Transactions {
+				p.message(2, func(p *protoWriter) {
+					writeProtoTx(p, tx)
+				})
+			}
+		})
+
+	case *MsgTx:
+		p.message(protoFieldTx, func(p *protoWriter) {
+			writeProtoTx(p, msg)
+		})
+
+	case *MsgHeaders:
+		for _, bh := range msg.Headers {
+			if bh.AuxPow != nil {
+				return nil, messageError("MarshalProto", "block "+
+					"headers with an auxiliary proof-of-work "+
+					"are not supported")
+			}
+		}
+		p.message(protoFieldHeaders, func(p *protoWriter) {
+			for _, bh := range msg.Headers {
+				p.message(1, func(p *protoWriter) {
+					writeProtoBlockHeader(p, bh)
+				})
+			}
+		})
+
+	case *MsgPing:
+		p.message(protoFieldNonce, func(p *protoWriter) {
+			p.uint(1, msg.Nonce)
+		})
+
+	case *MsgPong:
+		p.message(protoFieldNonce, func(p *protoWriter) {
+			p.uint(1, msg.Nonce)
+		})
+
+	case *MsgAlert:
+		p.message(protoFieldAlert, func(p *protoWriter) {
+			p.bytes(1, []byte(msg.PayloadBlob), false)
+			p.bytes(2, []byte(msg.Signature), false)
+		})
+
+	case *MsgUnknown:
+		p.message(protoFieldUnknown, func(p *protoWriter) {
+			p.bytes(1, msg.Payload, false)
+		})
+
+	default:
+		str := fmt.Sprintf("messages of type %T are not supported", msg)
+		return nil, messageError("MarshalProto", str)
+	}
+	return p.buf, nil
+}
+
+// protoPayloadFields maps the commands of the messages supported by
+// UnmarshalProto to the number of the WireMessage payload field which holds
+// their fields.
+var protoPayloadFields = map[string]int{
+	CmdVerAck:     protoFieldEmpty,
+	CmdGetAddr:    protoFieldEmpty,
+	CmdMemPool:    protoFieldEmpty,
+	CmdVersion:    protoFieldVersion,
+	CmdAddr:       protoFieldAddr,
+	CmdGetBlocks:  protoFieldBlockLocator,
+	CmdGetHeaders: protoFieldBlockLocator,
+	CmdInv:        protoFieldInvList,
+	CmdGetData:    protoFieldInvList,
+	CmdNotFound:   protoFieldInvList,
+	CmdBlock:      protoFieldBlock,
+	CmdTx:         protoFieldTx,
+	CmdHeaders:    protoFieldHeaders,
+	CmdPing:       protoFieldNonce,
+	CmdPong:       protoFieldNonce,
+	CmdAlert:      protoFieldAlert,
+}
+
+// UnmarshalProto decodes a WireMessage protocol buffer, as produced by
+// MarshalProto, into a message of the concrete type which corresponds to its
+// command.  A MsgUnknown is returned for the unknown payload.  A MessageError
+// is returned when the encoding is malformed or the payload does not match the
+// command.  Fields which are not defined in btcwire.proto are ignored.
+func UnmarshalProto(b []byte) (Message, error) {
+	// Find the command and payload first since the payload may precede
+	// the command.
+	var command string
+	var payload *protoField
+	err := decodeProtoFields(b, func(f *protoField) error {
+		if f.num == 1 {
+			b, err := f.bytes()
+			command = string(b)
+			return err
+		}
+		if f.num >= protoFieldEmpty && f.num <= protoFieldUnknown {
+			field := *f
+			payload = &field
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if payload == nil {
+		str := fmt.Sprintf("message with command [%s] has no payload",
+			command)
+		return nil, protoError(str)
+	}
+
+	if payload.num == protoFieldUnknown {
+		msg, err := NewMsgUnknown(command, nil)
+		if err != nil {
+			return nil, err
+		}
+		err = decodeProtoMessage(payload, func(f *protoField) error {
+			if f.num == 1 {
+				b, err := f.bytes()
+				msg.Payload = append([]byte{}, b...)
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return msg, nil
+	}
+
+	want, ok := protoPayloadFields[command]
+	if !ok {
+		str := fmt.Sprintf("messages with command [%s] are not "+
+			"supported", command)
+		return nil, messageError("UnmarshalProto", str)
+	}
+	if payload.num != want {
+		str := fmt.Sprintf("payload field %d does not match command "+
+			"[%s]", payload.num, command)
+		return nil, protoError(str)
+	}
+	msg, err := makeEmptyMessage(command)
+	if err != nil {
+		return nil, err
+	}
+
+	switch msg := msg.(type) {
+	case *MsgVerAck, *MsgGetAddr, *MsgMemPool:
+		_, err = payload.bytes()
+
+	case *MsgVersion:
+		err = decodeProtoMessage(payload, func(f *protoField) error {
+			var v uint64
+			var err error
+			switch f.num {
+			case 1:
+				v, err = f.uint()
+				msg.ProtocolVersion = int32(v)
+			case 2:
+				v, err = f.uint()
+				msg.Services = ServiceFlag(v)
+			case 3:
+				msg.Timestamp, err = protoTime(f)
+			case 4:
+				err = readProtoNetAddress(f, &msg.AddrYou)
+			case 5:
+				err = readProtoNetAddress(f, &msg.AddrMe)
+			case 6:
+				msg.Nonce, err = f.uint()
+			case 7:
+				var b []byte
+				b, err = f.bytes()
+				msg.UserAgent = string(b)
+			case 8:
+				v, err = f.uint()
+				msg.LastBlock = int32(v)
+			case 9:
+				v, err = f.uint()
+				msg.DisableRelayTx = v != 0
+			}
+			return err
+		})
+
+	case *MsgAddr:
+		msg.AddrList = make([]*NetAddress, 0)
+		err = decodeProtoMessage(payload, func(f *protoField) error {
+			if f.num != 1 {
+				return nil
+			}
+			na := &NetAddress{}
+			msg.AddrList = append(msg.AddrList, na)
+			return readProtoNetAddress(f, na)
+		})
+
+	case *MsgGetBlocks:
+		err = readProtoBlockLocator(payload, &msg.ProtocolVersion,
+			&msg.BlockLocatorHashes, &msg.HashStop)
+
+	case *MsgGetHeaders:
+		err = readProtoBlockLocator(payload, &msg.ProtocolVersion,
+			&msg.BlockLocatorHashes, &msg.HashStop)
+
+	case *MsgInv:
+		msg.InvList, err = readProtoInvList(payload)
+
+	case *MsgGetData:
+		msg.InvList, err = readProtoInvList(payload)
+
+	case *MsgNotFound:
+		msg.InvList, err = readProtoInvList(payload)
+
+	case *MsgBlock:
+		msg.Transactions = make([]*MsgTx, 0)
+		err = decodeProtoMessage(payload, func(f *protoField) error {
+			switch f.num {
+			case 1:
+				return readProtoBlockHeader(f, &msg.Header)
+			case 2:
+				tx := &MsgTx{}
+				msg.Transactions = append(msg.Transactions, tx)
+				return readProtoTx(f, tx)
+			}
+			return nil
+		})
+
+	case *MsgTx:
+		err = readProtoTx(payload, msg)
+
+	case *MsgHeaders:
+		msg.Headers = make([]*BlockHeader, 0)
+		err = decodeProtoMessage(payload, func(f *protoField) error {
+			if f.num != 1 {
+				return nil
+			}
+			bh := &BlockHeader{}
+			msg.Headers = append(msg.Headers, bh)
+			return readProtoBlockHeader(f, bh)
+		})
+
+	case *MsgPing:
+		err = decodeProtoMessage(payload, func(f *protoField) error {
+			var err error
+			if f.num == 1 {
+				msg.Nonce, err = f.uint()
+			}
+			return err
+		})
+
+	case *MsgPong:
+		err = decodeProtoMessage(payload, func(f *protoField) error {
+			var err error
+			if f.num == 1 {
+				msg.Nonce, err = f.uint()
+			}
+			return err
+		})
+
+	case *MsgAlert:
+		err = decodeProtoMessage(payload, func(f *protoField) error {
+			b, err := f.bytes()
+			switch f.num {
+			case 1:
+				msg.PayloadBlob = string(b)
+			case 2:
+				msg.Signature = string(b)
+			default:
+				return nil
+			}
+			return err
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"testing"
+)
+
+// TestProto ensures messages survive a round trip through the protocol buffer
+// encoding with the same wire encoding.
+func TestProto(t *testing.T) {
+	unknown, err := btcwire.NewMsgUnknown("futurecmd", []byte{0x01, 0x02})
+	if err != nil {
+		t.Fatalf("NewMsgUnknown: unexpected error %v", err)
+	}
+	tests := append(snapshotMessages(),
+		btcwire.NewMsgPing(0),
+		btcwire.NewMsgInv(),
+		btcwire.NewMsgTx(),
+		unknown,
+	)
+
+	pver := btcwire.ProtocolVersion
+	t.Logf("Running %d tests", len(tests))
+	for i, msg := range tests {
+		b, err := btcwire.MarshalProto(msg)
+		if err != nil {
+			t.Errorf("MarshalProto #%d (%s) error %v", i, msg.Command(),
+				err)
+			continue
+		}
+		got, err := btcwire.UnmarshalProto(b)
+		if err != nil {
+			t.Errorf("UnmarshalProto #%d (%s) error %v", i,
+				msg.Command(), err)
+			continue
+		}
+		if got.Command() != msg.Command() {
+			t.Errorf("UnmarshalProto #%d wrong command - got %s, "+
+				"want %s", i, got.Command(), msg.Command())
+			continue
+		}
+
+		var want, have bytes.Buffer
+		if err := msg.BtcEncode(&want, pver); err != nil {
+			t.Errorf("BtcEncode #%d (%s) error %v", i, msg.Command(),
+				err)
+			continue
+		}
+		if err := got.BtcEncode(&have, pver); err != nil {
+			t.Errorf("BtcEncode #%d (%s) error %v", i, msg.Command(),
+				err)
+			continue
+		}
+		if !bytes.Equal(have.Bytes(), want.Bytes()) {
+			t.Errorf("UnmarshalProto #%d (%s) round trip mismatch "+
+				"- got %x, want %x", i, msg.Command(),
+				have.Bytes(), want.Bytes())
+		}
+	}
+}
+
+// TestProtoEncoding ensures the protocol buffer encoding of messages matches
+// the definitions in btcwire.proto.
+func TestProtoEncoding(t *testing.T) {
+	tests := []struct {
+		msg btcwire.Message // Message to encode
+		buf []byte          // Expected encoding
+	}{
+		// WireMessage{command: "ping", nonce: Nonce{nonce: 1}}
+		{
+			btcwire.NewMsgPing(1),
+			[]byte{
+				0x0a, 0x04, 'p', 'i', 'n', 'g',
+				0x52, 0x02, 0x08, 0x01,
+			},
+		},
+
+		// WireMessage{command: "verack", empty: Empty{}}
+		{
+			btcwire.NewMsgVerAck(),
+			[]byte{
+				0x0a, 0x06, 'v', 'e', 'r', 'a', 'c', 'k',
+				0x12, 0x00,
+			},
+		},
+
+		// WireMessage{command: "inv", inv_list: InvList{inv_list: [
+		//     InvVect{type: 1, hash: 00...00}]}}
+		{
+			&btcwire.MsgInv{InvList: []*btcwire.InvVect{
+				{Type: btcwire.InvTypeTx},
+			}},
+			append([]byte{
+				0x0a, 0x03, 'i', 'n', 'v',
+				0x32, 0x26, 0x0a, 0x24, 0x08, 0x01, 0x12, 0x20,
+			}, make([]byte, 32)...),
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		b, err := btcwire.MarshalProto(test.msg)
+		if err != nil {
+			t.Errorf("MarshalProto #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(b, test.buf) {
+			t.Errorf("MarshalProto #%d\n got: %x want: %x", i, b,
+				test.buf)
+		}
+	}
+}
+
+// TestProtoErrors ensures malformed and unsupported protocol buffer encodings
+// are rejected.
+func TestProtoErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  []byte
+	}{
+		{"empty", nil},
+		{"truncated varint", []byte{0x0a, 0x80}},
+		{"truncated bytes", []byte{0x0a, 0x04, 'p', 'i'}},
+		{"no payload", []byte{0x0a, 0x04, 'p', 'i', 'n', 'g'}},
+		{"payload mismatch", []byte{
+			0x0a, 0x04, 'p', 'i', 'n', 'g', 0x12, 0x00,
+		}},
+		{"unsupported command", []byte{
+			0x0a, 0x04, 'x', 'x', 'x', 'x', 0x12, 0x00,
+		}},
+		{"wrong wire type", []byte{
+			0x0a, 0x04, 'p', 'i', 'n', 'g', 0x52, 0x02, 0x0a, 0x00,
+		}},
+		{"short hash", []byte{
+			0x0a, 0x03, 'i', 'n', 'v', 0x32, 0x06, 0x0a, 0x04,
+			0x12, 0x02, 0x00, 0x00,
+		}},
+		{"unsupported wire type", []byte{0x0b}},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for _, test := range tests {
+		_, err := btcwire.UnmarshalProto(test.buf)
+		if _, ok := err.(*btcwire.MessageError); !ok {
+			t.Errorf("UnmarshalProto (%s): wrong error - got %T (%v), "+
+				"want *MessageError", test.name, err, err)
+		}
+	}
+
+	// Ensure messages of the optional extensions are not supported.
+	_, err := btcwire.MarshalProto(&btcwire.MsgGetXThin{})
+	if _, ok := err.(*btcwire.MessageError); !ok {
+		t.Errorf("MarshalProto: wrong error - got %T (%v), want "+
+			"*MessageError", err, err)
+	}
+}
@@ -44,6 +44,38 @@ const (
 	// bloom filtering related messages and extended the version message
 	// with a relay flag (pver >= BIP0037Version).
 	BIP0037Version uint32 = 70001
+
+	// BIP0061Version is the protocol version which added the reject
+	// message (pver >= BIP0061Version).
+	BIP0061Version uint32 = 70002
+
+	// BIP0111Version is the protocol version which added the SFNodeBloom
+	// service flag and stopped peers from serving bloom filtering requests
+	// without it (pver >= BIP0111Version).
+	BIP0111Version uint32 = 70011
+
+	// BIP0130Version is the protocol version which added the sendheaders
+	// message (pver >= BIP0130Version).
+	BIP0130Version uint32 = 70012
+
+	// BIP0133Version is the protocol version which added the feefilter
+	// message (pver >= BIP0133Version).
+	BIP0133Version uint32 = 70013
+
+	// BIP0152Version is the protocol version which added the compact block
+	// messages (pver >= BIP0152Version).
+	BIP0152Version uint32 = 70014
+
+	// ShortIDsV2Version is the protocol version after which peers are no
+	// longer banned for relaying compact blocks which turn out to be
+	// invalid, which allows them to be relayed before being fully
+	// validated (pver >= ShortIDsV2Version).
+	ShortIDsV2Version uint32 = 70015
+
+	// BIP0339Version is the protocol version which added the wtxidrelay
+	// message to negotiate announcing transactions by witness hash
+	// (pver >= BIP0339Version).
+	BIP0339Version uint32 = 70016
 )
 
 // ServiceFlag identifies services supported by a bitcoin peer.
@@ -54,9 +86,24 @@ const (
 	SFNodeNetwork ServiceFlag = 1 << iota
 )
 
+// SFNodeCompression is a flag used to indicate a peer accepts compressed
+// messages.  See WriteMessageCompressed.  It is not part of the bitcoin
+// protocol and is only intended for private networks built on this wire
+// format, so it uses bit 24, which is in the range the reference
+// implementation reserves for experimental services.
+const SFNodeCompression ServiceFlag = 1 << 24
+
 // Map of service flags back to their constant names for pretty printing.
 var sfStrings = map[ServiceFlag]string{
-	SFNodeNetwork: "SFNodeNetwork",
+	SFNodeNetwork:     "SFNodeNetwork",
+	SFNodeCompression: "SFNodeCompression",
+}
+
+// orderedSFStrings is the order in which service flags are listed by String so
+// the result does not depend on map iteration order.
+var orderedSFStrings = []ServiceFlag{
+	SFNodeNetwork,
+	SFNodeCompression,
 }
 
 // String returns the ServiceFlag in human-readable form.
@@ -68,9 +115,9 @@ func (f ServiceFlag) String() string {
 
 	// Add individual bit flags.
 	s := ""
-	for flag, name := range sfStrings {
+	for _, flag := range orderedSFStrings {
 		if f&flag == flag {
-			s += name + "|"
+			s += sfStrings[flag] + "|"
 			f -= flag
 		}
 	}
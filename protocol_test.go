@@ -17,7 +17,10 @@ func TestServiceFlagStringer(t *testing.T) {
 	}{
 		{0, "0x0"},
 		{btcwire.SFNodeNetwork, "SFNodeNetwork"},
-		{0xffffffff, "SFNodeNetwork|0xfffffffe"},
+		{btcwire.SFNodeCompression, "SFNodeCompression"},
+		{btcwire.SFNodeNetwork | btcwire.SFNodeCompression,
+			"SFNodeNetwork|SFNodeCompression"},
+		{0xffffffff, "SFNodeNetwork|SFNodeCompression|0xfefffffe"},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -0,0 +1,156 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"fmt"
+)
+
+// ProtocolFeature identifies a feature of the bitcoin protocol which was
+// introduced by a protocol version bump.
+type ProtocolFeature int
+
+// Protocol features in the order they were introduced.
+const (
+	// FeatureMultipleAddresses is the ability to send more than one
+	// address in an addr message.
+	FeatureMultipleAddresses ProtocolFeature = iota
+
+	// FeatureAddrTimestamp is the timestamp field of network addresses.
+	FeatureAddrTimestamp
+
+	// FeaturePingNonce is the nonce of the ping message and the pong
+	// message (BIP0031).
+	FeaturePingNonce
+
+	// FeatureMemPool is the mempool message (BIP0035).
+	FeatureMemPool
+
+	// FeatureBloomFilter is connection bloom filtering and the relay flag
+	// of the version message (BIP0037).
+	FeatureBloomFilter
+
+	// FeatureReject is the reject message (BIP0061).
+	FeatureReject
+
+	// FeatureNodeBloom is the bloom service flag (BIP0111).
+	FeatureNodeBloom
+
+	// FeatureSendHeaders is the sendheaders message (BIP0130).
+	FeatureSendHeaders
+
+	// FeatureFeeFilter is the feefilter message (BIP0133).
+	FeatureFeeFilter
+
+	// FeatureCompactBlocks is compact block relay (BIP0152).
+	FeatureCompactBlocks
+
+	// FeatureShortIDsV2 is relaying compact blocks before they have been
+	// fully validated.
+	FeatureShortIDsV2
+
+	// FeatureWtxidRelay is announcing transactions by witness hash
+	// (BIP0339).
+	FeatureWtxidRelay
+
+	// numProtocolFeatures is the number of protocol features.  It must be
+	// the last item.
+	numProtocolFeatures
+)
+
+// protocolFeatureInfo describes when a protocol feature was introduced.
+type protocolFeatureInfo struct {
+	name       string
+	minVersion uint32
+	commands   []string
+}
+
+// protocolFeatures maps each protocol feature to the first protocol version
+// which supports it and the commands of the messages it introduced.  It is
+// consulted by the messages whose encoding depends on the protocol version.
+var protocolFeatures = [numProtocolFeatures]protocolFeatureInfo{
+	FeatureMultipleAddresses: {"FeatureMultipleAddresses",
+		MultipleAddressVersion, nil},
+	FeatureAddrTimestamp: {"FeatureAddrTimestamp", NetAddressTimeVersion,
+		nil},
+	// NOTE: BIP0031 was defined as AFTER the version unlike most others.
+	FeaturePingNonce: {"FeaturePingNonce", BIP0031Version + 1,
+		[]string{CmdPong}},
+	FeatureMemPool: {"FeatureMemPool", BIP0035Version,
+		[]string{CmdMemPool}},
+	FeatureBloomFilter: {"FeatureBloomFilter", BIP0037Version,
+		[]string{"filterload", "filteradd", "filterclear",
+			"merkleblock"}},
+	FeatureReject: {"FeatureReject", BIP0061Version,
+		[]string{"reject"}},
+	FeatureNodeBloom: {"FeatureNodeBloom", BIP0111Version, nil},
+	FeatureSendHeaders: {"FeatureSendHeaders", BIP0130Version,
+		[]string{"sendheaders"}},
+	FeatureFeeFilter: {"FeatureFeeFilter", BIP0133Version,
+		[]string{"feefilter"}},
+	FeatureCompactBlocks: {"FeatureCompactBlocks", BIP0152Version,
+		[]string{"sendcmpct", "cmpctblock", "getblocktxn",
+			"blocktxn"}},
+	FeatureShortIDsV2: {"FeatureShortIDsV2", ShortIDsV2Version, nil},
+	FeatureWtxidRelay: {"FeatureWtxidRelay", BIP0339Version,
+		[]string{"wtxidrelay"}},
+}
+
+// MinVersion returns the first protocol version which supports the feature.
+func (f ProtocolFeature) MinVersion() uint32 {
+	if f < 0 || f >= numProtocolFeatures {
+		return 0
+	}
+	return protocolFeatures[f].minVersion
+}
+
+// IsSupported returns whether the feature is supported by the provided
+// protocol version.
+func (f ProtocolFeature) IsSupported(pver uint32) bool {
+	return pver >= f.MinVersion()
+}
+
+// Commands returns the commands of the messages introduced by the feature, if
+// any.  Not all of them are implemented by this package.
+func (f ProtocolFeature) Commands() []string {
+	if f < 0 || f >= numProtocolFeatures {
+		return nil
+	}
+	return append([]string(nil), protocolFeatures[f].commands...)
+}
+
+// String returns the ProtocolFeature in human-readable form.
+func (f ProtocolFeature) String() string {
+	if f < 0 || f >= numProtocolFeatures {
+		return fmt.Sprintf("Unknown ProtocolFeature (%d)", int(f))
+	}
+	return protocolFeatures[f].name
+}
+
+// ProtocolFeatures returns the features supported by the provided protocol
+// version in the order they were introduced.
+func ProtocolFeatures(pver uint32) []ProtocolFeature {
+	var features []ProtocolFeature
+	for f := ProtocolFeature(0); f < numProtocolFeatures; f++ {
+		if f.IsSupported(pver) {
+			features = append(features, f)
+		}
+	}
+	return features
+}
+
+// CommandMinVersion returns the first protocol version which supports the
+// message with the provided command.  Zero is returned for commands which have
+// been supported since the beginning as well as for unrecognized commands.
+func CommandMinVersion(command string) uint32 {
+	for _, info := range protocolFeatures {
+		for _, cmd := range info.commands {
+			if cmd == command {
+				return info.minVersion
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"github.com/conformal/btcwire"
+	"reflect"
+	"testing"
+)
+
+// TestProtocolFeatures tests the protocol versions which introduced each
+// protocol feature.
+func TestProtocolFeatures(t *testing.T) {
+	tests := []struct {
+		feature    btcwire.ProtocolFeature
+		name       string
+		minVersion uint32
+	}{
+		{btcwire.FeatureMultipleAddresses, "FeatureMultipleAddresses", 209},
+		{btcwire.FeatureAddrTimestamp, "FeatureAddrTimestamp", 31402},
+		{btcwire.FeaturePingNonce, "FeaturePingNonce", 60001},
+		{btcwire.FeatureMemPool, "FeatureMemPool", 60002},
+		{btcwire.FeatureBloomFilter, "FeatureBloomFilter", 70001},
+		{btcwire.FeatureReject, "FeatureReject", 70002},
+		{btcwire.FeatureNodeBloom, "FeatureNodeBloom", 70011},
+		{btcwire.FeatureSendHeaders, "FeatureSendHeaders", 70012},
+		{btcwire.FeatureFeeFilter, "FeatureFeeFilter", 70013},
+		{btcwire.FeatureCompactBlocks, "FeatureCompactBlocks", 70014},
+		{btcwire.FeatureShortIDsV2, "FeatureShortIDsV2", 70015},
+		{btcwire.FeatureWtxidRelay, "FeatureWtxidRelay", 70016},
+		{btcwire.ProtocolFeature(0xff), "Unknown ProtocolFeature (255)", 0},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		if got := test.feature.String(); got != test.name {
+			t.Errorf("String #%d got: %s want: %s", i, got, test.name)
+			continue
+		}
+		if got := test.feature.MinVersion(); got != test.minVersion {
+			t.Errorf("MinVersion #%d (%s) got: %d want: %d", i,
+				test.name, got, test.minVersion)
+			continue
+		}
+		if !test.feature.IsSupported(test.minVersion) {
+			t.Errorf("IsSupported #%d (%s) not supported by %d", i,
+				test.name, test.minVersion)
+		}
+		if test.minVersion > 0 &&
+			test.feature.IsSupported(test.minVersion-1) {
+
+			t.Errorf("IsSupported #%d (%s) supported by %d", i,
+				test.name, test.minVersion-1)
+		}
+	}
+
+	// The latest protocol version of this package supports every feature
+	// up to bloom filtering.
+	want := []btcwire.ProtocolFeature{
+		btcwire.FeatureMultipleAddresses,
+		btcwire.FeatureAddrTimestamp,
+		btcwire.FeaturePingNonce,
+		btcwire.FeatureMemPool,
+		btcwire.FeatureBloomFilter,
+	}
+	got := btcwire.ProtocolFeatures(btcwire.ProtocolVersion)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ProtocolFeatures got: %v want: %v", got, want)
+	}
+	if got := btcwire.ProtocolFeatures(0); got != nil {
+		t.Errorf("ProtocolFeatures: features for version 0 got: %v", got)
+	}
+}
+
+// TestCommandMinVersion tests the protocol versions which introduced messages.
+func TestCommandMinVersion(t *testing.T) {
+	tests := []struct {
+		command string
+		want    uint32
+	}{
+		{btcwire.CmdVersion, 0},
+		{btcwire.CmdPong, btcwire.BIP0031Version + 1},
+		{btcwire.CmdMemPool, btcwire.BIP0035Version},
+		{"filterload", btcwire.BIP0037Version},
+		{"reject", btcwire.BIP0061Version},
+		{"sendheaders", btcwire.BIP0130Version},
+		{"feefilter", btcwire.BIP0133Version},
+		{"cmpctblock", btcwire.BIP0152Version},
+		{"wtxidrelay", btcwire.BIP0339Version},
+		{"unknown", 0},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		got := btcwire.CommandMinVersion(test.command)
+		if got != test.want {
+			t.Errorf("CommandMinVersion #%d (%s) got: %d want: %d", i,
+				test.command, got, test.want)
+		}
+	}
+
+	// The commands returned for a feature can't be used to modify the
+	// table.
+	cmds := btcwire.FeatureMemPool.Commands()
+	cmds[0] = "modified"
+	if btcwire.CommandMinVersion(btcwire.CmdMemPool) != btcwire.BIP0035Version {
+		t.Errorf("Commands: returned slice aliases the feature table")
+	}
+}
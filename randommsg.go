@@ -0,0 +1,319 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"math/rand"
+	"net"
+	"time"
+)
+
+// These constants bound the size of randomly generated messages so they stay
+// small enough to generate and encode many of them quickly.
+const (
+	// randomMaxCount is the maximum number of entries in each list of a
+	// random message, such as the transactions of a block.
+	randomMaxCount = 4
+
+	// randomMaxBytes is the maximum length of each script, filter, string,
+	// and other variable length field of a random message.
+	randomMaxBytes = 64
+)
+
+// RandomMessage returns a message of the concrete type which corresponds to
+// the provided command, such as a *MsgTx for CmdTx, with its fields set to
+// random values drawn from r.  The message is valid, in that it passes
+// Validate and encodes with ProtocolVersion, and every field it sets survives
+// being encoded and decoded again.  This makes it suitable for property tests,
+// such as ensuring decoding and encoding again reproduces the original
+// encoding.  Lists, scripts, and other variable length fields are kept short.
+//
+// The commands of optional protocol extensions, such as CmdGetXThin, are
+// supported whether or not they have been registered.  A *MsgUnknown with a
+// random payload is returned for any other command, so an error is only
+// returned when the command itself is not valid.
+func RandomMessage(r *rand.Rand, command string) (Message, error) {
+	switch command {
+	case CmdVersion:
+		return randomMsgVersion(r), nil
+
+	case CmdVerAck:
+		return &MsgVerAck{}, nil
+
+	case CmdGetAddr:
+		return &MsgGetAddr{}, nil
+
+	case CmdAddr:
+		msg := NewMsgAddr()
+		for i := r.Intn(randomMaxCount + 1); i > 0; i-- {
+			msg.AddAddress(randomNetAddress(r, true))
+		}
+		return msg, nil
+
+	case CmdGetBlocks:
+		hashStop := randomHash(r)
+		msg := NewMsgGetBlocks(&hashStop)
+		for i := r.Intn(randomMaxCount + 1); i > 0; i-- {
+			hash := randomHash(r)
+			msg.AddBlockLocatorHash(&hash)
+		}
+		return msg, nil
+
+	case CmdGetHeaders:
+		msg := NewMsgGetHeaders()
+		msg.HashStop = randomHash(r)
+		for i := r.Intn(randomMaxCount + 1); i > 0; i-- {
+			hash := randomHash(r)
+			msg.AddBlockLocatorHash(&hash)
+		}
+		return msg, nil
+
+	case CmdInv:
+		return &MsgInv{InvList: randomInvList(r)}, nil
+
+	case CmdGetData:
+		return &MsgGetData{InvList: randomInvList(r)}, nil
+
+	case CmdNotFound:
+		return &MsgNotFound{InvList: randomInvList(r)}, nil
+
+	case CmdBlock:
+		msg := NewMsgBlock(randomBlockHeader(r))
+		for _, tx := range randomTxList(r) {
+			msg.AddTransaction(tx)
+		}
+		return msg, nil
+
+	case CmdTx:
+		return randomMsgTx(r), nil
+
+	case CmdHeaders:
+		msg := NewMsgHeaders()
+		for i := r.Intn(randomMaxCount + 1); i > 0; i-- {
+			msg.AddBlockHeader(randomBlockHeader(r))
+		}
+		return msg, nil
+
+	case CmdPing:
+		return NewMsgPing(uint64(r.Int63())), nil
+
+	case CmdPong:
+		return NewMsgPong(uint64(r.Int63())), nil
+
+	case CmdAlert:
+		return &MsgAlert{
+			PayloadBlob: randomString(r),
+			Signature:   randomString(r),
+		}, nil
+
+	case CmdMemPool:
+		return &MsgMemPool{}, nil
+
+	case CmdGetXThin:
+		iv := randomInvVect(r)
+		return NewMsgGetXThin(iv, randomBytes(r),
+			uint32(r.Intn(MaxXThinFilterHashFuncs+1)), r.Uint32(),
+			uint8(r.Intn(256))), nil
+
+	case CmdXThinBlock:
+		msg := NewMsgXThinBlock(randomBlockHeader(r))
+		msg.TxHashes = randomCheapHashes(r)
+		msg.MissingTxs = randomTxList(r)
+		return msg, nil
+
+	case CmdXBlockTx:
+		hash := randomHash(r)
+		msg := NewMsgXBlockTx(&hash)
+		msg.Txs = randomTxList(r)
+		return msg, nil
+
+	case CmdGetXBlockTx:
+		hash := randomHash(r)
+		msg := NewMsgGetXBlockTx(&hash)
+		msg.TxHashes = randomCheapHashes(r)
+		return msg, nil
+
+	case CmdGetGrapheneBlock:
+		return NewMsgGetGrapheneBlock(randomInvVect(r),
+			uint64(r.Int63())), nil
+
+	case CmdGrapheneBlock:
+		msg := NewMsgGrapheneBlock(randomBlockHeader(r),
+			uint64(r.Intn(maxTxPerBlock+1)))
+		msg.Filter = randomBytes(r)
+		msg.HashFuncs = uint32(r.Intn(MaxXThinFilterHashFuncs + 1))
+		msg.Tweak = r.Uint32()
+		msg.Flags = uint8(r.Intn(256))
+		msg.IBLT = randomIBLT(r)
+		msg.AdditionalTxs = randomTxList(r)
+		return msg, nil
+
+	case CmdGetGrapheneBlockTx:
+		hash := randomHash(r)
+		msg := NewMsgGetGrapheneBlockTx(&hash)
+		msg.TxHashes = randomCheapHashes(r)
+		return msg, nil
+
+	case CmdGrapheneBlockTx:
+		hash := randomHash(r)
+		msg := NewMsgGrapheneBlockTx(&hash)
+		msg.Txs = randomTxList(r)
+		return msg, nil
+	}
+
+	msg, err := NewMsgUnknown(command, randomBytes(r))
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// randomBytes returns a slice of up to randomMaxBytes random bytes.  The slice
+// is never nil so it is the same as a decoded one.
+func randomBytes(r *rand.Rand) []byte {
+	b := make([]byte, r.Intn(randomMaxBytes+1))
+	r.Read(b)
+	return b
+}
+
+// randomString returns a string of up to randomMaxBytes random printable ASCII
+// characters.
+func randomString(r *rand.Rand) string {
+	b := make([]byte, r.Intn(randomMaxBytes+1))
+	for i := range b {
+		b[i] = byte(' ' + r.Intn('~'-' '+1))
+	}
+	return string(b)
+}
+
+// randomHash returns a random hash.
+func randomHash(r *rand.Rand) ShaHash {
+	var hash ShaHash
+	r.Read(hash[:])
+	return hash
+}
+
+// randomTimestamp returns a random time which is representable by the 32-bit
+// timestamps of block headers and addresses.
+func randomTimestamp(r *rand.Rand) time.Time {
+	return time.Unix(int64(r.Uint32()), 0)
+}
+
+// randomNetAddress returns a random address.  The ts flag indicates whether or
+// not the address is encoded with its timestamp, which is otherwise left zero
+// as it is when decoded.
+func randomNetAddress(r *rand.Rand, ts bool) *NetAddress {
+	ip := make(net.IP, net.IPv6len)
+	r.Read(ip)
+	na := NewNetAddressIPPort(ip, uint16(r.Intn(65536)),
+		ServiceFlag(r.Int63()))
+	na.Timestamp = time.Time{}
+	if ts {
+		na.Timestamp = randomTimestamp(r)
+	}
+	return na
+}
+
+// randomMsgVersion returns a random version message which is internally
+// consistent.
+func randomMsgVersion(r *rand.Rand) *MsgVersion {
+	me := randomNetAddress(r, false)
+	return &MsgVersion{
+		ProtocolVersion: r.Int31n(int32(ProtocolVersion)) + 1,
+		Services:        me.Services,
+		Timestamp:       time.Unix(r.Int63(), 0),
+		AddrYou:         *randomNetAddress(r, false),
+		AddrMe:          *me,
+		Nonce:           uint64(r.Int63()),
+		UserAgent:       randomString(r),
+		LastBlock:       r.Int31(),
+		DisableRelayTx:  r.Intn(2) == 0,
+	}
+}
+
+// randomInvVect returns a random inventory vector of a known type.
+func randomInvVect(r *rand.Rand) *InvVect {
+	hash := randomHash(r)
+	return NewInvVect(InvType(r.Intn(int(InvTypeBlock)+1)), &hash)
+}
+
+// randomInvList returns a list of up to randomMaxCount random inventory
+// vectors.
+func randomInvList(r *rand.Rand) []*InvVect {
+	invList := make([]*InvVect, r.Intn(randomMaxCount+1))
+	for i := range invList {
+		invList[i] = randomInvVect(r)
+	}
+	return invList
+}
+
+// randomBlockHeader returns a random block header without an auxiliary
+// proof-of-work or transactions.
+func randomBlockHeader(r *rand.Rand) *BlockHeader {
+	prevHash := randomHash(r)
+	merkleRoot := randomHash(r)
+	bh := NewBlockHeader(&prevHash, &merkleRoot, r.Uint32(), r.Uint32())
+	bh.Version = r.Uint32()
+	bh.Timestamp = randomTimestamp(r)
+	return bh
+}
+
+// randomMsgTx returns a random transaction with at least one input and one
+// output.
+func randomMsgTx(r *rand.Rand) *MsgTx {
+	tx := NewMsgTx()
+	tx.Version = r.Uint32()
+	tx.LockTime = r.Uint32()
+	for i := r.Intn(randomMaxCount) + 1; i > 0; i-- {
+		hash := randomHash(r)
+		ti := NewTxIn(NewOutPoint(&hash, r.Uint32()), randomBytes(r))
+		ti.Sequence = r.Uint32()
+		tx.AddTxIn(ti)
+	}
+	for i := r.Intn(randomMaxCount) + 1; i > 0; i-- {
+		tx.AddTxOut(NewTxOut(Amount(r.Int63()), randomBytes(r)))
+	}
+	return tx
+}
+
+// randomTxList returns a list of up to randomMaxCount random transactions.
+func randomTxList(r *rand.Rand) []*MsgTx {
+	txns := make([]*MsgTx, r.Intn(randomMaxCount+1))
+	for i := range txns {
+		txns[i] = randomMsgTx(r)
+	}
+	return txns
+}
+
+// randomCheapHashes returns a list of up to randomMaxCount random cheap
+// hashes.
+func randomCheapHashes(r *rand.Rand) []uint64 {
+	hashes := make([]uint64, r.Intn(randomMaxCount+1))
+	for i := range hashes {
+		hashes[i] = uint64(r.Int63())
+	}
+	return hashes
+}
+
+// randomIBLT returns a random invertible bloom lookup table with up to
+// randomMaxCount cells.
+func randomIBLT(r *rand.Rand) IBLT {
+	t := IBLT{
+		HashFuncs: uint8(r.Intn(256)),
+		Salt:      r.Uint32(),
+		Cells:     make([]IBLTCell, r.Intn(randomMaxCount+1)),
+	}
+	for i := range t.Cells {
+		valueSum := make([]byte, r.Intn(MaxIBLTValueSize+1))
+		r.Read(valueSum)
+		t.Cells[i] = IBLTCell{
+			Count:    r.Int31() - r.Int31(),
+			KeySum:   uint64(r.Int63()),
+			KeyCheck: r.Uint32(),
+			ValueSum: valueSum,
+		}
+	}
+	return t
+}
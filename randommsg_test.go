@@ -0,0 +1,97 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// TestRandomMessage ensures random messages are valid and survive being
+// encoded and decoded again.
+func TestRandomMessage(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	commands := []string{
+		btcwire.CmdVersion, btcwire.CmdVerAck, btcwire.CmdGetAddr,
+		btcwire.CmdAddr, btcwire.CmdGetBlocks, btcwire.CmdInv,
+		btcwire.CmdGetData, btcwire.CmdNotFound, btcwire.CmdBlock,
+		btcwire.CmdTx, btcwire.CmdGetHeaders, btcwire.CmdHeaders,
+		btcwire.CmdPing, btcwire.CmdPong, btcwire.CmdAlert,
+		btcwire.CmdMemPool, btcwire.CmdGetXThin, btcwire.CmdXThinBlock,
+		btcwire.CmdXBlockTx, btcwire.CmdGetXBlockTx,
+		btcwire.CmdGetGrapheneBlock, btcwire.CmdGrapheneBlock,
+		btcwire.CmdGetGrapheneBlockTx, btcwire.CmdGrapheneBlockTx,
+		"unknown",
+	}
+
+	// Use a fixed seed so failures are reproducible.
+	r := rand.New(rand.NewSource(1))
+	const iterations = 50
+	t.Logf("Running %d tests", len(commands)*iterations)
+	for _, cmd := range commands {
+		for i := 0; i < iterations; i++ {
+			msg, err := btcwire.RandomMessage(r, cmd)
+			if err != nil {
+				t.Errorf("RandomMessage (%s) error %v", cmd, err)
+				break
+			}
+			if msg.Command() != cmd {
+				t.Errorf("RandomMessage (%s) wrong command got: %s",
+					cmd, msg.Command())
+			}
+			if err := msg.Validate(); err != nil {
+				t.Errorf("Validate (%s) error %v", cmd, err)
+			}
+
+			var buf bytes.Buffer
+			if err := msg.BtcEncode(&buf, pver); err != nil {
+				t.Errorf("BtcEncode (%s) error %v", cmd, err)
+				continue
+			}
+			encoded := buf.Bytes()
+
+			// Decode into a new message of the same type, which must
+			// be equal to the original and encode the same way.
+			decoded := reflect.New(reflect.TypeOf(msg).Elem())
+			if cmd == "unknown" {
+				decoded = reflect.ValueOf(btcwire.MustNewMsgUnknown(cmd,
+					nil))
+			}
+			decodedMsg := decoded.Interface().(btcwire.Message)
+			err = decodedMsg.BtcDecode(bytes.NewReader(encoded), pver)
+			if err != nil {
+				t.Errorf("BtcDecode (%s) error %v\n%s", cmd, err,
+					spew.Sdump(msg))
+				continue
+			}
+			equal := reflect.ValueOf(msg).MethodByName("Equal")
+			if !equal.Call([]reflect.Value{decoded})[0].Bool() {
+				t.Errorf("BtcDecode (%s) not equal to original\n"+
+					"got: %s want: %s", cmd,
+					spew.Sdump(decodedMsg), spew.Sdump(msg))
+			}
+
+			buf.Reset()
+			if err := decodedMsg.BtcEncode(&buf, pver); err != nil {
+				t.Errorf("BtcEncode (%s) decoded error %v", cmd, err)
+				continue
+			}
+			if !bytes.Equal(buf.Bytes(), encoded) {
+				t.Errorf("BtcEncode (%s) wrong encoding after "+
+					"decoding\n got: %x want: %x", cmd, buf.Bytes(),
+					encoded)
+			}
+		}
+	}
+
+	// Invalid commands are rejected.
+	if _, err := btcwire.RandomMessage(r, "waytoolongcommand"); err == nil {
+		t.Errorf("RandomMessage: invalid command did not fail")
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"math"
+	"sync"
+)
+
+// maxRollingBloomHashFuncs is the maximum number of hash functions used by a
+// RollingBloomFilter.
+const maxRollingBloomHashFuncs = 50
+
+// rollingBloomHashMult is multiplied by the number of a hash function to derive
+// the seed of its murmur hash, the same as for the filters of BIP0037.
+const rollingBloomHashMult = 0xfba4c795
+
+// murmurHash3 returns the 32-bit x86 variant of the MurmurHash3 hash of data
+// with the provided seed.
+func murmurHash3(seed uint32, data []byte) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+
+	h1 := seed
+	nblocks := len(data) / 4
+	for i := 0; i < nblocks; i++ {
+		k1 := littleEndian.Uint32(data[i*4:])
+		k1 *= c1
+		k1 = k1<<15 | k1>>17
+		k1 *= c2
+
+		h1 ^= k1
+		h1 = h1<<13 | h1>>19
+		h1 = h1*5 + 0xe6546b64
+	}
+
+	tail := data[nblocks*4:]
+	var k1 uint32
+	switch len(tail) {
+	case 3:
+		k1 ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k1 ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k1 ^= uint32(tail[0])
+		k1 *= c1
+		k1 = k1<<15 | k1>>17
+		k1 *= c2
+		h1 ^= k1
+	}
+
+	h1 ^= uint32(len(data))
+	h1 ^= h1 >> 16
+	h1 *= 0x85ebca6b
+	h1 ^= h1 >> 13
+	h1 *= 0xc2b2ae35
+	h1 ^= h1 >> 16
+	return h1
+}
+
+// RollingBloomFilter is a probabilistic set of hashes, such as the transaction
+// hashes recently relayed to or received from peers, which remembers at least
+// the most recently added hashes up to its capacity and gradually forgets older
+// ones while using a fixed amount of memory.  It never reports a hash it
+// remembers as missing, but may report a hash which was never added, or was
+// forgotten, as present with roughly the false positive rate it was created
+// with.  It is safe for concurrent use.
+//
+// It is the same construction as the rolling bloom filter of the reference
+// implementation.  Hashes are added in generations of half the capacity, and
+// the oldest of three generations is cleared each time a new one starts, so
+// between one and one and a half times the capacity of the most recent hashes
+// are remembered.
+type RollingBloomFilter struct {
+	mtx            sync.Mutex
+	hashFuncs      uint32
+	entriesPerGen  uint32
+	entriesThisGen uint32
+	generation     uint32
+	tweak          uint32
+	data           []uint64
+}
+
+// NewRollingBloomFilter returns a new empty RollingBloomFilter which remembers
+// at least the last elements hashes added to it with the provided false
+// positive rate, such as 0.000001.  The false positive rate is clamped to a
+// range which requires between 1 and 50 hash functions.
+func NewRollingBloomFilter(elements uint32, fpRate float64) *RollingBloomFilter {
+	// The optimal number of hash functions is log(fpRate) / log(0.5),
+	// which is limited to between 1 and 50.
+	logFpRate := math.Log(fpRate)
+	hashFuncs := math.Floor(logFpRate/math.Log(0.5) + 0.5)
+	hashFuncs = math.Max(1, math.Min(hashFuncs, maxRollingBloomHashFuncs))
+
+	// Between two and three generations of half the elements are stored,
+	// so the number of bits is chosen such that the false positive rate
+	// holds with three full generations.
+	entriesPerGen := (elements + 1) / 2
+	maxElements := float64(entriesPerGen) * 3
+	filterBits := math.Ceil(-1 * hashFuncs * maxElements /
+		math.Log(1-math.Exp(logFpRate/hashFuncs)))
+
+	// Each position of the filter has two bits which hold the generation
+	// which set it, or zero when it is unset.  The two bits of position p
+	// are bit p&63 of the pair of integers starting at index (p>>6)*2.
+	words := (uint64(filterBits) + 63) / 64
+	bf := &RollingBloomFilter{
+		hashFuncs:     uint32(hashFuncs),
+		entriesPerGen: entriesPerGen,
+		data:          make([]uint64, words*2),
+	}
+	bf.reset()
+	return bf
+}
+
+// reset clears the filter and chooses a new random tweak for its hash
+// functions.  The filter must be locked or not yet shared.
+func (bf *RollingBloomFilter) reset() {
+	tweak, err := RandomUint64()
+	if err != nil {
+		tweak = uint64(now().UnixNano())
+	}
+	bf.tweak = uint32(tweak)
+	bf.entriesThisGen = 0
+	bf.generation = 1
+	for i := range bf.data {
+		bf.data[i] = 0
+	}
+}
+
+// position returns the index of the first integer of the pair holding the
+// position selected by the provided hash function for hash along with the bit
+// within them.
+func (bf *RollingBloomFilter) position(hashNum uint32, hash *ShaHash) (int, uint) {
+	h := murmurHash3(hashNum*rollingBloomHashMult+bf.tweak, hash[:])
+
+	// The upper bits of the hash select the pair, scaled to the size of
+	// the filter without a modulo, while the lower bits select the bit.
+	pos := (uint64(h) * uint64(len(bf.data))) >> 32
+	return int(pos &^ 1), uint(h & 0x3f)
+}
+
+// Add adds the provided hash to the filter.
+func (bf *RollingBloomFilter) Add(hash *ShaHash) {
+	bf.mtx.Lock()
+	defer bf.mtx.Unlock()
+
+	// Start a new generation once the current one is full, which clears
+	// the positions set by the oldest generation since it reuses its
+	// number.
+	if bf.entriesThisGen == bf.entriesPerGen {
+		bf.entriesThisGen = 0
+		bf.generation++
+		if bf.generation == 4 {
+			bf.generation = 1
+		}
+		mask1 := -uint64(bf.generation & 1)
+		mask2 := -uint64(bf.generation >> 1)
+		for i := 0; i < len(bf.data); i += 2 {
+			p1, p2 := bf.data[i], bf.data[i+1]
+			mask := (p1 ^ mask1) | (p2 ^ mask2)
+			bf.data[i] = p1 & mask
+			bf.data[i+1] = p2 & mask
+		}
+	}
+	bf.entriesThisGen++
+
+	gen1 := uint64(bf.generation & 1)
+	gen2 := uint64(bf.generation >> 1)
+	for n := uint32(0); n < bf.hashFuncs; n++ {
+		i, bit := bf.position(n, hash)
+		bf.data[i] = bf.data[i]&^(1<<bit) | gen1<<bit
+		bf.data[i+1] = bf.data[i+1]&^(1<<bit) | gen2<<bit
+	}
+}
+
+// Contains returns whether the provided hash is in the filter.  False
+// positives are possible, but false negatives are not for the hashes the
+// filter remembers.
+func (bf *RollingBloomFilter) Contains(hash *ShaHash) bool {
+	bf.mtx.Lock()
+	defer bf.mtx.Unlock()
+
+	for n := uint32(0); n < bf.hashFuncs; n++ {
+		i, bit := bf.position(n, hash)
+		if (bf.data[i]|bf.data[i+1])>>bit&1 == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Reset removes every hash from the filter.
+func (bf *RollingBloomFilter) Reset() {
+	bf.mtx.Lock()
+	bf.reset()
+	bf.mtx.Unlock()
+}
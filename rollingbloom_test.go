@@ -0,0 +1,112 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/conformal/btcwire"
+	"testing"
+)
+
+// TestMurmurHash3 ensures the murmur hash used by rolling bloom filters
+// matches the test vectors of the reference implementation.
+func TestMurmurHash3(t *testing.T) {
+	tests := []struct {
+		seed uint32 // Seed of the hash
+		data string // Hex encoded data to hash
+		want uint32 // Expected hash
+	}{
+		{0x00000000, "", 0x00000000},
+		{0xfba4c795, "", 0x6a396f08},
+		{0xffffffff, "", 0x81f16f39},
+		{0x00000000, "00", 0x514e28b7},
+		{0xfba4c795, "00", 0xea3f0b17},
+		{0x00000000, "ff", 0xfd6cf10d},
+		{0x00000000, "0011", 0x16c6b7ab},
+		{0x00000000, "001122", 0x8eb51c3d},
+		{0x00000000, "00112233", 0xb4471bf8},
+		{0x00000000, "0011223344", 0xe2301fa8},
+		{0x00000000, "001122334455", 0xfc2e4a15},
+		{0x00000000, "00112233445566", 0xb074502c},
+		{0x00000000, "0011223344556677", 0x8034d2a0},
+		{0x00000000, "001122334455667788", 0xb4698def},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		data, err := hex.DecodeString(test.data)
+		if err != nil {
+			t.Errorf("DecodeString #%d: unexpected error %v", i, err)
+			continue
+		}
+		got := btcwire.TstMurmurHash3(test.seed, data)
+		if got != test.want {
+			t.Errorf("TstMurmurHash3 #%d: got %08x, want %08x", i,
+				got, test.want)
+		}
+	}
+}
+
+// rollingBloomHash returns a distinct hash for the provided number.
+func rollingBloomHash(n uint32) *btcwire.ShaHash {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], n)
+	hash := btcwire.ShaHash(sha256.Sum256(buf[:]))
+	return &hash
+}
+
+// TestRollingBloomFilter ensures a RollingBloomFilter remembers the most
+// recently added hashes and forgets older ones.
+func TestRollingBloomFilter(t *testing.T) {
+	const elements = 100
+	bf := btcwire.NewRollingBloomFilter(elements, 0.000001)
+
+	tests := []struct {
+		name   string
+		add    uint32 // Number of hashes added before checking
+		from   uint32 // First hash to check
+		to     uint32 // End of the hashes to check
+		want   bool   // Expected result of Contains
+		maxBad int    // Maximum number of unexpected results
+	}{
+		{"not yet added", 0, 0, elements, false, 0},
+		{"added", elements, 0, elements, true, 0},
+		{"never added", 0, elements, elements * 10, false, 1},
+		{"still remembered", elements / 2, 0, elements * 3 / 2, true, 0},
+		{"forgotten", elements * 2, 0, elements, false, 1},
+		{"recent", 0, elements * 5 / 2, elements * 7 / 2, true, 0},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	added := uint32(0)
+	for _, test := range tests {
+		for i := uint32(0); i < test.add; i++ {
+			bf.Add(rollingBloomHash(added))
+			added++
+		}
+
+		bad := 0
+		for n := test.from; n < test.to; n++ {
+			if bf.Contains(rollingBloomHash(n)) != test.want {
+				bad++
+			}
+		}
+		if bad > test.maxBad {
+			t.Errorf("%s: %d of %d hashes with Contains not %v",
+				test.name, bad, test.to-test.from, test.want)
+		}
+	}
+
+	// Ensure resetting the filter removes every hash.
+	bf.Reset()
+	for n := uint32(0); n < added; n++ {
+		if bf.Contains(rollingBloomHash(n)) {
+			t.Errorf("Reset: hash %d still present", n)
+			break
+		}
+	}
+}
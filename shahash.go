@@ -5,9 +5,9 @@
 package btcwire
 
 import (
-	"bytes"
 	"encoding/hex"
 	"fmt"
+	"sort"
 )
 
 // Size of array used to store sha hashes.  See ShaHash.
@@ -20,30 +20,81 @@ const MaxHashStringSize = HashSize * 2
 // string that has too many characters.
 var ErrHashStrSize = fmt.Errorf("max hash length is %v chars", MaxHashStringSize)
 
+// ErrHashStrLen describes an error that indicates the caller specified a hash
+// string to NewShaHashFromStrStrict that does not have exactly
+// MaxHashStringSize characters.
+var ErrHashStrLen = fmt.Errorf("hash length must be exactly %v chars",
+	MaxHashStringSize)
+
+// ZeroHash is the ShaHash with every byte zero, which is used as a sentinel
+// value in the protocol, such as the previous outpoint hash of coinbase
+// transactions, the previous block of genesis blocks, and the stop hash of
+// getblocks and getheaders messages which request as many hashes as allowed.
+// It must not be modified.
+var ZeroHash ShaHash
+
 // ShaHash is used in several of the bitcoin messages and common structures.  It
 // typically represents the double sha256 of data.
+//
+// Since it is a fixed size array, a ShaHash is a value type which may be
+// copied by assignment, compared with ==, and used directly as a map key
+// without any allocations.
+//
+// The bytes of a ShaHash are stored in wire order, which is the order they are
+// produced by sha256 and serialized in messages.  Hashes are conventionally
+// displayed as big-endian numbers, so the display order used by String, block
+// explorers, and RPC interfaces is the reverse of the wire order.  Bytes,
+// SetBytes, and NewShaHash deal with wire order, while DisplayBytes,
+// SetDisplayBytes, and NewShaHashFromDisplayBytes deal with display order.
 type ShaHash [HashSize]byte
 
-// String returns the ShaHash in the standard bitcoin big-endian form.
+// String returns the ShaHash in the standard bitcoin big-endian form, which is
+// the hex encoding of its bytes in display order.
 func (hash ShaHash) String() string {
-	hashstr := ""
-	for i := range hash {
-		hashstr += fmt.Sprintf("%02x", hash[HashSize-1-i])
+	for i := 0; i < HashSize/2; i++ {
+		hash[i], hash[HashSize-1-i] = hash[HashSize-1-i], hash[i]
 	}
+	return hex.EncodeToString(hash[:])
+}
 
-	return hashstr
+// GoString returns the ShaHash as a Go expression which evaluates to it in
+// terms of MustNewShaHashFromStr.  This is part of the fmt.GoStringer interface
+// implementation.
+func (hash ShaHash) GoString() string {
+	return fmt.Sprintf("*%sMustNewShaHashFromStr(%q)", goStringPkg,
+		hash.String())
 }
 
-// Bytes returns the bytes which represent the hash as a byte slice.
-func (hash *ShaHash) Bytes() []byte {
+// CloneBytes returns a copy of the bytes which represent the hash as a byte
+// slice in wire order.  Modifying the returned slice does not modify the hash.
+func (hash *ShaHash) CloneBytes() []byte {
 	newHash := make([]byte, HashSize)
 	copy(newHash, hash[:])
 
 	return newHash
 }
 
-// SetBytes sets the bytes which represent the hash.  An error is returned if
-// the number of bytes passed in is not HashSize.
+// Bytes returns a copy of the bytes which represent the hash as a byte slice in
+// wire order.  It is equivalent to CloneBytes.
+func (hash *ShaHash) Bytes() []byte {
+	return hash.CloneBytes()
+}
+
+// DisplayBytes returns a copy of the bytes which represent the hash as a byte
+// slice in display order, which is the reverse of wire order.  The hex
+// encoding of the returned bytes is the same as String.
+func (hash *ShaHash) DisplayBytes() []byte {
+	newHash := make([]byte, HashSize)
+	for i := range hash {
+		newHash[i] = hash[HashSize-1-i]
+	}
+
+	return newHash
+}
+
+// SetBytes sets the bytes which represent the hash from a byte slice in wire
+// order.  An error is returned if the number of bytes passed in is not
+// HashSize.
 func (hash *ShaHash) SetBytes(newHash []byte) error {
 	nhlen := len(newHash)
 	if nhlen != HashSize {
@@ -55,13 +106,97 @@ func (hash *ShaHash) SetBytes(newHash []byte) error {
 	return nil
 }
 
-// IsEqual returns true if target is the same as hash.
+// SetDisplayBytes sets the bytes which represent the hash from a byte slice in
+// display order, which is the reverse of wire order.  An error is returned if
+// the number of bytes passed in is not HashSize.
+func (hash *ShaHash) SetDisplayBytes(newHash []byte) error {
+	nhlen := len(newHash)
+	if nhlen != HashSize {
+		return fmt.Errorf("ShaHash: invalid sha length of %v, want %v",
+			nhlen, HashSize)
+	}
+	for i := range hash {
+		hash[i] = newHash[HashSize-1-i]
+	}
+
+	return nil
+}
+
+// IsEqual returns true if target is the same as hash.  Two nil hashes are
+// considered equal, while a nil hash is not equal to any other.
 func (hash *ShaHash) IsEqual(target *ShaHash) bool {
-	return bytes.Equal(hash[:], target[:])
+	if hash == nil || target == nil {
+		return hash == target
+	}
+	return *hash == *target
 }
 
-// NewShaHash returns a new ShaHash from a byte slice.  An error is returned if
-// the number of bytes passed in is not HashSize.
+// IsZero returns whether every byte of the hash is zero, which is the same as
+// comparing it to ZeroHash.
+func (hash *ShaHash) IsZero() bool {
+	return *hash == ZeroHash
+}
+
+// hashListEqual returns whether the provided lists contain the same hashes in
+// the same order.
+func hashListEqual(a, b []*ShaHash) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, hash := range a {
+		if !hash.IsEqual(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Compare returns -1, 0, or 1 depending on whether hash is less than, equal
+// to, or greater than target when both are interpreted as the big-endian
+// numbers they represent.  This is the same order as their string forms, so
+// sorted hashes appear in the expected order when printed.
+func (hash *ShaHash) Compare(target *ShaHash) int {
+	for i := HashSize - 1; i >= 0; i-- {
+		switch {
+		case hash[i] < target[i]:
+			return -1
+		case hash[i] > target[i]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// ShaHashes attaches the methods of sort.Interface to a slice of hashes,
+// sorting in increasing order as defined by ShaHash.Compare.
+type ShaHashes []ShaHash
+
+// Len returns the number of hashes in the slice.  This is part of the
+// sort.Interface implementation.
+func (s ShaHashes) Len() int {
+	return len(s)
+}
+
+// Less returns whether the hash with index i sorts before the hash with index
+// j.  This is part of the sort.Interface implementation.
+func (s ShaHashes) Less(i, j int) bool {
+	return s[i].Compare(&s[j]) < 0
+}
+
+// Swap swaps the hashes with indexes i and j.  This is part of the
+// sort.Interface implementation.
+func (s ShaHashes) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// SortHashes sorts the provided hashes in place in increasing order as defined
+// by ShaHash.Compare.
+func SortHashes(hashes []ShaHash) {
+	sort.Sort(ShaHashes(hashes))
+}
+
+// NewShaHash returns a new ShaHash from a byte slice in wire order.  An error
+// is returned if the number of bytes passed in is not HashSize.
 func NewShaHash(newHash []byte) (*ShaHash, error) {
 	var sh ShaHash
 	err := sh.SetBytes(newHash)
@@ -71,8 +206,23 @@ func NewShaHash(newHash []byte) (*ShaHash, error) {
 	return &sh, err
 }
 
+// NewShaHashFromDisplayBytes returns a new ShaHash from a byte slice in display
+// order, which is the reverse of wire order.  An error is returned if the
+// number of bytes passed in is not HashSize.
+func NewShaHashFromDisplayBytes(newHash []byte) (*ShaHash, error) {
+	var sh ShaHash
+	err := sh.SetDisplayBytes(newHash)
+	if err != nil {
+		return nil, err
+	}
+	return &sh, nil
+}
+
 // NewShaHashFromStr converts a hash string in the standard bitcoin big-endian
-// form to a ShaHash (which is little-endian).
+// form to a ShaHash (which is little-endian).  Strings shorter than
+// MaxHashStringSize are treated as if they had leading zeros, so a truncated
+// hash is silently accepted as a different one.  Use NewShaHashFromStrStrict
+// when the string is expected to be a complete hash.
 func NewShaHashFromStr(hash string) (*ShaHash, error) {
 	// Return error if hash string is too long.
 	if len(hash) > MaxHashStringSize {
@@ -108,3 +258,40 @@ func NewShaHashFromStr(hash string) (*ShaHash, error) {
 	// Create the sha hash using the byte slice and return it.
 	return NewShaHash(pbuf)
 }
+
+// NewShaHashFromStrStrict converts a hash string in the standard bitcoin
+// big-endian form to a ShaHash (which is little-endian) in the same manner as
+// NewShaHashFromStr, except the string must consist of exactly
+// MaxHashStringSize hex characters.  ErrHashStrLen is returned for strings of
+// any other length rather than padding them with zeros.
+func NewShaHashFromStrStrict(hash string) (*ShaHash, error) {
+	if len(hash) != MaxHashStringSize {
+		return nil, ErrHashStrLen
+	}
+
+	var sh ShaHash
+	_, err := hex.Decode(sh[:], []byte(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	// The string was given in big-endian, so reverse the bytes to little
+	// endian.
+	for i := 0; i < HashSize/2; i++ {
+		sh[i], sh[HashSize-1-i] = sh[HashSize-1-i], sh[i]
+	}
+	return &sh, nil
+}
+
+// MustNewShaHashFromStr converts a hash string in the standard bitcoin
+// big-endian form to a ShaHash in the same manner as NewShaHashFromStr, except
+// it panics if the string is not a valid hash.  It is intended for hashes
+// which are known to be valid, such as those hard coded in tests or produced
+// by GoString.
+func MustNewShaHashFromStr(hash string) *ShaHash {
+	sha, err := NewShaHashFromStr(hash)
+	if err != nil {
+		panic(fmt.Sprintf("MustNewShaHashFromStr(%q): %v", hash, err))
+	}
+	return sha
+}
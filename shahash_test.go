@@ -15,10 +15,10 @@ import (
 func TestShaHash(t *testing.T) {
 
 	// Hash of block 234439.
-	blockHashStr := "14a0810ac680a3eb3f82edc878cea25ec41d6b790744e5daeef"
-	blockHash, err := btcwire.NewShaHashFromStr(blockHashStr)
+	blockHashStr := "000000000000014a0810ac680a3eb3f82edc878cea25ec41d6b790744e5daeef"
+	blockHash, err := btcwire.NewShaHashFromStrStrict(blockHashStr)
 	if err != nil {
-		t.Errorf("NewShaHashFromStr: %v", err)
+		t.Errorf("NewShaHashFromStrStrict: %v", err)
 	}
 
 	// Hash of block 234440 as byte slice.
@@ -62,6 +62,27 @@ func TestShaHash(t *testing.T) {
 			hash, blockHash)
 	}
 
+	// Ensure CloneBytes returns a copy which doesn't alias the hash.
+	cloned := hash.CloneBytes()
+	if !bytes.Equal(cloned, hash[:]) {
+		t.Errorf("CloneBytes: hash contents mismatch - got: %x, want: %x",
+			cloned, hash[:])
+	}
+	cloned[0] ^= 0xff
+	if hash[0] == cloned[0] {
+		t.Errorf("CloneBytes: modifying the returned slice modified " +
+			"the hash")
+	}
+
+	// Ensure nil hashes are only equal to each other.
+	var nilHash *btcwire.ShaHash
+	if hash.IsEqual(nil) || nilHash.IsEqual(hash) {
+		t.Errorf("IsEqual: nil hash should not match %v", hash)
+	}
+	if !nilHash.IsEqual(nil) {
+		t.Errorf("IsEqual: nil hashes should match")
+	}
+
 	// Invalid size for SetBytes.
 	err = hash.SetBytes([]byte{0x00})
 	if err == nil {
@@ -76,6 +97,155 @@ func TestShaHash(t *testing.T) {
 	}
 }
 
+// TestShaHashValue ensures a ShaHash behaves as a value type which may be used
+// as a map key and compared without allocations.
+func TestShaHashValue(t *testing.T) {
+	hash := btcwire.GenesisHash
+	other := hash
+	other[0] ^= 0xff
+
+	seen := map[btcwire.ShaHash]int{hash: 1}
+	seen[other] = 2
+	if len(seen) != 2 || seen[btcwire.GenesisHash] != 1 {
+		t.Errorf("map key: wrong contents %v", seen)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if hash.IsEqual(&other) {
+			t.Errorf("IsEqual: hash contents should not match")
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("IsEqual: got %v allocations, want 0", allocs)
+	}
+}
+
+// TestShaHashIsZero ensures only the all-zero hash is reported as zero and
+// checking for it does not allocate.
+func TestShaHashIsZero(t *testing.T) {
+	last := btcwire.ShaHash{}
+	last[btcwire.HashSize-1] = 0x01
+
+	tests := []struct {
+		hash *btcwire.ShaHash
+		want bool
+	}{
+		{&btcwire.ZeroHash, true},
+		{&btcwire.ShaHash{}, true},
+		{&btcwire.GenesisBlock.Header.PrevBlock, true},
+		{&btcwire.GenesisHash, false},
+		{btcwire.MustNewShaHashFromStr("01"), false},
+		{&last, false},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		if got := test.hash.IsZero(); got != test.want {
+			t.Errorf("IsZero #%d (%v) got: %v want: %v", i,
+				test.hash, got, test.want)
+		}
+	}
+
+	hash := btcwire.GenesisHash
+	allocs := testing.AllocsPerRun(100, func() {
+		if hash.IsZero() {
+			t.Errorf("IsZero: hash should not be zero")
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("IsZero: got %v allocations, want 0", allocs)
+	}
+}
+
+// TestShaHashCompare tests comparing and sorting hashes.
+func TestShaHashCompare(t *testing.T) {
+	low := btcwire.MustNewShaHashFromStr("01")
+	mid := btcwire.MustNewShaHashFromStr("ff00")
+	high := btcwire.MustNewShaHashFromStr("0100")
+
+	tests := []struct {
+		a, b *btcwire.ShaHash
+		want int
+	}{
+		{low, low, 0},
+		{low, mid, -1},
+		{mid, low, 1},
+		{mid, high, 1},
+		{high, mid, -1},
+		{&btcwire.GenesisHash, &btcwire.ShaHash{}, 1},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		if got := test.a.Compare(test.b); got != test.want {
+			t.Errorf("Compare #%d (%v, %v) got: %d want: %d", i,
+				test.a, test.b, got, test.want)
+		}
+	}
+
+	// Ensure sorted hashes are in the same order as their strings.
+	hashes := []btcwire.ShaHash{*mid, btcwire.GenesisHash, *low, *high,
+		*low}
+	btcwire.SortHashes(hashes)
+	for i := 1; i < len(hashes); i++ {
+		if hashes[i-1].String() > hashes[i].String() {
+			t.Errorf("SortHashes: hashes out of order %v", hashes)
+			break
+		}
+	}
+}
+
+// TestShaHashByteOrder ensures the wire order and display order byte methods
+// are the reverse of each other and consistent with String.
+func TestShaHashByteOrder(t *testing.T) {
+	hash := btcwire.GenesisHash
+	wire := hash.Bytes()
+	display := hash.DisplayBytes()
+
+	if !bytes.Equal(wire, hash[:]) {
+		t.Errorf("Bytes: wrong order - got: %x, want: %x", wire, hash[:])
+	}
+	if hex.EncodeToString(display) != hash.String() {
+		t.Errorf("DisplayBytes: wrong order - got: %x, want: %v",
+			display, hash)
+	}
+	for i := range wire {
+		if wire[i] != display[btcwire.HashSize-1-i] {
+			t.Fatalf("DisplayBytes: not the reverse of Bytes - "+
+				"got: %x, want reverse of: %x", display, wire)
+		}
+	}
+
+	// Ensure the display order bytes round trip.
+	got, err := btcwire.NewShaHashFromDisplayBytes(display)
+	if err != nil {
+		t.Fatalf("NewShaHashFromDisplayBytes: %v", err)
+	}
+	if !got.IsEqual(&hash) {
+		t.Errorf("NewShaHashFromDisplayBytes: got: %v, want: %v", got,
+			hash)
+	}
+	var set btcwire.ShaHash
+	err = set.SetDisplayBytes(display)
+	if err != nil {
+		t.Fatalf("SetDisplayBytes: %v", err)
+	}
+	if !set.IsEqual(&hash) {
+		t.Errorf("SetDisplayBytes: got: %v, want: %v", set, hash)
+	}
+
+	// Invalid size for SetDisplayBytes and NewShaHashFromDisplayBytes.
+	if err := set.SetDisplayBytes(display[1:]); err == nil {
+		t.Errorf("SetDisplayBytes: failed to received expected err - " +
+			"got: nil")
+	}
+	_, err = btcwire.NewShaHashFromDisplayBytes(nil)
+	if err == nil {
+		t.Errorf("NewShaHashFromDisplayBytes: failed to received " +
+			"expected err - got: nil")
+	}
+}
+
 // TestShaHashString  tests the stringized output for sha hashes.
 func TestShaHashString(t *testing.T) {
 	// Block 100000 hash.
@@ -172,3 +342,73 @@ func TestNewShaHashFromStr(t *testing.T) {
 		}
 	}
 }
+
+// TestNewShaHashFromStrStrict executes tests against the
+// NewShaHashFromStrStrict function.
+func TestNewShaHashFromStrStrict(t *testing.T) {
+	tests := []struct {
+		in   string
+		want btcwire.ShaHash
+		err  error
+	}{
+		// Genesis hash.
+		{
+			"000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+			btcwire.GenesisHash,
+			nil,
+		},
+
+		// Genesis hash with stripped leading zeros.
+		{
+			"19d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+			btcwire.ShaHash{},
+			btcwire.ErrHashStrLen,
+		},
+
+		// Genesis hash truncated to 61 characters.
+		{
+			"000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce",
+			btcwire.ShaHash{},
+			btcwire.ErrHashStrLen,
+		},
+
+		// Empty hash string.
+		{
+			"",
+			btcwire.ShaHash{},
+			btcwire.ErrHashStrLen,
+		},
+
+		// Hash string that is too long.
+		{
+			"000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f0",
+			btcwire.ShaHash{},
+			btcwire.ErrHashStrLen,
+		},
+
+		// Hash string that is contains non-hex chars.
+		{
+			"000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26g",
+			btcwire.ShaHash{},
+			hex.InvalidByteError('g'),
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		result, err := btcwire.NewShaHashFromStrStrict(test.in)
+		if err != test.err {
+			t.Errorf("NewShaHashFromStrStrict #%d failed to detect "+
+				"expected error - got: %v want: %v", i, err, test.err)
+			continue
+		} else if err != nil {
+			// Got expected error. Move on to the next test.
+			continue
+		}
+		if !test.want.IsEqual(result) {
+			t.Errorf("NewShaHashFromStrStrict #%d got: %v want: %v", i,
+				result, &test.want)
+			continue
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"github.com/conformal/btcwire"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// updateSnapshots causes TestWireSnapshots to rewrite the stored snapshots
+// with the current encodings instead of comparing against them.  It must only
+// be used when an encoding change is intentional.
+var updateSnapshots = flag.Bool("update-snapshots", false,
+	"rewrite the wire encoding snapshots in testdata")
+
+// snapshotFile is the file which houses the stored wire encoding snapshots.
+var snapshotFile = filepath.Join("testdata", "snapshots.txt")
+
+// snapshotPvers are the protocol versions every message is encoded at.  They
+// include every version which changed the encoding of any message along with
+// the version immediately prior to it.  Duplicates are ignored.
+var snapshotPvers = []uint32{
+	0,
+	btcwire.MultipleAddressVersion - 1,
+	btcwire.MultipleAddressVersion,
+	btcwire.NetAddressTimeVersion - 1,
+	btcwire.NetAddressTimeVersion,
+	btcwire.BIP0031Version,
+	btcwire.BIP0031Version + 1,
+	btcwire.BIP0035Version - 1,
+	btcwire.BIP0035Version,
+	btcwire.BIP0037Version,
+	btcwire.ProtocolVersion,
+}
+
+// snapshotMessages returns a deterministic instance of every message type with
+// all of its fields populated.
+func snapshotMessages() []btcwire.Message {
+	hash := btcwire.GenesisHash
+	na := &btcwire.NetAddress{
+		Timestamp: time.Unix(0x495fab29, 0),
+		Services:  btcwire.SFNodeNetwork,
+		IP:        net.ParseIP("127.0.0.1"),
+		Port:      8333,
+	}
+
+	version, _ := btcwire.NewMsgVersion(na, na, 123123,
+		"/btcwiretest:0.0.1/", 234234)
+	version.Timestamp = time.Unix(0x495fab29, 0)
+
+	addr := btcwire.NewMsgAddr()
+	addr.AddAddress(na)
+
+	getBlocks := btcwire.NewMsgGetBlocks(&hash)
+	getBlocks.AddBlockLocatorHash(&hash)
+
+	getHeaders := btcwire.NewMsgGetHeaders()
+	getHeaders.AddBlockLocatorHash(&hash)
+	getHeaders.HashStop = hash
+
+	iv := btcwire.NewInvVect(btcwire.InvTypeBlock, &hash)
+	inv := btcwire.NewMsgInv()
+	inv.AddInvVect(iv)
+	getData := btcwire.NewMsgGetData()
+	getData.AddInvVect(iv)
+	notFound := btcwire.NewMsgNotFound()
+	notFound.AddInvVect(iv)
+
+	header := blockOne.Header
+	header.TxnCount = 0
+	headers := btcwire.NewMsgHeaders()
+	headers.AddBlockHeader(&header)
+
+	alert, _ := btcwire.NewMsgAlert("payload", "signature")
+
+	return []btcwire.Message{
+		version,
+		btcwire.NewMsgVerAck(),
+		btcwire.NewMsgGetAddr(),
+		addr,
+		getBlocks,
+		getHeaders,
+		inv,
+		getData,
+		notFound,
+		&blockOne,
+		headers,
+		multiTx,
+		btcwire.NewMsgPing(0x1122334455667788),
+		btcwire.NewMsgPong(0x1122334455667788),
+		alert,
+		btcwire.NewMsgMemPool(),
+	}
+}
+
+// snapshotLines returns the current snapshot line for every message at every
+// protocol version.  Each line consists of the command, the protocol version,
+// and either the hex encoded payload, "-" for an empty payload, or the error
+// returned when encoding.
+func snapshotLines() []string {
+	var lines []string
+	for _, msg := range snapshotMessages() {
+		seen := make(map[uint32]bool)
+		for _, pver := range snapshotPvers {
+			if seen[pver] {
+				continue
+			}
+			seen[pver] = true
+
+			var buf bytes.Buffer
+			result := "-"
+			err := msg.BtcEncode(&buf, pver)
+			if err != nil {
+				result = "error: " + err.Error()
+			} else if buf.Len() > 0 {
+				result = hex.EncodeToString(buf.Bytes())
+			}
+			line := fmt.Sprintf("%s %d %s", msg.Command(), pver,
+				result)
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// TestWireSnapshots ensures the wire encoding of every message at every
+// protocol version matches the stored snapshots so encodings can't change
+// unintentionally.  Run the tests with -update-snapshots to rewrite the stored
+// snapshots after an intentional change.
+func TestWireSnapshots(t *testing.T) {
+	lines := snapshotLines()
+	if *updateSnapshots {
+		data := strings.Join(lines, "\n") + "\n"
+		err := ioutil.WriteFile(snapshotFile, []byte(data), 0644)
+		if err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		return
+	}
+
+	f, err := os.Open(snapshotFile)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+
+	var want []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		want = append(want, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+
+	if len(lines) != len(want) {
+		t.Errorf("TestWireSnapshots: wrong number of snapshots - got "+
+			"%d, want %d", len(lines), len(want))
+	}
+	for i := 0; i < len(lines) && i < len(want); i++ {
+		if lines[i] != want[i] {
+			t.Errorf("TestWireSnapshots: encoding changed\n got: "+
+				"%s\nwant: %s", lines[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+// splitCounts returns the number of messages required to hold total entries
+// when each message is limited to max entries.
+func splitCounts(total, max int) int {
+	return (total + max - 1) / max
+}
+
+// SplitInvList splits an arbitrarily long list of inventory vectors into as
+// many inv messages (MsgInv) as needed so that none of them exceed
+// MaxInvPerMsg.  The order of the inventory vectors is preserved.  No messages
+// are returned for an empty list.
+func SplitInvList(invList []*InvVect) []*MsgInv {
+	msgs := make([]*MsgInv, 0, splitCounts(len(invList), MaxInvPerMsg))
+	for len(invList) > 0 {
+		n := len(invList)
+		if n > MaxInvPerMsg {
+			n = MaxInvPerMsg
+		}
+		msg := &MsgInv{InvList: make([]*InvVect, n)}
+		copy(msg.InvList, invList[:n])
+		msgs = append(msgs, msg)
+		invList = invList[n:]
+	}
+	return msgs
+}
+
+// SplitAddrList splits an arbitrarily long list of addresses into as many addr
+// messages (MsgAddr) as needed so that none of them exceed MaxAddrPerMsg.  The
+// order of the addresses is preserved.  No messages are returned for an empty
+// list.
+func SplitAddrList(addrList []*NetAddress) []*MsgAddr {
+	msgs := make([]*MsgAddr, 0, splitCounts(len(addrList), MaxAddrPerMsg))
+	for len(addrList) > 0 {
+		n := len(addrList)
+		if n > MaxAddrPerMsg {
+			n = MaxAddrPerMsg
+		}
+		msg := &MsgAddr{AddrList: make([]*NetAddress, n)}
+		copy(msg.AddrList, addrList[:n])
+		msgs = append(msgs, msg)
+		addrList = addrList[n:]
+	}
+	return msgs
+}
+
+// SplitHeaders splits an arbitrarily long list of block headers into as many
+// headers messages (MsgHeaders) as needed so that none of them exceed
+// MaxBlockHeadersPerMsg.  The order of the headers is preserved.  No messages
+// are returned for an empty list.
+func SplitHeaders(headers []*BlockHeader) []*MsgHeaders {
+	msgs := make([]*MsgHeaders, 0, splitCounts(len(headers),
+		MaxBlockHeadersPerMsg))
+	for len(headers) > 0 {
+		n := len(headers)
+		if n > MaxBlockHeadersPerMsg {
+			n = MaxBlockHeadersPerMsg
+		}
+		msg := &MsgHeaders{Headers: make([]*BlockHeader, n)}
+		copy(msg.Headers, headers[:n])
+		msgs = append(msgs, msg)
+		headers = headers[n:]
+	}
+	return msgs
+}
+
+// GetDataBatchOptions houses the optional parameters for SplitGetData.  The
+// zero value provides sane defaults.
+type GetDataBatchOptions struct {
+	// BatchSize is the maximum number of inventory vectors to request in
+	// each getdata message.  It defaults to MaxInvPerMsg when zero and is
+	// limited to MaxInvPerMsg.
+	BatchSize int
+
+	// Interleave alternates block requests with requests for other types
+	// of data, such as transactions, rather than preserving the original
+	// order.  The relative order of the requests within each group is
+	// preserved.
+	Interleave bool
+}
+
+// interleaveInvList returns a new inventory list which alternates between the
+// block and non-block inventory vectors in invList.  Once one group runs out,
+// the remainder of the other group follows.
+func interleaveInvList(invList []*InvVect) []*InvVect {
+	blocks := make([]*InvVect, 0, len(invList))
+	others := make([]*InvVect, 0, len(invList))
+	for _, iv := range invList {
+		if iv.Type == InvTypeBlock {
+			blocks = append(blocks, iv)
+		} else {
+			others = append(others, iv)
+		}
+	}
+
+	merged := make([]*InvVect, 0, len(invList))
+	for len(blocks) > 0 || len(others) > 0 {
+		if len(blocks) > 0 {
+			merged = append(merged, blocks[0])
+			blocks = blocks[1:]
+		}
+		if len(others) > 0 {
+			merged = append(merged, others[0])
+			others = others[1:]
+		}
+	}
+	return merged
+}
+
+// SplitGetData converts an arbitrarily long list of wanted inventory into a
+// sequence of getdata messages (MsgGetData) with at most the configured batch
+// size per message.  A nil opts is treated the same as the zero value.  No
+// messages are returned for an empty list.
+func SplitGetData(invList []*InvVect, opts *GetDataBatchOptions) []*MsgGetData {
+	var o GetDataBatchOptions
+	if opts != nil {
+		o = *opts
+	}
+	batchSize := o.BatchSize
+	if batchSize <= 0 || batchSize > MaxInvPerMsg {
+		batchSize = MaxInvPerMsg
+	}
+	if o.Interleave {
+		invList = interleaveInvList(invList)
+	}
+
+	msgs := make([]*MsgGetData, 0, splitCounts(len(invList), batchSize))
+	for len(invList) > 0 {
+		n := len(invList)
+		if n > batchSize {
+			n = batchSize
+		}
+		msg := &MsgGetData{InvList: make([]*InvVect, n)}
+		copy(msg.InvList, invList[:n])
+		msgs = append(msgs, msg)
+		invList = invList[n:]
+	}
+	return msgs
+}
+
+// InvGroup is a group of inventory vectors which all have the same type.  See
+// GroupInvList.
+type InvGroup struct {
+	Type    InvType
+	InvList []*InvVect
+}
+
+// GroupInvList partitions a list of inventory vectors of mixed types into one
+// group per type, such as one for blocks and one for transactions.  The groups
+// are ordered by the first appearance of their type in the list and the order
+// of the inventory vectors within each group is preserved.  Every type,
+// including unknown ones, is given its own group.
+func GroupInvList(invList []*InvVect) []InvGroup {
+	var groups []InvGroup
+	index := make(map[InvType]int)
+	for _, iv := range invList {
+		i, ok := index[iv.Type]
+		if !ok {
+			i = len(groups)
+			index[iv.Type] = i
+			groups = append(groups, InvGroup{Type: iv.Type})
+		}
+		groups[i].InvList = append(groups[i].InvList, iv)
+	}
+	return groups
+}
+
+// SplitInvListByType splits an arbitrarily long list of inventory vectors of
+// mixed types into inv messages (MsgInv) which each only announce a single
+// type of inventory, as grouped by GroupInvList, and none of which exceed
+// MaxInvPerMsg.  No messages are returned for an empty list.
+func SplitInvListByType(invList []*InvVect) []*MsgInv {
+	var msgs []*MsgInv
+	for _, group := range GroupInvList(invList) {
+		msgs = append(msgs, SplitInvList(group.InvList)...)
+	}
+	return msgs
+}
+
+// SplitGetDataByType converts an arbitrarily long list of wanted inventory of
+// mixed types into getdata messages (MsgGetData) which each only request a
+// single type of inventory, as grouped by GroupInvList, with at most the
+// configured batch size per message.  A nil opts is treated the same as the
+// zero value.  The Interleave option has no effect since the types are never
+// mixed.  No messages are returned for an empty list.
+func SplitGetDataByType(invList []*InvVect, opts *GetDataBatchOptions) []*MsgGetData {
+	var msgs []*MsgGetData
+	for _, group := range GroupInvList(invList) {
+		msgs = append(msgs, SplitGetData(group.InvList, opts)...)
+	}
+	return msgs
+}
@@ -0,0 +1,306 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+// TestSplitInvList tests splitting inventory vectors into inv messages.
+func TestSplitInvList(t *testing.T) {
+	tests := []struct {
+		count int   // Number of inventory vectors
+		want  []int // Expected number of vectors per message
+	}{
+		{0, []int{}},
+		{1, []int{1}},
+		{btcwire.MaxInvPerMsg, []int{btcwire.MaxInvPerMsg}},
+		{btcwire.MaxInvPerMsg + 1, []int{btcwire.MaxInvPerMsg, 1}},
+		{btcwire.MaxInvPerMsg*2 + 5,
+			[]int{btcwire.MaxInvPerMsg, btcwire.MaxInvPerMsg, 5}},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		invList := make([]*btcwire.InvVect, test.count)
+		for j := range invList {
+			hash := btcwire.ShaHash{byte(j), byte(j >> 8), byte(j >> 16)}
+			invList[j] = btcwire.NewInvVect(btcwire.InvTypeTx, &hash)
+		}
+
+		msgs := btcwire.SplitInvList(invList)
+		if len(msgs) != len(test.want) {
+			t.Errorf("SplitInvList #%d wrong number of messages - "+
+				"got %d, want %d", i, len(msgs), len(test.want))
+			continue
+		}
+		next := 0
+		for j, msg := range msgs {
+			if len(msg.InvList) != test.want[j] {
+				t.Errorf("SplitInvList #%d message %d wrong "+
+					"count - got %d, want %d", i, j,
+					len(msg.InvList), test.want[j])
+				continue
+			}
+			for _, iv := range msg.InvList {
+				if iv != invList[next] {
+					t.Errorf("SplitInvList #%d message %d "+
+						"out of order", i, j)
+					break
+				}
+				next++
+			}
+
+			// Ensure the message can be encoded.
+			err := msg.BtcEncode(ioutil.Discard,
+				btcwire.ProtocolVersion)
+			if err != nil {
+				t.Errorf("SplitInvList #%d message %d encode "+
+					"error %v", i, j, err)
+			}
+		}
+	}
+}
+
+// TestSplitAddrList tests splitting addresses into addr messages.
+func TestSplitAddrList(t *testing.T) {
+	addrList := make([]*btcwire.NetAddress, btcwire.MaxAddrPerMsg*2+1)
+	for i := range addrList {
+		addrList[i] = &btcwire.NetAddress{Port: uint16(i)}
+	}
+
+	msgs := btcwire.SplitAddrList(addrList)
+	want := []int{btcwire.MaxAddrPerMsg, btcwire.MaxAddrPerMsg, 1}
+	if len(msgs) != len(want) {
+		t.Fatalf("SplitAddrList: wrong number of messages - got %d, "+
+			"want %d", len(msgs), len(want))
+	}
+	next := 0
+	for i, msg := range msgs {
+		if len(msg.AddrList) != want[i] {
+			t.Errorf("SplitAddrList: message %d wrong count - got "+
+				"%d, want %d", i, len(msg.AddrList), want[i])
+			continue
+		}
+		for _, na := range msg.AddrList {
+			if na != addrList[next] {
+				t.Errorf("SplitAddrList: message %d out of "+
+					"order", i)
+				break
+			}
+			next++
+		}
+		err := msg.BtcEncode(ioutil.Discard, btcwire.ProtocolVersion)
+		if err != nil {
+			t.Errorf("SplitAddrList: message %d encode error %v",
+				i, err)
+		}
+	}
+
+	if msgs := btcwire.SplitAddrList(nil); len(msgs) != 0 {
+		t.Errorf("SplitAddrList: got %d messages for empty list",
+			len(msgs))
+	}
+}
+
+// TestSplitHeaders tests splitting block headers into headers messages.
+func TestSplitHeaders(t *testing.T) {
+	headers := make([]*btcwire.BlockHeader, btcwire.MaxBlockHeadersPerMsg+7)
+	for i := range headers {
+		headers[i] = &btcwire.BlockHeader{Nonce: uint32(i)}
+	}
+
+	msgs := btcwire.SplitHeaders(headers)
+	want := []int{btcwire.MaxBlockHeadersPerMsg, 7}
+	if len(msgs) != len(want) {
+		t.Fatalf("SplitHeaders: wrong number of messages - got %d, "+
+			"want %d", len(msgs), len(want))
+	}
+	next := 0
+	for i, msg := range msgs {
+		if len(msg.Headers) != want[i] {
+			t.Errorf("SplitHeaders: message %d wrong count - got "+
+				"%d, want %d", i, len(msg.Headers), want[i])
+			continue
+		}
+		for _, bh := range msg.Headers {
+			if bh != headers[next] {
+				t.Errorf("SplitHeaders: message %d out of "+
+					"order", i)
+				break
+			}
+			next++
+		}
+		err := msg.BtcEncode(ioutil.Discard, btcwire.ProtocolVersion)
+		if err != nil {
+			t.Errorf("SplitHeaders: message %d encode error %v",
+				i, err)
+		}
+	}
+}
+
+// TestSplitGetData tests splitting wanted inventory into getdata messages.
+func TestSplitGetData(t *testing.T) {
+	// Build a list of 5 block requests followed by 3 tx requests.
+	invList := make([]*btcwire.InvVect, 0, 8)
+	for i := 0; i < 5; i++ {
+		hash := btcwire.ShaHash{byte(i)}
+		iv := btcwire.NewInvVect(btcwire.InvTypeBlock, &hash)
+		invList = append(invList, iv)
+	}
+	for i := 0; i < 3; i++ {
+		hash := btcwire.ShaHash{byte(i + 5)}
+		iv := btcwire.NewInvVect(btcwire.InvTypeTx, &hash)
+		invList = append(invList, iv)
+	}
+	interleaved := []*btcwire.InvVect{
+		invList[0], invList[5], invList[1], invList[6], invList[2],
+		invList[7], invList[3], invList[4],
+	}
+
+	tests := []struct {
+		opts  *btcwire.GetDataBatchOptions // Batch options
+		sizes []int                        // Expected message sizes
+		order []*btcwire.InvVect           // Expected request order
+	}{
+		// Defaults fit everything in one message.
+		{nil, []int{8}, invList},
+
+		// Batch size larger than the max is limited.
+		{
+			&btcwire.GetDataBatchOptions{
+				BatchSize: btcwire.MaxInvPerMsg + 1,
+			},
+			[]int{8},
+			invList,
+		},
+
+		// Custom batch size.
+		{
+			&btcwire.GetDataBatchOptions{BatchSize: 3},
+			[]int{3, 3, 2},
+			invList,
+		},
+
+		// Interleaved blocks and transactions.
+		{
+			&btcwire.GetDataBatchOptions{
+				BatchSize:  4,
+				Interleave: true,
+			},
+			[]int{4, 4},
+			interleaved,
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		msgs := btcwire.SplitGetData(invList, test.opts)
+		if len(msgs) != len(test.sizes) {
+			t.Errorf("SplitGetData #%d wrong number of messages - "+
+				"got %d, want %d", i, len(msgs), len(test.sizes))
+			continue
+		}
+
+		var got []*btcwire.InvVect
+		for j, msg := range msgs {
+			if len(msg.InvList) != test.sizes[j] {
+				t.Errorf("SplitGetData #%d message %d wrong "+
+					"count - got %d, want %d", i, j,
+					len(msg.InvList), test.sizes[j])
+			}
+			got = append(got, msg.InvList...)
+		}
+		if !reflect.DeepEqual(got, test.order) {
+			t.Errorf("SplitGetData #%d wrong order\n got: %s "+
+				"want: %s", i, spew.Sdump(got),
+				spew.Sdump(test.order))
+		}
+	}
+
+	if msgs := btcwire.SplitGetData(nil, nil); len(msgs) != 0 {
+		t.Errorf("SplitGetData: got %d messages for empty list",
+			len(msgs))
+	}
+}
+
+// TestSplitByType tests grouping mixed inventory vectors by type and splitting
+// the groups into inv and getdata messages.
+func TestSplitByType(t *testing.T) {
+	// Build a list which alternates between blocks, transactions, and an
+	// unknown type.
+	types := []btcwire.InvType{btcwire.InvTypeTx, btcwire.InvTypeBlock,
+		btcwire.InvType(3)}
+	invList := make([]*btcwire.InvVect, 0, 9)
+	for i := 0; i < 9; i++ {
+		hash := btcwire.ShaHash{byte(i)}
+		iv := btcwire.NewInvVect(types[i%len(types)], &hash)
+		invList = append(invList, iv)
+	}
+	wantGroups := []btcwire.InvGroup{
+		{btcwire.InvTypeTx, []*btcwire.InvVect{invList[0], invList[3],
+			invList[6]}},
+		{btcwire.InvTypeBlock, []*btcwire.InvVect{invList[1], invList[4],
+			invList[7]}},
+		{btcwire.InvType(3), []*btcwire.InvVect{invList[2], invList[5],
+			invList[8]}},
+	}
+
+	groups := btcwire.GroupInvList(invList)
+	if !reflect.DeepEqual(groups, wantGroups) {
+		t.Errorf("GroupInvList: wrong groups\n got: %s want: %s",
+			spew.Sdump(groups), spew.Sdump(wantGroups))
+	}
+	if groups := btcwire.GroupInvList(nil); len(groups) != 0 {
+		t.Errorf("GroupInvList: got %d groups for empty list",
+			len(groups))
+	}
+
+	tests := []struct {
+		batchSize int                  // Batch size for getdata messages
+		want      [][]*btcwire.InvVect // Expected getdata messages
+	}{
+		{0, [][]*btcwire.InvVect{wantGroups[0].InvList,
+			wantGroups[1].InvList, wantGroups[2].InvList}},
+		{2, [][]*btcwire.InvVect{
+			wantGroups[0].InvList[:2], wantGroups[0].InvList[2:],
+			wantGroups[1].InvList[:2], wantGroups[1].InvList[2:],
+			wantGroups[2].InvList[:2], wantGroups[2].InvList[2:],
+		}},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		opts := &btcwire.GetDataBatchOptions{BatchSize: test.batchSize}
+		msgs := btcwire.SplitGetDataByType(invList, opts)
+		got := make([][]*btcwire.InvVect, len(msgs))
+		for j, msg := range msgs {
+			got[j] = msg.InvList
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("SplitGetDataByType #%d wrong messages\n "+
+				"got: %s want: %s", i, spew.Sdump(got),
+				spew.Sdump(test.want))
+		}
+	}
+
+	msgs := btcwire.SplitInvListByType(invList)
+	if len(msgs) != len(wantGroups) {
+		t.Fatalf("SplitInvListByType: wrong number of messages - "+
+			"got %d, want %d", len(msgs), len(wantGroups))
+	}
+	for i, msg := range msgs {
+		if !reflect.DeepEqual(msg.InvList, wantGroups[i].InvList) {
+			t.Errorf("SplitInvListByType: message %d wrong "+
+				"inventory\n got: %s want: %s", i,
+				spew.Sdump(msg.InvList),
+				spew.Sdump(wantGroups[i].InvList))
+		}
+	}
+}
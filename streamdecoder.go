@@ -0,0 +1,181 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"bytes"
+	"time"
+)
+
+// DecodedMessage describes a message decoded by a StreamDecoder.
+type DecodedMessage struct {
+	// Timestamp is the time the data containing the start of the message
+	// was captured.
+	Timestamp time.Time
+
+	// Command is the command from the message header.  It is set even when
+	// the message failed to decode.
+	Command string
+
+	// Msg is the decoded message.  It is nil when Err is set.
+	Msg Message
+
+	// Payload is the raw payload of the message.
+	Payload []byte
+
+	// Err is the error which caused the message to fail to decode, if any.
+	Err error
+}
+
+// streamSegment records when the data starting at an offset in the buffer of a
+// StreamDecoder was captured.
+type streamSegment struct {
+	offset    int
+	timestamp time.Time
+}
+
+// StreamDecoder decodes the sequence of messages sent in one direction of a
+// connection from timestamped chunks of the TCP byte stream, such as those
+// reassembled from a packet capture.  It is intended for protocol debugging
+// and regression analysis rather than for use with live peers.
+//
+// Unlike ReadMessage, messages which fail to decode do not stop the decoder.
+// Since the header of every message indicates the length of its payload, the
+// decoder is able to skip the failed message and continue with the next one.
+// The only exception is a header which indicates a payload larger than the
+// maximum possible message payload, since that almost certainly means the
+// stream is not positioned at the start of a message and there is no reliable
+// way to find the next one.
+type StreamDecoder struct {
+	pver     uint32
+	btcnet   BitcoinNet
+	opts     *ReadOptions
+	buf      []byte
+	segments []streamSegment
+	err      error
+}
+
+// NewStreamDecoder returns a new StreamDecoder which decodes messages for the
+// provided protocol version and bitcoin network using the provided read
+// options.  A nil opts is treated the same as the zero value.
+func NewStreamDecoder(pver uint32, btcnet BitcoinNet, opts *ReadOptions) *StreamDecoder {
+	return &StreamDecoder{
+		pver:   pver,
+		btcnet: btcnet,
+		opts:   opts,
+	}
+}
+
+// Feed adds data captured at the provided time to the stream and returns all
+// of the messages which are now complete in the order they were sent.  Partial
+// messages are buffered until the rest of their data is fed.
+//
+// An error is only returned when the stream can no longer be decoded, in which
+// case all further calls return the same error.  Errors decoding individual
+// messages are reported via the Err field of the returned messages instead.
+func (d *StreamDecoder) Feed(timestamp time.Time, data []byte) ([]*DecodedMessage, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	d.segments = append(d.segments, streamSegment{len(d.buf), timestamp})
+	d.buf = append(d.buf, data...)
+
+	var msgs []*DecodedMessage
+	consumed := 0
+	for len(d.buf)-consumed >= MessageHeaderSize {
+		raw := d.buf[consumed:]
+
+		// The stream is unrecoverable when the header indicates a
+		// payload larger than any message can be.  Use ReadMessage to
+		// produce the error so it is the same one a live connection
+		// would have seen.
+		plen := littleEndian.Uint32(raw[16:20])
+		if plen > MaxMessagePayload {
+			_, _, d.err = readMessage(bytes.NewReader(raw), d.pver,
+				d.btcnet, nil, d.opts)
+			break
+		}
+		msgLen := MessageHeaderSize + int(plen)
+		if len(raw) < msgLen {
+			break
+		}
+		raw = raw[:msgLen]
+
+		msg, payload, err := readMessage(bytes.NewReader(raw), d.pver,
+			d.btcnet, nil, d.opts)
+		if err != nil {
+			payload = make([]byte, plen)
+			copy(payload, raw[MessageHeaderSize:])
+		}
+		command := bytes.TrimRight(raw[4:4+commandSize], "\x00")
+		msgs = append(msgs, &DecodedMessage{
+			Timestamp: d.timestampAt(consumed),
+			Command:   string(command),
+			Msg:       msg,
+			Payload:   payload,
+			Err:       err,
+		})
+		consumed += msgLen
+	}
+
+	d.consume(consumed)
+	return msgs, d.err
+}
+
+// Buffered returns the number of bytes which have been fed to the decoder, but
+// do not yet form a complete message.  A non-zero value at the end of a capture
+// indicates the capture ended in the middle of a message.
+func (d *StreamDecoder) Buffered() int {
+	return len(d.buf)
+}
+
+// timestampAt returns the time the data at the provided offset in the buffer
+// was captured.
+func (d *StreamDecoder) timestampAt(offset int) time.Time {
+	ts := d.segments[0].timestamp
+	for _, seg := range d.segments[1:] {
+		if seg.offset > offset {
+			break
+		}
+		ts = seg.timestamp
+	}
+	return ts
+}
+
+// consume removes n bytes from the front of the buffer along with the
+// timestamps of the segments which no longer have any data in it.
+func (d *StreamDecoder) consume(n int) {
+	if n == 0 {
+		return
+	}
+
+	// Keep the timestamp of the segment the remaining data starts in.
+	first := 0
+	for i, seg := range d.segments {
+		if seg.offset > n {
+			break
+		}
+		first = i
+	}
+	segments := d.segments[:0]
+	for _, seg := range d.segments[first:] {
+		seg.offset -= n
+		if seg.offset < 0 {
+			seg.offset = 0
+		}
+		segments = append(segments, seg)
+	}
+	d.segments = segments
+
+	remaining := len(d.buf) - n
+	copy(d.buf, d.buf[n:])
+	d.buf = d.buf[:remaining]
+	if remaining == 0 {
+		d.segments = d.segments[:0]
+	}
+}
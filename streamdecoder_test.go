@@ -0,0 +1,123 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestStreamDecoder tests decoding messages from timestamped chunks of a
+// stream.
+func TestStreamDecoder(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcnet := btcwire.MainNet
+
+	// Build a stream of a verack, a ping with a bad checksum, and a pong.
+	var stream bytes.Buffer
+	msgs := []btcwire.Message{
+		btcwire.NewMsgVerAck(),
+		btcwire.NewMsgPing(123),
+		btcwire.NewMsgPong(456),
+	}
+	var offsets []int
+	for _, msg := range msgs {
+		offsets = append(offsets, stream.Len())
+		err := btcwire.WriteMessage(&stream, msg, pver, btcnet)
+		if err != nil {
+			t.Fatalf("WriteMessage: %v", err)
+		}
+	}
+	raw := stream.Bytes()
+	raw[offsets[1]+20] ^= 0xff
+
+	// Feed the stream in chunks which split both the ping and the pong
+	// across two chunks.
+	base := time.Unix(1389000000, 0)
+	chunks := []struct {
+		data []byte    // Data to feed
+		ts   time.Time // Capture time of the data
+		cmds []string  // Expected commands completed by the chunk
+	}{
+		{raw[:offsets[1]+10], base, []string{"verack"}},
+		{raw[offsets[1]+10 : offsets[2]+5], base.Add(time.Second),
+			[]string{"ping"}},
+		{raw[offsets[2]+5:], base.Add(2 * time.Second),
+			[]string{"pong"}},
+	}
+
+	d := btcwire.NewStreamDecoder(pver, btcnet, nil)
+	var decoded []*btcwire.DecodedMessage
+	for i, chunk := range chunks {
+		got, err := d.Feed(chunk.ts, chunk.data)
+		if err != nil {
+			t.Fatalf("Feed #%d error %v", i, err)
+		}
+		var cmds []string
+		for _, dm := range got {
+			cmds = append(cmds, dm.Command)
+		}
+		if !reflect.DeepEqual(cmds, chunk.cmds) {
+			t.Errorf("Feed #%d wrong commands - got %v, want %v", i,
+				cmds, chunk.cmds)
+		}
+		decoded = append(decoded, got...)
+	}
+	if d.Buffered() != 0 {
+		t.Errorf("Buffered: unexpected leftover data - got %d bytes",
+			d.Buffered())
+	}
+	if len(decoded) != 3 {
+		t.Fatalf("Feed: wrong number of messages - got %d, want 3",
+			len(decoded))
+	}
+
+	// The ping started in the first chunk and the pong in the second, so
+	// they must have those timestamps.
+	wantTimes := []time.Time{base, base, base.Add(time.Second)}
+	for i, dm := range decoded {
+		if !dm.Timestamp.Equal(wantTimes[i]) {
+			t.Errorf("Feed message #%d wrong timestamp - got %v, "+
+				"want %v", i, dm.Timestamp, wantTimes[i])
+		}
+	}
+
+	// The ping has a bad checksum, so it must have an error along with its
+	// payload while the others must have decoded.
+	if merr, ok := decoded[1].Err.(*btcwire.MessageError); !ok ||
+		merr.Category != btcwire.ErrCategoryChecksum {
+		t.Errorf("Feed: wrong error for corrupt ping - got %v",
+			decoded[1].Err)
+	}
+	if len(decoded[1].Payload) != 8 {
+		t.Errorf("Feed: wrong payload length for corrupt ping - got %d, "+
+			"want 8", len(decoded[1].Payload))
+	}
+	for _, i := range []int{0, 2} {
+		if decoded[i].Err != nil {
+			t.Errorf("Feed message #%d error %v", i, decoded[i].Err)
+			continue
+		}
+		if !reflect.DeepEqual(decoded[i].Msg, msgs[i]) {
+			t.Errorf("Feed message #%d\n got: %s want: %s", i,
+				spew.Sdump(decoded[i].Msg), spew.Sdump(msgs[i]))
+		}
+	}
+
+	// A header which indicates a payload larger than any message stops the
+	// decoder permanently.
+	bogus := makeHeader(btcnet, "block", btcwire.MaxMessagePayload+1, 0)
+	if _, err := d.Feed(base, bogus); err == nil {
+		t.Errorf("Feed: did not receive error for oversized payload")
+	}
+	if _, err := d.Feed(base, raw); err == nil {
+		t.Errorf("Feed: did not receive error after unrecoverable " +
+			"stream")
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"fmt"
+)
+
+// BaseSubsidy is the subsidy of the blocks before the first halving, which is
+// 50 bitcoin.
+const BaseSubsidy Amount = 50 * satoshiPerBitcoin
+
+// subsidyHalvingIntervals houses the number of blocks between the halvings of
+// the block subsidy for each network which follows the bitcoin subsidy
+// schedule apart from its interval.
+var subsidyHalvingIntervals = map[BitcoinNet]int32{
+	MainNet:          210000,
+	TestNet:          150,
+	TestNet3:         210000,
+	LitecoinMainNet:  840000,
+	LitecoinTestNet4: 840000,
+	NamecoinMainNet:  210000,
+}
+
+// SubsidyHalvingInterval returns the number of blocks between the halvings of
+// the block subsidy on the provided network, which is 210000 on the main
+// network and 150 on the regression test network.  An error is returned for
+// networks with a different subsidy schedule, such as Dogecoin, or which are
+// unknown.
+func SubsidyHalvingInterval(btcnet BitcoinNet) (int32, error) {
+	interval, ok := subsidyHalvingIntervals[btcnet]
+	if !ok {
+		str := fmt.Sprintf("no subsidy schedule for network [%v]",
+			btcnet)
+		return 0, messageError("SubsidyHalvingInterval", str)
+	}
+	return interval, nil
+}
+
+// BlockSubsidy returns the subsidy of the block at the provided height on the
+// provided network, which is the amount its coinbase transaction may create in
+// addition to the fees of its other transactions.  The subsidy starts at
+// BaseSubsidy and halves, rounding down to a whole satoshi, every
+// SubsidyHalvingInterval blocks until it reaches zero.
+//
+// An error is returned for negative heights and for the networks
+// SubsidyHalvingInterval does not know.
+func BlockSubsidy(height int32, btcnet BitcoinNet) (Amount, error) {
+	if height < 0 {
+		str := fmt.Sprintf("negative block height [%d]", height)
+		return 0, messageError("BlockSubsidy", str)
+	}
+	interval, err := SubsidyHalvingInterval(btcnet)
+	if err != nil {
+		return 0, err
+	}
+
+	// The subsidy is zero long before 64 halvings, but shifting by 64 or
+	// more is not well defined in every language, so the reference
+	// implementation makes it explicit.
+	halvings := uint(height / interval)
+	if halvings >= 64 {
+		return 0, nil
+	}
+	return BaseSubsidy >> halvings, nil
+}
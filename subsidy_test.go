@@ -0,0 +1,85 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"github.com/conformal/btcwire"
+	"math"
+	"testing"
+)
+
+// TestBlockSubsidy ensures the block subsidy halves on the schedule of each
+// network.
+func TestBlockSubsidy(t *testing.T) {
+	tests := []struct {
+		height int32              // Height of the block
+		btcnet btcwire.BitcoinNet // Network of the block
+		want   btcwire.Amount     // Expected subsidy
+		err    bool               // Whether an error is expected
+	}{
+		{0, btcwire.MainNet, 5000000000, false},
+		{209999, btcwire.MainNet, 5000000000, false},
+		{210000, btcwire.MainNet, 2500000000, false},
+		{420000, btcwire.MainNet, 1250000000, false},
+		{630000, btcwire.MainNet, 625000000, false},
+		{840000, btcwire.MainNet, 312500000, false},
+		{210000 * 32, btcwire.MainNet, 1, false},
+		{210000 * 33, btcwire.MainNet, 0, false},
+		{210000 * 64, btcwire.MainNet, 0, false},
+		{math.MaxInt32, btcwire.MainNet, 0, false},
+		{210000, btcwire.TestNet3, 2500000000, false},
+		{149, btcwire.TestNet, 5000000000, false},
+		{150, btcwire.TestNet, 2500000000, false},
+		{150 * 64, btcwire.TestNet, 0, false},
+		{210000, btcwire.LitecoinMainNet, 5000000000, false},
+		{840000, btcwire.LitecoinMainNet, 2500000000, false},
+		{210000, btcwire.NamecoinMainNet, 2500000000, false},
+		{-1, btcwire.MainNet, 0, true},
+		{0, btcwire.DogecoinMainNet, 0, true},
+		{0, 0, 0, true},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		got, err := btcwire.BlockSubsidy(test.height, test.btcnet)
+		if (err != nil) != test.err {
+			t.Errorf("BlockSubsidy #%d unexpected error - got: %v "+
+				"want error: %v", i, err, test.err)
+			continue
+		}
+		if test.err {
+			if _, ok := err.(*btcwire.MessageError); !ok {
+				t.Errorf("BlockSubsidy #%d wrong error type %T",
+					i, err)
+			}
+			continue
+		}
+		if got != test.want {
+			t.Errorf("BlockSubsidy #%d got: %v want: %v", i, got,
+				test.want)
+		}
+	}
+
+	// Ensure the subsidies of every block on the main network add up to
+	// the well known total of just under 21 million bitcoin.
+	interval, err := btcwire.SubsidyHalvingInterval(btcwire.MainNet)
+	if err != nil {
+		t.Fatalf("SubsidyHalvingInterval: unexpected error %v", err)
+	}
+	var total btcwire.Amount
+	for height := int32(0); ; height += interval {
+		subsidy, err := btcwire.BlockSubsidy(height, btcwire.MainNet)
+		if err != nil {
+			t.Fatalf("BlockSubsidy: unexpected error %v", err)
+		}
+		if subsidy == 0 {
+			break
+		}
+		total += subsidy * btcwire.Amount(interval)
+	}
+	if want := btcwire.Amount(2099999997690000); total != want {
+		t.Errorf("total subsidy got: %v want: %v", total, want)
+	}
+}
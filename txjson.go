@@ -0,0 +1,142 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math"
+)
+
+// satoshiPerBitcoin is the number of satoshi, the unit of transaction output
+// values, in one bitcoin.
+const satoshiPerBitcoin = 1e8
+
+// DecodedScript is a script as rendered in the JSON produced by
+// MsgTx.ToDecodedJSON.
+type DecodedScript struct {
+	Hex string `json:"hex"`
+}
+
+// DecodedTxIn is a transaction input as rendered in the JSON produced by
+// MsgTx.ToDecodedJSON.  The input of a coinbase transaction only has the
+// Coinbase and Sequence fields set, while all other inputs have every field
+// except Coinbase set.
+type DecodedTxIn struct {
+	Coinbase  string         `json:"coinbase,omitempty"`
+	Txid      string         `json:"txid,omitempty"`
+	Vout      *uint32        `json:"vout,omitempty"`
+	ScriptSig *DecodedScript `json:"scriptSig,omitempty"`
+	Sequence  uint32         `json:"sequence"`
+}
+
+// DecodedTxOut is a transaction output as rendered in the JSON produced by
+// MsgTx.ToDecodedJSON.  The value is in bitcoin rather than satoshi.
+type DecodedTxOut struct {
+	Value        json.Number   `json:"value"`
+	N            uint32        `json:"n"`
+	ScriptPubKey DecodedScript `json:"scriptPubKey"`
+}
+
+// DecodedTx is a transaction in the structure of the decoderawtransaction RPC
+// of the reference implementation.  See MsgTx.ToDecodedJSON.
+type DecodedTx struct {
+	Txid     string         `json:"txid"`
+	Hash     string         `json:"hash"`
+	Version  uint32         `json:"version"`
+	Size     int            `json:"size"`
+	Vsize    int            `json:"vsize"`
+	Weight   int            `json:"weight"`
+	LockTime uint32         `json:"locktime"`
+	Vin      []DecodedTxIn  `json:"vin"`
+	Vout     []DecodedTxOut `json:"vout"`
+}
+
+// formatBitcoinValue returns the provided value in satoshi as a decimal number
+// of bitcoin with exactly eight fractional digits, such as 0.50000000, which is
+// how the reference implementation renders values in JSON.
+func formatBitcoinValue(value int64) json.Number {
+	return json.Number(Amount(value).Format(AmountBTC))
+}
+
+// isCoinBaseTx returns whether the transaction is a coinbase transaction, which
+// has a single input spending the null previous outpoint.
+func isCoinBaseTx(msg *MsgTx) bool {
+	if len(msg.TxIn) != 1 {
+		return false
+	}
+	prevOut := &msg.TxIn[0].PreviousOutpoint
+	return prevOut.Index == math.MaxUint32 && prevOut.Hash.IsZero()
+}
+
+// ToDecoded returns the transaction in the structure of the
+// decoderawtransaction RPC of the reference implementation.  See
+// ToDecodedJSON.
+func (msg *MsgTx) ToDecoded() (*DecodedTx, error) {
+	txSha, err := msg.TxSha()
+	if err != nil {
+		return nil, err
+	}
+	size := msg.SerializeSize(0)
+	dtx := &DecodedTx{
+		Txid:     txSha.String(),
+		Hash:     txSha.String(),
+		Version:  msg.Version,
+		Size:     size,
+		Vsize:    size,
+		Weight:   size * 4,
+		LockTime: msg.LockTime,
+		Vin:      make([]DecodedTxIn, 0, len(msg.TxIn)),
+		Vout:     make([]DecodedTxOut, 0, len(msg.TxOut)),
+	}
+
+	coinbase := isCoinBaseTx(msg)
+	for _, ti := range msg.TxIn {
+		if coinbase {
+			dtx.Vin = append(dtx.Vin, DecodedTxIn{
+				Coinbase: hex.EncodeToString(ti.SignatureScript),
+				Sequence: ti.Sequence,
+			})
+			continue
+		}
+		vout := ti.PreviousOutpoint.Index
+		dtx.Vin = append(dtx.Vin, DecodedTxIn{
+			Txid: ti.PreviousOutpoint.Hash.String(),
+			Vout: &vout,
+			ScriptSig: &DecodedScript{
+				Hex: hex.EncodeToString(ti.SignatureScript),
+			},
+			Sequence: ti.Sequence,
+		})
+	}
+	for i, to := range msg.TxOut {
+		dtx.Vout = append(dtx.Vout, DecodedTxOut{
+			Value: formatBitcoinValue(to.Value),
+			N:     uint32(i),
+			ScriptPubKey: DecodedScript{
+				Hex: hex.EncodeToString(to.PkScript),
+			},
+		})
+	}
+	return dtx, nil
+}
+
+// ToDecodedJSON returns the transaction as JSON in the same structure as the
+// decoderawtransaction RPC of the reference implementation, with its txid,
+// size, vin, and vout fields, so explorers and debugging tools get familiar
+// output directly from decoded transactions.  Output values are in bitcoin
+// with eight fractional digits.
+//
+// Scripts are only rendered as hex since this package does not disassemble
+// them, so the asm, type, and address fields are omitted.  This package does
+// not support witness data either, so the hash is always the same as the txid
+// and the virtual size is the same as the size.
+func (msg *MsgTx) ToDecodedJSON() ([]byte, error) {
+	dtx, err := msg.ToDecoded()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(dtx)
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"github.com/conformal/btcwire"
+	"math"
+	"testing"
+)
+
+// TestTxToDecodedJSON tests rendering transactions in the structure of the
+// decoderawtransaction RPC.
+func TestTxToDecodedJSON(t *testing.T) {
+	// Transaction spending an output of the block one coinbase.
+	prevHash, _ := blockOne.Transactions[0].TxSha()
+	spend := btcwire.NewMsgTx()
+	spend.Version = 2
+	spend.LockTime = 500000
+	spend.AddTxIn(btcwire.NewTxIn(btcwire.NewOutPoint(&prevHash, 0),
+		[]byte{0x51}))
+	spend.TxIn[0].Sequence = 0xfffffffe
+	spend.AddTxOut(btcwire.NewTxOut(1, []byte{0x6a}))
+	spend.AddTxOut(btcwire.NewTxOut(2099999997690000, nil))
+	spendSha, _ := spend.TxSha()
+
+	tests := []struct {
+		in   *btcwire.MsgTx // Transaction to render
+		want string         // Expected JSON
+	}{
+		// Block one coinbase as rendered by the reference
+		// implementation, less the script assembly and type.
+		{
+			blockOne.Transactions[0],
+			`{"txid":"0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098",` +
+				`"hash":"0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098",` +
+				`"version":1,"size":134,"vsize":134,"weight":536,"locktime":0,` +
+				`"vin":[{"coinbase":"04ffff001d0104","sequence":4294967295}],` +
+				`"vout":[{"value":50.00000000,"n":0,"scriptPubKey":{"hex":` +
+				`"410496b538e853519c726a2c91e61ec11600ae1390813a627c66fb8be7947be63c52da7589379515d4e0a604f8141781e62294721166bf621e73a82cbf2342c858eeac"}}]}`,
+		},
+
+		// Transaction with a regular input and multiple outputs.
+		{
+			spend,
+			`{"txid":"` + spendSha.String() + `",` +
+				`"hash":"` + spendSha.String() + `",` +
+				`"version":2,"size":71,"vsize":71,"weight":284,"locktime":500000,` +
+				`"vin":[{"txid":"0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098",` +
+				`"vout":0,"scriptSig":{"hex":"51"},"sequence":4294967294}],` +
+				`"vout":[{"value":0.00000001,"n":0,"scriptPubKey":{"hex":"6a"}},` +
+				`{"value":20999999.97690000,"n":1,"scriptPubKey":{"hex":""}}]}`,
+		},
+
+		// Transaction without inputs or outputs.
+		{
+			&btcwire.MsgTx{Version: 1},
+			`{"txid":"d21633ba23f70118185227be58a63527675641ad37967e2aa461559f577aec43",` +
+				`"hash":"d21633ba23f70118185227be58a63527675641ad37967e2aa461559f577aec43",` +
+				`"version":1,"size":10,"vsize":10,"weight":40,"locktime":0,` +
+				`"vin":[],"vout":[]}`,
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		got, err := test.in.ToDecodedJSON()
+		if err != nil {
+			t.Errorf("ToDecodedJSON #%d error %v", i, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("ToDecodedJSON #%d\n got: %s\nwant: %s", i, got,
+				test.want)
+		}
+	}
+}
+
+// TestTxToDecodedValues tests rendering output values in bitcoin.
+func TestTxToDecodedValues(t *testing.T) {
+	tests := []struct {
+		value btcwire.Amount // Output value
+		want  string         // Expected value in bitcoin
+	}{
+		{0, "0.00000000"},
+		{1, "0.00000001"},
+		{100000000, "1.00000000"},
+		{123456789, "1.23456789"},
+		{-1, "-0.00000001"},
+		{-150000000, "-1.50000000"},
+		{math.MaxInt64, "92233720368.54775807"},
+		{math.MinInt64, "-92233720368.54775808"},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		tx := btcwire.NewMsgTx()
+		tx.AddTxOut(btcwire.NewTxOut(test.value, nil))
+		dtx, err := tx.ToDecoded()
+		if err != nil {
+			t.Errorf("ToDecoded #%d error %v", i, err)
+			continue
+		}
+		if got := dtx.Vout[0].Value.String(); got != test.want {
+			t.Errorf("ToDecoded #%d got: %s, want: %s", i, got,
+				test.want)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// TxScanFields selects which fields of the transactions of a block are
+// decoded by a TxScanner.  Fields which are not selected are skipped over
+// without being decoded.
+type TxScanFields uint8
+
+const (
+	// ScanPrevOuts decodes the previous outpoints of transaction inputs
+	// into the PrevOuts field of ScannedTx.
+	ScanPrevOuts TxScanFields = 1 << iota
+
+	// ScanInputs decodes the complete transaction inputs into the TxIn
+	// field of ScannedTx.
+	ScanInputs
+
+	// ScanOutputs decodes the transaction outputs into the TxOut field of
+	// ScannedTx.
+	ScanOutputs
+)
+
+// ScannedTx is a transaction decoded by a TxScanner.  Only the fields selected
+// when the scanner was created are set.
+type ScannedTx struct {
+	// Index is the index of the transaction within the block.
+	Index int
+
+	// Sha is the hash of the transaction.  It is always set.
+	Sha ShaHash
+
+	// Version and LockTime are the version and lock time of the
+	// transaction.  They are always set.
+	Version  uint32
+	LockTime uint32
+
+	// PrevOuts are the previous outpoints of the transaction inputs.  They
+	// are only set with ScanPrevOuts.
+	PrevOuts []OutPoint
+
+	// TxIn are the transaction inputs.  They are only set with ScanInputs.
+	TxIn []*TxIn
+
+	// TxOut are the transaction outputs.  They are only set with
+	// ScanOutputs.
+	TxOut []*TxOut
+}
+
+// TxScanner decodes selected fields of the transactions of a serialized block
+// one transaction at a time, skipping over the rest.  This avoids most of the
+// cost of decoding complete transactions for applications which only need
+// some of their fields, such as building a set of unspent transaction outputs
+// or an index of spent outpoints.  Every transaction is still read in full to
+// compute its hash.
+type TxScanner struct {
+	r       io.Reader
+	hw      *HashWriter
+	fields  TxScanFields
+	header  BlockHeader
+	txCount uint64
+	index   uint64
+}
+
+// NewTxScanner returns a new TxScanner which decodes the selected fields of the
+// transactions of the block serialized in r, which must be in the same format
+// Deserialize reads.  The header and transaction count of the block are read
+// immediately.  See DeserializeBlockHeader for the errors which may be
+// returned.
+func NewTxScanner(r io.Reader, fields TxScanFields) (*TxScanner, error) {
+	bh, txCount, err := DeserializeBlockHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	err = checkCountFits(r, "NewTxScanner", "transactions", txCount,
+		minTxPayload)
+	if err != nil {
+		return nil, err
+	}
+	return &TxScanner{
+		r:       r,
+		hw:      NewHashWriter(),
+		fields:  fields,
+		header:  *bh,
+		txCount: txCount,
+	}, nil
+}
+
+// Header returns the header of the block being scanned.  Its TxnCount field is
+// the number of transactions in the block.
+func (s *TxScanner) Header() *BlockHeader {
+	return &s.header
+}
+
+// Next decodes the selected fields of the next transaction of the block.
+// io.EOF is returned once all of the transactions have been scanned, while
+// io.ErrUnexpectedEOF is returned when the block ends part way through a
+// transaction.  The scanner should not be used further after any other error.
+func (s *TxScanner) Next() (*ScannedTx, error) {
+	if s.index >= s.txCount {
+		return nil, io.EOF
+	}
+
+	s.hw.Reset()
+	r := io.TeeReader(s.r, s.hw)
+	stx := &ScannedTx{Index: int(s.index)}
+	err := s.scanTx(r, stx)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	stx.Sha = s.hw.Sum()
+	s.index++
+	return stx, nil
+}
+
+// scanTx reads a transaction from r into stx, decoding only the fields
+// selected for the scanner.  Reading from r also hashes the transaction, so
+// the underlying reader is only used directly to check counts.
+func (s *TxScanner) scanTx(r io.Reader, stx *ScannedTx) error {
+	var err error
+	stx.Version, err = binarySerializer.Uint32(r, littleEndian)
+	if err != nil {
+		return err
+	}
+
+	count, err := readVarInt(r, 0)
+	if err != nil {
+		return err
+	}
+	if count > uint64(maxTxInPerMessage) {
+		str := fmt.Sprintf("too many input transactions to fit into "+
+			"max message size [count %d, max %d]", count,
+			maxTxInPerMessage)
+		return categorizedError("TxScanner.Next", str,
+			ErrCategoryOversized)
+	}
+	err = checkCountFits(s.r, "TxScanner.Next", "transaction inputs",
+		count, minTxInPayload)
+	if err != nil {
+		return err
+	}
+	if s.fields&ScanPrevOuts != 0 {
+		stx.PrevOuts = make([]OutPoint, count)
+	}
+	if s.fields&ScanInputs != 0 {
+		stx.TxIn = make([]*TxIn, count)
+	}
+	for i := uint64(0); i < count; i++ {
+		if s.fields&ScanInputs != 0 {
+			ti := &TxIn{}
+			err := readTxIn(r, 0, stx.Version, ti)
+			if err != nil {
+				return err
+			}
+			stx.TxIn[i] = ti
+			if stx.PrevOuts != nil {
+				stx.PrevOuts[i] = ti.PreviousOutpoint
+			}
+			continue
+		}
+
+		if stx.PrevOuts != nil {
+			err = readOutPoint(r, 0, stx.Version, &stx.PrevOuts[i])
+		} else {
+			err = skipBytes(r, HashSize+4)
+		}
+		if err != nil {
+			return err
+		}
+		err = skipScript(r)
+		if err != nil {
+			return err
+		}
+		err = skipBytes(r, 4) // Sequence
+		if err != nil {
+			return err
+		}
+	}
+
+	count, err = readVarInt(r, 0)
+	if err != nil {
+		return err
+	}
+	if count > uint64(maxTxOutPerMessage) {
+		str := fmt.Sprintf("too many output transactions to fit into "+
+			"max message size [count %d, max %d]", count,
+			maxTxOutPerMessage)
+		return categorizedError("TxScanner.Next", str,
+			ErrCategoryOversized)
+	}
+	err = checkCountFits(s.r, "TxScanner.Next", "transaction outputs",
+		count, minTxOutPayload)
+	if err != nil {
+		return err
+	}
+	if s.fields&ScanOutputs != 0 {
+		stx.TxOut = make([]*TxOut, count)
+	}
+	for i := uint64(0); i < count; i++ {
+		if stx.TxOut != nil {
+			to := &TxOut{}
+			err := readTxOut(r, 0, stx.Version, to)
+			if err != nil {
+				return err
+			}
+			stx.TxOut[i] = to
+			continue
+		}
+
+		err = skipBytes(r, 8) // Value
+		if err != nil {
+			return err
+		}
+		err = skipScript(r)
+		if err != nil {
+			return err
+		}
+	}
+
+	stx.LockTime, err = binarySerializer.Uint32(r, littleEndian)
+	return err
+}
+
+// skipBytes reads and discards n bytes from r.
+func skipBytes(r io.Reader, n int64) error {
+	_, err := io.CopyN(ioutil.Discard, r, n)
+	return err
+}
+
+// skipScript reads and discards a script, including its length, from r.  The
+// script is subject to the same size limit as when it is decoded.
+func skipScript(r io.Reader) error {
+	count, err := readVarInt(r, 0)
+	if err != nil {
+		return err
+	}
+	maxSize := maxScriptSize(r)
+	if count > maxSize {
+		str := fmt.Sprintf("transaction script is larger than max "+
+			"script size [count %d, max %d]", count, maxSize)
+		return categorizedError("TxScanner.Next", str,
+			ErrCategoryOversized)
+	}
+	return skipBytes(r, int64(count))
+}
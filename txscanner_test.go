@@ -0,0 +1,131 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// TestTxScanner tests scanning selected fields of the transactions of blocks.
+func TestTxScanner(t *testing.T) {
+	block := btcwire.NewMsgBlock(&blockOne.Header)
+	block.AddTransaction(blockOne.Transactions[0])
+	block.AddTransaction(multiTx)
+	block.Header.TxnCount = 2
+	var buf bytes.Buffer
+	if err := block.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	serialized := buf.Bytes()
+
+	tests := []struct {
+		name   string
+		fields btcwire.TxScanFields
+	}{
+		{"none", 0},
+		{"prevouts", btcwire.ScanPrevOuts},
+		{"inputs", btcwire.ScanInputs},
+		{"outputs", btcwire.ScanOutputs},
+		{"all", btcwire.ScanPrevOuts | btcwire.ScanInputs |
+			btcwire.ScanOutputs},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		s, err := btcwire.NewTxScanner(bytes.NewReader(serialized),
+			test.fields)
+		if err != nil {
+			t.Errorf("NewTxScanner #%d (%s) error %v", i, test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(s.Header(), &block.Header) {
+			t.Errorf("Header #%d (%s)\n got: %s want: %s", i, test.name,
+				spew.Sdump(s.Header()), spew.Sdump(&block.Header))
+			continue
+		}
+
+		for j, tx := range block.Transactions {
+			stx, err := s.Next()
+			if err != nil {
+				t.Errorf("Next #%d (%s) tx %d error %v", i, test.name,
+					j, err)
+				break
+			}
+
+			want := &btcwire.ScannedTx{
+				Index:    j,
+				Version:  tx.Version,
+				LockTime: tx.LockTime,
+			}
+			want.Sha, _ = tx.TxSha()
+			if test.fields&btcwire.ScanPrevOuts != 0 {
+				for _, ti := range tx.TxIn {
+					want.PrevOuts = append(want.PrevOuts,
+						ti.PreviousOutpoint)
+				}
+			}
+			if test.fields&btcwire.ScanInputs != 0 {
+				want.TxIn = tx.TxIn
+			}
+			if test.fields&btcwire.ScanOutputs != 0 {
+				want.TxOut = tx.TxOut
+			}
+			if !reflect.DeepEqual(stx, want) {
+				t.Errorf("Next #%d (%s) tx %d\n got: %s want: %s", i,
+					test.name, j, spew.Sdump(stx), spew.Sdump(want))
+			}
+		}
+		if _, err := s.Next(); err != io.EOF {
+			t.Errorf("Next #%d (%s) wrong error at end got: %v want: %v",
+				i, test.name, err, io.EOF)
+		}
+	}
+}
+
+// TestTxScannerErrors performs negative tests against scanning transactions to
+// confirm error paths work correctly.
+func TestTxScannerErrors(t *testing.T) {
+	// A truncated header fails when creating the scanner.
+	_, err := btcwire.NewTxScanner(bytes.NewReader(blockOneBytes[:40]),
+		btcwire.ScanOutputs)
+	if err != io.ErrUnexpectedEOF && err != io.EOF {
+		t.Errorf("NewTxScanner: wrong error for truncated header got: %v",
+			err)
+	}
+
+	// A transaction count which can't fit in the remaining bytes is
+	// rejected before any transactions are scanned.
+	buf := append([]byte{}, blockOneBytes[:80]...)
+	buf = append(buf, 0xfd, 0xff, 0x00) // Varint for tx count (255)
+	buf = append(buf, blockOneBytes[81:]...)
+	_, err = btcwire.NewTxScanner(bytes.NewReader(buf), btcwire.ScanOutputs)
+	if _, ok := err.(*btcwire.MessageError); !ok {
+		t.Errorf("NewTxScanner: wrong error for bogus tx count got: %v",
+			err)
+	}
+
+	// A block which ends part way through a transaction fails with
+	// io.ErrUnexpectedEOF regardless of which fields are scanned.
+	for _, fields := range []btcwire.TxScanFields{0, btcwire.ScanOutputs} {
+		for _, n := range []int{81, 85, 150, len(blockOneBytes) - 1} {
+			s, err := btcwire.NewTxScanner(
+				newFixedReader(n, blockOneBytes), fields)
+			if err != nil {
+				t.Errorf("NewTxScanner: unexpected error %v", err)
+				continue
+			}
+			_, err = s.Next()
+			if err != io.ErrUnexpectedEOF {
+				t.Errorf("Next: wrong error for %d bytes got: %v "+
+					"want: %v", n, err, io.ErrUnexpectedEOF)
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxSatoshi is the maximum value of a transaction output, and of the total of
+// the outputs of a transaction, in satoshi.  It is the 21 million bitcoin which
+// will ever exist.
+const MaxSatoshi = 21e6 * satoshiPerBitcoin
+
+var (
+	// ErrNegativeValue describes an error that indicates a transaction
+	// output has a negative value.
+	ErrNegativeValue = errors.New("output value is negative")
+
+	// ErrValueTooHigh describes an error that indicates a transaction
+	// output has a value higher than MaxSatoshi.
+	ErrValueTooHigh = errors.New("output value is higher than max " +
+		"allowed value")
+
+	// ErrTotalValueTooHigh describes an error that indicates the total of
+	// the output values exceeds MaxSatoshi.
+	ErrTotalValueTooHigh = errors.New("total output value is higher " +
+		"than max allowed value")
+)
+
+// ValueError describes an output value which is out of range, or which makes
+// the total of the output values out of range, when summing the output values
+// of a transaction or block.  The reason, which is one of ErrNegativeValue,
+// ErrValueTooHigh, or ErrTotalValueTooHigh, is available via Unwrap so it may
+// be checked with errors.Is.
+type ValueError struct {
+	Tx     int   // Index of the transaction in the block, or -1
+	Output int   // Index of the output in the transaction
+	Value  int64 // Value of the output
+	Err    error // Reason the value is out of range
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e *ValueError) Error() string {
+	if e.Tx < 0 {
+		return fmt.Sprintf("output %d: %v [value %d, max %d]",
+			e.Output, e.Err, e.Value, int64(MaxSatoshi))
+	}
+	return fmt.Sprintf("output %d of transaction %d: %v [value %d, max %d]",
+		e.Output, e.Tx, e.Err, e.Value, int64(MaxSatoshi))
+}
+
+// Unwrap returns the reason the value is out of range so it may be inspected
+// with errors.Is.
+func (e *ValueError) Unwrap() error {
+	return e.Err
+}
+
+// addOutputValues adds the output values of the provided transaction to total,
+// which must not exceed MaxSatoshi, and returns the new total.  The provided
+// index of the transaction is used to attribute any errors.
+//
+// Every value and the running total are checked against MaxSatoshi after each
+// addition, so the running total never exceeds twice MaxSatoshi and can not
+// overflow no matter what values the transaction holds.
+func addOutputValues(total int64, tx int, msg *MsgTx) (int64, error) {
+	for i, to := range msg.TxOut {
+		var reason error
+		switch {
+		case to.Value < 0:
+			reason = ErrNegativeValue
+		case to.Value > MaxSatoshi:
+			reason = ErrValueTooHigh
+		default:
+			total += to.Value
+			if total > MaxSatoshi {
+				reason = ErrTotalValueTooHigh
+			}
+		}
+		if reason != nil {
+			return 0, &ValueError{Tx: tx, Output: i, Value: to.Value,
+				Err: reason}
+		}
+	}
+	return total, nil
+}
+
+// TotalOutputValue returns the total of the output values of the transaction.
+// A ValueError is returned when an output value is negative or exceeds
+// MaxSatoshi, or when the total exceeds MaxSatoshi, rather than silently
+// wrapping around as summing arbitrary int64 values would.
+func (msg *MsgTx) TotalOutputValue() (int64, error) {
+	return addOutputValues(0, -1, msg)
+}
+
+// TotalOutputValue returns the total of the output values of every transaction
+// in the block, including the coinbase transaction.  A ValueError identifying
+// the transaction and output is returned when an output value is negative or
+// exceeds MaxSatoshi, or when the total exceeds MaxSatoshi.
+func (msg *MsgBlock) TotalOutputValue() (int64, error) {
+	var total int64
+	for i, tx := range msg.Transactions {
+		var err error
+		total, err = addOutputValues(total, i, tx)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"errors"
+	"github.com/conformal/btcwire"
+	"math"
+	"testing"
+)
+
+// txWithValues returns a transaction with outputs of the provided values.
+func txWithValues(values ...int64) *btcwire.MsgTx {
+	tx := btcwire.NewMsgTx()
+	for _, value := range values {
+		tx.AddTxOut(btcwire.NewTxOut(btcwire.Amount(value), nil))
+	}
+	return tx
+}
+
+// TestTotalOutputValue ensures the output values of transactions and blocks
+// are summed without overflowing and out of range values are reported.
+func TestTotalOutputValue(t *testing.T) {
+	const max = btcwire.MaxSatoshi
+
+	tests := []struct {
+		values []int64 // Output values of the transaction
+		want   int64   // Expected total
+		err    error   // Expected reason for the error
+		output int     // Expected output of the error
+	}{
+		{nil, 0, nil, 0},
+		{[]int64{0}, 0, nil, 0},
+		{[]int64{1, 2, 3}, 6, nil, 0},
+		{[]int64{max}, max, nil, 0},
+		{[]int64{max - 1, 1}, max, nil, 0},
+		{[]int64{1, -1}, 0, btcwire.ErrNegativeValue, 1},
+		{[]int64{max + 1}, 0, btcwire.ErrValueTooHigh, 0},
+		{[]int64{max, 1}, 0, btcwire.ErrTotalValueTooHigh, 1},
+		{[]int64{1, 2, math.MaxInt64}, 0, btcwire.ErrValueTooHigh, 2},
+		{[]int64{max, max, max}, 0, btcwire.ErrTotalValueTooHigh, 1},
+		{[]int64{math.MinInt64}, 0, btcwire.ErrNegativeValue, 0},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		tx := txWithValues(test.values...)
+		for _, block := range []bool{false, true} {
+			var got int64
+			var err error
+			wantTx := -1
+			if block {
+				// Place the transaction after one with a
+				// single output without any value.
+				msg := btcwire.NewMsgBlock(&blockOne.Header)
+				msg.AddTransaction(txWithValues(0))
+				msg.AddTransaction(tx)
+				got, err = msg.TotalOutputValue()
+				wantTx = 1
+			} else {
+				got, err = tx.TotalOutputValue()
+			}
+
+			if !errors.Is(err, test.err) {
+				t.Errorf("TotalOutputValue #%d (block %v) wrong "+
+					"error - got %v, want %v", i, block, err,
+					test.err)
+				continue
+			}
+			if test.err != nil {
+				var verr *btcwire.ValueError
+				if !errors.As(err, &verr) {
+					t.Errorf("TotalOutputValue #%d (block %v) "+
+						"wrong error type %T", i, block, err)
+					continue
+				}
+				if verr.Tx != wantTx || verr.Output != test.output {
+					t.Errorf("TotalOutputValue #%d (block %v) "+
+						"got output %d of tx %d, want "+
+						"output %d of tx %d", i, block,
+						verr.Output, verr.Tx, test.output,
+						wantTx)
+				}
+				continue
+			}
+			if got != test.want {
+				t.Errorf("TotalOutputValue #%d (block %v) got %d, "+
+					"want %d", i, block, got, test.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,550 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+)
+
+const (
+	// minSpentOutputPayload is the minimum number of bytes a spent output
+	// can be when serialized.  Height and coinbase flag 1 byte + compressed
+	// amount 1 byte + compressed script 1 byte.
+	minSpentOutputPayload = 3
+
+	// numSpecialScripts is the number of script types which are compressed
+	// to a fixed size in undo data.  The size of other compressed scripts
+	// is offset by it.
+	numSpecialScripts = 6
+
+	// maxVLQ is the largest value the variable length quantities of undo
+	// data may hold.
+	maxVLQ = ^uint64(0)
+)
+
+// SpentOutput houses a transaction output spent by a block along with the
+// details of the transaction which created it as stored in the undo data of
+// the reference implementation.
+type SpentOutput struct {
+	// Value and PkScript are those of the spent output.
+	Value    int64
+	PkScript []byte
+
+	// Height is the height of the block containing the transaction which
+	// created the output.
+	Height int32
+
+	// IsCoinBase is whether the transaction which created the output is a
+	// coinbase.
+	IsCoinBase bool
+}
+
+// TxUndo houses the outputs spent by the inputs of a transaction in the order
+// of the inputs.
+type TxUndo struct {
+	SpentOutputs []*SpentOutput
+}
+
+// BlockUndo houses the undo data of a block, which is the information required
+// to reverse the changes the block made to the set of unspent transaction
+// outputs.  It contains a TxUndo for each transaction in the block other than
+// the coinbase, which spends nothing.
+//
+// The reference implementation stores the undo data of each block in its undo
+// files (rev*.dat) with the same magic and length framing as its block files,
+// except each record is followed by the checksum returned by Checksum.
+//
+// Spent outputs are serialized with the compression used by the reference
+// implementation for its chain state.  That is to say amounts are encoded via
+// CompressAmount and the scripts of standard pay-to-pubkey, pay-to-pubkey-hash,
+// and pay-to-script-hash outputs are reduced to their key or hash.
+type BlockUndo struct {
+	TxUndos []*TxUndo
+}
+
+// Serialize encodes the block undo data to w in the format used by the undo
+// files of the reference implementation.
+func (u *BlockUndo) Serialize(w io.Writer) error {
+	err := writeVarInt(w, 0, uint64(len(u.TxUndos)))
+	if err != nil {
+		return err
+	}
+	for _, txUndo := range u.TxUndos {
+		err := writeVarInt(w, 0, uint64(len(txUndo.SpentOutputs)))
+		if err != nil {
+			return err
+		}
+		for _, so := range txUndo.SpentOutputs {
+			err := writeSpentOutput(w, so)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Deserialize decodes block undo data from r into the receiver using the format
+// of the undo files of the reference implementation.
+func (u *BlockUndo) Deserialize(r io.Reader) error {
+	count, err := readVarInt(r, 0)
+	if err != nil {
+		return err
+	}
+	if count > maxTxPerBlock {
+		str := fmt.Sprintf("too many transactions to fit into a block "+
+			"[count %d, max %d]", count, maxTxPerBlock)
+		return categorizedError("BlockUndo.Deserialize", str,
+			ErrCategoryOversized)
+	}
+	err = checkCountFits(r, "BlockUndo.Deserialize", "transactions",
+		count, 1)
+	if err != nil {
+		return err
+	}
+
+	u.TxUndos = make([]*TxUndo, 0, count)
+	for i := uint64(0); i < count; i++ {
+		numSpent, err := readVarInt(r, 0)
+		if err != nil {
+			return err
+		}
+		if numSpent > maxTxInPerMessage {
+			str := fmt.Sprintf("too many spent outputs for a "+
+				"transaction [count %d, max %d]", numSpent,
+				maxTxInPerMessage)
+			return categorizedError("BlockUndo.Deserialize", str,
+				ErrCategoryOversized)
+		}
+		err = checkCountFits(r, "BlockUndo.Deserialize",
+			"spent outputs", numSpent, minSpentOutputPayload)
+		if err != nil {
+			return err
+		}
+
+		txUndo := TxUndo{
+			SpentOutputs: make([]*SpentOutput, 0, numSpent),
+		}
+		for j := uint64(0); j < numSpent; j++ {
+			so, err := readSpentOutput(r)
+			if err != nil {
+				return err
+			}
+			txUndo.SpentOutputs = append(txUndo.SpentOutputs, so)
+		}
+		u.TxUndos = append(u.TxUndos, &txUndo)
+	}
+	return nil
+}
+
+// SerializeSize returns the number of bytes it would take to serialize the
+// block undo data.
+func (u *BlockUndo) SerializeSize() int {
+	n := varIntSerializeSize(uint64(len(u.TxUndos)))
+	for _, txUndo := range u.TxUndos {
+		n += varIntSerializeSize(uint64(len(txUndo.SpentOutputs)))
+		for _, so := range txUndo.SpentOutputs {
+			n += spentOutputSerializeSize(so)
+		}
+	}
+	return n
+}
+
+// Checksum returns the checksum which follows the undo data of a block in the
+// undo files of the reference implementation, which is the double sha256 of
+// the hash of the previous block followed by the serialized undo data.
+func (u *BlockUndo) Checksum(prevHash *ShaHash) (ShaHash, error) {
+	hw := NewHashWriter()
+	hw.Write(prevHash[:])
+	err := u.Serialize(hw)
+	if err != nil {
+		return ShaHash{}, err
+	}
+	return hw.Sum(), nil
+}
+
+// writeSpentOutput encodes the provided spent output to w.
+func writeSpentOutput(w io.Writer, so *SpentOutput) error {
+	if so.Value < 0 {
+		str := fmt.Sprintf("spent output has negative value %d",
+			so.Value)
+		return messageError("BlockUndo.Serialize", str)
+	}
+	if so.Height < 0 {
+		str := fmt.Sprintf("spent output has negative height %d",
+			so.Height)
+		return messageError("BlockUndo.Serialize", str)
+	}
+	code := uint64(so.Height) << 1
+	if so.IsCoinBase {
+		code |= 1
+	}
+	err := writeVLQ(w, code)
+	if err != nil {
+		return err
+	}
+
+	// Outputs created at non-zero heights are followed by the unused
+	// transaction version kept for compatibility.
+	if so.Height > 0 {
+		err := writeVLQ(w, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writeVLQ(w, CompressAmount(uint64(so.Value)))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(compressScript(so.PkScript))
+	return err
+}
+
+// readSpentOutput decodes a spent output from r.
+func readSpentOutput(r io.Reader) (*SpentOutput, error) {
+	code, err := readVLQ(r)
+	if err != nil {
+		return nil, err
+	}
+	if code>>1 > 0x7fffffff {
+		str := fmt.Sprintf("spent output height %d is out of range",
+			code>>1)
+		return nil, categorizedError("BlockUndo.Deserialize", str,
+			ErrCategoryMalformed)
+	}
+	so := SpentOutput{
+		Height:     int32(code >> 1),
+		IsCoinBase: code&1 == 1,
+	}
+	if so.Height > 0 {
+		if _, err := readVLQ(r); err != nil {
+			return nil, err
+		}
+	}
+
+	amount, err := readVLQ(r)
+	if err != nil {
+		return nil, err
+	}
+	so.Value = int64(DecompressAmount(amount))
+
+	so.PkScript, err = readCompressedScript(r)
+	if err != nil {
+		return nil, err
+	}
+	return &so, nil
+}
+
+// spentOutputSerializeSize returns the number of bytes it would take to
+// serialize the provided spent output.
+func spentOutputSerializeSize(so *SpentOutput) int {
+	code := uint64(so.Height) << 1
+	n := vlqSerializeSize(code)
+	if so.Height > 0 {
+		n += vlqSerializeSize(0)
+	}
+	n += vlqSerializeSize(CompressAmount(uint64(so.Value)))
+	return n + len(compressScript(so.PkScript))
+}
+
+// writeVLQ writes val to w as a variable length quantity as used by the undo
+// data and chain state of the reference implementation.  Each byte holds 7
+// bits of the value, most significant first, with the high bit set on all but
+// the last byte.  Unlike a plain base-128 encoding, one is subtracted from the
+// value carried into each byte before the last, so every value has exactly
+// one encoding.
+func writeVLQ(w io.Writer, val uint64) error {
+	var buf [10]byte
+	i := len(buf) - 1
+	buf[i] = byte(val & 0x7f)
+	for val > 0x7f {
+		val = (val >> 7) - 1
+		i--
+		buf[i] = byte(val&0x7f) | 0x80
+	}
+	_, err := w.Write(buf[i:])
+	return err
+}
+
+// readVLQ reads a variable length quantity written by writeVLQ from r.
+func readVLQ(r io.Reader) (uint64, error) {
+	var val uint64
+	for {
+		b, err := binarySerializer.Uint8(r)
+		if err != nil {
+			return 0, err
+		}
+		if val > maxVLQ>>7 {
+			return 0, categorizedError("readVLQ", "variable length "+
+				"quantity overflows 64 bits", ErrCategoryMalformed)
+		}
+		val = val<<7 | uint64(b&0x7f)
+		if b&0x80 == 0 {
+			return val, nil
+		}
+		if val == maxVLQ {
+			return 0, categorizedError("readVLQ", "variable length "+
+				"quantity overflows 64 bits", ErrCategoryMalformed)
+		}
+		val++
+	}
+}
+
+// vlqSerializeSize returns the number of bytes it would take to serialize val
+// as a variable length quantity.
+func vlqSerializeSize(val uint64) int {
+	n := 1
+	for val > 0x7f {
+		val = (val >> 7) - 1
+		n++
+	}
+	return n
+}
+
+// CompressAmount returns the provided amount in satoshi compressed as in the
+// undo data and chain state of the reference implementation.  Amounts are
+// typically round numbers, so the trailing decimal zeros, up to 9, are
+// replaced by their count and the last non-zero digit, which can't be zero, is
+// stored in base 9 alongside the remaining digits.  The result is smaller
+// than the amount for round amounts, which helps when it is encoded as a
+// variable length quantity.
+func CompressAmount(amount uint64) uint64 {
+	if amount == 0 {
+		return 0
+	}
+	exp := uint64(0)
+	for amount%10 == 0 && exp < 9 {
+		amount /= 10
+		exp++
+	}
+	if exp < 9 {
+		lastDigit := amount % 10
+		amount /= 10
+		return 1 + (amount*9+lastDigit-1)*10 + exp
+	}
+	return 1 + (amount-1)*10 + 9
+}
+
+// DecompressAmount returns the amount in satoshi the provided value was
+// compressed from by CompressAmount.
+func DecompressAmount(x uint64) uint64 {
+	if x == 0 {
+		return 0
+	}
+	x--
+	exp := x % 10
+	x /= 10
+	var amount uint64
+	if exp < 9 {
+		lastDigit := x%9 + 1
+		x /= 9
+		amount = x*10 + lastDigit
+	} else {
+		amount = x + 1
+	}
+	for ; exp > 0; exp-- {
+		amount *= 10
+	}
+	return amount
+}
+
+// The following opcodes are used to recognize the scripts which are compressed
+// specially.
+const (
+	opDup         = 0x76
+	opHash160     = 0xa9
+	opEqual       = 0x87
+	opEqualVerify = 0x88
+	opCheckSig    = 0xac
+	opReturn      = 0x6a
+)
+
+// secp256k1 curve parameters used to validate and decompress the public keys of
+// pay-to-pubkey scripts.
+var (
+	secp256k1P, _ = new(big.Int).SetString("ffffffffffffffffffffffffffff"+
+		"fffffffffffffffffffffffffffefffffc2f", 16)
+	secp256k1B = big.NewInt(7)
+)
+
+// secp256k1Y2 returns x^3 + 7 mod P, which is the square of the y coordinate
+// of the point on the secp256k1 curve with the x coordinate x.
+func secp256k1Y2(x *big.Int) *big.Int {
+	y2 := new(big.Int).Mul(x, x)
+	y2.Mul(y2, x)
+	y2.Add(y2, secp256k1B)
+	return y2.Mod(y2, secp256k1P)
+}
+
+// isOnCurve returns whether the provided uncompressed public key, without its
+// prefix byte, is a point on the secp256k1 curve.
+func isOnCurve(xy []byte) bool {
+	x := new(big.Int).SetBytes(xy[:32])
+	y := new(big.Int).SetBytes(xy[32:])
+	if x.Cmp(secp256k1P) >= 0 || y.Cmp(secp256k1P) >= 0 {
+		return false
+	}
+	y2 := new(big.Int).Mul(y, y)
+	y2.Mod(y2, secp256k1P)
+	return y2.Cmp(secp256k1Y2(x)) == 0
+}
+
+// decompressPubKey returns the 65 byte uncompressed form of the public key with
+// the provided x coordinate whose y coordinate is odd when odd is set.  It
+// returns nil when there is no such point on the secp256k1 curve.
+func decompressPubKey(xBytes []byte, odd bool) []byte {
+	x := new(big.Int).SetBytes(xBytes)
+	if x.Cmp(secp256k1P) >= 0 {
+		return nil
+	}
+
+	// P = 3 mod 4, so the square root is (x^3 + 7)^((P+1)/4).
+	y2 := secp256k1Y2(x)
+	exp := new(big.Int).Add(secp256k1P, big.NewInt(1))
+	exp.Rsh(exp, 2)
+	y := new(big.Int).Exp(y2, exp, secp256k1P)
+	if new(big.Int).Mod(new(big.Int).Mul(y, y), secp256k1P).Cmp(y2) != 0 {
+		return nil
+	}
+	if (y.Bit(0) == 1) != odd {
+		y.Sub(secp256k1P, y)
+	}
+
+	pubKey := make([]byte, 65)
+	pubKey[0] = 0x04
+	xb, yb := x.Bytes(), y.Bytes()
+	copy(pubKey[33-len(xb):33], xb)
+	copy(pubKey[65-len(yb):], yb)
+	return pubKey
+}
+
+// compressScript returns the provided public key script compressed as in the
+// undo data and chain state of the reference implementation.  Standard
+// pay-to-pubkey-hash and pay-to-script-hash scripts are reduced to a type byte
+// of 0 and 1 followed by their hash, pay-to-pubkey scripts with compressed keys
+// to the 33 byte key itself, and those with valid uncompressed keys to a type
+// byte of 4 or 5, depending on whether the y coordinate is odd, followed by
+// the x coordinate.  Any other script is prefixed by its length plus 6, the
+// number of special script types, as a variable length quantity.
+func compressScript(pkScript []byte) []byte {
+	switch {
+	// Pay-to-pubkey-hash.
+	case len(pkScript) == 25 && pkScript[0] == opDup &&
+		pkScript[1] == opHash160 && pkScript[2] == 20 &&
+		pkScript[23] == opEqualVerify && pkScript[24] == opCheckSig:
+
+		return append([]byte{0x00}, pkScript[3:23]...)
+
+	// Pay-to-script-hash.
+	case len(pkScript) == 23 && pkScript[0] == opHash160 &&
+		pkScript[1] == 20 && pkScript[22] == opEqual:
+
+		return append([]byte{0x01}, pkScript[2:22]...)
+
+	// Pay-to-pubkey with a compressed key.
+	case len(pkScript) == 35 && pkScript[0] == 33 &&
+		pkScript[34] == opCheckSig &&
+		(pkScript[1] == 0x02 || pkScript[1] == 0x03):
+
+		return append([]byte(nil), pkScript[1:34]...)
+
+	// Pay-to-pubkey with a valid uncompressed key.
+	case len(pkScript) == 67 && pkScript[0] == 65 &&
+		pkScript[66] == opCheckSig && pkScript[1] == 0x04 &&
+		isOnCurve(pkScript[2:66]):
+
+		compressed := make([]byte, 33)
+		compressed[0] = 0x04 | pkScript[65]&0x01
+		copy(compressed[1:], pkScript[2:34])
+		return compressed
+	}
+
+	size := uint64(len(pkScript)) + numSpecialScripts
+	var buf bytes.Buffer
+	buf.Grow(vlqSerializeSize(size) + len(pkScript))
+	writeVLQ(&buf, size)
+	buf.Write(pkScript)
+	return buf.Bytes()
+}
+
+// readCompressedScript reads a script compressed by compressScript from r and
+// returns the original script.  Scripts larger than MaxScriptSize are replaced
+// by a script consisting of OP_RETURN, as in the reference implementation,
+// since they can never be spent.
+func readCompressedScript(r io.Reader) ([]byte, error) {
+	size, err := readVLQ(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch size {
+	case 0x00, 0x01:
+		var hash [20]byte
+		_, err := io.ReadFull(r, hash[:])
+		if err != nil {
+			return nil, err
+		}
+		if size == 0x00 {
+			script := []byte{opDup, opHash160, 20}
+			script = append(script, hash[:]...)
+			return append(script, opEqualVerify, opCheckSig), nil
+		}
+		script := []byte{opHash160, 20}
+		script = append(script, hash[:]...)
+		return append(script, opEqual), nil
+
+	case 0x02, 0x03, 0x04, 0x05:
+		var x [32]byte
+		_, err := io.ReadFull(r, x[:])
+		if err != nil {
+			return nil, err
+		}
+		if size <= 0x03 {
+			script := []byte{33, byte(size)}
+			script = append(script, x[:]...)
+			return append(script, opCheckSig), nil
+		}
+		pubKey := decompressPubKey(x[:], size == 0x05)
+		if pubKey == nil {
+			str := fmt.Sprintf("compressed public key %x is not on "+
+				"the secp256k1 curve", x)
+			return nil, categorizedError("readCompressedScript", str,
+				ErrCategoryMalformed)
+		}
+		script := []byte{65}
+		script = append(script, pubKey...)
+		return append(script, opCheckSig), nil
+	}
+
+	size -= numSpecialScripts
+	if size > MaxScriptSize {
+		if size > MaxMessagePayload {
+			str := fmt.Sprintf("compressed script is too large "+
+				"[len %d, max %d]", size, MaxMessagePayload)
+			return nil, categorizedError("readCompressedScript",
+				str, ErrCategoryOversized)
+		}
+		_, err := io.CopyN(ioutil.Discard, r, int64(size))
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		return []byte{opReturn}, nil
+	}
+	err = checkCountFits(r, "readCompressedScript", "script bytes", size, 1)
+	if err != nil {
+		return nil, err
+	}
+	script := make([]byte, size)
+	_, err = io.ReadFull(r, script)
+	if err != nil {
+		return nil, err
+	}
+	return script, nil
+}
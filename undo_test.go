@@ -0,0 +1,310 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// TestCompressAmount tests compressing amounts against the values produced by
+// the reference implementation and ensures they decompress to the original
+// amount.
+func TestCompressAmount(t *testing.T) {
+	tests := []struct {
+		amount     uint64
+		compressed uint64
+	}{
+		{0, 0},
+		{1, 1},
+		{1000000, 7},                       // 0.01 BTC
+		{100000000, 9},                     // 1 BTC
+		{5000000000, 50},                   // 50 BTC
+		{2100000000000000, 21000000},       // 21 million BTC
+		{123456789, 1111111101},            // No trailing zeros
+		{1000000000000000000, 10000000000}, // More than 9 zeros
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		got := btcwire.CompressAmount(test.amount)
+		if got != test.compressed {
+			t.Errorf("CompressAmount #%d got: %d want: %d", i, got,
+				test.compressed)
+			continue
+		}
+		if amount := btcwire.DecompressAmount(got); amount != test.amount {
+			t.Errorf("DecompressAmount #%d got: %d want: %d", i,
+				amount, test.amount)
+		}
+	}
+
+	// Every amount up to a few thousand, along with each power of ten and
+	// its neighbors, survives a round trip.
+	amounts := []uint64{}
+	for amount := uint64(0); amount < 5000; amount++ {
+		amounts = append(amounts, amount)
+	}
+	for pow := uint64(1); pow <= 1e18; pow *= 10 {
+		amounts = append(amounts, pow-1, pow, pow+1, pow*7)
+	}
+	for _, amount := range amounts {
+		compressed := btcwire.CompressAmount(amount)
+		if got := btcwire.DecompressAmount(compressed); got != amount {
+			t.Errorf("DecompressAmount(CompressAmount(%d)) got: %d",
+				amount, got)
+		}
+	}
+}
+
+// TestVLQ tests encoding and decoding the variable length quantities used by
+// undo data.
+func TestVLQ(t *testing.T) {
+	tests := []struct {
+		val uint64
+		buf []byte
+	}{
+		{0, []byte{0x00}},
+		{1, []byte{0x01}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x00}},
+		{255, []byte{0x80, 0x7f}},
+		{16511, []byte{0xff, 0x7f}},
+		{16512, []byte{0x80, 0x80, 0x00}},
+		{2113663, []byte{0xff, 0xff, 0x7f}},
+		{1<<64 - 1, []byte{0x80, 0xfe, 0xfe, 0xfe, 0xfe, 0xfe, 0xfe,
+			0xfe, 0xfe, 0x7f}},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		var buf bytes.Buffer
+		err := btcwire.TstWriteVLQ(&buf, test.val)
+		if err != nil {
+			t.Errorf("writeVLQ #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("writeVLQ #%d\n got: %x want: %x", i,
+				buf.Bytes(), test.buf)
+			continue
+		}
+		if n := btcwire.TstVLQSerializeSize(test.val); n != len(test.buf) {
+			t.Errorf("vlqSerializeSize #%d got: %d want: %d", i, n,
+				len(test.buf))
+		}
+
+		val, err := btcwire.TstReadVLQ(bytes.NewReader(test.buf))
+		if err != nil {
+			t.Errorf("readVLQ #%d error %v", i, err)
+			continue
+		}
+		if val != test.val {
+			t.Errorf("readVLQ #%d got: %d want: %d", i, val, test.val)
+		}
+	}
+
+	// Values which overflow 64 bits are rejected.
+	overflows := [][]byte{
+		{0x80, 0xfe, 0xfe, 0xfe, 0xfe, 0xfe, 0xfe, 0xfe, 0xff, 0x00},
+		{0x81, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x00},
+	}
+	for i, buf := range overflows {
+		_, err := btcwire.TstReadVLQ(bytes.NewReader(buf))
+		if _, ok := err.(*btcwire.MessageError); !ok {
+			t.Errorf("readVLQ overflow #%d wrong error got: %v", i, err)
+		}
+	}
+}
+
+// TestBlockUndo tests encoding and decoding block undo data with each type of
+// compressed script.
+func TestBlockUndo(t *testing.T) {
+	hash20 := bytes.Repeat([]byte{0x11}, 20)
+	p2pkh := append(append([]byte{0x76, 0xa9, 0x14}, hash20...), 0x88, 0xac)
+	p2sh := append(append([]byte{0xa9, 0x14}, hash20...), 0x87)
+	key33 := append([]byte{0x03}, bytes.Repeat([]byte{0x22}, 32)...)
+	p2pkCompressed := append(append([]byte{0x21}, key33...), 0xac)
+
+	// The pay-to-pubkey script of the genesis coinbase has a valid
+	// uncompressed key with an odd y coordinate.
+	p2pk := btcwire.GenesisBlock.Transactions[0].TxOut[0].PkScript
+
+	// An uncompressed key which is not on the curve can't be compressed.
+	badKey := append([]byte{0x41, 0x04}, bytes.Repeat([]byte{0x01}, 64)...)
+	badKey = append(badKey, 0xac)
+
+	other := []byte{0x6a, 0x01, 0x02}
+
+	tests := []struct {
+		name       string
+		so         btcwire.SpentOutput
+		compressed []byte // Expected serialized spent output
+	}{
+		{"p2pkh", btcwire.SpentOutput{Value: 1000000, PkScript: p2pkh},
+			append([]byte{0x00, 0x07, 0x00}, hash20...)},
+		{"p2sh", btcwire.SpentOutput{Value: 1, PkScript: p2sh,
+			Height: 1}, append([]byte{0x02, 0x00, 0x01, 0x01},
+			hash20...)},
+		{"compressed p2pk", btcwire.SpentOutput{Value: 100000000,
+			PkScript: p2pkCompressed, Height: 63, IsCoinBase: true},
+			append([]byte{0x7f, 0x00, 0x09}, key33...)},
+		{"uncompressed p2pk", btcwire.SpentOutput{Value: 5000000000,
+			PkScript: p2pk, Height: 64, IsCoinBase: true},
+			append([]byte{0x80, 0x01, 0x00, 0x32, 0x05}, p2pk[2:34]...)},
+		{"key not on curve", btcwire.SpentOutput{Value: 0,
+			PkScript: badKey}, append([]byte{0x00, 0x00, 0x49},
+			badKey...)},
+		{"other", btcwire.SpentOutput{Value: 0, PkScript: other},
+			append([]byte{0x00, 0x00, 0x09}, other...)},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		so := test.so
+		undo := btcwire.BlockUndo{
+			TxUndos: []*btcwire.TxUndo{
+				{SpentOutputs: []*btcwire.SpentOutput{&so}},
+			},
+		}
+		want := append([]byte{0x01, 0x01}, test.compressed...)
+
+		var buf bytes.Buffer
+		err := undo.Serialize(&buf)
+		if err != nil {
+			t.Errorf("Serialize #%d (%s) error %v", i, test.name, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("Serialize #%d (%s)\n got: %x want: %x", i,
+				test.name, buf.Bytes(), want)
+			continue
+		}
+		if n := undo.SerializeSize(); n != len(want) {
+			t.Errorf("SerializeSize #%d (%s) got: %d want: %d", i,
+				test.name, n, len(want))
+		}
+
+		var got btcwire.BlockUndo
+		err = got.Deserialize(bytes.NewReader(want))
+		if err != nil {
+			t.Errorf("Deserialize #%d (%s) error %v", i, test.name,
+				err)
+			continue
+		}
+		if !reflect.DeepEqual(&got, &undo) {
+			t.Errorf("Deserialize #%d (%s)\n got: %s want: %s", i,
+				test.name, spew.Sdump(&got), spew.Sdump(&undo))
+		}
+	}
+}
+
+// TestBlockUndoChecksum ensures the checksum of block undo data commits to the
+// hash of the previous block along with the undo data.
+func TestBlockUndoChecksum(t *testing.T) {
+	undo := btcwire.BlockUndo{
+		TxUndos: []*btcwire.TxUndo{
+			{SpentOutputs: []*btcwire.SpentOutput{
+				{Value: 100000000, PkScript: []byte{0x51}},
+			}},
+		},
+	}
+	var buf bytes.Buffer
+	undo.Serialize(&buf)
+
+	prevHash := btcwire.GenesisHash
+	want := btcwire.DoubleSha256(append(prevHash[:], buf.Bytes()...))
+	got, err := undo.Checksum(&prevHash)
+	if err != nil {
+		t.Fatalf("Checksum: %v", err)
+	}
+	if !bytes.Equal(got[:], want) {
+		t.Errorf("Checksum\n got: %x want: %x", got[:], want)
+	}
+}
+
+// TestBlockUndoErrors performs negative tests against encoding and decoding
+// block undo data to confirm error paths work correctly.
+func TestBlockUndoErrors(t *testing.T) {
+	negative := []btcwire.SpentOutput{
+		{Value: -1},
+		{Height: -1},
+	}
+	for i, so := range negative {
+		so := so
+		undo := btcwire.BlockUndo{
+			TxUndos: []*btcwire.TxUndo{
+				{SpentOutputs: []*btcwire.SpentOutput{&so}},
+			},
+		}
+		var buf bytes.Buffer
+		err := undo.Serialize(&buf)
+		if _, ok := err.(*btcwire.MessageError); !ok {
+			t.Errorf("Serialize #%d wrong error got: %v", i, err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		buf  []byte
+		err  error                 // Expected error
+		cat  btcwire.ErrorCategory // Expected category of MessageError
+	}{
+		{"empty", nil, io.EOF, 0},
+		{"truncated spent output", []byte{0x01, 0x01, 0x00, 0x07, 0x00,
+			0x11}, io.ErrUnexpectedEOF, 0},
+		{"too many spent outputs", []byte{0x01, 0x05, 0x00},
+			nil, btcwire.ErrCategoryMalformed},
+		{"too many transactions", []byte{0xfe, 0xff, 0xff, 0xff, 0x00},
+			nil, btcwire.ErrCategoryOversized},
+		{"height out of range", []byte{0x01, 0x01, 0x8f, 0xfe, 0xfe,
+			0xfe, 0x7f}, nil, btcwire.ErrCategoryMalformed},
+		{"key not on curve", append([]byte{0x01, 0x01, 0x00, 0x00,
+			0x04}, bytes.Repeat([]byte{0xff}, 32)...), nil,
+			btcwire.ErrCategoryMalformed},
+		{"script too large for bytes left", []byte{0x01, 0x01, 0x00,
+			0x00, 0x0a, 0x01}, nil, btcwire.ErrCategoryMalformed},
+		{"oversized script truncated", []byte{0x01, 0x01, 0x00, 0x00,
+			0xcd, 0x17, 0x01}, io.ErrUnexpectedEOF, 0},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		var undo btcwire.BlockUndo
+		err := undo.Deserialize(bytes.NewReader(test.buf))
+		if test.err != nil {
+			if err != test.err {
+				t.Errorf("Deserialize #%d (%s) wrong error got: %v "+
+					"want: %v", i, test.name, err, test.err)
+			}
+			continue
+		}
+		merr, ok := err.(*btcwire.MessageError)
+		if !ok || merr.Category != test.cat {
+			t.Errorf("Deserialize #%d (%s) wrong error got: %v want "+
+				"category: %v", i, test.name, err, test.cat)
+		}
+	}
+
+	// Scripts larger than MaxScriptSize are replaced by OP_RETURN.
+	size := btcwire.MaxScriptSize + 1
+	var buf bytes.Buffer
+	buf.Write([]byte{0x01, 0x01, 0x00, 0x00})
+	btcwire.TstWriteVLQ(&buf, uint64(size+6))
+	buf.Write(make([]byte, size))
+	var undo btcwire.BlockUndo
+	err := undo.Deserialize(&buf)
+	if err != nil {
+		t.Fatalf("Deserialize: oversized script error %v", err)
+	}
+	script := undo.TxUndos[0].SpentOutputs[0].PkScript
+	if !bytes.Equal(script, []byte{0x6a}) {
+		t.Errorf("Deserialize: oversized script got: %x want: 6a", script)
+	}
+}
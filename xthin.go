@@ -0,0 +1,280 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire
+
+import (
+	"fmt"
+	"io"
+)
+
+// Commands used in the message headers of the Xtreme Thinblocks (BUIP010)
+// messages.  These messages are not part of the bitcoin protocol and are only
+// recognized once RegisterXThinMessages has been called.
+const (
+	CmdGetXThin    = "get_xthin"
+	CmdXThinBlock  = "xthinblock"
+	CmdXBlockTx    = "xblocktx"
+	CmdGetXBlockTx = "get_xblocktx"
+)
+
+// RegisterXThinMessages registers the Xtreme Thinblocks (BUIP010) messages used
+// by Bitcoin Unlimited peers, which are MsgGetXThin, MsgXThinBlock,
+// MsgXBlockTx, and MsgGetXBlockTx, so they are recognized when they are read.
+// Until it is called, messages with their commands are treated the same as any
+// other unrecognized command.
+//
+// This is intended to be called during initialization by applications which
+// interoperate with Bitcoin Unlimited peers, such as for testing.
+func RegisterXThinMessages() {
+	registerMessage(CmdGetXThin, func() Message { return &MsgGetXThin{} })
+	registerMessage(CmdXThinBlock, func() Message { return &MsgXThinBlock{} })
+	registerMessage(CmdXBlockTx, func() Message { return &MsgXBlockTx{} })
+	registerMessage(CmdGetXBlockTx, func() Message { return &MsgGetXBlockTx{} })
+}
+
+// CheapHash returns the shortened transaction hash used by Xtreme Thinblocks to
+// identify the transactions of a block, which is the first 8 bytes of the hash
+// interpreted as a little-endian integer.
+func CheapHash(hash *ShaHash) uint64 {
+	return littleEndian.Uint64(hash[:8])
+}
+
+// maxCheapHashesPerMsg is the maximum number of cheap hashes an Xtreme
+// Thinblocks message can carry, which is one per transaction of the largest
+// possible block.
+const maxCheapHashesPerMsg = maxTxPerBlock
+
+// readCheapHashes reads a list of cheap hashes from r.  The function f is used
+// to attribute any errors.
+func readCheapHashes(r io.Reader, pver uint32, f string) ([]uint64, error) {
+	count, err := readVarInt(r, pver)
+	if err != nil {
+		return nil, err
+	}
+	if count > maxCheapHashesPerMsg {
+		str := fmt.Sprintf("too many transaction hashes for message "+
+			"[count %v, max %v]", count, maxCheapHashesPerMsg)
+		return nil, categorizedError(f, str, ErrCategoryOversized)
+	}
+	err = checkCountFits(r, f, "transaction hashes", count, 8)
+	if err != nil {
+		return nil, err
+	}
+	err = reserveAlloc(r, f, "transaction hashes", count*8)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]uint64, 0, count)
+	for i := uint64(0); i < count; i++ {
+		hash, err := binarySerializer.Uint64(r, littleEndian)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+// writeCheapHashes writes the provided list of cheap hashes to w.  The function
+// f is used to attribute any errors.
+func writeCheapHashes(w io.Writer, pver uint32, f string, hashes []uint64) error {
+	err := validateCheapHashes(f, hashes)
+	if err != nil {
+		return err
+	}
+	err = writeVarInt(w, pver, uint64(len(hashes)))
+	if err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		err := binarySerializer.PutUint64(w, littleEndian, hash)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateCheapHashes returns an error attributed to the function f when the
+// provided list of cheap hashes would be rejected by writeCheapHashes.
+func validateCheapHashes(f string, hashes []uint64) error {
+	if len(hashes) > maxCheapHashesPerMsg {
+		str := fmt.Sprintf("too many transaction hashes for message "+
+			"[count %v, max %v]", len(hashes), maxCheapHashesPerMsg)
+		return messageError(f, str)
+	}
+	return nil
+}
+
+// cheapHashesEqual returns whether the provided lists contain the same cheap
+// hashes in the same order.
+func cheapHashesEqual(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// readRelayFilter reads the bloom filter used by the Xtreme Thinblocks and
+// Graphene messages, which has the same fields as the filters of BIP0037, from
+// r into the provided fields.  The function f is used to attribute any errors.
+func readRelayFilter(r io.Reader, pver uint32, f string, filter *[]byte, hashFuncs, tweak *uint32, flags *uint8) error {
+	size, err := readVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	if size > MaxXThinFilterSize {
+		str := fmt.Sprintf("filter size too large for message "+
+			"[size %v, max %v]", size, MaxXThinFilterSize)
+		return categorizedError(f, str, ErrCategoryOversized)
+	}
+	err = checkCountFits(r, f, "filter bytes", size, 1)
+	if err != nil {
+		return err
+	}
+	err = reserveAlloc(r, f, "filter bytes", size)
+	if err != nil {
+		return err
+	}
+	*filter = make([]byte, size)
+	_, err = io.ReadFull(r, *filter)
+	if err != nil {
+		return err
+	}
+
+	err = readElements(r, hashFuncs, tweak, flags)
+	if err != nil {
+		return err
+	}
+	if *hashFuncs > MaxXThinFilterHashFuncs {
+		str := fmt.Sprintf("too many filter hash functions for message "+
+			"[count %v, max %v]", *hashFuncs, MaxXThinFilterHashFuncs)
+		return categorizedError(f, str, ErrCategoryOversized)
+	}
+	return nil
+}
+
+// writeRelayFilter writes the provided bloom filter fields to w.
+func writeRelayFilter(w io.Writer, pver uint32, filter []byte, hashFuncs, tweak uint32, flags uint8) error {
+	err := writeVarInt(w, pver, uint64(len(filter)))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(filter)
+	if err != nil {
+		return err
+	}
+	return writeElements(w, hashFuncs, tweak, flags)
+}
+
+// validateRelayFilter returns an error attributed to the function f when the
+// provided bloom filter fields exceed the allowed limits.
+func validateRelayFilter(f string, filter []byte, hashFuncs uint32) error {
+	if len(filter) > MaxXThinFilterSize {
+		str := fmt.Sprintf("filter size too large for message "+
+			"[size %v, max %v]", len(filter), MaxXThinFilterSize)
+		return messageError(f, str)
+	}
+	if hashFuncs > MaxXThinFilterHashFuncs {
+		str := fmt.Sprintf("too many filter hash functions for message "+
+			"[count %v, max %v]", hashFuncs, MaxXThinFilterHashFuncs)
+		return messageError(f, str)
+	}
+	return nil
+}
+
+// relayFilterSerializeSize returns the number of bytes it would take to encode
+// a bloom filter with the provided filter bytes along with its hash funcs,
+// tweak, and flags.
+func relayFilterSerializeSize(filter []byte) int {
+	return varIntSerializeSize(uint64(len(filter))) + len(filter) + 9
+}
+
+// readTxList reads a list of transactions from r.  The function f is used to
+// attribute any errors.
+func readTxList(r io.Reader, pver uint32, f string) ([]*MsgTx, error) {
+	count, err := readVarInt(r, pver)
+	if err != nil {
+		return nil, err
+	}
+	if count > maxTxPerBlock {
+		str := fmt.Sprintf("too many transactions for message "+
+			"[count %v, max %v]", count, maxTxPerBlock)
+		return nil, categorizedError(f, str, ErrCategoryOversized)
+	}
+	err = checkCountFits(r, f, "transactions", count, minTxPayload)
+	if err != nil {
+		return nil, err
+	}
+	err = reserveAlloc(r, f, "transactions", count*txAllocSize)
+	if err != nil {
+		return nil, err
+	}
+
+	txns := make([]*MsgTx, 0, count)
+	for i := uint64(0); i < count; i++ {
+		tx := MsgTx{}
+		err := tx.BtcDecode(r, pver)
+		if err != nil {
+			return nil, err
+		}
+		txns = append(txns, &tx)
+	}
+	return txns, nil
+}
+
+// writeTxList writes the provided list of transactions to w.  The function f is
+// used to attribute any errors.
+func writeTxList(w io.Writer, pver uint32, f string, txns []*MsgTx) error {
+	if len(txns) > maxTxPerBlock {
+		str := fmt.Sprintf("too many transactions for message "+
+			"[count %v, max %v]", len(txns), maxTxPerBlock)
+		return messageError(f, str)
+	}
+	err := writeVarInt(w, pver, uint64(len(txns)))
+	if err != nil {
+		return err
+	}
+	for _, tx := range txns {
+		err := tx.BtcEncode(w, pver)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// txListSerializeSize returns the number of bytes it would take to encode the
+// provided list of transactions.
+func txListSerializeSize(pver uint32, txns []*MsgTx) int {
+	n := varIntSerializeSize(uint64(len(txns)))
+	for _, tx := range txns {
+		n += tx.SerializeSize(pver)
+	}
+	return n
+}
+
+// validateTxList returns an error attributed to the function f when the
+// provided list of transactions would be rejected by writeTxList.
+func validateTxList(f string, txns []*MsgTx) error {
+	if len(txns) > maxTxPerBlock {
+		str := fmt.Sprintf("too many transactions for message "+
+			"[count %v, max %v]", len(txns), maxTxPerBlock)
+		return messageError(f, str)
+	}
+	for _, tx := range txns {
+		err := tx.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
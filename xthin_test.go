@@ -0,0 +1,93 @@
+// Copyright (c) 2013 Conformal Systems LLC.
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcwire_test
+
+import (
+	"bytes"
+	"github.com/conformal/btcwire"
+	"github.com/davecgh/go-spew/spew"
+	"reflect"
+	"testing"
+)
+
+// TestRegisterXThinMessages ensures the Xtreme Thinblocks messages are only
+// recognized once they have been registered.
+func TestRegisterXThinMessages(t *testing.T) {
+	pver := btcwire.ProtocolVersion
+	btcnet := btcwire.MainNet
+
+	iv := btcwire.NewInvVect(btcwire.InvTypeBlock, &btcwire.GenesisHash)
+	thinBlock := btcwire.NewMsgXThinBlock(&blockOne.Header)
+	thinBlock.Header.TxnCount = 0
+	thinBlock.TxHashes = []uint64{1}
+	thinBlock.MissingTxs = []*btcwire.MsgTx{multiTx}
+	xBlockTx := btcwire.NewMsgXBlockTx(&btcwire.GenesisHash)
+	xBlockTx.Txs = []*btcwire.MsgTx{multiTx}
+	getXBlockTx := btcwire.NewMsgGetXBlockTx(&btcwire.GenesisHash)
+	getXBlockTx.AddTxHash(1)
+	tests := []btcwire.Message{
+		btcwire.NewMsgGetXThin(iv, []byte{0x01}, 1, 2, 3),
+		thinBlock,
+		xBlockTx,
+		getXBlockTx,
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		btcwire.TstUnregisterMessage(test.Command())
+
+		var buf bytes.Buffer
+		err := btcwire.WriteMessage(&buf, test, pver, btcnet)
+		if err != nil {
+			t.Errorf("WriteMessage #%d error %v", i, err)
+			continue
+		}
+		raw := buf.Bytes()
+
+		// The command is unrecognized before registration.
+		_, _, err = btcwire.ReadMessage(bytes.NewReader(raw), pver, btcnet)
+		merr, ok := err.(*btcwire.MessageError)
+		if !ok || merr.Category != btcwire.ErrCategoryUnknownCommand {
+			t.Errorf("ReadMessage #%d unregistered: wrong error got: %v",
+				i, err)
+			continue
+		}
+
+		btcwire.RegisterXThinMessages()
+		msg, _, err := btcwire.ReadMessage(bytes.NewReader(raw), pver,
+			btcnet)
+		if err != nil {
+			t.Errorf("ReadMessage #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(msg, test) {
+			t.Errorf("ReadMessage #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test))
+		}
+	}
+}
+
+// TestCheapHash ensures CheapHash returns the first 8 bytes of a hash as a
+// little-endian integer.
+func TestCheapHash(t *testing.T) {
+	tests := []struct {
+		hash btcwire.ShaHash
+		want uint64
+	}{
+		{btcwire.ShaHash{}, 0},
+		{btcwire.GenesisHash, 0x72b3f1b60a8ce26f},
+		{btcwire.ShaHash{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+			0xff}, 0x0807060504030201},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		got := btcwire.CheapHash(&test.hash)
+		if got != test.want {
+			t.Errorf("CheapHash #%d got: %x want: %x", i, got,
+				test.want)
+		}
+	}
+}